@@ -12,6 +12,8 @@ import (
 	"user-management-system/config"
 	"user-management-system/database"
 	"user-management-system/handlers"
+	"user-management-system/models"
+	"user-management-system/openapi"
 	"user-management-system/repositories"
 	"user-management-system/routes"
 	"user-management-system/services"
@@ -30,16 +32,89 @@ func main() {
 	// Initialize repositories
 	userCollection := database.GetCollection("users")
 	userRepo := repositories.NewUserRepository(userCollection)
+	auditRepo := repositories.NewAuditRepository(database.GetCollection("audit_events"))
+	webhookRepo := repositories.NewWebhookRepository(database.GetCollection("webhooks"))
+	webhookDeliveryRepo := repositories.NewWebhookDeliveryRepository(database.GetCollection("webhook_deliveries"))
+	idempotencyRepo := repositories.NewIdempotencyRepository(database.GetCollection("idempotency_keys"))
+	passwordTokenRepo := repositories.NewPasswordTokenRepository(database.GetCollection("password_tokens"))
+	jobRepo := repositories.NewJobRepository(database.GetCollection("jobs"))
+	jobFileRepo := repositories.NewJobFileRepository(database.Database)
+	invitationRepo := repositories.NewInvitationRepository(database.GetCollection("invitations"))
+	orgRepo := repositories.NewOrganizationRepository(database.GetCollection("organizations"))
+	groupRepo := repositories.NewGroupRepository(database.GetCollection("groups"))
+	groupMemberRepo := repositories.NewGroupMemberRepository(database.GetCollection("group_members"))
+	apiKeyRepo := repositories.NewAPIKeyRepository(database.GetCollection("api_keys"))
+	oauthClientRepo := repositories.NewOAuthClientRepository(database.GetCollection("oauth_clients"))
+	oauthTokenRepo := repositories.NewOAuthTokenRepository(database.GetCollection("oauth_tokens"))
+	federationStateRepo := repositories.NewFederationStateRepository(database.GetCollection("federation_states"))
 
 	// Initialize services
-	userService := services.NewUserService(userRepo)
+	auditService := services.NewAuditService(auditRepo)
+	webhookClient := &http.Client{Timeout: cfg.WebhookTimeout}
+	webhookService := services.NewWebhookService(webhookRepo, webhookDeliveryRepo, webhookClient, cfg.WebhookMaxAttempts)
+	orgService := services.NewOrganizationService(orgRepo, userRepo, groupMemberRepo, auditService)
+	defaultOrg := ensureDefaultOrganization(orgService, cfg)
+	mailer := newMailer(cfg)
+	passwordHasher, err := services.NewPasswordHasher(cfg.PasswordHashAlgorithm, cfg.BcryptCost, cfg.Argon2Memory, cfg.Argon2Time, cfg.Argon2Threads)
+	if err != nil {
+		log.Fatalf("Invalid password hashing settings: %v", err)
+	}
+	passwordPolicy := newPasswordPolicy(cfg, passwordHasher)
+	passwordSetService := services.NewPasswordSetService(userRepo, passwordTokenRepo, passwordPolicy, passwordHasher, mailer, auditService, cfg.PasswordSetURL, cfg.PasswordSetTokenTTL)
+	emailChangeService := services.NewEmailChangeService(userRepo, passwordTokenRepo, passwordSetService, mailer, auditService, webhookService, cfg.EmailChangeURL, cfg.EmailChangeTokenTTL, cfg.EmailRevertURL, cfg.EmailRevertTokenTTL)
+	userService := services.NewUserService(userRepo, auditService, webhookService, emailChangeService, passwordPolicy, passwordHasher, defaultOrg.ID.Hex(), newAuthenticators(cfg)...)
+	scimService := services.NewSCIMService(userService, userRepo)
+	invitationService := services.NewInvitationService(invitationRepo, userRepo, userService, mailer, auditService, webhookService, cfg.InvitationURL, cfg.InvitationTTL)
+	jobService := services.NewJobService(jobRepo, jobFileRepo, cfg.JobLease, cfg.JobMaxAttempts)
+	groupService := services.NewGroupService(groupRepo, groupMemberRepo, userRepo, auditService)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userRepo, userService, auditService, webhookService)
+	signingKey, err := services.LoadSigningKey(cfg.OIDCSigningKeyFile)
+	if err != nil {
+		log.Fatalf("Invalid OIDC signing key: %v", err)
+	}
+	if cfg.OIDCSigningKeyFile == "" {
+		log.Printf("⚠️  OIDC_SIGNING_KEY_FILE is not set; ID tokens are signed with a key generated at startup")
+	}
+	oauthService := services.NewOAuthService(oauthClientRepo, oauthTokenRepo, userRepo, userService, auditService, signingKey, cfg)
+	federationClient := &http.Client{Timeout: 10 * time.Second}
+	federationService, err := services.NewFederationService(cfg.FederationProviders, federationStateRepo, userRepo, userService, auditService, webhookService, federationClient, cfg.FederationRedirectURL, cfg.FederationStateTTL)
+	if err != nil {
+		log.Fatalf("Invalid federation settings: %v", err)
+	}
+	userBulkService := services.NewUserBulkService(userRepo, userService, auditService, webhookService, passwordSetService, jobService)
+
+	// Create the initial admin account if configured
+	bootstrapAdmin(userService, cfg)
+
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	userService.StartDeletedUserPurger(workerCtx, cfg.DeletedUserRetention, cfg.PurgeInterval)
+	webhookService.StartDispatcher(workerCtx, cfg.WebhookPollInterval, cfg.WebhookWorkers)
+	jobService.StartRunner(workerCtx, cfg.JobPollInterval, cfg.JobWorkers)
+	jobService.StartJobPurger(workerCtx, cfg.JobRetention, cfg.PurgeInterval)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(userService, cfg)
-	userHandler := handlers.NewUserHandler(userService)
+	authHandler := handlers.NewAuthHandler(userService, passwordSetService, invitationService, orgService, emailChangeService, cfg)
+	userHandler := handlers.NewUserHandler(userService, groupService)
+	userBulkHandler := handlers.NewUserBulkHandler(userBulkService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	scimHandler := handlers.NewSCIMHandler(scimService)
+	jobHandler := handlers.NewJobHandler(jobService)
+	invitationHandler := handlers.NewInvitationHandler(invitationService)
+	organizationHandler := handlers.NewOrganizationHandler(orgService)
+	groupHandler := handlers.NewGroupHandler(groupService)
+	meHandler := handlers.NewMeHandler(userService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService, cfg)
+	federationHandler := handlers.NewFederationHandler(federationService, cfg)
 
 	// Setup routes
-	router := routes.SetupRoutes(userService, authHandler, userHandler, cfg)
+	router := routes.SetupRoutes(userService, authHandler, userHandler, userBulkHandler, auditHandler, webhookHandler, scimHandler, jobHandler, invitationHandler, organizationHandler, groupHandler, meHandler, apiKeyHandler, oauthHandler, federationHandler, groupService, apiKeyService, idempotencyRepo, cfg)
+	for _, route := range openapi.Undocumented(router) {
+		log.Printf("⚠️  Route %s has no OpenAPI entry", route)
+	}
 
 	// Create HTTP server
 	server := &http.Server{
@@ -55,6 +130,7 @@ func main() {
 		log.Printf("🚀 Server starting on port %s", cfg.AppPort)
 		log.Printf("🌐 Root endpoint: http://localhost:%s/", cfg.AppPort)
 		log.Printf("📝 API endpoints available at http://localhost:%s/api", cfg.AppPort)
+		log.Printf("📖 API docs available at http://localhost:%s/docs", cfg.AppPort)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
@@ -75,6 +151,84 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Stop background workers and let running jobs hand back their leases
+	stopWorkers()
+	if err := jobService.Shutdown(ctx); err != nil {
+		log.Printf("⚠️  Jobs still running at shutdown will be resumed after their lease expires")
+	}
+
 	log.Println("✅ Server exited")
 }
 
+// ensureDefaultOrganization creates the default organization if needed and
+// moves users from before organizations existed into it
+func ensureDefaultOrganization(orgService *services.OrganizationService, cfg *config.Config) *models.Organization {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	org, err := orgService.EnsureDefaultOrganization(ctx, cfg.DefaultOrgName)
+	if err != nil {
+		log.Fatalf("Failed to set up default organization: %v", err)
+	}
+	return org
+}
+
+// bootstrapAdmin creates the initial admin from ADMIN_EMAIL when no admin exists yet
+func bootstrapAdmin(userService *services.UserService, cfg *config.Config) {
+	if cfg.AdminEmail == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	admin, generatedPassword, err := userService.BootstrapAdmin(ctx, cfg.AdminName, cfg.AdminEmail, cfg.AdminPassword)
+	if err != nil {
+		log.Fatalf("Failed to bootstrap admin account: %v", err)
+	}
+	if admin == nil {
+		return
+	}
+
+	log.Printf("👤 Created initial admin account %s", admin.Email)
+	if generatedPassword != "" {
+		log.Printf("🔑 One-time admin password: %s (must be changed on first login via POST /api/auth/change-password)", generatedPassword)
+	}
+}
+
+// newMailer returns an SMTP mailer when SMTP_HOST is set, otherwise one that logs messages
+func newMailer(cfg *config.Config) services.Mailer {
+	if cfg.SMTPHost == "" {
+		log.Println("✉️  SMTP_HOST not set; emails will be written to the log")
+		return services.LogMailer{}
+	}
+	return services.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+}
+
+// newAuthenticators returns the directories logins are checked against after
+// local passwords: LDAP when LDAP_URL is set
+func newAuthenticators(cfg *config.Config) []services.Authenticator {
+	var authenticators []services.Authenticator
+	if cfg.LDAPURL != "" {
+		ldapAuthenticator, err := services.NewLDAPAuthenticator(cfg)
+		if err != nil {
+			log.Fatalf("Invalid LDAP settings: %v", err)
+		}
+		log.Printf("📒 LDAP authentication enabled against %s", cfg.LDAPURL)
+		authenticators = append(authenticators, ldapAuthenticator)
+	}
+	return authenticators
+}
+
+// newPasswordPolicy builds the password policy for passwords hashed with
+// hasher, loading the breached password list if configured
+func newPasswordPolicy(cfg *config.Config, hasher services.PasswordHasher) *services.PasswordPolicy {
+	policy, err := services.NewPasswordPolicyFromConfig(cfg, hasher)
+	if err != nil {
+		log.Fatalf("Failed to load breached passwords: %v", err)
+	}
+	if policy.Breached != nil {
+		log.Printf("🔒 Loaded %d breached password hashes", policy.Breached.Len())
+	}
+	return policy
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"user-management-system/config"
+	"user-management-system/database"
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/services"
+)
+
+// Exit codes returned by userctl
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// command describes a userctl subcommand
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, app *app, args []string) error
+}
+
+// app holds the dependencies shared by all subcommands
+type app struct {
+	userService *services.UserService
+	out         io.Writer
+	in          io.Reader
+}
+
+// usageError marks errors caused by invalid command line input
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+var commands = []command{
+	{"create", "create -name NAME -email EMAIL [-password PASS | -password-stdin] [-role user|admin] [-inactive]", runCreate},
+	{"get", "get (-id ID | -email EMAIL)", runGet},
+	{"list", "list [-page N] [-limit N] [-role ROLE] [-active true|false] [-include-deleted]", runList},
+	{"search", "search [-page N] [-limit N] [-role ROLE] [-active true|false] [-include-deleted] QUERY", runSearch},
+	{"reset-password", "reset-password (-id ID | -email EMAIL) [-password PASS | -password-stdin]", runResetPassword},
+	{"activate", "activate (-id ID | -email EMAIL)", runActivate},
+	{"deactivate", "deactivate (-id ID | -email EMAIL)", runDeactivate},
+	{"set-role", "set-role (-id ID | -email EMAIL) -role user|admin", runSetRole},
+	{"delete", "delete (-id ID | -email EMAIL)", runDelete},
+	{"restore", "restore -id ID", runRestore},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run dispatches to the requested subcommand and returns the process exit code
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == args[0] {
+			cmd = &commands[i]
+			break
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "userctl: unknown command %q\n\n", args[0])
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+
+	// Keep database logs off stdout so JSON output stays machine readable
+	log.SetOutput(os.Stderr)
+
+	// Load configuration
+	cfg := config.LoadConfig()
+
+	// Connect to MongoDB
+	if err := database.Connect(cfg.MongoURI, cfg.MongoDB); err != nil {
+		fmt.Fprintf(os.Stderr, "userctl: failed to connect to MongoDB: %v\n", err)
+		return exitFailure
+	}
+	defer database.Disconnect()
+
+	userRepo := repositories.NewUserRepository(database.GetCollection("users"))
+	auditRepo := repositories.NewAuditRepository(database.GetCollection("audit_events"))
+	webhookRepo := repositories.NewWebhookRepository(database.GetCollection("webhooks"))
+	webhookDeliveryRepo := repositories.NewWebhookDeliveryRepository(database.GetCollection("webhook_deliveries"))
+	orgRepo := repositories.NewOrganizationRepository(database.GetCollection("organizations"))
+	groupMemberRepo := repositories.NewGroupMemberRepository(database.GetCollection("group_members"))
+	auditService := services.NewAuditService(auditRepo)
+
+	// Events are only queued here; the API server's dispatcher delivers them
+	webhookService := services.NewWebhookService(webhookRepo, webhookDeliveryRepo, http.DefaultClient, cfg.WebhookMaxAttempts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// Users created with the CLI join the default organization
+	defaultOrg, err := services.NewOrganizationService(orgRepo, userRepo, groupMemberRepo, auditService).EnsureDefaultOrganization(ctx, cfg.DefaultOrgName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "userctl: failed to set up default organization: %v\n", err)
+		return exitFailure
+	}
+
+	passwordHasher, err := services.NewPasswordHasher(cfg.PasswordHashAlgorithm, cfg.BcryptCost, cfg.Argon2Memory, cfg.Argon2Time, cfg.Argon2Threads)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "userctl: invalid password hashing settings: %v\n", err)
+		return exitFailure
+	}
+
+	// Passwords set with the CLI follow the same policy as the API
+	passwordPolicy, err := services.NewPasswordPolicyFromConfig(cfg, passwordHasher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "userctl: failed to load breached passwords: %v\n", err)
+		return exitFailure
+	}
+
+	a := &app{
+		userService: services.NewUserService(userRepo, auditService, webhookService, nil, passwordPolicy, passwordHasher, defaultOrg.ID.Hex()),
+		out:         os.Stdout,
+		in:          os.Stdin,
+	}
+
+	ctx = operatorContext(ctx, operatorName())
+
+	if err := cmd.run(ctx, a, args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "userctl %s: %v\n", cmd.name, err)
+		if _, ok := err.(*usageError); ok {
+			fmt.Fprintf(os.Stderr, "usage: userctl %s\n", cmd.usage)
+			return exitUsage
+		}
+		return exitFailure
+	}
+
+	return exitOK
+}
+
+// operatorContext attributes audit events to the operator running the CLI.
+// The operator has database access, so they act as a super-admin and a
+// trusted provisioner.
+func operatorContext(ctx context.Context, operator string) context.Context {
+	ctx = context.WithValue(ctx, middleware.UserIDKey, "cli:"+operator)
+	ctx = context.WithValue(ctx, middleware.ProvisionerKey, true)
+	return context.WithValue(ctx, middleware.SuperAdminKey, true)
+}
+
+// operatorName returns the OS user running userctl, for audit attribution
+func operatorName() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return "unknown"
+}
+
+// printUsage writes the list of available subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "userctl - manage users of the user management system")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  userctl %s [-output table|json]\n", cmd.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Configuration is read from the same environment variables as the API server.")
+}
+
+// newFlagSet creates a flag set with the shared -output flag
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	output := fs.String("output", "table", "Output format: table or json")
+	return fs, output
+}
+
+// parseFlags parses args and validates the -output flag
+func parseFlags(fs *flag.FlagSet, output *string, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	if *output != "table" && *output != "json" {
+		return &usageError{msg: "output must be either table or json"}
+	}
+	return nil
+}
+
+// addUserSelector registers the -id and -email flags used to pick a single user
+func addUserSelector(fs *flag.FlagSet) (*string, *string) {
+	id := fs.String("id", "", "User ID")
+	email := fs.String("email", "", "User email")
+	return id, email
+}
+
+// resolveUser finds the user referenced by -id or -email
+func (a *app) resolveUser(ctx context.Context, id, email string) (*models.UserResponse, error) {
+	switch {
+	case id != "" && email != "":
+		return nil, &usageError{msg: "use either -id or -email, not both"}
+	case id != "":
+		return a.userService.GetUserByID(ctx, id)
+	case email != "":
+		return a.userService.GetUserByEmail(ctx, email)
+	default:
+		return nil, &usageError{msg: "-id or -email is required"}
+	}
+}
+
+// readPassword returns the -password value or reads a single line from stdin
+func (a *app) readPassword(password string, fromStdin bool) (string, error) {
+	if password != "" && fromStdin {
+		return "", &usageError{msg: "use either -password or -password-stdin, not both"}
+	}
+	if !fromStdin {
+		if password == "" {
+			return "", &usageError{msg: "-password or -password-stdin is required"}
+		}
+		return password, nil
+	}
+
+	line, err := bufio.NewReader(a.in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read password from stdin: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", &usageError{msg: "empty password read from stdin"}
+	}
+	return line, nil
+}
+
+// parseActiveFlag converts the -active flag into an optional bool
+func parseActiveFlag(value string) (*bool, error) {
+	switch value {
+	case "":
+		return nil, nil
+	case "true":
+		active := true
+		return &active, nil
+	case "false":
+		active := false
+		return &active, nil
+	default:
+		return nil, &usageError{msg: "active must be either true or false"}
+	}
+}
+
+func runCreate(ctx context.Context, a *app, args []string) error {
+	fs, output := newFlagSet("create")
+	name := fs.String("name", "", "Full name")
+	email := fs.String("email", "", "Email address")
+	password := fs.String("password", "", "Password")
+	passwordStdin := fs.Bool("password-stdin", false, "Read the password from stdin")
+	role := fs.String("role", "user", "Role: user or admin")
+	inactive := fs.Bool("inactive", false, "Create the account deactivated")
+	if err := parseFlags(fs, output, args); err != nil {
+		return err
+	}
+
+	if *name == "" || *email == "" {
+		return &usageError{msg: "-name and -email are required"}
+	}
+
+	pass, err := a.readPassword(*password, *passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	isActive := !*inactive
+	user, err := a.userService.CreateUser(ctx, &models.CreateUserRequest{
+		Name:     *name,
+		Email:    *email,
+		Password: pass,
+		Role:     *role,
+		IsActive: &isActive,
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.printUsers(*output, []*models.UserResponse{user})
+}
+
+func runGet(ctx context.Context, a *app, args []string) error {
+	fs, output := newFlagSet("get")
+	id, email := addUserSelector(fs)
+	if err := parseFlags(fs, output, args); err != nil {
+		return err
+	}
+
+	user, err := a.resolveUser(ctx, *id, *email)
+	if err != nil {
+		return err
+	}
+
+	return a.printUsers(*output, []*models.UserResponse{user})
+}
+
+func runList(ctx context.Context, a *app, args []string) error {
+	return a.listUsers(ctx, "list", args, false)
+}
+
+func runSearch(ctx context.Context, a *app, args []string) error {
+	return a.listUsers(ctx, "search", args, true)
+}
+
+// listUsers implements both list and search, which differ only by the positional query
+func (a *app) listUsers(ctx context.Context, name string, args []string, requireQuery bool) error {
+	fs, output := newFlagSet(name)
+	page := fs.Int("page", 1, "Page number")
+	limit := fs.Int("limit", 20, "Users per page (max 100)")
+	role := fs.String("role", "", "Only users with this role")
+	active := fs.String("active", "", "Only active (true) or inactive (false) users")
+	includeDeleted := fs.Bool("include-deleted", false, "Also list soft-deleted users")
+	if err := parseFlags(fs, output, args); err != nil {
+		return err
+	}
+
+	isActive, err := parseActiveFlag(*active)
+	if err != nil {
+		return err
+	}
+
+	filter := models.UserFilter{Role: *role, IsActive: isActive, IncludeDeleted: *includeDeleted}
+	if requireQuery {
+		if fs.NArg() != 1 {
+			return &usageError{msg: "exactly one search query is required"}
+		}
+		filter.Search = fs.Arg(0)
+	} else if fs.NArg() != 0 {
+		return &usageError{msg: "unexpected arguments: " + strings.Join(fs.Args(), " ")}
+	}
+
+	users, totalPages, total, err := a.userService.SearchUsers(ctx, filter, *page, *limit)
+	if err != nil {
+		return err
+	}
+
+	if *output == "json" {
+		return writeJSON(a.out, map[string]interface{}{
+			"data":       users,
+			"page":       *page,
+			"limit":      *limit,
+			"total":      total,
+			"totalPages": totalPages,
+		})
+	}
+
+	if err := writeUserTable(a.out, users); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(a.out, "\nPage %d of %d (%d users total)\n", *page, totalPages, total)
+	return err
+}
+
+func runResetPassword(ctx context.Context, a *app, args []string) error {
+	fs, output := newFlagSet("reset-password")
+	id, email := addUserSelector(fs)
+	password := fs.String("password", "", "New password")
+	passwordStdin := fs.Bool("password-stdin", false, "Read the new password from stdin")
+	if err := parseFlags(fs, output, args); err != nil {
+		return err
+	}
+
+	pass, err := a.readPassword(*password, *passwordStdin)
+	if err != nil {
+		return err
+	}
+	return a.updateUser(ctx, *output, *id, *email, &models.UpdateUserRequest{Password: pass})
+}
+
+func runActivate(ctx context.Context, a *app, args []string) error {
+	return a.setActive(ctx, "activate", args, true)
+}
+
+func runDeactivate(ctx context.Context, a *app, args []string) error {
+	return a.setActive(ctx, "deactivate", args, false)
+}
+
+// setActive implements activate and deactivate
+func (a *app) setActive(ctx context.Context, name string, args []string, active bool) error {
+	fs, output := newFlagSet(name)
+	id, email := addUserSelector(fs)
+	if err := parseFlags(fs, output, args); err != nil {
+		return err
+	}
+
+	return a.updateUser(ctx, *output, *id, *email, &models.UpdateUserRequest{IsActive: &active})
+}
+
+func runSetRole(ctx context.Context, a *app, args []string) error {
+	fs, output := newFlagSet("set-role")
+	id, email := addUserSelector(fs)
+	role := fs.String("role", "", "New role: user or admin")
+	if err := parseFlags(fs, output, args); err != nil {
+		return err
+	}
+
+	if *role != "user" && *role != "admin" {
+		return &usageError{msg: "-role must be either user or admin"}
+	}
+
+	return a.updateUser(ctx, *output, *id, *email, &models.UpdateUserRequest{Role: *role})
+}
+
+// updateUser resolves the selected user, applies the update and prints the result
+func (a *app) updateUser(ctx context.Context, output, id, email string, req *models.UpdateUserRequest) error {
+	user, err := a.resolveUser(ctx, id, email)
+	if err != nil {
+		return err
+	}
+
+	updated, err := a.userService.UpdateUser(ctx, user.ID, req)
+	if err != nil {
+		return err
+	}
+
+	return a.printUsers(output, []*models.UserResponse{updated})
+}
+
+func runDelete(ctx context.Context, a *app, args []string) error {
+	fs, output := newFlagSet("delete")
+	id, email := addUserSelector(fs)
+	if err := parseFlags(fs, output, args); err != nil {
+		return err
+	}
+
+	user, err := a.resolveUser(ctx, *id, *email)
+	if err != nil {
+		return err
+	}
+
+	if err := a.userService.DeleteUser(ctx, user.ID); err != nil {
+		return err
+	}
+
+	if *output == "json" {
+		return writeJSON(a.out, map[string]interface{}{"deleted": user.ID})
+	}
+	_, err = fmt.Fprintf(a.out, "Deleted user %s (%s); restore with: userctl restore -id %s\n", user.ID, user.Email, user.ID)
+	return err
+}
+
+func runRestore(ctx context.Context, a *app, args []string) error {
+	fs, output := newFlagSet("restore")
+	id := fs.String("id", "", "User ID")
+	if err := parseFlags(fs, output, args); err != nil {
+		return err
+	}
+
+	if *id == "" {
+		return &usageError{msg: "-id is required"}
+	}
+
+	user, err := a.userService.RestoreUser(ctx, *id)
+	if err != nil {
+		return err
+	}
+
+	return a.printUsers(*output, []*models.UserResponse{user})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/mongotest"
+	"user-management-system/repositories"
+	"user-management-system/services"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestApp returns an app on an empty in-memory database and the context
+// subcommands run in
+func newTestApp(t *testing.T) (*app, *bytes.Buffer, context.Context) {
+	t.Helper()
+
+	db := mongotest.NewDatabase(t)
+	userRepo := repositories.NewUserRepository(db.Collection("users"))
+	auditService := services.NewAuditService(repositories.NewAuditRepository(db.Collection("audit_events")))
+	webhookService := services.NewWebhookService(repositories.NewWebhookRepository(db.Collection("webhooks")), repositories.NewWebhookDeliveryRepository(db.Collection("webhook_deliveries")), http.DefaultClient, 3)
+	orgService := services.NewOrganizationService(repositories.NewOrganizationRepository(db.Collection("organizations")), userRepo, repositories.NewGroupMemberRepository(db.Collection("group_members")), auditService)
+
+	ctx := context.Background()
+	defaultOrg, err := orgService.EnsureDefaultOrganization(ctx, "Default")
+	if err != nil {
+		t.Fatalf("EnsureDefaultOrganization: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	a := &app{
+		userService: services.NewUserService(userRepo, auditService, webhookService, nil, &services.PasswordPolicy{MinLength: 8}, services.BcryptHasher{Cost: bcrypt.MinCost}, defaultOrg.ID.Hex()),
+		out:         out,
+		in:          strings.NewReader(""),
+	}
+	return a, out, operatorContext(ctx, "tester")
+}
+
+// runJSON runs a subcommand with -output json and decodes what it printed
+func runJSON(t *testing.T, a *app, out *bytes.Buffer, ctx context.Context, run func(context.Context, *app, []string) error, args ...string) *models.UserResponse {
+	t.Helper()
+
+	out.Reset()
+	if err := run(ctx, a, append(args, "-output", "json")); err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	var user models.UserResponse
+	if err := json.Unmarshal(out.Bytes(), &user); err != nil {
+		t.Fatalf("decoding %q: %v", out.String(), err)
+	}
+	return &user
+}
+
+func TestCreateAndGet(t *testing.T) {
+	a, out, ctx := newTestApp(t)
+
+	created := runJSON(t, a, out, ctx, runCreate, "-name", "Jane Doe", "-email", "Jane@Example.com", "-password", "correct horse", "-role", "admin")
+	if created.Email != "jane@example.com" || created.Role != "admin" || !created.IsActive {
+		t.Fatalf("created user = %+v", created)
+	}
+
+	byID := runJSON(t, a, out, ctx, runGet, "-id", created.ID)
+	byEmail := runJSON(t, a, out, ctx, runGet, "-email", "jane@example.com")
+	if byID.ID != created.ID || byEmail.ID != created.ID {
+		t.Fatalf("get returned %s and %s, want %s", byID.ID, byEmail.ID, created.ID)
+	}
+}
+
+func TestCreateReadsPasswordFromStdin(t *testing.T) {
+	a, out, ctx := newTestApp(t)
+	a.in = strings.NewReader("from stdin pass\n")
+
+	created := runJSON(t, a, out, ctx, runCreate, "-name", "Jane Doe", "-email", "jane@example.com", "-password-stdin")
+	if !created.IsActive {
+		t.Fatal("user was created inactive")
+	}
+
+	user, err := a.userService.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "from stdin pass"})
+	if err != nil || user == nil || user.ID.Hex() != created.ID {
+		t.Fatalf("Login with the stdin password = %v, %v; want jane", user, err)
+	}
+	if _, err := a.userService.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "from stdin pass\n"}); err == nil {
+		t.Fatal("Login accepted the password with the newline stdin ends with")
+	}
+
+	inactive := runJSON(t, a, out, ctx, runCreate, "-name", "John Roe", "-email", "john@example.com", "-password", "correct horse", "-inactive")
+	if inactive.IsActive {
+		t.Fatal("-inactive user was created active")
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	a, _, ctx := newTestApp(t)
+
+	tests := []struct {
+		name string
+		run  func(context.Context, *app, []string) error
+		args []string
+	}{
+		{"missing name", runCreate, []string{"-email", "jane@example.com", "-password", "correct horse"}},
+		{"missing password", runCreate, []string{"-name", "Jane", "-email", "jane@example.com"}},
+		{"both passwords", runCreate, []string{"-name", "Jane", "-email", "jane@example.com", "-password", "x", "-password-stdin"}},
+		{"bad output", runGet, []string{"-id", "x", "-output", "xml"}},
+		{"id and email", runGet, []string{"-id", "x", "-email", "jane@example.com"}},
+		{"no selector", runActivate, nil},
+		{"bad role", runSetRole, []string{"-email", "jane@example.com", "-role", "owner"}},
+		{"bad active", runList, []string{"-active", "yes"}},
+		{"search without query", runSearch, nil},
+		{"restore without id", runRestore, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(ctx, a, tt.args)
+			if _, ok := err.(*usageError); !ok {
+				t.Fatalf("error = %v, want a usage error", err)
+			}
+		})
+	}
+}
+
+func TestUpdateCommands(t *testing.T) {
+	a, out, ctx := newTestApp(t)
+	created := runJSON(t, a, out, ctx, runCreate, "-name", "Jane Doe", "-email", "jane@example.com", "-password", "correct horse")
+
+	if user := runJSON(t, a, out, ctx, runSetRole, "-email", "jane@example.com", "-role", "admin"); user.Role != "admin" {
+		t.Fatalf("set-role: role = %s", user.Role)
+	}
+	if user := runJSON(t, a, out, ctx, runDeactivate, "-id", created.ID); user.IsActive {
+		t.Fatal("deactivate left the user active")
+	}
+	if user := runJSON(t, a, out, ctx, runActivate, "-id", created.ID); !user.IsActive {
+		t.Fatal("activate left the user inactive")
+	}
+
+	runJSON(t, a, out, ctx, runResetPassword, "-id", created.ID, "-password", "battery staple")
+	if _, err := a.userService.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "battery staple"}); err != nil {
+		t.Fatalf("Login with the reset password: %v", err)
+	}
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	a, out, ctx := newTestApp(t)
+	created := runJSON(t, a, out, ctx, runCreate, "-name", "Jane Doe", "-email", "jane@example.com", "-password", "correct horse")
+
+	out.Reset()
+	if err := runDelete(ctx, a, []string{"-email", "jane@example.com"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if !strings.Contains(out.String(), "userctl restore -id "+created.ID) {
+		t.Fatalf("delete output %q does not explain how to restore", out.String())
+	}
+	if err := runGet(ctx, a, []string{"-id", created.ID}); err == nil {
+		t.Fatal("deleted user is still found")
+	}
+
+	if user := runJSON(t, a, out, ctx, runRestore, "-id", created.ID); user.ID != created.ID {
+		t.Fatalf("restore returned %s", user.ID)
+	}
+	runJSON(t, a, out, ctx, runGet, "-id", created.ID)
+}
+
+func TestListAndSearch(t *testing.T) {
+	a, out, ctx := newTestApp(t)
+	runJSON(t, a, out, ctx, runCreate, "-name", "Jane Doe", "-email", "jane@example.com", "-password", "correct horse")
+	runJSON(t, a, out, ctx, runCreate, "-name", "John Roe", "-email", "john@example.com", "-password", "correct horse", "-inactive")
+
+	var page struct {
+		Data  []*models.UserResponse `json:"data"`
+		Total int64                  `json:"total"`
+	}
+	list := func(run func(context.Context, *app, []string) error, args ...string) {
+		t.Helper()
+		out.Reset()
+		if err := run(ctx, a, append([]string{"-output", "json"}, args...)); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		page.Data = nil
+		if err := json.Unmarshal(out.Bytes(), &page); err != nil {
+			t.Fatalf("decoding %q: %v", out.String(), err)
+		}
+	}
+
+	list(runList)
+	if page.Total != 2 || len(page.Data) != 2 {
+		t.Fatalf("list: total %d, %d users", page.Total, len(page.Data))
+	}
+
+	list(runList, "-active", "false")
+	if page.Total != 1 || page.Data[0].Email != "john@example.com" {
+		t.Fatalf("list -active false: %+v", page.Data)
+	}
+
+	list(runSearch, "jane")
+	if page.Total != 1 || page.Data[0].Email != "jane@example.com" {
+		t.Fatalf("search jane: %+v", page.Data)
+	}
+
+	out.Reset()
+	if err := runList(ctx, a, nil); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if !strings.Contains(out.String(), "jane@example.com") || !strings.Contains(out.String(), "(2 users total)") {
+		t.Fatalf("table output:\n%s", out.String())
+	}
+}
+
+func TestOperatorIsTrustedProvisioner(t *testing.T) {
+	ctx := operatorContext(context.Background(), "tester")
+	if !middleware.IsProvisioner(ctx) || !middleware.IsSuperAdmin(ctx) || middleware.GetUserID(ctx) != "cli:tester" {
+		t.Fatal("the CLI operator is not a super-admin provisioner")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"user-management-system/models"
+)
+
+// printUsers writes users in the requested output format
+func (a *app) printUsers(output string, users []*models.UserResponse) error {
+	if output == "json" {
+		if len(users) == 1 {
+			return writeJSON(a.out, users[0])
+		}
+		return writeJSON(a.out, users)
+	}
+	return writeUserTable(a.out, users)
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeUserTable writes users as an aligned text table
+func writeUserTable(w io.Writer, users []*models.UserResponse) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tROLE\tACTIVE\tCREATED")
+	for _, user := range users {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%s\n",
+			user.ID,
+			user.Name,
+			user.Email,
+			user.Role,
+			user.IsActive,
+			user.CreatedAt.Format(time.RFC3339),
+		)
+	}
+	return tw.Flush()
+}
@@ -4,17 +4,159 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	AppPort       string
-	MongoURI      string
-	MongoDB       string
-	JWTSecret     string
+	AppPort        string
+	MongoURI       string
+	MongoDB        string
+	JWTSecret      string
 	JWTExpireHours int
+
+	// Initial admin account created on first start (optional)
+	AdminName     string
+	AdminEmail    string
+	AdminPassword string
+
+	// Password policy; the maximum is capped at what the hashing algorithm takes
+	PasswordMinLength        int
+	PasswordMaxLength        int
+	PasswordRequireUppercase bool
+	PasswordRequireLowercase bool
+	PasswordRequireDigit     bool
+	PasswordRequireSymbol    bool
+
+	// File of SHA-1 hashes of breached or common passwords to reject (empty disables the check)
+	PasswordBreachedFile string
+
+	// How many latest passwords may not be reused, and how long passwords of
+	// regular and admin accounts stay valid (0 disables each)
+	PasswordHistorySize int
+	PasswordMaxAge      time.Duration
+	AdminPasswordMaxAge time.Duration
+
+	// Algorithm and parameters new password hashes use; older hashes are upgraded on login
+	PasswordHashAlgorithm string
+	BcryptCost            int
+	Argon2Memory          uint32 // KiB
+	Argon2Time            uint32
+	Argon2Threads         uint8
+
+	// Soft-deleted users are purged after DeletedUserRetention (0 disables purging)
+	DeletedUserRetention time.Duration
+	PurgeInterval        time.Duration
+
+	// Webhook delivery settings
+	WebhookMaxAttempts  int
+	WebhookTimeout      time.Duration
+	WebhookPollInterval time.Duration
+	WebhookWorkers      int
+
+	// Bearer token for the identity provider's SCIM client (empty disables SCIM)
+	SCIMToken string
+
+	// How long responses to requests with an Idempotency-Key are kept for replay
+	IdempotencyTTL time.Duration
+
+	// Outgoing mail; without SMTPHost messages are only logged
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+
+	// Page that receives password-set tokens (?token=) and how long they stay valid
+	PasswordSetURL      string
+	PasswordSetTokenTTL time.Duration
+
+	// Page that receives invitation tokens (?token=) and how long invitations stay valid
+	InvitationURL string
+	InvitationTTL time.Duration
+
+	// Page that receives email change confirmation tokens (?token=) and how long they stay valid
+	EmailChangeURL      string
+	EmailChangeTokenTTL time.Duration
+
+	// Page that receives email revert tokens (?token=), mailed to the old address, and how long they stay valid
+	EmailRevertURL      string
+	EmailRevertTokenTTL time.Duration
+
+	// Close self-registration so new users can only join through an invitation
+	InvitationOnly bool
+
+	// Name of the organization self-registered users and pre-existing users join
+	DefaultOrgName string
+
+	// OAuth 2.0 authorization server: the issuer is the public base URL of
+	// this service; codes, access and refresh tokens and sign-in sessions
+	// last for their TTLs
+	OAuthIssuer          string
+	OAuthCodeTTL         time.Duration
+	OAuthAccessTokenTTL  time.Duration
+	OAuthRefreshTokenTTL time.Duration
+	OAuthSessionTTL      time.Duration
+
+	// OIDCSigningKeyFile is a PEM RSA private key for signing ID tokens; a
+	// key is generated at startup when it is empty
+	OIDCSigningKeyFile string
+
+	// External OpenID Connect providers users can sign in with. The provider
+	// sends users back to FederationRedirectURL, and a login must complete
+	// within FederationStateTTL.
+	FederationProviders   []FederationProvider
+	FederationRedirectURL string
+	FederationStateTTL    time.Duration
+
+	// LDAP directory that checks passwords local accounts do not match (empty
+	// LDAPURL disables it). Users are bound as LDAPBindDNTemplate, or found
+	// under LDAPBaseDN with LDAPUserFilter, as LDAPBindDN, and bound as. Members
+	// of LDAPAdminGroups are admins of the default organization.
+	LDAPURL            string
+	LDAPStartTLS       bool
+	LDAPCAFile         string
+	LDAPTimeout        time.Duration
+	LDAPBindDNTemplate string
+	LDAPBindDN         string
+	LDAPBindPassword   string
+	LDAPBaseDN         string
+	LDAPUserFilter     string
+	LDAPNameAttribute  string
+	LDAPGroupAttribute string
+	LDAPGroupBaseDN    string
+	LDAPGroupFilter    string
+	LDAPAdminGroups    []string
+
+	// Background job runner settings; finished jobs are purged after JobRetention
+	JobWorkers      int
+	JobPollInterval time.Duration
+	JobLease        time.Duration
+	JobMaxAttempts  int
+	JobRetention    time.Duration
+}
+
+// FederationProvider is an external OpenID Connect provider, configured with
+// FEDERATION_<ID>_* variables for each ID listed in FEDERATION_PROVIDERS
+type FederationProvider struct {
+	ID           string
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// TrustEmail treats the email claim as verified without email_verified,
+	// for providers that only issue verified addresses
+	TrustEmail bool
+
+	// JITProvisioning creates unknown users on first sign-in, with DefaultRole
+	// in the default organization
+	JITProvisioning bool
+	DefaultRole     string
 }
 
 // LoadConfig loads configuration from environment variables
@@ -23,11 +165,16 @@ func LoadConfig() *Config {
 	_ = godotenv.Load()
 
 	// Get JWT_EXPIRE_HOURS and convert to int, default to 24
-	jwtExpireHours := 24
-	if hours := os.Getenv("JWT_EXPIRE_HOURS"); hours != "" {
-		if parsed, err := strconv.Atoi(hours); err == nil {
-			jwtExpireHours = parsed
+	jwtExpireHours := getEnvInt("JWT_EXPIRE_HOURS", 24)
+
+	// Admin password may come from ADMIN_PASSWORD or a file (e.g. a mounted secret)
+	adminPassword := os.Getenv("ADMIN_PASSWORD")
+	if passwordFile := os.Getenv("ADMIN_PASSWORD_FILE"); passwordFile != "" && adminPassword == "" {
+		content, err := os.ReadFile(passwordFile)
+		if err != nil {
+			log.Fatalf("Failed to read ADMIN_PASSWORD_FILE: %v", err)
 		}
+		adminPassword = strings.TrimSpace(string(content))
 	}
 
 	config := &Config{
@@ -36,8 +183,93 @@ func LoadConfig() *Config {
 		MongoDB:        getEnv("MONGO_DB", "userdb"),
 		JWTSecret:      getEnv("JWT_SECRET", "supersecretkey"),
 		JWTExpireHours: jwtExpireHours,
+		AdminName:      getEnv("ADMIN_NAME", "Administrator"),
+		AdminEmail:     os.Getenv("ADMIN_EMAIL"),
+		AdminPassword:  adminPassword,
+
+		PasswordMinLength:        getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:        getEnvInt("PASSWORD_MAX_LENGTH", 72),
+		PasswordRequireUppercase: getEnvBool("PASSWORD_REQUIRE_UPPERCASE", false),
+		PasswordRequireLowercase: getEnvBool("PASSWORD_REQUIRE_LOWERCASE", false),
+		PasswordRequireDigit:     getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+		PasswordRequireSymbol:    getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+		PasswordBreachedFile:     os.Getenv("PASSWORD_BREACHED_FILE"),
+		PasswordHistorySize:      getEnvInt("PASSWORD_HISTORY_SIZE", 0),
+		PasswordMaxAge:           time.Duration(getEnvInt("PASSWORD_MAX_AGE_DAYS", 0)) * 24 * time.Hour,
+		AdminPasswordMaxAge:      time.Duration(getEnvInt("ADMIN_PASSWORD_MAX_AGE_DAYS", 0)) * 24 * time.Hour,
+
+		PasswordHashAlgorithm: getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt"),
+		BcryptCost:            getEnvInt("BCRYPT_COST", 10),
+		Argon2Memory:          uint32(getEnvInt("ARGON2_MEMORY_KIB", 64*1024)),
+		Argon2Time:            uint32(getEnvInt("ARGON2_TIME", 3)),
+		Argon2Threads:         uint8(getEnvInt("ARGON2_THREADS", 2)),
+
+		DeletedUserRetention: time.Duration(getEnvInt("DELETED_USER_RETENTION_HOURS", 720)) * time.Hour,
+		PurgeInterval:        time.Duration(getEnvInt("PURGE_INTERVAL_MINUTES", 60)) * time.Minute,
+
+		WebhookMaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookTimeout:      time.Duration(getEnvInt("WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
+		WebhookPollInterval: time.Duration(getEnvInt("WEBHOOK_POLL_INTERVAL_SECONDS", 5)) * time.Second,
+		WebhookWorkers:      getEnvInt("WEBHOOK_WORKERS", 4),
+
+		SCIMToken: os.Getenv("SCIM_TOKEN"),
+
+		IdempotencyTTL: time.Duration(getEnvInt("IDEMPOTENCY_TTL_HOURS", 24)) * time.Hour,
+
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@localhost"),
+
+		PasswordSetURL:      getEnv("PASSWORD_SET_URL", "http://localhost:8080/set-password"),
+		PasswordSetTokenTTL: time.Duration(getEnvInt("PASSWORD_SET_TOKEN_TTL_HOURS", 72)) * time.Hour,
+
+		EmailChangeURL:      getEnv("EMAIL_CHANGE_URL", "http://localhost:8080/confirm-email"),
+		EmailChangeTokenTTL: time.Duration(getEnvInt("EMAIL_CHANGE_TOKEN_TTL_HOURS", 24)) * time.Hour,
+		EmailRevertURL:      getEnv("EMAIL_REVERT_URL", "http://localhost:8080/revert-email"),
+		EmailRevertTokenTTL: time.Duration(getEnvInt("EMAIL_REVERT_TOKEN_TTL_HOURS", 168)) * time.Hour,
+
+		InvitationURL:  getEnv("INVITATION_URL", "http://localhost:8080/accept-invitation"),
+		InvitationTTL:  time.Duration(getEnvInt("INVITATION_TTL_HOURS", 168)) * time.Hour,
+		InvitationOnly: getEnvBool("INVITATION_ONLY", false),
+
+		DefaultOrgName: getEnv("DEFAULT_ORG_NAME", "Default"),
+
+		OAuthCodeTTL:         time.Duration(getEnvInt("OAUTH_CODE_TTL_SECONDS", 60)) * time.Second,
+		OAuthAccessTokenTTL:  time.Duration(getEnvInt("OAUTH_ACCESS_TOKEN_TTL_MINUTES", 60)) * time.Minute,
+		OAuthRefreshTokenTTL: time.Duration(getEnvInt("OAUTH_REFRESH_TOKEN_TTL_DAYS", 30)) * 24 * time.Hour,
+		OAuthSessionTTL:      time.Duration(getEnvInt("OAUTH_SESSION_TTL_HOURS", 12)) * time.Hour,
+		OIDCSigningKeyFile:   os.Getenv("OIDC_SIGNING_KEY_FILE"),
+
+		FederationProviders:   loadFederationProviders(),
+		FederationRedirectURL: getEnv("FEDERATION_REDIRECT_URL", "http://localhost:8080/login/callback"),
+		FederationStateTTL:    time.Duration(getEnvInt("FEDERATION_STATE_TTL_MINUTES", 10)) * time.Minute,
+
+		LDAPURL:            os.Getenv("LDAP_URL"),
+		LDAPStartTLS:       getEnvBool("LDAP_START_TLS", false),
+		LDAPCAFile:         os.Getenv("LDAP_CA_FILE"),
+		LDAPTimeout:        time.Duration(getEnvInt("LDAP_TIMEOUT_SECONDS", 10)) * time.Second,
+		LDAPBindDNTemplate: os.Getenv("LDAP_BIND_DN_TEMPLATE"),
+		LDAPBindDN:         os.Getenv("LDAP_BIND_DN"),
+		LDAPBindPassword:   os.Getenv("LDAP_BIND_PASSWORD"),
+		LDAPBaseDN:         os.Getenv("LDAP_BASE_DN"),
+		LDAPUserFilter:     getEnv("LDAP_USER_FILTER", "(mail={email})"),
+		LDAPNameAttribute:  getEnv("LDAP_NAME_ATTRIBUTE", "cn"),
+		LDAPGroupAttribute: getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		LDAPGroupBaseDN:    os.Getenv("LDAP_GROUP_BASE_DN"),
+		LDAPGroupFilter:    getEnv("LDAP_GROUP_FILTER", "(member={dn})"),
+		LDAPAdminGroups:    loadLDAPAdminGroups(),
+
+		JobWorkers:      getEnvInt("JOB_WORKERS", 2),
+		JobPollInterval: time.Duration(getEnvInt("JOB_POLL_INTERVAL_SECONDS", 2)) * time.Second,
+		JobLease:        time.Duration(getEnvInt("JOB_LEASE_SECONDS", 60)) * time.Second,
+		JobMaxAttempts:  getEnvInt("JOB_MAX_ATTEMPTS", 3),
+		JobRetention:    time.Duration(getEnvInt("JOB_RETENTION_HOURS", 168)) * time.Hour,
 	}
 
+	config.OAuthIssuer = strings.TrimSuffix(getEnv("OAUTH_ISSUER", "http://localhost:"+config.AppPort), "/")
+
 	// Validate required fields
 	if config.JWTSecret == "supersecretkey" {
 		log.Println("WARNING: Using default JWT_SECRET. Please set a secure secret in production!")
@@ -46,6 +278,43 @@ func LoadConfig() *Config {
 	return config
 }
 
+// loadFederationProviders reads the providers listed in FEDERATION_PROVIDERS
+func loadFederationProviders() []FederationProvider {
+	providers := []FederationProvider{}
+	for _, id := range strings.Split(os.Getenv("FEDERATION_PROVIDERS"), ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" {
+			continue
+		}
+
+		prefix := "FEDERATION_" + strings.ToUpper(id) + "_"
+		providers = append(providers, FederationProvider{
+			ID:              id,
+			Name:            getEnv(prefix+"NAME", id),
+			Issuer:          os.Getenv(prefix + "ISSUER"),
+			ClientID:        os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret:    os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:          strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+			TrustEmail:      getEnvBool(prefix+"TRUST_EMAIL", false),
+			JITProvisioning: getEnvBool(prefix+"JIT_PROVISIONING", true),
+			DefaultRole:     getEnv(prefix+"DEFAULT_ROLE", "member"),
+		})
+	}
+	return providers
+}
+
+// loadLDAPAdminGroups reads the DNs listed in LDAP_ADMIN_GROUPS. They are
+// separated by semicolons, as DNs contain commas.
+func loadLDAPAdminGroups() []string {
+	groups := []string{}
+	for _, dn := range strings.Split(os.Getenv("LDAP_ADMIN_GROUPS"), ";") {
+		if dn = strings.TrimSpace(dn); dn != "" {
+			groups = append(groups, dn)
+		}
+	}
+	return groups
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -54,3 +323,22 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvInt retrieves an integer environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvBool retrieves a boolean environment variable or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"user-management-system/models"
+	"user-management-system/services"
+	"user-management-system/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// APIKeyHandler handles requests for API keys and service accounts
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateKey creates an API key and returns it; the key is only shown here
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	key, plaintext, err := h.apiKeyService.CreateKey(r.Context(), &req)
+	if err != nil {
+		writeAPIKeyError(w, err, "Failed to create API key")
+		return
+	}
+
+	utils.JSON(w, http.StatusCreated, utils.Response{
+		Success: true,
+		Message: "API key created successfully; store the key now, it cannot be shown again",
+		Data:    &models.CreatedAPIKeyResponse{APIKeyResponse: key.ToAPIKeyResponse(), Key: plaintext},
+	})
+}
+
+// ListKeys retrieves API keys, newest first; admins may filter by owner with ?userId=
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	keys, err := h.apiKeyService.ListKeys(r.Context(), r.URL.Query().Get("userId"))
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve API keys")
+		return
+	}
+
+	responses := make([]*models.APIKeyResponse, len(keys))
+	for i, key := range keys {
+		responses[i] = key.ToAPIKeyResponse()
+	}
+
+	utils.SuccessResponse(w, "API keys retrieved successfully", responses)
+}
+
+// RevokeKey revokes an API key; requests made with it fail from then on
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	key, err := h.apiKeyService.RevokeKey(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeAPIKeyError(w, err, "Failed to revoke API key")
+		return
+	}
+
+	utils.SuccessResponse(w, "API key revoked successfully", key.ToAPIKeyResponse())
+}
+
+// CreateServiceAccount creates a service account, which acts only through API keys
+func (h *APIKeyHandler) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateServiceAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.apiKeyService.CreateServiceAccount(r.Context(), &req)
+	if err != nil {
+		writeAPIKeyError(w, err, "Failed to create service account")
+		return
+	}
+
+	utils.JSON(w, http.StatusCreated, utils.Response{
+		Success: true,
+		Message: "Service account created successfully; create API keys for it with POST /api/api-keys",
+		Data:    user.ToUserResponse(),
+	})
+}
+
+// writeAPIKeyError maps API key and service account errors to HTTP responses
+func writeAPIKeyError(w http.ResponseWriter, err error, fallback string) {
+	if strings.HasPrefix(err.Error(), "invalid scope: ") {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch err.Error() {
+	case "invalid API key ID", "invalid user ID", "name is required", "name must be at most 100 characters",
+		"name must be between 2 and 100 characters", "at least one scope is required", "expiresInDays must not be negative",
+		"organization is required", "role must be either admin or member", "account is deactivated",
+		"user is not a member of the organization":
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	case "only admins can create API keys for service accounts", "only super-admins can create their own API keys",
+		"API keys can only be created for yourself or a service account":
+		utils.ErrorResponse(w, http.StatusForbidden, err.Error())
+	case "API key not found", "user not found":
+		utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+	case "API key is already revoked":
+		utils.ErrorResponse(w, http.StatusConflict, err.Error())
+	default:
+		utils.ErrorResponse(w, http.StatusInternalServerError, fallback)
+	}
+}
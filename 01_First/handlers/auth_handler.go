@@ -12,25 +12,38 @@ import (
 
 // AuthHandler handles authentication-related requests
 type AuthHandler struct {
-	authService *services.UserService
-	config      *config.Config
+	authService        *services.UserService
+	passwordSetService *services.PasswordSetService
+	invitationService  *services.InvitationService
+	orgService         *services.OrganizationService
+	emailChangeService *services.EmailChangeService
+	config             *config.Config
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(authService *services.UserService, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(authService *services.UserService, passwordSetService *services.PasswordSetService, invitationService *services.InvitationService, orgService *services.OrganizationService, emailChangeService *services.EmailChangeService, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
-		config:      cfg,
+		authService:        authService,
+		passwordSetService: passwordSetService,
+		invitationService:  invitationService,
+		orgService:         orgService,
+		emailChangeService: emailChangeService,
+		config:             cfg,
 	}
 }
 
-// Register handles user registration
+// Register handles user registration, unless registration is by invitation only
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
+	if h.config.InvitationOnly {
+		utils.ErrorResponse(w, http.StatusForbidden, "registration is by invitation only")
+		return
+	}
+
 	var req models.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
@@ -39,6 +52,9 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.authService.Register(r.Context(), &req)
 	if err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
 		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -61,12 +77,228 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.authService.Login(r.Context(), &req)
 	if err != nil {
+		if err.Error() == "password change required" || err.Error() == "password expired" {
+			utils.ErrorResponse(w, http.StatusForbidden, err.Error())
+			return
+		}
 		utils.ErrorResponse(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
+	h.respondWithToken(w, user, "Login successful")
+}
+
+// ChangePassword handles password changes with the current password, including
+// the forced change required before the first login of a bootstrapped admin
+// and the change of an expired password
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.authService.ChangePassword(r.Context(), &req)
+	if err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		if err.Error() == "invalid email or password" || err.Error() == "account is deactivated" {
+			utils.ErrorResponse(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondWithToken(w, user, "Password changed successfully")
+}
+
+// SetPassword sets a password with the single-use token from a password-set link
+func (h *AuthHandler) SetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.SetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.passwordSetService.SetPassword(r.Context(), &req)
+	if err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		if err.Error() == "invalid or expired token" || err.Error() == "account is deactivated" {
+			utils.ErrorResponse(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.respondWithToken(w, user, "Password set successfully")
+}
+
+// ConfirmEmail applies an email change with the single-use token from the
+// confirmation link sent to the new address
+func (h *AuthHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.EmailTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.emailChangeService.Confirm(r.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid or expired token", "account is deactivated":
+			utils.ErrorResponse(w, http.StatusUnauthorized, err.Error())
+		case "email already in use":
+			utils.ErrorResponse(w, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to confirm email")
+		}
+		return
+	}
+
+	utils.SuccessResponse(w, "Email changed successfully", user)
+}
+
+// RevertEmail restores the previous email with the single-use token from the
+// notice sent to the old address, and mails it a password-set link
+func (h *AuthHandler) RevertEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.EmailTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.emailChangeService.Revert(r.Context(), &req)
+	if err != nil {
+		switch err.Error() {
+		case "invalid or expired token":
+			utils.ErrorResponse(w, http.StatusUnauthorized, err.Error())
+		case "email already in use":
+			utils.ErrorResponse(w, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to revert email")
+		}
+		return
+	}
+
+	utils.SuccessResponse(w, "Email change reverted; check your inbox to set a new password", user)
+}
+
+// AcceptInvitation creates the invited user with the single-use token from an
+// invitation link and logs them in
+func (h *AuthHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.invitationService.AcceptInvitation(r.Context(), &req)
+	if err != nil {
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		switch err.Error() {
+		case "invalid or expired invitation":
+			utils.ErrorResponse(w, http.StatusUnauthorized, err.Error())
+		case "email already registered":
+			utils.ErrorResponse(w, http.StatusConflict, err.Error())
+		case "failed to hash password", "failed to create user":
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to accept invitation")
+		default:
+			utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	h.respondWithToken(w, user, "Invitation accepted successfully")
+}
+
+// SwitchOrg exchanges the caller's token for one scoped to another
+// organization they belong to; super-admins may switch to any organization
+func (h *AuthHandler) SwitchOrg(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.SwitchOrgRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.orgService.SwitchOrganization(r.Context(), req.OrgID)
+	if err != nil {
+		switch err.Error() {
+		case "invalid organization ID":
+			utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		case "organization not found":
+			utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+		case "user not found", "account is deactivated":
+			utils.ErrorResponse(w, http.StatusUnauthorized, err.Error())
+		default:
+			utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to switch organization")
+		}
+		return
+	}
+
+	h.respondWithOrgToken(w, user, req.OrgID, "Organization switched successfully")
+}
+
+// respondWithToken issues a JWT for the user in their home organization and
+// writes it with the user info
+func (h *AuthHandler) respondWithToken(w http.ResponseWriter, user *models.User, message string) {
+	h.respondWithOrgToken(w, user, user.OrgID, message)
+}
+
+// respondWithOrgToken issues a JWT for the user acting in orgID and writes it
+// with the user info
+func (h *AuthHandler) respondWithOrgToken(w http.ResponseWriter, user *models.User, orgID, message string) {
+	writeUserToken(w, h.config, user, orgID, message)
+}
+
+// writeUserToken issues a JWT for the user acting in orgID and writes it with
+// the user info. The role claim is the user's effective role there.
+func writeUserToken(w http.ResponseWriter, cfg *config.Config, user *models.User, orgID, message string) {
 	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID.Hex(), user.Email, user.Role, h.config)
+	token, err := utils.GenerateToken(utils.JWTClaims{
+		UserID:     user.ID.Hex(),
+		Email:      user.Email,
+		Role:       user.EffectiveRole(orgID),
+		OrgID:      orgID,
+		OrgRole:    user.OrgRole(orgID),
+		SuperAdmin: user.IsSuperAdmin(),
+	}, cfg)
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to generate token")
 		return
@@ -78,6 +310,5 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		"token": token,
 	}
 
-	utils.SuccessResponse(w, "Login successful", response)
+	utils.SuccessResponse(w, message, response)
 }
-
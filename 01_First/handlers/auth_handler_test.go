@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"user-management-system/config"
+)
+
+func TestRegisterByInvitationOnly(t *testing.T) {
+	env := newTestEnv(t)
+	body := `{"name":"Jane Doe","email":"jane@example.com","password":"` + testPassword + `"}`
+
+	closed := NewAuthHandler(env.userService, nil, nil, nil, nil, &config.Config{InvitationOnly: true})
+	resp := serve(context.Background(), "/api/auth/register", closed.Register, http.MethodPost, "/api/auth/register", body, nil)
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("register when invitation only = %d %s, want 403", resp.Code, resp.Body)
+	}
+	if _, err := env.userRepo.FindByEmail(context.Background(), "jane@example.com"); err == nil {
+		t.Fatal("a user registered although registration is by invitation only")
+	}
+
+	open := NewAuthHandler(env.userService, nil, nil, nil, nil, &config.Config{})
+	resp = serve(context.Background(), "/api/auth/register", open.Register, http.MethodPost, "/api/auth/register", body, nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("open register = %d %s, want 200", resp.Code, resp.Body)
+	}
+	if _, err := env.userRepo.FindByEmail(context.Background(), "jane@example.com"); err != nil {
+		t.Fatalf("registered user: %v", err)
+	}
+}
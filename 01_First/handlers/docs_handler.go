@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"user-management-system/openapi"
+)
+
+// DocsHandler serves the OpenAPI document and the docs UI
+type DocsHandler struct{}
+
+// NewDocsHandler creates a new docs handler
+func NewDocsHandler() *DocsHandler {
+	return &DocsHandler{}
+}
+
+// Spec serves the OpenAPI 3.1 document
+func (h *DocsHandler) Spec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.JSON())
+}
+
+// Docs serves the interactive documentation page
+func (h *DocsHandler) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.DocsPage)
+}
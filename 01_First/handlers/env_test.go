@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/mongotest"
+	"user-management-system/repositories"
+	"user-management-system/services"
+	"user-management-system/tenant"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPassword passes the test password policy
+const testPassword = "correct horse battery"
+
+// testEnv wires the user and group services on an empty in-memory database,
+// for handler tests that go through the real services
+type testEnv struct {
+	t  *testing.T
+	db *mongo.Database
+
+	userRepo     *repositories.UserRepository
+	auditService *services.AuditService
+	userService  *services.UserService
+	groupService *services.GroupService
+	defaultOrg   *models.Organization
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	db := mongotest.NewDatabase(t)
+	env := &testEnv{t: t, db: db, userRepo: repositories.NewUserRepository(db.Collection("users"))}
+
+	auditService := services.NewAuditService(repositories.NewAuditRepository(db.Collection("audit_events")))
+	env.auditService = auditService
+	webhookService := services.NewWebhookService(repositories.NewWebhookRepository(db.Collection("webhooks")), repositories.NewWebhookDeliveryRepository(db.Collection("webhook_deliveries")), http.DefaultClient, 1)
+	orgService := services.NewOrganizationService(repositories.NewOrganizationRepository(db.Collection("organizations")), env.userRepo, repositories.NewGroupMemberRepository(db.Collection("group_members")), auditService)
+	env.groupService = services.NewGroupService(repositories.NewGroupRepository(db.Collection("groups")), repositories.NewGroupMemberRepository(db.Collection("group_members")), env.userRepo, auditService)
+
+	var err error
+	env.defaultOrg, err = orgService.EnsureDefaultOrganization(context.Background(), "Default")
+	if err != nil {
+		t.Fatalf("EnsureDefaultOrganization: %v", err)
+	}
+
+	policy := &services.PasswordPolicy{MinLength: 8}
+	env.userService = services.NewUserService(env.userRepo, auditService, webhookService, nil, policy, services.BcryptHasher{Cost: bcrypt.MinCost}, env.defaultOrg.ID.Hex())
+
+	return env
+}
+
+// createUser creates an active user in the default organization with the
+// given platform role
+func (env *testEnv) createUser(email, role string) *models.User {
+	env.t.Helper()
+
+	isActive := true
+	created, err := env.userService.CreateUser(context.Background(), &models.CreateUserRequest{Name: "Test User", Email: email, Password: testPassword, Role: role, IsActive: &isActive})
+	if err != nil {
+		env.t.Fatalf("creating %s: %v", email, err)
+	}
+	user, err := env.userRepo.FindByID(context.Background(), created.ID)
+	if err != nil {
+		env.t.Fatalf("loading %s: %v", email, err)
+	}
+	return user
+}
+
+// callerContext is the context of a request the user signed in to the
+// default organization makes, as the JWT middleware builds it
+func (env *testEnv) callerContext(user *models.User) context.Context {
+	orgID := env.defaultOrg.ID.Hex()
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, user.ID.Hex())
+	ctx = context.WithValue(ctx, middleware.EmailKey, user.Email)
+	ctx = context.WithValue(ctx, middleware.RoleKey, user.EffectiveRole(orgID))
+	ctx = context.WithValue(ctx, middleware.OrgRoleKey, user.OrgRole(orgID))
+	ctx = context.WithValue(ctx, middleware.SuperAdminKey, user.IsSuperAdmin())
+	return tenant.WithOrg(ctx, orgID)
+}
+
+// decodeList decodes the data of a paginated response
+func decodeList(t *testing.T, recorder *httptest.ResponseRecorder) []map[string]interface{} {
+	t.Helper()
+
+	var body struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding %d response: %v", recorder.Code, err)
+	}
+	return body.Data
+}
+
+// serve routes one request through a router holding only the given route
+func serve(ctx context.Context, pattern string, handler http.HandlerFunc, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.HandleFunc(pattern, handler)
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader).WithContext(ctx)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"user-management-system/config"
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/services"
+	"user-management-system/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// federationStateCookie keeps the hash of a started login's state in the
+// browser that started it, for the callback to check
+const federationStateCookie = "federation_state"
+
+// FederationHandler handles sign-in through external identity providers
+type FederationHandler struct {
+	federationService *services.FederationService
+	config            *config.Config
+}
+
+// NewFederationHandler creates a new federation handler
+func NewFederationHandler(federationService *services.FederationService, cfg *config.Config) *FederationHandler {
+	return &FederationHandler{
+		federationService: federationService,
+		config:            cfg,
+	}
+}
+
+// ListProviders lists the identity providers users can sign in with
+func (h *FederationHandler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	utils.SuccessResponse(w, "Identity providers retrieved successfully", h.federationService.Providers())
+}
+
+// Start begins a login with an identity provider and returns the URL to send
+// the user to. The client keeps the returned state to check the callback;
+// the browser keeps a cookie the callback must come with.
+func (h *FederationHandler) Start(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	start, err := h.federationService.Start(r.Context(), mux.Vars(r)["provider"])
+	if err != nil {
+		writeFederationError(w, err, "Failed to start login")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     federationStateCookie,
+		Value:    start.StateHash,
+		Path:     "/api/auth/federated",
+		MaxAge:   int(h.config.FederationStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.config.FederationRedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	utils.SuccessResponse(w, "Login started successfully", start)
+}
+
+// Callback completes a login with the code and state the identity provider
+// redirected back with, and returns a token like a password login. It must
+// come from the browser that started the login.
+func (h *FederationHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.FederationCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var stateHash string
+	if cookie, err := r.Cookie(federationStateCookie); err == nil {
+		stateHash = cookie.Value
+	}
+
+	user, err := h.federationService.Complete(r.Context(), &req, stateHash)
+	if err != nil {
+		writeFederationError(w, err, "Login failed")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     federationStateCookie,
+		Path:     "/api/auth/federated",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.config.FederationRedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	writeUserToken(w, h.config, user, user.OrgID, "Login successful")
+}
+
+// Unlink removes one of the authenticated user's linked identities
+func (h *FederationHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, err := h.federationService.Unlink(r.Context(), middleware.GetUserID(r.Context()), mux.Vars(r)["provider"])
+	if err != nil {
+		writeFederationError(w, err, "Failed to unlink identity")
+		return
+	}
+
+	utils.SuccessResponse(w, "Identity unlinked successfully", user)
+}
+
+// writeFederationError maps federated login errors to HTTP responses
+func writeFederationError(w http.ResponseWriter, err error, fallback string) {
+	switch err.Error() {
+	case "state and code are required", "invalid or expired state", "login was started in another browser":
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	case "identity provider rejected the login", "identity provider did not verify the email", "no account for this email",
+		"account is deactivated", "account is deleted":
+		utils.ErrorResponse(w, http.StatusUnauthorized, err.Error())
+	case "provider not found", "user not found", "identity not linked":
+		utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+	case "identity provider already linked", "identity is linked to another user", "directory identities cannot be unlinked",
+		"set a password before unlinking your only identity":
+		utils.ErrorResponse(w, http.StatusConflict, err.Error())
+	case "identity provider unavailable":
+		utils.ErrorResponse(w, http.StatusBadGateway, err.Error())
+	default:
+		utils.ErrorResponse(w, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management-system/config"
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/services"
+)
+
+func TestFederatedCallbackNeedsTheStateCookie(t *testing.T) {
+	env := newTestEnv(t)
+
+	var provider *httptest.Server
+	provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 provider.URL,
+			"authorization_endpoint": provider.URL + "/authorize",
+			"token_endpoint":         provider.URL + "/token",
+			"jwks_uri":               provider.URL + "/jwks",
+		})
+	}))
+	t.Cleanup(provider.Close)
+
+	cfg := &config.Config{FederationRedirectURL: "https://app.example.com/login/callback", FederationStateTTL: 10 * time.Minute}
+	webhookService := services.NewWebhookService(repositories.NewWebhookRepository(env.db.Collection("webhooks")), repositories.NewWebhookDeliveryRepository(env.db.Collection("webhook_deliveries")), http.DefaultClient, 1)
+	federationService, err := services.NewFederationService([]config.FederationProvider{{
+		ID: "corp", Issuer: provider.URL, ClientID: "user-management", Scopes: []string{"openid"}, DefaultRole: models.OrgRoleMember,
+	}}, repositories.NewFederationStateRepository(env.db.Collection("federation_states")), env.userRepo, env.userService, env.auditService, webhookService, http.DefaultClient, cfg.FederationRedirectURL, cfg.FederationStateTTL)
+	if err != nil {
+		t.Fatalf("NewFederationService: %v", err)
+	}
+	handler := NewFederationHandler(federationService, cfg)
+
+	start := func() (string, *http.Cookie) {
+		t.Helper()
+		recorder := serve(context.Background(), "/api/auth/federated/{provider}/start", handler.Start, http.MethodPost, "/api/auth/federated/corp/start", "", nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Start: status = %d, body = %s", recorder.Code, recorder.Body)
+		}
+		var body struct {
+			Data models.FederationStartResponse `json:"data"`
+		}
+		json.NewDecoder(recorder.Body).Decode(&body)
+		cookies := recorder.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != federationStateCookie || cookies[0].Value == "" || cookies[0].Value == body.Data.State ||
+			!cookies[0].HttpOnly || !cookies[0].Secure || cookies[0].SameSite != http.SameSiteLaxMode || cookies[0].Path != "/api/auth/federated" {
+			t.Fatalf("Start cookies = %+v", cookies)
+		}
+		return body.Data.State, cookies[0]
+	}
+	callback := func(state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		header := map[string]string{}
+		if cookie != nil {
+			header["Cookie"] = cookie.String()
+		}
+		return serve(context.Background(), "/api/auth/federated/callback", handler.Callback, http.MethodPost, "/api/auth/federated/callback", `{"state":"`+state+`","code":"upstream-code"}`, header)
+	}
+
+	attackerState, _ := start()
+	_, victimCookie := start()
+	for name, cookie := range map[string]*http.Cookie{"no cookie": nil, "another login's cookie": victimCookie} {
+		recorder := callback(attackerState, cookie)
+		if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "login was started in another browser") {
+			t.Errorf("%s: status = %d, body = %s", name, recorder.Code, recorder.Body)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"user-management-system/models"
+	"user-management-system/services"
+	"user-management-system/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// GroupHandler handles requests for groups and their members
+type GroupHandler struct {
+	groupService *services.GroupService
+}
+
+// NewGroupHandler creates a new group handler
+func NewGroupHandler(groupService *services.GroupService) *GroupHandler {
+	return &GroupHandler{
+		groupService: groupService,
+	}
+}
+
+// CreateGroup creates a group, optionally as a subgroup of an existing one
+func (h *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	group, err := h.groupService.CreateGroup(r.Context(), &req)
+	if err != nil {
+		writeGroupError(w, err, "Failed to create group")
+		return
+	}
+
+	utils.JSON(w, http.StatusCreated, utils.Response{
+		Success: true,
+		Message: "Group created successfully",
+		Data:    group,
+	})
+}
+
+// ListGroups retrieves the groups of the organization with optional search and parent filters
+func (h *GroupHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	page, limit := parsePagination(r, 20)
+	filter := models.GroupFilter{
+		Search:   r.URL.Query().Get("search"),
+		ParentID: strings.TrimSpace(r.URL.Query().Get("parentId")),
+	}
+
+	groups, totalPages, total, err := h.groupService.ListGroups(r.Context(), filter, page, limit)
+	if err != nil {
+		writeGroupError(w, err, "Failed to retrieve groups")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(w, groups, page, limit, total, totalPages)
+}
+
+// GetGroup retrieves a single group
+func (h *GroupHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	group, err := h.groupService.GetGroup(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeGroupError(w, err, "Failed to retrieve group")
+		return
+	}
+
+	utils.SuccessResponse(w, "Group retrieved successfully", group)
+}
+
+// UpdateGroup renames, describes or moves a group
+func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.UpdateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	group, err := h.groupService.UpdateGroup(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeGroupError(w, err, "Failed to update group")
+		return
+	}
+
+	utils.SuccessResponse(w, "Group updated successfully", group)
+}
+
+// DeleteGroup deletes a group that has no subgroups
+func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err := h.groupService.DeleteGroup(r.Context(), mux.Vars(r)["id"]); err != nil {
+		writeGroupError(w, err, "Failed to delete group")
+		return
+	}
+
+	utils.SuccessResponse(w, "Group deleted successfully", nil)
+}
+
+// ListMembers retrieves the direct members of a group
+func (h *GroupHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	page, limit := parsePagination(r, 20)
+
+	members, totalPages, total, err := h.groupService.ListMembers(r.Context(), mux.Vars(r)["id"], page, limit)
+	if err != nil {
+		writeGroupError(w, err, "Failed to retrieve members")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(w, members, page, limit, total, totalPages)
+}
+
+// AddMembers adds users to a group in bulk
+func (h *GroupHandler) AddMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.GroupMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.groupService.AddMembers(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeGroupError(w, err, "Failed to add members")
+		return
+	}
+
+	utils.SuccessResponse(w, "Members added successfully", result)
+}
+
+// RemoveMembers removes users from a group in bulk
+func (h *GroupHandler) RemoveMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.GroupMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.groupService.RemoveMembers(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeGroupError(w, err, "Failed to remove members")
+		return
+	}
+
+	utils.SuccessResponse(w, "Members removed successfully", result)
+}
+
+// writeGroupError maps group service errors to HTTP responses
+func writeGroupError(w http.ResponseWriter, err error, fallback string) {
+	switch err.Error() {
+	case "invalid group ID", "invalid user ID", "organization is required", "parent group not found",
+		"name must be between 2 and 100 characters", "description must be at most 500 characters",
+		"role must be either admin or member", "userIds is required":
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	case "only admins can grant the group admin role":
+		utils.ErrorResponse(w, http.StatusForbidden, err.Error())
+	case "group not found", "user not found":
+		utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+	case "group name already exists", "group has subgroups", "group hierarchy cannot contain cycles":
+		utils.ErrorResponse(w, http.StatusConflict, err.Error())
+	default:
+		if strings.HasPrefix(err.Error(), "at most ") {
+			utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"user-management-system/models"
+	"user-management-system/services"
+	"user-management-system/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// InvitationHandler handles admin requests for user invitations
+type InvitationHandler struct {
+	invitationService *services.InvitationService
+}
+
+// NewInvitationHandler creates a new invitation handler
+func NewInvitationHandler(invitationService *services.InvitationService) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+	}
+}
+
+// CreateInvitation invites a new user with the given email and organization role
+func (h *InvitationHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	invitation, err := h.invitationService.CreateInvitation(r.Context(), &req)
+	if err != nil {
+		writeInvitationError(w, err, "Failed to create invitation")
+		return
+	}
+
+	message := "Invitation sent successfully"
+	if invitation.LastSendError != "" {
+		message = "Invitation created, but the email could not be sent; resend it later"
+	}
+
+	utils.JSON(w, http.StatusCreated, utils.Response{
+		Success: true,
+		Message: message,
+		Data:    invitation.ToInvitationResponse(),
+	})
+}
+
+// ListInvitations retrieves invitations with optional email and status filters, newest first
+func (h *InvitationHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.InvitationFilter{
+		Email:  query.Get("email"),
+		Status: query.Get("status"),
+	}
+
+	switch filter.Status {
+	case "", models.InvitationPending, models.InvitationAccepted, models.InvitationRevoked, models.InvitationExpired:
+	default:
+		utils.ErrorResponse(w, http.StatusBadRequest, "status must be pending, accepted, revoked or expired")
+		return
+	}
+
+	// Get pagination parameters from query string
+	page := 1
+	limit := 20
+
+	if pageStr := query.Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	invitations, totalPages, total, err := h.invitationService.ListInvitations(r.Context(), filter, page, limit)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve invitations")
+		return
+	}
+
+	responses := make([]*models.InvitationResponse, len(invitations))
+	for i, invitation := range invitations {
+		responses[i] = invitation.ToInvitationResponse()
+	}
+
+	utils.PaginatedSuccessResponse(w, responses, page, limit, total, totalPages)
+}
+
+// GetInvitation retrieves a single invitation
+func (h *InvitationHandler) GetInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	invitation, err := h.invitationService.GetInvitation(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeInvitationError(w, err, "Failed to retrieve invitation")
+		return
+	}
+
+	utils.SuccessResponse(w, "Invitation retrieved successfully", invitation.ToInvitationResponse())
+}
+
+// ResendInvitation mails a pending invitation again with a fresh link
+func (h *InvitationHandler) ResendInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	invitation, err := h.invitationService.ResendInvitation(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeInvitationError(w, err, "Failed to resend invitation")
+		return
+	}
+
+	message := "Invitation resent successfully"
+	if invitation.LastSendError != "" {
+		message = "Invitation renewed, but the email could not be sent; resend it later"
+	}
+	utils.SuccessResponse(w, message, invitation.ToInvitationResponse())
+}
+
+// RevokeInvitation revokes a pending invitation
+func (h *InvitationHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	invitation, err := h.invitationService.RevokeInvitation(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeInvitationError(w, err, "Failed to revoke invitation")
+		return
+	}
+
+	utils.SuccessResponse(w, "Invitation revoked successfully", invitation.ToInvitationResponse())
+}
+
+// writeInvitationError maps invitation service errors to HTTP responses
+func writeInvitationError(w http.ResponseWriter, err error, fallback string) {
+	switch err.Error() {
+	case "invalid invitation ID", "email is required", "invalid email address", "role must be either admin or member", "organization is required":
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	case "invitation not found":
+		utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+	case "email already registered", "a pending invitation already exists for this email", "invitation is no longer pending":
+		utils.ErrorResponse(w, http.StatusConflict, err.Error())
+	default:
+		utils.ErrorResponse(w, http.StatusInternalServerError, fallback)
+	}
+}
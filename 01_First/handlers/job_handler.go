@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"user-management-system/models"
+	"user-management-system/services"
+	"user-management-system/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// JobHandler handles background job requests
+type JobHandler struct {
+	jobService *services.JobService
+}
+
+// NewJobHandler creates a new job handler
+func NewJobHandler(jobService *services.JobService) *JobHandler {
+	return &JobHandler{
+		jobService: jobService,
+	}
+}
+
+// ListJobs retrieves jobs with optional type and status filters, newest first
+func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.JobFilter{
+		Type:   query.Get("type"),
+		Status: query.Get("status"),
+	}
+
+	// Get pagination parameters from query string
+	page := 1
+	limit := 20
+
+	if pageStr := query.Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	jobs, totalPages, total, err := h.jobService.ListJobs(r.Context(), filter, page, limit)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve jobs")
+		return
+	}
+
+	responses := make([]*models.JobResponse, len(jobs))
+	for i, job := range jobs {
+		responses[i] = job.ToJobResponse()
+	}
+
+	utils.PaginatedSuccessResponse(w, responses, page, limit, total, totalPages)
+}
+
+// GetJob retrieves a job so clients can poll its status and progress
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	job, err := h.jobService.GetJob(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeJobError(w, err, "Failed to retrieve job")
+		return
+	}
+
+	utils.SuccessResponse(w, "Job retrieved successfully", job.ToJobResponse())
+}
+
+// CancelJob cancels a queued job or asks a running one to stop
+func (h *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	job, err := h.jobService.CancelJob(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeJobError(w, err, "Failed to cancel job")
+		return
+	}
+
+	message := "Job cancelled"
+	if job.Status == models.JobRunning {
+		message = "Cancellation requested; the job stops after its current step"
+	}
+	utils.SuccessResponse(w, message, job.ToJobResponse())
+}
+
+// DownloadResult streams the result file of a succeeded job
+func (h *JobHandler) DownloadResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	job, file, err := h.jobService.OpenResult(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeJobError(w, err, "Failed to open job result")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", job.ResultFileContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": job.ResultFileName}))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are already sent; a truncated body signals a failure
+	io.Copy(w, file)
+}
+
+// writeJobAccepted responds 202 with the queued job and where to poll it
+func writeJobAccepted(w http.ResponseWriter, job *models.Job, message string) {
+	w.Header().Set("Location", "/api/jobs/"+job.ID.Hex())
+	utils.JSON(w, http.StatusAccepted, utils.Response{
+		Success: true,
+		Message: message,
+		Data:    job.ToJobResponse(),
+	})
+}
+
+// writeJobError maps job service errors to HTTP responses
+func writeJobError(w http.ResponseWriter, err error, fallback string) {
+	switch err.Error() {
+	case "invalid job ID":
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	case "job not found", "job has no result file", "file not found":
+		utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+	case "job already finished":
+		utils.ErrorResponse(w, http.StatusConflict, err.Error())
+	default:
+		utils.ErrorResponse(w, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"user-management-system/models"
+	"user-management-system/services"
+	"user-management-system/utils"
+)
+
+// MeHandler handles self-service requests on the authenticated user's own account
+type MeHandler struct {
+	userService *services.UserService
+}
+
+// NewMeHandler creates a new self-service handler
+func NewMeHandler(userService *services.UserService) *MeHandler {
+	return &MeHandler{
+		userService: userService,
+	}
+}
+
+// GetMe retrieves the authenticated user
+func (h *MeHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user, err := h.userService.GetProfile(r.Context())
+	if err != nil {
+		writeMeError(w, err, "Failed to retrieve profile")
+		return
+	}
+
+	setUserETag(w, user)
+	utils.SuccessResponse(w, "Profile retrieved successfully", user)
+}
+
+// UpdateMe applies a JSON merge patch (RFC 7396) to the authenticated user's profile
+func (h *MeHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != mergePatchContentType {
+		utils.ErrorResponse(w, http.StatusUnsupportedMediaType, "Content-Type must be "+mergePatchContentType)
+		return
+	}
+
+	var patch map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Merge patch must be a JSON object")
+		return
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(r.Context(), patch, ifMatch)
+	if err != nil {
+		writeUserUpdateError(w, err, ifMatch)
+		return
+	}
+
+	setUserETag(w, user)
+	utils.SuccessResponse(w, "Profile updated successfully", user)
+}
+
+// ChangePassword changes the authenticated user's password given the current one
+func (h *MeHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ChangeOwnPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.ChangeOwnPassword(r.Context(), &req)
+	if err != nil {
+		writeMeError(w, err, "Failed to change password")
+		return
+	}
+
+	utils.SuccessResponse(w, "Password changed successfully", user)
+}
+
+// ChangeEmail sends a confirmation link to the new address; the email only
+// changes once the link is used
+func (h *MeHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.RequestEmailChange(r.Context(), &req)
+	if err != nil {
+		writeMeError(w, err, "Failed to request email change")
+		return
+	}
+
+	utils.JSON(w, http.StatusAccepted, utils.Response{
+		Success: true,
+		Message: "Confirmation sent to the new email address",
+		Data:    user,
+	})
+}
+
+// CloseAccount deletes the authenticated user's account given their password
+func (h *MeHandler) CloseAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CloseAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.userService.CloseAccount(r.Context(), &req); err != nil {
+		writeMeError(w, err, "Failed to close account")
+		return
+	}
+
+	utils.SuccessResponse(w, "Account closed successfully", nil)
+}
+
+// writeMeError maps self-service errors to HTTP responses
+func writeMeError(w http.ResponseWriter, err error, fallback string) {
+	if writePasswordPolicyError(w, err) {
+		return
+	}
+
+	switch err.Error() {
+	case "password is required", "current password is required", "new password is required",
+		"new password must be different from the current password", "email must be a valid email address",
+		"new email must be different from the current email":
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	case "password is incorrect":
+		utils.ErrorResponse(w, http.StatusForbidden, err.Error())
+	case "user not found":
+		utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+	case "email already in use", "the last super-admin cannot close their account",
+		"transfer ownership of your organizations before closing your account":
+		utils.ErrorResponse(w, http.StatusConflict, err.Error())
+	default:
+		utils.ErrorResponse(w, http.StatusInternalServerError, fallback)
+	}
+}
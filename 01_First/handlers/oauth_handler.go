@@ -0,0 +1,554 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-management-system/config"
+	"user-management-system/models"
+	"user-management-system/services"
+	"user-management-system/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// oauthSessionCookie keeps users signed in to the authorization server
+// between authorizations
+const oauthSessionCookie = "oauth_session"
+
+// oauthConsentCookie ties the consent forms shown before the user signs in to
+// their browser; once signed in, forms are tied to oauthSessionCookie
+const oauthConsentCookie = "oauth_consent"
+
+// OAuthHandler serves the OAuth 2.0 authorization server endpoints under
+// /oauth and the client registration API
+type OAuthHandler struct {
+	oauthService *services.OAuthService
+	config       *config.Config
+}
+
+// NewOAuthHandler creates a new OAuth handler
+func NewOAuthHandler(oauthService *services.OAuthService, cfg *config.Config) *OAuthHandler {
+	return &OAuthHandler{
+		oauthService: oauthService,
+		config:       cfg,
+	}
+}
+
+// consentPage asks the user to sign in, if they have no session, and to
+// allow or deny the client's request. The request parameters travel in
+// hidden fields back to POST /oauth/authorize, with an anti-CSRF token.
+var consentPage = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Authorize {{.Client.Name}}</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f4f5f7; display: flex; justify-content: center; padding-top: 10vh; }
+main { background: #fff; padding: 2rem; border-radius: 8px; box-shadow: 0 1px 4px rgba(0,0,0,.15); width: 22rem; }
+label { display: block; margin-top: .75rem; }
+input[type=email], input[type=password] { width: 100%; padding: .4rem; box-sizing: border-box; }
+.error { color: #b00020; }
+.actions { display: flex; gap: .5rem; margin-top: 1.25rem; }
+button { flex: 1; padding: .5rem; }
+</style>
+</head>
+<body>
+<main>
+<h1>Authorize {{.Client.Name}}</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/oauth/authorize">
+{{if .User}}
+<p>Signed in as <strong>{{.User.Email}}</strong>.</p>
+{{else}}
+<p>Sign in to continue.</p>
+<label>Email <input type="email" name="email" value="{{.Email}}" required autofocus></label>
+<label>Password <input type="password" name="password" required></label>
+{{end}}
+<p>{{.Client.Name}} will be able to act on your behalf{{if .Scopes}} with these permissions:{{else}}.{{end}}</p>
+{{if .Scopes}}<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<input type="hidden" name="response_type" value="code">
+<input type="hidden" name="client_id" value="{{.Client.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Scope}}">
+<input type="hidden" name="state" value="{{.State}}">
+<input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.CodeChallengeMethod}}">
+<input type="hidden" name="nonce" value="{{.Nonce}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<div class="actions">
+<button type="submit" name="action" value="allow">Allow</button>
+<button type="submit" name="action" value="deny" formnovalidate>Deny</button>
+</div>
+</form>
+</main>
+</body>
+</html>
+`))
+
+// errorPage reports authorization requests that cannot be sent back to the client
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Authorization failed</title></head>
+<body>
+<h1>Authorization failed</h1>
+<p>{{.Description}} ({{.Code}})</p>
+</body>
+</html>
+`))
+
+// consentData fills the consent page
+type consentData struct {
+	*services.AuthorizeRequest
+	Scopes    []string
+	User      *models.User
+	Email     string
+	Error     string
+	CSRFToken string
+}
+
+// Authorize serves the authorization endpoint. GET shows the consent page;
+// POST checks the page's anti-CSRF token, signs the user in if needed and,
+// when they allow the request, redirects back to the client with an
+// authorization code.
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		params = r.PostForm
+	}
+
+	req, err := h.oauthService.ParseAuthorizeRequest(r.Context(), params)
+	if err != nil {
+		if req == nil {
+			h.renderError(w, err)
+			return
+		}
+		redirectWithError(w, r, req, err)
+		return
+	}
+
+	user, authTime := h.sessionUser(r)
+	data := &consentData{AuthorizeRequest: req, Scopes: models.SplitScopes(req.Scope), User: user}
+
+	if r.Method == http.MethodGet {
+		h.renderConsent(w, r, http.StatusOK, data)
+		return
+	}
+
+	// The form must come from a consent page rendered for this browser
+	if !h.oauthService.CheckConsentToken(h.consentBinding(r, user), params.Get("csrf_token")) {
+		data.Error = "This page expired; review the request and try again."
+		h.renderConsent(w, r, http.StatusForbidden, data)
+		return
+	}
+
+	if params.Get("action") != "allow" {
+		redirectWithError(w, r, req, &models.OAuthError{Code: models.OAuthErrAccessDenied, Description: "the user denied the request"})
+		return
+	}
+
+	if user == nil {
+		data.Email = params.Get("email")
+		user, err = h.oauthService.SignIn(r.Context(), data.Email, params.Get("password"))
+		if err != nil {
+			switch err.Error() {
+			case "email and password are required", "invalid email or password", "account is deactivated":
+				data.Error = err.Error()
+			case "password change required", "password expired":
+				data.Error = "Your password must be changed before you can sign in."
+			default:
+				data.Error = "Sign-in failed; try again."
+			}
+			h.renderConsent(w, r, http.StatusUnauthorized, data)
+			return
+		}
+		authTime = time.Now()
+		h.setSession(w, user)
+	}
+
+	code, err := h.oauthService.IssueCode(r.Context(), req, user, authTime)
+	if err != nil {
+		redirectWithError(w, r, req, &models.OAuthError{Code: models.OAuthErrServerError})
+		return
+	}
+
+	redirectToClient(w, r, req, url.Values{"code": {code}})
+}
+
+// Token serves the token endpoint for the authorization_code,
+// refresh_token and client_credentials grants
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := h.oauthService.Token(r.Context(), client, r.PostForm)
+	if err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+
+	writeOAuthJSON(w, http.StatusOK, token)
+}
+
+// Introspect serves the token introspection endpoint (RFC 7662). Only
+// confidential clients may introspect tokens.
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+	if client.Public {
+		writeOAuthError(w, &models.OAuthError{Code: models.OAuthErrInvalidClient, Description: "public clients cannot introspect tokens", Status: http.StatusUnauthorized})
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, &models.OAuthError{Code: models.OAuthErrInvalidRequest, Description: "token is required", Status: http.StatusBadRequest})
+		return
+	}
+
+	writeOAuthJSON(w, http.StatusOK, h.oauthService.Introspect(r.Context(), token))
+}
+
+// Revoke serves the token revocation endpoint (RFC 7009)
+func (h *OAuthHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	client, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, &models.OAuthError{Code: models.OAuthErrInvalidRequest, Description: "token is required", Status: http.StatusBadRequest})
+		return
+	}
+
+	if err := h.oauthService.Revoke(r.Context(), client, token); err != nil {
+		writeOAuthError(w, &models.OAuthError{Code: models.OAuthErrServerError, Status: http.StatusServiceUnavailable})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticateClient parses a form POST and authenticates the client with
+// HTTP Basic credentials or client_id and client_secret form fields (RFC
+// 6749 section 2.3.1). It writes the error response when it fails.
+func (h *OAuthHandler) authenticateClient(w http.ResponseWriter, r *http.Request) (*models.OAuthClient, bool) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return nil, false
+	}
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, &models.OAuthError{Code: models.OAuthErrInvalidRequest, Description: "invalid form body", Status: http.StatusBadRequest})
+		return nil, false
+	}
+
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		// Basic credentials are form-encoded before they are joined
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	client, err := h.oauthService.AuthenticateClient(r.Context(), clientID, secret)
+	if err != nil {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeOAuthError(w, err)
+		return nil, false
+	}
+	return client, true
+}
+
+// sessionUser returns the user signed in with the session cookie and when
+// they signed in, or nil
+func (h *OAuthHandler) sessionUser(r *http.Request) (*models.User, time.Time) {
+	cookie, err := r.Cookie(oauthSessionCookie)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	user, authTime, err := h.oauthService.SessionUser(r.Context(), cookie.Value)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	return user, authTime
+}
+
+// setSession signs the user in to the authorization server with a cookie
+func (h *OAuthHandler) setSession(w http.ResponseWriter, user *models.User) {
+	token, err := h.oauthService.NewSession(user)
+	if err != nil {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthSessionCookie,
+		Value:    token,
+		Path:     "/oauth",
+		MaxAge:   int(h.config.OAuthSessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.config.OAuthIssuer, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// consentBinding returns what the consent form's anti-CSRF token is tied to:
+// the sign-in cookie of a signed-in user, or else the consent cookie
+func (h *OAuthHandler) consentBinding(r *http.Request, user *models.User) string {
+	name := oauthConsentCookie
+	if user != nil {
+		name = oauthSessionCookie
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// renderConsent writes the consent page with a new anti-CSRF token, setting
+// the consent cookie first when the user is not signed in and has none. The
+// page may not be framed by other sites.
+func (h *OAuthHandler) renderConsent(w http.ResponseWriter, r *http.Request, status int, data *consentData) {
+	binding := h.consentBinding(r, data.User)
+	if binding == "" {
+		var err error
+		if binding, err = h.oauthService.NewConsentBinding(); err != nil {
+			h.renderError(w, err)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauthConsentCookie,
+			Value:    binding,
+			Path:     "/oauth",
+			HttpOnly: true,
+			Secure:   strings.HasPrefix(h.config.OAuthIssuer, "https://"),
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	token, err := h.oauthService.ConsentToken(binding)
+	if err != nil {
+		h.renderError(w, err)
+		return
+	}
+	data.CSRFToken = token
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+	consentPage.Execute(w, data)
+}
+
+// renderError writes the error page for requests that cannot be redirected
+func (h *OAuthHandler) renderError(w http.ResponseWriter, err error) {
+	oauthErr, ok := err.(*models.OAuthError)
+	if !ok {
+		oauthErr = &models.OAuthError{Code: models.OAuthErrServerError, Description: "unexpected error"}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	errorPage.Execute(w, oauthErr)
+}
+
+// redirectWithError sends an authorization error back to the client
+func redirectWithError(w http.ResponseWriter, r *http.Request, req *services.AuthorizeRequest, err error) {
+	oauthErr, ok := err.(*models.OAuthError)
+	if !ok {
+		oauthErr = &models.OAuthError{Code: models.OAuthErrServerError}
+	}
+
+	values := url.Values{"error": {oauthErr.Code}}
+	if oauthErr.Description != "" {
+		values.Set("error_description", oauthErr.Description)
+	}
+	redirectToClient(w, r, req, values)
+}
+
+// redirectToClient redirects to the request's redirect URI with values and
+// the state added to its query
+func redirectToClient(w http.ResponseWriter, r *http.Request, req *services.AuthorizeRequest, values url.Values) {
+	target, _ := url.Parse(req.RedirectURI)
+	query := target.Query()
+	for key, value := range values {
+		query[key] = value
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	target.RawQuery = query.Encode()
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// writeOAuthJSON writes a token endpoint response, which must not be cached
+func writeOAuthJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeOAuthError writes an OAuth error response (RFC 6749 section 5.2)
+func writeOAuthError(w http.ResponseWriter, err error) {
+	oauthErr, ok := err.(*models.OAuthError)
+	if !ok {
+		oauthErr = &models.OAuthError{Code: models.OAuthErrServerError, Status: http.StatusInternalServerError}
+	}
+	status := oauthErr.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	writeOAuthJSON(w, status, oauthErr)
+}
+
+// ListClients retrieves every registered OAuth client
+func (h *OAuthHandler) ListClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	clients, err := h.oauthService.ListClients(r.Context())
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve OAuth clients")
+		return
+	}
+
+	responses := make([]*models.OAuthClientResponse, len(clients))
+	for i, client := range clients {
+		responses[i] = client.ToOAuthClientResponse()
+	}
+
+	utils.SuccessResponse(w, "OAuth clients retrieved successfully", responses)
+}
+
+// CreateClient registers an OAuth client; a confidential client's secret is only shown here
+func (h *OAuthHandler) CreateClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.OAuthClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	client, secret, err := h.oauthService.CreateClient(r.Context(), &req)
+	if err != nil {
+		writeOAuthClientError(w, err, "Failed to create OAuth client")
+		return
+	}
+
+	message := "OAuth client created successfully"
+	if secret != "" {
+		message = "OAuth client created successfully; store the secret now, it cannot be shown again"
+	}
+
+	utils.JSON(w, http.StatusCreated, utils.Response{
+		Success: true,
+		Message: message,
+		Data:    &models.CreatedOAuthClientResponse{OAuthClientResponse: client.ToOAuthClientResponse(), ClientSecret: secret},
+	})
+}
+
+// GetClient retrieves a single OAuth client
+func (h *OAuthHandler) GetClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	client, err := h.oauthService.GetClient(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeOAuthClientError(w, err, "Failed to retrieve OAuth client")
+		return
+	}
+
+	utils.SuccessResponse(w, "OAuth client retrieved successfully", client.ToOAuthClientResponse())
+}
+
+// UpdateClient replaces an OAuth client's settings
+func (h *OAuthHandler) UpdateClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.OAuthClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	client, err := h.oauthService.UpdateClient(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeOAuthClientError(w, err, "Failed to update OAuth client")
+		return
+	}
+
+	utils.SuccessResponse(w, "OAuth client updated successfully", client.ToOAuthClientResponse())
+}
+
+// DeleteClient removes an OAuth client with its codes and refresh tokens
+func (h *OAuthHandler) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err := h.oauthService.DeleteClient(r.Context(), mux.Vars(r)["id"]); err != nil {
+		writeOAuthClientError(w, err, "Failed to delete OAuth client")
+		return
+	}
+
+	utils.SuccessResponse(w, "OAuth client deleted successfully", nil)
+}
+
+// writeOAuthClientError maps client registration errors to HTTP responses
+func writeOAuthClientError(w http.ResponseWriter, err error, fallback string) {
+	message := err.Error()
+	for _, prefix := range []string{"unsupported grant type: ", "invalid redirect URI: ", "invalid scope: "} {
+		if strings.HasPrefix(message, prefix) {
+			utils.ErrorResponse(w, http.StatusBadRequest, message)
+			return
+		}
+	}
+
+	switch message {
+	case "invalid client ID", "name is required", "name must be at most 100 characters", "at least one grant type is required",
+		"public clients cannot use the client_credentials grant", "the authorization_code grant needs at least one redirect URI":
+		utils.ErrorResponse(w, http.StatusBadRequest, message)
+	case "client not found":
+		utils.ErrorResponse(w, http.StatusNotFound, message)
+	default:
+		utils.ErrorResponse(w, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+
+	"user-management-system/models"
+)
+
+func TestConsentFormNeedsCSRFToken(t *testing.T) {
+	env := newTestEnv(t)
+	server, oauthService := env.newOIDCServer()
+	user := env.createUser("jane@example.com", "user")
+
+	const redirectURI = "https://rp.example.com/callback"
+	client, _, err := oauthService.CreateClient(env.callerContext(user), &models.OAuthClientRequest{
+		Name:         "Relying Party",
+		RedirectURIs: []string{redirectURI},
+		GrantTypes:   []string{models.GrantAuthorizationCode},
+		Scopes:       []string{"reports"},
+	})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	newBrowser := func() *http.Client {
+		jar, _ := cookiejar.New(nil)
+		return &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	}
+	authorizeParams := url.Values{"response_type": {"code"}, "client_id": {client.ClientID}, "redirect_uri": {redirectURI}, "scope": {"reports"}, "state": {"xyz"}}
+	consentPage := func(browser *http.Client) string {
+		t.Helper()
+		resp, err := browser.Get(server.URL + "/oauth/authorize?" + authorizeParams.Encode())
+		return readBody(t, resp, err, http.StatusOK)
+	}
+	post := func(browser *http.Client, fields url.Values, status int) *http.Response {
+		t.Helper()
+		form := url.Values{"action": {"allow"}}
+		for key, values := range authorizeParams {
+			form[key] = values
+		}
+		for key, values := range fields {
+			form[key] = values
+		}
+		resp, err := browser.PostForm(server.URL+"/oauth/authorize", form)
+		page := readBody(t, resp, err, status)
+		if status == http.StatusForbidden && (!strings.Contains(page, "This page expired") || resp.Header.Get("Location") != "") {
+			t.Fatalf("refused consent = %s", page)
+		}
+		return resp
+	}
+	signIn := url.Values{"email": {"jane@example.com"}, "password": {testPassword}}
+
+	victim := newBrowser()
+	token := csrfToken(t, consentPage(victim))
+
+	// A form posted from another page carries no token, or the wrong one
+	post(victim, signIn, http.StatusForbidden)
+	signIn.Set("csrf_token", token+"x")
+	post(victim, signIn, http.StatusForbidden)
+
+	// A token rendered for another browser does not fit this one
+	signIn.Set("csrf_token", csrfToken(t, consentPage(newBrowser())))
+	post(victim, signIn, http.StatusForbidden)
+
+	signIn.Set("csrf_token", token)
+	if resp := post(victim, signIn, http.StatusFound); !strings.Contains(resp.Header.Get("Location"), "code=") {
+		t.Fatalf("consent redirected to %q", resp.Header.Get("Location"))
+	}
+
+	// Signed in, the victim is asked only to allow. A form auto-submitted by
+	// a same-site page cannot reuse the token of the sign-in form either.
+	post(victim, url.Values{"csrf_token": {token}}, http.StatusForbidden)
+	post(victim, nil, http.StatusForbidden)
+
+	page := consentPage(victim)
+	if !strings.Contains(page, "Signed in as") {
+		t.Fatalf("not signed in: %s", page)
+	}
+	if resp := post(victim, url.Values{"csrf_token": {csrfToken(t, page)}}, http.StatusFound); !strings.Contains(resp.Header.Get("Location"), "code=") {
+		t.Fatalf("consent redirected to %q", resp.Header.Get("Location"))
+	}
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"user-management-system/models"
+	"user-management-system/utils"
+)
+
+// logoutPage asks the user to confirm signing out when the request did not
+// prove which session it is for, and reports that they signed out
+var logoutPage = template.Must(template.New("logout").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Sign out</title></head>
+<body>
+{{if .Confirm}}
+<h1>Sign out?</h1>
+<form method="post" action="/oauth/logout">
+<input type="hidden" name="client_id" value="{{.ClientID}}">
+<input type="hidden" name="post_logout_redirect_uri" value="{{.RedirectURI}}">
+<input type="hidden" name="state" value="{{.State}}">
+<button type="submit" name="confirm" value="yes">Sign out</button>
+</form>
+{{else}}
+<h1>You are signed out</h1>
+{{end}}
+</body>
+</html>
+`))
+
+// logoutData fills the logout page
+type logoutData struct {
+	Confirm     bool
+	ClientID    string
+	RedirectURI string
+	State       string
+}
+
+// Discovery serves the OpenID Provider metadata
+func (h *OAuthHandler) Discovery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	utils.JSON(w, http.StatusOK, h.oauthService.Discovery())
+}
+
+// JWKS serves the public keys ID tokens are signed with
+func (h *OAuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	utils.JSON(w, http.StatusOK, h.oauthService.JWKS())
+}
+
+// UserInfo returns the claims about the user an access token was issued
+// for. The token is sent as a bearer token (RFC 6750).
+func (h *OAuthHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo"`)
+		writeOAuthError(w, &models.OAuthError{Code: models.OAuthErrInvalidRequest, Description: "a bearer access token is required", Status: http.StatusUnauthorized})
+		return
+	}
+
+	info, err := h.oauthService.UserInfo(r.Context(), token)
+	if err != nil {
+		if oauthErr, ok := err.(*models.OAuthError); ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo", error="`+oauthErr.Code+`", error_description="`+oauthErr.Description+`"`)
+		}
+		writeOAuthError(w, err)
+		return
+	}
+
+	writeOAuthJSON(w, http.StatusOK, info)
+}
+
+// Logout serves the end_session endpoint. It signs the user out of the
+// authorization server, asking first unless an id_token_hint for the
+// signed-in user was sent, then redirects to the client's post-logout
+// redirect URI if one was asked for. Tokens already issued to clients stay
+// valid.
+func (h *OAuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		params = r.PostForm
+	}
+
+	req, err := h.oauthService.ParseLogoutRequest(r.Context(), params)
+	if err != nil {
+		h.renderError(w, err)
+		return
+	}
+
+	user, _ := h.sessionUser(r)
+	confirmed := r.Method == http.MethodPost && params.Get("confirm") == "yes"
+	if req.Subject != "" && (user == nil || user.ID.Hex() == req.Subject) {
+		confirmed = true
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if !confirmed {
+		data := &logoutData{Confirm: true, RedirectURI: req.RedirectURI, State: req.State}
+		if req.Client != nil {
+			data.ClientID = req.Client.ClientID
+		}
+		h.renderLogout(w, data)
+		return
+	}
+
+	h.clearSession(w)
+
+	if req.RedirectURI != "" {
+		target, _ := url.Parse(req.RedirectURI)
+		if req.State != "" {
+			query := target.Query()
+			query.Set("state", req.State)
+			target.RawQuery = query.Encode()
+		}
+		http.Redirect(w, r, target.String(), http.StatusFound)
+		return
+	}
+
+	h.renderLogout(w, &logoutData{})
+}
+
+// clearSession signs the user out of the authorization server
+func (h *OAuthHandler) clearSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthSessionCookie,
+		Value:    "",
+		Path:     "/oauth",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.config.OAuthIssuer, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// renderLogout writes the logout page; it may not be framed by other sites
+func (h *OAuthHandler) renderLogout(w http.ResponseWriter, data *logoutData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(http.StatusOK)
+	logoutPage.Execute(w, data)
+}
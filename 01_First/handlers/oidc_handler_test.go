@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management-system/config"
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/services"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+// newOIDCServer serves the authorization server endpoints as routes.go does,
+// with the issuer set to the test server's URL
+func (env *testEnv) newOIDCServer() (*httptest.Server, *services.OAuthService) {
+	env.t.Helper()
+
+	signingKey, err := services.LoadSigningKey("")
+	if err != nil {
+		env.t.Fatalf("LoadSigningKey: %v", err)
+	}
+	cfg := &config.Config{
+		JWTSecret:            "test-secret",
+		JWTExpireHours:       1,
+		OAuthCodeTTL:         time.Minute,
+		OAuthAccessTokenTTL:  time.Hour,
+		OAuthRefreshTokenTTL: 24 * time.Hour,
+		OAuthSessionTTL:      time.Hour,
+	}
+	oauthService := services.NewOAuthService(repositories.NewOAuthClientRepository(env.db.Collection("oauth_clients")), repositories.NewOAuthTokenRepository(env.db.Collection("oauth_tokens")), env.userRepo, env.userService, env.auditService, signingKey, cfg)
+	oauthHandler := NewOAuthHandler(oauthService, cfg)
+
+	router := mux.NewRouter()
+	oauth := router.PathPrefix("/oauth").Subrouter()
+	oauth.HandleFunc("/authorize", oauthHandler.Authorize).Methods("GET", "POST")
+	oauth.HandleFunc("/token", oauthHandler.Token).Methods("POST")
+	router.HandleFunc("/.well-known/openid-configuration", oauthHandler.Discovery).Methods("GET")
+	oauth.HandleFunc("/jwks", oauthHandler.JWKS).Methods("GET")
+	oauth.HandleFunc("/userinfo", oauthHandler.UserInfo).Methods("GET", "POST")
+	oauth.HandleFunc("/logout", oauthHandler.Logout).Methods("GET", "POST")
+
+	server := httptest.NewServer(router)
+	env.t.Cleanup(server.Close)
+	cfg.OAuthIssuer = server.URL
+	return server, oauthService
+}
+
+// getJSON decodes the JSON body of a successful response into v
+func getJSON(t *testing.T, resp *http.Response, err error, v interface{}) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status %d: %s", resp.StatusCode, body)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+}
+
+// readBody returns the body of a response with the status wanted
+func readBody(t *testing.T, resp *http.Response, err error, status int) string {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != status {
+		t.Fatalf("status %d, want %d: %s", resp.StatusCode, status, body)
+	}
+	return string(body)
+}
+
+// TestOpenIDConnectFlow signs a user in to a relying party the way an OIDC
+// library would: it finds the endpoints by discovery, runs the authorization
+// code flow with PKCE, verifies the ID token with the published key, reads
+// userinfo and signs the user out.
+func TestOpenIDConnectFlow(t *testing.T) {
+	env := newTestEnv(t)
+	server, oauthService := env.newOIDCServer()
+	user := env.createUser("jane@example.com", "user")
+
+	const redirectURI = "https://rp.example.com/callback"
+	const logoutURI = "https://rp.example.com/signed-out"
+	client, _, err := oauthService.CreateClient(env.callerContext(user), &models.OAuthClientRequest{
+		Name:                   "Relying Party",
+		Public:                 true,
+		RedirectURIs:           []string{redirectURI},
+		GrantTypes:             []string{models.GrantAuthorizationCode},
+		Scopes:                 []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail},
+		PostLogoutRedirectURIs: []string{logoutURI},
+	})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	// Discovery
+	var discovery models.OIDCDiscovery
+	resp, err := http.Get(server.URL + "/.well-known/openid-configuration")
+	getJSON(t, resp, err, &discovery)
+	if discovery.Issuer != server.URL || !strings.HasPrefix(discovery.AuthorizationEndpoint, server.URL) {
+		t.Fatalf("discovery = %+v", discovery)
+	}
+
+	var jwks models.JSONWebKeySet
+	resp, err = http.Get(discovery.JWKSURI)
+	getJSON(t, resp, err, &jwks)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != "RSA" || jwks.Keys[0].Alg != "RS256" {
+		t.Fatalf("jwks = %+v", jwks)
+	}
+	publicKey := rsaPublicKey(t, jwks.Keys[0])
+
+	// Authorization: the consent page, then signing in and allowing
+	verifier := strings.Repeat("v", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	authorizeParams := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {"openid profile email"},
+		"state":                 {"af0ifjsldkj"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	resp, err = browser.Get(discovery.AuthorizationEndpoint + "?" + authorizeParams.Encode())
+	page := readBody(t, resp, err, http.StatusOK)
+	if !strings.Contains(page, "Authorize Relying Party") || !strings.Contains(page, "Sign in to continue") {
+		t.Fatalf("consent page = %s", page)
+	}
+
+	form := url.Values{"email": {"jane@example.com"}, "password": {testPassword}, "action": {"allow"}, "csrf_token": {csrfToken(t, page)}}
+	for key, values := range authorizeParams {
+		form[key] = values
+	}
+	resp, err = browser.PostForm(discovery.AuthorizationEndpoint, form)
+	readBody(t, resp, err, http.StatusFound)
+	callback, _ := url.Parse(resp.Header.Get("Location"))
+	if callback.Scheme+"://"+callback.Host+callback.Path != redirectURI || callback.Query().Get("state") != "af0ifjsldkj" || callback.Query().Get("code") == "" {
+		t.Fatalf("redirected to %s", callback)
+	}
+
+	// Token
+	var tokens models.OAuthTokenResponse
+	resp, err = http.PostForm(discovery.TokenEndpoint, url.Values{
+		"grant_type":    {models.GrantAuthorizationCode},
+		"client_id":     {client.ClientID},
+		"code":          {callback.Query().Get("code")},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	})
+	getJSON(t, resp, err, &tokens)
+	if tokens.AccessToken == "" || tokens.IDToken == "" || tokens.RefreshToken != "" {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+
+	// ID token verification, as the relying party does it
+	var idClaims struct {
+		Nonce  string `json:"nonce"`
+		AtHash string `json:"at_hash"`
+		Email  string `json:"email"`
+		Name   string `json:"name"`
+		jwt.RegisteredClaims
+	}
+	idToken, err := jwt.ParseWithClaims(tokens.IDToken, &idClaims, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["kid"] != jwks.Keys[0].Kid {
+			t.Errorf("ID token kid = %v, want %s", token.Header["kid"], jwks.Keys[0].Kid)
+		}
+		return publicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(discovery.Issuer), jwt.WithAudience(client.ClientID), jwt.WithExpirationRequired())
+	if err != nil || !idToken.Valid {
+		t.Fatalf("verifying the ID token: %v", err)
+	}
+	atHash := sha256.Sum256([]byte(tokens.AccessToken))
+	if idClaims.Subject != user.ID.Hex() || idClaims.Nonce != "n-0S6_WzA2Mj" || idClaims.Email != "jane@example.com" ||
+		idClaims.AtHash != base64.RawURLEncoding.EncodeToString(atHash[:16]) {
+		t.Fatalf("ID token claims = %+v", idClaims)
+	}
+
+	// Userinfo
+	req, _ := http.NewRequest(http.MethodGet, discovery.UserInfoEndpoint, nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	var info models.OIDCUserInfo
+	resp, err = http.DefaultClient.Do(req)
+	getJSON(t, resp, err, &info)
+	if info.Sub != idClaims.Subject || info.Email != "jane@example.com" || info.Name != "Test User" {
+		t.Fatalf("userinfo = %+v", info)
+	}
+
+	// The user stays signed in to the authorization server until logout
+	resp, err = browser.Get(discovery.AuthorizationEndpoint + "?" + authorizeParams.Encode())
+	if page := readBody(t, resp, err, http.StatusOK); !strings.Contains(page, "Signed in as <strong>jane@example.com</strong>") {
+		t.Fatalf("not signed in after authorizing: %s", page)
+	}
+
+	// Logout with the ID token as hint signs out without asking
+	logoutParams := url.Values{"id_token_hint": {tokens.IDToken}, "post_logout_redirect_uri": {logoutURI}, "state": {"bye"}}
+	resp, err = browser.Get(discovery.EndSessionEndpoint + "?" + logoutParams.Encode())
+	readBody(t, resp, err, http.StatusFound)
+	if location := resp.Header.Get("Location"); location != logoutURI+"?state=bye" {
+		t.Fatalf("logout redirected to %q", location)
+	}
+
+	resp, err = browser.Get(discovery.AuthorizationEndpoint + "?" + authorizeParams.Encode())
+	if page := readBody(t, resp, err, http.StatusOK); !strings.Contains(page, "Sign in to continue") {
+		t.Fatalf("still signed in after logout: %s", page)
+	}
+}
+
+// csrfTokenField finds the anti-CSRF token of the consent form
+var csrfTokenField = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+// csrfToken returns the anti-CSRF token of a consent page
+func csrfToken(t *testing.T, page string) string {
+	t.Helper()
+
+	match := csrfTokenField.FindStringSubmatch(page)
+	if match == nil {
+		t.Fatalf("no csrf_token on the consent page: %s", page)
+	}
+	return match[1]
+}
+
+// rsaPublicKey decodes an RSA JSON Web Key
+func rsaPublicKey(t *testing.T, key models.JSONWebKey) *rsa.PublicKey {
+	t.Helper()
+
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		t.Fatalf("decoding n: %v", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		t.Fatalf("decoding e: %v", err)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"user-management-system/models"
+	"user-management-system/services"
+	"user-management-system/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// OrganizationHandler handles requests for organizations and their members
+type OrganizationHandler struct {
+	orgService *services.OrganizationService
+}
+
+// NewOrganizationHandler creates a new organization handler
+func NewOrganizationHandler(orgService *services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService: orgService,
+	}
+}
+
+// CreateOrganization creates an organization, optionally with an existing user as owner
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	org, err := h.orgService.CreateOrganization(r.Context(), &req)
+	if err != nil {
+		writeOrganizationError(w, err, "Failed to create organization")
+		return
+	}
+
+	utils.JSON(w, http.StatusCreated, utils.Response{
+		Success: true,
+		Message: "Organization created successfully",
+		Data:    org,
+	})
+}
+
+// ListOrganizations retrieves the organizations visible to the caller
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	page, limit := parsePagination(r, 20)
+
+	orgs, totalPages, total, err := h.orgService.ListOrganizations(r.Context(), page, limit)
+	if err != nil {
+		writeOrganizationError(w, err, "Failed to retrieve organizations")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(w, orgs, page, limit, total, totalPages)
+}
+
+// GetOrganization retrieves a single organization
+func (h *OrganizationHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	org, err := h.orgService.GetOrganization(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeOrganizationError(w, err, "Failed to retrieve organization")
+		return
+	}
+
+	utils.SuccessResponse(w, "Organization retrieved successfully", org)
+}
+
+// UpdateOrganization renames an organization
+func (h *OrganizationHandler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.UpdateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	org, err := h.orgService.UpdateOrganization(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeOrganizationError(w, err, "Failed to update organization")
+		return
+	}
+
+	utils.SuccessResponse(w, "Organization updated successfully", org)
+}
+
+// ListMembers retrieves the members of an organization with the user list filters
+func (h *OrganizationHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	page, limit := parsePagination(r, 10)
+
+	filter, status, err := parseUserFilter(r)
+	if err != nil {
+		utils.ErrorResponse(w, status, err.Error())
+		return
+	}
+
+	members, totalPages, total, err := h.orgService.ListMembers(r.Context(), mux.Vars(r)["id"], filter, page, limit)
+	if err != nil {
+		writeOrganizationError(w, err, "Failed to retrieve members")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(w, members, page, limit, total, totalPages)
+}
+
+// AddMember adds an existing user to an organization
+func (h *OrganizationHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.AddMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.orgService.AddMember(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		writeOrganizationError(w, err, "Failed to add member")
+		return
+	}
+
+	utils.JSON(w, http.StatusCreated, utils.Response{
+		Success: true,
+		Message: "Member added successfully",
+		Data:    user,
+	})
+}
+
+// UpdateMember changes a member's organization role
+func (h *OrganizationHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	vars := mux.Vars(r)
+	user, err := h.orgService.UpdateMember(r.Context(), vars["id"], vars["userId"], &req)
+	if err != nil {
+		writeOrganizationError(w, err, "Failed to update member")
+		return
+	}
+
+	utils.SuccessResponse(w, "Member updated successfully", user)
+}
+
+// RemoveMember removes a user from an organization; members may remove themselves
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := h.orgService.RemoveMember(r.Context(), vars["id"], vars["userId"]); err != nil {
+		writeOrganizationError(w, err, "Failed to remove member")
+		return
+	}
+
+	utils.SuccessResponse(w, "Member removed successfully", nil)
+}
+
+// parsePagination reads the page and limit query parameters
+func parsePagination(r *http.Request, defaultLimit int) (int, int) {
+	page := 1
+	limit := defaultLimit
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	return page, limit
+}
+
+// writeOrganizationError maps organization service errors to HTTP responses
+func writeOrganizationError(w http.ResponseWriter, err error, fallback string) {
+	switch err.Error() {
+	case "invalid organization ID", "invalid user ID", "name must be between 2 and 100 characters",
+		"slug must be 2 to 50 lowercase letters, digits or dashes", "role must be owner, admin or member":
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	case "insufficient organization role", "only owners can manage owners",
+		"only super-admins can add existing users; invite new members instead":
+		utils.ErrorResponse(w, http.StatusForbidden, err.Error())
+	case "organization not found", "user not found", "membership not found":
+		utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+	case "organization slug already exists", "user is already a member", "organization must keep at least one owner",
+		"cannot remove a user from their home organization":
+		utils.ErrorResponse(w, http.StatusConflict, err.Error())
+	default:
+		utils.ErrorResponse(w, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"user-management-system/models"
+	"user-management-system/services"
+
+	"github.com/gorilla/mux"
+)
+
+// SCIMHandler handles SCIM 2.0 provisioning requests
+type SCIMHandler struct {
+	scimService *services.SCIMService
+}
+
+// NewSCIMHandler creates a new SCIM handler
+func NewSCIMHandler(scimService *services.SCIMService) *SCIMHandler {
+	return &SCIMHandler{
+		scimService: scimService,
+	}
+}
+
+// CreateUser provisions a user
+func (h *SCIMHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var resource models.SCIMUser
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil {
+		writeSCIMError(w, models.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "Invalid request body"))
+		return
+	}
+
+	user, err := h.scimService.CreateUser(r.Context(), &resource)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	resourceOut := user.ToSCIMUser(scimBaseURL(r))
+	w.Header().Set("Location", resourceOut.Meta.Location)
+	writeSCIM(w, http.StatusCreated, resourceOut)
+}
+
+// GetUser retrieves a provisioned user
+func (h *SCIMHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.scimService.GetUser(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	writeSCIM(w, http.StatusOK, user.ToSCIMUser(scimBaseURL(r)))
+}
+
+// ListUsers lists users with filter, startIndex and count
+func (h *SCIMHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	startIndex := 1
+	if value := query.Get("startIndex"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			startIndex = parsed
+		}
+	}
+
+	count := 100
+	if value := query.Get("count"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			count = parsed
+		}
+	}
+
+	users, total, err := h.scimService.ListUsers(r.Context(), query.Get("filter"), startIndex, count)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	baseURL := scimBaseURL(r)
+	resources := make([]*models.SCIMUser, len(users))
+	for i, user := range users {
+		resources[i] = user.ToSCIMUser(baseURL)
+	}
+
+	writeSCIM(w, http.StatusOK, &models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// ReplaceUser replaces a provisioned user (PUT)
+func (h *SCIMHandler) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	var resource models.SCIMUser
+	if err := json.NewDecoder(r.Body).Decode(&resource); err != nil {
+		writeSCIMError(w, models.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "Invalid request body"))
+		return
+	}
+
+	user, err := h.scimService.ReplaceUser(r.Context(), mux.Vars(r)["id"], &resource)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	writeSCIM(w, http.StatusOK, user.ToSCIMUser(scimBaseURL(r)))
+}
+
+// PatchUser applies PATCH operations to a provisioned user
+func (h *SCIMHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	var patch models.SCIMPatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeSCIMError(w, models.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "Invalid request body"))
+		return
+	}
+
+	user, err := h.scimService.PatchUser(r.Context(), mux.Vars(r)["id"], &patch)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	writeSCIM(w, http.StatusOK, user.ToSCIMUser(scimBaseURL(r)))
+}
+
+// DeleteUser deprovisions a user
+func (h *SCIMHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := h.scimService.DeleteUser(r.Context(), mux.Vars(r)["id"]); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ServiceProviderConfig describes the supported SCIM features
+func (h *SCIMHandler) ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeSCIM(w, http.StatusOK, map[string]interface{}{
+		"schemas":          []string{models.SCIMSchemaServiceProviderConfig},
+		"documentationUri": "https://datatracker.ietf.org/doc/html/rfc7644",
+		"patch":            map[string]bool{"supported": true},
+		"bulk":             map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]interface{}{"supported": true, "maxResults": 200},
+		"changePassword":   map[string]bool{"supported": true},
+		"sort":             map[string]bool{"supported": false},
+		"etag":             map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]interface{}{
+			{
+				"type":        "oauthbearertoken",
+				"name":        "Bearer Token",
+				"description": "Static bearer token configured with SCIM_TOKEN",
+				"primary":     true,
+			},
+		},
+		"meta": map[string]string{
+			"resourceType": "ServiceProviderConfig",
+			"location":     scimBaseURL(r) + "/ServiceProviderConfig",
+		},
+	})
+}
+
+// ResourceTypes lists the supported SCIM resource types
+func (h *SCIMHandler) ResourceTypes(w http.ResponseWriter, r *http.Request) {
+	baseURL := scimBaseURL(r)
+	userType := map[string]interface{}{
+		"schemas":     []string{models.SCIMSchemaResourceType},
+		"id":          "User",
+		"name":        "User",
+		"endpoint":    "/Users",
+		"description": "User Account",
+		"schema":      models.SCIMSchemaUser,
+		"meta": map[string]string{
+			"resourceType": "ResourceType",
+			"location":     baseURL + "/ResourceTypes/User",
+		},
+	}
+
+	writeSCIM(w, http.StatusOK, &models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: 1,
+		StartIndex:   1,
+		ItemsPerPage: 1,
+		Resources:    []interface{}{userType},
+	})
+}
+
+// Schemas describes the attributes of the supported User schema
+func (h *SCIMHandler) Schemas(w http.ResponseWriter, r *http.Request) {
+	attribute := func(name, attrType string, required bool, mutability, uniqueness string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":        name,
+			"type":        attrType,
+			"multiValued": false,
+			"required":    required,
+			"caseExact":   false,
+			"mutability":  mutability,
+			"returned":    "default",
+			"uniqueness":  uniqueness,
+		}
+	}
+
+	emails := attribute("emails", "complex", false, "readWrite", "none")
+	emails["multiValued"] = true
+	emails["subAttributes"] = []map[string]interface{}{
+		attribute("value", "string", true, "readWrite", "server"),
+		attribute("type", "string", false, "readWrite", "none"),
+		attribute("primary", "boolean", false, "readWrite", "none"),
+	}
+
+	roles := attribute("roles", "complex", false, "readWrite", "none")
+	roles["multiValued"] = true
+	roles["description"] = "Exactly one of: user, admin"
+	roles["subAttributes"] = []map[string]interface{}{
+		attribute("value", "string", true, "readWrite", "none"),
+		attribute("primary", "boolean", false, "readWrite", "none"),
+	}
+
+	name := attribute("name", "complex", false, "readWrite", "none")
+	name["subAttributes"] = []map[string]interface{}{
+		attribute("formatted", "string", false, "readWrite", "none"),
+		attribute("givenName", "string", false, "readWrite", "none"),
+		attribute("familyName", "string", false, "readWrite", "none"),
+	}
+
+	password := attribute("password", "string", false, "writeOnly", "none")
+	password["returned"] = "never"
+
+	userSchema := map[string]interface{}{
+		"schemas":     []string{models.SCIMSchemaSchema},
+		"id":          models.SCIMSchemaUser,
+		"name":        "User",
+		"description": "User Account",
+		"attributes": []map[string]interface{}{
+			attribute("userName", "string", true, "readWrite", "server"),
+			name,
+			attribute("displayName", "string", false, "readWrite", "none"),
+			emails,
+			attribute("active", "boolean", false, "readWrite", "none"),
+			roles,
+			password,
+		},
+		"meta": map[string]string{
+			"resourceType": "Schema",
+			"location":     scimBaseURL(r) + "/Schemas/" + models.SCIMSchemaUser,
+		},
+	}
+
+	writeSCIM(w, http.StatusOK, &models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: 1,
+		StartIndex:   1,
+		ItemsPerPage: 1,
+		Resources:    []interface{}{userSchema},
+	})
+}
+
+// scimBaseURL returns the absolute /scim/v2 URL of the current request
+func scimBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/scim/v2"
+}
+
+// writeSCIM writes a SCIM JSON response
+func writeSCIM(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeSCIMError writes err in the SCIM error format
+func writeSCIMError(w http.ResponseWriter, err error) {
+	scimErr, ok := err.(*models.SCIMError)
+	if !ok {
+		scimErr = models.NewSCIMError(http.StatusInternalServerError, "", "Internal server error")
+	}
+
+	status, convErr := strconv.Atoi(scimErr.Status)
+	if convErr != nil {
+		status = http.StatusInternalServerError
+	}
+	writeSCIM(w, status, scimErr)
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"user-management-system/models"
+	"user-management-system/services"
+	"user-management-system/utils"
+)
+
+// maxImportSize caps the size of an import upload
+const maxImportSize = 64 << 20
+
+// UserBulkHandler handles bulk user import, export and deactivation
+type UserBulkHandler struct {
+	bulkService *services.UserBulkService
+}
+
+// NewUserBulkHandler creates a new bulk user handler
+func NewUserBulkHandler(bulkService *services.UserBulkService) *UserBulkHandler {
+	return &UserBulkHandler{
+		bulkService: bulkService,
+	}
+}
+
+// ImportUsers creates users from a CSV or NDJSON upload and returns a per-row report.
+// With async=true the upload is stored and imported by a background job instead.
+func (h *UserBulkHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	opts := models.UserImportOptions{
+		Format: query.Get("format"),
+		DryRun: query.Get("dryRun") == "true",
+		Invite: query.Get("invite") == "true",
+	}
+
+	// Fall back to the Content-Type when no format is given
+	if opts.Format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			opts.Format = models.UserFormatCSV
+		case "application/x-ndjson", "application/json":
+			opts.Format = models.UserFormatNDJSON
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	if query.Get("async") == "true" {
+		job, err := h.bulkService.QueueImport(r.Context(), body, opts)
+		if err != nil {
+			writeImportError(w, err)
+			return
+		}
+		writeJobAccepted(w, job, "Import queued")
+		return
+	}
+
+	report, err := h.bulkService.ImportUsers(r.Context(), body, opts)
+	if err != nil {
+		writeImportError(w, err)
+		return
+	}
+
+	message := "Import completed"
+	if report.DryRun {
+		message = "Dry run completed, nothing was imported"
+	}
+	utils.SuccessResponse(w, message, report)
+}
+
+// ExportUsers streams users matching the list filters as CSV or NDJSON
+func (h *UserBulkHandler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	format, filter, status, err := parseExportRequest(r)
+	if err != nil {
+		utils.ErrorResponse(w, status, err.Error())
+		return
+	}
+
+	if format == models.UserFormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="users.ndjson"`)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	_, err = h.bulkService.ExportUsers(r.Context(), w, format, filter, func(int) {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+	if err != nil {
+		// Headers are already sent; the truncated stream signals the failure
+		return
+	}
+}
+
+// QueueExport queues a background job that exports users matching the list
+// filters to a downloadable file
+func (h *UserBulkHandler) QueueExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	format, filter, status, err := parseExportRequest(r)
+	if err != nil {
+		utils.ErrorResponse(w, status, err.Error())
+		return
+	}
+
+	job, err := h.bulkService.QueueExport(r.Context(), models.UserExportJobParams{
+		Format:         format,
+		Search:         filter.Search,
+		Role:           filter.Role,
+		IsActive:       filter.IsActive,
+		IncludeDeleted: filter.IncludeDeleted,
+	})
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to queue export")
+		return
+	}
+
+	writeJobAccepted(w, job, "Export queued")
+}
+
+// DeactivateUsers queues a background job that deactivates the selected users
+func (h *UserBulkHandler) DeactivateUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.DeactivateUsersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	job, err := h.bulkService.QueueDeactivation(r.Context(), &req)
+	if err != nil {
+		message := err.Error()
+		if strings.HasPrefix(message, "invalid user ID") ||
+			message == "at least one of ids, search or role is required" ||
+			message == "role must be either user or admin" {
+			utils.ErrorResponse(w, http.StatusBadRequest, message)
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to queue deactivation")
+		return
+	}
+
+	writeJobAccepted(w, job, "Deactivation queued")
+}
+
+// parseExportRequest reads the export format and the user list filters
+func parseExportRequest(r *http.Request) (string, models.UserFilter, int, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = models.UserFormatCSV
+	}
+	if format != models.UserFormatCSV && format != models.UserFormatNDJSON {
+		return format, models.UserFilter{}, http.StatusBadRequest, errors.New("format must be csv or ndjson")
+	}
+
+	filter, status, err := parseUserFilter(r)
+	return format, filter, status, err
+}
+
+// writeImportError maps import errors to HTTP responses
+func writeImportError(w http.ResponseWriter, err error) {
+	if strings.HasPrefix(err.Error(), "invalid import file") {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.Contains(err.Error(), "request body too large") {
+		utils.ErrorResponse(w, http.StatusRequestEntityTooLarge, "Import file too large")
+		return
+	}
+	utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to import users")
+}
@@ -2,9 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"user-management-system/middleware"
 	"user-management-system/models"
 	"user-management-system/services"
 	"user-management-system/utils"
@@ -14,13 +18,15 @@ import (
 
 // UserHandler handles user-related requests
 type UserHandler struct {
-	userService *services.UserService
+	userService  *services.UserService
+	groupService *services.GroupService
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(userService *services.UserService) *UserHandler {
+func NewUserHandler(userService *services.UserService, groupService *services.GroupService) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:  userService,
+		groupService: groupService,
 	}
 }
 
@@ -44,7 +50,12 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.SuccessResponse(w, "User retrieved successfully", user)
+	// Other users' profiles are reduced for non-admins, without an ETag to write against
+	view := user.Project(middleware.GetUserID(r.Context()), canSeeFullProfiles(r))
+	if _, full := view.(*models.UserResponse); full {
+		setUserETag(w, user)
+	}
+	utils.SuccessResponse(w, "User retrieved successfully", view)
 }
 
 // UpdateUser updates a user's information
@@ -63,16 +74,56 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userService.UpdateUser(r.Context(), userID, &req)
+	ifMatch, err := parseIfMatch(r)
 	if err != nil {
-		if err.Error() == "user not found" {
-			utils.ErrorResponse(w, http.StatusNotFound, err.Error())
-			return
-		}
 		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	user, err := h.userService.UpdateUserIfMatch(r.Context(), userID, &req, ifMatch)
+	if err != nil {
+		writeUserUpdateError(w, err, ifMatch)
+		return
+	}
+
+	setUserETag(w, user)
+	utils.SuccessResponse(w, "User updated successfully", user)
+}
+
+// PatchUser applies a JSON merge patch (RFC 7396) to a user
+func (h *UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != mergePatchContentType {
+		utils.ErrorResponse(w, http.StatusUnsupportedMediaType, "Content-Type must be "+mergePatchContentType)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	var patch map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Merge patch must be a JSON object")
+		return
+	}
+
+	ifMatch, err := parseIfMatch(r)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := h.userService.PatchUser(r.Context(), userID, patch, ifMatch)
+	if err != nil {
+		writeUserUpdateError(w, err, ifMatch)
+		return
+	}
+
+	setUserETag(w, user)
 	utils.SuccessResponse(w, "User updated successfully", user)
 }
 
@@ -92,6 +143,10 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 			utils.ErrorResponse(w, http.StatusNotFound, err.Error())
 			return
 		}
+		if err.Error() == "only super-admins can modify super-admins" || err.Error() == "only owners can modify owners and admins" {
+			utils.ErrorResponse(w, http.StatusForbidden, err.Error())
+			return
+		}
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to delete user")
 		return
 	}
@@ -99,6 +154,33 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	utils.SuccessResponse(w, "User deleted successfully", nil)
 }
 
+// RestoreUser restores a soft-deleted user
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	user, err := h.userService.RestoreUser(r.Context(), userID)
+	if err != nil {
+		if err.Error() == "deleted user not found" {
+			utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err.Error() == "invalid user ID" {
+			utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to restore user")
+		return
+	}
+
+	utils.SuccessResponse(w, "User restored successfully", user)
+}
+
 // GetAllUsers retrieves all users with pagination
 func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -122,17 +204,55 @@ func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	users, totalPages, total, err := h.userService.GetAllUsers(r.Context(), page, limit)
+	filter, status, err := parseUserFilter(r)
+	if err != nil {
+		utils.ErrorResponse(w, status, err.Error())
+		return
+	}
+
+	// Restrict to the members of a group and of its subgroups
+	if groupID := r.URL.Query().Get("group"); groupID != "" {
+		userIDs, err := h.groupService.MemberUserIDs(r.Context(), groupID)
+		if err != nil {
+			writeGroupError(w, err, "Failed to retrieve users")
+			return
+		}
+		if len(userIDs) == 0 {
+			utils.PaginatedSuccessResponse(w, []*models.UserResponse{}, page, limit, 0, 0)
+			return
+		}
+		filter.IDs = userIDs
+	}
+
+	users, totalPages, total, err := h.userService.SearchUsers(r.Context(), filter, page, limit)
 	if err != nil {
 		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve users")
 		return
 	}
 
-	utils.PaginatedSuccessResponse(w, users, page, limit, total, totalPages)
+	views := models.ProjectUsers(users, middleware.GetUserID(r.Context()), canSeeFullProfiles(r))
+	utils.PaginatedSuccessResponse(w, views, page, limit, total, totalPages)
+}
+
+// GetUserGroups lists the groups a user belongs to, directly or through a subgroup
+func (h *UserHandler) GetUserGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	groups, err := h.groupService.UserGroups(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeGroupError(w, err, "Failed to retrieve groups")
+		return
+	}
+
+	utils.SuccessResponse(w, "Groups retrieved successfully", groups)
 }
 
 // GetAllUsersWithoutLimit retrieves ALL users from database without pagination
-// This endpoint is optimized for large datasets using concurrent processing
+// This endpoint is optimized for large datasets using concurrent processing.
+// The route is admin-only, and profiles are projected like GetAllUsers's anyway.
 func (h *UserHandler) GetAllUsersWithoutLimit(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -146,11 +266,12 @@ func (h *UserHandler) GetAllUsersWithoutLimit(w http.ResponseWriter, r *http.Req
 	}
 
 	// Return all users with total count
+	views := models.ProjectUsers(users, middleware.GetUserID(r.Context()), canSeeFullProfiles(r))
 	response := map[string]interface{}{
 		"success": true,
 		"message": "All users retrieved successfully",
 		"data": map[string]interface{}{
-			"users": users,
+			"users": views,
 			"total": total,
 			"count": len(users),
 		},
@@ -160,3 +281,102 @@ func (h *UserHandler) GetAllUsersWithoutLimit(w http.ResponseWriter, r *http.Req
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// mergePatchContentType is the media type of JSON merge patch bodies
+const mergePatchContentType = "application/merge-patch+json"
+
+// setUserETag sets the ETag header from the user version
+func setUserETag(w http.ResponseWriter, user *models.UserResponse) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(user.Version, 10)+`"`)
+}
+
+// parseIfMatch reads the If-Match header; nil means no precondition (absent or *)
+func parseIfMatch(r *http.Request) (*int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+
+	value = strings.TrimPrefix(value, "W/")
+	version, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid If-Match header")
+	}
+	return &version, nil
+}
+
+// writeUserUpdateError maps update errors to status codes. A version conflict
+// is a failed precondition when the client sent If-Match, otherwise it lost a
+// race with a concurrent edit.
+func writeUserUpdateError(w http.ResponseWriter, err error, ifMatch *int64) {
+	if writePasswordPolicyError(w, err) {
+		return
+	}
+
+	switch err.Error() {
+	case "user not found":
+		utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+	case "only super-admins can change the role", "only super-admins can modify super-admins", "only owners can modify owners and admins":
+		utils.ErrorResponse(w, http.StatusForbidden, err.Error())
+	case "version conflict":
+		if ifMatch != nil {
+			utils.ErrorResponse(w, http.StatusPreconditionFailed, "user has been modified; fetch it again and retry")
+			return
+		}
+		utils.ErrorResponse(w, http.StatusConflict, "user was modified concurrently; retry the request")
+	default:
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// writePasswordPolicyError writes a 400 listing every password policy
+// violation when err is a password policy error, and reports whether it did
+func writePasswordPolicyError(w http.ResponseWriter, err error) bool {
+	policyErr, ok := err.(*models.PasswordPolicyError)
+	if !ok {
+		return false
+	}
+
+	utils.JSON(w, http.StatusBadRequest, utils.Response{
+		Success: false,
+		Error:   policyErr.Error(),
+		Data:    policyErr,
+	})
+	return true
+}
+
+// parseUserFilter reads the search, role, isActive and includeDeleted list
+// filters. Soft-deleted users are only listed for admins that ask for them.
+// Non-admins may only search by name, since they cannot see the other fields.
+func parseUserFilter(r *http.Request) (models.UserFilter, int, error) {
+	query := r.URL.Query()
+	filter := models.UserFilter{
+		Search:         query.Get("search"),
+		Role:           query.Get("role"),
+		SearchNameOnly: !canSeeFullProfiles(r),
+	}
+
+	if value := query.Get("isActive"); value != "" {
+		isActive, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, http.StatusBadRequest, errors.New("isActive must be true or false")
+		}
+		filter.IsActive = &isActive
+	}
+
+	if query.Get("includeDeleted") == "true" {
+		filter.IncludeDeleted = true
+	}
+
+	if !canSeeFullProfiles(r) && (filter.Role != "" || filter.IsActive != nil || filter.IncludeDeleted) {
+		return filter, http.StatusForbidden, errors.New("Insufficient permissions")
+	}
+
+	return filter, http.StatusOK, nil
+}
+
+// canSeeFullProfiles reports whether the caller sees every field of other
+// users; everyone else gets their public profile
+func canSeeFullProfiles(r *http.Request) bool {
+	return middleware.GetRole(r.Context()) == "admin"
+}
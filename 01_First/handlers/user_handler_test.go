@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+)
+
+func TestUserETagAndIfMatch(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin@example.com", "admin")
+	user := env.createUser("jane@example.com", "user")
+	h := NewUserHandler(env.userService, nil)
+	ctx := env.callerContext(admin)
+	target := "/api/users/" + user.ID.Hex()
+
+	got := serve(ctx, "/api/users/{id}", h.GetUser, http.MethodGet, target, "", nil)
+	etag := got.Header().Get("ETag")
+	if got.Code != http.StatusOK || etag != `"`+strconv.FormatInt(user.Version, 10)+`"` {
+		t.Fatalf("GET = %d with ETag %q", got.Code, etag)
+	}
+
+	put := serve(ctx, "/api/users/{id}", h.UpdateUser, http.MethodPut, target, `{"name":"Jane Roe"}`, map[string]string{"If-Match": etag})
+	if put.Code != http.StatusOK || put.Header().Get("ETag") == etag {
+		t.Fatalf("PUT with the current ETag = %d %s", put.Code, put.Body)
+	}
+
+	// The ETag read before the PUT is stale now
+	stale := serve(ctx, "/api/users/{id}", h.UpdateUser, http.MethodPut, target, `{"name":"Jane Poe"}`, map[string]string{"If-Match": etag})
+	if stale.Code != http.StatusPreconditionFailed {
+		t.Fatalf("PUT with a stale ETag = %d %s, want 412", stale.Code, stale.Body)
+	}
+
+	patchHeader := map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": etag}
+	if patched := serve(ctx, "/api/users/{id}", h.PatchUser, http.MethodPatch, target, `{"name":"Jane Poe"}`, patchHeader); patched.Code != http.StatusPreconditionFailed {
+		t.Fatalf("PATCH with a stale ETag = %d %s, want 412", patched.Code, patched.Body)
+	}
+
+	invalid := serve(ctx, "/api/users/{id}", h.UpdateUser, http.MethodPut, target, `{"name":"Jane Poe"}`, map[string]string{"If-Match": "not-a-version"})
+	if invalid.Code != http.StatusBadRequest {
+		t.Fatalf("PUT with an invalid If-Match = %d, want 400", invalid.Code)
+	}
+}
+
+func TestPatchUserRequiresMergePatch(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin@example.com", "admin")
+	user := env.createUser("jane@example.com", "user")
+	h := NewUserHandler(env.userService, nil)
+	ctx := env.callerContext(admin)
+	target := "/api/users/" + user.ID.Hex()
+
+	wrongType := serve(ctx, "/api/users/{id}", h.PatchUser, http.MethodPatch, target, `{"name":"Jane Roe"}`, map[string]string{"Content-Type": "application/json"})
+	if wrongType.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("PATCH with application/json = %d, want 415", wrongType.Code)
+	}
+
+	mergePatch := map[string]string{"Content-Type": "application/merge-patch+json"}
+	if notObject := serve(ctx, "/api/users/{id}", h.PatchUser, http.MethodPatch, target, `["name"]`, mergePatch); notObject.Code != http.StatusBadRequest {
+		t.Fatalf("PATCH with an array = %d, want 400", notObject.Code)
+	}
+
+	patched := serve(ctx, "/api/users/{id}", h.PatchUser, http.MethodPatch, target, `{"name":"Jane Roe"}`, mergePatch)
+	if patched.Code != http.StatusOK || patched.Header().Get("ETag") == "" {
+		t.Fatalf("PATCH = %d %s", patched.Code, patched.Body)
+	}
+}
+
+func TestListUsersByGroup(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin@example.com", "admin")
+	jane := env.createUser("jane@example.com", "user")
+	env.createUser("john@example.com", "user")
+	h := NewUserHandler(env.userService, env.groupService)
+	ctx := env.callerContext(admin)
+
+	engineering, _ := env.groupService.CreateGroup(ctx, &models.CreateGroupRequest{Name: "Engineering"})
+	backend, _ := env.groupService.CreateGroup(ctx, &models.CreateGroupRequest{Name: "Backend", ParentID: engineering.ID.Hex()})
+	empty, _ := env.groupService.CreateGroup(ctx, &models.CreateGroupRequest{Name: "Empty"})
+	env.groupService.AddMembers(ctx, backend.ID.Hex(), &models.GroupMembersRequest{UserIDs: []string{jane.ID.Hex()}})
+
+	// Members of subgroups are listed with the group
+	listed := decodeList(t, serve(ctx, "/api/users", h.GetAllUsers, http.MethodGet, "/api/users?group="+engineering.ID.Hex(), "", nil))
+	if len(listed) != 1 || listed[0]["email"] != jane.Email {
+		t.Fatalf("users in Engineering = %v, want jane", listed)
+	}
+	if listed := decodeList(t, serve(ctx, "/api/users", h.GetAllUsers, http.MethodGet, "/api/users?group="+empty.ID.Hex(), "", nil)); len(listed) != 0 {
+		t.Fatalf("users in an empty group = %v", listed)
+	}
+	if missing := serve(ctx, "/api/users", h.GetAllUsers, http.MethodGet, "/api/users?group=65ab0000567890abcdef9999", "", nil); missing.Code != http.StatusNotFound {
+		t.Fatalf("users in a missing group = %d, want 404", missing.Code)
+	}
+
+	resp := serve(ctx, "/api/users/{id}/groups", h.GetUserGroups, http.MethodGet, "/api/users/"+jane.ID.Hex()+"/groups", "", nil)
+	var groups struct {
+		Data []models.UserGroupResponse `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil || len(groups.Data) != 2 {
+		t.Fatalf("GET groups of jane = %d %+v, %v", resp.Code, groups, err)
+	}
+	if direct, inherited := groups.Data[0], groups.Data[1]; direct.Name != "Backend" || direct.Inherited || inherited.Name != "Engineering" || !inherited.Inherited {
+		t.Fatalf("groups of jane = %+v, %+v", direct.Group, inherited.Group)
+	}
+}
+
+func TestUserDirectoryVisibility(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin@example.com", "admin")
+	jane := env.createUser("jane@example.com", "user")
+	john := env.createUser("john@example.com", "user")
+	h := NewUserHandler(env.userService, env.groupService)
+	janeCtx := env.callerContext(jane)
+
+	// Regular users see their own full profile and the public profile of others
+	for _, user := range decodeList(t, serve(janeCtx, "/api/users", h.GetAllUsers, http.MethodGet, "/api/users", "", nil)) {
+		_, hasEmail := user["email"]
+		if own := user["id"] == jane.ID.Hex(); hasEmail != own || (!own && len(user) != 2) {
+			t.Errorf("jane sees %v", user)
+		}
+	}
+	other := serve(janeCtx, "/api/users/{id}", h.GetUser, http.MethodGet, "/api/users/"+john.ID.Hex(), "", nil)
+	if other.Code != http.StatusOK || strings.Contains(other.Body.String(), john.Email) || other.Header().Get("ETag") != "" {
+		t.Fatalf("jane reading john = %d %s with ETag %q", other.Code, other.Body, other.Header().Get("ETag"))
+	}
+	if own := serve(janeCtx, "/api/users/{id}", h.GetUser, http.MethodGet, "/api/users/"+jane.ID.Hex(), "", nil); !strings.Contains(own.Body.String(), jane.Email) {
+		t.Fatalf("jane reading herself = %s", own.Body)
+	}
+
+	// They cannot filter on what they cannot see, nor find others by email
+	for _, query := range []string{"role=admin", "isActive=false", "includeDeleted=true"} {
+		if resp := serve(janeCtx, "/api/users", h.GetAllUsers, http.MethodGet, "/api/users?"+query, "", nil); resp.Code != http.StatusForbidden {
+			t.Errorf("jane filtering on %s = %d, want 403", query, resp.Code)
+		}
+	}
+	if found := decodeList(t, serve(janeCtx, "/api/users", h.GetAllUsers, http.MethodGet, "/api/users?search=john@", "", nil)); len(found) != 0 {
+		t.Fatalf("jane found %v by email", found)
+	}
+
+	// Admins see everything, and the unpaginated list is theirs only
+	for _, user := range decodeList(t, serve(env.callerContext(admin), "/api/users", h.GetAllUsers, http.MethodGet, "/api/users?role=user", "", nil)) {
+		if user["email"] == nil || user["isActive"] == nil {
+			t.Errorf("admin sees %v", user)
+		}
+	}
+	if found := decodeList(t, serve(env.callerContext(admin), "/api/users", h.GetAllUsers, http.MethodGet, "/api/users?search=john@", "", nil)); len(found) != 1 {
+		t.Fatalf("admin searching by email found %v, want john", found)
+	}
+	all := middleware.RequireAdmin()(http.HandlerFunc(h.GetAllUsersWithoutLimit)).ServeHTTP
+	if resp := serve(janeCtx, "/api/users/all", all, http.MethodGet, "/api/users/all", "", nil); resp.Code != http.StatusForbidden {
+		t.Fatalf("jane listing /all = %d, want 403", resp.Code)
+	}
+	if resp := serve(env.callerContext(admin), "/api/users/all", all, http.MethodGet, "/api/users/all", "", nil); resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), john.Email) {
+		t.Fatalf("admin listing /all = %d %s", resp.Code, resp.Body)
+	}
+
+	// Without the route's guard the handler still only shows public profiles
+	if resp := serve(janeCtx, "/api/users/all", h.GetAllUsersWithoutLimit, http.MethodGet, "/api/users/all", "", nil); resp.Code != http.StatusOK || strings.Contains(resp.Body.String(), john.Email) || !strings.Contains(resp.Body.String(), jane.Email) {
+		t.Fatalf("jane listing /all unguarded = %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestUpdateOwnPasswordPointsToMe(t *testing.T) {
+	env := newTestEnv(t)
+	jane := env.createUser("jane@example.com", "user")
+	h := NewUserHandler(env.userService, nil)
+	target := "/api/users/" + jane.ID.Hex()
+
+	resp := serve(env.callerContext(jane), "/api/users/{id}", h.UpdateUser, http.MethodPut, target, `{"password":"stolen token password"}`, nil)
+	if resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), "POST /api/me/password") {
+		t.Fatalf("PUT own password = %d %s, want 400 pointing to /api/me", resp.Code, resp.Body)
+	}
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"user-management-system/models"
+	"user-management-system/services"
+	"user-management-system/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// WebhookHandler handles webhook subscription and delivery requests
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// CreateSubscription registers a new webhook endpoint
+func (h *WebhookHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	sub, secret, err := h.webhookService.CreateSubscription(r.Context(), &req)
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The secret is only ever returned here
+	response := map[string]interface{}{
+		"webhook": sub,
+		"secret":  secret,
+	}
+
+	utils.JSON(w, http.StatusCreated, utils.Response{
+		Success: true,
+		Message: "Webhook created successfully",
+		Data:    response,
+	})
+}
+
+// ListSubscriptions retrieves all webhook subscriptions
+func (h *WebhookHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	subs, err := h.webhookService.ListSubscriptions(r.Context())
+	if err != nil {
+		utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve webhooks")
+		return
+	}
+
+	utils.SuccessResponse(w, "Webhooks retrieved successfully", subs)
+}
+
+// GetSubscription retrieves a single webhook subscription
+func (h *WebhookHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	sub, err := h.webhookService.GetSubscription(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeWebhookError(w, err, "Failed to retrieve webhook")
+		return
+	}
+
+	utils.SuccessResponse(w, "Webhook retrieved successfully", sub)
+}
+
+// UpdateSubscription updates a webhook subscription
+func (h *WebhookHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	sub, err := h.webhookService.UpdateSubscription(r.Context(), mux.Vars(r)["id"], &req)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "not found") {
+			utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(w, "Webhook updated successfully", sub)
+}
+
+// DeleteSubscription removes a webhook subscription
+func (h *WebhookHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err := h.webhookService.DeleteSubscription(r.Context(), mux.Vars(r)["id"]); err != nil {
+		writeWebhookError(w, err, "Failed to delete webhook")
+		return
+	}
+
+	utils.SuccessResponse(w, "Webhook deleted successfully", nil)
+}
+
+// ListDeliveries retrieves the delivery log of a webhook subscription
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Get pagination parameters from query string
+	page := 1
+	limit := 20
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	status := r.URL.Query().Get("status")
+
+	deliveries, totalPages, total, err := h.webhookService.ListDeliveries(r.Context(), mux.Vars(r)["id"], status, page, limit)
+	if err != nil {
+		writeWebhookError(w, err, "Failed to retrieve deliveries")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(w, deliveries, page, limit, total, totalPages)
+}
+
+// GetDelivery retrieves a single delivery with its attempt log
+func (h *WebhookHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	delivery, err := h.webhookService.GetDelivery(r.Context(), vars["id"], vars["deliveryId"])
+	if err != nil {
+		writeWebhookError(w, err, "Failed to retrieve delivery")
+		return
+	}
+
+	utils.SuccessResponse(w, "Delivery retrieved successfully", delivery)
+}
+
+// Redeliver queues a delivery to be sent again
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	vars := mux.Vars(r)
+	delivery, err := h.webhookService.Redeliver(r.Context(), vars["id"], vars["deliveryId"])
+	if err != nil {
+		writeWebhookError(w, err, "Failed to queue redelivery")
+		return
+	}
+
+	utils.SuccessResponse(w, "Delivery queued for redelivery", delivery)
+}
+
+// writeWebhookError maps webhook service errors to HTTP responses
+func writeWebhookError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case strings.HasSuffix(err.Error(), "not found"):
+		utils.ErrorResponse(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "invalid"):
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(w, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,548 @@
+// Package ldaptest runs an in-memory LDAP directory on a local port, so the
+// LDAP authenticator can be tested without a directory server. It
+// answers simple binds, searches and StartTLS, with a self-signed certificate
+// for 127.0.0.1. Writes, referrals and extensible matches are not supported.
+package ldaptest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Protocol operations (RFC 4511 section 4.2 onwards), as application tags
+const (
+	opBindRequest      = 0
+	opBindResponse     = 1
+	opUnbindRequest    = 2
+	opSearchRequest    = 3
+	opSearchEntry      = 4
+	opSearchDone       = 5
+	opExtendedRequest  = 23
+	opExtendedResponse = 24
+)
+
+// startTLSOID names the StartTLS extended operation (RFC 4511 section 4.14)
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// Result codes the server returns
+const (
+	resultSuccess                 = 0
+	resultProtocolError           = 2
+	resultSizeLimitExceeded       = 4
+	resultAuthMethodNotSupported  = 7
+	resultConfidentialityRequired = 13
+	resultNoSuchObject            = 32
+	resultInvalidCredentials      = 49
+	resultUnwillingToPerform      = 53
+)
+
+// Entry is an entry of the directory
+type Entry struct {
+	DN         string
+	Password   string              // Password of simple binds as the entry; empty refuses them
+	Attributes map[string][]string // Attribute names are matched case-insensitively
+}
+
+// Server is an in-memory directory listening on 127.0.0.1
+type Server struct {
+	URL    string // ldap:// URL of the server
+	CAFile string // PEM file of the certificate StartTLS presents
+
+	// RequireTLS refuses binds and searches before StartTLS, as directories
+	// protecting passwords do
+	RequireTLS bool
+
+	listener  net.Listener
+	tlsConfig *tls.Config
+
+	mu      sync.Mutex
+	entries []*Entry
+	binds   []string
+}
+
+// NewServer starts a directory holding entries. It is closed when the test
+// ends.
+func NewServer(t testing.TB, entries ...*Entry) *Server {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ldaptest: %v", err)
+	}
+	certificate, caFile := newCertificate(t)
+
+	s := &Server{
+		URL:       "ldap://" + listener.Addr().String(),
+		CAFile:    caFile,
+		listener:  listener,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{certificate}},
+		entries:   entries,
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go s.serve()
+	return s
+}
+
+// Add adds entries to the directory
+func (s *Server) Add(entries ...*Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entries...)
+}
+
+// Binds returns the DNs of the successful binds so far, in order
+func (s *Server) Binds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+// newCertificate creates a self-signed certificate for 127.0.0.1 and writes
+// it to a PEM file
+func newCertificate(t testing.TB) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ldaptest: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldaptest"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("ldaptest: %v", err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ldaptest-ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("ldaptest: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
+
+// serve accepts connections until the listener is closed
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// session is the state of one connection
+type session struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tls    bool
+}
+
+// handle answers the requests of a connection until it is unbound or closed
+func (s *Server) handle(conn net.Conn) {
+	session := &session{conn: conn, reader: bufio.NewReader(conn)}
+	defer func() { session.conn.Close() }()
+
+	for {
+		id, op, err := readMessage(session.reader)
+		if err != nil {
+			return
+		}
+
+		switch op.Tag {
+		case opBindRequest:
+			code, message := s.bind(session, op)
+			session.reply(id, opBindResponse, result(code, message)...)
+		case opSearchRequest:
+			s.search(session, id, op)
+		case opExtendedRequest:
+			if !s.startTLS(session, id, op) {
+				return
+			}
+		default:
+			// Unbind or an operation the directory does not know
+			return
+		}
+	}
+}
+
+// bind runs a simple bind and returns its result code and message
+func (s *Server) bind(session *session, op asn1.RawValue) (int, string) {
+	fields := children(op)
+	if len(fields) < 3 {
+		return resultProtocolError, "malformed bind request"
+	}
+	dn, credentials := string(fields[1].Bytes), fields[2]
+	if credentials.Class != asn1.ClassContextSpecific || credentials.Tag != 0 {
+		return resultAuthMethodNotSupported, "only simple binds are supported"
+	}
+	password := string(credentials.Bytes)
+
+	if dn == "" && password == "" {
+		return resultSuccess, ""
+	}
+	if password == "" {
+		return resultUnwillingToPerform, "unauthenticated binds are not allowed"
+	}
+	if s.RequireTLS && !session.tls {
+		return resultConfidentialityRequired, "StartTLS is required"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.find(dn)
+	if entry == nil || entry.Password == "" || entry.Password != password {
+		return resultInvalidCredentials, "invalid credentials"
+	}
+	s.binds = append(s.binds, entry.DN)
+	return resultSuccess, ""
+}
+
+// search answers a search with the matching entries and its result
+func (s *Server) search(session *session, id int64, op asn1.RawValue) {
+	fields := children(op)
+	if len(fields) < 8 {
+		session.reply(id, opSearchDone, result(resultProtocolError, "malformed search request")...)
+		return
+	}
+	if s.RequireTLS && !session.tls {
+		session.reply(id, opSearchDone, result(resultConfidentialityRequired, "StartTLS is required")...)
+		return
+	}
+	baseDN := normalizeDN(string(fields[0].Bytes))
+	scope := integer(fields[1])
+	sizeLimit := integer(fields[3])
+	filter := fields[6]
+	var attributes []string
+	for _, attribute := range children(fields[7]) {
+		attributes = append(attributes, string(attribute.Bytes))
+	}
+
+	s.mu.Lock()
+	var found []*Entry
+	baseExists := false
+	for _, entry := range s.entries {
+		dn := normalizeDN(entry.DN)
+		if dn == baseDN || strings.HasSuffix(dn, ","+baseDN) || baseDN == "" {
+			baseExists = true
+		}
+		if inScope(dn, baseDN, scope) && matches(entry, filter) {
+			found = append(found, entry)
+		}
+	}
+	s.mu.Unlock()
+
+	if !baseExists {
+		session.reply(id, opSearchDone, result(resultNoSuchObject, "no such object")...)
+		return
+	}
+	for i, entry := range found {
+		if sizeLimit > 0 && int64(i) == sizeLimit {
+			session.reply(id, opSearchDone, result(resultSizeLimitExceeded, "size limit exceeded")...)
+			return
+		}
+		session.reply(id, opSearchEntry, searchEntry(entry, attributes)...)
+	}
+	session.reply(id, opSearchDone, result(resultSuccess, "")...)
+}
+
+// startTLS answers a StartTLS request and runs the TLS handshake. It returns
+// false when the connection cannot go on.
+func (s *Server) startTLS(session *session, id int64, op asn1.RawValue) bool {
+	fields := children(op)
+	if len(fields) < 1 || string(fields[0].Bytes) != startTLSOID {
+		session.reply(id, opExtendedResponse, result(resultProtocolError, "unknown extended operation")...)
+		return true
+	}
+	if session.tls {
+		session.reply(id, opExtendedResponse, result(resultProtocolError, "TLS is already running")...)
+		return true
+	}
+
+	session.reply(id, opExtendedResponse, result(resultSuccess, "")...)
+	tlsConn := tls.Server(session.conn, s.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return false
+	}
+	session.conn, session.reader, session.tls = tlsConn, bufio.NewReader(tlsConn), true
+	return true
+}
+
+// find returns the entry with the DN, or nil
+func (s *Server) find(dn string) *Entry {
+	for _, entry := range s.entries {
+		if normalizeDN(entry.DN) == normalizeDN(dn) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// inScope reports whether dn is within scope of the search base
+func inScope(dn, baseDN string, scope int64) bool {
+	switch scope {
+	case 0:
+		return dn == baseDN
+	case 1:
+		parent := ""
+		if i := strings.IndexByte(dn, ','); i >= 0 {
+			parent = dn[i+1:]
+		}
+		return parent == baseDN
+	default:
+		return dn == baseDN || baseDN == "" || strings.HasSuffix(dn, ","+baseDN)
+	}
+}
+
+// matches evaluates a search filter (RFC 4511 section 4.5.1.7) against an
+// entry. Values are compared case-insensitively; every entry has an
+// objectClass.
+func matches(entry *Entry, filter asn1.RawValue) bool {
+	if filter.Class != asn1.ClassContextSpecific {
+		return false
+	}
+	fields := children(filter)
+
+	switch filter.Tag {
+	case 0: // and
+		for _, child := range fields {
+			if !matches(entry, child) {
+				return false
+			}
+		}
+		return true
+	case 1: // or
+		for _, child := range fields {
+			if matches(entry, child) {
+				return true
+			}
+		}
+		return false
+	case 2: // not
+		return len(fields) == 1 && !matches(entry, fields[0])
+	case 3, 8: // equality and approximate match
+		if len(fields) != 2 {
+			return false
+		}
+		for _, value := range values(entry, string(fields[0].Bytes)) {
+			if strings.EqualFold(value, string(fields[1].Bytes)) {
+				return true
+			}
+		}
+		return false
+	case 4: // substrings
+		if len(fields) != 2 {
+			return false
+		}
+		for _, value := range values(entry, string(fields[0].Bytes)) {
+			if matchesSubstrings(strings.ToLower(value), children(fields[1])) {
+				return true
+			}
+		}
+		return false
+	case 7: // present
+		return strings.EqualFold(string(filter.Bytes), "objectClass") || len(values(entry, string(filter.Bytes))) > 0
+	default:
+		return false
+	}
+}
+
+// matchesSubstrings reports whether a lowercased value has the initial, any
+// and final parts of a substrings filter in order
+func matchesSubstrings(value string, parts []asn1.RawValue) bool {
+	for _, part := range parts {
+		s := strings.ToLower(string(part.Bytes))
+		switch part.Tag {
+		case 0:
+			if !strings.HasPrefix(value, s) {
+				return false
+			}
+			value = value[len(s):]
+		case 1:
+			i := strings.Index(value, s)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(s):]
+		case 2:
+			if !strings.HasSuffix(value, s) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// values returns the values of an entry's attribute, matched case-insensitively
+func values(entry *Entry, attribute string) []string {
+	for name, values := range entry.Attributes {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+	return nil
+}
+
+// searchEntry encodes a SearchResultEntry with the requested attributes: all
+// of them when none or * is requested, none for 1.1
+func searchEntry(entry *Entry, requested []string) []asn1.RawValue {
+	all := len(requested) == 0
+	for _, attribute := range requested {
+		if attribute == "*" {
+			all = true
+		}
+	}
+
+	var attributes []asn1.RawValue
+	for name, values := range entry.Attributes {
+		wanted := all
+		for _, attribute := range requested {
+			if strings.EqualFold(attribute, name) {
+				wanted = true
+			}
+		}
+		if !wanted {
+			continue
+		}
+
+		var set []asn1.RawValue
+		for _, value := range values {
+			set = append(set, octetString(value))
+		}
+		attributes = append(attributes, constructed(asn1.ClassUniversal, asn1.TagSequence,
+			octetString(name), constructed(asn1.ClassUniversal, asn1.TagSet, set...)))
+	}
+
+	return []asn1.RawValue{octetString(entry.DN), constructed(asn1.ClassUniversal, asn1.TagSequence, attributes...)}
+}
+
+// normalizeDN lowercases a DN and drops the spaces around its separators
+func normalizeDN(dn string) string {
+	parts := strings.Split(strings.ToLower(dn), ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return strings.Join(parts, ",")
+}
+
+// readMessage reads an LDAPMessage and returns its message ID and protocol
+// operation
+func readMessage(r *bufio.Reader) (int64, asn1.RawValue, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, asn1.RawValue{}, err
+	}
+	length := int(header[1])
+	if length&0x80 != 0 {
+		count := length & 0x7f
+		if count == 0 || count > 4 {
+			return 0, asn1.RawValue{}, errors.New("ldaptest: unsupported length encoding")
+		}
+		octets := make([]byte, count)
+		if _, err := io.ReadFull(r, octets); err != nil {
+			return 0, asn1.RawValue{}, err
+		}
+		header = append(header, octets...)
+		length = 0
+		for _, b := range octets {
+			length = length<<8 | int(b)
+		}
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return 0, asn1.RawValue{}, err
+	}
+
+	var message asn1.RawValue
+	if _, err := asn1.Unmarshal(append(header, content...), &message); err != nil {
+		return 0, asn1.RawValue{}, err
+	}
+	fields := children(message)
+	if len(fields) < 2 || fields[1].Class != asn1.ClassApplication {
+		return 0, asn1.RawValue{}, errors.New("ldaptest: malformed message")
+	}
+	return integer(fields[0]), fields[1], nil
+}
+
+// reply writes an LDAPMessage answering message id
+func (session *session) reply(id int64, op int, fields ...asn1.RawValue) {
+	idBytes, _ := asn1.Marshal(id)
+	message := constructed(asn1.ClassUniversal, asn1.TagSequence,
+		asn1.RawValue{FullBytes: idBytes}, constructed(asn1.ClassApplication, op, fields...))
+	encoded, _ := asn1.Marshal(message)
+	session.conn.Write(encoded)
+}
+
+// result encodes the fields of an LDAPResult
+func result(code int, message string) []asn1.RawValue {
+	codeBytes, _ := asn1.Marshal(asn1.Enumerated(code))
+	return []asn1.RawValue{{FullBytes: codeBytes}, octetString(""), octetString(message)}
+}
+
+// constructed returns a constructed element holding fields
+func constructed(class, tag int, fields ...asn1.RawValue) asn1.RawValue {
+	var content []byte
+	for _, field := range fields {
+		encoded, _ := asn1.Marshal(field)
+		content = append(content, encoded...)
+	}
+	return asn1.RawValue{Class: class, Tag: tag, IsCompound: true, Bytes: content}
+}
+
+// octetString returns an OCTET STRING element holding s
+func octetString(s string) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagOctetString, Bytes: []byte(s)}
+}
+
+// children parses the elements of a constructed element
+func children(value asn1.RawValue) []asn1.RawValue {
+	var fields []asn1.RawValue
+	for rest := value.Bytes; len(rest) > 0; {
+		var field asn1.RawValue
+		next, err := asn1.Unmarshal(rest, &field)
+		if err != nil {
+			return fields
+		}
+		fields = append(fields, field)
+		rest = next
+	}
+	return fields
+}
+
+// integer returns the value of an INTEGER or ENUMERATED element
+func integer(value asn1.RawValue) int64 {
+	var n int64
+	for i, b := range value.Bytes {
+		if i == 0 {
+			n = int64(int8(b))
+			continue
+		}
+		n = n<<8 | int64(b)
+	}
+	return n
+}
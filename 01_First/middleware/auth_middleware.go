@@ -43,6 +43,21 @@ func RequireAdmin() mux.MiddlewareFunc {
 	return RequireRole("admin")
 }
 
+// RequireSuperAdmin restricts routes that act on the whole deployment rather
+// than one organization
+func RequireSuperAdmin() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !IsSuperAdmin(r.Context()) {
+				utils.ErrorResponse(w, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // RequireAuth ensures user is authenticated (can be used after JWT middleware)
 func RequireAuth() mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
@@ -58,7 +73,9 @@ func RequireAuth() mux.MiddlewareFunc {
 	}
 }
 
-// CanModifyUser checks if user can modify another user (own account or admin)
+// CanModifyUser checks if user can modify another user (own account or admin).
+// The service also checks the target's roles, as admins may not modify
+// super-admins, and only owners modify owners and admins.
 func CanModifyUser() mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"user-management-system/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// GroupAuthorizer decides whether a user administers a group
+type GroupAuthorizer interface {
+	IsGroupAdmin(ctx context.Context, groupID, userID string) (bool, error)
+}
+
+// RequireGroupAdmin lets organization admins through, and users who are an
+// admin of the group in the {id} path variable or of one of its ancestors
+func RequireGroupAdmin(authorizer GroupAuthorizer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if GetRole(ctx) == "admin" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, err := authorizer.IsGroupAdmin(ctx, mux.Vars(r)["id"], GetUserID(ctx))
+			if err != nil {
+				log.Printf("⚠️  Failed to check group admin: %v", err)
+				utils.ErrorResponse(w, http.StatusInternalServerError, "Failed to check permissions")
+				return
+			}
+			if !allowed {
+				utils.ErrorResponse(w, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
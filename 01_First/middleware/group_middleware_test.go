@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// groupAdmins administers the groups listed for each user
+type groupAdmins map[string][]string
+
+func (a groupAdmins) IsGroupAdmin(ctx context.Context, groupID, userID string) (bool, error) {
+	if userID == "broken" {
+		return false, errors.New("database down")
+	}
+	for _, id := range a[userID] {
+		if id == groupID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestRequireGroupAdmin(t *testing.T) {
+	router := mux.NewRouter()
+	router.Handle("/api/groups/{id}/members", RequireGroupAdmin(groupAdmins{"lead": {"team"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	for _, test := range []struct {
+		userID, role, group string
+		want                int
+	}{
+		{"orgadmin", "admin", "team", http.StatusNoContent},
+		{"lead", "user", "team", http.StatusNoContent},
+		{"lead", "user", "other", http.StatusForbidden},
+		{"member", "user", "team", http.StatusForbidden},
+		{"broken", "user", "team", http.StatusInternalServerError},
+	} {
+		ctx := context.WithValue(context.Background(), UserIDKey, test.userID)
+		ctx = context.WithValue(ctx, RoleKey, test.role)
+		req := httptest.NewRequest(http.MethodPost, "/api/groups/"+test.group+"/members", nil).WithContext(ctx)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		if recorder.Code != test.want {
+			t.Errorf("%s on group %s = %d, want %d", test.userID, test.group, recorder.Code, test.want)
+		}
+	}
+}
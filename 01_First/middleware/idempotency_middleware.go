@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"user-management-system/models"
+	"user-management-system/utils"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client-chosen key of a retryable request
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader marks a response replayed from a previous request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodySize   = 1 << 20
+
+	// idempotencyLock is how long an in-progress request holds its key before
+	// it is considered abandoned
+	idempotencyLock = time.Minute
+)
+
+// IdempotencyStore persists Idempotency-Key reservations and responses
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, id, fingerprint string, lock, ttl time.Duration) (*models.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, id string, statusCode int, header map[string][]string, body []byte) error
+	Release(ctx context.Context, id string) error
+}
+
+// IdempotencyMiddleware makes requests carrying an Idempotency-Key safe to
+// retry. The first request runs and its response is stored for ttl; repeats
+// with the same key and body get the stored response, a repeat with a
+// different body gets 422, and a repeat while the first is still running gets
+// 409. Keys are scoped to the authenticated user, method and path. Requests
+// without the header are passed through.
+func IdempotencyMiddleware(store IdempotencyStore, ttl time.Duration) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				utils.ErrorResponse(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+			if err != nil {
+				utils.ErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+			if len(body) > maxIdempotentBodySize {
+				utils.ErrorResponse(w, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := r.Context()
+			id := hashParts(GetUserID(ctx), r.Method, r.URL.Path, key)
+			fingerprint := hashParts(r.Method, r.URL.Path, string(body))
+
+			record, reserved, err := store.Reserve(ctx, id, fingerprint, idempotencyLock, ttl)
+			if err != nil {
+				log.Printf("⚠️  Failed to reserve idempotency key: %v", err)
+				utils.ErrorResponse(w, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+
+			if !reserved {
+				switch {
+				case record.Fingerprint != fingerprint:
+					utils.ErrorResponse(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+				case record.Status != models.IdempotencyCompleted:
+					utils.ErrorResponse(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+				default:
+					replayResponse(w, record)
+				}
+				return
+			}
+
+			// Writes below must outlive a client that disconnects
+			storeCtx := context.WithoutCancel(ctx)
+
+			recorder := &idempotencyRecorder{ResponseWriter: w}
+			defer func() {
+				if p := recover(); p != nil {
+					store.Release(storeCtx, id)
+					panic(p)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+
+			// Server errors are not stored so the client can retry them
+			if recorder.status() >= http.StatusInternalServerError {
+				if err := store.Release(storeCtx, id); err != nil {
+					log.Printf("⚠️  Failed to release idempotency key: %v", err)
+				}
+				return
+			}
+
+			header := w.Header().Clone()
+			header.Del(RequestIDHeader)
+			if err := store.Complete(storeCtx, id, recorder.status(), header, recorder.body.Bytes()); err != nil {
+				log.Printf("⚠️  Failed to store idempotent response: %v", err)
+			}
+		})
+	}
+}
+
+// replayResponse writes a stored response
+func replayResponse(w http.ResponseWriter, record *models.IdempotencyRecord) {
+	for name, values := range record.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(record.Body)))
+	w.WriteHeader(record.StatusCode)
+	w.Write(record.Body)
+}
+
+// hashParts returns the hex SHA-256 of the NUL-separated parts
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyRecorder tees the response so it can be stored
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(code int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// status returns the response status, 200 when nothing was written
+func (rec *idempotencyRecorder) status() int {
+	if rec.statusCode == 0 {
+		return http.StatusOK
+	}
+	return rec.statusCode
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"user-management-system/mongotest"
+	"user-management-system/repositories"
+)
+
+// countingHandler answers with status and counts how often it runs
+type countingHandler struct {
+	calls  atomic.Int32
+	status int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := h.calls.Add(1)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(h.status)
+	w.Write([]byte(`{"call":` + strconv.Itoa(int(n)) + `}`))
+}
+
+func newIdempotentHandler(t *testing.T, next http.Handler) http.Handler {
+	store := repositories.NewIdempotencyRepository(mongotest.NewDatabase(t).Collection("idempotency_keys"))
+	return IdempotencyMiddleware(store, time.Hour)(next)
+}
+
+// post sends a POST to /api/auth/register as userID ("" for anonymous)
+func post(handler http.Handler, userID, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	if userID != "" {
+		req = req.WithContext(context.WithValue(req.Context(), UserIDKey, userID))
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	next := &countingHandler{status: http.StatusCreated}
+	handler := newIdempotentHandler(t, next)
+
+	first := post(handler, "", "key-1", `{"email":"jane@example.com"}`)
+	second := post(handler, "", "key-1", `{"email":"jane@example.com"}`)
+
+	if next.calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, want 1", next.calls.Load())
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() || second.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("replay = %d %q %v, want the first response %d %q", second.Code, second.Body, second.Header(), first.Code, first.Body)
+	}
+	if first.Header().Get(IdempotentReplayedHeader) != "" || second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("replayed headers: first %q, second %q", first.Header().Get(IdempotentReplayedHeader), second.Header().Get(IdempotentReplayedHeader))
+	}
+}
+
+func TestIdempotencyRejectsReusedKeyWithDifferentBody(t *testing.T) {
+	next := &countingHandler{status: http.StatusCreated}
+	handler := newIdempotentHandler(t, next)
+
+	post(handler, "", "key-1", `{"email":"jane@example.com"}`)
+	reused := post(handler, "", "key-1", `{"email":"john@example.com"}`)
+
+	if reused.Code != http.StatusUnprocessableEntity || next.calls.Load() != 1 {
+		t.Fatalf("reused key = %d after %d calls, want 422 after 1", reused.Code, next.calls.Load())
+	}
+}
+
+func TestIdempotencyRejectsConcurrentDuplicate(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := newIdempotentHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post(handler, "", "key-1", `{}`) }()
+	<-started
+
+	duplicate := post(handler, "", "key-1", `{}`)
+	close(release)
+	first := <-done
+
+	if duplicate.Code != http.StatusConflict || first.Code != http.StatusCreated {
+		t.Fatalf("in-flight duplicate = %d, first = %d; want 409 and 201", duplicate.Code, first.Code)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	next := &countingHandler{status: http.StatusInternalServerError}
+	handler := newIdempotentHandler(t, next)
+
+	post(handler, "", "key-1", `{}`)
+	next.status = http.StatusCreated
+	retried := post(handler, "", "key-1", `{}`)
+
+	if retried.Code != http.StatusCreated || next.calls.Load() != 2 {
+		t.Fatalf("retry after a 500 = %d after %d calls, want 201 after 2", retried.Code, next.calls.Load())
+	}
+}
+
+func TestIdempotencyKeysAreScoped(t *testing.T) {
+	next := &countingHandler{status: http.StatusCreated}
+	handler := newIdempotentHandler(t, next)
+
+	post(handler, "user-1", "key-1", `{}`)
+	post(handler, "user-2", "key-1", `{}`)
+	post(handler, "", "", `{}`)
+	post(handler, "", "", `{}`)
+
+	if next.calls.Load() != 4 {
+		t.Fatalf("handler ran %d times, want 4: keys are per user and requests without a key always run", next.calls.Load())
+	}
+
+	long := post(handler, "", strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`)
+	if long.Code != http.StatusBadRequest {
+		t.Fatalf("overlong key = %d, want 400", long.Code)
+	}
+}
+
+func TestIdempotencyTakesOverAbandonedRequest(t *testing.T) {
+	store := repositories.NewIdempotencyRepository(mongotest.NewDatabase(t).Collection("idempotency_keys"))
+	ctx := context.Background()
+
+	// The first request crashed while holding the key; its lock has expired
+	if _, reserved, err := store.Reserve(ctx, "id", "fingerprint", -time.Second, time.Hour); err != nil || !reserved {
+		t.Fatalf("first Reserve = %v, %v", reserved, err)
+	}
+	if _, reserved, err := store.Reserve(ctx, "id", "fingerprint", time.Minute, time.Hour); err != nil || !reserved {
+		t.Fatalf("Reserve of an abandoned key = %v, %v; want it taken over", reserved, err)
+	}
+	if _, reserved, err := store.Reserve(ctx, "id", "fingerprint", time.Minute, time.Hour); err != nil || reserved {
+		t.Fatalf("Reserve of a locked key = %v, %v; want it refused", reserved, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"user-management-system/config"
+	"user-management-system/tenant"
 	"user-management-system/utils"
 
 	"github.com/gorilla/mux"
@@ -15,12 +16,23 @@ import (
 type ContextKey string
 
 const (
-	UserIDKey ContextKey = "userId"
-	EmailKey  ContextKey = "email"
-	RoleKey   ContextKey = "role"
+	UserIDKey     ContextKey = "userId"
+	EmailKey      ContextKey = "email"
+	RoleKey       ContextKey = "role"
+	OrgRoleKey    ContextKey = "orgRole"
+	SuperAdminKey ContextKey = "superAdmin"
+	// ProvisionerKey marks trusted provisioners (the SCIM client and the admin
+	// CLI) that manage accounts on behalf of the platform
+	ProvisionerKey ContextKey = "provisioner"
 )
 
-// JWTMiddleware validates JWT tokens and extracts user information
+// OrgHeader lets super-admins act in another organization for one request,
+// or across all of them with "*"
+const OrgHeader = "X-Org-ID"
+
+// JWTMiddleware validates JWT tokens and extracts user information. The
+// request is scoped to the token's organization unless a super-admin names
+// another one in the X-Org-ID header.
 func JWTMiddleware(cfg *config.Config) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -42,22 +54,46 @@ func JWTMiddleware(cfg *config.Config) mux.MiddlewareFunc {
 
 			// Validate token
 			claims, err := utils.ValidateToken(tokenString, cfg)
-			if err != nil {
+			if err != nil || claims.OrgID == "" || len(claims.Audience) > 0 {
+				// Tokens issued before organizations existed carry no orgId, and
+				// tokens with an audience were issued to OAuth clients, not for this API
 				utils.ErrorResponse(w, http.StatusUnauthorized, "Invalid or expired token")
 				return
 			}
 
-			// Add user information to context
-			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
-			ctx = context.WithValue(ctx, EmailKey, claims.Email)
-			ctx = context.WithValue(ctx, RoleKey, claims.Role)
-
-			// Call next handler with updated context
-			next.ServeHTTP(w, r.WithContext(ctx))
+			serveAs(w, r, next, claims)
 		})
 	}
 }
 
+// serveAs calls next with the user information of claims in the request
+// context. The request is scoped to the claims' organization unless a
+// super-admin names another one in the X-Org-ID header.
+func serveAs(w http.ResponseWriter, r *http.Request, next http.Handler, claims *utils.JWTClaims) {
+	// Add user information to context
+	ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+	ctx = context.WithValue(ctx, EmailKey, claims.Email)
+	ctx = context.WithValue(ctx, RoleKey, claims.Role)
+	ctx = context.WithValue(ctx, OrgRoleKey, claims.OrgRole)
+	ctx = context.WithValue(ctx, SuperAdminKey, claims.SuperAdmin)
+
+	// Scope the request to the organization it acts in
+	switch orgID := r.Header.Get(OrgHeader); {
+	case orgID == "" || orgID == claims.OrgID:
+		ctx = tenant.WithOrg(ctx, claims.OrgID)
+	case !claims.SuperAdmin:
+		utils.ErrorResponse(w, http.StatusForbidden, "Only super-admins can act in another organization")
+		return
+	case orgID == "*":
+		ctx = tenant.AcrossOrgs(ctx)
+	default:
+		ctx = tenant.WithOrg(ctx, orgID)
+	}
+
+	// Call next handler with updated context
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // GetUserID extracts user ID from context
 func GetUserID(ctx context.Context) string {
 	if userID, ok := ctx.Value(UserIDKey).(string); ok {
@@ -74,6 +110,26 @@ func GetEmail(ctx context.Context) string {
 	return ""
 }
 
+// GetOrgRole extracts the organization role from context
+func GetOrgRole(ctx context.Context) string {
+	if role, ok := ctx.Value(OrgRoleKey).(string); ok {
+		return role
+	}
+	return ""
+}
+
+// IsSuperAdmin reports whether the authenticated user is a super-admin
+func IsSuperAdmin(ctx context.Context) bool {
+	superAdmin, _ := ctx.Value(SuperAdminKey).(bool)
+	return superAdmin
+}
+
+// IsProvisioner reports whether the request comes from a trusted provisioner
+func IsProvisioner(ctx context.Context) bool {
+	provisioner, _ := ctx.Value(ProvisionerKey).(bool)
+	return provisioner
+}
+
 // GetRole extracts role from context
 func GetRole(ctx context.Context) string {
 	if role, ok := ctx.Value(RoleKey).(string); ok {
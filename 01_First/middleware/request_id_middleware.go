@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	RequestIDKey ContextKey = "requestId"
+	ClientIPKey  ContextKey = "clientIp"
+	UserAgentKey ContextKey = "userAgent"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware assigns a request ID (reusing a valid incoming one) and
+// stores it with the client IP and user agent in the request context
+func RequestIDMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" || len(requestID) > 128 {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+
+			ctx := context.WithValue(r.Context(), RequestIDKey, requestID)
+			ctx = context.WithValue(ctx, ClientIPKey, ip)
+			ctx = context.WithValue(ctx, UserAgentKey, r.UserAgent())
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// newRequestID returns a random 16-byte hex request ID
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+// GetRequestID extracts the request ID from context
+func GetRequestID(ctx context.Context) string {
+	if requestID, ok := ctx.Value(RequestIDKey).(string); ok {
+		return requestID
+	}
+	return ""
+}
+
+// GetClientIP extracts the client IP from context
+func GetClientIP(ctx context.Context) string {
+	if ip, ok := ctx.Value(ClientIPKey).(string); ok {
+		return ip
+	}
+	return ""
+}
+
+// GetUserAgent extracts the user agent from context
+func GetUserAgent(ctx context.Context) string {
+	if userAgent, ok := ctx.Value(UserAgentKey).(string); ok {
+		return userAgent
+	}
+	return ""
+}
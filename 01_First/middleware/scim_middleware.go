@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"user-management-system/models"
+
+	"github.com/gorilla/mux"
+)
+
+// SCIMActorID identifies the identity provider as the actor in audit events
+const SCIMActorID = "scim"
+
+// SCIMAuthMiddleware authenticates the identity provider's SCIM client with a static bearer token
+func SCIMAuthMiddleware(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			provided := strings.TrimPrefix(authHeader, "Bearer ")
+
+			if token == "" || provided == authHeader || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+				w.Header().Set("Content-Type", "application/scim+json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(models.NewSCIMError(http.StatusUnauthorized, "", "invalid or missing bearer token"))
+				return
+			}
+
+			// Attribute provisioning changes to the IdP, which is trusted to
+			// grant and revoke the admin role it maps from its groups
+			ctx := context.WithValue(r.Context(), UserIDKey, SCIMActorID)
+			ctx = context.WithValue(ctx, ProvisionerKey, true)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKeyPrefix starts every API key, so leaked keys are easy to recognize
+const APIKeyPrefix = "umk_"
+
+// APIKeyResources are the route groups API keys can be scoped to. A scope is
+// "<resource>:read", which allows GET requests, or "<resource>:write", which
+// allows every method.
+var APIKeyResources = []string{"users", "groups", "orgs", "invitations", "jobs", "audit", "webhooks"}
+
+// IsValidAPIKeyScope reports whether scope names a known resource and access
+func IsValidAPIKeyScope(scope string) bool {
+	resource, access, ok := strings.Cut(scope, ":")
+	if !ok || (access != "read" && access != "write") {
+		return false
+	}
+	for _, known := range APIKeyResources {
+		if resource == known {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey lets a user or service account call the API without a password. It
+// acts with its owner's current role in one organization, limited to its
+// scopes. Only the SHA-256 of the key is stored; Prefix identifies it.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	OrgID      string             `bson:"orgId"`
+	UserID     string             `bson:"userId"` // Owner
+	Name       string             `bson:"name"`
+	Prefix     string             `bson:"prefix"`
+	KeyHash    string             `bson:"keyHash"`
+	Scopes     []string           `bson:"scopes"`
+	ExpiresAt  *time.Time         `bson:"expiresAt,omitempty"`
+	LastUsedAt *time.Time         `bson:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time         `bson:"revokedAt,omitempty"`
+
+	CreatedByID string    `bson:"createdById,omitempty"`
+	CreatedAt   time.Time `bson:"createdAt"`
+}
+
+// APIKeyResponse represents an API key as returned by the API, without the key
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	OrgID      string     `json:"orgId"`
+	UserID     string     `json:"userId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedBy  string     `json:"createdBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// CreatedAPIKeyResponse is returned once, when a key is created; the key
+// itself cannot be retrieved later
+type CreatedAPIKeyResponse struct {
+	*APIKeyResponse
+	Key string `json:"key"`
+}
+
+// ToAPIKeyResponse converts an APIKey to APIKeyResponse
+func (k *APIKey) ToAPIKeyResponse() *APIKeyResponse {
+	return &APIKeyResponse{
+		ID:         k.ID.Hex(),
+		OrgID:      k.OrgID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+		CreatedBy:  k.CreatedByID,
+		CreatedAt:  k.CreatedAt,
+	}
+}
+
+// Usable reports whether the key is neither revoked nor expired
+func (k *APIKey) Usable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || k.ExpiresAt.After(now))
+}
+
+// CreateAPIKeyRequest represents creating an API key. UserID names the owner
+// when an admin creates a key for a service account or another user; it
+// defaults to the caller. ExpiresInDays of 0 creates a key that never expires.
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1"`
+	ExpiresInDays int      `json:"expiresInDays" validate:"omitempty,min=0"`
+	UserID        string   `json:"userId" validate:"omitempty"`
+}
+
+// CreateServiceAccountRequest represents creating a service account: a user
+// that cannot sign in and only acts through its API keys
+type CreateServiceAccountRequest struct {
+	Name string `json:"name" validate:"required,min=2,max=100"`
+	Role string `json:"role" validate:"omitempty,oneof=admin member"` // Organization role
+}
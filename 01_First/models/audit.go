@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions recorded for user and auth mutations
+const (
+	AuditActionUserRegister   = "user.register"
+	AuditActionUserCreate     = "user.create"
+	AuditActionUserUpdate     = "user.update"
+	AuditActionUserDelete     = "user.delete"
+	AuditActionUserRestore    = "user.restore"
+	AuditActionUserImport     = "user.import"
+	AuditActionLoginSuccess   = "auth.login.success"
+	AuditActionLoginFailure   = "auth.login.failure"
+	AuditActionPasswordChange = "auth.password.change"
+	AuditActionPasswordSet    = "auth.password.set"
+
+	AuditActionEmailChangeRequest = "auth.email.request"
+	AuditActionEmailChange        = "auth.email.change"
+	AuditActionEmailRevert        = "auth.email.revert"
+
+	AuditActionInvitationCreate = "invitation.create"
+	AuditActionInvitationResend = "invitation.resend"
+	AuditActionInvitationRevoke = "invitation.revoke"
+	AuditActionInvitationAccept = "invitation.accept"
+
+	AuditActionOrgCreate       = "org.create"
+	AuditActionOrgUpdate       = "org.update"
+	AuditActionOrgMemberAdd    = "org.member.add"
+	AuditActionOrgMemberUpdate = "org.member.update"
+	AuditActionOrgMemberRemove = "org.member.remove"
+	AuditActionOrgSwitch       = "auth.org.switch"
+
+	AuditActionGroupCreate       = "group.create"
+	AuditActionGroupUpdate       = "group.update"
+	AuditActionGroupDelete       = "group.delete"
+	AuditActionGroupMemberAdd    = "group.member.add"
+	AuditActionGroupMemberRemove = "group.member.remove"
+
+	AuditActionAPIKeyCreate = "apikey.create"
+	AuditActionAPIKeyRevoke = "apikey.revoke"
+
+	AuditActionOAuthClientCreate = "oauth.client.create"
+	AuditActionOAuthClientUpdate = "oauth.client.update"
+	AuditActionOAuthClientDelete = "oauth.client.delete"
+	AuditActionOAuthAuthorize    = "oauth.authorize"
+	AuditActionOAuthCodeReplay   = "oauth.code.replay"
+	AuditActionOAuthRevoke       = "oauth.token.revoke"
+
+	AuditActionIdentityLink   = "auth.identity.link"
+	AuditActionIdentityUnlink = "auth.identity.unlink"
+)
+
+// AuditEvent represents an append-only record of a user or auth mutation
+type AuditEvent struct {
+	ID          primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	Action      string                 `json:"action" bson:"action"`
+	ActorID     string                 `json:"actorId,omitempty" bson:"actorId,omitempty"`
+	ActorEmail  string                 `json:"actorEmail,omitempty" bson:"actorEmail,omitempty"`
+	TargetID    string                 `json:"targetId,omitempty" bson:"targetId,omitempty"`
+	TargetEmail string                 `json:"targetEmail,omitempty" bson:"targetEmail,omitempty"`
+	OrgID       string                 `json:"orgId,omitempty" bson:"orgId,omitempty"` // Organization the action was taken in
+	Success     bool                   `json:"success" bson:"success"`
+	Reason      string                 `json:"reason,omitempty" bson:"reason,omitempty"`
+	Changes     map[string]AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+	IP          string                 `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent   string                 `json:"userAgent,omitempty" bson:"userAgent,omitempty"`
+	RequestID   string                 `json:"requestId,omitempty" bson:"requestId,omitempty"`
+	CreatedAt   time.Time              `json:"createdAt" bson:"createdAt"`
+}
+
+// AuditChange holds the before and after value of a changed field
+type AuditChange struct {
+	From interface{} `json:"from" bson:"from"`
+	To   interface{} `json:"to" bson:"to"`
+}
+
+// AuditFilter represents optional criteria for querying audit events
+type AuditFilter struct {
+	ActorID  string
+	TargetID string
+	Action   string
+	From     *time.Time
+	To       *time.Time
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LinkedIdentity is a user's account at an external OpenID Connect provider,
+// identified by the provider's subject
+type LinkedIdentity struct {
+	Provider string    `json:"provider" bson:"provider"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email,omitempty" bson:"email,omitempty"` // Email the provider reported when linking
+	LinkedAt time.Time `json:"linkedAt" bson:"linkedAt"`
+	OrgRole  string    `json:"orgRole,omitempty" bson:"orgRole,omitempty"` // Default organization role a directory last granted
+}
+
+// FederationState remembers a federated login started with a provider until
+// the user comes back with a code. It is stored by the SHA-256 of the state
+// parameter and removed by a TTL index.
+type FederationState struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	StateHash    string             `bson:"stateHash"`
+	Provider     string             `bson:"provider"`
+	Nonce        string             `bson:"nonce"`
+	CodeVerifier string             `bson:"codeVerifier"`
+	CreatedAt    time.Time          `bson:"createdAt"`
+	ExpiresAt    time.Time          `bson:"expiresAt"`
+}
+
+// FederationProviderResponse describes a provider users can sign in with
+type FederationProviderResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// FederationStartResponse is where to send the user to sign in with a
+// provider. The client keeps the state and checks it against the one the
+// provider returns before completing the login.
+type FederationStartResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+	State            string `json:"state"`
+	StateHash        string `json:"-"` // Kept in a cookie of the browser that started the login
+}
+
+// FederationCallbackRequest completes a federated login with the code and
+// state the provider returned
+type FederationCallbackRequest struct {
+	State string `json:"state" validate:"required"`
+	Code  string `json:"code" validate:"required"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Group roles. Group admins may manage the members of their group and of its subgroups.
+const (
+	GroupRoleAdmin  = "admin"
+	GroupRoleMember = "member"
+)
+
+// Group is a team or department within an organization. Groups nest: the
+// members of a subgroup are also members of its ancestors.
+type Group struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OrgID       string             `json:"orgId" bson:"orgId"`
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	ParentID    string             `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// GroupMember is a user's direct membership of a group
+type GroupMember struct {
+	ID        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	GroupID   string             `json:"groupId" bson:"groupId"`
+	UserID    string             `json:"userId" bson:"userId"`
+	OrgID     string             `json:"-" bson:"orgId"`
+	Role      string             `json:"role" bson:"role"`
+	AddedBy   string             `json:"addedBy,omitempty" bson:"addedBy,omitempty"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// GroupMemberResponse is a group member with their user details. Email and
+// isActive are left out for callers who only see public profiles.
+type GroupMemberResponse struct {
+	UserID    string    `json:"userId"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
+	IsActive  *bool     `json:"isActive,omitempty"`
+	CreatedAt time.Time `json:"createdAt"` // When the user joined the group
+}
+
+// UserGroupResponse is a group a user belongs to. Inherited groups are
+// ancestors of a group the user is a direct member of; Role is empty for them.
+type UserGroupResponse struct {
+	*Group
+	Role      string `json:"role,omitempty"`
+	Inherited bool   `json:"inherited"`
+}
+
+// GroupFilter represents optional criteria for listing groups
+type GroupFilter struct {
+	Search   string // Case-insensitive match on the name
+	ParentID string // Direct subgroups of this group; "root" for top-level groups
+}
+
+// CreateGroupRequest represents creating a group
+type CreateGroupRequest struct {
+	Name        string `json:"name" validate:"required,min=2,max=100"`
+	Description string `json:"description,omitempty" validate:"omitempty,max=500"`
+	ParentID    string `json:"parentId,omitempty"`
+}
+
+// UpdateGroupRequest represents updating a group. Omitted fields are left
+// unchanged; an empty parentId moves the group to the top level.
+type UpdateGroupRequest struct {
+	Name        *string `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=500"`
+	ParentID    *string `json:"parentId,omitempty"`
+}
+
+// GroupMembersRequest represents adding or removing users in bulk
+type GroupMembersRequest struct {
+	UserIDs []string `json:"userIds" validate:"required,min=1,max=500"`
+	Role    string   `json:"role,omitempty" validate:"omitempty,oneof=admin member"` // Only used when adding
+}
+
+// GroupMembersResult reports the outcome of a bulk membership change
+type GroupMembersResult struct {
+	Changed int                `json:"changed"`
+	Skipped int                `json:"skipped"` // Already members, or not members when removing
+	Errors  []GroupMemberError `json:"errors"`
+}
+
+// GroupMemberError reports a user a bulk membership change failed for
+type GroupMemberError struct {
+	UserID string `json:"userId"`
+	Error  string `json:"error"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Idempotency record states
+const (
+	IdempotencyInProgress = "in_progress"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyRecord stores the outcome of a request made with an Idempotency-Key
+// so a retry gets the original response instead of executing twice
+type IdempotencyRecord struct {
+	ID          string              `bson:"_id"` // Hash of the key scoped to actor, method and path
+	Fingerprint string              `bson:"fingerprint"`
+	Status      string              `bson:"status"`
+	StatusCode  int                 `bson:"statusCode,omitempty"`
+	Header      map[string][]string `bson:"header,omitempty"`
+	Body        []byte              `bson:"body,omitempty"`
+	LockedUntil time.Time           `bson:"lockedUntil"` // An in-progress record is abandoned after this
+	CreatedAt   time.Time           `bson:"createdAt"`
+	ExpiresAt   time.Time           `bson:"expiresAt"` // TTL index removes the record
+}
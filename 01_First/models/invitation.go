@@ -0,0 +1,110 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Invitation states. Expired is never stored: it is reported for pending
+// invitations whose ExpiresAt has passed.
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Invitation lets an admin bring a new user into their organization with a
+// chosen organization role. The invitee receives a single-use link; only the
+// SHA-256 of its token is stored.
+type Invitation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	OrgID     string             `bson:"orgId"`
+	Email     string             `bson:"email"`
+	Role      string             `bson:"role"` // Organization role: admin or member
+	TokenHash string             `bson:"tokenHash"`
+	Status    string             `bson:"status"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+
+	InvitedByID    string `bson:"invitedById,omitempty"`
+	InvitedByEmail string `bson:"invitedByEmail,omitempty"`
+
+	SendCount     int        `bson:"sendCount"`
+	LastSentAt    *time.Time `bson:"lastSentAt,omitempty"`
+	LastSendError string     `bson:"lastSendError,omitempty"`
+
+	AcceptedUserID string     `bson:"acceptedUserId,omitempty"`
+	AcceptedAt     *time.Time `bson:"acceptedAt,omitempty"`
+	RevokedAt      *time.Time `bson:"revokedAt,omitempty"`
+
+	CreatedAt time.Time `bson:"createdAt"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+// InvitationResponse represents an invitation as returned by the API
+type InvitationResponse struct {
+	ID             string     `json:"id"`
+	OrgID          string     `json:"orgId"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	Status         string     `json:"status"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	InvitedBy      string     `json:"invitedBy,omitempty"`
+	SendCount      int        `json:"sendCount"`
+	LastSentAt     *time.Time `json:"lastSentAt,omitempty"`
+	LastSendError  string     `json:"lastSendError,omitempty"`
+	AcceptedUserID string     `json:"acceptedUserId,omitempty"`
+	AcceptedAt     *time.Time `json:"acceptedAt,omitempty"`
+	RevokedAt      *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// CurrentStatus returns the status, reporting lapsed pending invitations as expired
+func (i *Invitation) CurrentStatus() string {
+	if i.Status == InvitationPending && !i.ExpiresAt.After(time.Now()) {
+		return InvitationExpired
+	}
+	return i.Status
+}
+
+// ToInvitationResponse converts an Invitation to InvitationResponse
+func (i *Invitation) ToInvitationResponse() *InvitationResponse {
+	return &InvitationResponse{
+		ID:             i.ID.Hex(),
+		OrgID:          i.OrgID,
+		Email:          i.Email,
+		Role:           i.Role,
+		Status:         i.CurrentStatus(),
+		ExpiresAt:      i.ExpiresAt,
+		InvitedBy:      i.InvitedByEmail,
+		SendCount:      i.SendCount,
+		LastSentAt:     i.LastSentAt,
+		LastSendError:  i.LastSendError,
+		AcceptedUserID: i.AcceptedUserID,
+		AcceptedAt:     i.AcceptedAt,
+		RevokedAt:      i.RevokedAt,
+		CreatedAt:      i.CreatedAt,
+		UpdatedAt:      i.UpdatedAt,
+	}
+}
+
+// InvitationFilter represents optional criteria for listing invitations
+type InvitationFilter struct {
+	Email  string
+	Status string // pending, accepted, revoked or expired
+}
+
+// CreateInvitationRequest represents an admin inviting a new user
+type CreateInvitationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role,omitempty" validate:"omitempty,oneof=admin member"`
+}
+
+// AcceptInvitationRequest represents an invitee accepting with the token from their link
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Name     string `json:"name" validate:"required,min=2"`
+	Password string `json:"password" validate:"required,min=6"`
+}
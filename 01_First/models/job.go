@@ -0,0 +1,164 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Background job types
+const (
+	JobTypeUserImport     = "user.import"
+	JobTypeUserExport     = "user.export"
+	JobTypeUserDeactivate = "user.deactivate"
+)
+
+// Job states. Queued and running jobs are active; the others are final.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job is a long-running operation executed by the background job runner.
+// A runner owns a job while its lease (LeaseID, LockedUntil) is fresh; a job
+// whose lease expires, e.g. because its replica died, is picked up again.
+type Job struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	Type   string             `bson:"type"`
+	Status string             `bson:"status"`
+	Params string             `bson:"params,omitempty"` // JSON-encoded job parameters
+
+	InputFileID *primitive.ObjectID `bson:"inputFileId,omitempty"` // Uploaded input kept in GridFS
+
+	Progress   JobProgress `bson:"progress"`
+	Checkpoint string      `bson:"checkpoint,omitempty"` // JSON state a resumed run continues from
+	Result     string      `bson:"result,omitempty"`     // JSON-encoded result summary
+	Error      string      `bson:"error,omitempty"`
+
+	ResultFileID          *primitive.ObjectID `bson:"resultFileId,omitempty"`
+	ResultFileName        string              `bson:"resultFileName,omitempty"`
+	ResultFileContentType string              `bson:"resultFileContentType,omitempty"`
+
+	CancelRequested bool       `bson:"cancelRequested"`
+	Attempts        int        `bson:"attempts"`
+	LeaseID         string     `bson:"leaseId,omitempty"`
+	LockedUntil     *time.Time `bson:"lockedUntil,omitempty"`
+	HeartbeatAt     *time.Time `bson:"heartbeatAt,omitempty"`
+
+	// Who started the job, and in which organization; the runner acts on
+	// their behalf. An empty OrgID is a super-admin acting across organizations.
+	OrgID               string `bson:"orgId,omitempty"`
+	CreatedByID         string `bson:"createdById,omitempty"`
+	CreatedByEmail      string `bson:"createdByEmail,omitempty"`
+	CreatedBySuperAdmin bool   `bson:"createdBySuperAdmin,omitempty"`
+	RequestID           string `bson:"requestId,omitempty"`
+
+	CreatedAt  time.Time  `bson:"createdAt"`
+	StartedAt  *time.Time `bson:"startedAt,omitempty"`
+	FinishedAt *time.Time `bson:"finishedAt,omitempty"`
+	UpdatedAt  time.Time  `bson:"updatedAt"`
+}
+
+// JobProgress reports how far a job has got. Total is 0 when unknown.
+type JobProgress struct {
+	Processed int64 `json:"processed" bson:"processed"`
+	Total     int64 `json:"total" bson:"total"`
+}
+
+// JobResponse is the job representation returned by the API
+type JobResponse struct {
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	Status          string          `json:"status"`
+	Progress        JobProgress     `json:"progress"`
+	Result          json.RawMessage `json:"result,omitempty"`
+	ResultURL       string          `json:"resultUrl,omitempty"` // Download link for a result file
+	Error           string          `json:"error,omitempty"`
+	CancelRequested bool            `json:"cancelRequested"`
+	Attempts        int             `json:"attempts"`
+	CreatedBy       string          `json:"createdBy,omitempty"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	StartedAt       *time.Time      `json:"startedAt,omitempty"`
+	HeartbeatAt     *time.Time      `json:"heartbeatAt,omitempty"`
+	FinishedAt      *time.Time      `json:"finishedAt,omitempty"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
+}
+
+// JobFilter represents optional criteria for listing jobs
+type JobFilter struct {
+	Type   string
+	Status string
+}
+
+// IsFinished reports whether the job has reached a final state
+func (j *Job) IsFinished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// ToJobResponse converts a Job to JobResponse
+func (j *Job) ToJobResponse() *JobResponse {
+	response := &JobResponse{
+		ID:              j.ID.Hex(),
+		Type:            j.Type,
+		Status:          j.Status,
+		Progress:        j.Progress,
+		Error:           j.Error,
+		CancelRequested: j.CancelRequested,
+		Attempts:        j.Attempts,
+		CreatedBy:       j.CreatedByEmail,
+		CreatedAt:       j.CreatedAt,
+		StartedAt:       j.StartedAt,
+		HeartbeatAt:     j.HeartbeatAt,
+		FinishedAt:      j.FinishedAt,
+		UpdatedAt:       j.UpdatedAt,
+	}
+	if j.Result != "" {
+		response.Result = json.RawMessage(j.Result)
+	}
+	if j.ResultFileID != nil && j.Status == JobSucceeded {
+		response.ResultURL = "/api/jobs/" + j.ID.Hex() + "/result"
+	}
+	return response
+}
+
+// UserExportJobParams are the parameters of a user.export job
+type UserExportJobParams struct {
+	Format         string `json:"format"`
+	Search         string `json:"search,omitempty"`
+	Role           string `json:"role,omitempty"`
+	IsActive       *bool  `json:"isActive,omitempty"`
+	IncludeDeleted bool   `json:"includeDeleted,omitempty"`
+}
+
+// DeactivateUsersRequest selects the users a user.deactivate job deactivates.
+// At least one criterion is required; criteria are combined with AND.
+type DeactivateUsersRequest struct {
+	IDs    []string `json:"ids,omitempty"`
+	Search string   `json:"search,omitempty"`
+	Role   string   `json:"role,omitempty" validate:"omitempty,oneof=user admin"`
+}
+
+// DeactivateUsersResult summarizes a finished user.deactivate job
+type DeactivateUsersResult struct {
+	Deactivated int                   `json:"deactivated"`
+	Skipped     int                   `json:"skipped"` // The requesting admin's own account
+	Failed      int                   `json:"failed"`
+	Errors      []DeactivateUserError `json:"errors"`
+}
+
+// DeactivateUserError reports a user a user.deactivate job could not deactivate
+type DeactivateUserError struct {
+	UserID string `json:"userId"`
+	Email  string `json:"email,omitempty"`
+	Error  string `json:"error"`
+}
+
+// UserExportResult summarizes a finished user.export job
+type UserExportResult struct {
+	Format string `json:"format"`
+	Count  int    `json:"count"`
+}
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OAuth 2.0 grant types clients may be registered for
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// OAuth token kinds stored in the oauth_tokens collection
+const (
+	OAuthTokenCode          = "code"
+	OAuthTokenRefresh       = "refresh"
+	OAuthTokenRevokedAccess = "revoked_access"
+	OAuthTokenRevokedGrant  = "revoked_grant"
+)
+
+// OAuth 2.0 error codes (RFC 6749 sections 4.1.2.1 and 5.2)
+const (
+	OAuthErrInvalidRequest          = "invalid_request"
+	OAuthErrInvalidClient           = "invalid_client"
+	OAuthErrInvalidGrant            = "invalid_grant"
+	OAuthErrUnauthorizedClient      = "unauthorized_client"
+	OAuthErrUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthErrUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrInvalidScope            = "invalid_scope"
+	OAuthErrAccessDenied            = "access_denied"
+	OAuthErrServerError             = "server_error"
+
+	// Bearer token errors of the userinfo endpoint (RFC 6750 section 3.1)
+	OAuthErrInvalidToken      = "invalid_token"
+	OAuthErrInsufficientScope = "insufficient_scope"
+)
+
+// OAuthClient is an application registered to sign users in through this
+// service. Confidential clients authenticate with a secret, of which only the
+// SHA-256 is stored; public clients, such as SPAs and native apps, have none
+// and must use PKCE.
+type OAuthClient struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty"`
+	ClientID     string             `bson:"clientId"`
+	SecretHash   string             `bson:"secretHash,omitempty"`
+	Name         string             `bson:"name"`
+	Public       bool               `bson:"public"`
+	RedirectURIs []string           `bson:"redirectUris"`
+	GrantTypes   []string           `bson:"grantTypes"`
+	Scopes       []string           `bson:"scopes"` // Scopes the client may request
+
+	// PostLogoutRedirectURIs are where /oauth/logout may send users back to
+	PostLogoutRedirectURIs []string `bson:"postLogoutRedirectUris,omitempty"`
+
+	CreatedByID string    `bson:"createdById,omitempty"`
+	CreatedAt   time.Time `bson:"createdAt"`
+	UpdatedAt   time.Time `bson:"updatedAt"`
+}
+
+// AllowsGrant reports whether the client is registered for the grant type
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	for _, allowed := range c.GrantTypes {
+		if allowed == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRedirectURI reports whether uri exactly matches a registered redirect URI
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if allowed == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsPostLogoutRedirectURI reports whether uri exactly matches a
+// registered post-logout redirect URI
+func (c *OAuthClient) AllowsPostLogoutRedirectURI(uri string) bool {
+	for _, allowed := range c.PostLogoutRedirectURIs {
+		if allowed == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthClientResponse represents a client as returned by the API, without the secret
+type OAuthClientResponse struct {
+	ID           string   `json:"id"`
+	ClientID     string   `json:"clientId"`
+	Name         string   `json:"name"`
+	Public       bool     `json:"public"`
+	RedirectURIs []string `json:"redirectUris"`
+	GrantTypes   []string `json:"grantTypes"`
+	Scopes       []string `json:"scopes"`
+
+	PostLogoutRedirectURIs []string `json:"postLogoutRedirectUris,omitempty"`
+
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// CreatedOAuthClientResponse is returned once, when a client is created; the
+// secret of a confidential client cannot be retrieved later
+type CreatedOAuthClientResponse struct {
+	*OAuthClientResponse
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+// ToOAuthClientResponse converts an OAuthClient to OAuthClientResponse
+func (c *OAuthClient) ToOAuthClientResponse() *OAuthClientResponse {
+	return &OAuthClientResponse{
+		ID:           c.ID.Hex(),
+		ClientID:     c.ClientID,
+		Name:         c.Name,
+		Public:       c.Public,
+		RedirectURIs: c.RedirectURIs,
+		GrantTypes:   c.GrantTypes,
+		Scopes:       c.Scopes,
+
+		PostLogoutRedirectURIs: c.PostLogoutRedirectURIs,
+
+		CreatedBy: c.CreatedByID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+// OAuthClientRequest represents registering or updating an OAuth client.
+// Public cannot be changed once the client exists.
+type OAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	Public       bool     `json:"public"`
+	RedirectURIs []string `json:"redirectUris"`
+	GrantTypes   []string `json:"grantTypes" validate:"required,min=1"`
+	Scopes       []string `json:"scopes"`
+
+	PostLogoutRedirectURIs []string `json:"postLogoutRedirectUris"`
+}
+
+// OAuthToken is an authorization code or refresh token, stored by the
+// SHA-256 of its value, or the ID of a revoked access token or grant. The TTL
+// index removes it once it expires.
+type OAuthToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Kind      string             `bson:"kind"`
+	TokenHash string             `bson:"tokenHash"` // jti for revoked access tokens
+	ClientID  string             `bson:"clientId"`
+	UserID    string             `bson:"userId,omitempty"`
+	OrgID     string             `bson:"orgId,omitempty"`
+	Scope     string             `bson:"scope,omitempty"`
+
+	// GrantID is the ID of the authorization code a refresh token descends
+	// from, so replaying the code revokes every token issued from it
+	GrantID string `bson:"grantId,omitempty"`
+
+	// Authorization codes only: where the code was sent, the PKCE challenge
+	// and when the code was redeemed. Redeemed codes are kept until they
+	// expire to detect replays.
+	RedirectURI         string     `bson:"redirectUri,omitempty"`
+	CodeChallenge       string     `bson:"codeChallenge,omitempty"`
+	CodeChallengeMethod string     `bson:"codeChallengeMethod,omitempty"`
+	UsedAt              *time.Time `bson:"usedAt,omitempty"`
+
+	// OpenID Connect: the nonce to put in the ID token (codes only) and when
+	// the user signed in
+	Nonce    string     `bson:"nonce,omitempty"`
+	AuthTime *time.Time `bson:"authTime,omitempty"`
+
+	CreatedAt time.Time `bson:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// OAuthTokenResponse is the token endpoint's success response (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"` // When the openid scope was granted
+}
+
+// OAuthIntrospection is the introspection endpoint's response (RFC 7662
+// section 2.2). Inactive tokens only report active: false.
+type OAuthIntrospection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+	OrgID     string   `json:"org_id,omitempty"`
+	Role      string   `json:"role,omitempty"`
+}
+
+// OAuthError is an OAuth 2.0 error response; it doubles as a Go error
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// JoinScopes and SplitScopes convert between scope lists and the
+// space-delimited scope parameter
+func JoinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// SplitScopes splits a space-delimited scope parameter
+func SplitScopes(scope string) []string {
+	return strings.Fields(scope)
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Organization roles, from most to least privileged
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// DefaultOrgSlug identifies the organization that self-registered and
+// SCIM-provisioned users join, and that pre-existing users were moved into
+const DefaultOrgSlug = "default"
+
+// Organization is a tenant. Users only see the users of the organization
+// they are acting in.
+type Organization struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	Slug      string             `json:"slug" bson:"slug"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// OrganizationResponse is an organization with the caller's role in it
+type OrganizationResponse struct {
+	*Organization
+	Role string `json:"role,omitempty"` // Empty for super-admins who are not a member
+}
+
+// OrgMembership is a user's role in one organization
+type OrgMembership struct {
+	OrgID string `json:"orgId" bson:"orgId"`
+	Role  string `json:"role" bson:"role"`
+}
+
+// IsValidOrgRole reports whether role is an organization role
+func IsValidOrgRole(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin || role == OrgRoleMember
+}
+
+// CreateOrganizationRequest represents a super-admin creating an organization
+type CreateOrganizationRequest struct {
+	Name       string `json:"name" validate:"required,min=2,max=100"`
+	Slug       string `json:"slug,omitempty" validate:"omitempty,min=2,max=50"`
+	OwnerEmail string `json:"ownerEmail,omitempty" validate:"omitempty,email"` // Existing user who becomes the owner
+}
+
+// UpdateOrganizationRequest represents renaming an organization
+type UpdateOrganizationRequest struct {
+	Name string `json:"name" validate:"required,min=2,max=100"`
+}
+
+// AddMemberRequest represents adding an existing user to an organization
+type AddMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role,omitempty" validate:"omitempty,oneof=owner admin member"`
+}
+
+// UpdateMemberRequest represents changing a member's organization role
+type UpdateMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// SwitchOrgRequest represents exchanging a token for one scoped to another organization
+type SwitchOrgRequest struct {
+	OrgID string `json:"orgId" validate:"required"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "strings"
+
+// Password policy violation codes
+const (
+	PasswordViolationTooShort         = "too_short"
+	PasswordViolationTooLong          = "too_long"
+	PasswordViolationMissingUppercase = "missing_uppercase"
+	PasswordViolationMissingLowercase = "missing_lowercase"
+	PasswordViolationMissingDigit     = "missing_digit"
+	PasswordViolationMissingSymbol    = "missing_symbol"
+	PasswordViolationContainsName     = "contains_name"
+	PasswordViolationContainsEmail    = "contains_email"
+	PasswordViolationBreached         = "breached"
+	PasswordViolationReused           = "reused"
+)
+
+// PasswordViolation is one reason a password was rejected
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every reason a password was rejected; it doubles as a Go error
+type PasswordPolicyError struct {
+	Violations []PasswordViolation `json:"violations"`
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Message
+	}
+	return strings.Join(messages, "; ")
+}
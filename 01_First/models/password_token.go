@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Password token purposes
+const (
+	PasswordTokenSet         = "password_set"
+	PasswordTokenEmailChange = "email_change"
+	PasswordTokenEmailRevert = "email_revert"
+)
+
+// PasswordToken is a single-use token mailed to a user, to set a password or
+// to confirm or revert an email change. Only the SHA-256 of the token is stored.
+type PasswordToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"tokenHash"`
+	UserID    string             `bson:"userId"`
+	Purpose   string             `bson:"purpose"`
+	Email     string             `bson:"email,omitempty"` // New address to confirm, or old address to revert to
+	CreatedAt time.Time          `bson:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt"` // TTL index removes the token
+}
+
+// SetPasswordRequest represents setting a password with a password-set token
+type SetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
+
+// EmailTokenRequest represents confirming or reverting an email change with
+// the token from the mailed link
+type EmailTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// SCIM 2.0 schema URNs
+const (
+	SCIMSchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMSchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMSchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMSchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCIMSchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCIMSchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SCIMSchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// SCIMUser is the SCIM 2.0 representation of a User
+type SCIMUser struct {
+	Schemas     []string       `json:"schemas"`
+	ID          string         `json:"id,omitempty"`
+	ExternalID  string         `json:"externalId,omitempty"`
+	UserName    string         `json:"userName"`
+	Name        *SCIMName      `json:"name,omitempty"`
+	DisplayName string         `json:"displayName,omitempty"`
+	Emails      []SCIMMultiVal `json:"emails,omitempty"`
+	Active      *bool          `json:"active,omitempty"`
+	Roles       []SCIMMultiVal `json:"roles,omitempty"`
+	Password    string         `json:"password,omitempty"`
+	Meta        *SCIMMeta      `json:"meta,omitempty"`
+}
+
+// SCIMName holds the SCIM name complex attribute
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// SCIMMultiVal is a SCIM multi-valued attribute entry (emails, roles)
+type SCIMMultiVal struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMMeta holds SCIM resource metadata
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+	Version      string    `json:"version,omitempty"`
+}
+
+// SCIMListResponse is a SCIM list response
+type SCIMListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// SCIMPatchRequest is a SCIM PATCH request body
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// SCIMPatchOperation is a single SCIM PATCH operation
+type SCIMPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// SCIMError is a SCIM error response; it doubles as a Go error
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// NewSCIMError creates a SCIM error with the given HTTP status
+func NewSCIMError(status int, scimType, detail string) *SCIMError {
+	return &SCIMError{
+		Schemas:  []string{SCIMSchemaError},
+		Status:   fmt.Sprintf("%d", status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+func (e *SCIMError) Error() string {
+	return e.Detail
+}
+
+// ToSCIMUser converts User to its SCIM representation; baseURL is the /scim/v2 URL
+func (u *User) ToSCIMUser(baseURL string) *SCIMUser {
+	active := u.IsActive
+	return &SCIMUser{
+		Schemas:     []string{SCIMSchemaUser},
+		ID:          u.ID.Hex(),
+		UserName:    u.Email,
+		Name:        &SCIMName{Formatted: u.Name},
+		DisplayName: u.Name,
+		Emails:      []SCIMMultiVal{{Value: u.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Roles:       []SCIMMultiVal{{Value: u.Role, Primary: true}},
+		Meta: &SCIMMeta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			LastModified: u.UpdatedAt,
+			Version:      fmt.Sprintf(`W/"%d"`, u.Version),
+			Location:     baseURL + "/Users/" + u.ID.Hex(),
+		},
+	}
+}
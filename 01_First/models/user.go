@@ -9,13 +9,46 @@ import (
 // User represents a user in the system
 type User struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string            `json:"name" bson:"name"`
-	Email     string            `json:"email" bson:"email"`
-	Password  string            `json:"-" bson:"password"` // Never return password in JSON
-	Role      string            `json:"role" bson:"role"`
-	IsActive  bool              `json:"isActive" bson:"isActive"`
-	CreatedAt time.Time         `json:"createdAt" bson:"createdAt"`
-	UpdatedAt time.Time         `json:"updatedAt" bson:"updatedAt"`
+	Name      string             `json:"name" bson:"name"`
+	Email     string             `json:"email" bson:"email"`
+	Password  string             `json:"-" bson:"password"` // Never return password in JSON
+	Role      string             `json:"role" bson:"role"`  // Platform role; admins are super-admins
+	IsActive  bool               `json:"isActive" bson:"isActive"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+
+	// OrgID is the home organization, which owns the account. Orgs lists every
+	// organization the user belongs to, the home organization included.
+	OrgID string          `json:"orgId" bson:"orgId"`
+	Orgs  []OrgMembership `json:"orgs" bson:"orgs"`
+
+	// ServiceAccount marks a user that cannot sign in and only acts through API keys
+	ServiceAccount bool `json:"serviceAccount,omitempty" bson:"serviceAccount,omitempty"`
+
+	// MustChangePassword blocks login until the user sets a new password
+	MustChangePassword bool `json:"mustChangePassword,omitempty" bson:"mustChangePassword,omitempty"`
+
+	// PasswordChangedAt is when the password was last set; passwords expire from it
+	PasswordChangedAt *time.Time `json:"passwordChangedAt,omitempty" bson:"passwordChangedAt,omitempty"`
+
+	// PasswordHistory holds the hashes of previous passwords, newest first, so they are not reused
+	PasswordHistory []string `json:"-" bson:"passwordHistory,omitempty"`
+
+	// RandomPassword marks a generated password that was never handed out, as
+	// for users an identity provider provisioned; it is cleared once a password is set
+	RandomPassword bool `json:"-" bson:"randomPassword,omitempty"`
+
+	// PendingEmail is a requested new email, applied once the address is confirmed
+	PendingEmail string `json:"pendingEmail,omitempty" bson:"pendingEmail,omitempty"`
+
+	// Identities are the accounts at external identity providers the user signs in with
+	Identities []LinkedIdentity `json:"identities,omitempty" bson:"identities,omitempty"`
+
+	// DeletedAt marks a soft-deleted user; it is purged after the retention period
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+
+	// Version is incremented on every write and backs ETag / If-Match
+	Version int64 `json:"version" bson:"version"`
 }
 
 // UserResponse represents a user without sensitive information
@@ -27,19 +60,114 @@ type UserResponse struct {
 	IsActive  bool      `json:"isActive"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+
+	OrgID string          `json:"orgId"`
+	Orgs  []OrgMembership `json:"orgs"`
+
+	ServiceAccount     bool             `json:"serviceAccount,omitempty"`
+	MustChangePassword bool             `json:"mustChangePassword,omitempty"`
+	PasswordChangedAt  *time.Time       `json:"passwordChangedAt,omitempty"`
+	PendingEmail       string           `json:"pendingEmail,omitempty"`
+	Identities         []LinkedIdentity `json:"identities,omitempty"`
+	DeletedAt          *time.Time       `json:"deletedAt,omitempty"`
+	Version            int64            `json:"version"`
 }
 
 // ToUserResponse converts User to UserResponse (excludes password)
 func (u *User) ToUserResponse() *UserResponse {
 	return &UserResponse{
-		ID:        u.ID.Hex(),
-		Name:      u.Name,
-		Email:     u.Email,
-		Role:      u.Role,
-		IsActive:  u.IsActive,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+		ID:                 u.ID.Hex(),
+		Name:               u.Name,
+		Email:              u.Email,
+		Role:               u.Role,
+		IsActive:           u.IsActive,
+		CreatedAt:          u.CreatedAt,
+		UpdatedAt:          u.UpdatedAt,
+		OrgID:              u.OrgID,
+		Orgs:               u.Orgs,
+		ServiceAccount:     u.ServiceAccount,
+		MustChangePassword: u.MustChangePassword,
+		PasswordChangedAt:  u.PasswordChangedAt,
+		PendingEmail:       u.PendingEmail,
+		Identities:         u.Identities,
+		DeletedAt:          u.DeletedAt,
+		Version:            u.Version,
+	}
+}
+
+// PublicUserResponse is the reduced profile of another user shown to non-admins
+type PublicUserResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Project returns what a viewer may see of the user: the full response when
+// full is set or the viewer is the user, the public profile otherwise
+func (u *UserResponse) Project(viewerID string, full bool) interface{} {
+	if full || u.ID == viewerID {
+		return u
+	}
+	return &PublicUserResponse{ID: u.ID, Name: u.Name}
+}
+
+// ProjectUsers applies Project to every user of a list
+func ProjectUsers(users []*UserResponse, viewerID string, full bool) []interface{} {
+	views := make([]interface{}, len(users))
+	for i, user := range users {
+		views[i] = user.Project(viewerID, full)
+	}
+	return views
+}
+
+// IsSuperAdmin reports whether the user may act in and across every organization
+func (u *User) IsSuperAdmin() bool {
+	return u.Role == "admin"
+}
+
+// HasAdminRole reports whether the user is a super-admin or an owner or admin of any organization
+func (u *User) HasAdminRole() bool {
+	if u.IsSuperAdmin() {
+		return true
+	}
+	for _, membership := range u.Orgs {
+		if membership.Role == OrgRoleOwner || membership.Role == OrgRoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// OrgRole returns the user's role in the organization, or "" when they are not a member
+func (u *User) OrgRole(orgID string) string {
+	for _, membership := range u.Orgs {
+		if membership.OrgID == orgID {
+			return membership.Role
+		}
 	}
+	return ""
+}
+
+// Identity returns the user's linked identity at a provider, or nil
+func (u *User) Identity(provider string) *LinkedIdentity {
+	for i := range u.Identities {
+		if u.Identities[i].Provider == provider {
+			return &u.Identities[i]
+		}
+	}
+	return nil
+}
+
+// EffectiveRole returns the user's role when acting in the organization:
+// admin for super-admins and organization owners and admins, user otherwise
+func (u *User) EffectiveRole(orgID string) string {
+	switch u.OrgRole(orgID) {
+	case OrgRoleOwner, OrgRoleAdmin:
+		return "admin"
+	}
+	if u.IsSuperAdmin() {
+		return "admin"
+	}
+	return "user"
 }
 
 // RegisterRequest represents user registration input
@@ -55,6 +183,30 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// ChangePasswordRequest represents a password change using the current password
+type ChangePasswordRequest struct {
+	Email           string `json:"email" validate:"required,email"`
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=6"`
+}
+
+// ChangeOwnPasswordRequest represents an authenticated user changing their own password
+type ChangeOwnPasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=6"`
+}
+
+// ChangeEmailRequest represents an authenticated user asking to change their email
+type ChangeEmailRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// CloseAccountRequest represents an authenticated user closing their own account
+type CloseAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
 // UpdateUserRequest represents user update input
 type UpdateUserRequest struct {
 	Name     string `json:"name" validate:"omitempty,min=2,max=100"`
@@ -64,3 +216,25 @@ type UpdateUserRequest struct {
 	IsActive *bool  `json:"isActive" validate:"omitempty"`
 }
 
+// CreateUserRequest represents an administrative user creation input
+type CreateUserRequest struct {
+	Name     string `json:"name" validate:"required,min=2,max=100"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=6"`
+	Role     string `json:"role" validate:"omitempty,oneof=user admin"`
+	IsActive *bool  `json:"isActive" validate:"omitempty"`
+}
+
+// UserFilter represents optional criteria for listing and searching users
+type UserFilter struct {
+	IDs      []primitive.ObjectID // Restrict to these users when set
+	AfterID  primitive.ObjectID   // Only users with a greater ID, for resuming a scan in ID order
+	Search   string               // Case-insensitive match on name or email
+	Role     string
+	IsActive *bool
+
+	SearchNameOnly bool // Match Search against the name only, for callers who may not see emails
+
+	IncludeDeleted bool // Also return soft-deleted users
+	OwnedOnly      bool // Only users whose home organization is the one acted in
+}
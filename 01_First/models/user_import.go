@@ -0,0 +1,47 @@
+package models
+
+// Supported bulk import/export formats
+const (
+	UserFormatCSV    = "csv"
+	UserFormatNDJSON = "ndjson"
+)
+
+// UserImportColumns are the CSV columns accepted by import; name and email are required
+var UserImportColumns = []string{"name", "email", "password", "role", "isActive"}
+
+// UserExportColumns are the CSV columns written by export
+var UserExportColumns = []string{"id", "name", "email", "role", "isActive", "createdAt", "updatedAt", "deletedAt"}
+
+// UserImportRow is one user in an import file
+type UserImportRow struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	IsActive *bool  `json:"isActive"`
+}
+
+// UserImportOptions controls an import
+type UserImportOptions struct {
+	Format string
+	DryRun bool // Validate only, nothing is written
+	Invite bool // Rows without a password get a password-set link instead
+}
+
+// UserImportError reports why a row was not imported
+type UserImportError struct {
+	Row   int    `json:"row"` // 1-based data row, excluding the CSV header
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+// UserImportReport summarises an import
+type UserImportReport struct {
+	DryRun          bool              `json:"dryRun"`
+	Total           int               `json:"total"`
+	Created         int               `json:"created"` // Would be created, for a dry run
+	Invited         int               `json:"invited"`
+	Failed          int               `json:"failed"`
+	Errors          []UserImportError `json:"errors"`
+	ErrorsTruncated bool              `json:"errorsTruncated,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestUserResponseProject(t *testing.T) {
+	user := &UserResponse{ID: "1", Name: "Jane Doe", Email: "jane@example.com", Role: "user", IsActive: true}
+
+	public, ok := user.Project("2", false).(*PublicUserResponse)
+	if !ok || *public != (PublicUserResponse{ID: "1", Name: "Jane Doe"}) {
+		t.Fatalf("view of another user = %#v, want the public profile", public)
+	}
+	if view := user.Project("1", false); view != user {
+		t.Fatalf("own view = %#v, want the full response", view)
+	}
+	if view := user.Project("2", true); view != user {
+		t.Fatalf("admin view = %#v, want the full response", view)
+	}
+
+	views := ProjectUsers([]*UserResponse{user, {ID: "2", Name: "John Doe"}}, "2", false)
+	if _, public := views[0].(*PublicUserResponse); !public || views[1].(*UserResponse).ID != "2" {
+		t.Fatalf("ProjectUsers = %#v", views)
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook event types emitted for the user lifecycle
+const (
+	WebhookEventUserCreated     = "user.created"
+	WebhookEventUserUpdated     = "user.updated"
+	WebhookEventUserDeactivated = "user.deactivated"
+	WebhookEventUserDeleted     = "user.deleted"
+)
+
+// WebhookEventTypes lists every event type a subscription may select
+var WebhookEventTypes = []string{
+	WebhookEventUserCreated,
+	WebhookEventUserUpdated,
+	WebhookEventUserDeactivated,
+	WebhookEventUserDeleted,
+}
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookSubscription represents a downstream endpoint receiving user events
+type WebhookSubscription struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	URL         string             `json:"url" bson:"url"`
+	Secret      string             `json:"-" bson:"secret"` // Only returned once, at creation
+	Events      []string           `json:"events" bson:"events"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	IsActive    bool               `json:"isActive" bson:"isActive"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Subscribes reports whether the subscription wants the given event type
+func (s *WebhookSubscription) Subscribes(eventType string) bool {
+	for _, event := range s.Events {
+		if event == eventType || event == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is a queued delivery holding one event for one subscription
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SubscriptionID primitive.ObjectID `json:"subscriptionId" bson:"subscriptionId"`
+	EventID        string             `json:"eventId" bson:"eventId"`
+	EventType      string             `json:"eventType" bson:"eventType"`
+	Payload        string             `json:"payload" bson:"payload"` // Exact bytes that are signed and sent
+	Status         string             `json:"status" bson:"status"`
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	NextAttemptAt  time.Time          `json:"nextAttemptAt" bson:"nextAttemptAt"`
+	LockedUntil    *time.Time         `json:"-" bson:"lockedUntil,omitempty"`
+	LastError      string             `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	DeliveredAt    *time.Time         `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+	Logs           []WebhookAttempt   `json:"logs" bson:"logs"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// WebhookAttempt records the outcome of a single delivery attempt
+type WebhookAttempt struct {
+	AttemptedAt time.Time `json:"attemptedAt" bson:"attemptedAt"`
+	StatusCode  int       `json:"statusCode,omitempty" bson:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty" bson:"error,omitempty"`
+	DurationMs  int64     `json:"durationMs" bson:"durationMs"`
+}
+
+// WebhookEvent is the JSON body POSTed to subscribers
+type WebhookEvent struct {
+	ID        string        `json:"id"`
+	Type      string        `json:"type"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Data      *UserResponse `json:"data"`
+}
+
+// CreateWebhookRequest represents webhook subscription creation input
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,url"`
+	Secret      string   `json:"secret" validate:"omitempty,min=16"` // Generated when empty
+	Events      []string `json:"events" validate:"required,min=1"`
+	Description string   `json:"description" validate:"omitempty,max=200"`
+}
+
+// UpdateWebhookRequest represents webhook subscription update input
+type UpdateWebhookRequest struct {
+	URL         string   `json:"url" validate:"omitempty,url"`
+	Events      []string `json:"events" validate:"omitempty,min=1"`
+	Description *string  `json:"description" validate:"omitempty,max=200"`
+	IsActive    *bool    `json:"isActive" validate:"omitempty"`
+}
@@ -0,0 +1,745 @@
+package mongotest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// collection is a collection of the in-memory server
+type collection struct {
+	name      string
+	documents []bson.D
+	indexes   []index
+}
+
+// index is an index of a collection; only unique indexes are enforced
+type index struct {
+	name          string
+	keys          []string
+	unique        bool
+	sparse        bool
+	partialFilter bson.D
+}
+
+// commandError is a failed command, reported to the driver with its code
+type commandError struct {
+	code    int32
+	message string
+}
+
+func (e *commandError) Error() string { return e.message }
+
+// duplicateKey reports a unique index violation (code 11000, which
+// mongo.IsDuplicateKeyError checks for)
+func duplicateKey(c *collection, idx index) *commandError {
+	return &commandError{code: 11000, message: fmt.Sprintf("E11000 duplicate key error collection: test.%s index: %s", c.name, idx.name)}
+}
+
+// run executes a command and returns its reply
+func (s *server) run(command bson.D) bson.D {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(command) == 0 {
+		return errorReply(&commandError{code: 9, message: "mongotest: empty command"})
+	}
+	name := command[0].Key
+	target, _ := command[0].Value.(string)
+
+	var reply bson.D
+	var err error
+	switch name {
+	case "ping", "hello", "isMaster", "ismaster", "endSessions", "killCursors", "commitTransaction", "abortTransaction":
+		reply = bson.D{}
+	case "insert":
+		reply, err = s.insert(s.collection(target), command)
+	case "find":
+		reply, err = s.find(s.collection(target), command)
+	case "update":
+		reply, err = s.update(s.collection(target), command)
+	case "delete":
+		reply, err = s.delete(s.collection(target), command)
+	case "findAndModify":
+		reply, err = s.findAndModify(s.collection(target), command)
+	case "aggregate":
+		reply, err = s.aggregate(s.collection(target), command)
+	case "count":
+		reply, err = s.count(s.collection(target), command)
+	case "distinct":
+		reply, err = s.distinct(s.collection(target), command)
+	case "createIndexes":
+		reply, err = s.createIndexes(s.collection(target), command)
+	case "listIndexes":
+		reply = s.listIndexes(s.collection(target))
+	case "dropIndexes":
+		s.collection(target).indexes = nil
+		reply = bson.D{}
+	case "drop":
+		delete(s.collections, target)
+		reply = bson.D{}
+	default:
+		err = &commandError{code: 59, message: "mongotest: unsupported command " + name}
+	}
+
+	if err != nil {
+		return errorReply(err)
+	}
+	return append(reply, bson.E{Key: "ok", Value: 1.0})
+}
+
+// errorReply is the reply of a failed command
+func errorReply(err error) bson.D {
+	code := int32(8)
+	if commandErr, ok := err.(*commandError); ok {
+		code = commandErr.code
+	}
+	return bson.D{{Key: "ok", Value: 0.0}, {Key: "errmsg", Value: err.Error()}, {Key: "code", Value: code}}
+}
+
+// writeErrorsReply is the reply of a write command that failed for some of
+// its statements
+func writeErrorsReply(reply bson.D, writeErrors bson.A) bson.D {
+	if len(writeErrors) > 0 {
+		reply = append(reply, bson.E{Key: "writeErrors", Value: writeErrors})
+	}
+	return reply
+}
+
+func writeError(i int, err error) bson.D {
+	code := int32(8)
+	if commandErr, ok := err.(*commandError); ok {
+		code = commandErr.code
+	}
+	return bson.D{{Key: "index", Value: int32(i)}, {Key: "code", Value: code}, {Key: "errmsg", Value: err.Error()}}
+}
+
+func (s *server) insert(c *collection, command bson.D) (bson.D, error) {
+	ordered := true
+	if value, ok := field(command, "ordered"); ok {
+		ordered, _ = value.(bool)
+	}
+
+	var n int32
+	var writeErrors bson.A
+	for i, value := range arrayField(command, "documents") {
+		document, _ := value.(bson.D)
+		if _, ok := field(document, "_id"); !ok {
+			document = append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}}, document...)
+		}
+		if err := c.add(document); err != nil {
+			writeErrors = append(writeErrors, writeError(i, err))
+			if ordered {
+				break
+			}
+			continue
+		}
+		n++
+	}
+
+	return writeErrorsReply(bson.D{{Key: "n", Value: n}}, writeErrors), nil
+}
+
+// add inserts a document after checking the unique indexes
+func (c *collection) add(document bson.D) error {
+	if err := c.checkUnique(document, -1); err != nil {
+		return err
+	}
+	c.documents = append(c.documents, document)
+	return nil
+}
+
+// replace replaces the document at position i after checking the unique indexes
+func (c *collection) replace(i int, document bson.D) error {
+	if err := c.checkUnique(document, i); err != nil {
+		return err
+	}
+	c.documents[i] = document
+	return nil
+}
+
+// checkUnique returns a duplicate key error when document shares the keys of
+// a unique index with another document than the one at position self
+func (c *collection) checkUnique(document bson.D, self int) error {
+	indexes := append([]index{{name: "_id_", keys: []string{"_id"}, unique: true}}, c.indexes...)
+	for _, idx := range indexes {
+		if !idx.unique || !idx.covers(document) {
+			continue
+		}
+		keys := idx.keyTuples(document)
+		for i, other := range c.documents {
+			if i == self || !idx.covers(other) {
+				continue
+			}
+			for _, key := range keys {
+				for _, otherKey := range idx.keyTuples(other) {
+					if equalValues(key, otherKey) {
+						return duplicateKey(c, idx)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// covers reports whether the index holds an entry for document
+func (idx index) covers(document bson.D) bool {
+	if idx.partialFilter != nil && !matches(document, idx.partialFilter) {
+		return false
+	}
+	if idx.sparse {
+		for _, key := range idx.keys {
+			if len(lookup(document, key)) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// keyTuples returns the index keys of document; a missing field is null and
+// an array field gives one key per element
+func (idx index) keyTuples(document bson.D) []bson.A {
+	tuples := []bson.A{{}}
+	for _, key := range idx.keys {
+		values := lookup(document, key)
+		var expanded []interface{}
+		for _, value := range values {
+			if array, ok := value.(bson.A); ok && len(array) > 0 {
+				expanded = append(expanded, array...)
+			} else {
+				expanded = append(expanded, value)
+			}
+		}
+		if len(expanded) == 0 {
+			expanded = []interface{}{nil}
+		}
+
+		var next []bson.A
+		for _, tuple := range tuples {
+			for _, value := range expanded {
+				next = append(next, append(append(bson.A{}, tuple...), value))
+			}
+		}
+		tuples = next
+	}
+	return tuples
+}
+
+func (s *server) find(c *collection, command bson.D) (bson.D, error) {
+	filter := docField(command, "filter")
+	documents := c.matching(filter)
+	sortDocuments(documents, docField(command, "sort"))
+
+	if skip := intField(command, "skip"); skip > 0 {
+		if skip >= len(documents) {
+			documents = nil
+		} else {
+			documents = documents[skip:]
+		}
+	}
+	if limit := intField(command, "limit"); limit < 0 {
+		limit = -limit
+		if limit < len(documents) {
+			documents = documents[:limit]
+		}
+	} else if limit > 0 && limit < len(documents) {
+		documents = documents[:limit]
+	}
+
+	projection := docField(command, "projection")
+	batch := bson.A{}
+	for _, document := range documents {
+		batch = append(batch, project(document, projection))
+	}
+	return cursorReply(c, batch), nil
+}
+
+// cursorReply returns all results in the first batch of a closed cursor
+func cursorReply(c *collection, batch bson.A) bson.D {
+	return bson.D{{Key: "cursor", Value: bson.D{
+		{Key: "firstBatch", Value: batch},
+		{Key: "id", Value: int64(0)},
+		{Key: "ns", Value: "test." + c.name},
+	}}}
+}
+
+// matching returns copies of the documents matching filter, in insertion order
+func (c *collection) matching(filter bson.D) []bson.D {
+	var documents []bson.D
+	for _, document := range c.documents {
+		if matches(document, filter) {
+			documents = append(documents, clone(document).(bson.D))
+		}
+	}
+	return documents
+}
+
+// positions returns the positions of the documents matching filter, in the
+// order given by sortSpec
+func (c *collection) positions(filter, sortSpec bson.D) []int {
+	var positions []int
+	for i, document := range c.documents {
+		if matches(document, filter) {
+			positions = append(positions, i)
+		}
+	}
+	if sortSpec != nil {
+		sort.SliceStable(positions, func(a, b int) bool {
+			return lessDocuments(c.documents[positions[a]], c.documents[positions[b]], sortSpec)
+		})
+	}
+	return positions
+}
+
+func sortDocuments(documents []bson.D, sortSpec bson.D) {
+	if sortSpec == nil {
+		return
+	}
+	sort.SliceStable(documents, func(a, b int) bool {
+		return lessDocuments(documents[a], documents[b], sortSpec)
+	})
+}
+
+func lessDocuments(a, b bson.D, sortSpec bson.D) bool {
+	for _, key := range sortSpec {
+		order := toFloat(key.Value)
+		result := compareValues(sortValue(a, key.Key, order), sortValue(b, key.Key, order))
+		if result != 0 {
+			return (result < 0) == (order > 0)
+		}
+	}
+	return false
+}
+
+// sortValue is the value a document sorts by on a field: the smallest
+// element of an array for ascending sorts, the largest for descending ones
+func sortValue(document bson.D, path string, order float64) interface{} {
+	values := lookup(document, path)
+	if len(values) == 0 {
+		return nil
+	}
+	value := values[0]
+	if array, ok := value.(bson.A); ok && len(array) > 0 {
+		value = array[0]
+		for _, element := range array[1:] {
+			if result := compareValues(element, value); (order > 0 && result < 0) || (order < 0 && result > 0) {
+				value = element
+			}
+		}
+	}
+	return value
+}
+
+// project applies an inclusion or exclusion projection to top-level fields
+func project(document bson.D, projection bson.D) bson.D {
+	if len(projection) == 0 {
+		return document
+	}
+
+	include := false
+	for _, spec := range projection {
+		if spec.Key != "_id" && truthy(spec.Value) {
+			include = true
+		}
+	}
+
+	var projected bson.D
+	for _, element := range document {
+		spec, listed := projectionSpec(projection, element.Key)
+		switch {
+		case element.Key == "_id" && !listed:
+			projected = append(projected, element)
+		case include && listed && truthy(spec):
+			projected = append(projected, element)
+		case !include && !(listed && !truthy(spec)):
+			projected = append(projected, element)
+		}
+	}
+	return projected
+}
+
+func projectionSpec(projection bson.D, key string) (interface{}, bool) {
+	for _, spec := range projection {
+		if spec.Key == key || strings.HasPrefix(spec.Key, key+".") {
+			return spec.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (s *server) update(c *collection, command bson.D) (bson.D, error) {
+	var n, modified int32
+	var upserted bson.A
+	var writeErrors bson.A
+
+	for i, value := range arrayField(command, "updates") {
+		statement, _ := value.(bson.D)
+		result, err := c.updateStatement(statement)
+		if err != nil {
+			writeErrors = append(writeErrors, writeError(i, err))
+			break
+		}
+		n += result.matched
+		modified += result.modified
+		if result.upsertedID != nil {
+			n++
+			upserted = append(upserted, bson.D{{Key: "index", Value: int32(i)}, {Key: "_id", Value: result.upsertedID}})
+		}
+	}
+
+	reply := bson.D{{Key: "n", Value: n}, {Key: "nModified", Value: modified}}
+	if len(upserted) > 0 {
+		reply = append(reply, bson.E{Key: "upserted", Value: upserted})
+	}
+	return writeErrorsReply(reply, writeErrors), nil
+}
+
+type updateResult struct {
+	matched    int32
+	modified   int32
+	upsertedID interface{}
+}
+
+func (c *collection) updateStatement(statement bson.D) (*updateResult, error) {
+	filter := docField(statement, "q")
+	update, ok := fieldValue(statement, "u").(bson.D)
+	if !ok {
+		return nil, &commandError{code: 9, message: "mongotest: update pipelines are not supported"}
+	}
+	multi, _ := fieldValue(statement, "multi").(bool)
+	upsert, _ := fieldValue(statement, "upsert").(bool)
+
+	result := &updateResult{}
+	positions := c.positions(filter, nil)
+	if !multi && len(positions) > 1 {
+		positions = positions[:1]
+	}
+	for _, i := range positions {
+		updated, err := applyUpdate(c.documents[i], filter, update, false)
+		if err != nil {
+			return nil, err
+		}
+		result.matched++
+		if !equalValues(updated, c.documents[i]) {
+			if err := c.replace(i, updated); err != nil {
+				return nil, err
+			}
+			result.modified++
+		}
+	}
+
+	if len(positions) == 0 && upsert {
+		document, err := c.upsert(filter, update)
+		if err != nil {
+			return nil, err
+		}
+		result.upsertedID = fieldValue(document, "_id")
+	}
+	return result, nil
+}
+
+// upsert inserts the document an update creates when nothing matches its filter
+func (c *collection) upsert(filter, update bson.D) (bson.D, error) {
+	document := seedFromFilter(filter)
+	document, err := applyUpdate(document, filter, update, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := field(document, "_id"); !ok {
+		document = append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}}, document...)
+	}
+	if err := c.add(document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// seedFromFilter returns the fields an upsert takes from the equality
+// conditions of its filter
+func seedFromFilter(filter bson.D) bson.D {
+	document := bson.D{}
+	for _, condition := range filter {
+		if strings.HasPrefix(condition.Key, "$") {
+			continue
+		}
+		value := condition.Value
+		if operators, ok := value.(bson.D); ok && isOperatorDocument(operators) {
+			eq, found := field(operators, "$eq")
+			if !found {
+				continue
+			}
+			value = eq
+		}
+		document, _ = setPath(document, condition.Key, clone(value))
+	}
+	return document
+}
+
+func (s *server) delete(c *collection, command bson.D) (bson.D, error) {
+	var n int32
+	for _, value := range arrayField(command, "deletes") {
+		statement, _ := value.(bson.D)
+		positions := c.positions(docField(statement, "q"), nil)
+		if intField(statement, "limit") == 1 && len(positions) > 1 {
+			positions = positions[:1]
+		}
+		c.removeAt(positions)
+		n += int32(len(positions))
+	}
+	return bson.D{{Key: "n", Value: n}}, nil
+}
+
+// removeAt removes the documents at the given positions
+func (c *collection) removeAt(positions []int) {
+	removed := map[int]bool{}
+	for _, i := range positions {
+		removed[i] = true
+	}
+	var kept []bson.D
+	for i, document := range c.documents {
+		if !removed[i] {
+			kept = append(kept, document)
+		}
+	}
+	c.documents = kept
+}
+
+func (s *server) findAndModify(c *collection, command bson.D) (bson.D, error) {
+	filter := docField(command, "query")
+	remove, _ := fieldValue(command, "remove").(bool)
+	returnNew, _ := fieldValue(command, "new").(bool)
+	upsert, _ := fieldValue(command, "upsert").(bool)
+	projection := docField(command, "fields")
+
+	positions := c.positions(filter, docField(command, "sort"))
+	if len(positions) == 0 {
+		if remove || !upsert {
+			return findAndModifyReply(nil, false, nil), nil
+		}
+		update, ok := fieldValue(command, "update").(bson.D)
+		if !ok {
+			return nil, &commandError{code: 9, message: "mongotest: update pipelines are not supported"}
+		}
+		document, err := c.upsert(filter, update)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if returnNew {
+			value = project(clone(document).(bson.D), projection)
+		}
+		return findAndModifyReply(value, false, fieldValue(document, "_id")), nil
+	}
+
+	i := positions[0]
+	original := c.documents[i]
+	if remove {
+		c.removeAt([]int{i})
+		return findAndModifyReply(project(clone(original).(bson.D), projection), false, nil), nil
+	}
+
+	update, ok := fieldValue(command, "update").(bson.D)
+	if !ok {
+		return nil, &commandError{code: 9, message: "mongotest: update pipelines are not supported"}
+	}
+	updated, err := applyUpdate(original, filter, update, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.replace(i, updated); err != nil {
+		return nil, err
+	}
+
+	value := original
+	if returnNew {
+		value = updated
+	}
+	return findAndModifyReply(project(clone(value).(bson.D), projection), true, nil), nil
+}
+
+func findAndModifyReply(value interface{}, updatedExisting bool, upsertedID interface{}) bson.D {
+	n := int32(0)
+	if value != nil || upsertedID != nil {
+		n = 1
+	}
+	lastError := bson.D{{Key: "n", Value: n}, {Key: "updatedExisting", Value: updatedExisting}}
+	if upsertedID != nil {
+		lastError = append(lastError, bson.E{Key: "upserted", Value: upsertedID})
+	}
+	return bson.D{{Key: "lastErrorObject", Value: lastError}, {Key: "value", Value: value}}
+}
+
+// aggregate supports the pipelines CountDocuments sends, and simple
+// $match/$sort/$skip/$limit pipelines
+func (s *server) aggregate(c *collection, command bson.D) (bson.D, error) {
+	documents := c.matching(nil)
+	for _, value := range arrayField(command, "pipeline") {
+		stage, _ := value.(bson.D)
+		if len(stage) != 1 {
+			return nil, &commandError{code: 9, message: "mongotest: malformed pipeline stage"}
+		}
+
+		switch stage[0].Key {
+		case "$match":
+			filter, _ := stage[0].Value.(bson.D)
+			var kept []bson.D
+			for _, document := range documents {
+				if matches(document, filter) {
+					kept = append(kept, document)
+				}
+			}
+			documents = kept
+		case "$sort":
+			spec, _ := stage[0].Value.(bson.D)
+			sortDocuments(documents, spec)
+		case "$skip":
+			skip := int(toFloat(stage[0].Value))
+			if skip >= len(documents) {
+				documents = nil
+			} else {
+				documents = documents[skip:]
+			}
+		case "$limit":
+			if limit := int(toFloat(stage[0].Value)); limit < len(documents) {
+				documents = documents[:limit]
+			}
+		case "$group":
+			// CountDocuments groups everything under one key and sums 1 per document
+			spec, _ := stage[0].Value.(bson.D)
+			if len(documents) == 0 {
+				documents = nil
+				continue
+			}
+			group := bson.D{{Key: "_id", Value: fieldValue(spec, "_id")}}
+			for _, accumulator := range spec {
+				if accumulator.Key == "_id" {
+					continue
+				}
+				sum, ok := accumulator.Value.(bson.D)
+				if !ok || len(sum) != 1 || sum[0].Key != "$sum" {
+					return nil, &commandError{code: 9, message: "mongotest: only $sum accumulators are supported"}
+				}
+				group = append(group, bson.E{Key: accumulator.Key, Value: int32(len(documents)) * int32(toFloat(sum[0].Value))})
+			}
+			documents = []bson.D{group}
+		case "$count":
+			name, _ := stage[0].Value.(string)
+			documents = []bson.D{{{Key: name, Value: int32(len(documents))}}}
+		default:
+			return nil, &commandError{code: 9, message: "mongotest: unsupported pipeline stage " + stage[0].Key}
+		}
+	}
+
+	batch := bson.A{}
+	for _, document := range documents {
+		batch = append(batch, document)
+	}
+	return cursorReply(c, batch), nil
+}
+
+func (s *server) count(c *collection, command bson.D) (bson.D, error) {
+	n := len(c.matching(docField(command, "query")))
+	return bson.D{{Key: "n", Value: int32(n)}}, nil
+}
+
+func (s *server) distinct(c *collection, command bson.D) (bson.D, error) {
+	key, _ := fieldValue(command, "key").(string)
+	values := bson.A{}
+	add := func(value interface{}) {
+		for _, existing := range values {
+			if equalValues(existing, value) {
+				return
+			}
+		}
+		values = append(values, value)
+	}
+
+	for _, document := range c.matching(docField(command, "query")) {
+		for _, value := range lookup(document, key) {
+			if array, ok := value.(bson.A); ok {
+				for _, element := range array {
+					add(element)
+				}
+			} else {
+				add(value)
+			}
+		}
+	}
+	return bson.D{{Key: "values", Value: values}}, nil
+}
+
+func (s *server) createIndexes(c *collection, command bson.D) (bson.D, error) {
+	for _, value := range arrayField(command, "indexes") {
+		spec, _ := value.(bson.D)
+		idx := index{partialFilter: docField(spec, "partialFilterExpression")}
+		idx.name, _ = fieldValue(spec, "name").(string)
+		idx.unique, _ = fieldValue(spec, "unique").(bool)
+		idx.sparse, _ = fieldValue(spec, "sparse").(bool)
+		for _, key := range docField(spec, "key") {
+			idx.keys = append(idx.keys, key.Key)
+		}
+
+		replaced := false
+		for i, existing := range c.indexes {
+			if existing.name == idx.name {
+				c.indexes[i] = idx
+				replaced = true
+			}
+		}
+		if !replaced {
+			c.indexes = append(c.indexes, idx)
+		}
+	}
+	return bson.D{}, nil
+}
+
+func (s *server) listIndexes(c *collection) bson.D {
+	batch := bson.A{bson.D{{Key: "v", Value: int32(2)}, {Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}}, {Key: "name", Value: "_id_"}}}
+	for _, idx := range c.indexes {
+		keys := bson.D{}
+		for _, key := range idx.keys {
+			keys = append(keys, bson.E{Key: key, Value: int32(1)})
+		}
+		batch = append(batch, bson.D{{Key: "v", Value: int32(2)}, {Key: "key", Value: keys}, {Key: "name", Value: idx.name}, {Key: "unique", Value: idx.unique}})
+	}
+	return cursorReply(c, batch)
+}
+
+// field returns the value of a top-level field of a document
+func field(document bson.D, key string) (interface{}, bool) {
+	for _, element := range document {
+		if element.Key == key {
+			return element.Value, true
+		}
+	}
+	return nil, false
+}
+
+func fieldValue(document bson.D, key string) interface{} {
+	value, _ := field(document, key)
+	return value
+}
+
+func docField(document bson.D, key string) bson.D {
+	value, _ := fieldValue(document, key).(bson.D)
+	return value
+}
+
+func arrayField(document bson.D, key string) bson.A {
+	value, _ := fieldValue(document, key).(bson.A)
+	return value
+}
+
+func intField(document bson.D, key string) int {
+	value, ok := field(document, key)
+	if !ok {
+		return 0
+	}
+	return int(toFloat(value))
+}
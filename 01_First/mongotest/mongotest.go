@@ -0,0 +1,279 @@
+// Package mongotest runs the MongoDB driver against an in-memory server, so
+// repositories and services can be tested without a running database. It
+// understands the commands and query operators the repositories use: finds,
+// writes, findAndModify, counts, distinct and unique indexes. Transactions
+// and aggregation beyond counting are not supported.
+//
+// The fake plugs into the driver's internal x/mongo/driver API, so it is tied
+// to the driver version in go.mod. Setting MONGODB_TEST_URI runs the same
+// tests against a real server instead; do that when upgrading the driver and
+// before relying on index or concurrency behavior only the fake has seen.
+package mongotest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/address"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+	"go.mongodb.org/mongo-driver/x/mongo/driver"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/wiremessage"
+)
+
+// serverAddress is the address the in-memory server reports
+const serverAddress = address.Address("mongotest:27017")
+
+var sessionTimeoutMinutes int64 = 30
+
+// serverDescription describes the in-memory server as a replica set primary,
+// so the driver uses sessions and retryable writes as it does in production
+var serverDescription = description.Server{
+	Addr:                     serverAddress,
+	CanonicalAddr:            serverAddress,
+	Kind:                     description.RSPrimary,
+	MaxDocumentSize:          16 * 1024 * 1024,
+	MaxMessageSize:           48000000,
+	MaxBatchCount:            100000,
+	SessionTimeoutMinutes:    uint32(sessionTimeoutMinutes),
+	SessionTimeoutMinutesPtr: &sessionTimeoutMinutes,
+	WireVersion:              &description.VersionRange{Min: 6, Max: 17},
+}
+
+// NewDatabase returns a database on a new, empty in-memory server. The client
+// is disconnected when the test ends.
+func NewDatabase(t testing.TB) *mongo.Database {
+	t.Helper()
+
+	if uri := os.Getenv("MONGODB_TEST_URI"); uri != "" {
+		return newServerDatabase(t, uri)
+	}
+
+	opts := options.Client()
+	opts.Deployment = &deployment{server: newServer()}
+	client, err := mongo.Connect(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("mongotest: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	return client.Database("test")
+}
+
+// newServerDatabase returns a new database of its own on the server at uri.
+// The database is dropped when the test ends.
+func newServerDatabase(t testing.TB, uri string) *mongo.Database {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("mongotest: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("mongotest: MONGODB_TEST_URI: %v", err)
+	}
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	db := client.Database("mongotest_" + hex.EncodeToString(suffix))
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return db
+}
+
+// deployment is a single in-memory server. It implements the driver's
+// Deployment, Server, Connector and Subscriber interfaces.
+type deployment struct {
+	server  *server
+	updates chan description.Topology
+}
+
+var (
+	_ driver.Deployment   = &deployment{}
+	_ driver.Server       = &deployment{}
+	_ driver.Connector    = &deployment{}
+	_ driver.Disconnector = &deployment{}
+	_ driver.Subscriber   = &deployment{}
+)
+
+func (d *deployment) SelectServer(context.Context, description.ServerSelector) (driver.Server, error) {
+	return d, nil
+}
+
+func (d *deployment) Kind() description.TopologyKind { return description.ReplicaSetWithPrimary }
+
+func (d *deployment) Connection(context.Context) (driver.Connection, error) {
+	return &connection{server: d.server}, nil
+}
+
+func (d *deployment) RTTMonitor() driver.RTTMonitor { return rttMonitor{} }
+
+func (d *deployment) Connect() error { return nil }
+
+func (d *deployment) Disconnect(context.Context) error { return nil }
+
+func (d *deployment) Subscribe() (*driver.Subscription, error) {
+	if d.updates == nil {
+		d.updates = make(chan description.Topology, 1)
+		d.updates <- description.Topology{
+			Kind:                     description.ReplicaSetWithPrimary,
+			SessionTimeoutMinutes:    uint32(sessionTimeoutMinutes),
+			SessionTimeoutMinutesPtr: &sessionTimeoutMinutes,
+		}
+	}
+	return &driver.Subscription{Updates: d.updates}, nil
+}
+
+func (d *deployment) Unsubscribe(*driver.Subscription) error { return nil }
+
+// rttMonitor reports no round-trip time
+type rttMonitor struct{}
+
+func (rttMonitor) EWMA() time.Duration { return 0 }
+func (rttMonitor) Min() time.Duration  { return 0 }
+func (rttMonitor) P90() time.Duration  { return 0 }
+func (rttMonitor) Stats() string       { return "" }
+
+// connection runs each command it is sent on the server and hands back the
+// reply on the next read
+type connection struct {
+	server *server
+	reply  []byte
+}
+
+var _ driver.Connection = &connection{}
+
+func (c *connection) WriteWireMessage(_ context.Context, message []byte) error {
+	command, err := readCommand(message)
+	if err != nil {
+		return err
+	}
+	c.reply = encodeReply(c.server.run(command))
+	return nil
+}
+
+func (c *connection) ReadWireMessage(context.Context) ([]byte, error) {
+	if c.reply == nil {
+		return nil, errors.New("mongotest: no command was sent")
+	}
+	reply := c.reply
+	c.reply = nil
+	return reply, nil
+}
+
+func (c *connection) Description() description.Server { return serverDescription }
+func (c *connection) Close() error                    { return nil }
+func (c *connection) ID() string                      { return "mongotest" }
+func (c *connection) DriverConnectionID() uint64      { return 1 }
+func (c *connection) Address() address.Address        { return serverAddress }
+func (c *connection) Stale() bool                     { return false }
+
+func (c *connection) ServerConnectionID() *int64 {
+	id := int64(1)
+	return &id
+}
+
+// readCommand decodes an OP_MSG into its command document, with the
+// document sequences (the documents of an insert, the statements of an update
+// or delete) added as array fields
+func readCommand(message []byte) (bson.D, error) {
+	_, _, _, opcode, rem, ok := wiremessage.ReadHeader(message)
+	if !ok || opcode != wiremessage.OpMsg {
+		return nil, errors.New("mongotest: only OP_MSG is supported")
+	}
+	_, rem, ok = wiremessage.ReadMsgFlags(rem)
+	if !ok {
+		return nil, errors.New("mongotest: malformed message")
+	}
+
+	var command bson.D
+	var sequences bson.D
+	for len(rem) > 0 {
+		var sectionType wiremessage.SectionType
+		sectionType, rem, ok = wiremessage.ReadMsgSectionType(rem)
+		if !ok {
+			return nil, errors.New("mongotest: malformed message")
+		}
+
+		switch sectionType {
+		case wiremessage.SingleDocument:
+			var document bsoncore.Document
+			document, rem, ok = wiremessage.ReadMsgSectionSingleDocument(rem)
+			if !ok {
+				return nil, errors.New("mongotest: malformed message")
+			}
+			if err := bson.Unmarshal(document, &command); err != nil {
+				return nil, err
+			}
+		case wiremessage.DocumentSequence:
+			var identifier string
+			var documents []bsoncore.Document
+			identifier, documents, rem, ok = wiremessage.ReadMsgSectionDocumentSequence(rem)
+			if !ok {
+				return nil, errors.New("mongotest: malformed message")
+			}
+			values := bson.A{}
+			for _, document := range documents {
+				var value bson.D
+				if err := bson.Unmarshal(document, &value); err != nil {
+					return nil, err
+				}
+				values = append(values, value)
+			}
+			sequences = append(sequences, bson.E{Key: identifier, Value: values})
+		default:
+			return nil, errors.New("mongotest: unknown message section")
+		}
+	}
+
+	return append(command, sequences...), nil
+}
+
+// encodeReply encodes a reply document as an OP_MSG
+func encodeReply(reply bson.D) []byte {
+	document, err := bson.Marshal(reply)
+	if err != nil {
+		document, _ = bson.Marshal(bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: err.Error()}})
+	}
+
+	index, message := wiremessage.AppendHeaderStart(nil, wiremessage.NextRequestID(), 0, wiremessage.OpMsg)
+	message = wiremessage.AppendMsgFlags(message, 0)
+	message = wiremessage.AppendMsgSectionType(message, wiremessage.SingleDocument)
+	message = append(message, document...)
+	return bsoncore.UpdateLength(message, index, int32(len(message[index:])))
+}
+
+// server holds the collections of the in-memory server. Commands run one at
+// a time.
+type server struct {
+	mu          sync.Mutex
+	collections map[string]*collection
+}
+
+func newServer() *server {
+	return &server{collections: map[string]*collection{}}
+}
+
+// collection returns the collection with the given name, creating it if needed
+func (s *server) collection(name string) *collection {
+	c, ok := s.collections[name]
+	if !ok {
+		c = &collection{name: name}
+		s.collections[name] = c
+	}
+	return c
+}
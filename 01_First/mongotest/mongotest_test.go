@@ -0,0 +1,159 @@
+package mongotest
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestQueryOperators(t *testing.T) {
+	ctx := context.Background()
+	collection := NewDatabase(t).Collection("people")
+
+	_, err := collection.InsertMany(ctx, []interface{}{
+		bson.M{"name": "ann", "age": 31, "tags": bson.A{"a", "b"}, "orgs": bson.A{bson.M{"orgId": "x", "role": "owner"}}},
+		bson.M{"name": "bob", "age": 25, "tags": bson.A{"b"}, "orgs": bson.A{bson.M{"orgId": "y", "role": "member"}}},
+		bson.M{"name": "cid", "age": 40, "deletedAt": 1},
+	})
+	if err != nil {
+		t.Fatalf("InsertMany: %v", err)
+	}
+
+	tests := []struct {
+		filter bson.M
+		want   int64
+	}{
+		{bson.M{}, 3},
+		{bson.M{"age": bson.M{"$gte": 31}}, 2},
+		{bson.M{"age": bson.M{"$gt": 25, "$lt": 40}}, 1},
+		{bson.M{"tags": "b"}, 2},
+		{bson.M{"tags": bson.M{"$in": bson.A{"a", "z"}}}, 1},
+		{bson.M{"deletedAt": bson.M{"$exists": false}}, 2},
+		{bson.M{"orgs.orgId": "y"}, 1},
+		{bson.M{"orgs": bson.M{"$elemMatch": bson.M{"orgId": "x", "role": "owner"}}}, 1},
+		{bson.M{"orgs": bson.M{"$elemMatch": bson.M{"orgId": "x", "role": "member"}}}, 0},
+		{bson.M{"$or": bson.A{bson.M{"name": "ann"}, bson.M{"age": 40}}}, 2},
+		{bson.M{"name": bson.M{"$ne": "ann"}}, 2},
+		{bson.M{"name": bson.M{"$regex": "^B", "$options": "i"}}, 1},
+		{bson.M{"missing": nil}, 3},
+	}
+	for _, tt := range tests {
+		n, err := collection.CountDocuments(ctx, tt.filter)
+		if err != nil {
+			t.Fatalf("CountDocuments(%v): %v", tt.filter, err)
+		}
+		if n != tt.want {
+			t.Errorf("CountDocuments(%v) = %d, want %d", tt.filter, n, tt.want)
+		}
+	}
+}
+
+func TestUpdates(t *testing.T) {
+	ctx := context.Background()
+	collection := NewDatabase(t).Collection("people")
+
+	if _, err := collection.InsertOne(ctx, bson.M{"_id": 1, "n": 1, "list": bson.A{1, 2}, "gone": true}); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+	_, err := collection.UpdateByID(ctx, 1, bson.M{
+		"$set":   bson.M{"a.b": "c"},
+		"$inc":   bson.M{"n": 2},
+		"$unset": bson.M{"gone": ""},
+		"$push":  bson.M{"list": bson.M{"$each": bson.A{3, 4}, "$slice": -3}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateByID: %v", err)
+	}
+
+	var got bson.M
+	if err := collection.FindOne(ctx, bson.M{"_id": 1}).Decode(&got); err != nil {
+		t.Fatalf("FindOne: %v", err)
+	}
+	if got["n"] != int32(3) || got["a"].(bson.M)["b"] != "c" || got["gone"] != nil || len(got["list"].(bson.A)) != 3 {
+		t.Fatalf("updated document = %v", got)
+	}
+
+	result := collection.FindOneAndUpdate(ctx, bson.M{"_id": 2}, bson.M{"$setOnInsert": bson.M{"n": 7}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+	if err := result.Decode(&got); err != nil || got["n"] != int32(7) {
+		t.Fatalf("upserted document = %v, %v", got, err)
+	}
+
+	deleted, err := collection.DeleteMany(ctx, bson.M{"n": bson.M{"$gt": 0}})
+	if err != nil || deleted.DeletedCount != 2 {
+		t.Fatalf("DeleteMany = %v, %v", deleted, err)
+	}
+}
+
+func TestPositionalUpdates(t *testing.T) {
+	ctx := context.Background()
+	collection := NewDatabase(t).Collection("people")
+
+	orgs := bson.A{bson.M{"orgId": "x", "role": "owner"}, bson.M{"orgId": "y", "role": "member"}}
+	if _, err := collection.InsertOne(ctx, bson.M{"_id": 1, "orgs": orgs}); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+
+	roles := func() []interface{} {
+		t.Helper()
+		var got struct{ Orgs []bson.M }
+		if err := collection.FindOne(ctx, bson.M{"_id": 1}).Decode(&got); err != nil {
+			t.Fatalf("FindOne: %v", err)
+		}
+		var roles []interface{}
+		for _, org := range got.Orgs {
+			roles = append(roles, org["role"])
+		}
+		return roles
+	}
+
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": 1, "orgs.orgId": "y"}, bson.M{"$set": bson.M{"orgs.$.role": "admin"}}); err != nil {
+		t.Fatalf("UpdateOne by orgs.orgId: %v", err)
+	}
+	if got := roles(); len(got) != 2 || got[0] != "owner" || got[1] != "admin" {
+		t.Fatalf("roles after updating y = %v", got)
+	}
+
+	filter := bson.M{"_id": 1, "orgs": bson.M{"$elemMatch": bson.M{"orgId": "x", "role": "owner"}}}
+	if _, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"orgs.$.role": "member"}}); err != nil {
+		t.Fatalf("UpdateOne by $elemMatch: %v", err)
+	}
+	if got := roles(); len(got) != 2 || got[0] != "member" || got[1] != "admin" {
+		t.Fatalf("roles after updating x = %v", got)
+	}
+
+	// Without a condition on the array there is no element to update
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": 1}, bson.M{"$set": bson.M{"orgs.$.role": "member"}}); err == nil {
+		t.Fatal("a positional update without a condition on the array succeeded")
+	}
+}
+
+func TestUniqueIndexes(t *testing.T) {
+	ctx := context.Background()
+	collection := NewDatabase(t).Collection("people")
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"code": bson.M{"$exists": true}})},
+	})
+	if err != nil {
+		t.Fatalf("CreateMany: %v", err)
+	}
+
+	if _, err := collection.InsertOne(ctx, bson.M{"email": "a"}); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+	if _, err := collection.InsertOne(ctx, bson.M{"email": "b"}); err != nil {
+		t.Fatalf("second document without a code: %v", err)
+	}
+	if _, err := collection.InsertOne(ctx, bson.M{"email": "a"}); !mongo.IsDuplicateKeyError(err) {
+		t.Fatalf("duplicate email: err = %v", err)
+	}
+	if _, err := collection.UpdateOne(ctx, bson.M{"email": "b"}, bson.M{"$set": bson.M{"email": "a"}}); !mongo.IsDuplicateKeyError(err) {
+		t.Fatalf("update to a duplicate email: err = %v", err)
+	}
+}
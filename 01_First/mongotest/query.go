@@ -0,0 +1,719 @@
+package mongotest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matches reports whether document matches a query filter
+func matches(document bson.D, filter bson.D) bool {
+	for _, condition := range filter {
+		switch condition.Key {
+		case "$and", "$or", "$nor":
+			clauses, _ := condition.Value.(bson.A)
+			matched := 0
+			for _, clause := range clauses {
+				if sub, ok := clause.(bson.D); ok && matches(document, sub) {
+					matched++
+				}
+			}
+			if (condition.Key == "$and" && matched != len(clauses)) ||
+				(condition.Key == "$or" && matched == 0) ||
+				(condition.Key == "$nor" && matched != 0) {
+				return false
+			}
+		default:
+			if !matchValues(lookup(document, condition.Key), condition.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchValues reports whether the values found at a path satisfy a condition,
+// either a value to equal or a document of operators
+func matchValues(values []interface{}, condition interface{}) bool {
+	if regex, ok := condition.(primitive.Regex); ok {
+		return matchRegex(values, regex.Pattern, regex.Options)
+	}
+	operators, ok := condition.(bson.D)
+	if !ok || !isOperatorDocument(operators) {
+		return matchEqual(values, condition)
+	}
+
+	for _, operator := range operators {
+		if !matchOperator(values, operator.Key, operator.Value, operators) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchOperator(values []interface{}, operator string, operand interface{}, operators bson.D) bool {
+	switch operator {
+	case "$eq":
+		return matchEqual(values, operand)
+	case "$ne":
+		return !matchEqual(values, operand)
+	case "$in", "$nin":
+		list, _ := operand.(bson.A)
+		found := false
+		for _, candidate := range list {
+			if regex, ok := candidate.(primitive.Regex); ok {
+				found = found || matchRegex(values, regex.Pattern, regex.Options)
+			} else {
+				found = found || matchEqual(values, candidate)
+			}
+		}
+		return found == (operator == "$in")
+	case "$exists":
+		return (len(values) > 0) == truthy(operand)
+	case "$gt", "$gte", "$lt", "$lte":
+		for _, value := range expand(values) {
+			if !comparable(value, operand) {
+				continue
+			}
+			result := compareValues(value, operand)
+			if (operator == "$gt" && result > 0) || (operator == "$gte" && result >= 0) ||
+				(operator == "$lt" && result < 0) || (operator == "$lte" && result <= 0) {
+				return true
+			}
+		}
+		return false
+	case "$elemMatch":
+		condition, _ := operand.(bson.D)
+		for _, value := range values {
+			array, ok := value.(bson.A)
+			if !ok {
+				continue
+			}
+			for _, element := range array {
+				if matchElement(element, condition) {
+					return true
+				}
+			}
+		}
+		return false
+	case "$size":
+		for _, value := range values {
+			if array, ok := value.(bson.A); ok && float64(len(array)) == toFloat(operand) {
+				return true
+			}
+		}
+		return false
+	case "$not":
+		return !matchValues(values, operand)
+	case "$regex":
+		options, _ := fieldValue(operators, "$options").(string)
+		switch pattern := operand.(type) {
+		case string:
+			return matchRegex(values, pattern, options)
+		case primitive.Regex:
+			return matchRegex(values, pattern.Pattern, pattern.Options+options)
+		}
+		return false
+	case "$options":
+		return true
+	default:
+		panic(fmt.Sprintf("mongotest: unsupported query operator %s", operator))
+	}
+}
+
+// matchElement reports whether an array element satisfies an $elemMatch
+// condition, which holds either field conditions or operators
+func matchElement(element interface{}, condition bson.D) bool {
+	if isOperatorDocument(condition) {
+		return matchValues([]interface{}{element}, condition)
+	}
+	document, ok := element.(bson.D)
+	return ok && matches(document, condition)
+}
+
+// matchEqual reports whether one of the values, or an element of one of them,
+// equals target. A missing field equals null.
+func matchEqual(values []interface{}, target interface{}) bool {
+	if len(values) == 0 {
+		return target == nil
+	}
+	for _, value := range values {
+		if equalValues(value, target) {
+			return true
+		}
+		if array, ok := value.(bson.A); ok {
+			for _, element := range array {
+				if equalValues(element, target) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func matchRegex(values []interface{}, pattern, options string) bool {
+	prefix := ""
+	if strings.Contains(options, "i") {
+		prefix = "(?i)"
+	}
+	re, err := regexp.Compile(prefix + pattern)
+	if err != nil {
+		panic(fmt.Sprintf("mongotest: invalid regex %q: %v", pattern, err))
+	}
+	for _, value := range expand(values) {
+		if s, ok := value.(string); ok && re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand returns the values with arrays replaced by their elements
+func expand(values []interface{}) []interface{} {
+	var expanded []interface{}
+	for _, value := range values {
+		if array, ok := value.(bson.A); ok {
+			expanded = append(expanded, array...)
+		} else {
+			expanded = append(expanded, value)
+		}
+	}
+	return expanded
+}
+
+// isOperatorDocument reports whether a condition is a document of operators
+// such as {$gt: 1} rather than a document to equal
+func isOperatorDocument(document bson.D) bool {
+	return len(document) > 0 && strings.HasPrefix(document[0].Key, "$")
+}
+
+// lookup returns the values at a dotted path. Arrays along the path are
+// traversed, so a path can yield several values; a missing field yields none.
+func lookup(value interface{}, path string) []interface{} {
+	return lookupParts(value, strings.Split(path, "."))
+}
+
+func lookupParts(value interface{}, parts []string) []interface{} {
+	if len(parts) == 0 {
+		return []interface{}{value}
+	}
+
+	switch v := value.(type) {
+	case bson.D:
+		child, ok := field(v, parts[0])
+		if !ok {
+			return nil
+		}
+		return lookupParts(child, parts[1:])
+	case bson.A:
+		if i, err := strconv.Atoi(parts[0]); err == nil {
+			if i < 0 || i >= len(v) {
+				return nil
+			}
+			return lookupParts(v[i], parts[1:])
+		}
+		var values []interface{}
+		for _, element := range v {
+			if _, ok := element.(bson.D); ok {
+				values = append(values, lookupParts(element, parts)...)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// typeOrder ranks BSON types for comparisons across types
+func typeOrder(value interface{}) int {
+	switch value.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return 1
+	case int32, int64, float64, int, primitive.Decimal128:
+		return 2
+	case string, primitive.Symbol:
+		return 3
+	case bson.D, bson.M:
+		return 4
+	case bson.A:
+		return 5
+	case primitive.Binary, []byte:
+		return 6
+	case primitive.ObjectID:
+		return 7
+	case bool:
+		return 8
+	case primitive.DateTime, time.Time:
+		return 9
+	case primitive.Timestamp:
+		return 10
+	case primitive.Regex:
+		return 11
+	}
+	return 12
+}
+
+// comparable reports whether two values can be ordered by $gt and friends,
+// which only compare values of the same type
+func comparable(a, b interface{}) bool {
+	return typeOrder(a) == typeOrder(b)
+}
+
+// compareValues orders two values like MongoDB sorts them
+func compareValues(a, b interface{}) int {
+	if orderA, orderB := typeOrder(a), typeOrder(b); orderA != orderB {
+		return compareInts(int64(orderA), int64(orderB))
+	}
+
+	switch x := a.(type) {
+	case int32, int64, float64, int:
+		fa, fb := toFloat(x), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(x, b.(string))
+	case primitive.ObjectID:
+		y := b.(primitive.ObjectID)
+		return bytes.Compare(x[:], y[:])
+	case bool:
+		y := b.(bool)
+		if x == y {
+			return 0
+		}
+		if !x {
+			return -1
+		}
+		return 1
+	case primitive.DateTime:
+		return compareInts(int64(x), int64(toDateTime(b)))
+	case time.Time:
+		return compareInts(int64(primitive.NewDateTimeFromTime(x)), int64(toDateTime(b)))
+	case bson.D:
+		y := b.(bson.D)
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if result := strings.Compare(x[i].Key, y[i].Key); result != 0 {
+				return result
+			}
+			if result := compareValues(x[i].Value, y[i].Value); result != 0 {
+				return result
+			}
+		}
+		return compareInts(int64(len(x)), int64(len(y)))
+	case bson.A:
+		y := b.(bson.A)
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if result := compareValues(x[i], y[i]); result != 0 {
+				return result
+			}
+		}
+		return compareInts(int64(len(x)), int64(len(y)))
+	case primitive.Binary:
+		return bytes.Compare(x.Data, b.(primitive.Binary).Data)
+	}
+	return 0
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// equalValues reports whether two values are equal, numbers of different
+// types included
+func equalValues(a, b interface{}) bool {
+	if typeOrder(a) != typeOrder(b) {
+		return false
+	}
+	if x, ok := a.(bson.D); ok {
+		y, ok := b.(bson.D)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if x[i].Key != y[i].Key || !equalValues(x[i].Value, y[i].Value) {
+				return false
+			}
+		}
+		return true
+	}
+	if x, ok := a.(bson.A); ok {
+		y, ok := b.(bson.A)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equalValues(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return compareValues(a, b) == 0
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+	}
+	return 0
+}
+
+func toDateTime(value interface{}) primitive.DateTime {
+	switch v := value.(type) {
+	case primitive.DateTime:
+		return v
+	case time.Time:
+		return primitive.NewDateTimeFromTime(v)
+	}
+	return 0
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case nil:
+		return false
+	case int32, int64, float64, int:
+		return toFloat(v) != 0
+	}
+	return true
+}
+
+// clone deep-copies documents and arrays
+func clone(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		copied := make(bson.D, len(v))
+		for i, element := range v {
+			copied[i] = bson.E{Key: element.Key, Value: clone(element.Value)}
+		}
+		return copied
+	case bson.A:
+		copied := make(bson.A, len(v))
+		for i, element := range v {
+			copied[i] = clone(element)
+		}
+		return copied
+	}
+	return value
+}
+
+// applyUpdate returns a copy of document with an update applied: either a
+// replacement document or update operators. $setOnInsert only applies when
+// the update inserts the document; filter, the query that matched it,
+// resolves positional paths such as orgs.$.role.
+func applyUpdate(document bson.D, filter bson.D, update bson.D, inserting bool) (bson.D, error) {
+	updated := clone(document).(bson.D)
+
+	if !isOperatorDocument(update) {
+		replacement := clone(update).(bson.D)
+		if id, ok := field(updated, "_id"); ok {
+			if _, has := field(replacement, "_id"); !has {
+				replacement = append(bson.D{{Key: "_id", Value: id}}, replacement...)
+			}
+		}
+		return replacement, nil
+	}
+
+	var err error
+	for _, operator := range update {
+		fields, _ := operator.Value.(bson.D)
+		for _, f := range fields {
+			if f.Key, err = positional(document, filter, f.Key); err != nil {
+				return nil, err
+			}
+			value := clone(f.Value)
+			switch operator.Key {
+			case "$set":
+				updated, err = setPath(updated, f.Key, value)
+			case "$setOnInsert":
+				if inserting {
+					updated, err = setPath(updated, f.Key, value)
+				}
+			case "$unset":
+				updated = unsetPath(updated, f.Key)
+			case "$inc":
+				var current interface{} = int32(0)
+				if values := lookup(updated, f.Key); len(values) > 0 {
+					current = values[0]
+				}
+				updated, err = setPath(updated, f.Key, addNumbers(current, value))
+			case "$min", "$max":
+				values := lookup(updated, f.Key)
+				if len(values) == 0 || (operator.Key == "$min" && compareValues(value, values[0]) < 0) ||
+					(operator.Key == "$max" && compareValues(value, values[0]) > 0) {
+					updated, err = setPath(updated, f.Key, value)
+				}
+			case "$push", "$addToSet":
+				updated, err = pushPath(updated, f.Key, value, operator.Key == "$addToSet")
+			case "$pull":
+				updated, err = pullPath(updated, f.Key, value)
+			default:
+				return nil, &commandError{code: 9, message: "mongotest: unsupported update operator " + operator.Key}
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return updated, nil
+}
+
+// positional replaces the $ of a path such as orgs.$.role with the index of
+// the first element of the array that matches the filter's conditions on it
+func positional(document, filter bson.D, path string) (string, error) {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		if part != "$" {
+			continue
+		}
+		array := strings.Join(parts[:i], ".")
+		index := -1
+		if values := lookup(document, array); len(values) == 1 {
+			elements, _ := values[0].(bson.A)
+			conditions := elementConditions(filter, array)
+			for j, element := range elements {
+				if len(conditions) > 0 && matchesAll(element, conditions) {
+					index = j
+					break
+				}
+			}
+		}
+		if index < 0 {
+			return "", &commandError{code: 2, message: "mongotest: the positional operator did not find the match needed from the query"}
+		}
+		parts[i] = strconv.Itoa(index)
+		return strings.Join(parts, "."), nil
+	}
+	return path, nil
+}
+
+// elementConditions collects the conditions a filter puts on the elements
+// of an array: on their fields, as in orgs.orgId, or $elemMatch on the array
+func elementConditions(filter bson.D, array string) []func(interface{}) bool {
+	var conditions []func(interface{}) bool
+	for _, condition := range filter {
+		condition := condition
+		switch {
+		case condition.Key == "$and":
+			clauses, _ := condition.Value.(bson.A)
+			for _, clause := range clauses {
+				if sub, ok := clause.(bson.D); ok {
+					conditions = append(conditions, elementConditions(sub, array)...)
+				}
+			}
+		case condition.Key == array:
+			if operators, ok := condition.Value.(bson.D); ok {
+				if elemMatch, ok := fieldValue(operators, "$elemMatch").(bson.D); ok {
+					conditions = append(conditions, func(element interface{}) bool { return matchElement(element, elemMatch) })
+				}
+			}
+		case strings.HasPrefix(condition.Key, array+"."):
+			sub := strings.TrimPrefix(condition.Key, array+".")
+			conditions = append(conditions, func(element interface{}) bool {
+				return matchValues(lookup(element, sub), condition.Value)
+			})
+		}
+	}
+	return conditions
+}
+
+// matchesAll reports whether an array element meets every condition
+func matchesAll(element interface{}, conditions []func(interface{}) bool) bool {
+	for _, condition := range conditions {
+		if !condition(element) {
+			return false
+		}
+	}
+	return true
+}
+
+// addNumbers adds two numbers, keeping integers as integers
+func addNumbers(a, b interface{}) interface{} {
+	_, floatA := a.(float64)
+	_, floatB := b.(float64)
+	if floatA || floatB {
+		return toFloat(a) + toFloat(b)
+	}
+	sum := int64(toFloat(a)) + int64(toFloat(b))
+	_, int32A := a.(int32)
+	_, int32B := b.(int32)
+	if int32A && int32B && sum >= math.MinInt32 && sum <= math.MaxInt32 {
+		return int32(sum)
+	}
+	return sum
+}
+
+// setPath sets the value at a dotted path, creating the documents along it
+func setPath(document bson.D, path string, value interface{}) (bson.D, error) {
+	parts := strings.SplitN(path, ".", 2)
+	for i, element := range document {
+		if element.Key != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			document[i].Value = value
+			return document, nil
+		}
+		child, err := setChild(element.Value, parts[1], value)
+		if err != nil {
+			return nil, err
+		}
+		document[i].Value = child
+		return document, nil
+	}
+
+	if len(parts) == 1 {
+		return append(document, bson.E{Key: parts[0], Value: value}), nil
+	}
+	child, err := setPath(bson.D{}, parts[1], value)
+	if err != nil {
+		return nil, err
+	}
+	return append(document, bson.E{Key: parts[0], Value: child}), nil
+}
+
+// setChild sets a path inside a document or, by index, inside an array
+func setChild(container interface{}, path string, value interface{}) (interface{}, error) {
+	switch c := container.(type) {
+	case bson.D:
+		return setPath(c, path, value)
+	case bson.A:
+		parts := strings.SplitN(path, ".", 2)
+		i, err := strconv.Atoi(parts[0])
+		if err != nil || i < 0 {
+			return nil, &commandError{code: 28, message: "mongotest: cannot set " + path + " in an array"}
+		}
+		for len(c) <= i {
+			c = append(c, nil)
+		}
+		if len(parts) == 1 {
+			c[i] = value
+			return c, nil
+		}
+		child, err := setChild(c[i], parts[1], value)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = child
+		return c, nil
+	case nil:
+		return setPath(bson.D{}, path, value)
+	}
+	return nil, &commandError{code: 28, message: "mongotest: cannot set " + path + " in a scalar"}
+}
+
+// unsetPath removes the field at a dotted path
+func unsetPath(document bson.D, path string) bson.D {
+	parts := strings.SplitN(path, ".", 2)
+	for i, element := range document {
+		if element.Key != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			return append(document[:i:i], document[i+1:]...)
+		}
+		if child, ok := element.Value.(bson.D); ok {
+			document[i].Value = unsetPath(child, parts[1])
+		}
+		return document
+	}
+	return document
+}
+
+// pushPath appends to the array at a path; value may use $each and $slice
+func pushPath(document bson.D, path string, value interface{}, unique bool) (bson.D, error) {
+	var array bson.A
+	if values := lookup(document, path); len(values) > 0 {
+		existing, ok := values[0].(bson.A)
+		if !ok {
+			return nil, &commandError{code: 2, message: "mongotest: " + path + " is not an array"}
+		}
+		array = existing
+	}
+
+	items := bson.A{value}
+	var slice *int
+	if modifiers, ok := value.(bson.D); ok && isOperatorDocument(modifiers) {
+		items, _ = fieldValue(modifiers, "$each").(bson.A)
+		if n, ok := field(modifiers, "$slice"); ok {
+			size := int(toFloat(n))
+			slice = &size
+		}
+	}
+
+	for _, item := range items {
+		if unique && matchEqual([]interface{}{array}, item) {
+			continue
+		}
+		array = append(array, item)
+	}
+	if slice != nil {
+		switch size := *slice; {
+		case size >= 0 && size < len(array):
+			array = array[:size]
+		case size < 0 && -size < len(array):
+			array = array[len(array)+size:]
+		}
+	}
+	if array == nil {
+		array = bson.A{}
+	}
+	return setPath(document, path, array)
+}
+
+// pullPath removes the elements of the array at a path that equal value or,
+// when value is a condition, match it
+func pullPath(document bson.D, path string, value interface{}) (bson.D, error) {
+	values := lookup(document, path)
+	if len(values) == 0 {
+		return document, nil
+	}
+	array, ok := values[0].(bson.A)
+	if !ok {
+		return nil, &commandError{code: 2, message: "mongotest: " + path + " is not an array"}
+	}
+
+	kept := bson.A{}
+	for _, element := range array {
+		remove := false
+		if condition, ok := value.(bson.D); ok {
+			remove = matchElement(element, condition)
+		} else {
+			remove = equalValues(element, value)
+		}
+		if !remove {
+			kept = append(kept, element)
+		}
+	}
+	return setPath(document, path, kept)
+}
@@ -0,0 +1,35 @@
+package openapi
+
+import (
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// Undocumented walks the router and returns every route ("METHOD /path")
+// that has no entry in the OpenAPI document
+func Undocumented(router *mux.Router) []string {
+	var missing []string
+
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		// Subrouter prefixes have no methods
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		for _, method := range methods {
+			if !Documented(method, path) {
+				missing = append(missing, method+" "+path)
+			}
+		}
+		return nil
+	})
+
+	sort.Strings(missing)
+	return missing
+}
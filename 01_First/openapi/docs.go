@@ -0,0 +1,8 @@
+package openapi
+
+import _ "embed"
+
+// DocsPage is the self-contained docs UI; it renders /openapi.json in the browser
+//
+//go:embed docs.html
+var DocsPage []byte
@@ -0,0 +1,1047 @@
+package openapi
+
+import (
+	"net/http"
+
+	"user-management-system/models"
+)
+
+// Payloads built from maps in the handlers, declared here for documentation only
+
+type authToken struct {
+	User  models.UserResponse `json:"user"`
+	Token string              `json:"token"`
+}
+
+type allUsers struct {
+	Users []models.UserResponse `json:"users"`
+	Total int64                 `json:"total"`
+	Count int                   `json:"count"`
+}
+
+type allUsersResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message"`
+	Data    allUsers `json:"data"`
+}
+
+type createdWebhook struct {
+	Webhook models.WebhookSubscription `json:"webhook"`
+	Secret  string                     `json:"secret"`
+}
+
+// userMergePatch documents the PATCH /api/users/{id} body; role: null resets the role to user
+type userMergePatch struct {
+	Name     string  `json:"name,omitempty" validate:"omitempty,min=2,max=100"`
+	Email    string  `json:"email,omitempty" validate:"omitempty,email"`
+	Password string  `json:"password,omitempty" validate:"omitempty,min=6"`
+	Role     *string `json:"role,omitempty" validate:"omitempty,oneof=user admin"`
+	IsActive *bool   `json:"isActive,omitempty"`
+}
+
+// oauthAuthorizeForm documents the POST /oauth/authorize form; the consent
+// page sends the authorization request back with the user's answer
+type oauthAuthorizeForm struct {
+	ResponseType        string `json:"response_type" validate:"required,oneof=code"`
+	ClientID            string `json:"client_id" validate:"required"`
+	RedirectURI         string `json:"redirect_uri,omitempty"`
+	Scope               string `json:"scope,omitempty"`
+	State               string `json:"state,omitempty"`
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty" validate:"omitempty,oneof=S256"`
+	Nonce               string `json:"nonce,omitempty"`
+	Email               string `json:"email,omitempty"`
+	Password            string `json:"password,omitempty"`
+	Action              string `json:"action" validate:"required,oneof=allow deny"`
+	CSRFToken           string `json:"csrf_token" validate:"required"`
+}
+
+// oauthTokenForm documents the POST /oauth/token form
+type oauthTokenForm struct {
+	GrantType    string `json:"grant_type" validate:"required,oneof=authorization_code refresh_token client_credentials"`
+	Code         string `json:"code,omitempty"`
+	RedirectURI  string `json:"redirect_uri,omitempty"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// oauthTokenLookupForm documents the introspection and revocation forms
+type oauthTokenLookupForm struct {
+	Token         string `json:"token" validate:"required"`
+	TokenTypeHint string `json:"token_type_hint,omitempty" validate:"omitempty,oneof=access_token refresh_token"`
+	ClientID      string `json:"client_id,omitempty"`
+	ClientSecret  string `json:"client_secret,omitempty"`
+}
+
+// oidcLogoutForm documents the /oauth/logout parameters
+type oidcLogoutForm struct {
+	IDTokenHint           string `json:"id_token_hint,omitempty"`
+	ClientID              string `json:"client_id,omitempty"`
+	PostLogoutRedirectURI string `json:"post_logout_redirect_uri,omitempty"`
+	State                 string `json:"state,omitempty"`
+	Confirm               string `json:"confirm,omitempty" validate:"omitempty,oneof=yes"`
+}
+
+// Shared parameters
+var (
+	pageParam  = param{name: "page", in: "query", schema: "integer", description: "Page number, starting at 1"}
+	limitParam = param{name: "limit", in: "query", schema: "integer", description: "Page size"}
+	idParam    = param{name: "id", in: "path", schema: "string", description: "Resource ID"}
+
+	userIDParam = param{name: "userId", in: "path", schema: "string", description: "User ID"}
+
+	providerParam = param{name: "provider", in: "path", schema: "string", description: "Identity provider ID"}
+
+	idempotencyKeyParam = param{name: "Idempotency-Key", in: "header", schema: "string", description: "Client-chosen unique key; retries with the same key replay the first response"}
+
+	ifMatchParam = param{name: "If-Match", in: "header", schema: "string", description: "ETag from a previous read; the write fails with 412 if the user changed since"}
+
+	userFilterParams = []param{
+		{name: "search", in: "query", schema: "string", description: "Case-insensitive match on name or email"},
+		{name: "role", in: "query", schema: "string", description: "user or admin"},
+		{name: "isActive", in: "query", schema: "boolean"},
+		{name: "includeDeleted", in: "query", schema: "boolean", description: "Include soft-deleted users (admin only)"},
+	}
+
+	auditFilterParams = []param{
+		{name: "actor", in: "query", schema: "string", description: "Actor user ID"},
+		{name: "target", in: "query", schema: "string", description: "Target user ID"},
+		{name: "action", in: "query", schema: "string", description: "Action, e.g. user.update"},
+		{name: "from", in: "query", schema: "string", description: "RFC 3339 lower bound on createdAt"},
+		{name: "to", in: "query", schema: "string", description: "RFC 3339 upper bound on createdAt"},
+	}
+)
+
+// operations documents every route in routes.SetupRoutes. Add an entry here
+// whenever a route is registered; Undocumented reports the ones missing.
+var operations = []operation{
+	// System
+	{
+		method: http.MethodGet, path: "/", tag: "System",
+		summary:  "Health check and welcome message",
+		response: "",
+	},
+	{
+		method: http.MethodGet, path: "/openapi.json", tag: "System",
+		summary: "This OpenAPI document",
+		raw:     true,
+	},
+	{
+		method: http.MethodGet, path: "/docs", tag: "System",
+		summary:     "Interactive API documentation",
+		raw:         true,
+		contentType: "text/html",
+	},
+
+	// Auth
+	{
+		method: http.MethodPost, path: "/api/auth/register", tag: "Auth",
+		summary:     "Register a new user",
+		description: "Returns 403 when INVITATION_ONLY is set; new users then join through an invitation. A password that breaks the password policy returns 400 with every violation in data.violations.",
+		params:      []param{idempotencyKeyParam},
+		request:     models.RegisterRequest{},
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusForbidden, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPost, path: "/api/auth/login", tag: "Auth",
+		summary:     "Log in and receive a JWT",
+		description: "The password is checked against the local account, then against the LDAP directory when one is configured; directory users get a local account on their first login. Returns 403 with \"password change required\" or \"password expired\" when a local password must be changed first via /api/auth/change-password.",
+		request:     models.LoginRequest{},
+		response:    authToken{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodPost, path: "/api/auth/change-password", tag: "Auth",
+		summary:     "Change password with the current password and receive a JWT",
+		description: "Also changes expired passwords. The new password must pass the password policy and may not repeat a recent one; a 400 lists every violation in data.violations.",
+		request:     models.ChangePasswordRequest{},
+		response:    authToken{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+
+	{
+		method: http.MethodPost, path: "/api/auth/set-password", tag: "Auth",
+		summary:     "Set a password with a password-set token and receive a JWT",
+		description: "The token comes from the link mailed to users created by an import with invite=true. It is single-use and expires, and is not used up when the password breaks the password policy.",
+		request:     models.SetPasswordRequest{},
+		response:    authToken{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodPost, path: "/api/auth/accept-invitation", tag: "Auth",
+		summary:     "Accept an invitation and receive a JWT",
+		description: "Creates the invited user in the invitation's organization with its email and role. The token comes from the invitation link; it is single-use and expires.",
+		request:     models.AcceptInvitationRequest{},
+		response:    authToken{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict},
+	},
+	{
+		method: http.MethodPost, path: "/api/auth/confirm-email", tag: "Auth",
+		summary:     "Confirm an email change",
+		description: "The token comes from the link mailed to the new address by POST /api/me/email. It is single-use and expires.",
+		request:     models.EmailTokenRequest{},
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict},
+	},
+	{
+		method: http.MethodPost, path: "/api/auth/revert-email", tag: "Auth",
+		summary:     "Revert an email change",
+		description: "The token comes from the notice mailed to the old address once a change is confirmed. Restores the old email and mails it a password-set link. It is single-use and expires.",
+		request:     models.EmailTokenRequest{},
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict},
+	},
+	{
+		method: http.MethodPost, path: "/api/auth/switch-org", tag: "Auth",
+		summary:     "Switch to another organization and receive a JWT for it",
+		description: "The caller must be a member of the organization; super-admins may switch to any. The token's role claim is the caller's effective role there.",
+		security:    securityBearer,
+		request:     models.SwitchOrgRequest{},
+		response:    authToken{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/auth/federated", tag: "Auth",
+		summary:  "List the identity providers users can sign in with",
+		response: []models.FederationProviderResponse{},
+	},
+	{
+		method: http.MethodPost, path: "/api/auth/federated/{provider}/start", tag: "Auth",
+		summary:     "Start a login with an external OpenID Connect provider",
+		description: "Returns the provider URL to send the user to. The provider redirects back to FEDERATION_REDIRECT_URL with code and state; the client must check that state matches the one returned here, then complete the login with POST /api/auth/federated/callback within FEDERATION_STATE_TTL_MINUTES. Also sets the HttpOnly federation_state cookie, which the callback needs.",
+		params:      []param{providerParam},
+		response:    models.FederationStartResponse{},
+		errors:      []int{http.StatusNotFound, http.StatusBadGateway},
+	},
+	{
+		method: http.MethodPost, path: "/api/auth/federated/callback", tag: "Auth",
+		summary:     "Complete a login with an external OpenID Connect provider and receive a JWT",
+		description: "The provider's ID token must be valid for the state's nonce. The user is the one the provider identity is linked to; otherwise the identity is linked to the user with the same email, which the provider must have verified and which must not be a super-admin or service account, or a user is provisioned in the default organization when the provider allows it. Each state completes one login, and only from the browser whose federation_state cookie was set when it was started; otherwise 400.",
+		request:     models.FederationCallbackRequest{},
+		response:    authToken{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict},
+	},
+
+	// Me
+	{
+		method: http.MethodGet, path: "/api/me", tag: "Me",
+		summary:     "Get the authenticated user",
+		description: "The ETag header carries the user version for use with If-Match.",
+		security:    securityBearer,
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPatch, path: "/api/me", tag: "Me",
+		summary:     "Update the authenticated user's profile with a JSON merge patch",
+		description: "Only name can be changed here; email and password have their own endpoints.",
+		security:    securityBearer,
+		params:      []param{ifMatchParam},
+		request:     userMergePatch{},
+		requestType: "application/merge-patch+json",
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnsupportedMediaType},
+	},
+	{
+		method: http.MethodDelete, path: "/api/me", tag: "Me",
+		summary:     "Close the authenticated user's account",
+		description: "Requires the password. The account is soft-deleted and purged after the retention period. The last super-admin and the last owner of an organization cannot close their account.",
+		security:    securityBearer,
+		request:     models.CloseAccountRequest{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict},
+	},
+	{
+		method: http.MethodPost, path: "/api/me/password", tag: "Me",
+		summary:     "Change the authenticated user's password",
+		description: "The new password must pass the password policy and may not repeat a recent one; a 400 lists every violation in data.violations.",
+		security:    securityBearer,
+		request:     models.ChangeOwnPasswordRequest{},
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodPost, path: "/api/me/email", tag: "Me",
+		summary:     "Request an email change",
+		description: "Requires the password. The new address is stored as pendingEmail and a confirmation link is mailed to it; the email changes once it is used with POST /api/auth/confirm-email. The current address is told about the request.",
+		security:    securityBearer,
+		request:     models.ChangeEmailRequest{},
+		status:      http.StatusAccepted,
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict},
+	},
+	{
+		method: http.MethodDelete, path: "/api/me/identities/{provider}", tag: "Me",
+		summary:     "Unlink the authenticated user's identity at an external provider",
+		description: "Signing in with the provider links the identity again when its email matches. The ldap identity of users that sign in through the LDAP directory cannot be unlinked, nor can the only identity of a user provisioned by a provider who has not set a password yet (409).",
+		security:    securityBearer,
+		params:      []param{providerParam},
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict},
+	},
+
+	// API keys
+	{
+		method: http.MethodGet, path: "/api/api-keys", tag: "API Keys",
+		summary:     "List API keys, newest first",
+		description: "Admins see every key of the organization, or one owner's with userId; other users see their own keys. Keys themselves are never returned, only their prefix.",
+		security:    securityBearer,
+		params:      []param{{name: "userId", in: "query", schema: "string", description: "Owner user ID (admin only)"}},
+		response:    []models.APIKeyResponse{},
+		errors:      []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	{
+		method: http.MethodPost, path: "/api/api-keys", tag: "API Keys",
+		summary:     "Create an API key",
+		description: "The key is returned once, in data.key, and stored hashed. It acts as its owner in the organization the request acts in, with the owner's current role, limited to its scopes: <resource>:read or <resource>:write for users, groups, orgs, invitations, jobs, audit and webhooks. Send it as \"Authorization: ApiKey <key>\". userId defaults to the caller; admins may name a service account of the organization. Keys for other people and for super-admins other than the caller are refused. API keys cannot manage API keys.",
+		security:    securityBearer,
+		params:      []param{idempotencyKeyParam},
+		request:     models.CreateAPIKeyRequest{},
+		status:      http.StatusCreated,
+		response:    models.CreatedAPIKeyResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodDelete, path: "/api/api-keys/{id}", tag: "API Keys",
+		summary:     "Revoke an API key",
+		description: "Owners revoke their own keys; admins any key of the organization. The key is kept, with revokedAt set.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		response:    models.APIKeyResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodPost, path: "/api/service-accounts", tag: "API Keys",
+		summary:     "Create a service account (admin)",
+		description: "A service account is a user of the organization, with the given organization role, that cannot sign in and acts only through its API keys. It gets a placeholder email.",
+		security:    securityBearer,
+		params:      []param{idempotencyKeyParam},
+		request:     models.CreateServiceAccountRequest{},
+		status:      http.StatusCreated,
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+
+	// Users
+	{
+		method: http.MethodGet, path: "/api/users", tag: "Users",
+		summary:     "List users with pagination",
+		description: "Admins get full profiles. Other users get their own full profile and only id and name of everyone else, may only search by name, and may not filter by role, isActive or includeDeleted.",
+		security:    securityBearer,
+		params: append([]param{pageParam, limitParam,
+			{name: "group", in: "query", schema: "string", description: "Group ID; members of the group and of its subgroups"}},
+			userFilterParams...),
+		response:  models.UserResponse{},
+		paginated: true,
+		errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		method: http.MethodGet, path: "/api/users/all", tag: "Users",
+		summary:  "List all users without pagination (admin)",
+		security: securityBearer,
+		response: allUsersResponse{},
+		raw:      true,
+		errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+	},
+	{
+		method: http.MethodPost, path: "/api/users/import", tag: "Users",
+		summary:     "Import users from CSV or NDJSON (admin)",
+		description: "The body is the file. CSV needs a header with name and email, and optionally password, role and isActive. NDJSON has one object with the same fields per line. Rows are validated one by one and failures are listed in the report. With async=true the file is imported by a background job instead: the response is 202 with the job, and the report becomes the job result.",
+		security:    securityBearer,
+		params: []param{
+			{name: "format", in: "query", schema: "string", description: "csv or ndjson; defaults from Content-Type (text/csv, application/x-ndjson)"},
+			{name: "dryRun", in: "query", schema: "boolean", description: "Validate only, write nothing"},
+			{name: "invite", in: "query", schema: "boolean", description: "Rows without a password get a password-set link by email"},
+			{name: "async", in: "query", schema: "boolean", description: "Run as a background job (202 Accepted)"},
+		},
+		request:     "",
+		requestType: "text/csv",
+		response:    models.UserImportReport{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestEntityTooLarge},
+	},
+	{
+		method: http.MethodGet, path: "/api/users/export", tag: "Users",
+		summary:     "Export users as CSV or NDJSON (admin)",
+		description: "Streams every user matching the list filters. CSV columns: id, name, email, role, isActive, createdAt, updatedAt, deletedAt.",
+		security:    securityBearer,
+		params: append([]param{
+			{name: "format", in: "query", schema: "string", description: "csv (default) or ndjson"},
+		}, userFilterParams...),
+		response:    "",
+		raw:         true,
+		contentType: "text/csv",
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodPost, path: "/api/users/export", tag: "Users",
+		summary:     "Export users to a file with a background job (admin)",
+		description: "Takes the same parameters as GET /api/users/export. Poll the job; once it succeeded, resultUrl downloads the file.",
+		security:    securityBearer,
+		params: append([]param{
+			{name: "format", in: "query", schema: "string", description: "csv (default) or ndjson"},
+			idempotencyKeyParam,
+		}, userFilterParams...),
+		status:   http.StatusAccepted,
+		response: models.JobResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPost, path: "/api/users/deactivate", tag: "Users",
+		summary:     "Deactivate many users with a background job (admin)",
+		description: "Deactivates the active users matching every given criterion. The requesting admin is skipped. The job result counts deactivated, skipped and failed users.",
+		security:    securityBearer,
+		params:      []param{idempotencyKeyParam},
+		request:     models.DeactivateUsersRequest{},
+		status:      http.StatusAccepted,
+		response:    models.JobResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/users/{id}", tag: "Users",
+		summary:     "Get a user",
+		description: "Admins and the user themselves get the full profile, with an ETag header carrying the user version for use with If-Match. Other users get only id and name.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/api/users/{id}", tag: "Users",
+		summary:     "Update a user",
+		description: "Users may update their own account; admins may update other accounts, except that only super-admins update super-admins and only owners update owners and admins. Empty fields are left unchanged. A new email is only stored as pendingEmail until it is confirmed, as with POST /api/me/email. Users change their own email and password with /api/me instead.",
+		security:    securityBearer,
+		params:      []param{idParam, ifMatchParam},
+		request:     models.UpdateUserRequest{},
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed},
+	},
+	{
+		method: http.MethodPatch, path: "/api/users/{id}", tag: "Users",
+		summary:     "Partially update a user with a JSON merge patch",
+		description: "RFC 7396 merge patch: only the members sent are changed and null removes a member (role falls back to user). A new email is only stored as pendingEmail until it is confirmed; users change their own email and password with /api/me. Returns 409 if a concurrent edit won the race.",
+		security:    securityBearer,
+		params:      []param{idParam, ifMatchParam},
+		request:     userMergePatch{},
+		requestType: "application/merge-patch+json",
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnsupportedMediaType},
+	},
+	{
+		method: http.MethodDelete, path: "/api/users/{id}", tag: "Users",
+		summary:     "Soft-delete a user (admin)",
+		description: "Only super-admins delete super-admins, and only owners delete owners and admins.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		errors:      []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/users/{id}/restore", tag: "Users",
+		summary:  "Restore a soft-deleted user (admin)",
+		security: securityBearer,
+		params:   []param{idParam, idempotencyKeyParam},
+		response: models.UserResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/users/{id}/groups", tag: "Users",
+		summary:     "List the groups a user belongs to",
+		description: "Direct memberships carry the user's group role. Ancestors of those groups are listed with inherited: true.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		response:    []models.UserGroupResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+
+	// Audit
+	{
+		method: http.MethodGet, path: "/api/audit", tag: "Audit",
+		summary:   "List audit events (super-admin)",
+		security:  securityBearer,
+		params:    append(append([]param{}, auditFilterParams...), pageParam, limitParam),
+		response:  models.AuditEvent{},
+		paginated: true,
+		errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodGet, path: "/api/audit/export", tag: "Audit",
+		summary:     "Export audit events as NDJSON (super-admin)",
+		security:    securityBearer,
+		params:      auditFilterParams,
+		response:    models.AuditEvent{},
+		raw:         true,
+		contentType: contentNDJSON,
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+
+	// Invitations
+	{
+		method: http.MethodGet, path: "/api/invitations", tag: "Invitations",
+		summary:  "List invitations, newest first (admin)",
+		security: securityBearer,
+		params: []param{pageParam, limitParam,
+			{name: "email", in: "query", schema: "string", description: "Exact email of the invitee"},
+			{name: "status", in: "query", schema: "string", description: "pending, accepted, revoked or expired"}},
+		response:  models.InvitationResponse{},
+		paginated: true,
+		errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodPost, path: "/api/invitations", tag: "Invitations",
+		summary:     "Invite a user into the organization by email with a role (admin)",
+		description: "Mails a single-use link to the invitee, who joins with the given organization role (admin or member). When the email cannot be sent the invitation is still created, with lastSendError set.",
+		security:    securityBearer,
+		params:      []param{idempotencyKeyParam},
+		request:     models.CreateInvitationRequest{},
+		status:      http.StatusCreated,
+		response:    models.InvitationResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/invitations/{id}", tag: "Invitations",
+		summary:  "Get an invitation (admin)",
+		security: securityBearer,
+		params:   []param{idParam},
+		response: models.InvitationResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/invitations/{id}/resend", tag: "Invitations",
+		summary:     "Resend a pending invitation with a fresh link (admin)",
+		description: "Renews the expiry; links sent earlier stop working.",
+		security:    securityBearer,
+		params:      []param{idParam, idempotencyKeyParam},
+		response:    models.InvitationResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPost, path: "/api/invitations/{id}/revoke", tag: "Invitations",
+		summary:  "Revoke a pending invitation (admin)",
+		security: securityBearer,
+		params:   []param{idParam},
+		response: models.InvitationResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+	},
+
+	// Organizations
+	{
+		method: http.MethodGet, path: "/api/orgs", tag: "Organizations",
+		summary:     "List organizations",
+		description: "Super-admins see every organization; other users see the ones they belong to, with their role in each.",
+		security:    securityBearer,
+		params:      []param{pageParam, limitParam},
+		response:    models.OrganizationResponse{},
+		paginated:   true,
+		errors:      []int{http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodPost, path: "/api/orgs", tag: "Organizations",
+		summary:     "Create an organization (super-admin)",
+		description: "The slug defaults to one derived from the name. ownerEmail names an existing user who becomes the owner.",
+		security:    securityBearer,
+		params:      []param{idempotencyKeyParam},
+		request:     models.CreateOrganizationRequest{},
+		status:      http.StatusCreated,
+		response:    models.Organization{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/orgs/{id}", tag: "Organizations",
+		summary:  "Get an organization the caller belongs to",
+		security: securityBearer,
+		params:   []param{idParam},
+		response: models.OrganizationResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/api/orgs/{id}", tag: "Organizations",
+		summary:  "Rename an organization (owner)",
+		security: securityBearer,
+		params:   []param{idParam},
+		request:  models.UpdateOrganizationRequest{},
+		response: models.Organization{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/orgs/{id}/members", tag: "Organizations",
+		summary:     "List the members of an organization",
+		description: "Owners, admins and super-admins get full profiles. Members get only id and name of the others and may only search by name.",
+		security:    securityBearer,
+		params:      append([]param{idParam, pageParam, limitParam}, userFilterParams...),
+		response:    models.UserResponse{},
+		paginated:   true,
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/orgs/{id}/members", tag: "Organizations",
+		summary:     "Add an existing user to an organization (super-admin)",
+		description: "The role defaults to member. Owners and admins invite new members instead.",
+		security:    securityBearer,
+		params:      []param{idParam, idempotencyKeyParam},
+		request:     models.AddMemberRequest{},
+		status:      http.StatusCreated,
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPut, path: "/api/orgs/{id}/members/{userId}", tag: "Organizations",
+		summary:     "Change a member's organization role (owner or admin)",
+		description: "Only owners may grant or revoke the owner role. The last owner cannot be demoted.",
+		security:    securityBearer,
+		params:      []param{idParam, userIDParam},
+		request:     models.UpdateMemberRequest{},
+		response:    models.UserResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodDelete, path: "/api/orgs/{id}/members/{userId}", tag: "Organizations",
+		summary:     "Remove a member from an organization (owner or admin)",
+		description: "Members may remove themselves. Users cannot be removed from their home organization, and the last owner cannot leave.",
+		security:    securityBearer,
+		params:      []param{idParam, userIDParam},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+	},
+
+	// Groups
+	{
+		method: http.MethodGet, path: "/api/groups", tag: "Groups",
+		summary:  "List the groups of the organization",
+		security: securityBearer,
+		params: []param{pageParam, limitParam,
+			{name: "search", in: "query", schema: "string", description: "Case-insensitive match on the name"},
+			{name: "parentId", in: "query", schema: "string", description: "Direct subgroups of this group; root for top-level groups"}},
+		response:  models.Group{},
+		paginated: true,
+		errors:    []int{http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodPost, path: "/api/groups", tag: "Groups",
+		summary:     "Create a group (admin)",
+		description: "parentId makes the group a subgroup; members of a subgroup count as members of its ancestors.",
+		security:    securityBearer,
+		params:      []param{idempotencyKeyParam},
+		request:     models.CreateGroupRequest{},
+		status:      http.StatusCreated,
+		response:    models.Group{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/groups/{id}", tag: "Groups",
+		summary:  "Get a group",
+		security: securityBearer,
+		params:   []param{idParam},
+		response: models.Group{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/api/groups/{id}", tag: "Groups",
+		summary:     "Update or move a group (admin)",
+		description: "Omitted fields are left unchanged. An empty parentId moves the group to the top level; moving a group below one of its own subgroups returns 409.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		request:     models.UpdateGroupRequest{},
+		response:    models.Group{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodDelete, path: "/api/groups/{id}", tag: "Groups",
+		summary:     "Delete a group (admin)",
+		description: "Groups with subgroups cannot be deleted. The group's memberships are removed with it.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodGet, path: "/api/groups/{id}/members", tag: "Groups",
+		summary:     "List the direct members of a group",
+		description: "email and isActive are only included for admins and for the caller's own membership.",
+		security:    securityBearer,
+		params:      []param{idParam, pageParam, limitParam},
+		response:    models.GroupMemberResponse{},
+		paginated:   true,
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/groups/{id}/members", tag: "Groups",
+		summary:     "Add users to a group (admin or group admin)",
+		description: "Up to 500 users of the organization per request. Existing members get the given role. The role defaults to member; only admins may make group admins. Admins of a group also manage the members of its subgroups.",
+		security:    securityBearer,
+		params:      []param{idParam, idempotencyKeyParam},
+		request:     models.GroupMembersRequest{},
+		response:    models.GroupMembersResult{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodPost, path: "/api/groups/{id}/members/remove", tag: "Groups",
+		summary:     "Remove users from a group (admin or group admin)",
+		description: "Up to 500 users per request; role is ignored. Users who are not direct members are counted as skipped.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		request:     models.GroupMembersRequest{},
+		response:    models.GroupMembersResult{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+
+	// Jobs
+	{
+		method: http.MethodGet, path: "/api/jobs", tag: "Jobs",
+		summary:  "List background jobs, newest first (admin)",
+		security: securityBearer,
+		params: []param{pageParam, limitParam,
+			{name: "type", in: "query", schema: "string", description: "user.import, user.export or user.deactivate"},
+			{name: "status", in: "query", schema: "string", description: "queued, running, succeeded, failed or cancelled"}},
+		response:  models.JobResponse{},
+		paginated: true,
+		errors:    []int{http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodGet, path: "/api/jobs/{id}", tag: "Jobs",
+		summary:     "Get a job's status, progress and result (admin)",
+		description: "Poll this until the status is succeeded, failed or cancelled.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		response:    models.JobResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/jobs/{id}/cancel", tag: "Jobs",
+		summary:     "Cancel a job (admin)",
+		description: "A queued job is cancelled at once. A running job gets cancelRequested and stops at its next heartbeat.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		response:    models.JobResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodGet, path: "/api/jobs/{id}/result", tag: "Jobs",
+		summary:     "Download the result file of a succeeded job (admin)",
+		security:    securityBearer,
+		params:      []param{idParam},
+		response:    "",
+		raw:         true,
+		contentType: "application/octet-stream",
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+
+	// Webhooks
+	{
+		method: http.MethodGet, path: "/api/webhooks", tag: "Webhooks",
+		summary:  "List webhook subscriptions (super-admin)",
+		security: securityBearer,
+		response: []models.WebhookSubscription{},
+		errors:   []int{http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodPost, path: "/api/webhooks", tag: "Webhooks",
+		summary:     "Create a webhook subscription (super-admin)",
+		description: "The signing secret is only returned in this response.",
+		security:    securityBearer,
+		params:      []param{idempotencyKeyParam},
+		request:     models.CreateWebhookRequest{},
+		status:      http.StatusCreated,
+		response:    createdWebhook{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+	{
+		method: http.MethodGet, path: "/api/webhooks/{id}", tag: "Webhooks",
+		summary:  "Get a webhook subscription (super-admin)",
+		security: securityBearer,
+		params:   []param{idParam},
+		response: models.WebhookSubscription{},
+		errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/api/webhooks/{id}", tag: "Webhooks",
+		summary:  "Update a webhook subscription (super-admin)",
+		security: securityBearer,
+		params:   []param{idParam},
+		request:  models.UpdateWebhookRequest{},
+		response: models.WebhookSubscription{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodDelete, path: "/api/webhooks/{id}", tag: "Webhooks",
+		summary:  "Delete a webhook subscription (super-admin)",
+		security: securityBearer,
+		params:   []param{idParam},
+		errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/webhooks/{id}/deliveries", tag: "Webhooks",
+		summary:  "List deliveries of a subscription (super-admin)",
+		security: securityBearer,
+		params: []param{idParam, pageParam, limitParam,
+			{name: "status", in: "query", schema: "string", description: "pending, delivered or dead"}},
+		response:  models.WebhookDelivery{},
+		paginated: true,
+		errors:    []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/api/webhooks/{id}/deliveries/{deliveryId}", tag: "Webhooks",
+		summary:  "Get a delivery with its attempt log (super-admin)",
+		security: securityBearer,
+		params:   []param{idParam, {name: "deliveryId", in: "path", schema: "string"}},
+		response: models.WebhookDelivery{},
+		errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/api/webhooks/{id}/deliveries/{deliveryId}/redeliver", tag: "Webhooks",
+		summary:  "Queue a delivery to be sent again (super-admin)",
+		security: securityBearer,
+		params:   []param{idParam, {name: "deliveryId", in: "path", schema: "string"}, idempotencyKeyParam},
+		response: models.WebhookDelivery{},
+		errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+	},
+
+	// SCIM (registered only when SCIM_TOKEN is set)
+	{
+		method: http.MethodGet, path: "/scim/v2/ServiceProviderConfig", tag: "SCIM",
+		summary:  "SCIM service provider configuration",
+		security: securitySCIM,
+		raw:      true, contentType: contentSCIM,
+		errors: []int{http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodGet, path: "/scim/v2/ResourceTypes", tag: "SCIM",
+		summary:  "SCIM resource types",
+		security: securitySCIM,
+		raw:      true, contentType: contentSCIM,
+		errors: []int{http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodGet, path: "/scim/v2/Schemas", tag: "SCIM",
+		summary:  "SCIM schemas",
+		security: securitySCIM,
+		raw:      true, contentType: contentSCIM,
+		errors: []int{http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodGet, path: "/scim/v2/Users", tag: "SCIM",
+		summary:  "List provisioned users",
+		security: securitySCIM,
+		params: []param{
+			{name: "filter", in: "query", schema: "string", description: `SCIM filter, e.g. userName eq "jane@example.com"`},
+			{name: "startIndex", in: "query", schema: "integer", description: "1-based index of the first result"},
+			{name: "count", in: "query", schema: "integer", description: "Page size, at most 200"},
+		},
+		response: models.SCIMListResponse{},
+		raw:      true, contentType: contentSCIM,
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodPost, path: "/scim/v2/Users", tag: "SCIM",
+		summary:  "Provision a user",
+		security: securitySCIM,
+		request:  models.SCIMUser{},
+		status:   http.StatusCreated,
+		response: models.SCIMUser{},
+		raw:      true, contentType: contentSCIM,
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict},
+	},
+	{
+		method: http.MethodGet, path: "/scim/v2/Users/{id}", tag: "SCIM",
+		summary:  "Get a provisioned user",
+		security: securitySCIM,
+		params:   []param{idParam},
+		response: models.SCIMUser{},
+		raw:      true, contentType: contentSCIM,
+		errors: []int{http.StatusUnauthorized, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/scim/v2/Users/{id}", tag: "SCIM",
+		summary:  "Replace a provisioned user",
+		security: securitySCIM,
+		params:   []param{idParam},
+		request:  models.SCIMUser{},
+		response: models.SCIMUser{},
+		raw:      true, contentType: contentSCIM,
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodPatch, path: "/scim/v2/Users/{id}", tag: "SCIM",
+		summary:  "Patch a provisioned user",
+		security: securitySCIM,
+		params:   []param{idParam},
+		request:  models.SCIMPatchRequest{},
+		response: models.SCIMUser{},
+		raw:      true, contentType: contentSCIM,
+		errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict},
+	},
+	{
+		method: http.MethodDelete, path: "/scim/v2/Users/{id}", tag: "SCIM",
+		summary:  "Deprovision (soft-delete) a user",
+		security: securitySCIM,
+		params:   []param{idParam},
+		status:   http.StatusNoContent,
+		raw:      true,
+		errors:   []int{http.StatusUnauthorized, http.StatusNotFound},
+	},
+
+	// OAuth client registration
+	{
+		method: http.MethodGet, path: "/api/oauth/clients", tag: "OAuth Clients",
+		summary:  "List OAuth clients, newest first (super-admin)",
+		security: securityBearer,
+		response: []models.OAuthClientResponse{},
+		errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError},
+	},
+	{
+		method: http.MethodPost, path: "/api/oauth/clients", tag: "OAuth Clients",
+		summary:     "Register an OAuth client (super-admin)",
+		description: "Confidential clients get a secret, returned once in data.clientSecret and stored hashed; public clients (single-page and native apps) get none and must use PKCE. Grant types are authorization_code, refresh_token and client_credentials, which public clients cannot use. Redirect URIs and post-logout redirect URIs must be https, or http on the loopback interface, and are matched exactly. Register the openid, profile and email scopes for OpenID Connect.",
+		security:    securityBearer,
+		params:      []param{idempotencyKeyParam},
+		request:     models.OAuthClientRequest{},
+		status:      http.StatusCreated,
+		response:    models.CreatedOAuthClientResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodGet, path: "/api/oauth/clients/{id}", tag: "OAuth Clients",
+		summary:  "Get an OAuth client (super-admin)",
+		security: securityBearer,
+		params:   []param{idParam},
+		response: models.OAuthClientResponse{},
+		errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/api/oauth/clients/{id}", tag: "OAuth Clients",
+		summary:     "Update an OAuth client (super-admin)",
+		description: "Replaces the name, redirect URIs, grant types and scopes. Whether the client is public cannot change.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		request:     models.OAuthClientRequest{},
+		response:    models.OAuthClientResponse{},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+	{
+		method: http.MethodDelete, path: "/api/oauth/clients/{id}", tag: "OAuth Clients",
+		summary:     "Delete an OAuth client (super-admin)",
+		description: "Its authorization codes and refresh tokens are deleted; access tokens already issued stay valid until they expire.",
+		security:    securityBearer,
+		params:      []param{idParam},
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound},
+	},
+
+	// OAuth 2.0 authorization server
+	{
+		method: http.MethodGet, path: "/oauth/authorize", tag: "OAuth",
+		summary:     "Authorization endpoint: show the consent page",
+		description: "Starts the authorization code flow. Shows a page where the user signs in, unless a session cookie from an earlier sign-in is valid, and allows or denies the client. Public clients must send a S256 code_challenge (PKCE). Requests with an unknown client or redirect URI get an error page; other errors are redirected to the client with error and state.",
+		params: []param{
+			{name: "response_type", in: "query", schema: "string", description: "code"},
+			{name: "client_id", in: "query", schema: "string"},
+			{name: "redirect_uri", in: "query", schema: "string", description: "One of the client's redirect URIs; optional when it has only one"},
+			{name: "scope", in: "query", schema: "string", description: "Space-delimited scopes, a subset of the client's; all of them when omitted"},
+			{name: "state", in: "query", schema: "string", description: "Returned to the client unchanged"},
+			{name: "code_challenge", in: "query", schema: "string", description: "BASE64URL(SHA256(code_verifier))"},
+			{name: "code_challenge_method", in: "query", schema: "string", description: "S256"},
+			{name: "nonce", in: "query", schema: "string", description: "Copied into the ID token when the openid scope is granted"},
+		},
+		raw:         true,
+		contentType: "text/html",
+		errors:      []int{http.StatusBadRequest},
+	},
+	{
+		method: http.MethodPost, path: "/oauth/authorize", tag: "OAuth",
+		summary:     "Authorization endpoint: submit the consent page",
+		description: "Signs the user in with email and password when they have no session, then redirects (302) to the redirect URI with code and state, or with error=access_denied when the user denied the request. Codes are single-use and expire after OAUTH_CODE_TTL_SECONDS. A failed sign-in shows the page again with 401. The form must carry the csrf_token of a consent page rendered for the same browser; otherwise the page is shown again with 403.",
+		request:     oauthAuthorizeForm{},
+		requestType: contentForm,
+		status:      http.StatusFound,
+		raw:         true,
+		contentType: "text/html",
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodPost, path: "/oauth/token", tag: "OAuth",
+		summary:     "Token endpoint",
+		description: "With the openid scope, the code and refresh token grants also return an RS256-signed ID token in id_token. authorization_code exchanges a code, with the redirect_uri it was issued for and the PKCE code_verifier, for an access token and, when the client may use the refresh_token grant, a refresh token. A code only works for the client it was issued to, once; redeeming it again revokes the tokens issued from it. refresh_token rotates the refresh token and may narrow the scope. client_credentials issues an access token for the client itself, without a refresh token. Access tokens are JWTs whose aud is the client ID; they are meant for the client's resource servers, which check them with POST /oauth/introspect, and are not accepted by this API.",
+		security:    securityOAuth,
+		request:     oauthTokenForm{},
+		requestType: contentForm,
+		response:    models.OAuthTokenResponse{},
+		raw:         true,
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodPost, path: "/oauth/introspect", tag: "OAuth",
+		summary:     "Token introspection (RFC 7662)",
+		description: "Confidential clients only. Reports whether an access token or refresh token is active, with its scope, client, subject and expiry; revoked, expired and unknown tokens only report active: false.",
+		security:    securityOAuth,
+		request:     oauthTokenLookupForm{},
+		requestType: contentForm,
+		response:    models.OAuthIntrospection{},
+		raw:         true,
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	{
+		method: http.MethodPost, path: "/oauth/revoke", tag: "OAuth",
+		summary:     "Token revocation (RFC 7009)",
+		description: "Revoking a refresh token ends its grant: the refresh and access tokens issued from the same authorization are revoked, while the user's other grants to the client stay valid. Revoking an access token makes introspection report it inactive. Revocations are audited as oauth.token.revoke. Unknown tokens and tokens of other clients are ignored; the response is 200 either way.",
+		security:    securityOAuth,
+		request:     oauthTokenLookupForm{},
+		requestType: contentForm,
+		raw:         true,
+		errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+
+	// OpenID Connect
+	{
+		method: http.MethodGet, path: "/.well-known/openid-configuration", tag: "OpenID Connect",
+		summary:     "OpenID Provider metadata",
+		description: "OpenID Connect Discovery 1.0 document; endpoint URLs are built from OAUTH_ISSUER.",
+		response:    models.OIDCDiscovery{},
+		raw:         true,
+	},
+	{
+		method: http.MethodGet, path: "/oauth/jwks", tag: "OpenID Connect",
+		summary:     "Keys ID tokens are signed with",
+		description: "JSON Web Key Set with the RSA public key; kid is its RFC 7638 thumbprint.",
+		response:    models.JSONWebKeySet{},
+		raw:         true,
+	},
+	{
+		method: http.MethodGet, path: "/oauth/userinfo", tag: "OpenID Connect",
+		summary:     "Claims about the signed-in user",
+		description: "Needs an access token granted the openid scope. sub is always returned; name and role (in the organization the token acts in) with the profile scope, email with the email scope.",
+		security:    securityOIDC,
+		response:    models.OIDCUserInfo{},
+		raw:         true,
+		errors:      []int{http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodPost, path: "/oauth/userinfo", tag: "OpenID Connect",
+		summary:     "Claims about the signed-in user",
+		description: "Same as GET.",
+		security:    securityOIDC,
+		response:    models.OIDCUserInfo{},
+		raw:         true,
+		errors:      []int{http.StatusUnauthorized, http.StatusForbidden},
+	},
+	{
+		method: http.MethodGet, path: "/oauth/logout", tag: "OpenID Connect",
+		summary:     "End session endpoint (RP-initiated logout)",
+		description: "Signs the user out of the authorization server. With an id_token_hint for the signed-in user it does so at once; otherwise it shows a page asking them to confirm. Then it redirects (302) to post_logout_redirect_uri with state, when one of the client's registered post-logout redirect URIs is given, or shows a signed-out page. Tokens already issued to clients stay valid; revoke them with POST /oauth/revoke.",
+		params: []param{
+			{name: "id_token_hint", in: "query", schema: "string", description: "An ID token issued to the client; expired ones are accepted"},
+			{name: "client_id", in: "query", schema: "string", description: "Needed with post_logout_redirect_uri when there is no id_token_hint"},
+			{name: "post_logout_redirect_uri", in: "query", schema: "string"},
+			{name: "state", in: "query", schema: "string", description: "Returned to the client unchanged"},
+		},
+		raw:         true,
+		contentType: "text/html",
+		errors:      []int{http.StatusBadRequest},
+	},
+	{
+		method: http.MethodPost, path: "/oauth/logout", tag: "OpenID Connect",
+		summary:     "End session endpoint (RP-initiated logout)",
+		description: "Same as GET with the parameters in the form body. confirm=yes, sent by the confirmation page, signs the user out without an id_token_hint.",
+		request:     oidcLogoutForm{},
+		requestType: contentForm,
+		raw:         true,
+		contentType: "text/html",
+		errors:      []int{http.StatusBadRequest},
+	},
+}
@@ -0,0 +1,212 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	objectIDType   = reflect.TypeOf(primitive.ObjectID{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaRegistry generates JSON Schemas for Go types and collects named
+// struct schemas under components/schemas
+type schemaRegistry struct {
+	schemas map[string]interface{}
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: map[string]interface{}{}}
+}
+
+// ref returns a schema for v, registering named structs as components
+func (r *schemaRegistry) ref(v interface{}) map[string]interface{} {
+	return r.schemaFor(reflect.TypeOf(v))
+}
+
+func (r *schemaRegistry) schemaFor(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case objectIDType:
+		return map[string]interface{}{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+	case rawMessageType:
+		return map[string]interface{}{} // Any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": r.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": r.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		name := schemaName(t)
+		if name == "" {
+			return r.structSchema(t)
+		}
+		if _, exists := r.schemas[name]; !exists {
+			r.schemas[name] = map[string]interface{}{} // Placeholder breaks recursion
+			r.schemas[name] = r.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+
+	return map[string]interface{}{}
+}
+
+// structSchema builds an object schema from json and validate struct tags
+func (r *schemaRegistry) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitEmpty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		// Inline embedded structs without a JSON name
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Ptr {
+				embeddedType = embeddedType.Elem()
+			}
+			embedded := r.structSchema(embeddedType)
+			if props, ok := embedded["properties"].(map[string]interface{}); ok {
+				for k, v := range props {
+					properties[k] = v
+				}
+			}
+			continue
+		}
+
+		schema := r.schemaFor(field.Type)
+		if applyValidateTag(schema, field.Tag.Get("validate"), field.Type) {
+			required = append(required, name)
+		} else if !omitEmpty && field.Type.Kind() != reflect.Ptr && field.Tag.Get("validate") == "" {
+			// Response fields that are always present
+			required = append(required, name)
+		}
+		properties[name] = schema
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// applyValidateTag translates validate rules into JSON Schema keywords and
+// reports whether the field is required
+func applyValidateTag(schema map[string]interface{}, tag string, t reflect.Type) bool {
+	if tag == "" {
+		return false
+	}
+
+	// $ref schemas cannot carry sibling constraints
+	if _, isRef := schema["$ref"]; isRef {
+		return strings.Contains(tag, "required")
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	isCollection := t.Kind() == reflect.Slice || t.Kind() == reflect.Map
+
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			schema["format"] = "email"
+		case "url":
+			schema["format"] = "uri"
+		case "oneof":
+			schema["enum"] = strings.Fields(value)
+		case "min", "max":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			switch {
+			case isCollection && key == "min":
+				schema["minItems"] = n
+			case isCollection:
+				schema["maxItems"] = n
+			case t.Kind() == reflect.String && key == "min":
+				schema["minLength"] = n
+			case t.Kind() == reflect.String:
+				schema["maxLength"] = n
+			case key == "min":
+				schema["minimum"] = n
+			default:
+				schema["maximum"] = n
+			}
+		}
+	}
+
+	return required
+}
+
+// jsonName returns the JSON property name of a struct field
+func jsonName(field reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+// schemaName returns the component name of a named struct type, e.g. UserResponse
+func schemaName(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	name := t.Name()
+	// Doc-only types in this package are lowercase; capitalise them
+	return strings.ToUpper(name[:1]) + name[1:]
+}
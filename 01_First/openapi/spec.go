@@ -0,0 +1,316 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"user-management-system/models"
+	"user-management-system/utils"
+)
+
+// Security schemes referenced by operations
+const (
+	securityBearer = "bearerAuth"
+	securityAPIKey = "apiKeyAuth"
+	securitySCIM   = "scimBearer"
+	securityOAuth  = "oauthClient"
+	securityOIDC   = "oauthAccessToken"
+)
+
+// Response content types other than application/json
+const (
+	contentNDJSON = "application/x-ndjson"
+	contentSCIM   = "application/scim+json"
+	contentForm   = "application/x-www-form-urlencoded"
+)
+
+// param documents a path or query parameter
+type param struct {
+	name        string
+	in          string // path, query or header
+	schema      string // string, integer or boolean
+	description string
+}
+
+// operation documents one route registered in routes.SetupRoutes
+type operation struct {
+	method      string
+	path        string
+	tag         string
+	summary     string
+	description string
+	security    string // Empty for public routes
+	params      []param
+	request     interface{} // Request body model, nil when there is none
+	requestType string      // Request content type, application/json when empty
+	status      int         // Success status, 200 when zero
+	response    interface{} // Payload of the success envelope, nil when there is none
+	paginated   bool        // response is the item type of a PaginatedResponse
+	raw         bool        // response is written as-is, without an envelope
+	contentType string      // Response content type, application/json when empty
+	errors      []int
+}
+
+var (
+	specOnce sync.Once
+	specJSON []byte
+)
+
+// JSON returns the OpenAPI document, built once and cached
+func JSON() []byte {
+	specOnce.Do(func() {
+		data, err := json.MarshalIndent(Build(), "", "  ")
+		if err != nil {
+			panic("openapi: " + err.Error())
+		}
+		specJSON = data
+	})
+	return specJSON
+}
+
+// Build assembles the OpenAPI 3.1 document from the operation table
+func Build() map[string]interface{} {
+	registry := newSchemaRegistry()
+	registry.schemas["ErrorResponse"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"success": map[string]interface{}{"type": "boolean", "const": false},
+			"error":   map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"success", "error"},
+	}
+	registry.ref(utils.Response{})
+	registry.ref(utils.PaginatedResponse{})
+	registry.ref(models.SCIMError{})
+	registry.ref(models.OAuthError{})
+
+	paths := map[string]interface{}{}
+	for _, op := range operations {
+		item, ok := paths[op.path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = op.build(registry)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "User Management API",
+			"version":     "1.0.0",
+			"description": "REST API for user management with JWT authentication, audit log, webhooks and SCIM 2.0 provisioning.",
+		},
+		"tags":  tags(),
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": registry.schemas,
+			"securitySchemes": map[string]interface{}{
+				securityBearer: map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+					"description":  "Token returned by POST /api/auth/login. Requests act in the token's organization; super-admins may send X-Org-ID to act in another one, or * for all.",
+				},
+				securityAPIKey: map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": "\"ApiKey <key>\" with a key from POST /api/api-keys, accepted instead of a JWT by the users, groups, orgs, invitations, jobs, audit and webhooks routes. Requests act as the key's owner in the key's organization and need the <resource>:read scope for GET, <resource>:write otherwise.",
+				},
+				securitySCIM: map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Static token configured with SCIM_TOKEN",
+				},
+				securityOAuth: map[string]interface{}{
+					"type":        "http",
+					"scheme":      "basic",
+					"description": "OAuth client ID and secret from POST /api/oauth/clients. Clients may send client_id and client_secret in the form body instead; public clients send only client_id.",
+				},
+				securityOIDC: map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+					"description":  "Access token issued to an OAuth client by POST /oauth/token with the openid scope",
+				},
+			},
+		},
+	}
+}
+
+// build renders the operation object
+func (op operation) build(registry *schemaRegistry) map[string]interface{} {
+	out := map[string]interface{}{
+		"tags":        []string{op.tag},
+		"summary":     op.summary,
+		"operationId": operationID(op),
+	}
+	if op.description != "" {
+		out["description"] = op.description
+	}
+	if op.security != "" {
+		security := []map[string][]string{{op.security: {}}}
+		if op.security == securityBearer && acceptsAPIKey(op.path) {
+			security = append(security, map[string][]string{securityAPIKey: {}})
+		}
+		out["security"] = security
+	}
+
+	var params []map[string]interface{}
+	for _, p := range op.params {
+		entry := map[string]interface{}{
+			"name":   p.name,
+			"in":     p.in,
+			"schema": map[string]interface{}{"type": p.schema},
+		}
+		if p.in == "path" {
+			entry["required"] = true
+		}
+		if p.description != "" {
+			entry["description"] = p.description
+		}
+		params = append(params, entry)
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	if op.request != nil {
+		contentType := op.requestType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		if op.tag == "SCIM" {
+			contentType = contentSCIM
+		}
+		out["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				contentType: map[string]interface{}{"schema": registry.ref(op.request)},
+			},
+		}
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if status != http.StatusNoContent {
+		contentType := op.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		success["content"] = map[string]interface{}{
+			contentType: map[string]interface{}{"schema": op.responseSchema(registry)},
+		}
+	}
+
+	responses := map[string]interface{}{strconv.Itoa(status): success}
+	for _, code := range op.errors {
+		errorSchema := map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"}
+		contentType := "application/json"
+		if op.tag == "SCIM" {
+			errorSchema = map[string]interface{}{"$ref": "#/components/schemas/SCIMError"}
+			contentType = contentSCIM
+		}
+		if op.tag == "OAuth" || op.tag == "OpenID Connect" {
+			errorSchema = map[string]interface{}{"$ref": "#/components/schemas/OAuthError"}
+		}
+		responses[strconv.Itoa(code)] = map[string]interface{}{
+			"description": http.StatusText(code),
+			"content": map[string]interface{}{
+				contentType: map[string]interface{}{"schema": errorSchema},
+			},
+		}
+	}
+	out["responses"] = responses
+
+	return out
+}
+
+// acceptsAPIKey reports whether routes under path accept API keys as well as JWTs
+func acceptsAPIKey(path string) bool {
+	for _, resource := range models.APIKeyResources {
+		if path == "/api/"+resource || strings.HasPrefix(path, "/api/"+resource+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// responseSchema wraps the payload schema in the Response or PaginatedResponse envelope
+func (op operation) responseSchema(registry *schemaRegistry) map[string]interface{} {
+	if op.raw {
+		if op.response == nil {
+			return map[string]interface{}{}
+		}
+		return registry.ref(op.response)
+	}
+
+	if op.paginated {
+		return map[string]interface{}{
+			"allOf": []interface{}{
+				map[string]interface{}{"$ref": "#/components/schemas/PaginatedResponse"},
+				map[string]interface{}{
+					"properties": map[string]interface{}{
+						"data": map[string]interface{}{"type": "array", "items": registry.ref(op.response)},
+					},
+				},
+			},
+		}
+	}
+
+	if op.response == nil {
+		return map[string]interface{}{"$ref": "#/components/schemas/Response"}
+	}
+
+	return map[string]interface{}{
+		"allOf": []interface{}{
+			map[string]interface{}{"$ref": "#/components/schemas/Response"},
+			map[string]interface{}{
+				"properties": map[string]interface{}{"data": registry.ref(op.response)},
+			},
+		},
+	}
+}
+
+// operationID derives a stable identifier such as getApiUsersId
+func operationID(op operation) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(op.method))
+	for _, part := range strings.FieldsFunc(op.path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '-' || r == '.'
+	}) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+// tags lists operation tags in the order they first appear
+func tags() []map[string]string {
+	seen := map[string]bool{}
+	var out []map[string]string
+	for _, op := range operations {
+		if !seen[op.tag] {
+			seen[op.tag] = true
+			out = append(out, map[string]string{"name": op.tag})
+		}
+	}
+	return out
+}
+
+// Documented reports whether method and path have a spec entry
+func Documented(method, path string) bool {
+	for _, op := range operations {
+		if op.method == method && op.path == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(collection *mongo.Collection) *APIKeyRepository {
+	repo := &APIKeyRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates the key lookup indexes and the owner listing index
+func (r *APIKeyRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "keyHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "prefix", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "orgId", Value: 1}, {Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// Create inserts a new API key
+func (r *APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	key.ID = primitive.NewObjectID()
+	key.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, key)
+	return err
+}
+
+// FindByHash finds a key by the SHA-256 of its value, in any organization
+func (r *APIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.collection.FindOne(ctx, bson.M{"keyHash": keyHash}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("API key not found")
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// FindByID finds a key of the organization ctx acts in by its ID
+func (r *APIKeyRepository) FindByID(ctx context.Context, id string) (*models.APIKey, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid API key ID")
+	}
+
+	var key models.APIKey
+	err = r.collection.FindOne(ctx, scopedToOrg(ctx, bson.M{"_id": objectID})).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("API key not found")
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// Find retrieves the keys of the organization ctx acts in, newest first,
+// limited to one owner when userID is set
+func (r *APIKeyRepository) Find(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	query := bson.M{}
+	if userID != "" {
+		query["userId"] = userID
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := r.collection.Find(ctx, scopedToOrg(ctx, query), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []*models.APIKey{}
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Revoke marks a key of the organization ctx acts in as revoked and returns it
+func (r *APIKeyRepository) Revoke(ctx context.Context, id string) (*models.APIKey, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid API key ID")
+	}
+
+	filter := scopedToOrg(ctx, bson.M{"_id": objectID, "revokedAt": bson.M{"$exists": false}})
+	update := bson.M{"$set": bson.M{"revokedAt": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var key models.APIKey
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("API key not found")
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// Touch records that a key was used at now. To spare a write on every
+// request, it is only recorded when the last use is older than interval.
+func (r *APIKeyRepository) Touch(ctx context.Context, id primitive.ObjectID, now time.Time, interval time.Duration) error {
+	filter := bson.M{
+		"_id": id,
+		"$or": bson.A{
+			bson.M{"lastUsedAt": bson.M{"$exists": false}},
+			bson.M{"lastUsedAt": bson.M{"$lt": now.Add(-interval)}},
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"lastUsedAt": now}})
+	return err
+}
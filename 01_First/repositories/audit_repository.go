@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRepository handles database operations for audit events.
+// Events are append-only: there are no update or delete operations.
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(collection *mongo.Collection) *AuditRepository {
+	repo := &AuditRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates indexes for the common audit queries
+func (r *AuditRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "createdAt", Value: -1}}},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// Insert appends a new audit event
+func (r *AuditRepository) Insert(ctx context.Context, event *models.AuditEvent) error {
+	event.ID = primitive.NewObjectID()
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	_, err := r.collection.InsertOne(ctx, event)
+	return err
+}
+
+// Find retrieves audit events matching the filter with pagination, newest first
+func (r *AuditRepository) Find(ctx context.Context, filter models.AuditFilter, page, limit int) ([]*models.AuditEvent, int64, error) {
+	query := buildAuditQuery(filter)
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((page - 1) * limit))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []*models.AuditEvent{}
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+// Stream calls fn for every audit event matching the filter, oldest first.
+// It stops at the first error returned by fn.
+func (r *AuditRepository) Stream(ctx context.Context, filter models.AuditFilter, fn func(*models.AuditEvent) error) error {
+	findOptions := options.Find()
+	findOptions.SetBatchSize(1000)
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, buildAuditQuery(filter), findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			return err
+		}
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// buildAuditQuery converts an AuditFilter into a MongoDB query document
+func buildAuditQuery(filter models.AuditFilter) bson.M {
+	query := bson.M{}
+
+	if filter.ActorID != "" {
+		query["actorId"] = filter.ActorID
+	}
+
+	if filter.TargetID != "" {
+		query["targetId"] = filter.TargetID
+	}
+
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+
+	if filter.From != nil || filter.To != nil {
+		createdAt := bson.M{}
+		if filter.From != nil {
+			createdAt["$gte"] = *filter.From
+		}
+		if filter.To != nil {
+			createdAt["$lt"] = *filter.To
+		}
+		query["createdAt"] = createdAt
+	}
+
+	return query
+}
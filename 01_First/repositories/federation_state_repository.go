@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FederationStateRepository stores federated logins in progress
+type FederationStateRepository struct {
+	collection *mongo.Collection
+}
+
+// NewFederationStateRepository creates a new federation state repository
+func NewFederationStateRepository(collection *mongo.Collection) *FederationStateRepository {
+	repo := &FederationStateRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates the state lookup and TTL indexes
+func (r *FederationStateRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "stateHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// Create stores a login in progress
+func (r *FederationStateRepository) Create(ctx context.Context, state *models.FederationState) error {
+	state.ID = primitive.NewObjectID()
+	state.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, state)
+	return err
+}
+
+// Consume atomically deletes and returns an unexpired login in progress, so
+// each state can only complete one login
+func (r *FederationStateRepository) Consume(ctx context.Context, stateHash string) (*models.FederationState, error) {
+	filter := bson.M{
+		"stateHash": stateHash,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	var state models.FederationState
+	err := r.collection.FindOneAndDelete(ctx, filter).Decode(&state)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invalid or expired state")
+		}
+		return nil, err
+	}
+
+	return &state, nil
+}
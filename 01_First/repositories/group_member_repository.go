@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GroupMemberRepository handles database operations for direct group memberships
+type GroupMemberRepository struct {
+	collection *mongo.Collection
+}
+
+// NewGroupMemberRepository creates a new group membership repository
+func NewGroupMemberRepository(collection *mongo.Collection) *GroupMemberRepository {
+	repo := &GroupMemberRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes allows one membership per user and group and indexes a user's groups
+func (r *GroupMemberRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "groupId", Value: 1}, {Key: "userId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "orgId", Value: 1}}},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// Upsert makes the users members of the group with the given role. Users who
+// are already members get the role. It returns how many memberships were
+// created or changed.
+func (r *GroupMemberRepository) Upsert(ctx context.Context, group *models.Group, userIDs []string, role, addedBy string) (int64, error) {
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, len(userIDs))
+	for i, userID := range userIDs {
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"groupId": group.ID.Hex(), "userId": userID}).
+			SetUpdate(bson.M{
+				"$set": bson.M{"role": role},
+				"$setOnInsert": bson.M{
+					"orgId":     group.OrgID,
+					"addedBy":   addedBy,
+					"createdAt": now,
+				},
+			}).
+			SetUpsert(true)
+	}
+
+	result, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.UpsertedCount + result.ModifiedCount, nil
+}
+
+// Remove deletes the users' memberships of the group and returns how many were removed
+func (r *GroupMemberRepository) Remove(ctx context.Context, groupID string, userIDs []string) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"groupId": groupID, "userId": bson.M{"$in": userIDs}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// RemoveGroup deletes every membership of a group
+func (r *GroupMemberRepository) RemoveGroup(ctx context.Context, groupID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"groupId": groupID})
+	return err
+}
+
+// RemoveUserFromOrg deletes a user's memberships of the groups of an organization
+func (r *GroupMemberRepository) RemoveUserFromOrg(ctx context.Context, orgID, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"orgId": orgID, "userId": userID})
+	return err
+}
+
+// FindByGroup retrieves the direct members of a group with pagination, oldest first
+func (r *GroupMemberRepository) FindByGroup(ctx context.Context, groupID string, page, limit int) ([]*models.GroupMember, int64, error) {
+	query := bson.M{"groupId": groupID}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((page - 1) * limit))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	members := []*models.GroupMember{}
+	if err = cursor.All(ctx, &members); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return members, total, nil
+}
+
+// FindByUser retrieves a user's direct memberships in the organization ctx acts in
+func (r *GroupMemberRepository) FindByUser(ctx context.Context, userID string) ([]*models.GroupMember, error) {
+	cursor, err := r.collection.Find(ctx, scopedToOrg(ctx, bson.M{"userId": userID}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	members := []*models.GroupMember{}
+	if err = cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// UserIDs returns the distinct users who are direct members of any of the groups
+func (r *GroupMemberRepository) UserIDs(ctx context.Context, groupIDs []string) ([]string, error) {
+	values, err := r.collection.Distinct(ctx, "userId", bson.M{"groupId": bson.M{"$in": groupIDs}})
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if userID, ok := value.(string); ok {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GroupRepository handles database operations for groups
+type GroupRepository struct {
+	collection *mongo.Collection
+}
+
+// NewGroupRepository creates a new group repository
+func NewGroupRepository(collection *mongo.Collection) *GroupRepository {
+	repo := &GroupRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes keeps group names unique within an organization and indexes subgroup lookups
+func (r *GroupRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "orgId", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "parentId", Value: 1}}},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// Create inserts a new group
+func (r *GroupRepository) Create(ctx context.Context, group *models.Group) error {
+	group.ID = primitive.NewObjectID()
+	group.CreatedAt = time.Now()
+	group.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, group)
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.New("group name already exists")
+	}
+	return err
+}
+
+// FindByID finds a group of the organization ctx acts in by its ID
+func (r *GroupRepository) FindByID(ctx context.Context, id string) (*models.Group, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid group ID")
+	}
+
+	var group models.Group
+	err = r.collection.FindOne(ctx, scopedToOrg(ctx, bson.M{"_id": objectID})).Decode(&group)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("group not found")
+		}
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+// Find retrieves groups of the organization ctx acts in matching the filter
+// with pagination, sorted by name
+func (r *GroupRepository) Find(ctx context.Context, filter models.GroupFilter, page, limit int) ([]*models.Group, int64, error) {
+	query := scopedToOrg(ctx, bson.M{})
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		query["name"] = primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+	}
+	switch filter.ParentID {
+	case "":
+	case "root":
+		query["parentId"] = bson.M{"$exists": false}
+	default:
+		query["parentId"] = filter.ParentID
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((page - 1) * limit))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	groups := []*models.Group{}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return groups, total, nil
+}
+
+// FindAll retrieves every group of the organization ctx acts in, for walking
+// the group hierarchy
+func (r *GroupRepository) FindAll(ctx context.Context) ([]*models.Group, error) {
+	cursor, err := r.collection.Find(ctx, scopedToOrg(ctx, bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	groups := []*models.Group{}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// CountChildren returns the number of direct subgroups of a group
+func (r *GroupRepository) CountChildren(ctx context.Context, id string) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"parentId": id})
+}
+
+// Update applies set and unset to a group and returns it
+func (r *GroupRepository) Update(ctx context.Context, id primitive.ObjectID, set, unset bson.M) (*models.Group, error) {
+	set["updatedAt"] = time.Now()
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var group models.Group
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&group)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("group name already exists")
+		}
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("group not found")
+		}
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+// Delete removes a group
+func (r *GroupRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("group not found")
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IdempotencyRepository stores Idempotency-Key records
+type IdempotencyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewIdempotencyRepository creates a new idempotency repository
+func NewIdempotencyRepository(collection *mongo.Collection) *IdempotencyRepository {
+	repo := &IdempotencyRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates the TTL index that expires records
+func (r *IdempotencyRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err := r.collection.Indexes().CreateOne(ctx, index)
+	if err != nil {
+		// Index might already exist, which is fine
+		_ = err
+	}
+}
+
+// Reserve claims a key for a new request. It returns (record, true) when the
+// caller owns the key and should execute the request, or the existing record
+// and false when the key was already used. An in-progress record whose lock
+// has expired (its request crashed) is taken over.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, id, fingerprint string, lock, ttl time.Duration) (*models.IdempotencyRecord, bool, error) {
+	now := time.Now()
+	record := &models.IdempotencyRecord{
+		ID:          id,
+		Fingerprint: fingerprint,
+		Status:      models.IdempotencyInProgress,
+		LockedUntil: now.Add(lock),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	_, err := r.collection.InsertOne(ctx, record)
+	if err == nil {
+		return record, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, false, err
+	}
+
+	// Take over an abandoned in-progress record
+	filter := bson.M{
+		"_id":         id,
+		"status":      models.IdempotencyInProgress,
+		"lockedUntil": bson.M{"$lt": now},
+	}
+	update := bson.M{"$set": bson.M{
+		"fingerprint": fingerprint,
+		"lockedUntil": record.LockedUntil,
+		"expiresAt":   record.ExpiresAt,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var existing models.IdempotencyRecord
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&existing)
+	if err == nil {
+		return &existing, true, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, err
+	}
+
+	err = r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		// Expired between the insert and the lookup; let the client retry
+		return nil, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &existing, false, nil
+}
+
+// Complete stores the response of a reserved request
+func (r *IdempotencyRepository) Complete(ctx context.Context, id string, statusCode int, header map[string][]string, body []byte) error {
+	update := bson.M{"$set": bson.M{
+		"status":     models.IdempotencyCompleted,
+		"statusCode": statusCode,
+		"header":     header,
+		"body":       body,
+	}}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// Release deletes a reservation so the key can be retried
+func (r *IdempotencyRepository) Release(ctx context.Context, id string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
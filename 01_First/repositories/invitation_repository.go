@@ -0,0 +1,258 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InvitationRepository handles database operations for user invitations
+type InvitationRepository struct {
+	collection *mongo.Collection
+}
+
+// NewInvitationRepository creates a new invitation repository
+func NewInvitationRepository(collection *mongo.Collection) *InvitationRepository {
+	repo := &InvitationRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates the token lookup index and allows at most one pending
+// invitation per email
+func (r *InvitationRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{
+			Keys: bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": models.InvitationPending}),
+		},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "orgId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// Create inserts a new pending invitation
+func (r *InvitationRepository) Create(ctx context.Context, invitation *models.Invitation) error {
+	invitation.ID = primitive.NewObjectID()
+	invitation.Status = models.InvitationPending
+	invitation.CreatedAt = time.Now()
+	invitation.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, invitation)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("a pending invitation already exists for this email")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// FindByID finds an invitation of the organization ctx acts in by its ID
+func (r *InvitationRepository) FindByID(ctx context.Context, id string) (*models.Invitation, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid invitation ID")
+	}
+
+	var invitation models.Invitation
+	err = r.collection.FindOne(ctx, scopedToOrg(ctx, bson.M{"_id": objectID})).Decode(&invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invitation not found")
+		}
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// Find retrieves invitations of the organization ctx acts in matching the
+// filter with pagination, newest first
+func (r *InvitationRepository) Find(ctx context.Context, filter models.InvitationFilter, page, limit int) ([]*models.Invitation, int64, error) {
+	query := scopedToOrg(ctx, bson.M{})
+	if filter.Email != "" {
+		query["email"] = filter.Email
+	}
+
+	// Lapsed pending invitations are reported as expired
+	now := time.Now()
+	switch filter.Status {
+	case "":
+	case models.InvitationPending:
+		query["status"] = models.InvitationPending
+		query["expiresAt"] = bson.M{"$gt": now}
+	case models.InvitationExpired:
+		query["$or"] = bson.A{
+			bson.M{"status": models.InvitationExpired},
+			bson.M{"status": models.InvitationPending, "expiresAt": bson.M{"$lte": now}},
+		}
+	default:
+		query["status"] = filter.Status
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((page - 1) * limit))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	invitations := []*models.Invitation{}
+	if err = cursor.All(ctx, &invitations); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return invitations, total, nil
+}
+
+// ExpireLapsed marks a lapsed pending invitation for the email as expired, so
+// a new one can be created in its place
+func (r *InvitationRepository) ExpireLapsed(ctx context.Context, email string) error {
+	filter := bson.M{
+		"email":     email,
+		"status":    models.InvitationPending,
+		"expiresAt": bson.M{"$lte": time.Now()},
+	}
+	update := bson.M{"$set": bson.M{"status": models.InvitationExpired, "updatedAt": time.Now()}}
+
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// Reissue replaces the token of a pending invitation and extends its expiry,
+// so links sent earlier stop working
+func (r *InvitationRepository) Reissue(ctx context.Context, id primitive.ObjectID, tokenHash string, expiresAt time.Time) (*models.Invitation, error) {
+	update := bson.M{"$set": bson.M{
+		"tokenHash": tokenHash,
+		"expiresAt": expiresAt,
+		"updatedAt": time.Now(),
+	}}
+	return r.updatePending(ctx, bson.M{"_id": id}, update)
+}
+
+// RecordSend records a delivery attempt of the invitation email. An empty
+// sendError clears the error of an earlier attempt.
+func (r *InvitationRepository) RecordSend(ctx context.Context, id primitive.ObjectID, sentAt time.Time, sendError string) error {
+	update := bson.M{
+		"$set": bson.M{"lastSentAt": sentAt, "updatedAt": time.Now()},
+		"$inc": bson.M{"sendCount": 1},
+	}
+	if sendError != "" {
+		update["$set"].(bson.M)["lastSendError"] = sendError
+	} else {
+		update["$unset"] = bson.M{"lastSendError": ""}
+	}
+
+	_, err := r.collection.UpdateByID(ctx, id, update)
+	return err
+}
+
+// Revoke revokes a pending invitation
+func (r *InvitationRepository) Revoke(ctx context.Context, id primitive.ObjectID) (*models.Invitation, error) {
+	now := time.Now()
+	update := bson.M{"$set": bson.M{
+		"status":    models.InvitationRevoked,
+		"revokedAt": now,
+		"updatedAt": now,
+	}}
+	return r.updatePending(ctx, bson.M{"_id": id}, update)
+}
+
+// Claim atomically marks the unexpired pending invitation with the token hash
+// as accepted and returns it, so a token can only be used once
+func (r *InvitationRepository) Claim(ctx context.Context, tokenHash string) (*models.Invitation, error) {
+	now := time.Now()
+	filter := bson.M{
+		"tokenHash": tokenHash,
+		"status":    models.InvitationPending,
+		"expiresAt": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{
+		"status":     models.InvitationAccepted,
+		"acceptedAt": now,
+		"updatedAt":  now,
+	}}
+
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var invitation models.Invitation
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invalid or expired invitation")
+		}
+		return nil, err
+	}
+
+	return &invitation, nil
+}
+
+// Reopen returns a claimed invitation to pending when creating its user failed
+func (r *InvitationRepository) Reopen(ctx context.Context, id primitive.ObjectID) error {
+	filter := bson.M{
+		"_id":            id,
+		"status":         models.InvitationAccepted,
+		"acceptedUserId": bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$set":   bson.M{"status": models.InvitationPending, "updatedAt": time.Now()},
+		"$unset": bson.M{"acceptedAt": ""},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// SetAcceptedUser links a claimed invitation to the user created from it
+func (r *InvitationRepository) SetAcceptedUser(ctx context.Context, id primitive.ObjectID, userID string) error {
+	update := bson.M{"$set": bson.M{"acceptedUserId": userID, "updatedAt": time.Now()}}
+
+	_, err := r.collection.UpdateByID(ctx, id, update)
+	return err
+}
+
+// updatePending applies update to a pending invitation and returns the result
+func (r *InvitationRepository) updatePending(ctx context.Context, filter bson.M, update bson.M) (*models.Invitation, error) {
+	filter["status"] = models.InvitationPending
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var invitation models.Invitation
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invitation is no longer pending")
+		}
+		return nil, err
+	}
+
+	return &invitation, nil
+}
@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// jobFileBucket is the GridFS bucket holding job inputs and results
+const jobFileBucket = "job_files"
+
+// JobFileRepository stores job input and result files in GridFS, so any
+// replica can read a file another one wrote
+type JobFileRepository struct {
+	database *mongo.Database
+}
+
+// NewJobFileRepository creates a new job file repository
+func NewJobFileRepository(database *mongo.Database) *JobFileRepository {
+	return &JobFileRepository{database: database}
+}
+
+// bucket returns a GridFS bucket. Buckets carry unsynchronized state, so each
+// operation gets its own.
+func (r *JobFileRepository) bucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(r.database, options.GridFSBucket().SetName(jobFileBucket))
+}
+
+// Create opens a new file with the given ID for writing. The caller must Close
+// it to store the file, or Abort it to discard what was written.
+func (r *JobFileRepository) Create(id primitive.ObjectID, filename string) (*gridfs.UploadStream, error) {
+	bucket, err := r.bucket()
+	if err != nil {
+		return nil, err
+	}
+	return bucket.OpenUploadStreamWithID(id, filename)
+}
+
+// Open opens a stored file for reading
+func (r *JobFileRepository) Open(id primitive.ObjectID) (*gridfs.DownloadStream, error) {
+	bucket, err := r.bucket()
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := bucket.OpenDownloadStream(id)
+	if err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			return nil, errors.New("file not found")
+		}
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Delete removes a stored file; a missing file is not an error
+func (r *JobFileRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	bucket, err := r.bucket()
+	if err != nil {
+		return err
+	}
+
+	err = bucket.DeleteContext(ctx, id)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil
+	}
+	return err
+}
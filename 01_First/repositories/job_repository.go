@@ -0,0 +1,328 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// JobRepository handles persistence of background jobs
+type JobRepository struct {
+	collection *mongo.Collection
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(collection *mongo.Collection) *JobRepository {
+	repo := &JobRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates indexes used by the runner and job listings
+func (r *JobRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "finishedAt", Value: 1}}},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// Create queues a new job
+func (r *JobRepository) Create(ctx context.Context, job *models.Job) error {
+	now := time.Now()
+	job.ID = primitive.NewObjectID()
+	job.Status = models.JobQueued
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	_, err := r.collection.InsertOne(ctx, job)
+	return err
+}
+
+// FindByID finds a job by its ID
+func (r *JobRepository) FindByID(ctx context.Context, id string) (*models.Job, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid job ID")
+	}
+
+	var job models.Job
+	err = r.collection.FindOne(ctx, scopedToOrg(ctx, bson.M{"_id": objectID})).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("job not found")
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Find retrieves jobs matching the filter with pagination, newest first
+func (r *JobRepository) Find(ctx context.Context, filter models.JobFilter, page, limit int) ([]*models.Job, int64, error) {
+	query := scopedToOrg(ctx, bson.M{})
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((page - 1) * limit))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []*models.Job{}
+	if err = cursor.All(ctx, &jobs); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobs, total, nil
+}
+
+// ClaimNext atomically leases the oldest runnable job to leaseID for lease duration.
+// Queued jobs are runnable, and so are running jobs whose lease expired (the
+// replica running them died), as long as they have attempts left. It returns
+// nil when there is nothing to run.
+func (r *JobRepository) ClaimNext(ctx context.Context, leaseID string, lease time.Duration, maxAttempts int) (*models.Job, error) {
+	now := time.Now()
+	filter := bson.M{
+		"status":   bson.M{"$in": bson.A{models.JobQueued, models.JobRunning}},
+		"attempts": bson.M{"$lt": maxAttempts},
+		"$or": bson.A{
+			bson.M{"lockedUntil": bson.M{"$exists": false}},
+			bson.M{"lockedUntil": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      models.JobRunning,
+			"leaseId":     leaseID,
+			"lockedUntil": now.Add(lease),
+			"heartbeatAt": now,
+			"startedAt":   now,
+			"updatedAt":   now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.Job
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Heartbeat extends the lease and stores progress. It returns the job as stored,
+// so the runner can see a cancellation request, or "job lease lost" when the
+// runner no longer owns the job.
+func (r *JobRepository) Heartbeat(ctx context.Context, job *models.Job, lease time.Duration) (*models.Job, error) {
+	now := time.Now()
+	update := bson.M{"$set": bson.M{
+		"lockedUntil": now.Add(lease),
+		"heartbeatAt": now,
+		"progress":    job.Progress,
+		"updatedAt":   now,
+	}}
+
+	return r.updateLeased(ctx, job, update)
+}
+
+// SaveCheckpoint stores the state a resumed run continues from, with progress
+func (r *JobRepository) SaveCheckpoint(ctx context.Context, job *models.Job) error {
+	update := bson.M{"$set": bson.M{
+		"checkpoint": job.Checkpoint,
+		"progress":   job.Progress,
+		"updatedAt":  time.Now(),
+	}}
+
+	_, err := r.updateLeased(ctx, job, update)
+	return err
+}
+
+// Finish moves a leased job to its final state and releases the lease
+func (r *JobRepository) Finish(ctx context.Context, job *models.Job) error {
+	now := time.Now()
+	set := bson.M{
+		"status":     job.Status,
+		"progress":   job.Progress,
+		"result":     job.Result,
+		"error":      job.Error,
+		"finishedAt": now,
+		"updatedAt":  now,
+	}
+	if job.ResultFileID != nil {
+		set["resultFileId"] = job.ResultFileID
+		set["resultFileName"] = job.ResultFileName
+		set["resultFileContentType"] = job.ResultFileContentType
+	}
+
+	update := bson.M{
+		"$set":   set,
+		"$unset": bson.M{"leaseId": "", "lockedUntil": "", "checkpoint": ""},
+	}
+
+	_, err := r.updateLeased(ctx, job, update)
+	return err
+}
+
+// Release gives up a lease without finishing the job, e.g. on shutdown, so any
+// runner can pick it up straight away. The attempt is not counted.
+func (r *JobRepository) Release(ctx context.Context, job *models.Job) error {
+	update := bson.M{
+		"$set":   bson.M{"progress": job.Progress, "updatedAt": time.Now()},
+		"$unset": bson.M{"leaseId": "", "lockedUntil": ""},
+		"$inc":   bson.M{"attempts": -1},
+	}
+
+	_, err := r.updateLeased(ctx, job, update)
+	return err
+}
+
+// updateLeased applies update only while job.LeaseID still owns the job
+func (r *JobRepository) updateLeased(ctx context.Context, job *models.Job, update bson.M) (*models.Job, error) {
+	filter := bson.M{"_id": job.ID, "leaseId": job.LeaseID}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated models.Job
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("job lease lost")
+		}
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// RequestCancel cancels a queued job at once and asks the runner of a running
+// job to stop. It returns the job as stored afterwards.
+func (r *JobRepository) RequestCancel(ctx context.Context, id string) (*models.Job, error) {
+	job, err := r.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	// A queued job has no runner, so it is cancelled directly
+	var updated models.Job
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": job.ID, "status": models.JobQueued},
+		bson.M{"$set": bson.M{
+			"status":          models.JobCancelled,
+			"cancelRequested": true,
+			"finishedAt":      now,
+			"updatedAt":       now,
+		}},
+		findOptions,
+	).Decode(&updated)
+	if err == nil {
+		return &updated, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": job.ID, "status": models.JobRunning},
+		bson.M{"$set": bson.M{"cancelRequested": true, "updatedAt": now}},
+		findOptions,
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("job already finished")
+		}
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// FailExhausted fails running jobs whose lease expired after their last attempt
+func (r *JobRepository) FailExhausted(ctx context.Context, maxAttempts int) (int64, error) {
+	now := time.Now()
+	filter := bson.M{
+		"status":      models.JobRunning,
+		"attempts":    bson.M{"$gte": maxAttempts},
+		"lockedUntil": bson.M{"$lt": now},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":     models.JobFailed,
+			"error":      fmt.Sprintf("job abandoned after %d attempts", maxAttempts),
+			"finishedAt": now,
+			"updatedAt":  now,
+		},
+		"$unset": bson.M{"leaseId": "", "lockedUntil": "", "checkpoint": ""},
+	}
+
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// FindFinishedBefore returns up to limit jobs that finished before the cutoff
+func (r *JobRepository) FindFinishedBefore(ctx context.Context, before time.Time, limit int) ([]*models.Job, error) {
+	findOptions := options.Find().SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, bson.M{"finishedAt": bson.M{"$lt": before}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []*models.Job{}
+	if err = cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// Delete permanently removes a job
+func (r *JobRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OAuthClientRepository handles database operations for registered OAuth clients
+type OAuthClientRepository struct {
+	collection *mongo.Collection
+}
+
+// NewOAuthClientRepository creates a new OAuth client repository
+func NewOAuthClientRepository(collection *mongo.Collection) *OAuthClientRepository {
+	repo := &OAuthClientRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates the client ID lookup index
+func (r *OAuthClientRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "clientId", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// Create inserts a new client
+func (r *OAuthClientRepository) Create(ctx context.Context, client *models.OAuthClient) error {
+	client.ID = primitive.NewObjectID()
+	client.CreatedAt = time.Now()
+	client.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, client)
+	return err
+}
+
+// FindByID finds a client by its database ID
+func (r *OAuthClientRepository) FindByID(ctx context.Context, id string) (*models.OAuthClient, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid client ID")
+	}
+	return r.findOne(ctx, bson.M{"_id": objectID})
+}
+
+// FindByClientID finds a client by the client_id it authenticates with
+func (r *OAuthClientRepository) FindByClientID(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	return r.findOne(ctx, bson.M{"clientId": clientID})
+}
+
+// findOne finds the client matching filter
+func (r *OAuthClientRepository) findOne(ctx context.Context, filter bson.M) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.collection.FindOne(ctx, filter).Decode(&client)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("client not found")
+		}
+		return nil, err
+	}
+
+	return &client, nil
+}
+
+// FindAll retrieves all clients, newest first
+func (r *OAuthClientRepository) FindAll(ctx context.Context) ([]*models.OAuthClient, error) {
+	findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	clients := []*models.OAuthClient{}
+	if err = cursor.All(ctx, &clients); err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// Update updates an existing client
+func (r *OAuthClientRepository) Update(ctx context.Context, id primitive.ObjectID, updateData bson.M) error {
+	updateData["updatedAt"] = time.Now()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": updateData})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("client not found")
+	}
+
+	return nil
+}
+
+// Delete removes a client
+func (r *OAuthClientRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errors.New("client not found")
+	}
+
+	return nil
+}
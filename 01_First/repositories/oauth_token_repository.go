@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OAuthTokenRepository stores authorization codes, refresh tokens and the
+// IDs of revoked access tokens
+type OAuthTokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewOAuthTokenRepository creates a new OAuth token repository
+func NewOAuthTokenRepository(collection *mongo.Collection) *OAuthTokenRepository {
+	repo := &OAuthTokenRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates the token lookup and TTL indexes
+func (r *OAuthTokenRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "kind", Value: 1}, {Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "clientId", Value: 1}, {Key: "userId", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// Create stores a token
+func (r *OAuthTokenRepository) Create(ctx context.Context, token *models.OAuthToken) error {
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, token)
+	if err != nil && mongo.IsDuplicateKeyError(err) && (token.Kind == models.OAuthTokenRevokedAccess || token.Kind == models.OAuthTokenRevokedGrant) {
+		// Revoking an access token or a grant twice is fine
+		return nil
+	}
+	return err
+}
+
+// Find returns an unexpired token of the given kind without using it up
+func (r *OAuthTokenRepository) Find(ctx context.Context, kind, tokenHash string) (*models.OAuthToken, error) {
+	var token models.OAuthToken
+	err := r.collection.FindOne(ctx, unexpired(kind, tokenHash)).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invalid or expired token")
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Consume atomically deletes and returns an unexpired token of the given
+// kind issued to the client, so it can only be used once. Tokens of other
+// clients are left alone.
+func (r *OAuthTokenRepository) Consume(ctx context.Context, kind, tokenHash, clientID string) (*models.OAuthToken, error) {
+	filter := unexpired(kind, tokenHash)
+	filter["clientId"] = clientID
+
+	var token models.OAuthToken
+	err := r.collection.FindOneAndDelete(ctx, filter).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invalid or expired token")
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// RedeemCode atomically marks an unexpired, unused authorization code issued
+// to the client as used and returns it. The code stays stored, so a second
+// attempt to redeem it can be told apart from an unknown code.
+func (r *OAuthTokenRepository) RedeemCode(ctx context.Context, tokenHash, clientID string) (*models.OAuthToken, error) {
+	filter := unexpired(models.OAuthTokenCode, tokenHash)
+	filter["clientId"] = clientID
+	filter["usedAt"] = bson.M{"$exists": false}
+
+	now := time.Now()
+	var token models.OAuthToken
+	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"usedAt": now}}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invalid or expired token")
+		}
+		return nil, err
+	}
+
+	token.UsedAt = &now
+	return &token, nil
+}
+
+// DeleteGrant removes the refresh tokens descending from an authorization code
+func (r *OAuthTokenRepository) DeleteGrant(ctx context.Context, grantID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"kind": models.OAuthTokenRefresh, "grantId": grantID})
+	return err
+}
+
+// Exists reports whether an unexpired token of the given kind is stored
+func (r *OAuthTokenRepository) Exists(ctx context.Context, kind, tokenHash string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, unexpired(kind, tokenHash), options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// DeleteForClient removes the codes and refresh tokens of a client, or of
+// one of its users when userID is set
+func (r *OAuthTokenRepository) DeleteForClient(ctx context.Context, clientID, userID string) error {
+	filter := bson.M{
+		"clientId": clientID,
+		"kind":     bson.M{"$in": bson.A{models.OAuthTokenCode, models.OAuthTokenRefresh}},
+	}
+	if userID != "" {
+		filter["userId"] = userID
+	}
+
+	_, err := r.collection.DeleteMany(ctx, filter)
+	return err
+}
+
+// unexpired matches the unexpired token of the given kind and hash; the TTL
+// index only removes expired tokens periodically
+func unexpired(kind, tokenHash string) bson.M {
+	return bson.M{
+		"kind":      kind,
+		"tokenHash": tokenHash,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+}
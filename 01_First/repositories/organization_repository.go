@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OrganizationRepository handles database operations for organizations
+type OrganizationRepository struct {
+	collection *mongo.Collection
+}
+
+// NewOrganizationRepository creates a new organization repository
+func NewOrganizationRepository(collection *mongo.Collection) *OrganizationRepository {
+	repo := &OrganizationRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates the unique slug index
+func (r *OrganizationRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	slugIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "slug", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := r.collection.Indexes().CreateOne(ctx, slugIndex)
+	if err != nil {
+		// Index might already exist, which is fine
+		_ = err
+	}
+}
+
+// Create inserts a new organization
+func (r *OrganizationRepository) Create(ctx context.Context, org *models.Organization) error {
+	org.ID = primitive.NewObjectID()
+	org.CreatedAt = time.Now()
+	org.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, org)
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.New("organization slug already exists")
+	}
+	return err
+}
+
+// EnsureBySlug returns the organization with the slug, creating it with the
+// given name if it does not exist yet. Concurrent callers get the same one.
+func (r *OrganizationRepository) EnsureBySlug(ctx context.Context, slug, name string) (*models.Organization, error) {
+	now := time.Now()
+	update := bson.M{"$setOnInsert": bson.M{
+		"_id":       primitive.NewObjectID(),
+		"name":      name,
+		"slug":      slug,
+		"createdAt": now,
+		"updatedAt": now,
+	}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var org models.Organization
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"slug": slug}, update, opts).Decode(&org)
+	if mongo.IsDuplicateKeyError(err) {
+		// Lost an upsert race; the other insert won
+		err = r.collection.FindOne(ctx, bson.M{"slug": slug}).Decode(&org)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+// FindByID finds an organization by its ID
+func (r *OrganizationRepository) FindByID(ctx context.Context, id string) (*models.Organization, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid organization ID")
+	}
+
+	var org models.Organization
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&org)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("organization not found")
+		}
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+// FindByIDs retrieves the organizations with the given IDs, sorted by name.
+// Invalid and unknown IDs are skipped.
+func (r *OrganizationRepository) FindByIDs(ctx context.Context, ids []string) ([]*models.Organization, error) {
+	objectIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objectID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objectIDs = append(objectIDs, objectID)
+		}
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": objectIDs}}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	orgs := []*models.Organization{}
+	if err = cursor.All(ctx, &orgs); err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
+
+// FindAll retrieves organizations with pagination, sorted by name
+func (r *OrganizationRepository) FindAll(ctx context.Context, page, limit int) ([]*models.Organization, int64, error) {
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((page - 1) * limit))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "name", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	orgs := []*models.Organization{}
+	if err = cursor.All(ctx, &orgs); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return orgs, total, nil
+}
+
+// UpdateName renames an organization and returns it
+func (r *OrganizationRepository) UpdateName(ctx context.Context, id primitive.ObjectID, name string) (*models.Organization, error) {
+	update := bson.M{"$set": bson.M{"name": name, "updatedAt": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var org models.Organization
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&org)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("organization not found")
+		}
+		return nil, err
+	}
+
+	return &org, nil
+}
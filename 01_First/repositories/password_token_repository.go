@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PasswordTokenRepository stores single-use password tokens
+type PasswordTokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewPasswordTokenRepository creates a new password token repository
+func NewPasswordTokenRepository(collection *mongo.Collection) *PasswordTokenRepository {
+	repo := &PasswordTokenRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates the token lookup and TTL indexes
+func (r *PasswordTokenRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// Create stores a token, replacing earlier tokens of the same user and purpose
+func (r *PasswordTokenRepository) Create(ctx context.Context, token *models.PasswordToken) error {
+	if _, err := r.collection.DeleteMany(ctx, bson.M{"userId": token.UserID, "purpose": token.Purpose}); err != nil {
+		return err
+	}
+
+	token.ID = primitive.NewObjectID()
+	token.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+// Find returns an unexpired token without using it up
+func (r *PasswordTokenRepository) Find(ctx context.Context, tokenHash, purpose string) (*models.PasswordToken, error) {
+	filter := bson.M{
+		"tokenHash": tokenHash,
+		"purpose":   purpose,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	var token models.PasswordToken
+	err := r.collection.FindOne(ctx, filter).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invalid or expired token")
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Consume atomically deletes and returns an unexpired token, so it can only be used once
+func (r *PasswordTokenRepository) Consume(ctx context.Context, tokenHash, purpose string) (*models.PasswordToken, error) {
+	filter := bson.M{
+		"tokenHash": tokenHash,
+		"purpose":   purpose,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+
+	var token models.PasswordToken
+	err := r.collection.FindOneAndDelete(ctx, filter).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("invalid or expired token")
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
@@ -3,10 +3,12 @@ package repositories
 import (
 	"context"
 	"errors"
+	"regexp"
 	"strings"
 	"time"
 
 	"user-management-system/models"
+	"user-management-system/tenant"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -37,23 +39,202 @@ func (r *UserRepository) createIndexes() {
 		Options: options.Index().SetUnique(true),
 	}
 
-	_, err := r.collection.Indexes().CreateOne(ctx, emailIndex)
+	// Sparse index on deletedAt for the purger; only soft-deleted users carry the field.
+	// The email index stays global, so a soft-deleted user's email cannot be
+	// re-registered until the record is purged.
+	deletedAtIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "deletedAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	}
+
+	// Organization indexes back tenant scoping: members for reads, home organization for writes
+	orgIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "orgs.orgId", Value: 1}}},
+		{Keys: bson.D{{Key: "orgId", Value: 1}}},
+	}
+
+	// An external identity can be linked to one user only
+	identityIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"identities.subject": bson.M{"$exists": true}}),
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, append([]mongo.IndexModel{emailIndex, deletedAtIndex, identityIndex}, orgIndexes...))
 	if err != nil {
 		// Index might already exist, which is fine
 		_ = err
 	}
 }
 
+// notDeleted returns a filter that excludes soft-deleted users
+func notDeleted(filter bson.M) bson.M {
+	filter["deletedAt"] = bson.M{"$exists": false}
+	return filter
+}
+
+// scoped restricts query to the organization ctx acts in. Reads see the
+// organization's members; owned queries, used for writes, only match users
+// whose home organization it is. Contexts that are not scoped to an
+// organization (background workers, public auth endpoints, explicit
+// cross-organization requests) are not restricted.
+func scoped(ctx context.Context, query bson.M, owned bool) bson.M {
+	orgID := tenant.OrgID(ctx)
+	if orgID == "" {
+		return query
+	}
+	if owned {
+		query["orgId"] = orgID
+	} else {
+		query["orgs.orgId"] = orgID
+	}
+	return query
+}
+
+// scopedToOrg restricts a query on documents that belong to a single
+// organization, such as invitations and jobs, to the one ctx acts in
+func scopedToOrg(ctx context.Context, query bson.M) bson.M {
+	if orgID := tenant.OrgID(ctx); orgID != "" {
+		query["orgId"] = orgID
+	}
+	return query
+}
+
+// assignOrg puts a new user into the organization ctx acts in, unless the
+// user already has one, and makes sure the home organization is listed among
+// the user's memberships
+func assignOrg(ctx context.Context, user *models.User) error {
+	if user.OrgID == "" {
+		user.OrgID = tenant.OrgID(ctx)
+	}
+	if user.OrgID == "" {
+		return errors.New("organization is required")
+	}
+	if user.OrgRole(user.OrgID) == "" {
+		user.Orgs = append(user.Orgs, models.OrgMembership{OrgID: user.OrgID, Role: models.OrgRoleMember})
+	}
+	return nil
+}
+
 // Create inserts a new user into the database
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	if err := assignOrg(ctx, user); err != nil {
+		return err
+	}
+
 	user.ID = primitive.NewObjectID()
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
+	user.Version = 1
 
 	_, err := r.collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.New("email already exists")
+	}
 	return err
 }
 
+// InsertMany inserts users without stopping at the first failure. It returns
+// the per-user errors keyed by index into users; users not in the map were inserted.
+func (r *UserRepository) InsertMany(ctx context.Context, users []*models.User) (map[int]error, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	documents := make([]interface{}, len(users))
+	for i, user := range users {
+		if err := assignOrg(ctx, user); err != nil {
+			return nil, err
+		}
+		user.ID = primitive.NewObjectID()
+		user.CreatedAt = now
+		user.UpdatedAt = now
+		user.Version = 1
+		documents[i] = user
+	}
+
+	// Use InsertMany with ordered=false to continue on duplicate key errors
+	opts := options.InsertMany().SetOrdered(false)
+	_, err := r.collection.InsertMany(ctx, documents, opts)
+	if err == nil {
+		return nil, nil
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return nil, err
+	}
+
+	failed := make(map[int]error, len(bulkErr.WriteErrors))
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code == 11000 {
+			failed[writeErr.Index] = errors.New("email already registered")
+		} else {
+			failed[writeErr.Index] = errors.New(writeErr.Message)
+		}
+	}
+	return failed, nil
+}
+
+// ExistingEmails returns which of the emails are already taken, including by
+// soft-deleted users (the unique index covers them too). Emails are unique
+// across organizations, so this is not scoped.
+func (r *UserRepository) ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(emails) == 0 {
+		return existing, nil
+	}
+
+	opts := options.Find().SetProjection(bson.M{"email": 1})
+	cursor, err := r.collection.Find(ctx, bson.M{"email": bson.M{"$in": emails}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return nil, err
+		}
+		existing[user.Email] = true
+	}
+
+	return existing, cursor.Err()
+}
+
+// Stream calls fn for every user matching the filter, oldest first, without
+// loading the result set into memory
+func (r *UserRepository) Stream(ctx context.Context, filter models.UserFilter, fn func(*models.User) error) error {
+	findOptions := options.Find()
+	findOptions.SetBatchSize(1000)
+	findOptions.SetSort(bson.D{{Key: "_id", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, scoped(ctx, buildUserQuery(filter), filter.OwnedOnly), findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// Count returns the number of users matching the filter
+func (r *UserRepository) Count(ctx context.Context, filter models.UserFilter) (int64, error) {
+	return r.collection.CountDocuments(ctx, scoped(ctx, buildUserQuery(filter), filter.OwnedOnly))
+}
+
 // FindByID finds a user by their ID
 func (r *UserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
 	objectID, err := primitive.ObjectIDFromHex(id)
@@ -62,7 +243,7 @@ func (r *UserRepository) FindByID(ctx context.Context, id string) (*models.User,
 	}
 
 	var user models.User
-	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	err = r.collection.FindOne(ctx, scoped(ctx, notDeleted(bson.M{"_id": objectID}), false)).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, errors.New("user not found")
@@ -78,6 +259,23 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models
 	// Convert email to lowercase for consistent searching
 	email = strings.ToLower(strings.TrimSpace(email))
 
+	var user models.User
+	err := r.collection.FindOne(ctx, scoped(ctx, notDeleted(bson.M{"email": email}), false)).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// FindByEmailIncludingDeleted finds the user who holds an email, which may be
+// soft-deleted; emails stay taken until the user is purged
+func (r *UserRepository) FindByEmailIncludingDeleted(ctx context.Context, email string) (*models.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
 	var user models.User
 	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
 	if err != nil {
@@ -90,6 +288,66 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models
 	return &user, nil
 }
 
+// FindByIdentity finds the user an external identity is linked to
+func (r *UserRepository) FindByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	filter := bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+
+	var user models.User
+	err := r.collection.FindOne(ctx, scoped(ctx, notDeleted(filter), false)).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// AddIdentity links an external identity to a user, who may have only one
+// identity per provider, and returns the updated user
+func (r *UserRepository) AddIdentity(ctx context.Context, id string, identity models.LinkedIdentity) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	filter := notDeleted(bson.M{"_id": objectID, "identities.provider": bson.M{"$ne": identity.Provider}})
+	update := bson.M{
+		"$push": bson.M{"identities": identity},
+		"$set":  bson.M{"updatedAt": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+
+	user, err := r.updateMembership(ctx, objectID, filter, update, "identity provider already linked")
+	if err != nil && mongo.IsDuplicateKeyError(err) {
+		return nil, errors.New("identity is linked to another user")
+	}
+	return user, err
+}
+
+// RemoveIdentity unlinks a user's identity at a provider and returns the
+// updated user. A user whose password is random keeps at least one identity.
+func (r *UserRepository) RemoveIdentity(ctx context.Context, id, provider string) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	filter := notDeleted(bson.M{
+		"_id":                 objectID,
+		"identities.provider": provider,
+		"$or":                 []bson.M{{"randomPassword": bson.M{"$ne": true}}, {"identities.1": bson.M{"$exists": true}}},
+	})
+	update := bson.M{
+		"$pull": bson.M{"identities": bson.M{"provider": provider}},
+		"$set":  bson.M{"updatedAt": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+
+	return r.updateMembership(ctx, objectID, filter, update, "identity not linked")
+}
+
 // Update updates an existing user
 func (r *UserRepository) Update(ctx context.Context, id string, updateData bson.M) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
@@ -99,8 +357,8 @@ func (r *UserRepository) Update(ctx context.Context, id string, updateData bson.
 
 	updateData["updatedAt"] = time.Now()
 
-	filter := bson.M{"_id": objectID}
-	update := bson.M{"$set": updateData}
+	filter := scoped(ctx, notDeleted(bson.M{"_id": objectID}), true)
+	update := bson.M{"$set": updateData, "$inc": bson.M{"version": 1}}
 
 	result, err := r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
@@ -114,27 +372,324 @@ func (r *UserRepository) Update(ctx context.Context, id string, updateData bson.
 	return nil
 }
 
-// Delete removes a user from the database
+// ReplacePasswordHash swaps the stored hash of an unchanged password for one
+// made with other parameters. It only applies while the stored hash is still
+// oldHash, so it never undoes a concurrent password change, and it leaves the
+// version alone since the password itself did not change.
+func (r *UserRepository) ReplacePasswordHash(ctx context.Context, id, oldHash, newHash string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+
+	filter := scoped(ctx, notDeleted(bson.M{"_id": objectID, "password": oldHash}), true)
+	_, err = r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"password": newHash}})
+	return err
+}
+
+// UpdateIfVersion updates a user only if its stored version still equals
+// version, and returns the updated user. The check and the write are a single
+// atomic operation, so of two concurrent edits based on the same version only
+// one succeeds; the other gets "version conflict".
+func (r *UserRepository) UpdateIfVersion(ctx context.Context, id string, version int64, updateData bson.M) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	updateData["updatedAt"] = time.Now()
+
+	filter := scoped(ctx, notDeleted(bson.M{"_id": objectID, "version": versionMatch(version)}), true)
+	update := bson.M{"$set": updateData, "$inc": bson.M{"version": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var user models.User
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		// Distinguish a missing (or not owned) user from a stale version
+		count, countErr := r.collection.CountDocuments(ctx, scoped(ctx, notDeleted(bson.M{"_id": objectID}), true))
+		if countErr != nil {
+			return nil, countErr
+		}
+		if count == 0 {
+			return nil, errors.New("user not found")
+		}
+		return nil, errors.New("version conflict")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ConfirmEmail makes a user's pending email their email. It only applies
+// while email is still the pending one, and the unique email index makes the
+// uniqueness check and the write a single atomic operation.
+func (r *UserRepository) ConfirmEmail(ctx context.Context, id, email string) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	filter := scoped(ctx, notDeleted(bson.M{"_id": objectID, "pendingEmail": email}), true)
+	update := bson.M{
+		"$set":   bson.M{"email": email, "updatedAt": time.Now()},
+		"$unset": bson.M{"pendingEmail": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+
+	return r.setEmail(ctx, filter, update, "invalid or expired token")
+}
+
+// RevertEmail restores a previous email of a user and drops any pending change
+func (r *UserRepository) RevertEmail(ctx context.Context, id, email string) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	filter := scoped(ctx, notDeleted(bson.M{"_id": objectID}), true)
+	update := bson.M{
+		"$set":   bson.M{"email": email, "updatedAt": time.Now()},
+		"$unset": bson.M{"pendingEmail": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+
+	return r.setEmail(ctx, filter, update, "user not found")
+}
+
+// setEmail applies an email update and returns the updated user
+func (r *UserRepository) setEmail(ctx context.Context, filter, update bson.M, noMatch string) (*models.User, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var user models.User
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("email already in use")
+		}
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New(noMatch)
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// versionMatch matches a version; users created before versioning have no
+// version field and count as version 0
+func versionMatch(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
+
+// Delete soft-deletes a user by setting deletedAt; the record is kept until purged
 func (r *UserRepository) Delete(ctx context.Context, id string) error {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return errors.New("invalid user ID")
 	}
 
-	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{"deletedAt": now, "updatedAt": now},
+		"$inc": bson.M{"version": 1},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, scoped(ctx, notDeleted(bson.M{"_id": objectID}), true), update)
 	if err != nil {
 		return err
 	}
 
-	if result.DeletedCount == 0 {
+	if result.MatchedCount == 0 {
 		return errors.New("user not found")
 	}
 
 	return nil
 }
 
+// Restore clears deletedAt on a soft-deleted user
+func (r *UserRepository) Restore(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+
+	filter := scoped(ctx, bson.M{"_id": objectID, "deletedAt": bson.M{"$exists": true}}, true)
+	update := bson.M{
+		"$unset": bson.M{"deletedAt": ""},
+		"$set":   bson.M{"updatedAt": time.Now()},
+		"$inc":   bson.M{"version": 1},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("deleted user not found")
+	}
+
+	return nil
+}
+
+// PurgeDeleted permanently removes users soft-deleted before the given time
+func (r *UserRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, scoped(ctx, bson.M{"deletedAt": bson.M{"$lte": before}}, true))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+// AddMembership adds a user to an organization and returns the updated user.
+// It is not scoped, as the user does not belong to the organization yet.
+func (r *UserRepository) AddMembership(ctx context.Context, id string, membership models.OrgMembership) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	filter := notDeleted(bson.M{"_id": objectID, "orgs.orgId": bson.M{"$ne": membership.OrgID}})
+	update := bson.M{
+		"$push": bson.M{"orgs": membership},
+		"$set":  bson.M{"updatedAt": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+
+	return r.updateMembership(ctx, objectID, filter, update, "user is already a member")
+}
+
+// SetIdentityOrgRole records the organization role a directory granted with
+// the user's identity at it and returns the updated user
+func (r *UserRepository) SetIdentityOrgRole(ctx context.Context, id, provider, role string) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	filter := notDeleted(bson.M{"_id": objectID, "identities.provider": provider})
+	update := bson.M{
+		"$set": bson.M{"identities.$.orgRole": role, "updatedAt": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+
+	return r.updateMembership(ctx, objectID, filter, update, "identity not linked")
+}
+
+// SetMembershipRole changes a member's role in an organization and returns the updated user
+func (r *UserRepository) SetMembershipRole(ctx context.Context, id, orgID, role string) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	filter := notDeleted(bson.M{"_id": objectID, "orgs.orgId": orgID})
+	update := bson.M{
+		"$set": bson.M{"orgs.$.role": role, "updatedAt": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+
+	return r.updateMembership(ctx, objectID, filter, update, "membership not found")
+}
+
+// RemoveMembership removes a user from an organization other than their home
+// organization and returns the updated user
+func (r *UserRepository) RemoveMembership(ctx context.Context, id, orgID string) (*models.User, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+
+	filter := notDeleted(bson.M{"_id": objectID, "orgs.orgId": orgID, "orgId": bson.M{"$ne": orgID}})
+	update := bson.M{
+		"$pull": bson.M{"orgs": bson.M{"orgId": orgID}},
+		"$set":  bson.M{"updatedAt": time.Now()},
+		"$inc":  bson.M{"version": 1},
+	}
+
+	user, err := r.updateMembership(ctx, objectID, filter, update, "membership not found")
+	if err != nil && err.Error() == "membership not found" {
+		var existing models.User
+		if findErr := r.collection.FindOne(ctx, notDeleted(bson.M{"_id": objectID})).Decode(&existing); findErr == nil && existing.OrgID == orgID {
+			return nil, errors.New("cannot remove a user from their home organization")
+		}
+	}
+	return user, err
+}
+
+// updateMembership applies a membership or identity update and returns the updated user.
+// When filter matches nothing it reports a missing user, or noMatch otherwise.
+func (r *UserRepository) updateMembership(ctx context.Context, id primitive.ObjectID, filter, update bson.M, noMatch string) (*models.User, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var user models.User
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		count, countErr := r.collection.CountDocuments(ctx, notDeleted(bson.M{"_id": id}))
+		if countErr != nil {
+			return nil, countErr
+		}
+		if count == 0 {
+			return nil, errors.New("user not found")
+		}
+		return nil, errors.New(noMatch)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// CountOrgRole returns the number of users with the given role in an organization
+func (r *UserRepository) CountOrgRole(ctx context.Context, orgID, role string) (int64, error) {
+	filter := notDeleted(bson.M{"orgs": bson.M{"$elemMatch": bson.M{"orgId": orgID, "role": role}}})
+	return r.collection.CountDocuments(ctx, filter)
+}
+
+// AssignOrphans moves users that have no organization, i.e. that were created
+// before organizations existed, into orgID. Admins become its owners.
+func (r *UserRepository) AssignOrphans(ctx context.Context, orgID string) (int64, error) {
+	var assigned int64
+	for _, role := range []string{"admin", ""} {
+		filter := bson.M{"orgId": bson.M{"$exists": false}}
+		orgRole := models.OrgRoleMember
+		if role != "" {
+			filter["role"] = role
+			orgRole = models.OrgRoleOwner
+		}
+
+		update := bson.M{"$set": bson.M{
+			"orgId": orgID,
+			"orgs":  []models.OrgMembership{{OrgID: orgID, Role: orgRole}},
+		}}
+
+		result, err := r.collection.UpdateMany(ctx, filter, update)
+		if err != nil {
+			return assigned, err
+		}
+		assigned += result.ModifiedCount
+	}
+
+	return assigned, nil
+}
+
 // FindAll retrieves all users with pagination
 func (r *UserRepository) FindAll(ctx context.Context, page, limit int) ([]*models.User, int64, error) {
+	return r.FindByFilter(ctx, models.UserFilter{}, page, limit)
+}
+
+// FindByFilter retrieves users matching the given filter with pagination
+func (r *UserRepository) FindByFilter(ctx context.Context, filter models.UserFilter, page, limit int) ([]*models.User, int64, error) {
+	query := scoped(ctx, buildUserQuery(filter), filter.OwnedOnly)
+
 	// Calculate skip value
 	skip := (page - 1) * limit
 
@@ -144,8 +699,8 @@ func (r *UserRepository) FindAll(ctx context.Context, page, limit int) ([]*model
 	findOptions.SetLimit(int64(limit))
 	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}}) // Sort by createdAt descending
 
-	// Find all users
-	cursor, err := r.collection.Find(ctx, bson.M{}, findOptions)
+	// Find matching users
+	cursor, err := r.collection.Find(ctx, query, findOptions)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -157,7 +712,36 @@ func (r *UserRepository) FindAll(ctx context.Context, page, limit int) ([]*model
 	}
 
 	// Get total count
-	total, err := r.collection.CountDocuments(ctx, bson.M{})
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// FindByQuery retrieves users matching a raw query with offset pagination, oldest first.
+// Soft-deleted users are always excluded.
+func (r *UserRepository) FindByQuery(ctx context.Context, query bson.M, skip, limit int64) ([]*models.User, int64, error) {
+	query = scoped(ctx, notDeleted(query), false)
+
+	findOptions := options.Find()
+	findOptions.SetSkip(skip)
+	findOptions.SetLimit(limit)
+	findOptions.SetSort(bson.D{{Key: "_id", Value: 1}}) // Stable order for offset paging
+
+	cursor, err := r.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*models.User{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -174,7 +758,8 @@ func (r *UserRepository) FindAllWithoutLimit(ctx context.Context) ([]*models.Use
 	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}}) // Sort by createdAt descending
 
 	// Find all users with cursor
-	cursor, err := r.collection.Find(ctx, bson.M{}, findOptions)
+	query := scoped(ctx, notDeleted(bson.M{}), false)
+	cursor, err := r.collection.Find(ctx, query, findOptions)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -188,7 +773,7 @@ func (r *UserRepository) FindAllWithoutLimit(ctx context.Context) ([]*models.Use
 	countChan := make(chan countResult, 1)
 
 	go func() {
-		total, err := r.collection.CountDocuments(ctx, bson.M{})
+		total, err := r.collection.CountDocuments(ctx, query)
 		countChan <- countResult{count: total, err: err}
 	}()
 
@@ -258,5 +843,52 @@ func (r *UserRepository) FindAllWithoutLimit(ctx context.Context) ([]*models.Use
 
 // GetTotalCount retrieves the total count of users in the database
 func (r *UserRepository) GetTotalCount(ctx context.Context) (int64, error) {
-	return r.collection.CountDocuments(ctx, bson.M{})
+	return r.collection.CountDocuments(ctx, scoped(ctx, notDeleted(bson.M{}), false))
+}
+
+// CountByRole returns the number of users with the given role
+func (r *UserRepository) CountByRole(ctx context.Context, role string) (int64, error) {
+	return r.collection.CountDocuments(ctx, scoped(ctx, notDeleted(bson.M{"role": role}), false))
+}
+
+// buildUserQuery converts a UserFilter into a MongoDB query document
+func buildUserQuery(filter models.UserFilter) bson.M {
+	query := bson.M{}
+
+	idQuery := bson.M{}
+	if len(filter.IDs) > 0 {
+		idQuery["$in"] = filter.IDs
+	}
+	if !filter.AfterID.IsZero() {
+		idQuery["$gt"] = filter.AfterID
+	}
+	if len(idQuery) > 0 {
+		query["_id"] = idQuery
+	}
+
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		if filter.SearchNameOnly {
+			query["name"] = pattern
+		} else {
+			query["$or"] = bson.A{
+				bson.M{"name": pattern},
+				bson.M{"email": pattern},
+			}
+		}
+	}
+
+	if filter.Role != "" {
+		query["role"] = filter.Role
+	}
+
+	if filter.IsActive != nil {
+		query["isActive"] = *filter.IsActive
+	}
+
+	if !filter.IncludeDeleted {
+		query = notDeleted(query)
+	}
+
+	return query
 }
@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxDeliveryLogs caps the attempt log kept on each delivery
+const maxDeliveryLogs = 20
+
+// WebhookDeliveryRepository handles the persistent webhook delivery queue
+type WebhookDeliveryRepository struct {
+	collection *mongo.Collection
+}
+
+// NewWebhookDeliveryRepository creates a new webhook delivery repository
+func NewWebhookDeliveryRepository(collection *mongo.Collection) *WebhookDeliveryRepository {
+	repo := &WebhookDeliveryRepository{collection: collection}
+	repo.createIndexes()
+	return repo
+}
+
+// createIndexes creates indexes used by the dispatcher and delivery listings
+func (r *WebhookDeliveryRepository) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+		{Keys: bson.D{{Key: "subscriptionId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	}
+
+	_, err := r.collection.Indexes().CreateMany(ctx, indexes)
+	if err != nil {
+		// Indexes might already exist, which is fine
+		_ = err
+	}
+}
+
+// InsertMany adds deliveries to the queue
+func (r *WebhookDeliveryRepository) InsertMany(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	documents := make([]interface{}, len(deliveries))
+	for i, delivery := range deliveries {
+		delivery.ID = primitive.NewObjectID()
+		delivery.Status = models.WebhookDeliveryPending
+		delivery.NextAttemptAt = now
+		delivery.Logs = []models.WebhookAttempt{}
+		delivery.CreatedAt = now
+		delivery.UpdatedAt = now
+		documents[i] = delivery
+	}
+
+	_, err := r.collection.InsertMany(ctx, documents)
+	return err
+}
+
+// ClaimDue atomically leases the oldest due pending delivery for lease duration.
+// It returns nil when nothing is due. A lease that expires (e.g. the replica
+// died mid-delivery) makes the delivery claimable again.
+func (r *WebhookDeliveryRepository) ClaimDue(ctx context.Context, lease time.Duration) (*models.WebhookDelivery, error) {
+	now := time.Now()
+	filter := bson.M{
+		"status":        models.WebhookDeliveryPending,
+		"nextAttemptAt": bson.M{"$lte": now},
+		"$or": bson.A{
+			bson.M{"lockedUntil": bson.M{"$exists": false}},
+			bson.M{"lockedUntil": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"lockedUntil": now.Add(lease)}}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var delivery models.WebhookDelivery
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// RecordAttempt appends an attempt log and moves the delivery to its next state.
+// nextAttemptAt is ignored unless status is pending.
+func (r *WebhookDeliveryRepository) RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	set := bson.M{
+		"status":    status,
+		"lastError": attempt.Error,
+		"updatedAt": time.Now(),
+	}
+	if status == models.WebhookDeliveryPending {
+		set["nextAttemptAt"] = nextAttemptAt
+	}
+	if status == models.WebhookDeliveryDelivered {
+		set["deliveredAt"] = attempt.AttemptedAt
+	}
+
+	update := bson.M{
+		"$set":   set,
+		"$inc":   bson.M{"attempts": 1},
+		"$unset": bson.M{"lockedUntil": ""},
+		"$push": bson.M{"logs": bson.M{
+			"$each":  bson.A{attempt},
+			"$slice": -maxDeliveryLogs,
+		}},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// MarkDead moves a delivery straight to the dead-letter state without an attempt
+func (r *WebhookDeliveryRepository) MarkDead(ctx context.Context, id primitive.ObjectID, reason string) error {
+	update := bson.M{
+		"$set":   bson.M{"status": models.WebhookDeliveryDead, "lastError": reason, "updatedAt": time.Now()},
+		"$unset": bson.M{"lockedUntil": ""},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// FindByID finds a delivery belonging to the given subscription
+func (r *WebhookDeliveryRepository) FindByID(ctx context.Context, subscriptionID, id string) (*models.WebhookDelivery, error) {
+	subObjectID, err := primitive.ObjectIDFromHex(subscriptionID)
+	if err != nil {
+		return nil, errors.New("invalid webhook ID")
+	}
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid delivery ID")
+	}
+
+	var delivery models.WebhookDelivery
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID, "subscriptionId": subObjectID}).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("delivery not found")
+		}
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// FindBySubscription retrieves deliveries of a subscription with pagination, newest first
+func (r *WebhookDeliveryRepository) FindBySubscription(ctx context.Context, subscriptionID, status string, page, limit int) ([]*models.WebhookDelivery, int64, error) {
+	subObjectID, err := primitive.ObjectIDFromHex(subscriptionID)
+	if err != nil {
+		return nil, 0, errors.New("invalid webhook ID")
+	}
+
+	query := bson.M{"subscriptionId": subObjectID}
+	if status != "" {
+		query["status"] = status
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((page - 1) * limit))
+	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []*models.WebhookDelivery{}
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		return nil, 0, err
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// Requeue makes a delivery due immediately again, keeping its attempt log
+func (r *WebhookDeliveryRepository) Requeue(ctx context.Context, id primitive.ObjectID) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":        models.WebhookDeliveryPending,
+			"attempts":      0,
+			"nextAttemptAt": time.Now(),
+			"updatedAt":     time.Now(),
+		},
+		"$unset": bson.M{"lockedUntil": "", "deliveredAt": ""},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
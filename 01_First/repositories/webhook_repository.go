@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WebhookRepository handles database operations for webhook subscriptions
+type WebhookRepository struct {
+	collection *mongo.Collection
+}
+
+// NewWebhookRepository creates a new webhook subscription repository
+func NewWebhookRepository(collection *mongo.Collection) *WebhookRepository {
+	return &WebhookRepository{collection: collection}
+}
+
+// Create inserts a new webhook subscription
+func (r *WebhookRepository) Create(ctx context.Context, sub *models.WebhookSubscription) error {
+	sub.ID = primitive.NewObjectID()
+	sub.CreatedAt = time.Now()
+	sub.UpdatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, sub)
+	return err
+}
+
+// FindByID finds a webhook subscription by its ID
+func (r *WebhookRepository) FindByID(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid webhook ID")
+	}
+
+	var sub models.WebhookSubscription
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&sub)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("webhook not found")
+		}
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+// FindAll retrieves all webhook subscriptions, newest first
+func (r *WebhookRepository) FindAll(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	findOptions := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subs := []*models.WebhookSubscription{}
+	if err = cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// FindActiveForEvent retrieves active subscriptions that want the given event type
+func (r *WebhookRepository) FindActiveForEvent(ctx context.Context, eventType string) ([]*models.WebhookSubscription, error) {
+	filter := bson.M{
+		"isActive": true,
+		"events":   bson.M{"$in": bson.A{eventType, "*"}},
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var subs []*models.WebhookSubscription
+	if err = cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// Update updates an existing webhook subscription
+func (r *WebhookRepository) Update(ctx context.Context, id string, updateData bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid webhook ID")
+	}
+
+	updateData["updatedAt"] = time.Now()
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objectID}, bson.M{"$set": updateData})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return errors.New("webhook not found")
+	}
+
+	return nil
+}
+
+// Delete removes a webhook subscription
+func (r *WebhookRepository) Delete(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid webhook ID")
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return errors.New("webhook not found")
+	}
+
+	return nil
+}
@@ -16,12 +16,28 @@ func SetupRoutes(
 	userService *services.UserService,
 	authHandler *handlers.AuthHandler,
 	userHandler *handlers.UserHandler,
+	userBulkHandler *handlers.UserBulkHandler,
+	auditHandler *handlers.AuditHandler,
+	webhookHandler *handlers.WebhookHandler,
+	scimHandler *handlers.SCIMHandler,
+	jobHandler *handlers.JobHandler,
+	invitationHandler *handlers.InvitationHandler,
+	organizationHandler *handlers.OrganizationHandler,
+	groupHandler *handlers.GroupHandler,
+	meHandler *handlers.MeHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	oauthHandler *handlers.OAuthHandler,
+	federationHandler *handlers.FederationHandler,
+	groupAuthorizer middleware.GroupAuthorizer,
+	apiKeyAuthenticator middleware.APIKeyAuthenticator,
+	idempotencyStore middleware.IdempotencyStore,
 	cfg *config.Config,
 ) *mux.Router {
 	router := mux.NewRouter()
 
 	// Apply global middleware
 	router.Use(middleware.RecoveryMiddleware())
+	router.Use(middleware.RequestIDMiddleware())
 	router.Use(middleware.LoggingMiddleware())
 	router.Use(middleware.CORSMiddleware())
 
@@ -29,24 +45,101 @@ func SetupRoutes(
 	homeHandler := handlers.NewHomeHandler()
 	router.HandleFunc("/", homeHandler.Welcome).Methods("GET")
 
+	// API documentation (public)
+	docsHandler := handlers.NewDocsHandler()
+	router.HandleFunc("/openapi.json", docsHandler.Spec).Methods("GET")
+	router.HandleFunc("/docs", docsHandler.Docs).Methods("GET")
+
+	// Idempotency-Key support for POST endpoints that are unsafe to retry.
+	// Login and change-password are left out so issued tokens are never stored.
+	idempotent := middleware.IdempotencyMiddleware(idempotencyStore, cfg.IdempotencyTTL)
+
+	// Resource routes accept a JWT or an API key limited by its scopes
+	authenticate := middleware.AuthMiddleware(cfg, apiKeyAuthenticator)
+
 	// API routes
 	api := router.PathPrefix("/api").Subrouter()
 
 	// Auth routes (public)
 	auth := api.PathPrefix("/auth").Subrouter()
-	auth.HandleFunc("/register", authHandler.Register).Methods("POST")
+	auth.HandleFunc("/register", applyMiddleware(authHandler.Register, idempotent)).Methods("POST")
 	auth.HandleFunc("/login", authHandler.Login).Methods("POST")
+	auth.HandleFunc("/change-password", authHandler.ChangePassword).Methods("POST")
+	auth.HandleFunc("/set-password", authHandler.SetPassword).Methods("POST")
+	auth.HandleFunc("/accept-invitation", authHandler.AcceptInvitation).Methods("POST")
+	auth.HandleFunc("/confirm-email", authHandler.ConfirmEmail).Methods("POST")
+	auth.HandleFunc("/revert-email", authHandler.RevertEmail).Methods("POST")
+
+	// Sign-in through external OpenID Connect providers (public)
+	auth.HandleFunc("/federated", federationHandler.ListProviders).Methods("GET")
+	auth.HandleFunc("/federated/callback", federationHandler.Callback).Methods("POST")
+	auth.HandleFunc("/federated/{provider}/start", federationHandler.Start).Methods("POST")
+
+	// Exchange a token for one scoped to another organization (authenticated)
+	auth.HandleFunc("/switch-org", applyMiddleware(
+		authHandler.SwitchOrg,
+		middleware.JWTMiddleware(cfg),
+	)).Methods("POST")
+
+	// Self-service routes for the authenticated user's own account
+	me := api.PathPrefix("/me").Subrouter()
+	me.Use(middleware.JWTMiddleware(cfg))
+	me.Use(middleware.RequireAuth())
+
+	me.HandleFunc("", meHandler.GetMe).Methods("GET")
+	me.HandleFunc("", meHandler.UpdateMe).Methods("PATCH")
+	me.HandleFunc("", meHandler.CloseAccount).Methods("DELETE")
+	me.HandleFunc("/password", meHandler.ChangePassword).Methods("POST")
+	me.HandleFunc("/email", meHandler.ChangeEmail).Methods("POST")
+	me.HandleFunc("/identities/{provider}", federationHandler.Unlink).Methods("DELETE")
+
+	// API key routes; keys are managed with a JWT, never with another key
+	apiKeys := api.PathPrefix("/api-keys").Subrouter()
+	apiKeys.Use(middleware.JWTMiddleware(cfg))
+	apiKeys.Use(middleware.RequireAuth())
+
+	apiKeys.HandleFunc("", apiKeyHandler.ListKeys).Methods("GET")
+	apiKeys.HandleFunc("", applyMiddleware(apiKeyHandler.CreateKey, idempotent)).Methods("POST")
+	apiKeys.HandleFunc("/{id}", apiKeyHandler.RevokeKey).Methods("DELETE")
+
+	// Service accounts own API keys and cannot sign in (admin only)
+	serviceAccounts := api.PathPrefix("/service-accounts").Subrouter()
+	serviceAccounts.Use(middleware.JWTMiddleware(cfg))
+	serviceAccounts.Use(middleware.RequireAdmin())
+
+	serviceAccounts.HandleFunc("", applyMiddleware(apiKeyHandler.CreateServiceAccount, idempotent)).Methods("POST")
 
 	// User routes (protected)
 	users := api.PathPrefix("/users").Subrouter()
-	users.Use(middleware.JWTMiddleware(cfg))
+	users.Use(authenticate)
 	users.Use(middleware.RequireAuth())
 
 	// Get all users (with pagination)
 	users.HandleFunc("", userHandler.GetAllUsers).Methods("GET")
 
-	// Get all users without pagination limit (optimized for large datasets)
-	users.HandleFunc("/all", userHandler.GetAllUsersWithoutLimit).Methods("GET")
+	// Get all users without pagination limit (admin only, optimized for large datasets)
+	users.HandleFunc("/all", applyMiddleware(
+		userHandler.GetAllUsersWithoutLimit,
+		middleware.RequireAdmin(),
+	)).Methods("GET")
+
+	// Bulk import, export and deactivation (admin only); registered before /{id}
+	users.HandleFunc("/import", applyMiddleware(
+		userBulkHandler.ImportUsers,
+		middleware.RequireAdmin(),
+	)).Methods("POST")
+	users.HandleFunc("/export", applyMiddleware(
+		userBulkHandler.ExportUsers,
+		middleware.RequireAdmin(),
+	)).Methods("GET")
+	users.HandleFunc("/export", applyMiddleware(
+		applyMiddleware(userBulkHandler.QueueExport, idempotent),
+		middleware.RequireAdmin(),
+	)).Methods("POST")
+	users.HandleFunc("/deactivate", applyMiddleware(
+		applyMiddleware(userBulkHandler.DeactivateUsers, idempotent),
+		middleware.RequireAdmin(),
+	)).Methods("POST")
 
 	// Get single user
 	users.HandleFunc("/{id}", userHandler.GetUser).Methods("GET")
@@ -57,12 +150,155 @@ func SetupRoutes(
 		middleware.CanModifyUser(),
 	)).Methods("PUT")
 
-	// Delete user (admin only)
+	// Partially update user with a JSON merge patch
+	users.HandleFunc("/{id}", applyMiddleware(
+		userHandler.PatchUser,
+		middleware.CanModifyUser(),
+	)).Methods("PATCH")
+
+	// Delete user (admin only, soft delete)
 	users.HandleFunc("/{id}", applyMiddleware(
 		userHandler.DeleteUser,
 		middleware.RequireAdmin(),
 	)).Methods("DELETE")
 
+	// Groups a user belongs to, directly or through a subgroup
+	users.HandleFunc("/{id}/groups", userHandler.GetUserGroups).Methods("GET")
+
+	// Restore soft-deleted user (admin only)
+	users.HandleFunc("/{id}/restore", applyMiddleware(
+		applyMiddleware(userHandler.RestoreUser, idempotent),
+		middleware.RequireAdmin(),
+	)).Methods("POST")
+
+	// Audit log routes (super-admin only)
+	audit := api.PathPrefix("/audit").Subrouter()
+	audit.Use(authenticate)
+	audit.Use(middleware.RequireSuperAdmin())
+
+	audit.HandleFunc("", auditHandler.ListEvents).Methods("GET")
+	audit.HandleFunc("/export", auditHandler.ExportEvents).Methods("GET")
+
+	// Webhook subscription routes (super-admin only)
+	webhooks := api.PathPrefix("/webhooks").Subrouter()
+	webhooks.Use(authenticate)
+	webhooks.Use(middleware.RequireSuperAdmin())
+
+	webhooks.HandleFunc("", webhookHandler.ListSubscriptions).Methods("GET")
+	webhooks.HandleFunc("", applyMiddleware(webhookHandler.CreateSubscription, idempotent)).Methods("POST")
+	webhooks.HandleFunc("/{id}", webhookHandler.GetSubscription).Methods("GET")
+	webhooks.HandleFunc("/{id}", webhookHandler.UpdateSubscription).Methods("PUT")
+	webhooks.HandleFunc("/{id}", webhookHandler.DeleteSubscription).Methods("DELETE")
+	webhooks.HandleFunc("/{id}/deliveries", webhookHandler.ListDeliveries).Methods("GET")
+	webhooks.HandleFunc("/{id}/deliveries/{deliveryId}", webhookHandler.GetDelivery).Methods("GET")
+	webhooks.HandleFunc("/{id}/deliveries/{deliveryId}/redeliver", applyMiddleware(webhookHandler.Redeliver, idempotent)).Methods("POST")
+
+	// Invitation routes (admin only)
+	invitations := api.PathPrefix("/invitations").Subrouter()
+	invitations.Use(authenticate)
+	invitations.Use(middleware.RequireAdmin())
+
+	invitations.HandleFunc("", invitationHandler.ListInvitations).Methods("GET")
+	invitations.HandleFunc("", applyMiddleware(invitationHandler.CreateInvitation, idempotent)).Methods("POST")
+	invitations.HandleFunc("/{id}", invitationHandler.GetInvitation).Methods("GET")
+	invitations.HandleFunc("/{id}/resend", applyMiddleware(invitationHandler.ResendInvitation, idempotent)).Methods("POST")
+	invitations.HandleFunc("/{id}/revoke", invitationHandler.RevokeInvitation).Methods("POST")
+
+	// Organization routes; access within an organization depends on the caller's role there
+	orgs := api.PathPrefix("/orgs").Subrouter()
+	orgs.Use(authenticate)
+
+	orgs.HandleFunc("", organizationHandler.ListOrganizations).Methods("GET")
+	orgs.HandleFunc("", applyMiddleware(
+		applyMiddleware(organizationHandler.CreateOrganization, idempotent),
+		middleware.RequireSuperAdmin(),
+	)).Methods("POST")
+	orgs.HandleFunc("/{id}", organizationHandler.GetOrganization).Methods("GET")
+	orgs.HandleFunc("/{id}", organizationHandler.UpdateOrganization).Methods("PUT")
+	orgs.HandleFunc("/{id}/members", organizationHandler.ListMembers).Methods("GET")
+	orgs.HandleFunc("/{id}/members", applyMiddleware(organizationHandler.AddMember, idempotent)).Methods("POST")
+	orgs.HandleFunc("/{id}/members/{userId}", organizationHandler.UpdateMember).Methods("PUT")
+	orgs.HandleFunc("/{id}/members/{userId}", organizationHandler.RemoveMember).Methods("DELETE")
+
+	// Group routes; admins manage groups, group admins manage their groups' members
+	groups := api.PathPrefix("/groups").Subrouter()
+	groups.Use(authenticate)
+	groups.Use(middleware.RequireAuth())
+
+	groups.HandleFunc("", groupHandler.ListGroups).Methods("GET")
+	groups.HandleFunc("", applyMiddleware(
+		applyMiddleware(groupHandler.CreateGroup, idempotent),
+		middleware.RequireAdmin(),
+	)).Methods("POST")
+	groups.HandleFunc("/{id}", groupHandler.GetGroup).Methods("GET")
+	groups.HandleFunc("/{id}", applyMiddleware(
+		groupHandler.UpdateGroup,
+		middleware.RequireAdmin(),
+	)).Methods("PUT")
+	groups.HandleFunc("/{id}", applyMiddleware(
+		groupHandler.DeleteGroup,
+		middleware.RequireAdmin(),
+	)).Methods("DELETE")
+	groups.HandleFunc("/{id}/members", groupHandler.ListMembers).Methods("GET")
+	groups.HandleFunc("/{id}/members", applyMiddleware(
+		applyMiddleware(groupHandler.AddMembers, idempotent),
+		middleware.RequireGroupAdmin(groupAuthorizer),
+	)).Methods("POST")
+	groups.HandleFunc("/{id}/members/remove", applyMiddleware(
+		groupHandler.RemoveMembers,
+		middleware.RequireGroupAdmin(groupAuthorizer),
+	)).Methods("POST")
+
+	// Background job routes (admin only)
+	jobs := api.PathPrefix("/jobs").Subrouter()
+	jobs.Use(authenticate)
+	jobs.Use(middleware.RequireAdmin())
+
+	jobs.HandleFunc("", jobHandler.ListJobs).Methods("GET")
+	jobs.HandleFunc("/{id}", jobHandler.GetJob).Methods("GET")
+	jobs.HandleFunc("/{id}/cancel", jobHandler.CancelJob).Methods("POST")
+	jobs.HandleFunc("/{id}/result", jobHandler.DownloadResult).Methods("GET")
+
+	// OAuth client registration routes (super-admin only)
+	oauthClients := api.PathPrefix("/oauth/clients").Subrouter()
+	oauthClients.Use(middleware.JWTMiddleware(cfg))
+	oauthClients.Use(middleware.RequireSuperAdmin())
+
+	oauthClients.HandleFunc("", oauthHandler.ListClients).Methods("GET")
+	oauthClients.HandleFunc("", applyMiddleware(oauthHandler.CreateClient, idempotent)).Methods("POST")
+	oauthClients.HandleFunc("/{id}", oauthHandler.GetClient).Methods("GET")
+	oauthClients.HandleFunc("/{id}", oauthHandler.UpdateClient).Methods("PUT")
+	oauthClients.HandleFunc("/{id}", oauthHandler.DeleteClient).Methods("DELETE")
+
+	// OAuth 2.0 authorization server; clients authenticate on each endpoint
+	oauth := router.PathPrefix("/oauth").Subrouter()
+	oauth.HandleFunc("/authorize", oauthHandler.Authorize).Methods("GET", "POST")
+	oauth.HandleFunc("/token", oauthHandler.Token).Methods("POST")
+	oauth.HandleFunc("/introspect", oauthHandler.Introspect).Methods("POST")
+	oauth.HandleFunc("/revoke", oauthHandler.Revoke).Methods("POST")
+
+	// OpenID Connect on top of the authorization server
+	router.HandleFunc("/.well-known/openid-configuration", oauthHandler.Discovery).Methods("GET")
+	oauth.HandleFunc("/jwks", oauthHandler.JWKS).Methods("GET")
+	oauth.HandleFunc("/userinfo", oauthHandler.UserInfo).Methods("GET", "POST")
+	oauth.HandleFunc("/logout", oauthHandler.Logout).Methods("GET", "POST")
+
+	// SCIM 2.0 provisioning routes (identity provider bearer token)
+	if cfg.SCIMToken != "" {
+		scim := router.PathPrefix("/scim/v2").Subrouter()
+		scim.Use(middleware.SCIMAuthMiddleware(cfg.SCIMToken))
+
+		scim.HandleFunc("/ServiceProviderConfig", scimHandler.ServiceProviderConfig).Methods("GET")
+		scim.HandleFunc("/ResourceTypes", scimHandler.ResourceTypes).Methods("GET")
+		scim.HandleFunc("/Schemas", scimHandler.Schemas).Methods("GET")
+		scim.HandleFunc("/Users", scimHandler.ListUsers).Methods("GET")
+		scim.HandleFunc("/Users", scimHandler.CreateUser).Methods("POST")
+		scim.HandleFunc("/Users/{id}", scimHandler.GetUser).Methods("GET")
+		scim.HandleFunc("/Users/{id}", scimHandler.ReplaceUser).Methods("PUT")
+		scim.HandleFunc("/Users/{id}", scimHandler.PatchUser).Methods("PATCH")
+		scim.HandleFunc("/Users/{id}", scimHandler.DeleteUser).Methods("DELETE")
+	}
+
 	return router
 }
 
@@ -12,10 +12,10 @@ import (
 	"user-management-system/config"
 	"user-management-system/database"
 	"user-management-system/models"
+	"user-management-system/services"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -121,11 +121,14 @@ func main() {
 	collection := database.GetCollection("users")
 
 	// Hash password once (all users will have password "123456")
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.DefaultCost)
+	hasher, err := services.NewPasswordHasher(cfg.PasswordHashAlgorithm, cfg.BcryptCost, cfg.Argon2Memory, cfg.Argon2Time, cfg.Argon2Threads)
+	if err != nil {
+		log.Fatalf("Invalid password hashing settings: %v", err)
+	}
+	passwordHash, err := hasher.Hash("123456")
 	if err != nil {
 		log.Fatalf("Failed to hash password: %v", err)
 	}
-	passwordHash := string(hashedPassword)
 
 	// Generate users
 	log.Printf("Generating %d users...", count)
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/tenant"
+	"user-management-system/utils"
+)
+
+// apiKeyTouchInterval is how stale a key's recorded last use may get before
+// a request updates it
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyService manages API keys for machine-to-machine access and resolves
+// them for the authentication middleware. Keys are shown once at creation and
+// stored hashed; a key acts with its owner's current role in the organization
+// it was created in, limited to its scopes.
+type APIKeyService struct {
+	apiKeyRepo     *repositories.APIKeyRepository
+	userRepo       *repositories.UserRepository
+	userService    *UserService
+	auditService   *AuditService
+	webhookService *WebhookService
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(apiKeyRepo *repositories.APIKeyRepository, userRepo *repositories.UserRepository, userService *UserService, auditService *AuditService, webhookService *WebhookService) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:     apiKeyRepo,
+		userRepo:       userRepo,
+		userService:    userService,
+		auditService:   auditService,
+		webhookService: webhookService,
+	}
+}
+
+// CreateKey creates an API key in the organization ctx acts in and returns it
+// with the plaintext key, which is not stored. Users create keys for
+// themselves; admins also for service accounts and other users of the
+// organization.
+func (s *APIKeyService) CreateKey(ctx context.Context, req *models.CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", errors.New("name is required")
+	}
+	if len(name) > 100 {
+		return nil, "", errors.New("name must be at most 100 characters")
+	}
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if req.ExpiresInDays < 0 {
+		return nil, "", errors.New("expiresInDays must not be negative")
+	}
+
+	orgID := tenant.OrgID(ctx)
+	if orgID == "" {
+		return nil, "", errors.New("organization is required")
+	}
+
+	callerID := middleware.GetUserID(ctx)
+	ownerID := req.UserID
+	if ownerID == "" {
+		ownerID = callerID
+	}
+	if ownerID != callerID && middleware.GetRole(ctx) != "admin" {
+		return nil, "", errors.New("only admins can create API keys for service accounts")
+	}
+
+	owner, err := s.userRepo.FindByID(selfScope(ctx, ownerID), ownerID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !owner.IsActive {
+		return nil, "", errors.New("account is deactivated")
+	}
+	if owner.OrgRole(orgID) == "" && !owner.IsSuperAdmin() {
+		return nil, "", errors.New("user is not a member of the organization")
+	}
+	if ownerID != callerID {
+		if owner.IsSuperAdmin() {
+			return nil, "", errors.New("only super-admins can create their own API keys")
+		}
+		if !owner.ServiceAccount {
+			return nil, "", errors.New("API keys can only be created for yourself or a service account")
+		}
+	}
+
+	prefix, secret, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext := prefix + "_" + secret
+
+	key := &models.APIKey{
+		OrgID:       orgID,
+		UserID:      ownerID,
+		Name:        name,
+		Prefix:      prefix,
+		KeyHash:     hashToken(plaintext),
+		Scopes:      scopes,
+		CreatedByID: callerID,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionAPIKeyCreate,
+		TargetID:    owner.ID.Hex(),
+		TargetEmail: owner.Email,
+		Success:     true,
+		Changes: map[string]models.AuditChange{
+			"prefix": {From: nil, To: prefix},
+			"scopes": {From: nil, To: scopes},
+		},
+	})
+
+	return key, plaintext, nil
+}
+
+// ListKeys retrieves the API keys of the organization ctx acts in. Admins see
+// every key, or one owner's with userID; other users only their own.
+func (s *APIKeyService) ListKeys(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	if middleware.GetRole(ctx) != "admin" {
+		userID = middleware.GetUserID(ctx)
+	}
+	return s.apiKeyRepo.Find(ctx, userID)
+}
+
+// RevokeKey revokes an API key of the organization ctx acts in. Users revoke
+// their own keys; admins any key of the organization.
+func (s *APIKeyService) RevokeKey(ctx context.Context, id string) (*models.APIKey, error) {
+	key, err := s.apiKeyRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key.UserID != middleware.GetUserID(ctx) && middleware.GetRole(ctx) != "admin" {
+		return nil, errors.New("API key not found")
+	}
+	if key.RevokedAt != nil {
+		return nil, errors.New("API key is already revoked")
+	}
+
+	key, err = s.apiKeyRepo.Revoke(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   models.AuditActionAPIKeyRevoke,
+		TargetID: key.UserID,
+		Success:  true,
+		Changes:  map[string]models.AuditChange{"prefix": {From: key.Prefix, To: nil}},
+	})
+
+	return key, nil
+}
+
+// CreateServiceAccount creates a service account in the organization ctx acts
+// in with the given organization role. It gets a placeholder email and a
+// random password nobody knows, and cannot sign in; admins give it API keys.
+func (s *APIKeyService) CreateServiceAccount(ctx context.Context, req *models.CreateServiceAccountRequest) (*models.User, error) {
+	name := strings.TrimSpace(req.Name)
+	if len(name) < 2 || len(name) > 100 {
+		return nil, errors.New("name must be between 2 and 100 characters")
+	}
+
+	role := req.Role
+	if role == "" {
+		role = models.OrgRoleMember
+	}
+	if role != models.OrgRoleMember && role != models.OrgRoleAdmin {
+		return nil, errors.New("role must be either admin or member")
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	password, err := generateRandomPassword()
+	if err != nil {
+		return nil, errors.New("failed to generate password")
+	}
+
+	user, err := s.userService.insertUser(ctx, &models.User{
+		Name:           name,
+		Email:          "svc-" + hex.EncodeToString(id) + "@service-accounts.invalid",
+		Role:           "user",
+		IsActive:       true,
+		ServiceAccount: true,
+	}, password, role)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionUserCreate,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Reason:      "service account",
+		Changes:     map[string]models.AuditChange{"orgRole": {From: nil, To: role}},
+	})
+	s.webhookService.Publish(ctx, models.WebhookEventUserCreated, user)
+
+	return user, nil
+}
+
+// AuthenticateAPIKey resolves a key to the claims its requests act with and
+// its scopes. The claims are built from the owner's current account, so
+// deactivating the owner or changing their role applies to their keys at once.
+// A key someone else created only works while its owner is a service account
+// and not a super-admin, so promoting an account never hands its platform
+// rights to keys other people hold.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, plaintext string) (*utils.JWTClaims, []string, error) {
+	invalid := errors.New("invalid or expired API key")
+	if !strings.HasPrefix(plaintext, models.APIKeyPrefix) {
+		return nil, nil, invalid
+	}
+
+	ctx = tenant.AcrossOrgs(ctx)
+	key, err := s.apiKeyRepo.FindByHash(ctx, hashToken(plaintext))
+	if err != nil {
+		return nil, nil, invalid
+	}
+	now := time.Now()
+	if !key.Usable(now) {
+		return nil, nil, invalid
+	}
+
+	owner, err := s.userRepo.FindByID(ctx, key.UserID)
+	if err != nil || !owner.IsActive {
+		return nil, nil, invalid
+	}
+	if owner.OrgRole(key.OrgID) == "" && !owner.IsSuperAdmin() {
+		return nil, nil, invalid
+	}
+	if key.CreatedByID != key.UserID && (!owner.ServiceAccount || owner.IsSuperAdmin()) {
+		return nil, nil, invalid
+	}
+
+	if err := s.apiKeyRepo.Touch(ctx, key.ID, now, apiKeyTouchInterval); err != nil {
+		log.Printf("⚠️  Failed to record use of API key %s: %v", key.Prefix, err)
+	}
+
+	return &utils.JWTClaims{
+		UserID:     owner.ID.Hex(),
+		Email:      owner.Email,
+		Role:       owner.EffectiveRole(key.OrgID),
+		OrgID:      key.OrgID,
+		OrgRole:    owner.OrgRole(key.OrgID),
+		SuperAdmin: owner.IsSuperAdmin(),
+	}, key.Scopes, nil
+}
+
+// normalizeScopes validates scopes and removes duplicates
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !models.IsValidAPIKeyScope(scope) {
+			return nil, errors.New("invalid scope: " + scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized, nil
+}
+
+// generateAPIKey returns a new key's public prefix, which identifies it, and
+// its secret part. The key is "<prefix>_<secret>".
+func generateAPIKey() (string, string, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret, err := generateToken()
+	if err != nil {
+		return "", "", err
+	}
+	return models.APIKeyPrefix + hex.EncodeToString(id), secret, nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCreateAPIKeyOwners(t *testing.T) {
+	env := newTestEnv(t)
+	orgAdmin := env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin)
+	member := env.createUser("member@example.com", "user", models.OrgRoleMember)
+	superAdmin := env.createUser("root@example.com", "admin", models.OrgRoleMember)
+	adminCtx := env.callerContext(orgAdmin)
+
+	serviceAccount, err := env.apiKeyService.CreateServiceAccount(adminCtx, &models.CreateServiceAccountRequest{Name: "HR sync"})
+	if err != nil {
+		t.Fatalf("CreateServiceAccount: %v", err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		caller *models.User
+		owner  *models.User
+		want   string
+	}{
+		{"own key", member, nil, ""},
+		{"admin for a service account", orgAdmin, serviceAccount, ""},
+		{"super-admin for themselves", superAdmin, superAdmin, ""},
+		{"member for a service account", member, serviceAccount, "only admins can create API keys for service accounts"},
+		{"admin for another user", orgAdmin, member, "API keys can only be created for yourself or a service account"},
+		{"admin for a super-admin", orgAdmin, superAdmin, "only super-admins can create their own API keys"},
+		{"super-admin for another user", superAdmin, member, "API keys can only be created for yourself or a service account"},
+	} {
+		req := &models.CreateAPIKeyRequest{Name: test.name, Scopes: []string{"users:read"}}
+		if test.owner != nil {
+			req.UserID = test.owner.ID.Hex()
+		}
+		key, plaintext, err := env.apiKeyService.CreateKey(env.callerContext(test.caller), req)
+		if test.want != "" {
+			if err == nil || err.Error() != test.want {
+				t.Errorf("%s: err = %v, want %q", test.name, err, test.want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: CreateKey: %v", test.name, err)
+			continue
+		}
+		if key.CreatedByID != test.caller.ID.Hex() || key.KeyHash == plaintext {
+			t.Errorf("%s: key = %+v", test.name, key)
+		}
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	env := newTestEnv(t)
+	member := env.createUser("member@example.com", "user", models.OrgRoleMember)
+
+	_, plaintext, err := env.apiKeyService.CreateKey(env.callerContext(member), &models.CreateAPIKeyRequest{Name: "script", Scopes: []string{"users:read", "users:read"}, ExpiresInDays: 30})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+
+	claims, scopes, err := env.apiKeyService.AuthenticateAPIKey(systemContext(), plaintext)
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey: %v", err)
+	}
+	if claims.UserID != member.ID.Hex() || claims.OrgID != env.defaultOrg.ID.Hex() || claims.Role != "user" || claims.SuperAdmin {
+		t.Fatalf("claims = %+v", claims)
+	}
+	if len(scopes) != 1 || scopes[0] != "users:read" {
+		t.Fatalf("scopes = %v", scopes)
+	}
+
+	for _, bad := range []string{"", "not-a-key", plaintext + "x"} {
+		if _, _, err := env.apiKeyService.AuthenticateAPIKey(systemContext(), bad); err == nil {
+			t.Errorf("AuthenticateAPIKey(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRevokedAndExpiredAPIKeys(t *testing.T) {
+	env := newTestEnv(t)
+	member := env.createUser("member@example.com", "user", models.OrgRoleMember)
+	ctx := env.callerContext(member)
+
+	revoked, revokedPlaintext, _ := env.apiKeyService.CreateKey(ctx, &models.CreateAPIKeyRequest{Name: "revoked", Scopes: []string{"users:read"}})
+	if _, err := env.apiKeyService.RevokeKey(ctx, revoked.ID.Hex()); err != nil {
+		t.Fatalf("RevokeKey: %v", err)
+	}
+	if _, err := env.apiKeyService.RevokeKey(ctx, revoked.ID.Hex()); err == nil || err.Error() != "API key is already revoked" {
+		t.Fatalf("revoking twice: err = %v", err)
+	}
+	if _, _, err := env.apiKeyService.AuthenticateAPIKey(systemContext(), revokedPlaintext); err == nil {
+		t.Fatal("a revoked key authenticated")
+	}
+
+	expired, expiredPlaintext, _ := env.apiKeyService.CreateKey(ctx, &models.CreateAPIKeyRequest{Name: "expired", Scopes: []string{"users:read"}, ExpiresInDays: 1})
+	_, err := env.db.Collection("api_keys").UpdateByID(context.Background(), expired.ID, bson.M{"$set": bson.M{"expiresAt": time.Now().Add(-time.Minute)}})
+	if err != nil {
+		t.Fatalf("backdating expiry: %v", err)
+	}
+	if _, _, err := env.apiKeyService.AuthenticateAPIKey(systemContext(), expiredPlaintext); err == nil {
+		t.Fatal("an expired key authenticated")
+	}
+}
+
+func TestAPIKeysOfOthersStopWhenOwnerIsPromoted(t *testing.T) {
+	env := newTestEnv(t)
+	orgAdmin := env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin)
+	adminCtx := env.callerContext(orgAdmin)
+
+	serviceAccount, _ := env.apiKeyService.CreateServiceAccount(adminCtx, &models.CreateServiceAccountRequest{Name: "HR sync"})
+	_, serviceKey, err := env.apiKeyService.CreateKey(adminCtx, &models.CreateAPIKeyRequest{UserID: serviceAccount.ID.Hex(), Name: "sync", Scopes: []string{"users:read"}})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	_, ownKey, _ := env.apiKeyService.CreateKey(adminCtx, &models.CreateAPIKeyRequest{Name: "mine", Scopes: []string{"users:read"}})
+
+	// Promoting the service account must not hand super-admin rights to the
+	// admin who holds its key
+	_, err = env.db.Collection("users").UpdateByID(context.Background(), serviceAccount.ID, bson.M{"$set": bson.M{"role": "admin"}})
+	if err != nil {
+		t.Fatalf("promoting the service account: %v", err)
+	}
+	if _, _, err := env.apiKeyService.AuthenticateAPIKey(systemContext(), serviceKey); err == nil {
+		t.Fatal("a key created by someone else acts as a super-admin")
+	}
+
+	// A super-admin's own keys keep working
+	_, err = env.db.Collection("users").UpdateByID(context.Background(), orgAdmin.ID, bson.M{"$set": bson.M{"role": "admin"}})
+	if err != nil {
+		t.Fatalf("promoting the admin: %v", err)
+	}
+	claims, _, err := env.apiKeyService.AuthenticateAPIKey(systemContext(), ownKey)
+	if err != nil || !claims.SuperAdmin {
+		t.Fatalf("own key of a super-admin: claims = %+v, err = %v", claims, err)
+	}
+}
+
+func TestAPIKeyOfFormerServiceAccountStops(t *testing.T) {
+	env := newTestEnv(t)
+	adminCtx := env.callerContext(env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin))
+
+	serviceAccount, _ := env.apiKeyService.CreateServiceAccount(adminCtx, &models.CreateServiceAccountRequest{Name: "HR sync"})
+	_, plaintext, _ := env.apiKeyService.CreateKey(adminCtx, &models.CreateAPIKeyRequest{UserID: serviceAccount.ID.Hex(), Name: "sync", Scopes: []string{"users:read"}})
+
+	_, err := env.db.Collection("users").UpdateByID(context.Background(), serviceAccount.ID, bson.M{"$unset": bson.M{"serviceAccount": ""}})
+	if err != nil {
+		t.Fatalf("clearing serviceAccount: %v", err)
+	}
+	if _, _, err := env.apiKeyService.AuthenticateAPIKey(systemContext(), plaintext); err == nil {
+		t.Fatal("a key created by someone else still acts as a regular user")
+	}
+}
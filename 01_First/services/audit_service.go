@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/tenant"
+)
+
+// AuditService records and queries audit events
+type AuditService struct {
+	auditRepo *repositories.AuditRepository
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(auditRepo *repositories.AuditRepository) *AuditService {
+	return &AuditService{auditRepo: auditRepo}
+}
+
+// Record stores an audit event, filling in the actor and request metadata from ctx.
+// Failures are logged rather than returned so auditing never breaks the mutation itself.
+func (s *AuditService) Record(ctx context.Context, event *models.AuditEvent) {
+	if event.ActorID == "" {
+		event.ActorID = middleware.GetUserID(ctx)
+		event.ActorEmail = middleware.GetEmail(ctx)
+	}
+	if event.OrgID == "" {
+		event.OrgID = tenant.OrgID(ctx)
+	}
+	event.IP = middleware.GetClientIP(ctx)
+	event.UserAgent = middleware.GetUserAgent(ctx)
+	event.RequestID = middleware.GetRequestID(ctx)
+	event.CreatedAt = time.Now()
+
+	// Write even if the request context was cancelled after the mutation
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err := s.auditRepo.Insert(writeCtx, event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", event.Action, err)
+	}
+}
+
+// ListEvents retrieves audit events matching the filter with pagination
+func (s *AuditService) ListEvents(ctx context.Context, filter models.AuditFilter, page, limit int) ([]*models.AuditEvent, int, int64, error) {
+	// Validate pagination parameters
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500 // Max limit
+	}
+
+	events, total, err := s.auditRepo.Find(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// Calculate total pages
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+
+	return events, totalPages, total, nil
+}
+
+// ExportEvents streams every audit event matching the filter to fn, oldest first
+func (s *AuditService) ExportEvents(ctx context.Context, filter models.AuditFilter, fn func(*models.AuditEvent) error) error {
+	return s.auditRepo.Stream(ctx, filter, fn)
+}
+
+// userChanges returns the fields that differ between before and after.
+// Password values are never recorded, only the fact that the password changed.
+func userChanges(before, after *models.User) map[string]models.AuditChange {
+	changes := map[string]models.AuditChange{}
+
+	if before.Name != after.Name {
+		changes["name"] = models.AuditChange{From: before.Name, To: after.Name}
+	}
+	if before.Email != after.Email {
+		changes["email"] = models.AuditChange{From: before.Email, To: after.Email}
+	}
+	if before.Role != after.Role {
+		changes["role"] = models.AuditChange{From: before.Role, To: after.Role}
+	}
+	if before.IsActive != after.IsActive {
+		changes["isActive"] = models.AuditChange{From: before.IsActive, To: after.IsActive}
+	}
+	if before.Password != after.Password {
+		changes["password"] = models.AuditChange{From: "[redacted]", To: "[redacted]"}
+	}
+
+	return changes
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+)
+
+func TestRecordFillsActorAndRequest(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin@example.com", "admin", models.OrgRoleOwner)
+
+	ctx := env.callerContext(admin)
+	ctx = context.WithValue(ctx, middleware.RequestIDKey, "req-1")
+	ctx = context.WithValue(ctx, middleware.ClientIPKey, "203.0.113.7")
+	ctx = context.WithValue(ctx, middleware.UserAgentKey, "test-agent")
+	env.auditService.Record(ctx, &models.AuditEvent{Action: models.AuditActionUserDelete, TargetID: "target", Success: true})
+
+	events, _, total, err := env.auditService.ListEvents(context.Background(), models.AuditFilter{Action: models.AuditActionUserDelete}, 1, 10)
+	if err != nil || total != 1 {
+		t.Fatalf("ListEvents = %d events, %v", total, err)
+	}
+	event := events[0]
+	if event.ActorID != admin.ID.Hex() || event.ActorEmail != admin.Email || event.OrgID != env.defaultOrg.ID.Hex() ||
+		event.RequestID != "req-1" || event.IP != "203.0.113.7" || event.UserAgent != "test-agent" || event.CreatedAt.IsZero() {
+		t.Fatalf("recorded event = %+v", event)
+	}
+}
+
+func TestMutationsAreAudited(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+
+	registered, err := env.userService.Register(ctx, &models.RegisterRequest{Name: "Jane Doe", Email: "jane@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	env.userService.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "wrong password"})
+	env.userService.Login(ctx, &models.LoginRequest{Email: "nobody@example.com", Password: testPassword})
+	if _, err := env.userService.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, err := env.userService.UpdateUser(ctx, registered.ID, &models.UpdateUserRequest{Name: "Jane Roe", Password: "a new secret pass"}); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if err := env.userService.DeleteUser(ctx, registered.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	want := []string{
+		models.AuditActionUserRegister,
+		models.AuditActionLoginFailure,
+		models.AuditActionLoginFailure,
+		models.AuditActionLoginSuccess,
+		models.AuditActionUserUpdate,
+		models.AuditActionUserDelete,
+	}
+	if got := env.auditActions(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("audit actions = %v, want %v", got, want)
+	}
+
+	failures, _, _, err := env.auditService.ListEvents(ctx, models.AuditFilter{Action: models.AuditActionLoginFailure}, 1, 10)
+	if err != nil || len(failures) != 2 {
+		t.Fatalf("login failures = %v, %v", failures, err)
+	}
+	reasons := map[string]bool{failures[0].Reason: true, failures[1].Reason: true}
+	if !reasons["invalid password"] || !reasons["unknown email"] {
+		t.Fatalf("login failure reasons = %v", reasons)
+	}
+
+	updates, _, _, err := env.auditService.ListEvents(ctx, models.AuditFilter{Action: models.AuditActionUserUpdate, TargetID: registered.ID}, 1, 10)
+	if err != nil || len(updates) != 1 {
+		t.Fatalf("updates = %v, %v", updates, err)
+	}
+	changes := updates[0].Changes
+	if changes["name"].From != "Jane Doe" || changes["name"].To != "Jane Roe" {
+		t.Fatalf("name change = %+v", changes["name"])
+	}
+	if changes["password"].From != "[redacted]" || changes["password"].To != "[redacted]" {
+		t.Fatalf("password change is not redacted: %+v", changes["password"])
+	}
+}
+
+func TestExportEventsOldestFirst(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	for _, action := range []string{"first", "second", "third"} {
+		env.auditService.Record(ctx, &models.AuditEvent{Action: action, Success: true})
+	}
+
+	var exported []string
+	err := env.auditService.ExportEvents(ctx, models.AuditFilter{}, func(event *models.AuditEvent) error {
+		exported = append(exported, event.Action)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(exported, []string{"first", "second", "third"}) {
+		t.Fatalf("ExportEvents = %v, %v", exported, err)
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-management-system/models"
+)
+
+// LocalDirectory is the name of the authenticator that checks the passwords
+// stored with users
+const LocalDirectory = "local"
+
+// Authenticator checks passwords against a directory of accounts, such as the
+// local users or LDAP. UserService.Login asks its chain of authenticators in
+// order, the local one first.
+type Authenticator interface {
+	// Name identifies the directory; shadow users carry it as the provider of
+	// their linked identity
+	Name() string
+
+	// Authenticate returns the directory's account for the email and
+	// password, or nil when the directory does not accept them. user is the
+	// local user with the email, as Login loaded it, or nil when there is
+	// none. Errors mean the directory could not be asked.
+	Authenticate(ctx context.Context, email, password string, user *models.User) (*ExternalAccount, error)
+}
+
+// ExternalAccount is an account a directory accepted the password of
+type ExternalAccount struct {
+	Subject string // Stable ID of the account in the directory, such as its DN
+	Name    string
+	OrgRole string // Role the directory grants in the default organization: member or admin
+}
+
+// LocalAuthenticator checks passwords against the hashes stored with users.
+// It is the first authenticator of every UserService.
+type LocalAuthenticator struct{}
+
+// NewLocalAuthenticator creates an authenticator for the local users
+func NewLocalAuthenticator() *LocalAuthenticator {
+	return &LocalAuthenticator{}
+}
+
+// Name implements Authenticator
+func (a *LocalAuthenticator) Name() string {
+	return LocalDirectory
+}
+
+// Authenticate implements Authenticator. It checks the password of user, so
+// it accepts exactly the account Login goes on to sign in; service accounts
+// and deactivated users are never accepted.
+func (a *LocalAuthenticator) Authenticate(ctx context.Context, email, password string, user *models.User) (*ExternalAccount, error) {
+	if user == nil || user.ServiceAccount || !user.IsActive || verifyPassword(user.Password, password) != nil {
+		return nil, nil
+	}
+
+	return &ExternalAccount{Subject: user.ID.Hex(), Name: user.Name, OrgRole: user.OrgRole(user.OrgID)}, nil
+}
+
+// syncShadowUser returns the local user that stands for a directory account,
+// so the rest of the API works for it: user, updated with the account's name
+// and default organization role when they changed, or a new user when user is
+// nil. New users join the default organization with the role the directory
+// grants and get a random password that is never handed out. Shadow users
+// are never super-admins.
+func (s *UserService) syncShadowUser(ctx context.Context, directory, email string, account *ExternalAccount, user *models.User) (*models.User, error) {
+	if user != nil {
+		if account.Name != "" && account.Name != user.Name {
+			if _, err := s.applyUpdate(ctx, user, map[string]interface{}{"name": account.Name}); err != nil {
+				return nil, err
+			}
+		}
+		if identity := user.Identity(directory); identity != nil && identity.OrgRole != account.OrgRole {
+			if err := s.applyDirectoryRole(ctx, directory, user, account.OrgRole); err != nil {
+				return nil, err
+			}
+		}
+		return s.userRepo.FindByID(ctx, user.ID.Hex())
+	}
+
+	password, err := generateRandomPassword()
+	if err != nil {
+		return nil, errors.New("failed to create user")
+	}
+	name := account.Name
+	if len(name) < 2 {
+		name = email
+	}
+
+	user, err = s.insertUser(ctx, &models.User{
+		Name:     name,
+		Email:    email,
+		Role:     "user",
+		IsActive: true,
+		Identities: []models.LinkedIdentity{{
+			Provider: directory,
+			Subject:  account.Subject,
+			Email:    email,
+			LinkedAt: time.Now(),
+			OrgRole:  account.OrgRole,
+		}},
+		RandomPassword: true,
+	}, password, account.OrgRole)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionUserCreate,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		OrgID:       s.defaultOrgID,
+		Success:     true,
+		Reason:      "login via " + directory,
+		Changes:     map[string]models.AuditChange{"role": {From: nil, To: user.Role}, "orgRole": {From: nil, To: account.OrgRole}},
+	})
+	s.webhookService.Publish(ctx, models.WebhookEventUserCreated, user)
+
+	return user, nil
+}
+
+// applyDirectoryRole gives a shadow user the default organization role the
+// directory now grants and remembers the grant. It runs only when the grant
+// changed, so roles changed locally in between stay until the directory
+// changes its mind; owners are left alone.
+func (s *UserService) applyDirectoryRole(ctx context.Context, directory string, user *models.User, orgRole string) error {
+	current := user.OrgRole(s.defaultOrgID)
+	if current != "" && current != models.OrgRoleOwner && current != orgRole {
+		if _, err := s.userRepo.SetMembershipRole(ctx, user.ID.Hex(), s.defaultOrgID, orgRole); err != nil {
+			return err
+		}
+
+		s.auditService.Record(ctx, &models.AuditEvent{
+			Action:      models.AuditActionOrgMemberUpdate,
+			TargetID:    user.ID.Hex(),
+			TargetEmail: user.Email,
+			OrgID:       s.defaultOrgID,
+			Success:     true,
+			Reason:      "login via " + directory,
+			Changes:     map[string]models.AuditChange{"orgRole": {From: current, To: orgRole}},
+		})
+	}
+
+	_, err := s.userRepo.SetIdentityOrgRole(ctx, user.ID.Hex(), directory, orgRole)
+	return err
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/tenant"
+)
+
+// EmailChangeService changes a user's email address only once the new
+// address has been confirmed through a single-use link sent to it. The old
+// address is told about the request, and gets a link to revert the change
+// once it is applied, so a stolen session cannot quietly take over an account.
+type EmailChangeService struct {
+	userRepo           *repositories.UserRepository
+	tokenRepo          *repositories.PasswordTokenRepository
+	passwordSetService *PasswordSetService
+	mailer             Mailer
+	auditService       *AuditService
+	webhookService     *WebhookService
+	confirmURL         string
+	confirmTTL         time.Duration
+	revertURL          string
+	revertTTL          time.Duration
+}
+
+// NewEmailChangeService creates a new email change service. confirmURL and
+// revertURL are the pages that receive the tokens as ?token=
+func NewEmailChangeService(userRepo *repositories.UserRepository, tokenRepo *repositories.PasswordTokenRepository, passwordSetService *PasswordSetService, mailer Mailer, auditService *AuditService, webhookService *WebhookService, confirmURL string, confirmTTL time.Duration, revertURL string, revertTTL time.Duration) *EmailChangeService {
+	return &EmailChangeService{
+		userRepo:           userRepo,
+		tokenRepo:          tokenRepo,
+		passwordSetService: passwordSetService,
+		mailer:             mailer,
+		auditService:       auditService,
+		webhookService:     webhookService,
+		confirmURL:         confirmURL,
+		confirmTTL:         confirmTTL,
+		revertURL:          revertURL,
+		revertTTL:          revertTTL,
+	}
+}
+
+// Request stores email as the user's pending email, mails a confirmation
+// link to it and tells the current address. A new request replaces an
+// earlier one. It returns the updated user.
+func (s *EmailChangeService) Request(ctx context.Context, user *models.User, email string) (*models.User, error) {
+	if err := checkEmailAvailable(ctx, s.userRepo, user.ID.Hex(), email); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.Update(ctx, user.ID.Hex(), map[string]interface{}{"pendingEmail": email}); err != nil {
+		return nil, err
+	}
+
+	link, err := s.issueLink(ctx, user, models.PasswordTokenEmailChange, email, s.confirmURL, s.confirmTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	body := fmt.Sprintf(
+		"Hello %s,\n\nConfirm %s as the new email address of your account here:\n\n%s\n\nThe link expires in %s and can be used once. If you did not ask for this, ignore this message.\n",
+		user.Name, email, link, s.confirmTTL,
+	)
+	if err := s.mailer.Send(ctx, email, "Confirm your new email address", body); err != nil {
+		return nil, err
+	}
+
+	notice := fmt.Sprintf(
+		"Hello %s,\n\nA change of your account's email address to %s was requested. It only takes effect once the new address is confirmed.\n\nIf this was not you, change your password now.\n",
+		user.Name, email,
+	)
+	s.notify(ctx, user.Email, "Email change requested", notice)
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionEmailChangeRequest,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"pendingEmail": {From: user.PendingEmail, To: email}},
+	})
+
+	return s.userRepo.FindByID(ctx, user.ID.Hex())
+}
+
+// Confirm consumes a confirmation token and applies the pending email. The
+// old address gets a link to revert the change.
+func (s *EmailChangeService) Confirm(ctx context.Context, req *models.EmailTokenRequest) (*models.UserResponse, error) {
+	if req.Token == "" {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	token, err := s.tokenRepo.Consume(ctx, hashToken(req.Token), models.PasswordTokenEmailChange)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		return nil, errors.New("invalid or expired token")
+	}
+	if !before.IsActive {
+		return nil, errors.New("account is deactivated")
+	}
+
+	user, err := s.userRepo.ConfirmEmail(ctx, token.UserID, token.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordChange(ctx, models.AuditActionEmailChange, before, user)
+
+	link, err := s.issueLink(ctx, user, models.PasswordTokenEmailRevert, before.Email, s.revertURL, s.revertTTL)
+	if err != nil {
+		log.Printf("⚠️  Failed to issue email revert link for user %s: %v", user.ID.Hex(), err)
+		return user.ToUserResponse(), nil
+	}
+	notice := fmt.Sprintf(
+		"Hello %s,\n\nThe email address of your account was changed to %s.\n\nIf this was not you, undo the change here:\n\n%s\n\nThe link expires in %s.\n",
+		user.Name, user.Email, link, s.revertTTL,
+	)
+	s.notify(ctx, before.Email, "Your email address was changed", notice)
+
+	return user.ToUserResponse(), nil
+}
+
+// Revert consumes a revert token and restores the email the account had
+// before the change. A password-set link is mailed to the restored address
+// so the owner can lock out whoever made the change.
+func (s *EmailChangeService) Revert(ctx context.Context, req *models.EmailTokenRequest) (*models.UserResponse, error) {
+	if req.Token == "" {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	token, err := s.tokenRepo.Consume(ctx, hashToken(req.Token), models.PasswordTokenEmailRevert)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		return nil, errors.New("invalid or expired token")
+	}
+
+	user, err := s.userRepo.RevertEmail(ctx, token.UserID, token.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordChange(ctx, models.AuditActionEmailRevert, before, user)
+
+	if err := s.passwordSetService.SendLink(ctx, user); err != nil {
+		log.Printf("⚠️  Failed to send password-set link after email revert for user %s: %v", user.ID.Hex(), err)
+	}
+
+	return user.ToUserResponse(), nil
+}
+
+// issueLink stores a token of the given purpose for the user and returns the link carrying it
+func (s *EmailChangeService) issueLink(ctx context.Context, user *models.User, purpose, email, baseURL string, ttl time.Duration) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.tokenRepo.Create(ctx, &models.PasswordToken{
+		TokenHash: hashToken(token),
+		UserID:    user.ID.Hex(),
+		Purpose:   purpose,
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return baseURL + "?token=" + url.QueryEscape(token), nil
+}
+
+// notify mails an informational message; failures are logged, not returned
+func (s *EmailChangeService) notify(ctx context.Context, to, subject, body string) {
+	if err := s.mailer.Send(ctx, to, subject, body); err != nil {
+		log.Printf("⚠️  Failed to send %q to %s: %v", subject, to, err)
+	}
+}
+
+// recordChange audits and publishes an applied email change
+func (s *EmailChangeService) recordChange(ctx context.Context, action string, before, user *models.User) {
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      action,
+		ActorID:     user.ID.Hex(),
+		ActorEmail:  user.Email,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"email": {From: before.Email, To: user.Email}},
+	})
+	s.webhookService.Publish(ctx, models.WebhookEventUserUpdated, user)
+}
+
+// checkEmailAvailable fails when another user, in any organization, already
+// has the email. The unique email index has the final word when it is written.
+func checkEmailAvailable(ctx context.Context, userRepo *repositories.UserRepository, id, email string) error {
+	existingUser, _ := userRepo.FindByEmail(tenant.AcrossOrgs(ctx), email)
+	if existingUser != nil && existingUser.ID.Hex() != id {
+		return errors.New("email already in use")
+	}
+	return nil
+}
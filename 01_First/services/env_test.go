@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/mongotest"
+	"user-management-system/repositories"
+	"user-management-system/tenant"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPassword passes the test password policy
+const testPassword = "correct horse battery"
+
+// testEnv wires the services the way cmd/main.go does, on an empty in-memory
+// database. Passwords are hashed at the lowest bcrypt cost to keep tests fast.
+type testEnv struct {
+	t  *testing.T
+	db *mongo.Database
+
+	userRepo          *repositories.UserRepository
+	auditRepo         *repositories.AuditRepository
+	passwordTokenRepo *repositories.PasswordTokenRepository
+
+	policy             *PasswordPolicy
+	hasher             PasswordHasher
+	mailer             *recordingMailer
+	auditService       *AuditService
+	webhookService     *WebhookService
+	orgService         *OrganizationService
+	groupService       *GroupService
+	passwordSetService *PasswordSetService
+	emailChangeService *EmailChangeService
+	userService        *UserService
+	invitationService  *InvitationService
+	jobService         *JobService
+	bulkService        *UserBulkService
+	apiKeyService      *APIKeyService
+	defaultOrg         *models.Organization
+}
+
+func newTestEnv(t *testing.T, authenticators ...Authenticator) *testEnv {
+	t.Helper()
+
+	env := &testEnv{
+		t:      t,
+		db:     mongotest.NewDatabase(t),
+		policy: &PasswordPolicy{MinLength: 8},
+		hasher: BcryptHasher{Cost: bcrypt.MinCost},
+		mailer: &recordingMailer{},
+	}
+	env.userRepo = repositories.NewUserRepository(env.db.Collection("users"))
+	env.auditRepo = repositories.NewAuditRepository(env.db.Collection("audit_events"))
+	env.passwordTokenRepo = repositories.NewPasswordTokenRepository(env.db.Collection("password_tokens"))
+
+	env.auditService = NewAuditService(env.auditRepo)
+	env.webhookService = NewWebhookService(repositories.NewWebhookRepository(env.db.Collection("webhooks")), repositories.NewWebhookDeliveryRepository(env.db.Collection("webhook_deliveries")), http.DefaultClient, 3)
+	env.orgService = NewOrganizationService(repositories.NewOrganizationRepository(env.db.Collection("organizations")), env.userRepo, repositories.NewGroupMemberRepository(env.db.Collection("group_members")), env.auditService)
+
+	env.groupService = NewGroupService(repositories.NewGroupRepository(env.db.Collection("groups")), repositories.NewGroupMemberRepository(env.db.Collection("group_members")), env.userRepo, env.auditService)
+
+	var err error
+	env.defaultOrg, err = env.orgService.EnsureDefaultOrganization(context.Background(), "Default")
+	if err != nil {
+		t.Fatalf("EnsureDefaultOrganization: %v", err)
+	}
+
+	env.passwordSetService = NewPasswordSetService(env.userRepo, env.passwordTokenRepo, env.policy, env.hasher, env.mailer, env.auditService, "http://app/set-password", time.Hour)
+	env.emailChangeService = NewEmailChangeService(env.userRepo, env.passwordTokenRepo, env.passwordSetService, env.mailer, env.auditService, env.webhookService, "http://app/confirm-email", time.Hour, "http://app/revert-email", time.Hour)
+	env.userService = NewUserService(env.userRepo, env.auditService, env.webhookService, env.emailChangeService, env.policy, env.hasher, env.defaultOrg.ID.Hex(), authenticators...)
+	env.invitationService = NewInvitationService(repositories.NewInvitationRepository(env.db.Collection("invitations")), env.userRepo, env.userService, env.mailer, env.auditService, env.webhookService, "http://app/accept-invitation", time.Hour)
+	env.jobService = NewJobService(repositories.NewJobRepository(env.db.Collection("jobs")), repositories.NewJobFileRepository(env.db), time.Minute, 3)
+	env.bulkService = NewUserBulkService(env.userRepo, env.userService, env.auditService, env.webhookService, env.passwordSetService, env.jobService)
+	env.apiKeyService = NewAPIKeyService(repositories.NewAPIKeyRepository(env.db.Collection("api_keys")), env.userRepo, env.userService, env.auditService, env.webhookService)
+
+	return env
+}
+
+// createUser creates an active user in the default organization with the
+// platform role and organization role given
+func (env *testEnv) createUser(email, role, orgRole string) *models.User {
+	env.t.Helper()
+
+	user, err := env.userService.insertUser(systemContext(), &models.User{Name: "Test User", Email: email, Role: role, IsActive: true}, testPassword, orgRole)
+	if err != nil {
+		env.t.Fatalf("creating %s: %v", email, err)
+	}
+	return user
+}
+
+// reload reads a user back from the database
+func (env *testEnv) reload(user *models.User) *models.User {
+	env.t.Helper()
+
+	reloaded, err := env.userRepo.FindByID(context.Background(), user.ID.Hex())
+	if err != nil {
+		env.t.Fatalf("reloading %s: %v", user.Email, err)
+	}
+	return reloaded
+}
+
+// auditActions returns the actions of the audit events recorded so far, oldest first
+func (env *testEnv) auditActions() []string {
+	env.t.Helper()
+
+	var actions []string
+	err := env.auditRepo.Stream(context.Background(), models.AuditFilter{}, func(event *models.AuditEvent) error {
+		actions = append(actions, event.Action)
+		return nil
+	})
+	if err != nil {
+		env.t.Fatalf("reading audit events: %v", err)
+	}
+	return actions
+}
+
+// systemContext is a context without a signed-in user, like the one of
+// background workers
+func systemContext() context.Context {
+	return context.Background()
+}
+
+// callerContext is the context of a request the user signed in to the
+// default organization makes, as the JWT middleware builds it
+func (env *testEnv) callerContext(user *models.User) context.Context {
+	return env.callerContextIn(user, env.defaultOrg.ID.Hex())
+}
+
+// callerContextIn is the context of a request the user makes while acting
+// in the organization
+func (env *testEnv) callerContextIn(user *models.User, orgID string) context.Context {
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, user.ID.Hex())
+	ctx = context.WithValue(ctx, middleware.EmailKey, user.Email)
+	ctx = context.WithValue(ctx, middleware.RoleKey, user.EffectiveRole(orgID))
+	ctx = context.WithValue(ctx, middleware.OrgRoleKey, user.OrgRole(orgID))
+	ctx = context.WithValue(ctx, middleware.SuperAdminKey, user.IsSuperAdmin())
+	return tenant.WithOrg(ctx, orgID)
+}
+
+// recordingMailer keeps the messages it is asked to send
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []sentMail
+}
+
+type sentMail struct {
+	to, subject, body string
+}
+
+func (m *recordingMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, sentMail{to: to, subject: subject, body: body})
+	return nil
+}
+
+// token returns the token of the link in the last message sent to an
+// address, which starts with link
+func (m *recordingMailer) token(t *testing.T, to, link string) string {
+	t.Helper()
+
+	messages := m.sent(to)
+	if len(messages) == 0 {
+		t.Fatalf("no message was sent to %s", to)
+	}
+	body := messages[len(messages)-1].body
+	start := strings.Index(body, link+"?token=")
+	if start < 0 {
+		t.Fatalf("message to %s has no %s link: %q", to, link, body)
+	}
+	token := body[start+len(link+"?token="):]
+	return token[:strings.IndexAny(token, "\n ")]
+}
+
+// sent returns the messages sent to an address
+func (m *recordingMailer) sent(to string) []sentMail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var messages []sentMail
+	for _, message := range m.messages {
+		if message.to == to {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"user-management-system/config"
+	"user-management-system/models"
+	"user-management-system/repositories"
+)
+
+// FederationService signs users in through external OpenID Connect
+// providers. A login is started with Start, which remembers the state, nonce
+// and PKCE verifier, and completed with Complete once the provider redirects
+// back with a code. The provider's user is found by a linked identity, linked
+// to the account with the same verified email, or provisioned just in time.
+type FederationService struct {
+	providers      []*oidcProvider
+	stateRepo      *repositories.FederationStateRepository
+	userRepo       *repositories.UserRepository
+	userService    *UserService
+	auditService   *AuditService
+	webhookService *WebhookService
+	redirectURL    string
+	stateTTL       time.Duration
+}
+
+// NewFederationService creates a new federation service for the configured
+// providers. Providers are contacted through client; they redirect users back
+// to redirectURL, and logins in progress expire after stateTTL.
+func NewFederationService(providers []config.FederationProvider, stateRepo *repositories.FederationStateRepository, userRepo *repositories.UserRepository, userService *UserService, auditService *AuditService, webhookService *WebhookService, client *http.Client, redirectURL string, stateTTL time.Duration) (*FederationService, error) {
+	service := &FederationService{
+		stateRepo:      stateRepo,
+		userRepo:       userRepo,
+		userService:    userService,
+		auditService:   auditService,
+		webhookService: webhookService,
+		redirectURL:    redirectURL,
+		stateTTL:       stateTTL,
+	}
+
+	for _, provider := range providers {
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, errors.New("federation provider " + provider.ID + " needs an issuer and a client ID")
+		}
+		if provider.DefaultRole != models.OrgRoleMember && provider.DefaultRole != models.OrgRoleAdmin {
+			return nil, errors.New("federation provider " + provider.ID + " default role must be member or admin")
+		}
+		if provider.ID == LocalDirectory || provider.ID == LDAPDirectory {
+			return nil, errors.New("federation provider ID " + provider.ID + " is reserved")
+		}
+		if service.provider(provider.ID) != nil {
+			return nil, errors.New("federation provider " + provider.ID + " is configured twice")
+		}
+		service.providers = append(service.providers, &oidcProvider{config: provider, client: client})
+	}
+
+	return service, nil
+}
+
+// Providers lists the providers users can sign in with
+func (s *FederationService) Providers() []*models.FederationProviderResponse {
+	providers := make([]*models.FederationProviderResponse, len(s.providers))
+	for i, provider := range s.providers {
+		providers[i] = &models.FederationProviderResponse{ID: provider.config.ID, Name: provider.config.Name}
+	}
+	return providers
+}
+
+// provider returns the configured provider with the given ID, or nil
+func (s *FederationService) provider(id string) *oidcProvider {
+	for _, provider := range s.providers {
+		if provider.config.ID == id {
+			return provider
+		}
+	}
+	return nil
+}
+
+// Start begins a login with a provider and returns where to send the user
+func (s *FederationService) Start(ctx context.Context, providerID string) (*models.FederationStartResponse, error) {
+	provider := s.provider(providerID)
+	if provider == nil {
+		return nil, errors.New("provider not found")
+	}
+
+	state, err := generateToken()
+	if err != nil {
+		return nil, errors.New("failed to start login")
+	}
+	nonce, err := generateToken()
+	if err != nil {
+		return nil, errors.New("failed to start login")
+	}
+	verifier, err := generateToken()
+	if err != nil {
+		return nil, errors.New("failed to start login")
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	authorizationURL, err := provider.authorizationURL(ctx, s.redirectURL, state, nonce, challenge)
+	if err != nil {
+		log.Printf("⚠️  Identity provider %s unavailable: %v", providerID, err)
+		return nil, errors.New("identity provider unavailable")
+	}
+
+	err = s.stateRepo.Create(ctx, &models.FederationState{
+		StateHash:    hashToken(state),
+		Provider:     providerID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(s.stateTTL),
+	})
+	if err != nil {
+		return nil, errors.New("failed to start login")
+	}
+
+	return &models.FederationStartResponse{AuthorizationURL: authorizationURL, State: state, StateHash: hashToken(state)}, nil
+}
+
+// Complete finishes a login with the code the provider returned and returns
+// the signed-in user. stateHash is the StateHash of Start, kept by the
+// browser that started the login: a callback another site forwards to the
+// user's browser with its own state and code is refused before the state is
+// used, so nobody can sign a user in to their account.
+func (s *FederationService) Complete(ctx context.Context, req *models.FederationCallbackRequest, stateHash string) (*models.User, error) {
+	if req.State == "" || req.Code == "" {
+		return nil, errors.New("state and code are required")
+	}
+	if subtle.ConstantTimeCompare([]byte(stateHash), []byte(hashToken(req.State))) != 1 {
+		return nil, errors.New("login was started in another browser")
+	}
+
+	state, err := s.stateRepo.Consume(ctx, hashToken(req.State))
+	if err != nil {
+		return nil, errors.New("invalid or expired state")
+	}
+	provider := s.provider(state.Provider)
+	if provider == nil {
+		return nil, errors.New("provider not found")
+	}
+
+	idToken, err := provider.exchange(ctx, req.Code, state.CodeVerifier, s.redirectURL)
+	if err != nil {
+		log.Printf("⚠️  Code exchange with identity provider %s failed: %v", state.Provider, err)
+		return nil, errors.New("identity provider rejected the login")
+	}
+	claims, err := provider.verify(ctx, idToken, state.Nonce)
+	if err != nil {
+		log.Printf("⚠️  Invalid ID token from identity provider %s: %v", state.Provider, err)
+		return nil, errors.New("identity provider rejected the login")
+	}
+
+	user, err := s.resolveUser(ctx, provider.config, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		s.userService.recordLoginFailure(ctx, user.Email, user, "account deactivated")
+		return nil, errors.New("account is deactivated")
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionLoginSuccess,
+		ActorID:     user.ID.Hex(),
+		ActorEmail:  user.Email,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Reason:      "federated login via " + provider.config.ID,
+	})
+
+	return user, nil
+}
+
+// resolveUser finds the user an upstream identity signs in as: the user it
+// is linked to, else the user with the same email, which the provider must
+// have verified unless it is trusted to and which must not be a super-admin
+// or service account, else a new user when the provider provisions just in
+// time
+func (s *FederationService) resolveUser(ctx context.Context, provider config.FederationProvider, claims *upstreamClaims) (*models.User, error) {
+	user, err := s.userRepo.FindByIdentity(ctx, provider.ID, claims.Subject)
+	if err == nil {
+		return user, nil
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" || !(claims.emailVerified() || provider.TrustEmail) {
+		s.userService.recordLoginFailure(ctx, email, nil, "unverified email from "+provider.ID)
+		return nil, errors.New("identity provider did not verify the email")
+	}
+
+	identity := models.LinkedIdentity{
+		Provider: provider.ID,
+		Subject:  claims.Subject,
+		Email:    email,
+		LinkedAt: time.Now(),
+	}
+
+	// Service accounts only act through API keys, so they are never linked.
+	// Neither are super-admins: a provider that hands out the address would
+	// otherwise get the whole platform.
+	existing, err := s.userRepo.FindByEmail(ctx, email)
+	if err == nil && existing.ServiceAccount {
+		s.userService.recordLoginFailure(ctx, email, existing, "service account")
+		return nil, errors.New("no account for this email")
+	}
+	if err == nil && existing.IsSuperAdmin() {
+		s.userService.recordLoginFailure(ctx, email, existing, "super-admins are not linked by email")
+		return nil, errors.New("no account for this email")
+	}
+	if err == nil {
+		user, err := s.userRepo.AddIdentity(ctx, existing.ID.Hex(), identity)
+		if err != nil {
+			s.userService.recordLoginFailure(ctx, email, existing, err.Error())
+			return nil, err
+		}
+
+		s.auditService.Record(ctx, &models.AuditEvent{
+			Action:      models.AuditActionIdentityLink,
+			ActorID:     user.ID.Hex(),
+			ActorEmail:  user.Email,
+			TargetID:    user.ID.Hex(),
+			TargetEmail: user.Email,
+			Success:     true,
+			Changes:     map[string]models.AuditChange{"identities": {From: nil, To: provider.ID}},
+		})
+		s.webhookService.Publish(ctx, models.WebhookEventUserUpdated, user)
+		return user, nil
+	}
+
+	if !provider.JITProvisioning {
+		s.userService.recordLoginFailure(ctx, email, nil, "no account for federated login via "+provider.ID)
+		return nil, errors.New("no account for this email")
+	}
+
+	// A deleted user keeps their email until purged; a new account cannot take it
+	if s.isDeletedUsersEmail(ctx, email) {
+		s.userService.recordLoginFailure(ctx, email, nil, "deleted account, federated login via "+provider.ID)
+		return nil, errors.New("account is deleted")
+	}
+
+	// The account signs in through the provider; its random password is never
+	// handed out and can only be replaced through a password reset
+	password, err := generateRandomPassword()
+	if err != nil {
+		return nil, errors.New("failed to create user")
+	}
+	name := strings.TrimSpace(claims.Name)
+	if len(name) < 2 {
+		name = strings.SplitN(email, "@", 2)[0]
+	}
+
+	user, err = s.userService.insertUser(ctx, &models.User{
+		Name:           name,
+		Email:          email,
+		Role:           "user",
+		IsActive:       true,
+		Identities:     []models.LinkedIdentity{identity},
+		RandomPassword: true,
+	}, password, provider.DefaultRole)
+	if err != nil {
+		if err.Error() == "email already registered" {
+			if s.isDeletedUsersEmail(ctx, email) {
+				return nil, errors.New("account is deleted")
+			}
+			// Another login for the same email won the race
+			return nil, errors.New("identity is linked to another user")
+		}
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionUserCreate,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Reason:      "federated login via " + provider.ID,
+		Changes:     map[string]models.AuditChange{"role": {From: nil, To: user.Role}},
+	})
+	s.webhookService.Publish(ctx, models.WebhookEventUserCreated, user)
+
+	return user, nil
+}
+
+// isDeletedUsersEmail reports whether email belongs to a soft-deleted user
+func (s *FederationService) isDeletedUsersEmail(ctx context.Context, email string) bool {
+	user, err := s.userRepo.FindByEmailIncludingDeleted(ctx, email)
+	return err == nil && user.DeletedAt != nil
+}
+
+// Unlink removes a user's identity at a provider; the user then signs in
+// with their password or links the identity again through the provider. A
+// user who never set a password must keep another identity to sign in with.
+func (s *FederationService) Unlink(ctx context.Context, userID, providerID string) (*models.UserResponse, error) {
+	// The LDAP identity marks a shadow user, which signs in with the directory's password
+	if providerID == LDAPDirectory {
+		return nil, errors.New("directory identities cannot be unlinked")
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Identity(providerID) == nil {
+		return nil, errors.New("identity not linked")
+	}
+	if user.RandomPassword && len(user.Identities) < 2 {
+		return nil, errors.New("set a password before unlinking your only identity")
+	}
+
+	user, err = s.userRepo.RemoveIdentity(ctx, userID, providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionIdentityUnlink,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"identities": {From: providerID, To: nil}},
+	})
+	s.webhookService.Publish(ctx, models.WebhookEventUserUpdated, user)
+
+	return user.ToUserResponse(), nil
+}
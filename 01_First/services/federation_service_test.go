@@ -0,0 +1,491 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"user-management-system/config"
+	"user-management-system/models"
+	"user-management-system/repositories"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testFederationClientID = "user-management"
+
+// otherSigningKey signs tokens the mock provider does not publish
+var otherSigningKey = sync.OnceValue(func() *SigningKey {
+	key, err := LoadSigningKey("")
+	if err != nil {
+		panic(err)
+	}
+	return key
+})
+
+// mockProvider is an OpenID Connect provider on a test server. Its token
+// endpoint checks the PKCE verifier against the challenge of the last
+// authorization URL and returns an ID token with the claims set for the
+// next login.
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	issuer string // Issuer named in the discovery document
+
+	mu        sync.Mutex
+	challenge string
+	claims    jwt.MapClaims
+	signWith  *SigningKey
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	provider := &mockProvider{t: t}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 provider.issuer,
+			"authorization_endpoint": provider.server.URL + "/authorize",
+			"token_endpoint":         provider.server.URL + "/token",
+			"jwks_uri":               provider.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.JSONWebKeySet{Keys: []models.JSONWebKey{testSigningKey().JWK()}})
+	})
+	mux.HandleFunc("/token", provider.token)
+
+	provider.server = httptest.NewServer(mux)
+	provider.issuer = provider.server.URL
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if r.FormValue("grant_type") != "authorization_code" || r.FormValue("client_id") != testFederationClientID ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := p.signWith.Sign(p.claims)
+	if err != nil {
+		p.t.Errorf("signing the ID token: %v", err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "upstream", "token_type": "Bearer", "id_token": idToken})
+}
+
+// config returns the provider's configuration for the federation service
+func (p *mockProvider) config(id string) config.FederationProvider {
+	return config.FederationProvider{
+		ID:          id,
+		Name:        "Corp",
+		Issuer:      p.server.URL,
+		ClientID:    testFederationClientID,
+		Scopes:      []string{"openid", "email", "profile"},
+		DefaultRole: models.OrgRoleMember,
+	}
+}
+
+// newFederationService wires a federation service for the providers onto
+// the test environment
+func (env *testEnv) newFederationService(providers ...config.FederationProvider) *FederationService {
+	env.t.Helper()
+
+	service, err := NewFederationService(providers, repositories.NewFederationStateRepository(env.db.Collection("federation_states")), env.userRepo, env.userService, env.auditService, env.webhookService, http.DefaultClient, "http://app/login/callback", time.Minute)
+	if err != nil {
+		env.t.Fatalf("NewFederationService: %v", err)
+	}
+	return service
+}
+
+// federatedLogin signs in through the provider with an ID token carrying
+// claims on top of valid defaults; a nil claim value removes the claim
+func (env *testEnv) federatedLogin(service *FederationService, provider *mockProvider, providerID string, claims jwt.MapClaims) (*models.User, error) {
+	env.t.Helper()
+
+	start, err := service.Start(systemContext(), providerID)
+	if err != nil {
+		env.t.Fatalf("Start: %v", err)
+	}
+	authorizationURL, _ := url.Parse(start.AuthorizationURL)
+	query := authorizationURL.Query()
+
+	idClaims := jwt.MapClaims{
+		"iss":            provider.server.URL,
+		"aud":            testFederationClientID,
+		"sub":            "upstream-1",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          query.Get("nonce"),
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(idClaims, name)
+		} else {
+			idClaims[name] = value
+		}
+	}
+
+	provider.mu.Lock()
+	provider.challenge = query.Get("code_challenge")
+	provider.claims = idClaims
+	if provider.signWith == nil {
+		provider.signWith = testSigningKey()
+	}
+	provider.mu.Unlock()
+
+	return service.Complete(systemContext(), &models.FederationCallbackRequest{State: start.State, Code: "upstream-code"}, start.StateHash)
+}
+
+// countActions counts the audit events with the given action
+func countActions(actions []string, action string) int {
+	n := 0
+	for _, a := range actions {
+		if a == action {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFederationStart(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	service := env.newFederationService(provider.config("corp"))
+
+	start, err := service.Start(systemContext(), "corp")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	authorizationURL, _ := url.Parse(start.AuthorizationURL)
+	query := authorizationURL.Query()
+	if authorizationURL.Scheme+"://"+authorizationURL.Host+authorizationURL.Path != provider.server.URL+"/authorize" ||
+		query.Get("client_id") != testFederationClientID || query.Get("redirect_uri") != "http://app/login/callback" ||
+		query.Get("state") != start.State || query.Get("nonce") == "" || query.Get("code_challenge_method") != "S256" ||
+		query.Get("scope") != "openid email profile" {
+		t.Fatalf("authorization URL = %s", start.AuthorizationURL)
+	}
+
+	if _, err := service.Start(systemContext(), "other"); err == nil || err.Error() != "provider not found" {
+		t.Fatalf("Start with an unknown provider: err = %v", err)
+	}
+}
+
+func TestFederationDiscoveryMustNameTheIssuer(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	provider.issuer = "https://impostor.example.com"
+	service := env.newFederationService(provider.config("corp"))
+
+	if _, err := service.Start(systemContext(), "corp"); err == nil || err.Error() != "identity provider unavailable" {
+		t.Fatalf("Start with a discovery document for another issuer: err = %v", err)
+	}
+}
+
+func TestOIDCProviderFetchesWithoutTheLock(t *testing.T) {
+	provider := newMockProvider(t)
+	upstream := &oidcProvider{config: provider.config("corp"), client: http.DefaultClient}
+	kid := testSigningKey().JWK().Kid
+	if _, err := upstream.key(systemContext(), kid); err != nil {
+		t.Fatalf("key: %v", err)
+	}
+
+	// A token with an unknown key makes the next lookup fetch the key set
+	// again, from a provider that hangs
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hanging.Close()
+	defer close(release)
+	upstream.mu.Lock()
+	upstream.metadata = &oidcProviderMetadata{Issuer: upstream.metadata.Issuer, JWKSURI: hanging.URL}
+	upstream.keysFetchedAt = time.Time{}
+	upstream.mu.Unlock()
+
+	fetching := make(chan struct{})
+	go func() {
+		defer close(fetching)
+		upstream.key(systemContext(), "rotated")
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Logins with the known key go on meanwhile
+	done := make(chan error, 1)
+	go func() {
+		_, err := upstream.key(systemContext(), kid)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("key: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a cached key waited for another login's fetch")
+	}
+
+	select {
+	case <-fetching:
+		t.Fatal("the fetch from the hanging provider returned")
+	default:
+	}
+}
+
+func TestFederatedLoginRejectsInvalidIDTokens(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	service := env.newFederationService(provider.config("corp"))
+	env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	for _, test := range []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"nonce mismatch", jwt.MapClaims{"nonce": "replayed"}},
+		{"no nonce", jwt.MapClaims{"nonce": nil}},
+		{"another issuer", jwt.MapClaims{"iss": "https://impostor.example.com"}},
+		{"another audience", jwt.MapClaims{"aud": "another-client"}},
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{"no subject", jwt.MapClaims{"sub": nil}},
+	} {
+		if _, err := env.federatedLogin(service, provider, "corp", test.claims); err == nil || err.Error() != "identity provider rejected the login" {
+			t.Errorf("%s: err = %v", test.name, err)
+		}
+	}
+
+	// A token signed with a key the provider does not publish
+	provider.signWith = otherSigningKey()
+	if _, err := env.federatedLogin(service, provider, "corp", nil); err == nil || err.Error() != "identity provider rejected the login" {
+		t.Fatalf("unknown signing key: err = %v", err)
+	}
+}
+
+func TestFederationStateIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	service := env.newFederationService(provider.config("corp"))
+	env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	start, _ := service.Start(systemContext(), "corp")
+	if _, err := service.Complete(systemContext(), &models.FederationCallbackRequest{State: start.State}, start.StateHash); err == nil || err.Error() != "state and code are required" {
+		t.Fatalf("Complete without a code: err = %v", err)
+	}
+	service.Complete(systemContext(), &models.FederationCallbackRequest{State: start.State, Code: "upstream-code"}, start.StateHash)
+	if _, err := service.Complete(systemContext(), &models.FederationCallbackRequest{State: start.State, Code: "upstream-code"}, start.StateHash); err == nil || err.Error() != "invalid or expired state" {
+		t.Fatalf("completing twice: err = %v", err)
+	}
+}
+
+func TestFederationStateIsBoundToTheBrowser(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	service := env.newFederationService(provider.config("corp"))
+	env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	// An attacker starts a login and hands their state and code to the
+	// user's browser, which started no login or another one
+	attacker, _ := service.Start(systemContext(), "corp")
+	victim, _ := service.Start(systemContext(), "corp")
+	for _, stateHash := range []string{"", victim.StateHash, attacker.State} {
+		if _, err := service.Complete(systemContext(), &models.FederationCallbackRequest{State: attacker.State, Code: "upstream-code"}, stateHash); err == nil || err.Error() != "login was started in another browser" {
+			t.Errorf("state hash %q: err = %v", stateHash, err)
+		}
+	}
+
+	// The refused callbacks left the attacker's state unused
+	if _, err := service.stateRepo.Consume(systemContext(), attacker.StateHash); err != nil {
+		t.Fatalf("the state was used up: %v", err)
+	}
+}
+
+func TestFederatedLoginNeedsVerifiedEmail(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	trusted := provider.config("trusted")
+	trusted.TrustEmail = true
+	service := env.newFederationService(provider.config("corp"), trusted)
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	for _, verified := range []interface{}{false, "false", nil} {
+		if _, err := env.federatedLogin(service, provider, "corp", jwt.MapClaims{"email_verified": verified}); err == nil || err.Error() != "identity provider did not verify the email" {
+			t.Errorf("email_verified %v: err = %v", verified, err)
+		}
+	}
+	if linked := env.reload(jane); len(linked.Identities) != 0 {
+		t.Fatalf("an unverified email was linked: %+v", linked.Identities)
+	}
+
+	// Some providers send the string "true"
+	if user, err := env.federatedLogin(service, provider, "corp", jwt.MapClaims{"email_verified": "true"}); err != nil || user.ID != jane.ID {
+		t.Fatalf("email_verified \"true\": user = %+v, err = %v", user, err)
+	}
+
+	// Trusted providers need no email_verified
+	if user, err := env.federatedLogin(service, provider, "trusted", jwt.MapClaims{"sub": "upstream-2", "email_verified": nil}); err != nil || user.ID != jane.ID {
+		t.Fatalf("trusted provider: user = %+v, err = %v", user, err)
+	}
+}
+
+func TestFederatedLoginLinksExistingUser(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	service := env.newFederationService(provider.config("corp"))
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	user, err := env.federatedLogin(service, provider, "corp", jwt.MapClaims{"email": "Jane@Example.com"})
+	if err != nil || user.ID != jane.ID {
+		t.Fatalf("first login: user = %+v, err = %v", user, err)
+	}
+	linked := env.reload(jane)
+	if len(linked.Identities) != 1 || linked.Identities[0].Provider != "corp" || linked.Identities[0].Subject != "upstream-1" {
+		t.Fatalf("identities = %+v", linked.Identities)
+	}
+
+	// Later logins follow the linked identity, whatever email the provider reports
+	user, err = env.federatedLogin(service, provider, "corp", jwt.MapClaims{"email": "jane.doe@corp.example.com"})
+	if err != nil || user.ID != jane.ID {
+		t.Fatalf("login after the upstream email changed: user = %+v, err = %v", user, err)
+	}
+
+	// Another identity with the same email cannot take over the account
+	if _, err := env.federatedLogin(service, provider, "corp", jwt.MapClaims{"sub": "upstream-2"}); err == nil {
+		t.Fatal("a second identity of the same provider was linked")
+	}
+
+	// Deactivated users cannot sign in through the provider
+	if err := env.userRepo.Update(systemContext(), jane.ID.Hex(), bson.M{"isActive": false}); err != nil {
+		t.Fatalf("deactivating: %v", err)
+	}
+	if _, err := env.federatedLogin(service, provider, "corp", nil); err == nil || err.Error() != "account is deactivated" {
+		t.Fatalf("deactivated user: err = %v", err)
+	}
+}
+
+func TestFederatedLoginNeverLinksSuperAdminsOrServiceAccounts(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	service := env.newFederationService(provider.config("corp"))
+	root := env.createUser("root@example.com", "admin", models.OrgRoleOwner)
+	serviceAccount, err := env.apiKeyService.CreateServiceAccount(env.callerContext(root), &models.CreateServiceAccountRequest{Name: "HR sync"})
+	if err != nil {
+		t.Fatalf("CreateServiceAccount: %v", err)
+	}
+
+	for _, account := range []*models.User{root, serviceAccount} {
+		if _, err := env.federatedLogin(service, provider, "corp", jwt.MapClaims{"sub": account.ID.Hex(), "email": account.Email}); err == nil || err.Error() != "no account for this email" {
+			t.Errorf("login as %s: err = %v", account.Email, err)
+		}
+		if linked := env.reload(account); len(linked.Identities) != 0 {
+			t.Errorf("%s was linked: %+v", account.Email, linked.Identities)
+		}
+	}
+}
+
+func TestJustInTimeProvisioning(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	jit := provider.config("jit")
+	jit.JITProvisioning = true
+	jit.DefaultRole = models.OrgRoleAdmin
+	service := env.newFederationService(provider.config("corp"), jit)
+
+	if _, err := env.federatedLogin(service, provider, "corp", nil); err == nil || err.Error() != "no account for this email" {
+		t.Fatalf("unknown user without provisioning: err = %v", err)
+	}
+
+	user, err := env.federatedLogin(service, provider, "jit", nil)
+	if err != nil {
+		t.Fatalf("provisioning: %v", err)
+	}
+	if user.Email != "jane@example.com" || user.Name != "Jane Doe" || user.IsSuperAdmin() || !user.IsActive ||
+		user.OrgRole(env.defaultOrg.ID.Hex()) != models.OrgRoleAdmin || len(user.Identities) != 1 || user.Identities[0].Provider != "jit" {
+		t.Fatalf("provisioned user = %+v", user)
+	}
+
+	// The next login finds the provisioned user by its identity
+	again, err := env.federatedLogin(service, provider, "jit", jwt.MapClaims{"name": "Renamed"})
+	if err != nil || again.ID != user.ID {
+		t.Fatalf("second login: user = %+v, err = %v", again, err)
+	}
+	if created := countActions(env.auditActions(), models.AuditActionUserCreate); created != 1 {
+		t.Fatalf("%d users were created, want 1", created)
+	}
+}
+
+func TestFederatedLoginDoesNotReuseDeletedUsersEmail(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	jit := provider.config("jit")
+	jit.JITProvisioning = true
+	service := env.newFederationService(jit)
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+	if err := env.userService.DeleteUser(systemContext(), jane.ID.Hex()); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	if _, err := env.federatedLogin(service, provider, "jit", nil); err == nil || err.Error() != "account is deleted" {
+		t.Fatalf("login with a deleted user's email: err = %v", err)
+	}
+	if created := countActions(env.auditActions(), models.AuditActionUserCreate); created != 0 {
+		t.Fatalf("%d users were created, want 0", created)
+	}
+}
+
+func TestUnlinkKeepsAWayToSignIn(t *testing.T) {
+	env := newTestEnv(t)
+	provider := newMockProvider(t)
+	jit := provider.config("jit")
+	jit.JITProvisioning = true
+	service := env.newFederationService(provider.config("corp"), jit)
+
+	jane, err := env.federatedLogin(service, provider, "jit", nil)
+	if err != nil {
+		t.Fatalf("provisioning: %v", err)
+	}
+	ctx := env.callerContext(jane)
+
+	// Nobody knows the provisioned user's password
+	if _, err := service.Unlink(ctx, jane.ID.Hex(), "jit"); err == nil || err.Error() != "set a password before unlinking your only identity" {
+		t.Fatalf("unlinking the only identity: err = %v", err)
+	}
+
+	// Another identity to sign in with will do
+	if _, err := env.federatedLogin(service, provider, "corp", nil); err != nil {
+		t.Fatalf("linking corp: %v", err)
+	}
+	if _, err := service.Unlink(ctx, jane.ID.Hex(), "jit"); err != nil {
+		t.Fatalf("unlinking jit: %v", err)
+	}
+	if _, err := service.Unlink(ctx, jane.ID.Hex(), "corp"); err == nil || err.Error() != "set a password before unlinking your only identity" {
+		t.Fatalf("unlinking the last identity: err = %v", err)
+	}
+
+	// So will a password of their own
+	if err := env.passwordSetService.SendLink(systemContext(), env.reload(jane)); err != nil {
+		t.Fatalf("SendLink: %v", err)
+	}
+	token := env.mailer.token(t, "jane@example.com", "http://app/set-password")
+	if _, err := env.passwordSetService.SetPassword(systemContext(), &models.SetPasswordRequest{Token: token, Password: testPassword}); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	unlinked, err := service.Unlink(ctx, jane.ID.Hex(), "corp")
+	if err != nil || len(unlinked.Identities) != 0 {
+		t.Fatalf("unlinking after setting a password: user = %+v, err = %v", unlinked, err)
+	}
+}
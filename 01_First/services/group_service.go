@@ -0,0 +1,590 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/tenant"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GroupService manages groups, their nesting and their members within the
+// organization ctx acts in
+type GroupService struct {
+	groupRepo    *repositories.GroupRepository
+	memberRepo   *repositories.GroupMemberRepository
+	userRepo     *repositories.UserRepository
+	auditService *AuditService
+}
+
+// NewGroupService creates a new group service
+func NewGroupService(groupRepo *repositories.GroupRepository, memberRepo *repositories.GroupMemberRepository, userRepo *repositories.UserRepository, auditService *AuditService) *GroupService {
+	return &GroupService{
+		groupRepo:    groupRepo,
+		memberRepo:   memberRepo,
+		userRepo:     userRepo,
+		auditService: auditService,
+	}
+}
+
+// CreateGroup creates a group, optionally as a subgroup of an existing one
+func (s *GroupService) CreateGroup(ctx context.Context, req *models.CreateGroupRequest) (*models.Group, error) {
+	orgID := tenant.OrgID(ctx)
+	if orgID == "" {
+		return nil, errors.New("organization is required")
+	}
+
+	name, description, err := validateGroupFields(req.Name, req.Description)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ParentID != "" {
+		if _, err := s.parent(ctx, req.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	group := &models.Group{
+		OrgID:       orgID,
+		Name:        name,
+		Description: description,
+		ParentID:    req.ParentID,
+	}
+	if err := s.groupRepo.Create(ctx, group); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   models.AuditActionGroupCreate,
+		TargetID: group.ID.Hex(),
+		Success:  true,
+		Changes:  map[string]models.AuditChange{"name": {From: nil, To: name}},
+	})
+
+	return group, nil
+}
+
+// GetGroup retrieves a group by ID
+func (s *GroupService) GetGroup(ctx context.Context, id string) (*models.Group, error) {
+	return s.groupRepo.FindByID(ctx, id)
+}
+
+// ListGroups retrieves groups matching the filter with pagination
+func (s *GroupService) ListGroups(ctx context.Context, filter models.GroupFilter, page, limit int) ([]*models.Group, int, int64, error) {
+	// Validate pagination parameters
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100 // Max limit
+	}
+
+	groups, total, err := s.groupRepo.Find(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// Calculate total pages
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+
+	return groups, totalPages, total, nil
+}
+
+// UpdateGroup renames, describes or moves a group. Moving a group below
+// itself or one of its subgroups is refused.
+func (s *GroupService) UpdateGroup(ctx context.Context, id string, req *models.UpdateGroupRequest) (*models.Group, error) {
+	group, err := s.groupRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	changes := map[string]models.AuditChange{}
+
+	if req.Name != nil || req.Description != nil {
+		name, description := group.Name, group.Description
+		if req.Name != nil {
+			name = *req.Name
+		}
+		if req.Description != nil {
+			description = *req.Description
+		}
+		if name, description, err = validateGroupFields(name, description); err != nil {
+			return nil, err
+		}
+
+		if name != group.Name {
+			set["name"] = name
+			changes["name"] = models.AuditChange{From: group.Name, To: name}
+		}
+		if description != group.Description {
+			set["description"] = description
+			changes["description"] = models.AuditChange{From: group.Description, To: description}
+		}
+	}
+
+	if req.ParentID != nil && *req.ParentID != group.ParentID {
+		parentID := *req.ParentID
+		if parentID == "" {
+			unset["parentId"] = ""
+		} else {
+			if err := s.checkParent(ctx, group, parentID); err != nil {
+				return nil, err
+			}
+			set["parentId"] = parentID
+		}
+		changes["parentId"] = models.AuditChange{From: group.ParentID, To: parentID}
+	}
+
+	if len(changes) == 0 {
+		return group, nil
+	}
+
+	updated, err := s.groupRepo.Update(ctx, group.ID, set, unset)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   models.AuditActionGroupUpdate,
+		TargetID: group.ID.Hex(),
+		Success:  true,
+		Changes:  changes,
+	})
+
+	return updated, nil
+}
+
+// DeleteGroup deletes a group without subgroups, and its memberships
+func (s *GroupService) DeleteGroup(ctx context.Context, id string) error {
+	group, err := s.groupRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	children, err := s.groupRepo.CountChildren(ctx, group.ID.Hex())
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return errors.New("group has subgroups")
+	}
+
+	if err := s.groupRepo.Delete(ctx, group.ID); err != nil {
+		return err
+	}
+	if err := s.memberRepo.RemoveGroup(ctx, group.ID.Hex()); err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   models.AuditActionGroupDelete,
+		TargetID: group.ID.Hex(),
+		Success:  true,
+		Changes:  map[string]models.AuditChange{"name": {From: group.Name, To: nil}},
+	})
+
+	return nil
+}
+
+// ListMembers retrieves the direct members of a group with pagination.
+// Memberships of users who have since been deleted are left out of the page.
+func (s *GroupService) ListMembers(ctx context.Context, id string, page, limit int) ([]*models.GroupMemberResponse, int, int64, error) {
+	group, err := s.groupRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// Validate pagination parameters
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100 // Max limit
+	}
+
+	members, total, err := s.memberRepo.FindByGroup(ctx, group.ID.Hex(), page, limit)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	userIDs := make([]string, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+	}
+	users, err := s.findUsers(ctx, userIDs)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// Non-admins see the public profile of the other members
+	full := middleware.GetRole(ctx) == "admin"
+	viewerID := middleware.GetUserID(ctx)
+
+	responses := make([]*models.GroupMemberResponse, 0, len(members))
+	for _, member := range members {
+		user, ok := users[member.UserID]
+		if !ok {
+			continue
+		}
+		response := &models.GroupMemberResponse{
+			UserID:    member.UserID,
+			Name:      user.Name,
+			Role:      member.Role,
+			CreatedAt: member.CreatedAt,
+		}
+		if full || member.UserID == viewerID {
+			isActive := user.IsActive
+			response.Email = user.Email
+			response.IsActive = &isActive
+		}
+		responses = append(responses, response)
+	}
+
+	// Calculate total pages
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+
+	return responses, totalPages, total, nil
+}
+
+// AddMembers adds users of the organization to a group, or changes the role
+// of those already in it. Only organization admins may make group admins.
+func (s *GroupService) AddMembers(ctx context.Context, id string, req *models.GroupMembersRequest) (*models.GroupMembersResult, error) {
+	group, err := s.groupRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	role := req.Role
+	if role == "" {
+		role = models.GroupRoleMember
+	}
+	if role != models.GroupRoleMember && role != models.GroupRoleAdmin {
+		return nil, errors.New("role must be either admin or member")
+	}
+	if role == models.GroupRoleAdmin && middleware.GetRole(ctx) != "admin" {
+		return nil, errors.New("only admins can grant the group admin role")
+	}
+
+	result, userIDs, err := s.checkMembersRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only users the caller can see, i.e. members of the organization, can join
+	users, err := s.findUsers(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	known := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if _, ok := users[userID]; !ok {
+			result.Errors = append(result.Errors, models.GroupMemberError{UserID: userID, Error: "user not found"})
+			continue
+		}
+		known = append(known, userID)
+	}
+
+	changed, err := s.memberRepo.Upsert(ctx, group, known, role, middleware.GetUserID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	result.Changed = int(changed)
+	result.Skipped = len(known) - result.Changed
+
+	s.recordMembersChange(ctx, models.AuditActionGroupMemberAdd, group, fmt.Sprintf("added or updated %d users as %s", result.Changed, role))
+	return result, nil
+}
+
+// RemoveMembers removes users from a group. Users who are not direct members are skipped.
+func (s *GroupService) RemoveMembers(ctx context.Context, id string, req *models.GroupMembersRequest) (*models.GroupMembersResult, error) {
+	group, err := s.groupRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, userIDs, err := s.checkMembersRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := s.memberRepo.Remove(ctx, group.ID.Hex(), userIDs)
+	if err != nil {
+		return nil, err
+	}
+	result.Changed = int(removed)
+	result.Skipped = len(userIDs) - result.Changed
+
+	s.recordMembersChange(ctx, models.AuditActionGroupMemberRemove, group, fmt.Sprintf("removed %d users", result.Changed))
+	return result, nil
+}
+
+// UserGroups lists the groups a user belongs to: the groups they are a direct
+// member of and, marked as inherited, the ancestors of those groups
+func (s *GroupService) UserGroups(ctx context.Context, userID string) ([]*models.UserGroupResponse, error) {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	memberships, err := s.memberRepo.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.hierarchy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []*models.UserGroupResponse{}
+	seen := map[string]bool{}
+	for _, membership := range memberships {
+		if group, ok := groups[membership.GroupID]; ok {
+			responses = append(responses, &models.UserGroupResponse{Group: group, Role: membership.Role})
+			seen[membership.GroupID] = true
+		}
+	}
+	for _, membership := range memberships {
+		for _, ancestorID := range ancestors(groups, membership.GroupID) {
+			if !seen[ancestorID] {
+				responses = append(responses, &models.UserGroupResponse{Group: groups[ancestorID], Inherited: true})
+				seen[ancestorID] = true
+			}
+		}
+	}
+
+	return responses, nil
+}
+
+// MemberUserIDs returns the users who are members of a group or of any of its subgroups
+func (s *GroupService) MemberUserIDs(ctx context.Context, id string) ([]primitive.ObjectID, error) {
+	group, err := s.groupRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.hierarchy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs, err := s.memberRepo.UserIDs(ctx, append(descendants(groups, group.ID.Hex()), group.ID.Hex()))
+	if err != nil {
+		return nil, err
+	}
+
+	objectIDs := make([]primitive.ObjectID, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if objectID, err := primitive.ObjectIDFromHex(userID); err == nil {
+			objectIDs = append(objectIDs, objectID)
+		}
+	}
+	return objectIDs, nil
+}
+
+// IsGroupAdmin reports whether the user is an admin of the group or of one
+// of its ancestors. It implements middleware.GroupAuthorizer.
+func (s *GroupService) IsGroupAdmin(ctx context.Context, groupID, userID string) (bool, error) {
+	memberships, err := s.memberRepo.FindByUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	adminOf := map[string]bool{}
+	for _, membership := range memberships {
+		if membership.Role == models.GroupRoleAdmin {
+			adminOf[membership.GroupID] = true
+		}
+	}
+	if len(adminOf) == 0 {
+		return false, nil
+	}
+	if adminOf[groupID] {
+		return true, nil
+	}
+
+	groups, err := s.hierarchy(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, ancestorID := range ancestors(groups, groupID) {
+		if adminOf[ancestorID] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parent loads a prospective parent group, reporting a missing one as a bad request
+func (s *GroupService) parent(ctx context.Context, id string) (*models.Group, error) {
+	parent, err := s.groupRepo.FindByID(ctx, id)
+	if err != nil && (err.Error() == "group not found" || err.Error() == "invalid group ID") {
+		return nil, errors.New("parent group not found")
+	}
+	return parent, err
+}
+
+// checkParent refuses to move a group below itself or one of its subgroups
+func (s *GroupService) checkParent(ctx context.Context, group *models.Group, parentID string) error {
+	if _, err := s.parent(ctx, parentID); err != nil {
+		return err
+	}
+
+	groups, err := s.hierarchy(ctx)
+	if err != nil {
+		return err
+	}
+	if parentID == group.ID.Hex() {
+		return errors.New("group hierarchy cannot contain cycles")
+	}
+	for _, ancestorID := range ancestors(groups, parentID) {
+		if ancestorID == group.ID.Hex() {
+			return errors.New("group hierarchy cannot contain cycles")
+		}
+	}
+	return nil
+}
+
+// checkMembersRequest validates the user IDs of a bulk membership change. It
+// returns the result with invalid IDs already reported, and the valid IDs
+// without duplicates.
+func (s *GroupService) checkMembersRequest(req *models.GroupMembersRequest) (*models.GroupMembersResult, []string, error) {
+	if len(req.UserIDs) == 0 {
+		return nil, nil, errors.New("userIds is required")
+	}
+	if len(req.UserIDs) > maxGroupMembersPerRequest {
+		return nil, nil, fmt.Errorf("at most %d userIds per request", maxGroupMembersPerRequest)
+	}
+
+	result := &models.GroupMembersResult{Errors: []models.GroupMemberError{}}
+	userIDs := make([]string, 0, len(req.UserIDs))
+	seen := map[string]bool{}
+	for _, userID := range req.UserIDs {
+		if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+			result.Errors = append(result.Errors, models.GroupMemberError{UserID: userID, Error: "invalid user ID"})
+			continue
+		}
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	return result, userIDs, nil
+}
+
+// findUsers loads the users with the given IDs that ctx can see, keyed by ID
+func (s *GroupService) findUsers(ctx context.Context, userIDs []string) (map[string]*models.User, error) {
+	users := map[string]*models.User{}
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	filter := models.UserFilter{}
+	for _, userID := range userIDs {
+		if objectID, err := primitive.ObjectIDFromHex(userID); err == nil {
+			filter.IDs = append(filter.IDs, objectID)
+		}
+	}
+
+	found, _, err := s.userRepo.FindByFilter(ctx, filter, 1, len(filter.IDs))
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range found {
+		users[user.ID.Hex()] = user
+	}
+	return users, nil
+}
+
+// hierarchy loads the groups of the organization ctx acts in, keyed by ID
+func (s *GroupService) hierarchy(ctx context.Context) (map[string]*models.Group, error) {
+	groups, err := s.groupRepo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.Group, len(groups))
+	for _, group := range groups {
+		byID[group.ID.Hex()] = group
+	}
+	return byID, nil
+}
+
+// recordMembersChange audits a bulk membership change of a group
+func (s *GroupService) recordMembersChange(ctx context.Context, action string, group *models.Group, reason string) {
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   action,
+		TargetID: group.ID.Hex(),
+		Success:  true,
+		Reason:   reason,
+	})
+}
+
+// maxGroupMembersPerRequest bounds bulk membership changes
+const maxGroupMembersPerRequest = 500
+
+// validateGroupFields trims and validates a group name and description
+func validateGroupFields(name, description string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	description = strings.TrimSpace(description)
+
+	if len(name) < 2 || len(name) > 100 {
+		return "", "", errors.New("name must be between 2 and 100 characters")
+	}
+	if len(description) > 500 {
+		return "", "", errors.New("description must be at most 500 characters")
+	}
+	return name, description, nil
+}
+
+// ancestors returns the IDs of a group's ancestors, nearest first. A cycle
+// left behind by concurrent moves ends the walk instead of looping.
+func ancestors(groups map[string]*models.Group, id string) []string {
+	var out []string
+	visited := map[string]bool{id: true}
+	for group, ok := groups[id]; ok && group.ParentID != "" && !visited[group.ParentID]; group, ok = groups[group.ParentID] {
+		visited[group.ParentID] = true
+		out = append(out, group.ParentID)
+	}
+	return out
+}
+
+// descendants returns the IDs of every subgroup below a group
+func descendants(groups map[string]*models.Group, id string) []string {
+	children := map[string][]string{}
+	for groupID, group := range groups {
+		if group.ParentID != "" {
+			children[group.ParentID] = append(children[group.ParentID], groupID)
+		}
+	}
+
+	var out []string
+	visited := map[string]bool{id: true}
+	queue := []string{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range children[current] {
+			if !visited[child] {
+				visited[child] = true
+				out = append(out, child)
+				queue = append(queue, child)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"user-management-system/models"
+)
+
+// createGroup creates a group, below parent when it is set
+func (env *testEnv) createGroup(ctx context.Context, name string, parent *models.Group) *models.Group {
+	env.t.Helper()
+
+	req := &models.CreateGroupRequest{Name: name}
+	if parent != nil {
+		req.ParentID = parent.ID.Hex()
+	}
+	group, err := env.groupService.CreateGroup(ctx, req)
+	if err != nil {
+		env.t.Fatalf("creating group %s: %v", name, err)
+	}
+	return group
+}
+
+func TestGroupHierarchyRejectsCycles(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin))
+
+	engineering := env.createGroup(ctx, "Engineering", nil)
+	backend := env.createGroup(ctx, "Backend", engineering)
+	databases := env.createGroup(ctx, "Databases", backend)
+
+	for _, parent := range []*models.Group{engineering, databases} {
+		parentID := parent.ID.Hex()
+		if _, err := env.groupService.UpdateGroup(ctx, engineering.ID.Hex(), &models.UpdateGroupRequest{ParentID: &parentID}); err == nil || err.Error() != "group hierarchy cannot contain cycles" {
+			t.Errorf("moving Engineering below %s: err = %v", parent.Name, err)
+		}
+	}
+
+	missing := "65ab0000567890abcdef9999"
+	if _, err := env.groupService.CreateGroup(ctx, &models.CreateGroupRequest{Name: "Orphan", ParentID: missing}); err == nil || err.Error() != "parent group not found" {
+		t.Fatalf("creating a group below a missing parent: err = %v", err)
+	}
+
+	if err := env.groupService.DeleteGroup(ctx, backend.ID.Hex()); err == nil || err.Error() != "group has subgroups" {
+		t.Fatalf("deleting a group with subgroups: err = %v", err)
+	}
+
+	// Moving to the top level is always possible
+	top := ""
+	moved, err := env.groupService.UpdateGroup(ctx, databases.ID.Hex(), &models.UpdateGroupRequest{ParentID: &top})
+	if err != nil || moved.ParentID != "" {
+		t.Fatalf("moving Databases to the top level = %+v, %v", moved, err)
+	}
+	if err := env.groupService.DeleteGroup(ctx, backend.ID.Hex()); err != nil {
+		t.Fatalf("deleting a group without subgroups: %v", err)
+	}
+}
+
+func TestGroupMembershipIsInherited(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin))
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+	john := env.createUser("john@example.com", "user", models.OrgRoleMember)
+
+	engineering := env.createGroup(ctx, "Engineering", nil)
+	backend := env.createGroup(ctx, "Backend", engineering)
+
+	result, err := env.groupService.AddMembers(ctx, backend.ID.Hex(), &models.GroupMembersRequest{UserIDs: []string{jane.ID.Hex(), jane.ID.Hex(), "65ab0000567890abcdef9999", "nope"}})
+	if err != nil || result.Changed != 1 || len(result.Errors) != 2 {
+		t.Fatalf("AddMembers = %+v, %v", result, err)
+	}
+	env.groupService.AddMembers(ctx, engineering.ID.Hex(), &models.GroupMembersRequest{UserIDs: []string{john.ID.Hex()}})
+
+	// Adding someone again is a no-op
+	if again, _ := env.groupService.AddMembers(ctx, backend.ID.Hex(), &models.GroupMembersRequest{UserIDs: []string{jane.ID.Hex()}}); again.Changed != 0 || again.Skipped != 1 {
+		t.Fatalf("adding an existing member = %+v", again)
+	}
+
+	groups, err := env.groupService.UserGroups(ctx, jane.ID.Hex())
+	if err != nil || len(groups) != 2 {
+		t.Fatalf("UserGroups = %+v, %v", groups, err)
+	}
+	if groups[0].ID != backend.ID || groups[0].Inherited || groups[0].Role != models.GroupRoleMember || groups[1].ID != engineering.ID || !groups[1].Inherited {
+		t.Fatalf("jane's groups = %+v, %+v", groups[0], groups[1])
+	}
+
+	// Members of a subgroup are members of the groups above it
+	if ids, _ := env.groupService.MemberUserIDs(ctx, engineering.ID.Hex()); len(ids) != 2 {
+		t.Fatalf("members of Engineering = %v, want jane and john", ids)
+	}
+	if ids, _ := env.groupService.MemberUserIDs(ctx, backend.ID.Hex()); len(ids) != 1 || ids[0] != jane.ID {
+		t.Fatalf("members of Backend = %v, want jane", ids)
+	}
+
+	removed, err := env.groupService.RemoveMembers(ctx, backend.ID.Hex(), &models.GroupMembersRequest{UserIDs: []string{jane.ID.Hex(), john.ID.Hex()}})
+	if err != nil || removed.Changed != 1 || removed.Skipped != 1 {
+		t.Fatalf("RemoveMembers = %+v, %v", removed, err)
+	}
+	if groups, _ := env.groupService.UserGroups(ctx, jane.ID.Hex()); len(groups) != 0 {
+		t.Fatalf("jane is still in %+v", groups)
+	}
+}
+
+func TestGroupAdminsManageTheirSubgroups(t *testing.T) {
+	env := newTestEnv(t)
+	orgAdmin := env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin)
+	lead := env.createUser("lead@example.com", "user", models.OrgRoleMember)
+	member := env.createUser("member@example.com", "user", models.OrgRoleMember)
+	ctx := env.callerContext(orgAdmin)
+
+	engineering := env.createGroup(ctx, "Engineering", nil)
+	backend := env.createGroup(ctx, "Backend", engineering)
+	sales := env.createGroup(ctx, "Sales", nil)
+
+	if _, err := env.groupService.AddMembers(ctx, engineering.ID.Hex(), &models.GroupMembersRequest{UserIDs: []string{lead.ID.Hex()}, Role: models.GroupRoleAdmin}); err != nil {
+		t.Fatalf("AddMembers as group admin: %v", err)
+	}
+
+	for group, want := range map[*models.Group]bool{engineering: true, backend: true, sales: false} {
+		if admin, err := env.groupService.IsGroupAdmin(ctx, group.ID.Hex(), lead.ID.Hex()); err != nil || admin != want {
+			t.Errorf("IsGroupAdmin(%s) = %v, %v; want %v", group.Name, admin, err, want)
+		}
+	}
+	if admin, _ := env.groupService.IsGroupAdmin(ctx, engineering.ID.Hex(), member.ID.Hex()); admin {
+		t.Fatal("a user outside the group administers it")
+	}
+
+	// Group admins manage members but cannot make more group admins
+	leadCtx := env.callerContext(lead)
+	if _, err := env.groupService.AddMembers(leadCtx, backend.ID.Hex(), &models.GroupMembersRequest{UserIDs: []string{member.ID.Hex()}}); err != nil {
+		t.Fatalf("group admin adding a member: %v", err)
+	}
+	_, err := env.groupService.AddMembers(leadCtx, backend.ID.Hex(), &models.GroupMembersRequest{UserIDs: []string{member.ID.Hex()}, Role: models.GroupRoleAdmin})
+	if err == nil || err.Error() != "only admins can grant the group admin role" {
+		t.Fatalf("group admin granting the group admin role: err = %v", err)
+	}
+}
+
+func TestGroupMembersProjection(t *testing.T) {
+	env := newTestEnv(t)
+	orgAdmin := env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin)
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+	john := env.createUser("john@example.com", "user", models.OrgRoleMember)
+	ctx := env.callerContext(orgAdmin)
+
+	team := env.createGroup(ctx, "Team", nil)
+	env.groupService.AddMembers(ctx, team.ID.Hex(), &models.GroupMembersRequest{UserIDs: []string{jane.ID.Hex(), john.ID.Hex()}})
+
+	members, _, total, err := env.groupService.ListMembers(env.callerContext(jane), team.ID.Hex(), 1, 10)
+	if err != nil || total != 2 {
+		t.Fatalf("ListMembers = %+v, %d, %v", members, total, err)
+	}
+	for _, member := range members {
+		own := member.UserID == jane.ID.Hex()
+		if (member.Email != "") != own || (member.IsActive != nil) != own {
+			t.Errorf("member %s seen by jane = %+v", member.Name, member)
+		}
+	}
+
+	members, _, _, _ = env.groupService.ListMembers(ctx, team.ID.Hex(), 1, 10)
+	for _, member := range members {
+		if member.Email == "" || member.IsActive == nil {
+			t.Errorf("member seen by an admin = %+v", member)
+		}
+	}
+}
+
+func TestGroupsAreScopedToTheirOrganization(t *testing.T) {
+	env := newTestEnv(t)
+	superAdmin := env.createUser("root@example.com", "admin", models.OrgRoleOwner)
+	outsider := env.createUser("outsider@example.com", "user", models.OrgRoleMember)
+
+	other, err := env.orgService.CreateOrganization(env.callerContext(superAdmin), &models.CreateOrganizationRequest{Name: "Acme"})
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+	group := env.createGroup(env.callerContextIn(superAdmin, other.ID.Hex()), "Acme Team", nil)
+
+	if _, err := env.groupService.GetGroup(env.callerContext(outsider), group.ID.Hex()); err == nil {
+		t.Fatal("a group of another organization was found")
+	}
+
+	// Users of another organization cannot join
+	result, err := env.groupService.AddMembers(env.callerContextIn(superAdmin, other.ID.Hex()), group.ID.Hex(), &models.GroupMembersRequest{UserIDs: []string{outsider.ID.Hex()}})
+	if err != nil || result.Changed != 0 || len(result.Errors) != 1 {
+		t.Fatalf("adding a user of another organization = %+v, %v", result, err)
+	}
+}
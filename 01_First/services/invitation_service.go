@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/tenant"
+)
+
+// InvitationService lets organization admins invite users into their
+// organization with a chosen organization role. The invitee accepts through a
+// single-use, expiring link and chooses their own name and password.
+type InvitationService struct {
+	invitationRepo *repositories.InvitationRepository
+	userRepo       *repositories.UserRepository
+	userService    *UserService
+	mailer         Mailer
+	auditService   *AuditService
+	webhookService *WebhookService
+	acceptURL      string
+	ttl            time.Duration
+}
+
+// NewInvitationService creates a new invitation service. acceptURL is the page
+// that receives the token as ?token=
+func NewInvitationService(invitationRepo *repositories.InvitationRepository, userRepo *repositories.UserRepository, userService *UserService, mailer Mailer, auditService *AuditService, webhookService *WebhookService, acceptURL string, ttl time.Duration) *InvitationService {
+	return &InvitationService{
+		invitationRepo: invitationRepo,
+		userRepo:       userRepo,
+		userService:    userService,
+		mailer:         mailer,
+		auditService:   auditService,
+		webhookService: webhookService,
+		acceptURL:      acceptURL,
+		ttl:            ttl,
+	}
+}
+
+// CreateInvitation invites a new user into the organization ctx acts in and
+// mails them the link. A failed
+// delivery does not fail the invitation; it is reported in LastSendError and
+// the invitation can be resent.
+func (s *InvitationService) CreateInvitation(ctx context.Context, req *models.CreateInvitationRequest) (*models.Invitation, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" {
+		return nil, errors.New("email is required")
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return nil, errors.New("invalid email address")
+	}
+
+	role := req.Role
+	if role == "" {
+		role = models.OrgRoleMember
+	}
+	if role != models.OrgRoleMember && role != models.OrgRoleAdmin {
+		return nil, errors.New("role must be either admin or member")
+	}
+
+	orgID := tenant.OrgID(ctx)
+	if orgID == "" {
+		return nil, errors.New("organization is required")
+	}
+
+	// Emails are unique across organizations; existing users are added as members instead
+	if existing, _ := s.userRepo.FindByEmail(tenant.AcrossOrgs(ctx), email); existing != nil {
+		return nil, errors.New("email already registered")
+	}
+
+	// A lapsed invitation for the same email gives way to the new one
+	if err := s.invitationRepo.ExpireLapsed(ctx, email); err != nil {
+		return nil, err
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := &models.Invitation{
+		OrgID:          orgID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      hashToken(token),
+		ExpiresAt:      time.Now().Add(s.ttl),
+		InvitedByID:    middleware.GetUserID(ctx),
+		InvitedByEmail: middleware.GetEmail(ctx),
+	}
+	if err := s.invitationRepo.Create(ctx, invitation); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionInvitationCreate,
+		TargetID:    invitation.ID.Hex(),
+		TargetEmail: invitation.Email,
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"role": {From: nil, To: role}},
+	})
+
+	s.send(ctx, invitation, token)
+	return invitation, nil
+}
+
+// GetInvitation retrieves an invitation by ID
+func (s *InvitationService) GetInvitation(ctx context.Context, id string) (*models.Invitation, error) {
+	return s.invitationRepo.FindByID(ctx, id)
+}
+
+// ListInvitations retrieves invitations matching the filter with pagination
+func (s *InvitationService) ListInvitations(ctx context.Context, filter models.InvitationFilter, page, limit int) ([]*models.Invitation, int, int64, error) {
+	// Validate pagination parameters
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100 // Max limit
+	}
+
+	filter.Email = strings.ToLower(strings.TrimSpace(filter.Email))
+
+	invitations, total, err := s.invitationRepo.Find(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// Calculate total pages
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+
+	return invitations, totalPages, total, nil
+}
+
+// ResendInvitation mails a pending invitation again with a fresh link and a
+// renewed expiry. Links sent earlier stop working.
+func (s *InvitationService) ResendInvitation(ctx context.Context, id string) (*models.Invitation, error) {
+	invitation, err := s.invitationRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err = s.invitationRepo.Reissue(ctx, invitation.ID, hashToken(token), time.Now().Add(s.ttl))
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionInvitationResend,
+		TargetID:    invitation.ID.Hex(),
+		TargetEmail: invitation.Email,
+		Success:     true,
+	})
+
+	s.send(ctx, invitation, token)
+	return invitation, nil
+}
+
+// RevokeInvitation revokes a pending invitation so its link stops working
+func (s *InvitationService) RevokeInvitation(ctx context.Context, id string) (*models.Invitation, error) {
+	invitation, err := s.invitationRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err = s.invitationRepo.Revoke(ctx, invitation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionInvitationRevoke,
+		TargetID:    invitation.ID.Hex(),
+		TargetEmail: invitation.Email,
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"status": {From: models.InvitationPending, To: models.InvitationRevoked}},
+	})
+
+	return invitation, nil
+}
+
+// AcceptInvitation consumes an invitation token and creates the invited user
+// with the invitation's email, in its organization with its role
+func (s *InvitationService) AcceptInvitation(ctx context.Context, req *models.AcceptInvitationRequest) (*models.User, error) {
+	if req.Token == "" {
+		return nil, errors.New("invalid or expired invitation")
+	}
+	if len(strings.TrimSpace(req.Name)) < 2 {
+		return nil, errors.New("name must be at least 2 characters")
+	}
+	if req.Password == "" {
+		return nil, errors.New("password is required")
+	}
+
+	invitation, err := s.invitationRepo.Claim(ctx, hashToken(req.Token))
+	if err != nil {
+		return nil, err
+	}
+
+	orgCtx := tenant.WithOrg(ctx, invitation.OrgID)
+	user, err := s.userService.createUser(orgCtx, strings.TrimSpace(req.Name), invitation.Email, req.Password, "user", invitation.Role, true)
+	if err != nil {
+		// Reopen the invitation so the invitee can try again
+		if reopenErr := s.invitationRepo.Reopen(context.WithoutCancel(ctx), invitation.ID); reopenErr != nil {
+			log.Printf("⚠️  Failed to reopen invitation %s: %v", invitation.ID.Hex(), reopenErr)
+		}
+		return nil, err
+	}
+
+	if err := s.invitationRepo.SetAcceptedUser(ctx, invitation.ID, user.ID.Hex()); err != nil {
+		log.Printf("⚠️  Failed to link invitation %s to user %s: %v", invitation.ID.Hex(), user.ID.Hex(), err)
+	}
+
+	s.auditService.Record(orgCtx, &models.AuditEvent{
+		Action:      models.AuditActionInvitationAccept,
+		ActorID:     user.ID.Hex(),
+		ActorEmail:  user.Email,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"role": {From: nil, To: invitation.Role}},
+	})
+	s.webhookService.Publish(orgCtx, models.WebhookEventUserCreated, user)
+
+	return user, nil
+}
+
+// send mails the invitation link and records the outcome on the invitation
+func (s *InvitationService) send(ctx context.Context, invitation *models.Invitation, token string) {
+	inviter := "An administrator"
+	if invitation.InvitedByEmail != "" {
+		inviter = invitation.InvitedByEmail
+	}
+
+	link := s.acceptURL + "?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(
+		"Hello,\n\n%s has invited you to join their organization as %s. Accept the invitation and choose your password here:\n\n%s\n\nThe link expires in %s and can be used once.\n",
+		inviter, invitation.Role, link, s.ttl,
+	)
+
+	sentAt := time.Now()
+	var sendError string
+	if err := s.mailer.Send(ctx, invitation.Email, "You have been invited", body); err != nil {
+		log.Printf("⚠️  Failed to send invitation to %s: %v", invitation.Email, err)
+		sendError = "failed to send invitation email"
+	}
+
+	if err := s.invitationRepo.RecordSend(context.WithoutCancel(ctx), invitation.ID, sentAt, sendError); err != nil {
+		log.Printf("⚠️  Failed to record invitation delivery for %s: %v", invitation.Email, err)
+	}
+
+	invitation.SendCount++
+	invitation.LastSentAt = &sentAt
+	invitation.LastSendError = sendError
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testAcceptURL = "http://app/accept-invitation"
+
+func TestInvitationLifecycle(t *testing.T) {
+	env := newTestEnv(t)
+	orgAdmin := env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin)
+	ctx := env.callerContext(orgAdmin)
+
+	invitation, err := env.invitationService.CreateInvitation(ctx, &models.CreateInvitationRequest{Email: "New@Example.com", Role: models.OrgRoleAdmin})
+	if err != nil {
+		t.Fatalf("CreateInvitation: %v", err)
+	}
+	if invitation.Email != "new@example.com" || invitation.Status != models.InvitationPending || invitation.OrgID != env.defaultOrg.ID.Hex() ||
+		invitation.InvitedByID != orgAdmin.ID.Hex() || invitation.SendCount != 1 || invitation.LastSendError != "" {
+		t.Fatalf("invitation = %+v", invitation)
+	}
+	token := env.mailer.token(t, "new@example.com", testAcceptURL)
+
+	user, err := env.invitationService.AcceptInvitation(systemContext(), &models.AcceptInvitationRequest{Token: token, Name: "New User", Password: testPassword})
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	if user.Email != "new@example.com" || user.Name != "New User" || user.IsSuperAdmin() || user.OrgRole(env.defaultOrg.ID.Hex()) != models.OrgRoleAdmin {
+		t.Fatalf("accepted user = %+v", user)
+	}
+	if _, err := env.userService.Login(systemContext(), &models.LoginRequest{Email: "new@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("invited user cannot log in: %v", err)
+	}
+
+	// Tokens are single-use
+	_, err = env.invitationService.AcceptInvitation(systemContext(), &models.AcceptInvitationRequest{Token: token, Name: "Someone Else", Password: testPassword})
+	if err == nil || err.Error() != "invalid or expired invitation" {
+		t.Fatalf("accepting twice: err = %v", err)
+	}
+
+	stored, err := env.invitationService.GetInvitation(ctx, invitation.ID.Hex())
+	if err != nil || stored.Status != models.InvitationAccepted || stored.AcceptedUserID != user.ID.Hex() {
+		t.Fatalf("accepted invitation = %+v, %v", stored, err)
+	}
+	if _, err := env.invitationService.RevokeInvitation(ctx, invitation.ID.Hex()); err == nil || err.Error() != "invitation is no longer pending" {
+		t.Fatalf("revoking an accepted invitation: err = %v", err)
+	}
+}
+
+func TestCreateInvitationValidation(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin))
+	env.createUser("taken@example.com", "user", models.OrgRoleMember)
+
+	for _, test := range []struct {
+		req  models.CreateInvitationRequest
+		want string
+	}{
+		{models.CreateInvitationRequest{}, "email is required"},
+		{models.CreateInvitationRequest{Email: "Jane <jane@example.com>"}, "invalid email address"},
+		{models.CreateInvitationRequest{Email: "jane@example.com", Role: models.OrgRoleOwner}, "role must be either admin or member"},
+		{models.CreateInvitationRequest{Email: "taken@example.com"}, "email already registered"},
+	} {
+		if _, err := env.invitationService.CreateInvitation(ctx, &test.req); err == nil || err.Error() != test.want {
+			t.Errorf("CreateInvitation(%+v): err = %v, want %q", test.req, err, test.want)
+		}
+	}
+
+	if _, err := env.invitationService.CreateInvitation(systemContext(), &models.CreateInvitationRequest{Email: "jane@example.com"}); err == nil || err.Error() != "organization is required" {
+		t.Fatalf("CreateInvitation without an organization: err = %v", err)
+	}
+}
+
+func TestResendAndRevokeInvitation(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin))
+
+	invitation, _ := env.invitationService.CreateInvitation(ctx, &models.CreateInvitationRequest{Email: "new@example.com"})
+	firstToken := env.mailer.token(t, "new@example.com", testAcceptURL)
+
+	resent, err := env.invitationService.ResendInvitation(ctx, invitation.ID.Hex())
+	if err != nil || resent.SendCount != 2 {
+		t.Fatalf("ResendInvitation = %+v, %v", resent, err)
+	}
+	secondToken := env.mailer.token(t, "new@example.com", testAcceptURL)
+	if secondToken == firstToken {
+		t.Fatal("the resent invitation reused the old link")
+	}
+
+	// Only the latest link works, and not once the invitation is revoked
+	_, err = env.invitationService.AcceptInvitation(systemContext(), &models.AcceptInvitationRequest{Token: firstToken, Name: "New User", Password: testPassword})
+	if err == nil {
+		t.Fatal("the link of a resent invitation still works")
+	}
+	if _, err := env.invitationService.RevokeInvitation(ctx, invitation.ID.Hex()); err != nil {
+		t.Fatalf("RevokeInvitation: %v", err)
+	}
+	_, err = env.invitationService.AcceptInvitation(systemContext(), &models.AcceptInvitationRequest{Token: secondToken, Name: "New User", Password: testPassword})
+	if err == nil {
+		t.Fatal("a revoked invitation was accepted")
+	}
+	if _, err := env.invitationService.ResendInvitation(ctx, invitation.ID.Hex()); err == nil {
+		t.Fatal("a revoked invitation was resent")
+	}
+}
+
+func TestExpiredInvitation(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin))
+
+	invitation, _ := env.invitationService.CreateInvitation(ctx, &models.CreateInvitationRequest{Email: "new@example.com"})
+	token := env.mailer.token(t, "new@example.com", testAcceptURL)
+	_, err := env.db.Collection("invitations").UpdateByID(context.Background(), invitation.ID, bson.M{"$set": bson.M{"expiresAt": time.Now().Add(-time.Minute)}})
+	if err != nil {
+		t.Fatalf("backdating expiry: %v", err)
+	}
+
+	_, err = env.invitationService.AcceptInvitation(systemContext(), &models.AcceptInvitationRequest{Token: token, Name: "New User", Password: testPassword})
+	if err == nil || err.Error() != "invalid or expired invitation" {
+		t.Fatalf("accepting an expired invitation: err = %v", err)
+	}
+
+	// A new invitation for the same email replaces the lapsed one
+	if _, err := env.invitationService.CreateInvitation(ctx, &models.CreateInvitationRequest{Email: "new@example.com"}); err != nil {
+		t.Fatalf("re-inviting after expiry: %v", err)
+	}
+	if lapsed, _ := env.invitationService.GetInvitation(ctx, invitation.ID.Hex()); lapsed.Status != models.InvitationExpired {
+		t.Fatalf("lapsed invitation = %+v, want expired", lapsed)
+	}
+}
+
+func TestRejectedAcceptReopensInvitation(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin))
+
+	env.invitationService.CreateInvitation(ctx, &models.CreateInvitationRequest{Email: "new@example.com"})
+	token := env.mailer.token(t, "new@example.com", testAcceptURL)
+
+	_, err := env.invitationService.AcceptInvitation(systemContext(), &models.AcceptInvitationRequest{Token: token, Name: "New User", Password: "short"})
+	if err == nil {
+		t.Fatal("AcceptInvitation accepted a password that fails the policy")
+	}
+
+	if _, err := env.invitationService.AcceptInvitation(systemContext(), &models.AcceptInvitationRequest{Token: token, Name: "New User", Password: testPassword}); err != nil {
+		t.Fatalf("AcceptInvitation after fixing the password: %v", err)
+	}
+}
@@ -0,0 +1,550 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/tenant"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// jobPurgeBatch is how many finished jobs are purged per query
+const jobPurgeBatch = 100
+
+// JobFunc runs one job and returns its result summary, which is stored as JSON
+// on the job. ctx is cancelled when the job is cancelled, its lease is lost or
+// the server shuts down, and the function must then return promptly.
+// A job can run more than once if its replica dies, so it should resume from
+// its checkpoint or otherwise be safe to repeat.
+type JobFunc func(ctx context.Context, run *JobRun) (interface{}, error)
+
+// JobService queues long-running operations and runs them in the background.
+// Jobs live in MongoDB and are leased by the runner that executes them; the
+// runner renews the lease with heartbeats, so a job whose replica dies is
+// picked up again by another runner once the lease expires.
+type JobService struct {
+	jobRepo     *repositories.JobRepository
+	fileRepo    *repositories.JobFileRepository
+	lease       time.Duration
+	maxAttempts int
+	funcs       map[string]JobFunc
+	running     sync.WaitGroup
+}
+
+// NewJobService creates a new job service. A job that is still unfinished after
+// maxAttempts leases is failed.
+func NewJobService(jobRepo *repositories.JobRepository, fileRepo *repositories.JobFileRepository, lease time.Duration, maxAttempts int) *JobService {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	if lease < 3*time.Second {
+		lease = time.Minute
+	}
+	return &JobService{
+		jobRepo:     jobRepo,
+		fileRepo:    fileRepo,
+		lease:       lease,
+		maxAttempts: maxAttempts,
+		funcs:       make(map[string]JobFunc),
+	}
+}
+
+// Register sets the function that runs jobs of the given type.
+// All types must be registered before the runner starts.
+func (s *JobService) Register(jobType string, fn JobFunc) {
+	s.funcs[jobType] = fn
+}
+
+// Enqueue queues a job on behalf of the user in ctx. params is stored as JSON
+// and input, when not nil, is stored as the job's input file.
+func (s *JobService) Enqueue(ctx context.Context, jobType string, params interface{}, input io.Reader) (*models.Job, error) {
+	if _, ok := s.funcs[jobType]; !ok {
+		return nil, fmt.Errorf("unknown job type %q", jobType)
+	}
+
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{
+		Type:                jobType,
+		Params:              string(encoded),
+		OrgID:               tenant.OrgID(ctx),
+		CreatedByID:         middleware.GetUserID(ctx),
+		CreatedByEmail:      middleware.GetEmail(ctx),
+		CreatedBySuperAdmin: middleware.IsSuperAdmin(ctx),
+		RequestID:           middleware.GetRequestID(ctx),
+	}
+
+	if input != nil {
+		fileID := primitive.NewObjectID()
+		if err := s.writeFile(fileID, jobType+".input", func(w io.Writer) error {
+			_, err := io.Copy(w, input)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		job.InputFileID = &fileID
+	}
+
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		if job.InputFileID != nil {
+			s.deleteFile(ctx, *job.InputFileID)
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GetJob retrieves a job of the organization ctx acts in by ID
+func (s *JobService) GetJob(ctx context.Context, id string) (*models.Job, error) {
+	return s.jobRepo.FindByID(ctx, id)
+}
+
+// ListJobs retrieves jobs matching the filter with pagination, newest first
+func (s *JobService) ListJobs(ctx context.Context, filter models.JobFilter, page, limit int) ([]*models.Job, int, int64, error) {
+	// Validate pagination parameters
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100 // Max limit
+	}
+
+	jobs, total, err := s.jobRepo.Find(ctx, filter, page, limit)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// Calculate total pages
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+
+	return jobs, totalPages, total, nil
+}
+
+// CancelJob cancels a queued job, or asks the runner of a running job to stop.
+// The job reaches the cancelled state once its runner notices, at the latest
+// one heartbeat later.
+func (s *JobService) CancelJob(ctx context.Context, id string) (*models.Job, error) {
+	return s.jobRepo.RequestCancel(ctx, id)
+}
+
+// OpenResult opens the result file of a succeeded job
+func (s *JobService) OpenResult(ctx context.Context, id string) (*models.Job, io.ReadCloser, error) {
+	job, err := s.jobRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != models.JobSucceeded || job.ResultFileID == nil {
+		return nil, nil, errors.New("job has no result file")
+	}
+
+	file, err := s.fileRepo.Open(*job.ResultFileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, file, nil
+}
+
+// RunNext claims one runnable job and runs it. It reports whether a job was run.
+func (s *JobService) RunNext(ctx context.Context) (bool, error) {
+	leaseID, err := generateToken()
+	if err != nil {
+		return false, err
+	}
+
+	job, err := s.jobRepo.ClaimNext(ctx, leaseID, s.lease, s.maxAttempts)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	s.running.Add(1)
+	defer s.running.Done()
+	s.run(ctx, job)
+	return true, nil
+}
+
+// StartRunner runs jobs on a pool of workers until ctx is cancelled. Jobs are
+// leased in MongoDB, so runners on several replicas can share the queue.
+func (s *JobService) StartRunner(ctx context.Context, interval time.Duration, workers int) {
+	startPollers(ctx, "Job runner", interval, workers, func(ctx context.Context) error {
+		if failed, err := s.jobRepo.FailExhausted(ctx, s.maxAttempts); err != nil {
+			return err
+		} else if failed > 0 {
+			log.Printf("⚠️  Failed %d abandoned jobs", failed)
+		}
+
+		for {
+			ran, err := s.RunNext(ctx)
+			if err != nil || !ran {
+				return err
+			}
+		}
+	})
+}
+
+// Shutdown waits until running jobs have stopped after the runner's context was
+// cancelled, or until ctx is done. Interrupted jobs release their leases so
+// another replica resumes them right away.
+func (s *JobService) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PurgeFinishedJobs removes jobs, and their files, that finished longer than retention ago
+func (s *JobService) PurgeFinishedJobs(ctx context.Context, retention time.Duration) (int64, error) {
+	var purged int64
+	for {
+		jobs, err := s.jobRepo.FindFinishedBefore(ctx, time.Now().Add(-retention), jobPurgeBatch)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, job := range jobs {
+			for _, fileID := range []*primitive.ObjectID{job.InputFileID, job.ResultFileID} {
+				if fileID == nil {
+					continue
+				}
+				if err := s.fileRepo.Delete(ctx, *fileID); err != nil {
+					return purged, err
+				}
+			}
+			if err := s.jobRepo.Delete(ctx, job.ID); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+
+		if len(jobs) < jobPurgeBatch {
+			return purged, nil
+		}
+	}
+}
+
+// StartJobPurger runs PurgeFinishedJobs every interval until ctx is cancelled.
+// Purging is idempotent, so it is safe to run on every replica.
+func (s *JobService) StartJobPurger(ctx context.Context, retention, interval time.Duration) {
+	if retention <= 0 || interval <= 0 {
+		log.Println("Finished job purger disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purgeCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			purged, err := s.PurgeFinishedJobs(purgeCtx, retention)
+			cancel()
+
+			if err != nil {
+				log.Printf("Failed to purge finished jobs: %v", err)
+			} else if purged > 0 {
+				log.Printf("🧹 Purged %d finished jobs", purged)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// run executes a claimed job while a heartbeat keeps its lease alive, then
+// records the outcome
+func (s *JobService) run(ctx context.Context, job *models.Job) {
+	run := &JobRun{service: s, job: job}
+
+	fn, ok := s.funcs[job.Type]
+	switch {
+	case job.CancelRequested:
+		// Cancelled while its previous runner was gone
+		s.finish(ctx, run, nil, errors.New("job cancelled"))
+		return
+	case !ok:
+		s.finish(ctx, run, nil, fmt.Errorf("unknown job type %q", job.Type))
+		return
+	}
+
+	// Act as the user who queued the job, in their organization, so the job
+	// sees the users they saw and audit events are attributed to them
+	jobCtx := context.WithValue(ctx, middleware.UserIDKey, job.CreatedByID)
+	jobCtx = context.WithValue(jobCtx, middleware.EmailKey, job.CreatedByEmail)
+	jobCtx = context.WithValue(jobCtx, middleware.SuperAdminKey, job.CreatedBySuperAdmin)
+	jobCtx = context.WithValue(jobCtx, middleware.RequestIDKey, job.RequestID)
+	if job.OrgID != "" {
+		jobCtx = tenant.WithOrg(jobCtx, job.OrgID)
+	} else {
+		jobCtx = tenant.AcrossOrgs(jobCtx)
+	}
+	jobCtx, cancel := context.WithCancel(jobCtx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.heartbeat(ctx, run, cancel, done)
+	}()
+
+	log.Printf("⚙️  Running job %s (%s), attempt %d", job.ID.Hex(), job.Type, job.Attempts)
+	result, err := run.call(jobCtx, fn)
+
+	close(done)
+	wg.Wait()
+
+	run.mu.Lock()
+	lost := run.lost
+	run.mu.Unlock()
+
+	switch {
+	case lost:
+		log.Printf("⚠️  Job %s lost its lease and was stopped", job.ID.Hex())
+	case err != nil && ctx.Err() != nil && !run.cancelled():
+		// Interrupted by shutdown; let another runner take over straight away
+		releaseCtx, cancelRelease := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancelRelease()
+		if err := s.jobRepo.Release(releaseCtx, run.snapshot()); err != nil {
+			log.Printf("Failed to release job %s: %v", job.ID.Hex(), err)
+		}
+	default:
+		s.finish(ctx, run, result, err)
+	}
+}
+
+// heartbeat renews the job lease until done is closed. It cancels the job when
+// a cancellation is requested or the lease is lost.
+func (s *JobService) heartbeat(ctx context.Context, run *JobRun, cancel context.CancelFunc, done <-chan struct{}) {
+	ticker := time.NewTicker(s.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		stored, err := s.jobRepo.Heartbeat(ctx, run.snapshot(), s.lease)
+		if err != nil {
+			if err.Error() == "job lease lost" {
+				run.mu.Lock()
+				run.lost = true
+				run.mu.Unlock()
+				cancel()
+				return
+			}
+			if ctx.Err() == nil {
+				log.Printf("Job %s heartbeat failed: %v", run.job.ID.Hex(), err)
+			}
+			continue
+		}
+
+		if stored.CancelRequested {
+			run.mu.Lock()
+			run.job.CancelRequested = true
+			run.mu.Unlock()
+			cancel()
+		}
+	}
+}
+
+// finish stores the final state of a job and removes files it no longer needs
+func (s *JobService) finish(ctx context.Context, run *JobRun, result interface{}, err error) {
+	// Record the outcome even when the runner is shutting down
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	// A job that completed before it noticed a cancellation still succeeds
+	job := run.snapshot()
+	switch {
+	case err != nil && job.CancelRequested:
+		job.Status = models.JobCancelled
+	case err != nil:
+		job.Status = models.JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = models.JobSucceeded
+		if result != nil {
+			encoded, err := json.Marshal(result)
+			if err != nil {
+				job.Status = models.JobFailed
+				job.Error = "failed to encode job result"
+			} else {
+				job.Result = string(encoded)
+			}
+		}
+	}
+
+	if job.Status != models.JobSucceeded && job.ResultFileID != nil {
+		s.deleteFile(ctx, *job.ResultFileID)
+		job.ResultFileID = nil
+	}
+
+	if err := s.jobRepo.Finish(ctx, job); err != nil {
+		log.Printf("Failed to record outcome of job %s: %v", job.ID.Hex(), err)
+		return
+	}
+	if job.InputFileID != nil {
+		s.deleteFile(ctx, *job.InputFileID)
+	}
+
+	log.Printf("⚙️  Job %s (%s) %s", job.ID.Hex(), job.Type, job.Status)
+}
+
+// writeFile stores the output of write as a file, discarding it if write fails
+func (s *JobService) writeFile(id primitive.ObjectID, name string, write func(w io.Writer) error) error {
+	file, err := s.fileRepo.Create(id, name)
+	if err != nil {
+		return err
+	}
+
+	if err := write(file); err != nil {
+		file.Abort()
+		return err
+	}
+	return file.Close()
+}
+
+// deleteFile removes a job file, logging failures; the purger is the fallback
+func (s *JobService) deleteFile(ctx context.Context, id primitive.ObjectID) {
+	if err := s.fileRepo.Delete(ctx, id); err != nil {
+		log.Printf("Failed to delete job file %s: %v", id.Hex(), err)
+	}
+}
+
+// JobRun gives a running job access to its parameters, input, progress,
+// checkpoint and result file
+type JobRun struct {
+	service *JobService
+
+	mu   sync.Mutex
+	job  *models.Job
+	lost bool
+}
+
+// call runs fn, turning a panic into an error so one bad job cannot stop the runner
+func (r *JobRun) call(ctx context.Context, fn JobFunc) (result interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("❌ Job %s panicked: %v", r.job.ID.Hex(), recovered)
+			result, err = nil, errors.New("job panicked")
+		}
+	}()
+	return fn(ctx, r)
+}
+
+// snapshot returns a copy of the job that is safe to read while the job runs
+func (r *JobRun) snapshot() *models.Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job := *r.job
+	return &job
+}
+
+// cancelled reports whether a cancellation was requested
+func (r *JobRun) cancelled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.job.CancelRequested
+}
+
+// DecodeParams decodes the job parameters into v
+func (r *JobRun) DecodeParams(v interface{}) error {
+	return json.Unmarshal([]byte(r.job.Params), v)
+}
+
+// OpenInput opens the input file uploaded with the job
+func (r *JobRun) OpenInput() (io.ReadCloser, error) {
+	if r.job.InputFileID == nil {
+		return nil, errors.New("job has no input file")
+	}
+	return r.service.fileRepo.Open(*r.job.InputFileID)
+}
+
+// SetProgress records progress; it is stored with the next heartbeat or checkpoint.
+// total is 0 when unknown.
+func (r *JobRun) SetProgress(processed, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.job.Progress = models.JobProgress{Processed: processed, Total: total}
+}
+
+// LoadCheckpoint decodes the state saved by an earlier attempt into v.
+// It reports false when there is none.
+func (r *JobRun) LoadCheckpoint(v interface{}) (bool, error) {
+	if r.job.Checkpoint == "" {
+		return false, nil
+	}
+	return true, json.Unmarshal([]byte(r.job.Checkpoint), v)
+}
+
+// SaveCheckpoint stores state that a later attempt resumes from, with the
+// current progress. It is saved even if ctx was just cancelled, so work that
+// already completed is never repeated on resume.
+func (r *JobRun) SaveCheckpoint(ctx context.Context, v interface{}) error {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.job.Checkpoint = string(encoded)
+	r.mu.Unlock()
+
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	return r.service.jobRepo.SaveCheckpoint(saveCtx, r.snapshot())
+}
+
+// WriteResultFile stores the output of write as the job's downloadable result.
+// The file takes the job's ID, so a file left by an interrupted attempt is replaced.
+func (r *JobRun) WriteResultFile(ctx context.Context, name, contentType string, write func(w io.Writer) error) error {
+	if err := r.service.fileRepo.Delete(ctx, r.job.ID); err != nil {
+		return err
+	}
+	if err := r.service.writeFile(r.job.ID, name, write); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.job.ID
+	r.job.ResultFileID = &id
+	r.job.ResultFileName = name
+	r.job.ResultFileContentType = contentType
+	return nil
+}
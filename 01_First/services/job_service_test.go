@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-management-system/models"
+	"user-management-system/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testJobType = "test.job"
+
+// runJob runs the next runnable job and returns it as stored afterwards
+func (env *testEnv) runJob(id string) *models.Job {
+	env.t.Helper()
+
+	if ran, err := env.jobService.RunNext(context.Background()); err != nil || !ran {
+		env.t.Fatalf("RunNext = %v, %v; want a job run", ran, err)
+	}
+	job, err := env.jobService.GetJob(context.Background(), id)
+	if err != nil {
+		env.t.Fatalf("GetJob: %v", err)
+	}
+	return job
+}
+
+// expireJobLease makes a running job look abandoned by a replica that died
+func (env *testEnv) expireJobLease(job *models.Job) {
+	env.t.Helper()
+
+	_, err := env.db.Collection("jobs").UpdateByID(context.Background(), job.ID, bson.M{"$set": bson.M{"lockedUntil": time.Now().Add(-time.Second)}})
+	if err != nil {
+		env.t.Fatalf("expiring job lease: %v", err)
+	}
+}
+
+func TestJobRunsAsItsCreator(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin@example.com", "admin", models.OrgRoleOwner)
+
+	var seen *models.User
+	env.jobService.Register(testJobType, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		var params struct{ Email string }
+		if err := run.DecodeParams(&params); err != nil {
+			return nil, err
+		}
+		user, err := env.userRepo.FindByEmail(ctx, params.Email)
+		seen = user
+		return map[string]string{"found": user.Email}, err
+	})
+
+	job, err := env.jobService.Enqueue(env.callerContext(admin), testJobType, map[string]string{"email": admin.Email}, nil)
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if job.Status != models.JobQueued || job.CreatedByID != admin.ID.Hex() || job.OrgID != env.defaultOrg.ID.Hex() || !job.CreatedBySuperAdmin {
+		t.Fatalf("queued job = %+v", job)
+	}
+
+	job = env.runJob(job.ID.Hex())
+	if job.Status != models.JobSucceeded || job.Result != `{"found":"admin@example.com"}` || job.Attempts != 1 || job.FinishedAt == nil {
+		t.Fatalf("finished job = %+v", job)
+	}
+	if seen == nil || seen.ID != admin.ID {
+		t.Fatalf("job saw %v in its organization", seen)
+	}
+
+	if ran, _ := env.jobService.RunNext(context.Background()); ran {
+		t.Fatal("a finished job ran again")
+	}
+	if _, err := env.jobService.Enqueue(env.callerContext(admin), "no.such.job", nil, nil); err == nil {
+		t.Fatal("Enqueue accepted an unregistered job type")
+	}
+}
+
+func TestJobFailures(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("admin@example.com", "admin", models.OrgRoleOwner))
+	env.jobService.Register("test.fail", func(ctx context.Context, run *JobRun) (interface{}, error) {
+		return nil, errors.New("something broke")
+	})
+	env.jobService.Register("test.panic", func(ctx context.Context, run *JobRun) (interface{}, error) {
+		panic("boom")
+	})
+
+	failing, _ := env.jobService.Enqueue(ctx, "test.fail", nil, nil)
+	if job := env.runJob(failing.ID.Hex()); job.Status != models.JobFailed || job.Error != "something broke" {
+		t.Fatalf("failing job = %+v", job)
+	}
+
+	panicking, _ := env.jobService.Enqueue(ctx, "test.panic", nil, nil)
+	if job := env.runJob(panicking.ID.Hex()); job.Status != models.JobFailed || job.Error != "job panicked" {
+		t.Fatalf("panicking job = %+v", job)
+	}
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("admin@example.com", "admin", models.OrgRoleOwner))
+	env.jobService.Register(testJobType, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		t.Error("a cancelled job ran")
+		return nil, nil
+	})
+
+	job, _ := env.jobService.Enqueue(ctx, testJobType, nil, nil)
+	cancelled, err := env.jobService.CancelJob(ctx, job.ID.Hex())
+	if err != nil || cancelled.Status != models.JobCancelled {
+		t.Fatalf("CancelJob = %+v, %v", cancelled, err)
+	}
+	if ran, _ := env.jobService.RunNext(context.Background()); ran {
+		t.Fatal("a cancelled job was claimed")
+	}
+	if _, err := env.jobService.CancelJob(ctx, job.ID.Hex()); err == nil || err.Error() != "job already finished" {
+		t.Fatalf("cancelling a finished job: err = %v", err)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("admin@example.com", "admin", models.OrgRoleOwner))
+
+	// The shortest lease has a heartbeat every second
+	jobs := NewJobService(repositories.NewJobRepository(env.db.Collection("jobs")), repositories.NewJobFileRepository(env.db), 3*time.Second, 3)
+	started := make(chan struct{})
+	jobs.Register(testJobType, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	job, _ := jobs.Enqueue(ctx, testJobType, nil, nil)
+	go func() {
+		<-started
+		if _, err := jobs.CancelJob(ctx, job.ID.Hex()); err != nil {
+			t.Errorf("CancelJob: %v", err)
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		jobs.RunNext(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the running job did not stop after it was cancelled")
+	}
+
+	if stored, _ := jobs.GetJob(ctx, job.ID.Hex()); stored.Status != models.JobCancelled {
+		t.Fatalf("job = %+v, want cancelled", stored)
+	}
+}
+
+func TestAbandonedJobResumesFromCheckpoint(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("admin@example.com", "admin", models.OrgRoleOwner))
+
+	type checkpoint struct{ Step int }
+	var resumedFrom checkpoint
+	env.jobService.Register(testJobType, func(ctx context.Context, run *JobRun) (interface{}, error) {
+		if _, err := run.LoadCheckpoint(&resumedFrom); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	})
+
+	job, _ := env.jobService.Enqueue(ctx, testJobType, nil, nil)
+
+	// A replica claims the job, checkpoints and dies
+	jobRepo := repositories.NewJobRepository(env.db.Collection("jobs"))
+	claimed, err := jobRepo.ClaimNext(context.Background(), "dead-replica", time.Minute, 3)
+	if err != nil || claimed == nil {
+		t.Fatalf("ClaimNext = %v, %v", claimed, err)
+	}
+	claimed.Checkpoint = `{"Step":2}`
+	if err := jobRepo.SaveCheckpoint(context.Background(), claimed); err != nil {
+		t.Fatalf("SaveCheckpoint: %v", err)
+	}
+
+	// Nobody else may run it while the lease is fresh
+	if ran, _ := env.jobService.RunNext(context.Background()); ran {
+		t.Fatal("a leased job was claimed by another runner")
+	}
+
+	env.expireJobLease(claimed)
+	finished := env.runJob(job.ID.Hex())
+	if finished.Status != models.JobSucceeded || finished.Attempts != 2 || resumedFrom.Step != 2 {
+		t.Fatalf("resumed job = %+v from %+v", finished, resumedFrom)
+	}
+
+	// The dead replica's lease is gone, so it cannot overwrite the outcome
+	if err := jobRepo.Finish(context.Background(), claimed); err == nil || err.Error() != "job lease lost" {
+		t.Fatalf("Finish with a lost lease: err = %v", err)
+	}
+}
+
+func TestExhaustedJobFails(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("admin@example.com", "admin", models.OrgRoleOwner))
+	env.jobService.Register(testJobType, func(ctx context.Context, run *JobRun) (interface{}, error) { return nil, nil })
+	job, _ := env.jobService.Enqueue(ctx, testJobType, nil, nil)
+
+	// Every attempt is abandoned by a replica that dies
+	jobRepo := repositories.NewJobRepository(env.db.Collection("jobs"))
+	for attempt := 0; attempt < 3; attempt++ {
+		claimed, err := jobRepo.ClaimNext(context.Background(), "dead-replica", time.Minute, 3)
+		if err != nil || claimed == nil {
+			t.Fatalf("attempt %d: ClaimNext = %v, %v", attempt+1, claimed, err)
+		}
+		env.expireJobLease(claimed)
+	}
+
+	if ran, _ := env.jobService.RunNext(context.Background()); ran {
+		t.Fatal("a job ran after its last attempt")
+	}
+	if failed, err := jobRepo.FailExhausted(context.Background(), 3); err != nil || failed != 1 {
+		t.Fatalf("FailExhausted = %d, %v", failed, err)
+	}
+	if stored, _ := env.jobService.GetJob(ctx, job.ID.Hex()); stored.Status != models.JobFailed || stored.Error != "job abandoned after 3 attempts" {
+		t.Fatalf("job = %+v", stored)
+	}
+}
+
+func TestPurgeFinishedJobs(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("admin@example.com", "admin", models.OrgRoleOwner))
+	env.jobService.Register(testJobType, func(ctx context.Context, run *JobRun) (interface{}, error) { return nil, nil })
+
+	finished, _ := env.jobService.Enqueue(ctx, testJobType, nil, nil)
+	env.runJob(finished.ID.Hex())
+	queued, _ := env.jobService.Enqueue(ctx, testJobType, nil, nil)
+
+	if purged, err := env.jobService.PurgeFinishedJobs(context.Background(), time.Hour); err != nil || purged != 0 {
+		t.Fatalf("PurgeFinishedJobs within the retention = %d, %v", purged, err)
+	}
+	if purged, err := env.jobService.PurgeFinishedJobs(context.Background(), -time.Second); err != nil || purged != 1 {
+		t.Fatalf("PurgeFinishedJobs = %d, %v; want 1", purged, err)
+	}
+	if _, err := env.jobService.GetJob(ctx, finished.ID.Hex()); err == nil {
+		t.Fatal("the purged job is still there")
+	}
+	if _, err := env.jobService.GetJob(ctx, queued.ID.Hex()); err != nil {
+		t.Fatalf("an unfinished job was purged: %v", err)
+	}
+}
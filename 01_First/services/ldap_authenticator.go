@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"user-management-system/config"
+	"user-management-system/models"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPDirectory is the name of the LDAP authenticator and the provider of the
+// linked identity of its shadow users
+const LDAPDirectory = "ldap"
+
+// LDAPAuthenticator checks passwords by binding to an LDAP directory as the
+// user. The user's entry is found either by filling the bind DN template with
+// the login, or by searching for it, bound as a service account when one is
+// configured. Groups come from an attribute of the entry, such as memberOf,
+// and from a group search; members of the admin groups are admins of the
+// default organization.
+type LDAPAuthenticator struct {
+	config    *config.Config
+	tlsConfig *tls.Config
+}
+
+// NewLDAPAuthenticator creates an authenticator for the LDAP_* settings of cfg
+func NewLDAPAuthenticator(cfg *config.Config) (*LDAPAuthenticator, error) {
+	target, err := url.Parse(cfg.LDAPURL)
+	if err != nil || (target.Scheme != "ldap" && target.Scheme != "ldaps") || target.Host == "" {
+		return nil, errors.New("LDAP_URL must be an ldap:// or ldaps:// URL")
+	}
+	if target.Scheme == "ldaps" && cfg.LDAPStartTLS {
+		return nil, errors.New("LDAP_START_TLS cannot be used with ldaps://")
+	}
+	if cfg.LDAPBindDNTemplate == "" && cfg.LDAPBaseDN == "" {
+		return nil, errors.New("LDAP needs LDAP_BIND_DN_TEMPLATE or LDAP_BASE_DN")
+	}
+	if cfg.LDAPTimeout <= 0 {
+		return nil, errors.New("LDAP_TIMEOUT_SECONDS must be positive")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: target.Hostname()}
+	if cfg.LDAPCAFile != "" {
+		pem, err := os.ReadFile(cfg.LDAPCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("LDAP_CA_FILE holds no PEM certificates")
+		}
+	}
+
+	return &LDAPAuthenticator{config: cfg, tlsConfig: tlsConfig}, nil
+}
+
+// Name implements Authenticator
+func (a *LDAPAuthenticator) Name() string {
+	return LDAPDirectory
+}
+
+// Authenticate implements Authenticator. The login is limited to
+// LDAP_TIMEOUT_SECONDS, connecting included.
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, email, password string, user *models.User) (*ExternalAccount, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.config.LDAPTimeout)
+	defer cancel()
+
+	conn, err := ldap.DialURL(a.config.LDAPURL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: a.config.LDAPTimeout}),
+		ldap.DialWithTLSConfig(a.tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetTimeout(a.config.LDAPTimeout)
+	// Closing the connection ends a request still waiting for the server
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if a.config.LDAPStartTLS {
+		if err := conn.StartTLS(a.tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	attributes := []string{a.config.LDAPNameAttribute}
+	if a.config.LDAPGroupAttribute != "" {
+		attributes = append(attributes, a.config.LDAPGroupAttribute)
+	}
+
+	var entry *ldap.Entry
+	if a.config.LDAPBindDNTemplate != "" {
+		dn := a.fill(a.config.LDAPBindDNTemplate, email, "", ldap.EscapeDN)
+		if err := conn.Bind(dn, password); err != nil {
+			if rejected(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		result, err := conn.Search(ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", attributes, nil))
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Entries) != 1 {
+			return nil, errors.New("cannot read the entry of " + dn)
+		}
+		entry = result.Entries[0]
+	} else {
+		if a.config.LDAPBindDN != "" {
+			if err := conn.Bind(a.config.LDAPBindDN, a.config.LDAPBindPassword); err != nil {
+				return nil, err
+			}
+		}
+
+		filter := a.fill(a.config.LDAPUserFilter, email, "", ldap.EscapeFilter)
+		result, err := conn.Search(ldap.NewSearchRequest(a.config.LDAPBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false, filter, attributes, nil))
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) || (result != nil && len(result.Entries) > 1) {
+			return nil, errors.New("several entries match " + filter)
+		}
+		if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, err
+		}
+		if result == nil || len(result.Entries) == 0 {
+			return nil, nil
+		}
+		entry = result.Entries[0]
+
+		if err := conn.Bind(entry.DN, password); err != nil {
+			if rejected(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+
+	groups := entry.GetEqualFoldAttributeValues(a.config.LDAPGroupAttribute)
+	if a.config.LDAPGroupBaseDN != "" {
+		filter := a.fill(a.config.LDAPGroupFilter, email, entry.DN, ldap.EscapeFilter)
+		result, err := conn.Search(ldap.NewSearchRequest(a.config.LDAPGroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false, filter, []string{"1.1"}, nil))
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range result.Entries {
+			groups = append(groups, group.DN)
+		}
+	}
+
+	return &ExternalAccount{
+		Subject: entry.DN,
+		Name:    strings.TrimSpace(entry.GetEqualFoldAttributeValue(a.config.LDAPNameAttribute)),
+		OrgRole: a.orgRole(groups),
+	}, nil
+}
+
+// rejected reports whether a bind failed because of the credentials rather
+// than the directory
+func rejected(err error) bool {
+	return ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) || ldap.IsErrorWithCode(err, ldap.ErrorEmptyPassword)
+}
+
+// fill replaces the {email}, {username} (the part of the email before the @)
+// and {dn} placeholders of a template with escaped values
+func (a *LDAPAuthenticator) fill(template, email, dn string, escape func(string) string) string {
+	username := strings.SplitN(email, "@", 2)[0]
+	return strings.NewReplacer(
+		"{email}", escape(email),
+		"{username}", escape(username),
+		"{dn}", escape(dn),
+	).Replace(template)
+}
+
+// orgRole returns the admin organization role when one of the groups is an
+// admin group, member otherwise
+func (a *LDAPAuthenticator) orgRole(groups []string) string {
+	for _, group := range groups {
+		for _, adminGroup := range a.config.LDAPAdminGroups {
+			if normalizeDN(group) == normalizeDN(adminGroup) {
+				return models.OrgRoleAdmin
+			}
+		}
+	}
+	return models.OrgRoleMember
+}
+
+// normalizeDN lowercases a DN and drops the spaces around its separators, so
+// DNs written differently by the server and the configuration compare equal
+func normalizeDN(dn string) string {
+	parts := strings.Split(strings.ToLower(dn), ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management-system/config"
+	"user-management-system/ldaptest"
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	janeDN    = "uid=jane,ou=people,dc=example,dc=com"
+	johnDN    = "uid=john,ou=people,dc=example,dc=com"
+	serviceDN = "cn=user-management,ou=services,dc=example,dc=com"
+	adminsDN  = "cn=admins,ou=groups,dc=example,dc=com"
+)
+
+// newTestDirectory starts a directory with two people and a service account
+func newTestDirectory(t *testing.T) *ldaptest.Server {
+	return ldaptest.NewServer(t,
+		&ldaptest.Entry{DN: "ou=people,dc=example,dc=com"},
+		&ldaptest.Entry{DN: janeDN, Password: "jane-secret", Attributes: map[string][]string{
+			"cn": {"Jane Doe"}, "mail": {"jane@example.com"}, "objectClass": {"inetOrgPerson"},
+		}},
+		&ldaptest.Entry{DN: johnDN, Password: "john-secret", Attributes: map[string][]string{
+			"cn": {"John Roe"}, "mail": {"john@example.com"}, "objectClass": {"inetOrgPerson"},
+		}},
+		&ldaptest.Entry{DN: serviceDN, Password: "service-secret"},
+	)
+}
+
+// ldapConfig returns the default LDAP settings of LoadConfig for the
+// directory, with neither a bind DN template nor a base DN
+func ldapConfig(server *ldaptest.Server) *config.Config {
+	return &config.Config{
+		LDAPURL:            server.URL,
+		LDAPTimeout:        5 * time.Second,
+		LDAPUserFilter:     "(mail={email})",
+		LDAPNameAttribute:  "cn",
+		LDAPGroupAttribute: "memberOf",
+		LDAPGroupFilter:    "(member={dn})",
+	}
+}
+
+// newLDAPAuthenticator creates an authenticator for cfg
+func newLDAPAuthenticator(t *testing.T, cfg *config.Config) *LDAPAuthenticator {
+	t.Helper()
+
+	authenticator, err := NewLDAPAuthenticator(cfg)
+	if err != nil {
+		t.Fatalf("NewLDAPAuthenticator: %v", err)
+	}
+	return authenticator
+}
+
+func TestLDAPBindDNTemplate(t *testing.T) {
+	server := newTestDirectory(t)
+	cfg := ldapConfig(server)
+	cfg.LDAPBindDNTemplate = "uid={username},ou=people,dc=example,dc=com"
+	authenticator := newLDAPAuthenticator(t, cfg)
+
+	account, err := authenticator.Authenticate(context.Background(), "jane@example.com", "jane-secret", nil)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if account == nil || account.Subject != janeDN || account.Name != "Jane Doe" || account.OrgRole != models.OrgRoleMember {
+		t.Fatalf("account = %+v", account)
+	}
+	if binds := server.Binds(); len(binds) != 1 || binds[0] != janeDN {
+		t.Fatalf("binds = %v, want only %s", binds, janeDN)
+	}
+
+	for _, login := range []struct{ email, password string }{
+		{"jane@example.com", "wrong"},
+		{"nobody@example.com", "jane-secret"},
+		// The username is escaped, so it cannot name another entry
+		{"jane,ou=people@example.com", "jane-secret"},
+	} {
+		account, err := authenticator.Authenticate(context.Background(), login.email, login.password, nil)
+		if err != nil || account != nil {
+			t.Errorf("Authenticate(%s, %s) = %+v, %v; want nil, nil", login.email, login.password, account, err)
+		}
+	}
+}
+
+func TestLDAPSearchThenBind(t *testing.T) {
+	server := newTestDirectory(t)
+	cfg := ldapConfig(server)
+	cfg.LDAPBaseDN = "ou=people,dc=example,dc=com"
+	cfg.LDAPUserFilter = "(&(objectClass=inetOrgPerson)(mail={email}))"
+	cfg.LDAPBindDN = serviceDN
+	cfg.LDAPBindPassword = "service-secret"
+	authenticator := newLDAPAuthenticator(t, cfg)
+
+	account, err := authenticator.Authenticate(context.Background(), "John@Example.com", "john-secret", nil)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if account == nil || account.Subject != johnDN || account.Name != "John Roe" {
+		t.Fatalf("account = %+v", account)
+	}
+	if binds := server.Binds(); len(binds) != 2 || binds[0] != serviceDN || binds[1] != johnDN {
+		t.Fatalf("binds = %v, want the service account and then %s", binds, johnDN)
+	}
+
+	if account, err := authenticator.Authenticate(context.Background(), "john@example.com", "jane-secret", nil); err != nil || account != nil {
+		t.Fatalf("Authenticate with a wrong password = %+v, %v; want nil, nil", account, err)
+	}
+	if account, err := authenticator.Authenticate(context.Background(), "nobody@example.com", "john-secret", nil); err != nil || account != nil {
+		t.Fatalf("Authenticate of an unknown email = %+v, %v; want nil, nil", account, err)
+	}
+	// A filter value cannot widen the search
+	if account, err := authenticator.Authenticate(context.Background(), "*", "john-secret", nil); err != nil || account != nil {
+		t.Fatalf("Authenticate of * = %+v, %v; want nil, nil", account, err)
+	}
+
+	// An email shared by two entries is an error, not a guess
+	server.Add(&ldaptest.Entry{DN: "uid=john2,ou=people,dc=example,dc=com", Password: "john-secret", Attributes: map[string][]string{
+		"mail": {"john@example.com"}, "objectClass": {"inetOrgPerson"},
+	}})
+	if _, err := authenticator.Authenticate(context.Background(), "john@example.com", "john-secret", nil); err == nil {
+		t.Fatal("Authenticate succeeded with two entries for the email")
+	}
+
+	// The service account's own credentials must work
+	cfg.LDAPBindPassword = "wrong"
+	if _, err := authenticator.Authenticate(context.Background(), "jane@example.com", "jane-secret", nil); err == nil {
+		t.Fatal("Authenticate succeeded with a wrong service account password")
+	}
+}
+
+func TestLDAPStartTLS(t *testing.T) {
+	server := newTestDirectory(t)
+	server.RequireTLS = true
+	cfg := ldapConfig(server)
+	cfg.LDAPBindDNTemplate = "uid={username},ou=people,dc=example,dc=com"
+
+	// Without StartTLS the directory refuses the bind
+	if _, err := newLDAPAuthenticator(t, cfg).Authenticate(context.Background(), "jane@example.com", "jane-secret", nil); err == nil {
+		t.Fatal("Authenticate succeeded in the clear on a directory requiring TLS")
+	}
+
+	// StartTLS with the system roots does not trust the test certificate
+	cfg.LDAPStartTLS = true
+	if _, err := newLDAPAuthenticator(t, cfg).Authenticate(context.Background(), "jane@example.com", "jane-secret", nil); err == nil {
+		t.Fatal("Authenticate trusted a certificate outside LDAP_CA_FILE")
+	}
+
+	cfg.LDAPCAFile = server.CAFile
+	account, err := newLDAPAuthenticator(t, cfg).Authenticate(context.Background(), "jane@example.com", "jane-secret", nil)
+	if err != nil {
+		t.Fatalf("Authenticate with StartTLS: %v", err)
+	}
+	if account == nil || account.Subject != janeDN {
+		t.Fatalf("account = %+v", account)
+	}
+
+	cfg.LDAPURL = "ldaps://127.0.0.1:636"
+	if _, err := NewLDAPAuthenticator(cfg); err == nil || err.Error() != "LDAP_START_TLS cannot be used with ldaps://" {
+		t.Fatalf("NewLDAPAuthenticator with ldaps:// and StartTLS = %v", err)
+	}
+}
+
+func TestLDAPGroupMapping(t *testing.T) {
+	server := newTestDirectory(t)
+	server.Add(
+		// memberOf as written by the directory, with spaces and capitals
+		&ldaptest.Entry{DN: "uid=ann,ou=people,dc=example,dc=com", Password: "ann-secret", Attributes: map[string][]string{
+			"cn": {"Ann"}, "mail": {"ann@example.com"}, "memberOf": {"cn=staff,ou=groups,dc=example,dc=com", "CN=Admins, OU=Groups, DC=Example, DC=Com"},
+		}},
+		// Group entries listing their members
+		&ldaptest.Entry{DN: adminsDN, Attributes: map[string][]string{"member": {janeDN}}},
+		&ldaptest.Entry{DN: "cn=staff,ou=groups,dc=example,dc=com", Attributes: map[string][]string{"member": {janeDN, johnDN}}},
+	)
+	cfg := ldapConfig(server)
+	cfg.LDAPBindDNTemplate = "uid={username},ou=people,dc=example,dc=com"
+	cfg.LDAPAdminGroups = []string{adminsDN}
+
+	roles := func(cfg *config.Config) map[string]string {
+		t.Helper()
+
+		authenticator := newLDAPAuthenticator(t, cfg)
+		got := map[string]string{}
+		for _, login := range []struct{ email, password string }{
+			{"ann@example.com", "ann-secret"},
+			{"jane@example.com", "jane-secret"},
+			{"john@example.com", "john-secret"},
+		} {
+			account, err := authenticator.Authenticate(context.Background(), login.email, login.password, nil)
+			if err != nil || account == nil {
+				t.Fatalf("Authenticate(%s) = %+v, %v", login.email, account, err)
+			}
+			got[login.email] = account.OrgRole
+		}
+		return got
+	}
+
+	// Only the memberOf attribute
+	got := roles(cfg)
+	if got["ann@example.com"] != models.OrgRoleAdmin || got["jane@example.com"] != models.OrgRoleMember || got["john@example.com"] != models.OrgRoleMember {
+		t.Fatalf("roles from memberOf = %v", got)
+	}
+
+	// The group search adds the groups listing the user as a member
+	cfg.LDAPGroupBaseDN = "ou=groups,dc=example,dc=com"
+	got = roles(cfg)
+	if got["ann@example.com"] != models.OrgRoleAdmin || got["jane@example.com"] != models.OrgRoleAdmin || got["john@example.com"] != models.OrgRoleMember {
+		t.Fatalf("roles with the group search = %v", got)
+	}
+
+	// Without admin groups everyone is a member
+	cfg.LDAPAdminGroups = nil
+	got = roles(cfg)
+	if got["ann@example.com"] != models.OrgRoleMember || got["jane@example.com"] != models.OrgRoleMember {
+		t.Fatalf("roles without admin groups = %v", got)
+	}
+}
+
+func TestLoginThroughLDAP(t *testing.T) {
+	server := newTestDirectory(t)
+	server.Add(&ldaptest.Entry{DN: adminsDN, Attributes: map[string][]string{"member": {janeDN}}})
+	cfg := ldapConfig(server)
+	cfg.LDAPBindDNTemplate = "uid={username},ou=people,dc=example,dc=com"
+	cfg.LDAPGroupBaseDN = "ou=groups,dc=example,dc=com"
+	cfg.LDAPAdminGroups = []string{adminsDN}
+	env := newTestEnv(t, newLDAPAuthenticator(t, cfg))
+	ctx := context.Background()
+
+	orgID := env.defaultOrg.ID.Hex()
+	login := func() *models.User {
+		t.Helper()
+		user, err := env.userService.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "jane-secret"})
+		if err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		return user
+	}
+
+	// The first login creates a shadow user in the default organization,
+	// where the admin group makes her an admin, not of the whole platform
+	shadow := login()
+	if shadow.Name != "Jane Doe" || shadow.Role != "user" || shadow.IsSuperAdmin() || shadow.Identity(LDAPDirectory) == nil || shadow.Identity(LDAPDirectory).Subject != janeDN {
+		t.Fatalf("shadow user = %+v", shadow)
+	}
+	if role := shadow.OrgRole(orgID); role != models.OrgRoleAdmin {
+		t.Fatalf("shadow user orgs = %+v", shadow.Orgs)
+	}
+
+	// A local demotion survives logins while the directory's grant stays the same
+	if _, err := env.userRepo.SetMembershipRole(ctx, shadow.ID.Hex(), orgID, models.OrgRoleMember); err != nil {
+		t.Fatalf("demoting: %v", err)
+	}
+	if again := login(); again.ID != shadow.ID || again.OrgRole(orgID) != models.OrgRoleMember {
+		t.Fatalf("login after a local demotion = %+v", again)
+	}
+
+	// Changes in the directory apply on the next login: leaving the admin
+	// group, then joining it again
+	cfg.LDAPAdminGroups = []string{"cn=other,ou=groups,dc=example,dc=com"}
+	if again := login(); again.OrgRole(orgID) != models.OrgRoleMember {
+		t.Fatalf("login after leaving the admin group = %+v", again)
+	}
+	cfg.LDAPAdminGroups = []string{adminsDN}
+	if again := login(); again.OrgRole(orgID) != models.OrgRoleAdmin || again.IsSuperAdmin() {
+		t.Fatalf("login after joining the admin group = %+v", again)
+	}
+
+	// A local account with a directory entry of the same email keeps its
+	// local password, and the directory's is refused
+	local := env.createUser("john@example.com", "user", models.OrgRoleMember)
+	if _, err := env.userService.Login(ctx, &models.LoginRequest{Email: "john@example.com", Password: "john-secret"}); err == nil || err.Error() != "invalid email or password" {
+		t.Fatalf("Login with the directory password of a local user = %v", err)
+	}
+	if user, err := env.userService.Login(ctx, &models.LoginRequest{Email: "john@example.com", Password: testPassword}); err != nil || user.ID != local.ID {
+		t.Fatalf("Login with the local password = %+v, %v", user, err)
+	}
+	if identities := env.reload(local).Identities; len(identities) != 0 {
+		t.Fatalf("local user identities = %+v", identities)
+	}
+
+	// Deactivated shadow users cannot sign in through the directory either
+	if err := env.userRepo.Update(ctx, shadow.ID.Hex(), bson.M{"isActive": false}); err != nil {
+		t.Fatalf("deactivating: %v", err)
+	}
+	if _, err := env.userService.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: "jane-secret"}); err == nil || err.Error() != "account is deactivated" {
+		t.Fatalf("Login of a deactivated shadow user = %v", err)
+	}
+
+	want := []string{
+		models.AuditActionUserCreate, models.AuditActionLoginSuccess, // jane's first login
+		models.AuditActionLoginSuccess,                                    // jane demoted locally
+		models.AuditActionLoginSuccess,                                    // jane left the admin group
+		models.AuditActionOrgMemberUpdate, models.AuditActionLoginSuccess, // jane joined it again
+		models.AuditActionLoginFailure, models.AuditActionLoginSuccess, // john's passwords
+		models.AuditActionLoginFailure, // jane deactivated
+	}
+	if actions := env.auditActions(); strings.Join(actions, " ") != strings.Join(want, " ") {
+		t.Fatalf("audit actions = %v, want %v", actions, want)
+	}
+}
+
+func TestLocalAuthenticator(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+	service := env.createUser("robot@example.com", "user", models.OrgRoleMember)
+	if err := env.userRepo.Update(ctx, service.ID.Hex(), bson.M{"serviceAccount": true}); err != nil {
+		t.Fatalf("making a service account: %v", err)
+	}
+	inactive := env.createUser("gone@example.com", "user", models.OrgRoleMember)
+	if err := env.userRepo.Update(ctx, inactive.ID.Hex(), bson.M{"isActive": false}); err != nil {
+		t.Fatalf("deactivating: %v", err)
+	}
+
+	// Every user service asks the local passwords first
+	if chain := env.userService.authenticators; len(chain) == 0 || chain[0].Name() != LocalDirectory {
+		t.Fatalf("authenticator chain does not start with %s", LocalDirectory)
+	}
+
+	// It checks the password of the user Login loaded
+	authenticator := NewLocalAuthenticator()
+	account, err := authenticator.Authenticate(ctx, "jane@example.com", testPassword, user)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if account == nil || account.Subject != user.ID.Hex() || account.Name != user.Name || account.OrgRole != models.OrgRoleMember {
+		t.Fatalf("account = %+v", account)
+	}
+
+	for _, login := range []struct {
+		user     *models.User
+		password string
+	}{
+		{user, "wrong password"},
+		{nil, testPassword},
+		{env.reload(service), testPassword},
+		{env.reload(inactive), testPassword},
+	} {
+		account, err := authenticator.Authenticate(ctx, "jane@example.com", login.password, login.user)
+		if err != nil || account != nil {
+			t.Errorf("Authenticate(%+v, %s) = %+v, %v; want nil, nil", login.user, login.password, account, err)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Registration of OAuth clients by super-admins
+
+// CreateClient registers an OAuth client and returns it with its secret,
+// which is only stored hashed; public clients get none
+func (s *OAuthService) CreateClient(ctx context.Context, req *models.OAuthClientRequest) (*models.OAuthClient, string, error) {
+	client, err := normalizeClientRequest(req, req.Public)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+	client.ClientID = hex.EncodeToString(id)
+	client.CreatedByID = middleware.GetUserID(ctx)
+
+	secret := ""
+	if !client.Public {
+		if secret, err = generateToken(); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashToken(secret)
+	}
+
+	if err := s.clientRepo.Create(ctx, client); err != nil {
+		return nil, "", err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   models.AuditActionOAuthClientCreate,
+		TargetID: client.ClientID,
+		Success:  true,
+		Changes: map[string]models.AuditChange{
+			"name":       {From: nil, To: client.Name},
+			"grantTypes": {From: nil, To: client.GrantTypes},
+			"scopes":     {From: nil, To: client.Scopes},
+		},
+	})
+
+	return client, secret, nil
+}
+
+// ListClients retrieves every registered client
+func (s *OAuthService) ListClients(ctx context.Context) ([]*models.OAuthClient, error) {
+	return s.clientRepo.FindAll(ctx)
+}
+
+// GetClient retrieves a client by its database ID
+func (s *OAuthService) GetClient(ctx context.Context, id string) (*models.OAuthClient, error) {
+	return s.clientRepo.FindByID(ctx, id)
+}
+
+// UpdateClient replaces the name, redirect URIs, grant types, scopes and
+// post-logout redirect URIs of a client. Whether it is public cannot change.
+func (s *OAuthService) UpdateClient(ctx context.Context, id string, req *models.OAuthClientRequest) (*models.OAuthClient, error) {
+	before, err := s.clientRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := normalizeClientRequest(req, before.Public)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.clientRepo.Update(ctx, before.ID, bson.M{
+		"name":         client.Name,
+		"redirectUris": client.RedirectURIs,
+		"grantTypes":   client.GrantTypes,
+		"scopes":       client.Scopes,
+
+		"postLogoutRedirectUris": client.PostLogoutRedirectURIs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   models.AuditActionOAuthClientUpdate,
+		TargetID: before.ClientID,
+		Success:  true,
+		Changes: map[string]models.AuditChange{
+			"redirectUris": {From: before.RedirectURIs, To: client.RedirectURIs},
+			"grantTypes":   {From: before.GrantTypes, To: client.GrantTypes},
+			"scopes":       {From: before.Scopes, To: client.Scopes},
+		},
+	})
+
+	return s.clientRepo.FindByID(ctx, id)
+}
+
+// DeleteClient removes a client with its codes and refresh tokens. Access
+// tokens already issued stay valid until they expire.
+func (s *OAuthService) DeleteClient(ctx context.Context, id string) error {
+	client, err := s.clientRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.clientRepo.Delete(ctx, client.ID); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.DeleteForClient(ctx, client.ClientID, ""); err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   models.AuditActionOAuthClientDelete,
+		TargetID: client.ClientID,
+		Success:  true,
+	})
+
+	return nil
+}
+
+// normalizeClientRequest validates a client registration and returns the
+// client it describes
+func normalizeClientRequest(req *models.OAuthClientRequest, public bool) (*models.OAuthClient, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(name) > 100 {
+		return nil, errors.New("name must be at most 100 characters")
+	}
+
+	if len(req.GrantTypes) == 0 {
+		return nil, errors.New("at least one grant type is required")
+	}
+	grantTypes := []string{}
+	for _, grantType := range req.GrantTypes {
+		switch grantType {
+		case models.GrantAuthorizationCode, models.GrantRefreshToken:
+		case models.GrantClientCredentials:
+			if public {
+				return nil, errors.New("public clients cannot use the client_credentials grant")
+			}
+		default:
+			return nil, errors.New("unsupported grant type: " + grantType)
+		}
+		if !containsString(grantTypes, grantType) {
+			grantTypes = append(grantTypes, grantType)
+		}
+	}
+
+	redirectURIs := []string{}
+	for _, uri := range req.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, err
+		}
+		if !containsString(redirectURIs, uri) {
+			redirectURIs = append(redirectURIs, uri)
+		}
+	}
+	if containsString(grantTypes, models.GrantAuthorizationCode) && len(redirectURIs) == 0 {
+		return nil, errors.New("the authorization_code grant needs at least one redirect URI")
+	}
+
+	postLogoutRedirectURIs := []string{}
+	for _, uri := range req.PostLogoutRedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, err
+		}
+		if !containsString(postLogoutRedirectURIs, uri) {
+			postLogoutRedirectURIs = append(postLogoutRedirectURIs, uri)
+		}
+	}
+
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		if !validScopeToken(scope) {
+			return nil, errors.New("invalid scope: " + scope)
+		}
+		if !containsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return &models.OAuthClient{
+		Name:         name,
+		Public:       public,
+		RedirectURIs: redirectURIs,
+		GrantTypes:   grantTypes,
+		Scopes:       scopes,
+
+		PostLogoutRedirectURIs: postLogoutRedirectURIs,
+	}, nil
+}
+
+// validateRedirectURI accepts absolute https URIs without a fragment, and
+// http ones on the loopback interface for local development
+func validateRedirectURI(uri string) error {
+	invalid := errors.New("invalid redirect URI: " + uri)
+
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Host == "" || parsed.Fragment != "" || strings.Contains(uri, "#") {
+		return invalid
+	}
+
+	switch parsed.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := parsed.Hostname()
+		if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+			return nil
+		}
+	}
+	return invalid
+}
+
+// validScopeToken reports whether scope is a scope token as defined in
+// RFC 6749 section 3.3: printable ASCII without spaces, quotes or backslashes
+func validScopeToken(scope string) bool {
+	if scope == "" {
+		return false
+	}
+	for _, c := range scope {
+		if c <= ' ' || c > '~' || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
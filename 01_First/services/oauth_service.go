@@ -0,0 +1,652 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-management-system/config"
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/tenant"
+	"user-management-system/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oauthSessionAudience marks the JWTs kept in the authorization server's
+// sign-in cookie, so they are never accepted as access or API tokens
+const oauthSessionAudience = "oauth-session"
+
+// OAuthService is an OAuth 2.0 authorization server for other applications:
+// the authorization code grant with PKCE, the client credentials and refresh
+// token grants, token introspection (RFC 7662) and revocation (RFC 7009),
+// with OpenID Connect on top (see oidc_service.go). Access tokens are JWTs
+// from utils.GenerateToken carrying the granted scope and the client as
+// audience; codes and refresh tokens are random and stored hashed.
+type OAuthService struct {
+	clientRepo   *repositories.OAuthClientRepository
+	tokenRepo    *repositories.OAuthTokenRepository
+	userRepo     *repositories.UserRepository
+	userService  *UserService
+	auditService *AuditService
+	signingKey   *SigningKey
+	config       *config.Config
+}
+
+// NewOAuthService creates a new OAuth service. ID tokens are signed with
+// signingKey; the issuer and token lifetimes come from the OAUTH_* settings in
+// cfg.
+func NewOAuthService(clientRepo *repositories.OAuthClientRepository, tokenRepo *repositories.OAuthTokenRepository, userRepo *repositories.UserRepository, userService *UserService, auditService *AuditService, signingKey *SigningKey, cfg *config.Config) *OAuthService {
+	return &OAuthService{
+		clientRepo:   clientRepo,
+		tokenRepo:    tokenRepo,
+		userRepo:     userRepo,
+		userService:  userService,
+		auditService: auditService,
+		signingKey:   signingKey,
+		config:       cfg,
+	}
+}
+
+// AuthorizeRequest is a validated request to /oauth/authorize
+type AuthorizeRequest struct {
+	Client              *models.OAuthClient
+	RedirectURI         string
+	State               string
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// ParseAuthorizeRequest validates the parameters of an authorization request.
+// When the client or redirect URI is invalid it returns a nil request, and
+// the error must be shown to the user; the user agent must not be redirected.
+// Other errors come with the request and are sent to its redirect URI.
+func (s *OAuthService) ParseAuthorizeRequest(ctx context.Context, params url.Values) (*AuthorizeRequest, error) {
+	client, err := s.clientRepo.FindByClientID(ctx, params.Get("client_id"))
+	if err != nil {
+		return nil, oauthError(models.OAuthErrInvalidClient, "unknown client", http.StatusBadRequest)
+	}
+
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !client.AllowsRedirectURI(redirectURI) {
+		return nil, oauthError(models.OAuthErrInvalidRequest, "redirect_uri is not registered for the client", http.StatusBadRequest)
+	}
+
+	req := &AuthorizeRequest{
+		Client:              client,
+		RedirectURI:         redirectURI,
+		State:               params.Get("state"),
+		CodeChallenge:       params.Get("code_challenge"),
+		CodeChallengeMethod: params.Get("code_challenge_method"),
+		Nonce:               params.Get("nonce"),
+	}
+
+	if params.Get("response_type") != "code" {
+		return req, oauthError(models.OAuthErrUnsupportedResponseType, "response_type must be code", http.StatusBadRequest)
+	}
+	if !client.AllowsGrant(models.GrantAuthorizationCode) {
+		return req, oauthError(models.OAuthErrUnauthorizedClient, "the client may not use the authorization_code grant", http.StatusBadRequest)
+	}
+
+	if req.Scope, err = grantedScope(client, params.Get("scope")); err != nil {
+		return req, err
+	}
+
+	switch {
+	case req.CodeChallenge == "" && client.Public:
+		return req, oauthError(models.OAuthErrInvalidRequest, "public clients must use PKCE", http.StatusBadRequest)
+	case req.CodeChallenge != "" && req.CodeChallengeMethod != "S256":
+		return req, oauthError(models.OAuthErrInvalidRequest, "code_challenge_method must be S256", http.StatusBadRequest)
+	}
+
+	return req, nil
+}
+
+// IssueCode records the user's consent to an authorization request and
+// returns a single-use authorization code for it. authTime is when the user
+// signed in.
+func (s *OAuthService) IssueCode(ctx context.Context, req *AuthorizeRequest, user *models.User, authTime time.Time) (string, error) {
+	code, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.tokenRepo.Create(ctx, &models.OAuthToken{
+		Kind:                models.OAuthTokenCode,
+		TokenHash:           hashToken(code),
+		ClientID:            req.Client.ClientID,
+		UserID:              user.ID.Hex(),
+		OrgID:               user.OrgID,
+		Scope:               req.Scope,
+		RedirectURI:         req.RedirectURI,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		AuthTime:            &authTime,
+		ExpiresAt:           time.Now().Add(s.config.OAuthCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:     models.AuditActionOAuthAuthorize,
+		ActorID:    user.ID.Hex(),
+		ActorEmail: user.Email,
+		OrgID:      user.OrgID,
+		TargetID:   req.Client.ClientID,
+		Success:    true,
+		Changes:    map[string]models.AuditChange{"scope": {From: nil, To: req.Scope}},
+	})
+
+	return code, nil
+}
+
+// SignIn checks a user's credentials on the consent screen
+func (s *OAuthService) SignIn(ctx context.Context, email, password string) (*models.User, error) {
+	return s.userService.Login(ctx, &models.LoginRequest{Email: email, Password: password})
+}
+
+// NewSession returns a token for the sign-in cookie, so the user is not asked
+// for their password on every authorization
+func (s *OAuthService) NewSession(user *models.User) (string, error) {
+	claims := utils.JWTClaims{UserID: user.ID.Hex(), Email: user.Email}
+	claims.Issuer = s.config.OAuthIssuer
+	claims.Audience = jwt.ClaimStrings{oauthSessionAudience}
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(s.config.OAuthSessionTTL))
+	return utils.GenerateToken(claims, s.config)
+}
+
+// NewConsentBinding returns a random value to tie consent forms to before the
+// user has a session; the handler keeps it in a cookie
+func (s *OAuthService) NewConsentBinding() (string, error) {
+	return generateToken()
+}
+
+// ConsentToken returns an anti-CSRF token for one rendering of the consent
+// form, tied to binding: the sign-in cookie of the signed-in user, or the
+// value of NewConsentBinding before they sign in. Other sites, sibling
+// subdomains included, cannot read it, so they cannot post the form.
+func (s *OAuthService) ConsentToken(binding string) (string, error) {
+	nonce, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	return nonce + "." + s.consentMAC(nonce, binding), nil
+}
+
+// CheckConsentToken reports, in constant time, whether token was issued by
+// ConsentToken for binding
+func (s *OAuthService) CheckConsentToken(binding, token string) bool {
+	nonce, mac, ok := strings.Cut(token, ".")
+	if !ok || binding == "" {
+		return false
+	}
+	return hmac.Equal([]byte(mac), []byte(s.consentMAC(nonce, binding)))
+}
+
+// consentMAC authenticates a consent token's nonce and binding with the JWT
+// secret
+func (s *OAuthService) consentMAC(nonce, binding string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.JWTSecret))
+	mac.Write([]byte("oauth-consent\x00" + nonce + "\x00" + binding))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SessionUser returns the active user a sign-in cookie token belongs to and
+// when they signed in
+func (s *OAuthService) SessionUser(ctx context.Context, token string) (*models.User, time.Time, error) {
+	claims, err := utils.ValidateToken(token, s.config)
+	if err != nil || len(claims.Audience) != 1 || claims.Audience[0] != oauthSessionAudience {
+		return nil, time.Time{}, errors.New("invalid session")
+	}
+
+	user, err := s.userRepo.FindByID(tenant.AcrossOrgs(ctx), claims.UserID)
+	if err != nil || !user.IsActive || user.ServiceAccount {
+		return nil, time.Time{}, errors.New("invalid session")
+	}
+	return user, claims.IssuedAt.Time, nil
+}
+
+// AuthenticateClient checks the credentials a client sent to the token,
+// introspection or revocation endpoint. Public clients send no secret.
+func (s *OAuthService) AuthenticateClient(ctx context.Context, clientID, secret string) (*models.OAuthClient, error) {
+	invalid := oauthError(models.OAuthErrInvalidClient, "client authentication failed", http.StatusUnauthorized)
+	if clientID == "" {
+		return nil, invalid
+	}
+
+	client, err := s.clientRepo.FindByClientID(ctx, clientID)
+	if err != nil {
+		return nil, invalid
+	}
+
+	if client.Public {
+		if secret != "" {
+			return nil, invalid
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, invalid
+	}
+	return client, nil
+}
+
+// Token runs the grant named by the grant_type parameter for an authenticated client
+func (s *OAuthService) Token(ctx context.Context, client *models.OAuthClient, params url.Values) (*models.OAuthTokenResponse, error) {
+	grantType := params.Get("grant_type")
+	switch grantType {
+	case models.GrantAuthorizationCode, models.GrantRefreshToken, models.GrantClientCredentials:
+	case "":
+		return nil, oauthError(models.OAuthErrInvalidRequest, "grant_type is required", http.StatusBadRequest)
+	default:
+		return nil, oauthError(models.OAuthErrUnsupportedGrantType, "", http.StatusBadRequest)
+	}
+	if !client.AllowsGrant(grantType) {
+		return nil, oauthError(models.OAuthErrUnauthorizedClient, "the client may not use the "+grantType+" grant", http.StatusBadRequest)
+	}
+
+	switch grantType {
+	case models.GrantAuthorizationCode:
+		return s.exchangeCode(ctx, client, params)
+	case models.GrantRefreshToken:
+		return s.refresh(ctx, client, params)
+	default:
+		return s.clientCredentials(ctx, client, params)
+	}
+}
+
+// exchangeCode redeems an authorization code of the client, checking the
+// redirect URI and the PKCE verifier. Redeeming a code twice revokes the
+// tokens issued from it, as RFC 6749 section 4.1.2 recommends: the code has
+// leaked.
+func (s *OAuthService) exchangeCode(ctx context.Context, client *models.OAuthClient, params url.Values) (*models.OAuthTokenResponse, error) {
+	invalidGrant := oauthError(models.OAuthErrInvalidGrant, "invalid or expired authorization code", http.StatusBadRequest)
+
+	codeHash := hashToken(params.Get("code"))
+	code, err := s.tokenRepo.RedeemCode(ctx, codeHash, client.ClientID)
+	if err != nil {
+		if used, err := s.tokenRepo.Find(ctx, models.OAuthTokenCode, codeHash); err == nil && used.UsedAt != nil && used.ClientID == client.ClientID {
+			s.revokeReplayedGrant(ctx, used)
+		}
+		return nil, invalidGrant
+	}
+	// redirect_uri may only be left out when the client has a single one,
+	// which the authorization request then defaulted to
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if code.RedirectURI != redirectURI {
+		return nil, invalidGrant
+	}
+	if code.CodeChallenge != "" && !verifyCodeChallenge(code.CodeChallenge, params.Get("code_verifier")) {
+		return nil, oauthError(models.OAuthErrInvalidGrant, "code_verifier does not match the code_challenge", http.StatusBadRequest)
+	}
+
+	user, err := s.activeUser(ctx, code.UserID)
+	if err != nil {
+		return nil, invalidGrant
+	}
+
+	return s.issueTokens(ctx, client, user, code, code.Scope, code.Scope)
+}
+
+// refresh redeems a refresh token for a new access token and a new refresh
+// token; the old refresh token stops working. A narrower scope may be asked for.
+func (s *OAuthService) refresh(ctx context.Context, client *models.OAuthClient, params url.Values) (*models.OAuthTokenResponse, error) {
+	invalidGrant := oauthError(models.OAuthErrInvalidGrant, "invalid or expired refresh token", http.StatusBadRequest)
+
+	// The scope is checked before the token is used up, so asking for too
+	// much does not cost the client its grant
+	tokenHash := hashToken(params.Get("refresh_token"))
+	token, err := s.tokenRepo.Find(ctx, models.OAuthTokenRefresh, tokenHash)
+	if err != nil || token.ClientID != client.ClientID {
+		return nil, invalidGrant
+	}
+
+	scope := token.Scope
+	if requested := params.Get("scope"); requested != "" {
+		granted := models.SplitScopes(token.Scope)
+		for _, requestedScope := range models.SplitScopes(requested) {
+			if !containsString(granted, requestedScope) {
+				return nil, oauthError(models.OAuthErrInvalidScope, "scope exceeds the original grant", http.StatusBadRequest)
+			}
+		}
+		scope = models.JoinScopes(models.SplitScopes(requested))
+	}
+
+	if token, err = s.tokenRepo.Consume(ctx, models.OAuthTokenRefresh, tokenHash, client.ClientID); err != nil {
+		return nil, invalidGrant
+	}
+
+	user, err := s.activeUser(ctx, token.UserID)
+	if err != nil {
+		return nil, invalidGrant
+	}
+
+	// ID tokens issued on refresh carry no nonce
+	token.Nonce = ""
+	return s.issueTokens(ctx, client, user, token, scope, token.Scope)
+}
+
+// clientCredentials issues an access token to a confidential client acting
+// on its own behalf, without a user and without a refresh token
+func (s *OAuthService) clientCredentials(ctx context.Context, client *models.OAuthClient, params url.Values) (*models.OAuthTokenResponse, error) {
+	scope, err := grantedScope(client, params.Get("scope"))
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, client, nil, nil, scope, "")
+}
+
+// issueTokens issues an access token for scope and, when a user granted it
+// with the code or refresh token grant, a refresh token for refreshScope if
+// the client may use the refresh_token grant and an ID token if scope
+// includes openid
+func (s *OAuthService) issueTokens(ctx context.Context, client *models.OAuthClient, user *models.User, grant *models.OAuthToken, scope, refreshScope string) (*models.OAuthTokenResponse, error) {
+	orgID, grantID := "", ""
+	if grant != nil {
+		orgID, grantID = grant.OrgID, grant.GrantID
+		if grantID == "" {
+			grantID = grant.ID.Hex()
+		}
+	}
+
+	accessToken, err := s.accessToken(client, user, orgID, grantID, scope)
+	if err != nil {
+		return nil, oauthError(models.OAuthErrServerError, "", http.StatusInternalServerError)
+	}
+
+	response := &models.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.config.OAuthAccessTokenTTL / time.Second),
+		Scope:       scope,
+	}
+
+	if user != nil && client.AllowsGrant(models.GrantRefreshToken) {
+		refreshToken, err := generateToken()
+		if err != nil {
+			return nil, oauthError(models.OAuthErrServerError, "", http.StatusInternalServerError)
+		}
+		err = s.tokenRepo.Create(ctx, &models.OAuthToken{
+			Kind:      models.OAuthTokenRefresh,
+			TokenHash: hashToken(refreshToken),
+			ClientID:  client.ClientID,
+			UserID:    user.ID.Hex(),
+			OrgID:     orgID,
+			Scope:     refreshScope,
+			GrantID:   grantID,
+			AuthTime:  grant.AuthTime,
+			ExpiresAt: time.Now().Add(s.config.OAuthRefreshTokenTTL),
+		})
+		if err != nil {
+			return nil, oauthError(models.OAuthErrServerError, "", http.StatusInternalServerError)
+		}
+		response.RefreshToken = refreshToken
+	}
+
+	if user != nil && containsString(models.SplitScopes(scope), models.ScopeOpenID) {
+		if response.IDToken, err = s.idToken(client, user, grant, scope, accessToken); err != nil {
+			return nil, oauthError(models.OAuthErrServerError, "", http.StatusInternalServerError)
+		}
+	}
+
+	return response, nil
+}
+
+// accessToken signs an access token through utils.GenerateToken. It carries
+// the user's claims in orgID, if there is a user, the grant it descends from,
+// the granted scope and the client as audience; its subject is the user, or
+// the client itself.
+func (s *OAuthService) accessToken(client *models.OAuthClient, user *models.User, orgID, grantID, scope string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	claims := utils.JWTClaims{Scope: scope, ClientID: client.ClientID, GrantID: grantID}
+	claims.Subject = client.ClientID
+	if user != nil {
+		claims.UserID = user.ID.Hex()
+		claims.Email = user.Email
+		claims.Role = user.EffectiveRole(orgID)
+		claims.OrgID = orgID
+		claims.OrgRole = user.OrgRole(orgID)
+		claims.SuperAdmin = user.IsSuperAdmin()
+		claims.Subject = user.ID.Hex()
+	}
+	claims.Issuer = s.config.OAuthIssuer
+	claims.Audience = jwt.ClaimStrings{client.ClientID}
+	claims.ID = hex.EncodeToString(jti)
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(s.config.OAuthAccessTokenTTL))
+
+	return utils.GenerateToken(claims, s.config)
+}
+
+// Introspect reports whether a token is active and what it grants (RFC
+// 7662). Revoked and expired tokens, tokens of deactivated users and tokens
+// this server did not issue are inactive.
+func (s *OAuthService) Introspect(ctx context.Context, token string) *models.OAuthIntrospection {
+	inactive := &models.OAuthIntrospection{Active: false}
+
+	if claims, err := s.validAccessToken(ctx, token); err == nil {
+		return &models.OAuthIntrospection{
+			Active:    true,
+			Scope:     claims.Scope,
+			ClientID:  claims.ClientID,
+			Username:  claims.Email,
+			TokenType: "Bearer",
+			Exp:       claims.ExpiresAt.Unix(),
+			Iat:       claims.IssuedAt.Unix(),
+			Sub:       claims.Subject,
+			Aud:       claims.Audience,
+			Iss:       claims.Issuer,
+			Jti:       claims.ID,
+			OrgID:     claims.OrgID,
+			Role:      claims.Role,
+		}
+	}
+
+	refresh, err := s.tokenRepo.Find(ctx, models.OAuthTokenRefresh, hashToken(token))
+	if err != nil {
+		return inactive
+	}
+	user, err := s.activeUser(ctx, refresh.UserID)
+	if err != nil {
+		return inactive
+	}
+	return &models.OAuthIntrospection{
+		Active:    true,
+		Scope:     refresh.Scope,
+		ClientID:  refresh.ClientID,
+		Username:  user.Email,
+		TokenType: "refresh_token",
+		Exp:       refresh.ExpiresAt.Unix(),
+		Iat:       refresh.CreatedAt.Unix(),
+		Sub:       refresh.UserID,
+		Iss:       s.config.OAuthIssuer,
+		OrgID:     refresh.OrgID,
+	}
+}
+
+// Revoke revokes a refresh token or access token of the client (RFC 7009).
+// Revoking a refresh token ends its grant: the other refresh tokens and the
+// access tokens issued from the same authorization code. The user's other
+// grants to the client are left alone. Unknown tokens and tokens of other
+// clients are ignored, as the RFC requires.
+func (s *OAuthService) Revoke(ctx context.Context, client *models.OAuthClient, token string) error {
+	if refresh, err := s.tokenRepo.Find(ctx, models.OAuthTokenRefresh, hashToken(token)); err == nil {
+		if refresh.ClientID != client.ClientID {
+			return nil
+		}
+		if refresh.GrantID == "" {
+			if _, err := s.tokenRepo.Consume(ctx, models.OAuthTokenRefresh, refresh.TokenHash, client.ClientID); err != nil {
+				return err
+			}
+		} else if err := s.revokeGrant(ctx, refresh.GrantID, refresh.ClientID, refresh.UserID); err != nil {
+			return err
+		}
+		s.recordRevoke(ctx, refresh.ClientID, refresh.UserID, refresh.OrgID, models.OAuthTokenRefresh)
+		return nil
+	}
+
+	claims, err := s.validAccessToken(ctx, token)
+	if err != nil || claims.ClientID != client.ClientID {
+		return nil
+	}
+	err = s.tokenRepo.Create(ctx, &models.OAuthToken{
+		Kind:      models.OAuthTokenRevokedAccess,
+		TokenHash: claims.ID,
+		ClientID:  claims.ClientID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return err
+	}
+	s.recordRevoke(ctx, claims.ClientID, claims.UserID, claims.OrgID, "access")
+	return nil
+}
+
+// recordRevoke writes the audit event of a token revocation. userID is empty
+// for client_credentials tokens.
+func (s *OAuthService) recordRevoke(ctx context.Context, clientID, userID, orgID, kind string) {
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   models.AuditActionOAuthRevoke,
+		OrgID:    orgID,
+		TargetID: userID,
+		Success:  true,
+		Changes: map[string]models.AuditChange{
+			"clientId":  {From: clientID, To: nil},
+			"tokenKind": {From: kind, To: nil},
+		},
+	})
+}
+
+// revokeGrant deletes the refresh tokens issued from an authorization code and
+// refuses its access tokens until the last of them expires
+func (s *OAuthService) revokeGrant(ctx context.Context, grantID, clientID, userID string) error {
+	if err := s.tokenRepo.DeleteGrant(ctx, grantID); err != nil {
+		return err
+	}
+	return s.tokenRepo.Create(ctx, &models.OAuthToken{
+		Kind:      models.OAuthTokenRevokedGrant,
+		TokenHash: grantID,
+		ClientID:  clientID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(s.config.OAuthAccessTokenTTL),
+	})
+}
+
+// revokeReplayedGrant revokes the tokens issued from an authorization code
+// that was redeemed twice
+func (s *OAuthService) revokeReplayedGrant(ctx context.Context, code *models.OAuthToken) {
+	grantID := code.ID.Hex()
+	log.Printf("⚠️  Authorization code of client %s was redeemed twice; revoking the tokens issued from it", code.ClientID)
+
+	if err := s.revokeGrant(ctx, grantID, code.ClientID, code.UserID); err != nil {
+		log.Printf("⚠️  Failed to revoke the tokens of grant %s: %v", grantID, err)
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   models.AuditActionOAuthCodeReplay,
+		OrgID:    code.OrgID,
+		TargetID: code.UserID,
+		Success:  false,
+		Reason:   "authorization code of client " + code.ClientID + " redeemed twice; its tokens were revoked",
+	})
+}
+
+// validAccessToken parses an access token issued to an OAuth client and
+// checks that it is not revoked and its user is still active
+func (s *OAuthService) validAccessToken(ctx context.Context, token string) (*utils.JWTClaims, error) {
+	claims, err := utils.ValidateToken(token, s.config)
+	if err != nil || claims.ClientID == "" || claims.ID == "" || claims.Issuer != s.config.OAuthIssuer {
+		return nil, errors.New("invalid token")
+	}
+
+	revoked, err := s.tokenRepo.Exists(ctx, models.OAuthTokenRevokedAccess, claims.ID)
+	if err != nil || revoked {
+		return nil, errors.New("invalid token")
+	}
+	if claims.GrantID != "" {
+		revoked, err := s.tokenRepo.Exists(ctx, models.OAuthTokenRevokedGrant, claims.GrantID)
+		if err != nil || revoked {
+			return nil, errors.New("invalid token")
+		}
+	}
+
+	if claims.UserID != "" {
+		if _, err := s.activeUser(ctx, claims.UserID); err != nil {
+			return nil, errors.New("invalid token")
+		}
+	}
+	return claims, nil
+}
+
+// activeUser loads a user that may still use their grants
+func (s *OAuthService) activeUser(ctx context.Context, id string) (*models.User, error) {
+	user, err := s.userRepo.FindByID(tenant.AcrossOrgs(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive || user.ServiceAccount {
+		return nil, errors.New("account is deactivated")
+	}
+	return user, nil
+}
+
+// grantedScope checks a requested scope parameter against the client's
+// registered scopes; an empty request grants all of them
+func grantedScope(client *models.OAuthClient, requested string) (string, error) {
+	scopes := models.SplitScopes(requested)
+	if len(scopes) == 0 {
+		return models.JoinScopes(client.Scopes), nil
+	}
+	for _, scope := range scopes {
+		if !containsString(client.Scopes, scope) {
+			return "", oauthError(models.OAuthErrInvalidScope, "scope "+scope+" is not registered for the client", http.StatusBadRequest)
+		}
+	}
+	return models.JoinScopes(scopes), nil
+}
+
+// verifyCodeChallenge checks a PKCE code verifier against an S256 challenge (RFC 7636)
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-._~", c)) {
+			return false
+		}
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// oauthError returns an OAuth error response with the given HTTP status
+func oauthError(code, description string, status int) *models.OAuthError {
+	return &models.OAuthError{Code: code, Description: description, Status: status}
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"user-management-system/config"
+	"user-management-system/models"
+	"user-management-system/repositories"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	testIssuer      = "http://issuer.test"
+	testRedirectURI = "https://app.example.com/callback"
+	testVerifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// testSigningKey is generated once, as RSA key generation is slow
+var testSigningKey = sync.OnceValue(func() *SigningKey {
+	key, err := LoadSigningKey("")
+	if err != nil {
+		panic(err)
+	}
+	return key
+})
+
+// newOAuthService wires an OAuth service onto the test environment
+func (env *testEnv) newOAuthService() *OAuthService {
+	cfg := &config.Config{
+		JWTSecret:            "test-secret",
+		JWTExpireHours:       1,
+		OAuthIssuer:          testIssuer,
+		OAuthCodeTTL:         time.Minute,
+		OAuthAccessTokenTTL:  time.Hour,
+		OAuthRefreshTokenTTL: 24 * time.Hour,
+		OAuthSessionTTL:      time.Hour,
+	}
+	return NewOAuthService(repositories.NewOAuthClientRepository(env.db.Collection("oauth_clients")), repositories.NewOAuthTokenRepository(env.db.Collection("oauth_tokens")), env.userRepo, env.userService, env.auditService, testSigningKey(), cfg)
+}
+
+// createOAuthClient registers a client for the code and refresh token grants
+func createOAuthClient(t *testing.T, s *OAuthService, public bool, scopes ...string) (*models.OAuthClient, string) {
+	t.Helper()
+
+	client, secret, err := s.CreateClient(systemContext(), &models.OAuthClientRequest{
+		Name:         "Reports",
+		Public:       public,
+		RedirectURIs: []string{testRedirectURI},
+		GrantTypes:   []string{models.GrantAuthorizationCode, models.GrantRefreshToken},
+		Scopes:       scopes,
+	})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	return client, secret
+}
+
+// authorizeCode runs an authorization request with PKCE for the user and
+// returns the code it issues
+func authorizeCode(t *testing.T, s *OAuthService, client *models.OAuthClient, user *models.User, scope string) string {
+	t.Helper()
+
+	req, err := s.ParseAuthorizeRequest(systemContext(), url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ClientID},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {scope},
+		"code_challenge":        {codeChallenge(testVerifier)},
+		"code_challenge_method": {"S256"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+	})
+	if err != nil {
+		t.Fatalf("ParseAuthorizeRequest: %v", err)
+	}
+	code, err := s.IssueCode(systemContext(), req, user, time.Now())
+	if err != nil {
+		t.Fatalf("IssueCode: %v", err)
+	}
+	return code
+}
+
+// exchange redeems a code with the test PKCE verifier
+func exchange(s *OAuthService, client *models.OAuthClient, code string) (*models.OAuthTokenResponse, error) {
+	return s.Token(systemContext(), client, url.Values{
+		"grant_type":    {models.GrantAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {testVerifier},
+	})
+}
+
+// refreshTokens redeems a refresh token, optionally for a narrower scope
+func refreshTokens(s *OAuthService, client *models.OAuthClient, refreshToken, scope string) (*models.OAuthTokenResponse, error) {
+	params := url.Values{"grant_type": {models.GrantRefreshToken}, "refresh_token": {refreshToken}}
+	if scope != "" {
+		params.Set("scope", scope)
+	}
+	return s.Token(systemContext(), client, params)
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// oauthErrorCode returns the OAuth error code of err, or "" if it has none
+func oauthErrorCode(err error) string {
+	var oauthErr *models.OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErr.Code
+	}
+	return ""
+}
+
+func TestParseAuthorizeRequest(t *testing.T) {
+	env := newTestEnv(t)
+	s := env.newOAuthService()
+	public, _ := createOAuthClient(t, s, true, "reports")
+	confidential, _ := createOAuthClient(t, s, false, "reports")
+
+	for _, test := range []struct {
+		name       string
+		client     *models.OAuthClient
+		params     url.Values
+		want       string
+		noRedirect bool
+	}{
+		{"unknown client", nil, url.Values{"client_id": {"nope"}}, models.OAuthErrInvalidClient, true},
+		{"unregistered redirect", public, url.Values{"redirect_uri": {"https://evil.example.com/cb"}}, models.OAuthErrInvalidRequest, true},
+		{"token response type", public, url.Values{"response_type": {"token"}}, models.OAuthErrUnsupportedResponseType, false},
+		{"unregistered scope", confidential, url.Values{"response_type": {"code"}, "scope": {"admin"}}, models.OAuthErrInvalidScope, false},
+		{"public without PKCE", public, url.Values{"response_type": {"code"}}, models.OAuthErrInvalidRequest, false},
+		{"plain PKCE", public, url.Values{"response_type": {"code"}, "code_challenge": {testVerifier}, "code_challenge_method": {"plain"}}, models.OAuthErrInvalidRequest, false},
+		{"confidential without PKCE", confidential, url.Values{"response_type": {"code"}}, "", false},
+	} {
+		if test.client != nil {
+			test.params.Set("client_id", test.client.ClientID)
+		}
+		req, err := s.ParseAuthorizeRequest(systemContext(), test.params)
+		if code := oauthErrorCode(err); code != test.want {
+			t.Errorf("%s: err = %v, want %q", test.name, err, test.want)
+		}
+		if (req == nil) != test.noRedirect {
+			t.Errorf("%s: request = %+v, want redirect %v", test.name, req, !test.noRedirect)
+		}
+	}
+}
+
+func TestExchangeCodeWithPKCE(t *testing.T) {
+	env := newTestEnv(t)
+	s := env.newOAuthService()
+	client, _ := createOAuthClient(t, s, true, "reports")
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	// A wrong verifier is refused, and burns the code
+	code := authorizeCode(t, s, client, user, "reports")
+	_, err := s.Token(systemContext(), client, url.Values{
+		"grant_type":    {models.GrantAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {"wrong-verifier-wrong-verifier-wrong-verifier"},
+	})
+	if oauthErrorCode(err) != models.OAuthErrInvalidGrant {
+		t.Fatalf("wrong verifier: err = %v", err)
+	}
+	if _, err := exchange(s, client, code); err == nil {
+		t.Fatal("the code worked after a failed attempt")
+	}
+
+	code = authorizeCode(t, s, client, user, "reports")
+	tokens, err := exchange(s, client, code)
+	if err != nil {
+		t.Fatalf("exchanging the code: %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.Scope != "reports" || tokens.TokenType != "Bearer" || tokens.IDToken != "" {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+}
+
+func TestCodeIsBoundToItsClient(t *testing.T) {
+	env := newTestEnv(t)
+	s := env.newOAuthService()
+	client, _ := createOAuthClient(t, s, true, "reports")
+	other, _ := createOAuthClient(t, s, true, "reports")
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	code := authorizeCode(t, s, client, user, "reports")
+	if _, err := exchange(s, other, code); oauthErrorCode(err) != models.OAuthErrInvalidGrant {
+		t.Fatalf("another client redeemed the code: err = %v", err)
+	}
+
+	// The other client's attempt leaves the code usable by its own client
+	if _, err := exchange(s, client, code); err != nil {
+		t.Fatalf("exchanging the code after another client tried it: %v", err)
+	}
+}
+
+func TestReplayedCodeRevokesItsTokens(t *testing.T) {
+	env := newTestEnv(t)
+	s := env.newOAuthService()
+	client, _ := createOAuthClient(t, s, true, "reports")
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	code := authorizeCode(t, s, client, user, "reports")
+	first, err := exchange(s, client, code)
+	if err != nil {
+		t.Fatalf("exchanging the code: %v", err)
+	}
+	refreshed, err := refreshTokens(s, client, first.RefreshToken, "")
+	if err != nil {
+		t.Fatalf("refreshing: %v", err)
+	}
+
+	// A grant from another code is not affected
+	untouched, err := exchange(s, client, authorizeCode(t, s, client, user, "reports"))
+	if err != nil {
+		t.Fatalf("exchanging another code: %v", err)
+	}
+
+	if _, err := exchange(s, client, code); oauthErrorCode(err) != models.OAuthErrInvalidGrant {
+		t.Fatalf("replaying the code: err = %v", err)
+	}
+
+	for name, token := range map[string]string{
+		"first access token":     first.AccessToken,
+		"refreshed access token": refreshed.AccessToken,
+		"refresh token":          refreshed.RefreshToken,
+	} {
+		if s.Introspect(systemContext(), token).Active {
+			t.Errorf("%s is still active after the code was replayed", name)
+		}
+	}
+	if _, err := refreshTokens(s, client, refreshed.RefreshToken, ""); err == nil {
+		t.Fatal("the refresh token of a replayed code still works")
+	}
+	if !s.Introspect(systemContext(), untouched.AccessToken).Active || !s.Introspect(systemContext(), untouched.RefreshToken).Active {
+		t.Fatal("replaying a code revoked the tokens of another grant")
+	}
+	if actions := env.auditActions(); actions[len(actions)-1] != models.AuditActionOAuthCodeReplay {
+		t.Fatalf("audit actions = %v", actions)
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	env := newTestEnv(t)
+	s := env.newOAuthService()
+	client, _ := createOAuthClient(t, s, true, "reports", "exports")
+	other, _ := createOAuthClient(t, s, true, "reports", "exports")
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	tokens, err := exchange(s, client, authorizeCode(t, s, client, user, "reports exports"))
+	if err != nil {
+		t.Fatalf("exchanging the code: %v", err)
+	}
+
+	if _, err := refreshTokens(s, other, tokens.RefreshToken, ""); oauthErrorCode(err) != models.OAuthErrInvalidGrant {
+		t.Fatalf("another client used the refresh token: err = %v", err)
+	}
+	if _, err := refreshTokens(s, client, tokens.RefreshToken, "admin"); oauthErrorCode(err) != models.OAuthErrInvalidScope {
+		t.Fatalf("widening the scope: err = %v", err)
+	}
+
+	narrowed, err := refreshTokens(s, client, tokens.RefreshToken, "reports")
+	if err != nil {
+		t.Fatalf("refreshing: %v", err)
+	}
+	if narrowed.Scope != "reports" || narrowed.RefreshToken == tokens.RefreshToken {
+		t.Fatalf("refreshed tokens = %+v", narrowed)
+	}
+	if _, err := refreshTokens(s, client, tokens.RefreshToken, ""); oauthErrorCode(err) != models.OAuthErrInvalidGrant {
+		t.Fatalf("reusing a rotated refresh token: err = %v", err)
+	}
+
+	// The new refresh token keeps the original grant's scope
+	again, err := refreshTokens(s, client, narrowed.RefreshToken, "")
+	if err != nil || again.Scope != "exports reports" && again.Scope != "reports exports" {
+		t.Fatalf("refreshing the rotated token = %+v, %v", again, err)
+	}
+}
+
+func TestIntrospect(t *testing.T) {
+	env := newTestEnv(t)
+	s := env.newOAuthService()
+	client, _ := createOAuthClient(t, s, false, "reports")
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	tokens, err := exchange(s, client, authorizeCode(t, s, client, user, "reports"))
+	if err != nil {
+		t.Fatalf("exchanging the code: %v", err)
+	}
+
+	access := s.Introspect(systemContext(), tokens.AccessToken)
+	if !access.Active || access.Scope != "reports" || access.ClientID != client.ClientID || access.Username != "jane@example.com" ||
+		access.Sub != user.ID.Hex() || access.Iss != testIssuer || access.TokenType != "Bearer" || access.OrgID != env.defaultOrg.ID.Hex() {
+		t.Fatalf("access token introspection = %+v", access)
+	}
+	refresh := s.Introspect(systemContext(), tokens.RefreshToken)
+	if !refresh.Active || refresh.TokenType != "refresh_token" || refresh.Sub != user.ID.Hex() {
+		t.Fatalf("refresh token introspection = %+v", refresh)
+	}
+	if s.Introspect(systemContext(), "not-a-token").Active {
+		t.Fatal("an unknown token is active")
+	}
+
+	// Deactivating the user ends their tokens at once
+	if err := env.userRepo.Update(systemContext(), user.ID.Hex(), bson.M{"isActive": false}); err != nil {
+		t.Fatalf("deactivating: %v", err)
+	}
+	if s.Introspect(systemContext(), tokens.AccessToken).Active || s.Introspect(systemContext(), tokens.RefreshToken).Active {
+		t.Fatal("tokens of a deactivated user are active")
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	env := newTestEnv(t)
+	s := env.newOAuthService()
+	client, _ := createOAuthClient(t, s, false, "reports")
+	other, _ := createOAuthClient(t, s, false, "reports")
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	tokens, err := exchange(s, client, authorizeCode(t, s, client, user, "reports"))
+	if err != nil {
+		t.Fatalf("exchanging the code: %v", err)
+	}
+
+	// Other clients' revocations are ignored
+	if err := s.Revoke(systemContext(), other, tokens.AccessToken); err != nil {
+		t.Fatalf("Revoke by another client: %v", err)
+	}
+	if err := s.Revoke(systemContext(), other, tokens.RefreshToken); err != nil {
+		t.Fatalf("Revoke by another client: %v", err)
+	}
+	if !s.Introspect(systemContext(), tokens.AccessToken).Active || !s.Introspect(systemContext(), tokens.RefreshToken).Active {
+		t.Fatal("another client revoked the tokens")
+	}
+
+	if err := s.Revoke(systemContext(), client, tokens.AccessToken); err != nil {
+		t.Fatalf("revoking the access token: %v", err)
+	}
+	if s.Introspect(systemContext(), tokens.AccessToken).Active {
+		t.Fatal("a revoked access token is active")
+	}
+	if err := s.Revoke(systemContext(), client, tokens.AccessToken); err != nil {
+		t.Fatalf("revoking the access token twice: %v", err)
+	}
+
+	if err := s.Revoke(systemContext(), client, tokens.RefreshToken); err != nil {
+		t.Fatalf("revoking the refresh token: %v", err)
+	}
+	if _, err := refreshTokens(s, client, tokens.RefreshToken, ""); oauthErrorCode(err) != models.OAuthErrInvalidGrant {
+		t.Fatalf("using a revoked refresh token: err = %v", err)
+	}
+	if err := s.Revoke(systemContext(), client, "unknown-token"); err != nil {
+		t.Fatalf("revoking an unknown token: %v", err)
+	}
+}
+
+func TestRevokeEndsOnlyItsGrant(t *testing.T) {
+	env := newTestEnv(t)
+	s := env.newOAuthService()
+	client, _ := createOAuthClient(t, s, false, "reports")
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	// Two grants of the same user to the same client, e.g. on two devices
+	laptopCode := authorizeCode(t, s, client, user, "reports")
+	laptop, err := exchange(s, client, laptopCode)
+	if err != nil {
+		t.Fatalf("exchanging the laptop code: %v", err)
+	}
+	phone, err := exchange(s, client, authorizeCode(t, s, client, user, "reports"))
+	if err != nil {
+		t.Fatalf("exchanging the phone code: %v", err)
+	}
+	rotated, err := refreshTokens(s, client, laptop.RefreshToken, "")
+	if err != nil {
+		t.Fatalf("refreshing the laptop tokens: %v", err)
+	}
+
+	if err := s.Revoke(systemContext(), client, rotated.RefreshToken); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	// The laptop grant ends, access tokens issued before the rotation included
+	for name, token := range map[string]string{"first access token": laptop.AccessToken, "rotated access token": rotated.AccessToken, "refresh token": rotated.RefreshToken} {
+		if s.Introspect(systemContext(), token).Active {
+			t.Errorf("laptop %s is still active", name)
+		}
+	}
+
+	// The phone grant goes on
+	if !s.Introspect(systemContext(), phone.AccessToken).Active {
+		t.Fatal("phone access token was revoked with the laptop grant")
+	}
+	if _, err := refreshTokens(s, client, phone.RefreshToken, ""); err != nil {
+		t.Fatalf("refreshing the phone tokens: %v", err)
+	}
+
+	// The used laptop code is kept, so replaying it is still detected
+	if _, err := exchange(s, client, laptopCode); oauthErrorCode(err) != models.OAuthErrInvalidGrant {
+		t.Fatalf("replaying the laptop code: err = %v", err)
+	}
+	if countActions(env.auditActions(), models.AuditActionOAuthCodeReplay) != 1 {
+		t.Fatalf("audit actions = %v, want a code replay", env.auditActions())
+	}
+}
+
+func TestRevokeIsAudited(t *testing.T) {
+	env := newTestEnv(t)
+	s := env.newOAuthService()
+	client, _ := createOAuthClient(t, s, false, "reports")
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	tokens, err := exchange(s, client, authorizeCode(t, s, client, user, "reports"))
+	if err != nil {
+		t.Fatalf("exchanging the code: %v", err)
+	}
+	for _, token := range []string{tokens.AccessToken, tokens.RefreshToken, "unknown-token"} {
+		if err := s.Revoke(systemContext(), client, token); err != nil {
+			t.Fatalf("Revoke: %v", err)
+		}
+	}
+
+	var kinds []string
+	err = env.auditRepo.Stream(systemContext(), models.AuditFilter{Action: models.AuditActionOAuthRevoke}, func(event *models.AuditEvent) error {
+		if event.TargetID != user.ID.Hex() || event.Changes["clientId"].From != client.ClientID {
+			t.Errorf("revocation event = %+v", event)
+		}
+		kinds = append(kinds, event.Changes["tokenKind"].From.(string))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading audit events: %v", err)
+	}
+	if strings.Join(kinds, ",") != "access,"+models.OAuthTokenRefresh {
+		t.Fatalf("revoked token kinds = %v, want the access and the refresh token", kinds)
+	}
+}
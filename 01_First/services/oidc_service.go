@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"time"
+
+	"user-management-system/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OpenID Connect on top of the OAuth authorization server: ID tokens for the
+// openid scope, the userinfo endpoint, discovery and RP-initiated logout
+
+// idTokenClaims are the claims of an ID token (OpenID Connect Core 1.0
+// section 2). The user's claims depend on the granted scopes, as in userinfo.
+type idTokenClaims struct {
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	AtHash   string `json:"at_hash,omitempty"`
+
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	Role  string `json:"role,omitempty"`
+
+	jwt.RegisteredClaims
+}
+
+// LogoutRequest is a validated request to /oauth/logout
+type LogoutRequest struct {
+	Client      *models.OAuthClient // Set with RedirectURI
+	RedirectURI string              // Registered post-logout redirect URI, if one was asked for
+	State       string
+	Subject     string // User the id_token_hint was issued to, if one was sent
+}
+
+// Discovery returns the OpenID Provider metadata
+func (s *OAuthService) Discovery() *models.OIDCDiscovery {
+	issuer := s.config.OAuthIssuer
+	return &models.OIDCDiscovery{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + "/oauth/userinfo",
+		JWKSURI:                           issuer + "/oauth/jwks",
+		EndSessionEndpoint:                issuer + "/oauth/logout",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		ScopesSupported:                   []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail},
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{models.GrantAuthorizationCode, models.GrantRefreshToken, models.GrantClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{jwt.SigningMethodRS256.Alg()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "name", "email", "role"},
+	}
+}
+
+// JWKS returns the keys ID tokens are signed with
+func (s *OAuthService) JWKS() *models.JSONWebKeySet {
+	return &models.JSONWebKeySet{Keys: []models.JSONWebKey{s.signingKey.JWK()}}
+}
+
+// UserInfo returns the claims about the user an access token was issued for.
+// The token must have been granted the openid scope.
+func (s *OAuthService) UserInfo(ctx context.Context, token string) (*models.OIDCUserInfo, error) {
+	claims, err := s.validAccessToken(ctx, token)
+	if err != nil || claims.UserID == "" {
+		return nil, oauthError(models.OAuthErrInvalidToken, "the access token is invalid, expired or revoked", http.StatusUnauthorized)
+	}
+	if !containsString(models.SplitScopes(claims.Scope), models.ScopeOpenID) {
+		return nil, oauthError(models.OAuthErrInsufficientScope, "the access token was not granted the openid scope", http.StatusForbidden)
+	}
+
+	user, err := s.activeUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, oauthError(models.OAuthErrInvalidToken, "the access token is invalid, expired or revoked", http.StatusUnauthorized)
+	}
+
+	return userInfo(user, claims.OrgID, claims.Scope), nil
+}
+
+// ParseLogoutRequest validates the parameters of an RP-initiated logout
+// request (OpenID Connect RP-Initiated Logout 1.0). The post-logout redirect
+// URI must be registered for the client named by client_id or by the
+// id_token_hint's audience. Expired ID tokens are accepted as hints.
+func (s *OAuthService) ParseLogoutRequest(ctx context.Context, params url.Values) (*LogoutRequest, error) {
+	req := &LogoutRequest{State: params.Get("state")}
+	clientID := params.Get("client_id")
+
+	if hint := params.Get("id_token_hint"); hint != "" {
+		var claims jwt.RegisteredClaims
+		err := s.signingKey.Parse(hint, &claims, jwt.WithoutClaimsValidation())
+		if err != nil || claims.Issuer != s.config.OAuthIssuer || len(claims.Audience) != 1 || claims.Subject == "" {
+			return nil, oauthError(models.OAuthErrInvalidRequest, "invalid id_token_hint", http.StatusBadRequest)
+		}
+		if clientID != "" && clientID != claims.Audience[0] {
+			return nil, oauthError(models.OAuthErrInvalidRequest, "client_id does not match the id_token_hint", http.StatusBadRequest)
+		}
+		clientID = claims.Audience[0]
+		req.Subject = claims.Subject
+	}
+
+	if uri := params.Get("post_logout_redirect_uri"); uri != "" {
+		if clientID == "" {
+			return nil, oauthError(models.OAuthErrInvalidRequest, "post_logout_redirect_uri needs client_id or id_token_hint", http.StatusBadRequest)
+		}
+		client, err := s.clientRepo.FindByClientID(ctx, clientID)
+		if err != nil {
+			return nil, oauthError(models.OAuthErrInvalidClient, "unknown client", http.StatusBadRequest)
+		}
+		if !client.AllowsPostLogoutRedirectURI(uri) {
+			return nil, oauthError(models.OAuthErrInvalidRequest, "post_logout_redirect_uri is not registered for the client", http.StatusBadRequest)
+		}
+		req.Client = client
+		req.RedirectURI = uri
+	}
+
+	return req, nil
+}
+
+// idToken signs an ID token for the user, with the nonce and sign-in time of
+// the grant and the hash of the access token issued with it
+func (s *OAuthService) idToken(client *models.OAuthClient, user *models.User, grant *models.OAuthToken, scope, accessToken string) (string, error) {
+	info := userInfo(user, grant.OrgID, scope)
+	now := time.Now()
+
+	// at_hash is the left half of the access token's SHA-256 (Core section 3.1.3.6)
+	sum := sha256.Sum256([]byte(accessToken))
+
+	claims := idTokenClaims{
+		Nonce:  grant.Nonce,
+		AtHash: base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]),
+		Name:   info.Name,
+		Email:  info.Email,
+		Role:   info.Role,
+	}
+	if grant.AuthTime != nil {
+		claims.AuthTime = grant.AuthTime.Unix()
+	}
+	claims.Issuer = s.config.OAuthIssuer
+	claims.Subject = info.Sub
+	claims.Audience = jwt.ClaimStrings{client.ClientID}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(s.config.OAuthAccessTokenTTL))
+
+	return s.signingKey.Sign(claims)
+}
+
+// userInfo returns the user's claims for the granted scope: name and the
+// role in orgID for profile, email for email
+func userInfo(user *models.User, orgID, scope string) *models.OIDCUserInfo {
+	scopes := models.SplitScopes(scope)
+	info := &models.OIDCUserInfo{Sub: user.ID.Hex()}
+	if containsString(scopes, models.ScopeProfile) {
+		info.Name = user.Name
+		info.Role = user.EffectiveRole(orgID)
+	}
+	if containsString(scopes, models.ScopeEmail) {
+		info.Email = user.Email
+	}
+	return info
+}
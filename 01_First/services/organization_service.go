@@ -0,0 +1,512 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/tenant"
+)
+
+// slugPattern is the shape of an organization slug
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+// OrganizationService manages organizations (tenants) and their members.
+// Permissions are checked against the caller's stored memberships, so a
+// changed role takes effect without waiting for a new token.
+type OrganizationService struct {
+	orgRepo         *repositories.OrganizationRepository
+	userRepo        *repositories.UserRepository
+	groupMemberRepo *repositories.GroupMemberRepository
+	auditService    *AuditService
+}
+
+// NewOrganizationService creates a new organization service
+func NewOrganizationService(orgRepo *repositories.OrganizationRepository, userRepo *repositories.UserRepository, groupMemberRepo *repositories.GroupMemberRepository, auditService *AuditService) *OrganizationService {
+	return &OrganizationService{
+		orgRepo:         orgRepo,
+		userRepo:        userRepo,
+		groupMemberRepo: groupMemberRepo,
+		auditService:    auditService,
+	}
+}
+
+// EnsureDefaultOrganization creates the default organization if needed and
+// moves users created before organizations existed into it
+func (s *OrganizationService) EnsureDefaultOrganization(ctx context.Context, name string) (*models.Organization, error) {
+	org, err := s.orgRepo.EnsureBySlug(ctx, models.DefaultOrgSlug, name)
+	if err != nil {
+		return nil, err
+	}
+
+	assigned, err := s.userRepo.AssignOrphans(ctx, org.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if assigned > 0 {
+		log.Printf("🏢 Moved %d existing users into organization %q", assigned, org.Name)
+	}
+
+	return org, nil
+}
+
+// CreateOrganization creates an organization, optionally with an existing user as its owner
+func (s *OrganizationService) CreateOrganization(ctx context.Context, req *models.CreateOrganizationRequest) (*models.Organization, error) {
+	name := strings.TrimSpace(req.Name)
+	if len(name) < 2 || len(name) > 100 {
+		return nil, errors.New("name must be between 2 and 100 characters")
+	}
+
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if slug == "" {
+		slug = slugify(name)
+	}
+	if !slugPattern.MatchString(slug) {
+		return nil, errors.New("slug must be 2 to 50 lowercase letters, digits or dashes")
+	}
+
+	var owner *models.User
+	if req.OwnerEmail != "" {
+		var err error
+		owner, err = s.userRepo.FindByEmail(tenant.AcrossOrgs(ctx), req.OwnerEmail)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	org := &models.Organization{Name: name, Slug: slug}
+	if err := s.orgRepo.Create(ctx, org); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:   models.AuditActionOrgCreate,
+		TargetID: org.ID.Hex(),
+		OrgID:    org.ID.Hex(),
+		Success:  true,
+		Changes:  map[string]models.AuditChange{"name": {From: nil, To: name}},
+	})
+
+	if owner != nil {
+		if _, err := s.addMember(ctx, org, owner.ID.Hex(), models.OrgRoleOwner); err != nil {
+			return nil, err
+		}
+	}
+
+	return org, nil
+}
+
+// ListOrganizations lists every organization for super-admins, and the
+// caller's own organizations for everyone else
+func (s *OrganizationService) ListOrganizations(ctx context.Context, page, limit int) ([]*models.OrganizationResponse, int, int64, error) {
+	// Validate pagination parameters
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100 // Max limit
+	}
+
+	caller, err := s.caller(ctx)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	var orgs []*models.Organization
+	var total int64
+	if middleware.IsSuperAdmin(ctx) {
+		orgs, total, err = s.orgRepo.FindAll(ctx, page, limit)
+	} else {
+		// Members have few organizations; they are paged in memory
+		ids := make([]string, len(caller.Orgs))
+		for i, membership := range caller.Orgs {
+			ids[i] = membership.OrgID
+		}
+		orgs, err = s.orgRepo.FindByIDs(ctx, ids)
+		total = int64(len(orgs))
+		start := min((page-1)*limit, len(orgs))
+		orgs = orgs[start:min(start+limit, len(orgs))]
+	}
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	responses := make([]*models.OrganizationResponse, len(orgs))
+	for i, org := range orgs {
+		responses[i] = &models.OrganizationResponse{Organization: org, Role: caller.OrgRole(org.ID.Hex())}
+	}
+
+	// Calculate total pages
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+
+	return responses, totalPages, total, nil
+}
+
+// GetOrganization retrieves an organization the caller belongs to
+func (s *OrganizationService) GetOrganization(ctx context.Context, id string) (*models.OrganizationResponse, error) {
+	org, role, err := s.authorize(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OrganizationResponse{Organization: org, Role: role}, nil
+}
+
+// UpdateOrganization renames an organization; only its owners may
+func (s *OrganizationService) UpdateOrganization(ctx context.Context, id string, req *models.UpdateOrganizationRequest) (*models.Organization, error) {
+	org, _, err := s.authorize(ctx, id, models.OrgRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if len(name) < 2 || len(name) > 100 {
+		return nil, errors.New("name must be between 2 and 100 characters")
+	}
+
+	updated, err := s.orgRepo.UpdateName(ctx, org.ID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if updated.Name != org.Name {
+		s.auditService.Record(ctx, &models.AuditEvent{
+			Action:   models.AuditActionOrgUpdate,
+			TargetID: org.ID.Hex(),
+			OrgID:    org.ID.Hex(),
+			Success:  true,
+			Changes:  map[string]models.AuditChange{"name": {From: org.Name, To: updated.Name}},
+		})
+	}
+
+	return updated, nil
+}
+
+// ListMembers lists the members of an organization the caller belongs to.
+// Plain members see the public profiles of the others and may only search by name.
+func (s *OrganizationService) ListMembers(ctx context.Context, id string, filter models.UserFilter, page, limit int) ([]interface{}, int, int64, error) {
+	org, callerRole, err := s.authorize(ctx, id)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	full := middleware.IsSuperAdmin(ctx) || callerRole == models.OrgRoleOwner || callerRole == models.OrgRoleAdmin
+	if !full {
+		if filter.Role != "" || filter.IsActive != nil || filter.IncludeDeleted {
+			return nil, 0, 0, errors.New("insufficient organization role")
+		}
+		filter.SearchNameOnly = true
+	}
+
+	// Validate pagination parameters
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100 // Max limit
+	}
+
+	users, total, err := s.userRepo.FindByFilter(tenant.WithOrg(ctx, org.ID.Hex()), filter, page, limit)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	responses := make([]*models.UserResponse, len(users))
+	for i, user := range users {
+		responses[i] = user.ToUserResponse()
+	}
+
+	// Calculate total pages
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+
+	return models.ProjectUsers(responses, middleware.GetUserID(ctx), full), totalPages, total, nil
+}
+
+// AddMember adds an existing user, found by email, to an organization. The
+// lookup crosses organizations, so only super-admins may add users directly;
+// owners and admins invite new members instead.
+func (s *OrganizationService) AddMember(ctx context.Context, id string, req *models.AddMemberRequest) (*models.UserResponse, error) {
+	org, _, err := s.authorize(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !middleware.IsSuperAdmin(ctx) {
+		return nil, errors.New("only super-admins can add existing users; invite new members instead")
+	}
+
+	role := req.Role
+	if role == "" {
+		role = models.OrgRoleMember
+	}
+	if !models.IsValidOrgRole(role) {
+		return nil, errors.New("role must be owner, admin or member")
+	}
+
+	// Emails are unique across organizations, so the lookup crosses them
+	user, err := s.userRepo.FindByEmail(tenant.AcrossOrgs(ctx), req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.addMember(ctx, org, user.ID.Hex(), role)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated.ToUserResponse(), nil
+}
+
+// addMember adds a user to an organization and records it
+func (s *OrganizationService) addMember(ctx context.Context, org *models.Organization, userID, role string) (*models.User, error) {
+	user, err := s.userRepo.AddMembership(ctx, userID, models.OrgMembership{OrgID: org.ID.Hex(), Role: role})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionOrgMemberAdd,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		OrgID:       org.ID.Hex(),
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"orgRole": {From: nil, To: role}},
+	})
+
+	return user, nil
+}
+
+// UpdateMember changes a member's role. Owners and admins may change roles;
+// only owners may grant or revoke ownership, and the last owner stays.
+func (s *OrganizationService) UpdateMember(ctx context.Context, id, userID string, req *models.UpdateMemberRequest) (*models.UserResponse, error) {
+	org, callerRole, err := s.authorize(ctx, id, models.OrgRoleOwner, models.OrgRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if !models.IsValidOrgRole(req.Role) {
+		return nil, errors.New("role must be owner, admin or member")
+	}
+
+	current, err := s.memberRole(ctx, org, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current == req.Role {
+		user, err := s.userRepo.FindByID(tenant.WithOrg(ctx, org.ID.Hex()), userID)
+		if err != nil {
+			return nil, err
+		}
+		return user.ToUserResponse(), nil
+	}
+
+	if (current == models.OrgRoleOwner || req.Role == models.OrgRoleOwner) && !s.isOwner(ctx, callerRole) {
+		return nil, errors.New("only owners can manage owners")
+	}
+	if current == models.OrgRoleOwner {
+		if err := s.checkNotLastOwner(ctx, org); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := s.userRepo.SetMembershipRole(ctx, userID, org.ID.Hex(), req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionOrgMemberUpdate,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		OrgID:       org.ID.Hex(),
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"orgRole": {From: current, To: req.Role}},
+	})
+
+	return user.ToUserResponse(), nil
+}
+
+// RemoveMember removes a user from an organization that is not their home
+// organization. Owners and admins may remove members, only owners may remove
+// owners, and every member may leave.
+func (s *OrganizationService) RemoveMember(ctx context.Context, id, userID string) error {
+	leaving := userID == middleware.GetUserID(ctx)
+
+	var org *models.Organization
+	var callerRole string
+	var err error
+	if leaving {
+		org, callerRole, err = s.authorize(ctx, id)
+	} else {
+		org, callerRole, err = s.authorize(ctx, id, models.OrgRoleOwner, models.OrgRoleAdmin)
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := s.memberRole(ctx, org, userID)
+	if err != nil {
+		return err
+	}
+	if current == models.OrgRoleOwner {
+		if !leaving && !s.isOwner(ctx, callerRole) {
+			return errors.New("only owners can manage owners")
+		}
+		if err := s.checkNotLastOwner(ctx, org); err != nil {
+			return err
+		}
+	}
+
+	user, err := s.userRepo.RemoveMembership(ctx, userID, org.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	// The user leaves the organization's groups too
+	if err := s.groupMemberRepo.RemoveUserFromOrg(ctx, org.ID.Hex(), userID); err != nil {
+		log.Printf("⚠️  Failed to remove user %s from the groups of organization %s: %v", userID, org.ID.Hex(), err)
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionOrgMemberRemove,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		OrgID:       org.ID.Hex(),
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"orgRole": {From: current, To: nil}},
+	})
+
+	return nil
+}
+
+// SwitchOrganization checks that the caller may act in the organization and
+// returns them, so a token scoped to it can be issued
+func (s *OrganizationService) SwitchOrganization(ctx context.Context, orgID string) (*models.User, error) {
+	org, role, err := s.authorize(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, errors.New("account is deactivated")
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionOrgSwitch,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		OrgID:       org.ID.Hex(),
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"orgId": {From: tenant.OrgID(ctx), To: org.ID.Hex()}, "orgRole": {From: nil, To: role}},
+	})
+
+	return user, nil
+}
+
+// authorize loads an organization and checks that the caller belongs to it,
+// with one of roles when given. Super-admins always pass, with the role they
+// hold there, if any. Organizations the caller does not belong to are
+// reported as not found.
+func (s *OrganizationService) authorize(ctx context.Context, id string, roles ...string) (*models.Organization, string, error) {
+	org, err := s.orgRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	caller, err := s.caller(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	role := caller.OrgRole(org.ID.Hex())
+	if middleware.IsSuperAdmin(ctx) {
+		return org, role, nil
+	}
+	if role == "" {
+		return nil, "", errors.New("organization not found")
+	}
+	if len(roles) == 0 {
+		return org, role, nil
+	}
+	for _, allowed := range roles {
+		if role == allowed {
+			return org, role, nil
+		}
+	}
+
+	return nil, "", errors.New("insufficient organization role")
+}
+
+// caller loads the authenticated user, wherever their home organization is
+func (s *OrganizationService) caller(ctx context.Context) (*models.User, error) {
+	user, err := s.userRepo.FindByID(tenant.AcrossOrgs(ctx), middleware.GetUserID(ctx))
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// isOwner reports whether the caller may manage owners
+func (s *OrganizationService) isOwner(ctx context.Context, callerRole string) bool {
+	return callerRole == models.OrgRoleOwner || middleware.IsSuperAdmin(ctx)
+}
+
+// memberRole returns the role of a member of the organization
+func (s *OrganizationService) memberRole(ctx context.Context, org *models.Organization, userID string) (string, error) {
+	user, err := s.userRepo.FindByID(tenant.WithOrg(ctx, org.ID.Hex()), userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return "", errors.New("membership not found")
+		}
+		return "", err
+	}
+	return user.OrgRole(org.ID.Hex()), nil
+}
+
+// checkNotLastOwner fails when the organization has a single owner left
+func (s *OrganizationService) checkNotLastOwner(ctx context.Context, org *models.Organization) error {
+	owners, err := s.userRepo.CountOrgRole(ctx, org.ID.Hex(), models.OrgRoleOwner)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return errors.New("organization must keep at least one owner")
+	}
+	return nil
+}
+
+// slugify derives a slug from an organization name
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > 50 {
+		slug = strings.TrimSuffix(slug[:50], "-")
+	}
+	return slug
+}
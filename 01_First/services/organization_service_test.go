@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"user-management-system/models"
+)
+
+func TestOnlySuperAdminsAddExistingUsers(t *testing.T) {
+	env := newTestEnv(t)
+	superAdmin := env.createUser("root@example.com", "admin", models.OrgRoleMember)
+	stranger := env.createUser("stranger@example.com", "user", models.OrgRoleMember)
+
+	owner := env.createUser("owner@example.com", "user", models.OrgRoleMember)
+	org, err := env.orgService.CreateOrganization(env.callerContext(superAdmin), &models.CreateOrganizationRequest{Name: "Acme", OwnerEmail: owner.Email})
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+
+	// The owner of another organization cannot pull in users from elsewhere
+	ownerCtx := env.callerContextIn(env.reload(owner), org.ID.Hex())
+	_, err = env.orgService.AddMember(ownerCtx, org.ID.Hex(), &models.AddMemberRequest{Email: stranger.Email})
+	if err == nil || err.Error() != "only super-admins can add existing users; invite new members instead" {
+		t.Fatalf("owner adding an existing user: err = %v", err)
+	}
+	if env.reload(stranger).OrgRole(org.ID.Hex()) != "" {
+		t.Fatal("the owner added a user from another organization")
+	}
+
+	added, err := env.orgService.AddMember(env.callerContextIn(superAdmin, org.ID.Hex()), org.ID.Hex(), &models.AddMemberRequest{Email: stranger.Email, Role: models.OrgRoleAdmin})
+	if err != nil || added.Email != stranger.Email {
+		t.Fatalf("super-admin AddMember = %+v, %v", added, err)
+	}
+	if role := env.reload(stranger).OrgRole(org.ID.Hex()); role != models.OrgRoleAdmin {
+		t.Fatalf("added member role = %q, want admin", role)
+	}
+}
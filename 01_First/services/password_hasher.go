@@ -0,0 +1,177 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	PasswordAlgorithmBcrypt   = "bcrypt"
+	PasswordAlgorithmArgon2id = "argon2id"
+)
+
+// PasswordHasher hashes passwords into self-describing strings that carry
+// the algorithm and its parameters. Argon2id hashes use the PHC string format
+// ($argon2id$v=19$m=...,t=...,p=...$salt$hash); bcrypt keeps its own $2a$
+// format, which the PHC format grew out of.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of password with the hasher's parameters
+	Hash(password string) (string, error)
+	// Current reports whether encoded was made by this hasher with its current
+	// parameters; other hashes should be replaced on the next login
+	Current(encoded string) bool
+	// MaxPasswordBytes is the longest password the hasher takes into account
+	MaxPasswordBytes() int
+}
+
+// NewPasswordHasher returns the hasher new passwords are hashed with
+func NewPasswordHasher(algorithm string, bcryptCost int, argon2Memory, argon2Time uint32, argon2Threads uint8) (PasswordHasher, error) {
+	switch algorithm {
+	case PasswordAlgorithmBcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return BcryptHasher{Cost: bcryptCost}, nil
+	case PasswordAlgorithmArgon2id:
+		if argon2Memory < 8*uint32(argon2Threads) || argon2Time < 1 || argon2Threads < 1 {
+			return nil, errors.New("argon2id needs a time and threads of at least 1 and at least 8 KiB of memory per thread")
+		}
+		return Argon2idHasher{Memory: argon2Memory, Time: argon2Time, Threads: argon2Threads}, nil
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm %q", algorithm)
+	}
+}
+
+// verifyPassword checks password against an encoded hash of any supported
+// algorithm, whatever parameters it was made with
+func verifyPassword(encoded, password string) error {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return verifyArgon2id(encoded, password)
+	case strings.HasPrefix(encoded, "$2"):
+		return bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	default:
+		return errors.New("unknown password hash format")
+	}
+}
+
+// BcryptHasher hashes passwords with bcrypt at the given cost
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash returns the bcrypt hash of password
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Current reports whether encoded is a bcrypt hash of the hasher's cost
+func (h BcryptHasher) Current(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err == nil && cost == h.Cost
+}
+
+// MaxPasswordBytes returns 72: bcrypt ignores the rest of longer passwords
+func (h BcryptHasher) MaxPasswordBytes() int {
+	return bcryptMaxBytes
+}
+
+// argon2idSaltLength and argon2idKeyLength are the salt and hash sizes in
+// bytes. Argon2id hashes passwords of any length; argon2idMaxBytes only keeps
+// hashing cheap to request.
+const (
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+	argon2idMaxBytes   = 1024
+)
+
+// Argon2idHasher hashes passwords with Argon2id. Memory is in KiB.
+type Argon2idHasher struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+}
+
+// Hash returns the PHC-formatted Argon2id hash of password with a random salt
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, argon2idKeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Current reports whether encoded is an Argon2id hash with the hasher's parameters
+func (h Argon2idHasher) Current(encoded string) bool {
+	params, _, key, err := parseArgon2id(encoded)
+	return err == nil && params == h && len(key) == argon2idKeyLength
+}
+
+// MaxPasswordBytes returns the longest password accepted for Argon2id
+func (h Argon2idHasher) MaxPasswordBytes() int {
+	return argon2idMaxBytes
+}
+
+// verifyArgon2id checks password against a PHC-formatted Argon2id hash
+func verifyArgon2id(encoded, password string) error {
+	params, salt, key, err := parseArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return errors.New("password does not match")
+	}
+	return nil
+}
+
+// parseArgon2id splits a PHC-formatted Argon2id hash into its parameters, salt and key
+func parseArgon2id(encoded string) (Argon2idHasher, []byte, []byte, error) {
+	var params Argon2idHasher
+	invalid := errors.New("invalid argon2id hash")
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != PasswordAlgorithmArgon2id {
+		return params, nil, nil, invalid
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, invalid
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, invalid
+	}
+	if params.Time < 1 || params.Threads < 1 {
+		return params, nil, nil, invalid
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, invalid
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, invalid
+	}
+
+	return params, salt, key, nil
+}
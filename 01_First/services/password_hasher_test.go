@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"user-management-system/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id hashes with little memory to keep tests fast
+var testArgon2id = Argon2idHasher{Memory: 64, Time: 1, Threads: 1}
+
+func TestPasswordHashersRoundTrip(t *testing.T) {
+	for _, hasher := range []PasswordHasher{BcryptHasher{Cost: bcrypt.MinCost}, testArgon2id} {
+		encoded, err := hasher.Hash(testPassword)
+		if err != nil {
+			t.Fatalf("%T.Hash: %v", hasher, err)
+		}
+		if err := verifyPassword(encoded, testPassword); err != nil {
+			t.Errorf("%T: the password does not verify: %v", hasher, err)
+		}
+		if err := verifyPassword(encoded, "wrong password"); err == nil {
+			t.Errorf("%T: a wrong password verifies", hasher)
+		}
+		if !hasher.Current(encoded) {
+			t.Errorf("%T: its own hash %q is not current", hasher, encoded)
+		}
+	}
+
+	// Hashes with other parameters or another algorithm are due for a rehash
+	bcryptHash, _ := BcryptHasher{Cost: bcrypt.MinCost}.Hash(testPassword)
+	argon2Hash, _ := testArgon2id.Hash(testPassword)
+	if (BcryptHasher{Cost: bcrypt.MinCost + 1}).Current(bcryptHash) || testArgon2id.Current(bcryptHash) {
+		t.Error("a bcrypt hash of another cost is current")
+	}
+	if (Argon2idHasher{Memory: 128, Time: 1, Threads: 1}).Current(argon2Hash) || (BcryptHasher{Cost: bcrypt.MinCost}).Current(argon2Hash) {
+		t.Error("an argon2id hash with other parameters is current")
+	}
+	if !strings.HasPrefix(argon2Hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("argon2id hash = %q, want the PHC string format", argon2Hash)
+	}
+}
+
+func TestVerifyPasswordRejectsMalformedHashes(t *testing.T) {
+	for _, encoded := range []string{
+		"",
+		"plaintext",
+		"$argon2id$v=18$m=64,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA$",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5",
+	} {
+		if err := verifyPassword(encoded, testPassword); err == nil {
+			t.Errorf("verifyPassword(%q) succeeded", encoded)
+		}
+	}
+}
+
+func TestNewPasswordHasher(t *testing.T) {
+	if hasher, err := NewPasswordHasher(PasswordAlgorithmArgon2id, 0, 65536, 3, 2); err != nil || hasher != (Argon2idHasher{Memory: 65536, Time: 3, Threads: 2}) {
+		t.Fatalf("argon2id hasher = %v, %v", hasher, err)
+	}
+	for name, params := range map[string]struct {
+		algorithm    string
+		cost         int
+		memory, time uint32
+		threads      uint8
+	}{
+		"bcrypt cost too low":  {PasswordAlgorithmBcrypt, 3, 0, 0, 0},
+		"bcrypt cost too high": {PasswordAlgorithmBcrypt, 32, 0, 0, 0},
+		"argon2id no passes":   {PasswordAlgorithmArgon2id, 0, 65536, 0, 2},
+		"argon2id low memory":  {PasswordAlgorithmArgon2id, 0, 8, 1, 2},
+		"unknown":              {"md5", 0, 0, 0, 0},
+	} {
+		if _, err := NewPasswordHasher(params.algorithm, params.cost, params.memory, params.time, params.threads); err == nil {
+			t.Errorf("%s: NewPasswordHasher succeeded", name)
+		}
+	}
+}
+
+func TestLoginRehashesWithCurrentHasher(t *testing.T) {
+	env := newTestEnv(t)
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	upgraded := NewUserService(env.userRepo, env.auditService, env.webhookService, nil, env.policy, testArgon2id, env.defaultOrg.ID.Hex())
+	if _, err := upgraded.Login(systemContext(), &models.LoginRequest{Email: jane.Email, Password: testPassword}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if hash := env.reload(jane).Password; !testArgon2id.Current(hash) {
+		t.Fatalf("password hash after login = %q, want it rehashed with argon2id", hash)
+	}
+	if _, err := upgraded.Login(systemContext(), &models.LoginRequest{Email: jane.Email, Password: testPassword}); err != nil {
+		t.Fatalf("Login with the rehashed password: %v", err)
+	}
+}
+
+// BenchmarkBcrypt times bcrypt at the costs worth considering. Pick the
+// highest BCRYPT_COST that stays within the login latency you aim for, on
+// the hardware the server runs on:
+//
+//	go test ./services -run '^$' -bench Bcrypt
+func BenchmarkBcrypt(b *testing.B) {
+	for cost := 10; cost <= 14; cost++ {
+		hasher := BcryptHasher{Cost: cost}
+		b.Run(fmt.Sprintf("cost=%d", cost), func(b *testing.B) {
+			benchmarkHasher(b, hasher)
+		})
+	}
+}
+
+// BenchmarkArgon2id times Argon2id at doubling memory sizes with 3 passes
+// over 2 threads, the defaults. Pick ARGON2_MEMORY_KIB the same way as the
+// bcrypt cost:
+//
+//	go test ./services -run '^$' -bench Argon2id
+func BenchmarkArgon2id(b *testing.B) {
+	for memoryMiB := uint32(16); memoryMiB <= 256; memoryMiB *= 2 {
+		hasher := Argon2idHasher{Memory: memoryMiB * 1024, Time: 3, Threads: 2}
+		b.Run(fmt.Sprintf("memory=%dMiB", memoryMiB), func(b *testing.B) {
+			benchmarkHasher(b, hasher)
+		})
+	}
+}
+
+func benchmarkHasher(b *testing.B, hasher PasswordHasher) {
+	for i := 0; i < b.N; i++ {
+		if _, err := hasher.Hash(testPassword); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
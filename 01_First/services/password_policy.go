@@ -0,0 +1,328 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"user-management-system/config"
+	"user-management-system/models"
+)
+
+// bcryptMaxBytes is the longest password bcrypt hashes; it ignores the rest
+const bcryptMaxBytes = 72
+
+// PasswordPolicy decides which passwords users may choose. It applies
+// wherever a password is set: registration, account creation, updates,
+// password changes, password-set links, invitations, SCIM and imports.
+type PasswordPolicy struct {
+	MinLength        int // in characters
+	MaxLength        int // in bytes, at most HasherMaxBytes
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+
+	// HasherMaxBytes is the active hasher's MaxPasswordBytes, which caps
+	// MaxLength; 0 caps it at bcrypt's 72 bytes
+	HasherMaxBytes int
+
+	// Breached lists known breached or common passwords; nil skips the check
+	Breached *BreachedPasswords
+
+	// HistorySize is how many of the latest passwords, the current one
+	// included, a new password may not repeat (0 allows reuse)
+	HistorySize int
+
+	// MaxAge and AdminMaxAge are how long a password stays valid on accounts
+	// without and with an admin role (0 never expires)
+	MaxAge      time.Duration
+	AdminMaxAge time.Duration
+}
+
+// NewPasswordPolicyFromConfig builds the policy of the PASSWORD_* settings of
+// cfg for passwords hashed with hasher, loading the breached password list
+// when one is configured. The API and userctl both use it, so passwords set
+// through either follow the same rules.
+func NewPasswordPolicyFromConfig(cfg *config.Config, hasher PasswordHasher) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		MinLength:        cfg.PasswordMinLength,
+		MaxLength:        cfg.PasswordMaxLength,
+		HasherMaxBytes:   hasher.MaxPasswordBytes(),
+		RequireUppercase: cfg.PasswordRequireUppercase,
+		RequireLowercase: cfg.PasswordRequireLowercase,
+		RequireDigit:     cfg.PasswordRequireDigit,
+		RequireSymbol:    cfg.PasswordRequireSymbol,
+		HistorySize:      cfg.PasswordHistorySize,
+		MaxAge:           cfg.PasswordMaxAge,
+		AdminMaxAge:      cfg.AdminPasswordMaxAge,
+	}
+
+	if cfg.PasswordBreachedFile != "" {
+		breached, err := LoadBreachedPasswords(cfg.PasswordBreachedFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = breached
+	}
+
+	return policy, nil
+}
+
+// Check returns a *models.PasswordPolicyError listing every rule password
+// breaks, or nil. name and email are the account's, which the password may
+// not contain; either may be empty.
+func (p *PasswordPolicy) Check(password, name, email string) error {
+	return violationsError(p.violations(password, name, email))
+}
+
+// CheckChange is Check for a new password of an existing user, which also
+// may not repeat one of the user's latest passwords
+func (p *PasswordPolicy) CheckChange(password string, user *models.User) error {
+	violations := p.violations(password, user.Name, user.Email)
+	if p.reused(password, user) {
+		violations = append(violations, models.PasswordViolation{
+			Code:    models.PasswordViolationReused,
+			Message: fmt.Sprintf("password must not be one of your last %d passwords", p.HistorySize),
+		})
+	}
+	return violationsError(violations)
+}
+
+// Expired reports whether the user's password is older than the maximum age
+// for the account. Passwords set before their change time was recorded count
+// from the account's creation.
+func (p *PasswordPolicy) Expired(user *models.User, now time.Time) bool {
+	maxAge := p.MaxAge
+	if user.HasAdminRole() {
+		maxAge = p.AdminMaxAge
+	}
+	if maxAge <= 0 {
+		return false
+	}
+
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	return now.Sub(changedAt) > maxAge
+}
+
+// reused reports whether password matches the user's current password or one
+// of the previous ones the history covers
+func (p *PasswordPolicy) reused(password string, user *models.User) bool {
+	if p.HistorySize <= 0 {
+		return false
+	}
+
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > p.HistorySize {
+		hashes = hashes[:p.HistorySize]
+	}
+	for _, hash := range hashes {
+		if hash != "" && verifyPassword(hash, password) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// history returns the previous password hashes to keep once the user's
+// current password is replaced. Together with the new password they cover
+// the latest HistorySize passwords.
+func (p *PasswordPolicy) history(user *models.User) []string {
+	if p.HistorySize <= 1 {
+		return []string{}
+	}
+
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > p.HistorySize-1 {
+		hashes = hashes[:p.HistorySize-1]
+	}
+	return hashes
+}
+
+// violationsError wraps violations in a *models.PasswordPolicyError, or returns nil
+func violationsError(violations []models.PasswordViolation) error {
+	if len(violations) > 0 {
+		return &models.PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// passwordFields checks a new password of user against policy and returns
+// the fields that store it: the hash, the change time and the history. The
+// password is no longer a random one nobody knows.
+func passwordFields(policy *PasswordPolicy, hasher PasswordHasher, user *models.User, password string) (map[string]interface{}, error) {
+	if err := policy.CheckChange(password, user); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		return nil, errors.New("failed to hash password")
+	}
+
+	return map[string]interface{}{
+		"password":          hashedPassword,
+		"passwordChangedAt": time.Now(),
+		"passwordHistory":   policy.history(user),
+		"randomPassword":    false,
+	}, nil
+}
+
+// violations lists every rule password breaks
+func (p *PasswordPolicy) violations(password, name, email string) []models.PasswordViolation {
+	var violations []models.PasswordViolation
+	violate := func(code, message string) {
+		violations = append(violations, models.PasswordViolation{Code: code, Message: message})
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violate(models.PasswordViolationTooShort, fmt.Sprintf("password must be at least %d characters", p.MinLength))
+	}
+	if maxLength := p.maxLength(); len(password) > maxLength {
+		violate(models.PasswordViolationTooLong, fmt.Sprintf("password must be at most %d bytes", maxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.RequireUppercase && !upper {
+		violate(models.PasswordViolationMissingUppercase, "password must contain an uppercase letter")
+	}
+	if p.RequireLowercase && !lower {
+		violate(models.PasswordViolationMissingLowercase, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violate(models.PasswordViolationMissingDigit, "password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violate(models.PasswordViolationMissingSymbol, "password must contain a symbol")
+	}
+
+	folded := strings.ToLower(password)
+	for _, part := range strings.Fields(strings.ToLower(name)) {
+		if utf8.RuneCountInString(part) >= 3 && strings.Contains(folded, part) {
+			violate(models.PasswordViolationContainsName, "password must not contain your name")
+			break
+		}
+	}
+	if local, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@"); utf8.RuneCountInString(local) >= 3 && strings.Contains(folded, local) {
+		violate(models.PasswordViolationContainsEmail, "password must not contain your email address")
+	}
+
+	if p.Breached.Contains(password) {
+		violate(models.PasswordViolationBreached, "password must not be a known breached or common password")
+	}
+
+	return violations
+}
+
+// maxLength is the configured maximum, capped at what the hasher can hash
+func (p *PasswordPolicy) maxLength() int {
+	limit := p.HasherMaxBytes
+	if limit <= 0 {
+		limit = bcryptMaxBytes
+	}
+	if p.MaxLength <= 0 || p.MaxLength > limit {
+		return limit
+	}
+	return p.MaxLength
+}
+
+// breachedPrefixLength is the length of the SHA-1 hex prefix hashes are
+// bucketed by, as in the k-anonymity range API of Have I Been Pwned
+const breachedPrefixLength = 5
+
+// BreachedPasswords is a set of SHA-1 hashes of breached or common
+// passwords, bucketed by hash prefix. Passwords are looked up by hash only.
+type BreachedPasswords struct {
+	buckets map[string][]string // prefix -> sorted suffixes
+	count   int
+}
+
+// LoadBreachedPasswords reads a file with one uppercase or lowercase SHA-1
+// hex hash per line, optionally followed by ":count" as in the Have I Been
+// Pwned downloads. Blank lines and lines starting with # are skipped.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	breached := &BreachedPasswords{buckets: make(map[string][]string)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: expected a SHA-1 hex hash", path, line)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("%s:%d: expected a SHA-1 hex hash", path, line)
+		}
+
+		prefix := hash[:breachedPrefixLength]
+		breached.buckets[prefix] = append(breached.buckets[prefix], hash[breachedPrefixLength:])
+		breached.count++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if breached.count == 0 {
+		return nil, errors.New(path + ": no password hashes found")
+	}
+
+	for _, suffixes := range breached.buckets {
+		sort.Strings(suffixes)
+	}
+
+	return breached, nil
+}
+
+// Len returns the number of hashes loaded
+func (b *BreachedPasswords) Len() int {
+	if b == nil {
+		return 0
+	}
+	return b.count
+}
+
+// Contains reports whether password is in the set; a nil set contains nothing
+func (b *BreachedPasswords) Contains(password string) bool {
+	if b == nil {
+		return false
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes := b.buckets[hash[:breachedPrefixLength]]
+	i := sort.SearchStrings(suffixes, hash[breachedPrefixLength:])
+	return i < len(suffixes) && suffixes[i] == hash[breachedPrefixLength:]
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"user-management-system/config"
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// violationCodes returns the codes of the violations err lists
+func violationCodes(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	policyErr, ok := err.(*models.PasswordPolicyError)
+	if !ok {
+		t.Fatalf("err = %v, want a *models.PasswordPolicyError", err)
+	}
+	codes := make([]string, len(policyErr.Violations))
+	for i, violation := range policyErr.Violations {
+		codes[i] = violation.Code
+	}
+	return codes
+}
+
+func TestPasswordMaxLengthFollowsHasher(t *testing.T) {
+	long := strings.Repeat("x", 100)
+
+	for name, test := range map[string]struct {
+		policy  PasswordPolicy
+		allowed string
+		refused string
+	}{
+		"bcrypt default":      {PasswordPolicy{}, strings.Repeat("x", 72), strings.Repeat("x", 73)},
+		"bcrypt caps config":  {PasswordPolicy{MaxLength: 200, HasherMaxBytes: BcryptHasher{}.MaxPasswordBytes()}, strings.Repeat("x", 72), strings.Repeat("x", 73)},
+		"argon2id raises cap": {PasswordPolicy{MaxLength: 200, HasherMaxBytes: Argon2idHasher{}.MaxPasswordBytes()}, long, strings.Repeat("x", 201)},
+		"argon2id caps":       {PasswordPolicy{MaxLength: 5000, HasherMaxBytes: Argon2idHasher{}.MaxPasswordBytes()}, strings.Repeat("x", 1024), strings.Repeat("x", 1025)},
+		"lower config":        {PasswordPolicy{MaxLength: 20, HasherMaxBytes: Argon2idHasher{}.MaxPasswordBytes()}, strings.Repeat("x", 20), strings.Repeat("x", 21)},
+	} {
+		if err := test.policy.Check(test.allowed, "", ""); err != nil {
+			t.Errorf("%s: %d bytes refused: %v", name, len(test.allowed), err)
+		}
+		if codes := violationCodes(t, test.policy.Check(test.refused, "", "")); len(codes) != 1 || codes[0] != models.PasswordViolationTooLong {
+			t.Errorf("%s: %d bytes = %v, want too_long", name, len(test.refused), codes)
+		}
+	}
+}
+
+func TestPasswordPolicyListsEveryViolation(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 12, RequireUppercase: true, RequireDigit: true, RequireSymbol: true}
+
+	codes := violationCodes(t, policy.Check("janedoe", "Jane Doe", "jane@example.com"))
+	want := []string{
+		models.PasswordViolationTooShort,
+		models.PasswordViolationMissingUppercase,
+		models.PasswordViolationMissingDigit,
+		models.PasswordViolationMissingSymbol,
+		models.PasswordViolationContainsName,
+		models.PasswordViolationContainsEmail,
+	}
+	if strings.Join(codes, ",") != strings.Join(want, ",") {
+		t.Fatalf("violations = %v, want %v", codes, want)
+	}
+
+	if err := policy.Check("Correct-Horse-42", "Jane Doe", "jane@example.com"); err != nil {
+		t.Fatalf("a compliant password was refused: %v", err)
+	}
+}
+
+func TestPasswordHistoryBlocksReuse(t *testing.T) {
+	env := newTestEnv(t)
+	env.policy.HistorySize = 3
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+	ctx := env.callerContext(jane)
+
+	change := func(current, next string) error {
+		_, err := env.userService.ChangeOwnPassword(ctx, &models.ChangeOwnPasswordRequest{CurrentPassword: current, NewPassword: next})
+		return err
+	}
+	if err := change(testPassword, "second password"); err != nil {
+		t.Fatalf("first change: %v", err)
+	}
+	if err := change("second password", "third password"); err != nil {
+		t.Fatalf("second change: %v", err)
+	}
+
+	// The original password is the third latest, so it is still covered
+	if codes := violationCodes(t, change("third password", testPassword)); len(codes) != 1 || codes[0] != models.PasswordViolationReused {
+		t.Fatalf("reusing the first password = %v, want reused", codes)
+	}
+	if err := change("third password", "fourth password"); err != nil {
+		t.Fatalf("third change: %v", err)
+	}
+	if history := env.reload(jane).PasswordHistory; len(history) != 2 {
+		t.Fatalf("history keeps %d hashes, want 2", len(history))
+	}
+
+	// Now it has dropped out of the history
+	if err := change("fourth password", testPassword); err != nil {
+		t.Fatalf("reusing a password older than the history: %v", err)
+	}
+
+	// Admin resets are checked against the history too
+	admin := env.createUser("admin@example.com", "admin", models.OrgRoleOwner)
+	_, err := env.userService.UpdateUser(env.callerContext(admin), jane.ID.Hex(), &models.UpdateUserRequest{Password: "fourth password"})
+	if codes := violationCodes(t, err); len(codes) != 1 || codes[0] != models.PasswordViolationReused {
+		t.Fatalf("admin reset to a recent password = %v, want reused", codes)
+	}
+}
+
+func TestPasswordExpired(t *testing.T) {
+	now := time.Now()
+	changed := now.Add(-10 * 24 * time.Hour)
+	policy := &PasswordPolicy{MaxAge: 30 * 24 * time.Hour, AdminMaxAge: 7 * 24 * time.Hour}
+
+	member := &models.User{CreatedAt: now.Add(-100 * 24 * time.Hour), PasswordChangedAt: &changed}
+	admin := &models.User{Role: "admin", CreatedAt: member.CreatedAt, PasswordChangedAt: &changed}
+	orgAdmin := &models.User{Orgs: []models.OrgMembership{{OrgID: "org", Role: models.OrgRoleAdmin}}, CreatedAt: member.CreatedAt, PasswordChangedAt: &changed}
+	legacy := &models.User{CreatedAt: member.CreatedAt}
+
+	for name, test := range map[string]struct {
+		policy *PasswordPolicy
+		user   *models.User
+		want   bool
+	}{
+		"member within max age":   {policy, member, false},
+		"super-admin past admin":  {policy, admin, true},
+		"org admin past admin":    {policy, orgAdmin, true},
+		"legacy counts from join": {policy, legacy, true},
+		"no max age":              {&PasswordPolicy{}, legacy, false},
+	} {
+		if got := test.policy.Expired(test.user, now); got != test.want {
+			t.Errorf("%s: Expired = %v, want %v", name, got, test.want)
+		}
+	}
+}
+
+func TestExpiredPasswordBlocksLoginUntilChanged(t *testing.T) {
+	env := newTestEnv(t)
+	env.policy.MaxAge = 24 * time.Hour
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	_, err := env.db.Collection("users").UpdateByID(context.Background(), jane.ID, bson.M{"$set": bson.M{"passwordChangedAt": time.Now().Add(-48 * time.Hour)}})
+	if err != nil {
+		t.Fatalf("backdating the password: %v", err)
+	}
+
+	login := &models.LoginRequest{Email: jane.Email, Password: testPassword}
+	if _, err := env.userService.Login(systemContext(), login); err == nil || err.Error() != "password expired" {
+		t.Fatalf("login with an expired password: err = %v", err)
+	}
+
+	if _, err := env.userService.ChangePassword(systemContext(), &models.ChangePasswordRequest{Email: jane.Email, CurrentPassword: testPassword, NewPassword: "a fresh password"}); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if _, err := env.userService.Login(systemContext(), &models.LoginRequest{Email: jane.Email, Password: "a fresh password"}); err != nil {
+		t.Fatalf("login after changing the expired password: %v", err)
+	}
+}
+
+func TestPasswordPolicyFromConfig(t *testing.T) {
+	cfg := &config.Config{
+		PasswordMinLength:    10,
+		PasswordMaxLength:    200,
+		PasswordRequireDigit: true,
+		PasswordHistorySize:  5,
+		PasswordMaxAge:       90 * 24 * time.Hour,
+		AdminPasswordMaxAge:  30 * 24 * time.Hour,
+	}
+
+	policy, err := NewPasswordPolicyFromConfig(cfg, BcryptHasher{})
+	if err != nil {
+		t.Fatalf("NewPasswordPolicyFromConfig: %v", err)
+	}
+	if policy.MinLength != 10 || !policy.RequireDigit || policy.HistorySize != 5 || policy.MaxAge != cfg.PasswordMaxAge || policy.AdminMaxAge != cfg.AdminPasswordMaxAge || policy.Breached != nil {
+		t.Fatalf("policy = %+v", policy)
+	}
+	// The hasher caps the configured maximum length
+	if codes := violationCodes(t, policy.Check(strings.Repeat("x1", 40), "", "")); len(codes) != 1 || codes[0] != models.PasswordViolationTooLong {
+		t.Fatalf("80 bytes with bcrypt = %v, want too_long", codes)
+	}
+
+	breachedFile := filepath.Join(t.TempDir(), "breached.txt")
+	sum := sha1.Sum([]byte("password123"))
+	if err := os.WriteFile(breachedFile, []byte(hex.EncodeToString(sum[:])+":42\n"), 0o600); err != nil {
+		t.Fatalf("writing the breached file: %v", err)
+	}
+	cfg.PasswordBreachedFile = breachedFile
+	policy, err = NewPasswordPolicyFromConfig(cfg, BcryptHasher{})
+	if err != nil {
+		t.Fatalf("NewPasswordPolicyFromConfig with a breached file: %v", err)
+	}
+	if policy.Breached == nil || policy.Breached.Len() != 1 {
+		t.Fatalf("breached list = %+v", policy.Breached)
+	}
+
+	cfg.PasswordBreachedFile = filepath.Join(t.TempDir(), "missing.txt")
+	if _, err := NewPasswordPolicyFromConfig(cfg, BcryptHasher{}); err == nil {
+		t.Fatal("NewPasswordPolicyFromConfig accepted a missing breached file")
+	}
+}
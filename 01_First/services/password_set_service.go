@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"user-management-system/models"
+	"user-management-system/repositories"
+)
+
+// PasswordSetService issues single-use links that let a user choose their own
+// password, so accounts can be created without handling plaintext passwords
+type PasswordSetService struct {
+	userRepo       *repositories.UserRepository
+	tokenRepo      *repositories.PasswordTokenRepository
+	passwordPolicy *PasswordPolicy
+	passwordHasher PasswordHasher
+	mailer         Mailer
+	auditService   *AuditService
+	setURL         string
+	ttl            time.Duration
+}
+
+// NewPasswordSetService creates a new password-set service. setURL is the page
+// that receives the token as ?token=
+func NewPasswordSetService(userRepo *repositories.UserRepository, tokenRepo *repositories.PasswordTokenRepository, passwordPolicy *PasswordPolicy, passwordHasher PasswordHasher, mailer Mailer, auditService *AuditService, setURL string, ttl time.Duration) *PasswordSetService {
+	return &PasswordSetService{
+		userRepo:       userRepo,
+		tokenRepo:      tokenRepo,
+		passwordPolicy: passwordPolicy,
+		passwordHasher: passwordHasher,
+		mailer:         mailer,
+		auditService:   auditService,
+		setURL:         setURL,
+		ttl:            ttl,
+	}
+}
+
+// SendLink issues a password-set token for the user and mails the link.
+// Earlier links of the user stop working.
+func (s *PasswordSetService) SendLink(ctx context.Context, user *models.User) error {
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.tokenRepo.Create(ctx, &models.PasswordToken{
+		TokenHash: hashToken(token),
+		UserID:    user.ID.Hex(),
+		Purpose:   models.PasswordTokenSet,
+		ExpiresAt: time.Now().Add(s.ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.setURL + "?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(
+		"Hello %s,\n\nAn account has been created for you. Choose your password here:\n\n%s\n\nThe link expires in %s and can be used once.\n",
+		user.Name, link, s.ttl,
+	)
+
+	return s.mailer.Send(ctx, user.Email, "Set your password", body)
+}
+
+// SetPassword consumes a password-set token and sets the user's password.
+// The token is only consumed once the password passes the policy, so a
+// rejected password can be retried with the same link.
+func (s *PasswordSetService) SetPassword(ctx context.Context, req *models.SetPasswordRequest) (*models.User, error) {
+	if req.Token == "" {
+		return nil, errors.New("invalid or expired token")
+	}
+	if req.Password == "" {
+		return nil, errors.New("password is required")
+	}
+
+	token, err := s.tokenRepo.Find(ctx, hashToken(req.Token), models.PasswordTokenSet)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, token.UserID)
+	if err != nil {
+		return nil, errors.New("invalid or expired token")
+	}
+	if !user.IsActive {
+		return nil, errors.New("account is deactivated")
+	}
+	updateData, err := passwordFields(s.passwordPolicy, s.passwordHasher, user, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.tokenRepo.Consume(ctx, token.TokenHash, models.PasswordTokenSet); err != nil {
+		return nil, err
+	}
+
+	updateData["mustChangePassword"] = false
+	if err := s.userRepo.Update(ctx, token.UserID, updateData); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionPasswordSet,
+		ActorID:     user.ID.Hex(),
+		ActorEmail:  user.Email,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"password": {From: "[redacted]", To: "[redacted]"}},
+	})
+
+	return s.userRepo.FindByID(ctx, token.UserID)
+}
+
+// generateToken returns a random 32-byte URL-safe token
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken returns the hex SHA-256 of a token, which is what gets stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
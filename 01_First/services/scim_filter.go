@@ -0,0 +1,237 @@
+package services
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// scimAttributeFields maps filterable SCIM attributes to User fields
+var scimAttributeFields = map[string]string{
+	"username":       "email",
+	"emails":         "email",
+	"emails.value":   "email",
+	"active":         "isActive",
+	"displayname":    "name",
+	"name.formatted": "name",
+}
+
+// parseSCIMFilter converts a SCIM filter expression into a MongoDB query.
+// Supported: eq, sw and co on userName, emails, displayName and name.formatted,
+// eq on active, combined with and, or and parentheses.
+func parseSCIMFilter(filter string) (bson.M, error) {
+	if strings.TrimSpace(filter) == "" {
+		return bson.M{}, nil
+	}
+
+	tokens, err := tokenizeSCIMFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &scimFilterParser{tokens: tokens}
+	query, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, invalidSCIMFilter("unexpected token " + p.tokens[p.pos].value)
+	}
+
+	return query, nil
+}
+
+// scimToken is a lexical token of a SCIM filter
+type scimToken struct {
+	value  string
+	quoted bool
+}
+
+// tokenizeSCIMFilter splits a filter into words, quoted strings and parentheses
+func tokenizeSCIMFilter(filter string) ([]scimToken, error) {
+	var tokens []scimToken
+	for i := 0; i < len(filter); {
+		switch c := filter[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, scimToken{value: string(c)})
+			i++
+		case c == '"':
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(filter) {
+				if filter[i] == '\\' && i+1 < len(filter) {
+					sb.WriteByte(filter[i+1])
+					i += 2
+					continue
+				}
+				if filter[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(filter[i])
+				i++
+			}
+			if !closed {
+				return nil, invalidSCIMFilter("unterminated string")
+			}
+			tokens = append(tokens, scimToken{value: sb.String(), quoted: true})
+		default:
+			start := i
+			for i < len(filter) && filter[i] != ' ' && filter[i] != '\t' && filter[i] != '(' && filter[i] != ')' {
+				i++
+			}
+			tokens = append(tokens, scimToken{value: filter[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+// scimFilterParser is a recursive descent parser over filter tokens
+type scimFilterParser struct {
+	tokens []scimToken
+	pos    int
+}
+
+func (p *scimFilterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].value, keyword)
+}
+
+func (p *scimFilterParser) next() (scimToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return scimToken{}, false
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token, true
+}
+
+func (p *scimFilterParser) parseOr() (bson.M, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	clauses := bson.A{left}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, right)
+	}
+
+	if len(clauses) == 1 {
+		return left, nil
+	}
+	return bson.M{"$or": clauses}, nil
+}
+
+func (p *scimFilterParser) parseAnd() (bson.M, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	clauses := bson.A{left}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, right)
+	}
+
+	if len(clauses) == 1 {
+		return left, nil
+	}
+	return bson.M{"$and": clauses}, nil
+}
+
+func (p *scimFilterParser) parseTerm() (bson.M, error) {
+	if p.peekKeyword("(") {
+		p.pos++
+		query, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekKeyword(")") {
+			return nil, invalidSCIMFilter("missing closing parenthesis")
+		}
+		p.pos++
+		return query, nil
+	}
+
+	attr, ok := p.next()
+	if !ok {
+		return nil, invalidSCIMFilter("expected attribute")
+	}
+	op, ok := p.next()
+	if !ok {
+		return nil, invalidSCIMFilter("expected operator after " + attr.value)
+	}
+	value, ok := p.next()
+	if !ok {
+		return nil, invalidSCIMFilter("expected value after " + op.value)
+	}
+
+	field, known := scimAttributeFields[strings.ToLower(attr.value)]
+	if !known {
+		return nil, invalidSCIMFilter("unsupported attribute " + attr.value)
+	}
+
+	operator := strings.ToLower(op.value)
+
+	if field == "isActive" {
+		if operator != "eq" || value.quoted {
+			return nil, invalidSCIMFilter("active only supports eq true or eq false")
+		}
+		switch strings.ToLower(value.value) {
+		case "true":
+			return bson.M{"isActive": true}, nil
+		case "false":
+			return bson.M{"isActive": false}, nil
+		default:
+			return nil, invalidSCIMFilter("active must be compared to true or false")
+		}
+	}
+
+	if !value.quoted {
+		return nil, invalidSCIMFilter(attr.value + " must be compared to a quoted string")
+	}
+
+	// Emails are stored lowercase; names are matched case-insensitively
+	literal := value.value
+	if field == "email" {
+		literal = strings.ToLower(literal)
+	}
+
+	switch operator {
+	case "eq":
+		if field == "email" {
+			return bson.M{field: literal}, nil
+		}
+		return bson.M{field: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(literal) + "$", Options: "i"}}, nil
+	case "sw":
+		return bson.M{field: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(literal), Options: "i"}}, nil
+	case "co":
+		return bson.M{field: primitive.Regex{Pattern: regexp.QuoteMeta(literal), Options: "i"}}, nil
+	default:
+		return nil, invalidSCIMFilter("unsupported operator " + op.value)
+	}
+}
+
+// invalidSCIMFilter returns a SCIM invalidFilter error
+func invalidSCIMFilter(detail string) error {
+	return models.NewSCIMError(http.StatusBadRequest, "invalidFilter", detail)
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"user-management-system/models"
+	"user-management-system/repositories"
+)
+
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+)
+
+// SCIMService maps SCIM 2.0 provisioning requests onto users. Mutations go
+// through UserService so they are audited and announced like any other change.
+type SCIMService struct {
+	userService *UserService
+	userRepo    *repositories.UserRepository
+}
+
+// NewSCIMService creates a new SCIM service
+func NewSCIMService(userService *UserService, userRepo *repositories.UserRepository) *SCIMService {
+	return &SCIMService{
+		userService: userService,
+		userRepo:    userRepo,
+	}
+}
+
+// CreateUser provisions a user from a SCIM resource. A random password is
+// assigned when the IdP does not send one.
+func (s *SCIMService) CreateUser(ctx context.Context, resource *models.SCIMUser) (*models.User, error) {
+	email := scimEmail(resource)
+	if email == "" {
+		return nil, models.NewSCIMError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	role, err := scimRole(resource.Roles, "user")
+	if err != nil {
+		return nil, err
+	}
+
+	password := resource.Password
+	if password == "" {
+		password, err = generateRandomPassword()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	isActive := true
+	if resource.Active != nil {
+		isActive = *resource.Active
+	}
+
+	created, err := s.userService.CreateUser(ctx, &models.CreateUserRequest{
+		Name:     scimDisplayName(resource, email),
+		Email:    email,
+		Password: password,
+		Role:     role,
+		IsActive: &isActive,
+	})
+	if err != nil {
+		return nil, mapSCIMError(err)
+	}
+
+	return s.GetUser(ctx, created.ID)
+}
+
+// GetUser retrieves a user by ID
+func (s *SCIMService) GetUser(ctx context.Context, id string) (*models.User, error) {
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, mapSCIMError(err)
+	}
+	return user, nil
+}
+
+// ListUsers retrieves users matching a SCIM filter using 1-based startIndex paging
+func (s *SCIMService) ListUsers(ctx context.Context, filter string, startIndex, count int) ([]*models.User, int64, error) {
+	query, err := parseSCIMFilter(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > scimMaxCount {
+		count = scimMaxCount
+	}
+
+	// count=0 asks for totalResults only
+	if count == 0 {
+		_, total, err := s.userRepo.FindByQuery(ctx, query, int64(startIndex-1), 1)
+		return []*models.User{}, total, err
+	}
+
+	return s.userRepo.FindByQuery(ctx, query, int64(startIndex-1), int64(count))
+}
+
+// ReplaceUser applies a SCIM PUT. Roles are only changed when the IdP sends them.
+func (s *SCIMService) ReplaceUser(ctx context.Context, id string, resource *models.SCIMUser) (*models.User, error) {
+	existing, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	email := scimEmail(resource)
+	if email == "" {
+		return nil, models.NewSCIMError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	role, err := scimRole(resource.Roles, existing.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	isActive := true
+	if resource.Active != nil {
+		isActive = *resource.Active
+	}
+
+	req := &models.UpdateUserRequest{
+		Name:     scimDisplayName(resource, email),
+		Email:    email,
+		Password: resource.Password,
+		Role:     role,
+		IsActive: &isActive,
+	}
+
+	return s.update(ctx, id, req)
+}
+
+// PatchUser applies SCIM PATCH operations on active, userName, emails, displayName, name and roles
+func (s *SCIMService) PatchUser(ctx context.Context, id string, patch *models.SCIMPatchRequest) (*models.User, error) {
+	if _, err := s.GetUser(ctx, id); err != nil {
+		return nil, err
+	}
+
+	if len(patch.Operations) == 0 {
+		return nil, models.NewSCIMError(http.StatusBadRequest, "invalidValue", "at least one operation is required")
+	}
+
+	req := &models.UpdateUserRequest{}
+	for _, op := range patch.Operations {
+		if err := applySCIMPatchOperation(req, op); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.update(ctx, id, req)
+}
+
+// DeleteUser deprovisions (soft-deletes) a user
+func (s *SCIMService) DeleteUser(ctx context.Context, id string) error {
+	return mapSCIMError(s.userService.DeleteUser(ctx, id))
+}
+
+// update applies the update through UserService and reloads the user
+func (s *SCIMService) update(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error) {
+	if _, err := s.userService.UpdateUser(ctx, id, req); err != nil {
+		return nil, mapSCIMError(err)
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+// applySCIMPatchOperation folds one PATCH operation into an update request
+func applySCIMPatchOperation(req *models.UpdateUserRequest, op models.SCIMPatchOperation) error {
+	operation := strings.ToLower(op.Op)
+	path := strings.ToLower(strings.TrimSpace(op.Path))
+
+	switch operation {
+	case "add", "replace":
+	case "remove":
+		// Removing roles demotes to the default role; other attributes are required
+		if strings.HasPrefix(path, "roles") {
+			req.Role = "user"
+			return nil
+		}
+		return models.NewSCIMError(http.StatusBadRequest, "mutability", "cannot remove "+op.Path)
+	default:
+		return models.NewSCIMError(http.StatusBadRequest, "invalidSyntax", "unsupported op "+op.Op)
+	}
+
+	// Without a path the value is a partial resource
+	if path == "" {
+		attrs, ok := op.Value.(map[string]interface{})
+		if !ok {
+			return models.NewSCIMError(http.StatusBadRequest, "invalidValue", "value must be an object when path is omitted")
+		}
+		for key, value := range attrs {
+			if err := applySCIMPatchOperation(req, models.SCIMPatchOperation{Op: op.Op, Path: key, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch {
+	case path == "active":
+		active, ok := scimBool(op.Value)
+		if !ok {
+			return models.NewSCIMError(http.StatusBadRequest, "invalidValue", "active must be a boolean")
+		}
+		req.IsActive = &active
+
+	case path == "username" || path == "emails" || strings.HasPrefix(path, "emails["):
+		email := scimStringOrFirstValue(op.Value)
+		if email == "" {
+			return models.NewSCIMError(http.StatusBadRequest, "invalidValue", op.Path+" must be an email")
+		}
+		req.Email = email
+
+	case path == "displayname" || path == "name.formatted":
+		name, ok := op.Value.(string)
+		if !ok || name == "" {
+			return models.NewSCIMError(http.StatusBadRequest, "invalidValue", op.Path+" must be a string")
+		}
+		req.Name = name
+
+	case path == "name":
+		attrs, ok := op.Value.(map[string]interface{})
+		if !ok {
+			return models.NewSCIMError(http.StatusBadRequest, "invalidValue", "name must be an object")
+		}
+		name := scimNameFromMap(attrs)
+		if name == "" {
+			return models.NewSCIMError(http.StatusBadRequest, "invalidValue", "name must not be empty")
+		}
+		req.Name = name
+
+	case path == "roles" || strings.HasPrefix(path, "roles["):
+		role := scimStringOrFirstValue(op.Value)
+		if role != "user" && role != "admin" {
+			return models.NewSCIMError(http.StatusBadRequest, "invalidValue", "role must be either user or admin")
+		}
+		req.Role = role
+
+	case path == "password":
+		password, ok := op.Value.(string)
+		if !ok {
+			return models.NewSCIMError(http.StatusBadRequest, "invalidValue", "password must be a string")
+		}
+		req.Password = password
+
+	case path == "externalid":
+		// Accepted for IdP compatibility but not stored
+
+	default:
+		return models.NewSCIMError(http.StatusBadRequest, "invalidPath", "unsupported path "+op.Path)
+	}
+
+	return nil
+}
+
+// scimEmail returns the userName, or the primary email when userName is empty
+func scimEmail(resource *models.SCIMUser) string {
+	if resource.UserName != "" {
+		return strings.ToLower(strings.TrimSpace(resource.UserName))
+	}
+	for _, email := range resource.Emails {
+		if email.Primary {
+			return strings.ToLower(strings.TrimSpace(email.Value))
+		}
+	}
+	if len(resource.Emails) > 0 {
+		return strings.ToLower(strings.TrimSpace(resource.Emails[0].Value))
+	}
+	return ""
+}
+
+// scimDisplayName derives User.Name from displayName, name or the email
+func scimDisplayName(resource *models.SCIMUser, email string) string {
+	if resource.DisplayName != "" {
+		return resource.DisplayName
+	}
+	if resource.Name != nil {
+		if resource.Name.Formatted != "" {
+			return resource.Name.Formatted
+		}
+		if name := strings.TrimSpace(resource.Name.GivenName + " " + resource.Name.FamilyName); name != "" {
+			return name
+		}
+	}
+	return email
+}
+
+// scimRole returns the primary (or first) role, or fallback when none is sent
+func scimRole(roles []models.SCIMMultiVal, fallback string) (string, error) {
+	if len(roles) == 0 {
+		return fallback, nil
+	}
+
+	role := roles[0].Value
+	for _, r := range roles {
+		if r.Primary {
+			role = r.Value
+			break
+		}
+	}
+
+	if role != "user" && role != "admin" {
+		return "", models.NewSCIMError(http.StatusBadRequest, "invalidValue", "role must be either user or admin")
+	}
+	return role, nil
+}
+
+// scimBool accepts JSON booleans and the "True"/"False" strings some IdPs send
+func scimBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(v) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// scimStringOrFirstValue reads a plain string or the value of a multi-valued attribute
+func scimStringOrFirstValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []interface{}:
+		var first string
+		for _, item := range v {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			val, _ := entry["value"].(string)
+			if primary, _ := entry["primary"].(bool); primary {
+				return strings.TrimSpace(val)
+			}
+			if first == "" {
+				first = val
+			}
+		}
+		return strings.TrimSpace(first)
+	case map[string]interface{}:
+		val, _ := v["value"].(string)
+		return strings.TrimSpace(val)
+	}
+	return ""
+}
+
+// scimNameFromMap reads a SCIM name object
+func scimNameFromMap(attrs map[string]interface{}) string {
+	if formatted, _ := attrs["formatted"].(string); formatted != "" {
+		return formatted
+	}
+	given, _ := attrs["givenName"].(string)
+	family, _ := attrs["familyName"].(string)
+	return strings.TrimSpace(given + " " + family)
+}
+
+// mapSCIMError converts service errors into SCIM errors
+func mapSCIMError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*models.SCIMError); ok {
+		return err
+	}
+	if _, ok := err.(*models.PasswordPolicyError); ok {
+		return models.NewSCIMError(http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	switch err.Error() {
+	case "user not found", "invalid user ID":
+		return models.NewSCIMError(http.StatusNotFound, "", "user not found")
+	case "email already registered", "email already in use":
+		return models.NewSCIMError(http.StatusConflict, "uniqueness", err.Error())
+	}
+
+	if strings.Contains(err.Error(), "must") {
+		return models.NewSCIMError(http.StatusBadRequest, "invalidValue", err.Error())
+	}
+
+	return err
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+)
+
+// scimContext is the context SCIMAuthMiddleware hands to the SCIM handlers
+func scimContext(t *testing.T) context.Context {
+	t.Helper()
+
+	var ctx context.Context
+	handler := middleware.SCIMAuthMiddleware("scim-token")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/scim/v2/Users", nil)
+	req.Header.Set("Authorization", "Bearer scim-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if ctx == nil {
+		t.Fatal("SCIM middleware rejected the token")
+	}
+	return ctx
+}
+
+func TestSCIMRoleMapping(t *testing.T) {
+	env := newTestEnv(t)
+	scim := NewSCIMService(env.userService, env.userRepo)
+	ctx := scimContext(t)
+
+	created, err := scim.CreateUser(ctx, &models.SCIMUser{UserName: "Jane@Example.com", DisplayName: "Jane Doe", Roles: []models.SCIMMultiVal{{Value: "admin", Primary: true}}})
+	if err != nil {
+		t.Fatalf("CreateUser with the admin role: %v", err)
+	}
+	if !created.IsSuperAdmin() || created.Email != "jane@example.com" {
+		t.Fatalf("created user = %+v, want a super-admin", created)
+	}
+	id := created.ID.Hex()
+
+	// PUT without roles leaves the role alone
+	replaced, err := scim.ReplaceUser(ctx, id, &models.SCIMUser{UserName: "jane@example.com", DisplayName: "Jane Roe"})
+	if err != nil || !replaced.IsSuperAdmin() || replaced.Name != "Jane Roe" {
+		t.Fatalf("ReplaceUser without roles = %+v, %v", replaced, err)
+	}
+
+	replaced, err = scim.ReplaceUser(ctx, id, &models.SCIMUser{UserName: "jane@example.com", Roles: []models.SCIMMultiVal{{Value: "user"}}})
+	if err != nil || replaced.IsSuperAdmin() {
+		t.Fatalf("ReplaceUser with the user role = %+v, %v; want the admin role revoked", replaced, err)
+	}
+
+	patched, err := scim.PatchUser(ctx, id, &models.SCIMPatchRequest{Operations: []models.SCIMPatchOperation{{Op: "replace", Path: "roles", Value: "admin"}}})
+	if err != nil || !patched.IsSuperAdmin() {
+		t.Fatalf("PATCH replace roles = %+v, %v; want the admin role granted", patched, err)
+	}
+
+	patched, err = scim.PatchUser(ctx, id, &models.SCIMPatchRequest{Operations: []models.SCIMPatchOperation{{Op: "remove", Path: "roles"}}})
+	if err != nil || patched.IsSuperAdmin() {
+		t.Fatalf("PATCH remove roles = %+v, %v; want the admin role revoked", patched, err)
+	}
+
+	if _, err := scim.CreateUser(ctx, &models.SCIMUser{UserName: "owner@example.com", Roles: []models.SCIMMultiVal{{Value: "owner"}}}); err == nil {
+		t.Fatal("CreateUser accepted an unknown role")
+	}
+}
+
+func TestSCIMMiddlewareMarksProvisioner(t *testing.T) {
+	ctx := scimContext(t)
+	if middleware.GetUserID(ctx) != middleware.SCIMActorID || !middleware.IsProvisioner(ctx) || middleware.IsSuperAdmin(ctx) {
+		t.Fatalf("SCIM context: user %q, provisioner %v, super-admin %v", middleware.GetUserID(ctx), middleware.IsProvisioner(ctx), middleware.IsSuperAdmin(ctx))
+	}
+}
+
+func TestOrgAdminCannotGrantPlatformAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	orgAdmin := env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin)
+	member := env.createUser("member@example.com", "user", models.OrgRoleMember)
+	ctx := env.callerContext(orgAdmin)
+
+	isActive := true
+	_, err := env.userService.CreateUser(ctx, &models.CreateUserRequest{Name: "New Admin", Email: "new@example.com", Password: testPassword, Role: "admin", IsActive: &isActive})
+	if err == nil || err.Error() != "only super-admins can grant the admin role" {
+		t.Fatalf("CreateUser with the admin role: err = %v", err)
+	}
+
+	_, err = env.userService.UpdateUser(ctx, member.ID.Hex(), &models.UpdateUserRequest{Role: "admin"})
+	if err == nil || err.Error() != "only super-admins can change the role" {
+		t.Fatalf("UpdateUser to the admin role: err = %v", err)
+	}
+	if env.reload(member).IsSuperAdmin() {
+		t.Fatal("an organization admin promoted a member to super-admin")
+	}
+}
+
+func TestSCIMEmailChangeAppliesDirectly(t *testing.T) {
+	env := newTestEnv(t)
+	scim := NewSCIMService(env.userService, env.userRepo)
+	ctx := scimContext(t)
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	replaced, err := scim.ReplaceUser(ctx, jane.ID.Hex(), &models.SCIMUser{UserName: "jane.doe@example.com"})
+	if err != nil || replaced.Email != "jane.doe@example.com" || replaced.PendingEmail != "" {
+		t.Fatalf("ReplaceUser with a new userName = %+v, %v; want the email changed", replaced, err)
+	}
+	if mails := env.mailer.sent("jane.doe@example.com"); len(mails) != 0 {
+		t.Fatalf("the provisioned address was asked to confirm: %+v", mails)
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+
+	"user-management-system/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey signs ID tokens with RS256, so relying parties can verify them
+// with the public key from /oauth/jwks without sharing a secret
+type SigningKey struct {
+	key *rsa.PrivateKey
+	kid string
+}
+
+// LoadSigningKey reads an RSA private key from a PEM file (PKCS #1 or
+// PKCS #8). With an empty path it generates a key, which lasts until the
+// process exits.
+func LoadSigningKey(path string) (*SigningKey, error) {
+	if path == "" {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return newSigningKey(key), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return newSigningKey(key), nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("not an RSA private key")
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return newSigningKey(key), nil
+}
+
+// newSigningKey identifies key by its JWK thumbprint (RFC 7638)
+func newSigningKey(key *rsa.PrivateKey) *SigningKey {
+	k := &SigningKey{key: key}
+	jwk := k.JWK()
+	// The thumbprint hashes the required members in lexicographic order
+	thumbprint, _ := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{jwk.E, jwk.Kty, jwk.N})
+	sum := sha256.Sum256(thumbprint)
+	k.kid = base64.RawURLEncoding.EncodeToString(sum[:])
+	return k
+}
+
+// JWK returns the public key as a JSON Web Key
+func (k *SigningKey) JWK() models.JSONWebKey {
+	return models.JSONWebKey{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: jwt.SigningMethodRS256.Alg(),
+		Kid: k.kid,
+		N:   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+	}
+}
+
+// Sign returns claims as a JWT signed with RS256
+func (k *SigningKey) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = k.kid
+	return token.SignedString(k.key)
+}
+
+// Parse verifies a JWT signed with this key and decodes it into claims
+func (k *SigningKey) Parse(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) error {
+	options = append(options, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return &k.key.PublicKey, nil
+	}, options...)
+	return err
+}
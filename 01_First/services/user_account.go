@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+	"user-management-system/tenant"
+)
+
+// Self-service operations on the authenticated user's own account. They act
+// on middleware.GetUserID and work from whichever organization the user acts in.
+
+// GetProfile retrieves the authenticated user
+func (s *UserService) GetProfile(ctx context.Context) (*models.UserResponse, error) {
+	id := middleware.GetUserID(ctx)
+	return s.GetUserByID(selfScope(ctx, id), id)
+}
+
+// UpdateProfile applies a JSON merge patch to the authenticated user. Only
+// the name can be changed this way; the email and password have their own
+// endpoints, which ask for the current password.
+func (s *UserService) UpdateProfile(ctx context.Context, patch map[string]interface{}, ifMatch *int64) (*models.UserResponse, error) {
+	for field := range patch {
+		switch field {
+		case "email":
+			return nil, errors.New("email is changed with POST /api/me/email")
+		case "password":
+			return nil, errors.New("password is changed with POST /api/me/password")
+		case "role", "isActive":
+			return nil, errors.New(field + " cannot be changed on your own account")
+		}
+	}
+
+	return s.PatchUser(ctx, middleware.GetUserID(ctx), patch, ifMatch)
+}
+
+// ChangeOwnPassword verifies the authenticated user's current password and replaces it
+func (s *UserService) ChangeOwnPassword(ctx context.Context, req *models.ChangeOwnPasswordRequest) (*models.UserResponse, error) {
+	if req.CurrentPassword == "" {
+		return nil, errors.New("current password is required")
+	}
+	if err := checkNewPassword(req.CurrentPassword, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	user, err := s.verifySelf(ctx, req.CurrentPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.replacePassword(tenant.AcrossOrgs(ctx), user, req.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+	return updated.ToUserResponse(), nil
+}
+
+// CloseAccount soft-deletes the authenticated user's account after checking
+// their password. The last super-admin and the last owner of an organization
+// cannot leave, so nothing is left without an administrator.
+func (s *UserService) CloseAccount(ctx context.Context, req *models.CloseAccountRequest) error {
+	user, err := s.verifySelf(ctx, req.Password)
+	if err != nil {
+		return err
+	}
+	ctx = tenant.AcrossOrgs(ctx)
+
+	if user.IsSuperAdmin() {
+		admins, err := s.userRepo.CountByRole(ctx, "admin")
+		if err != nil {
+			return err
+		}
+		if admins <= 1 {
+			return errors.New("the last super-admin cannot close their account")
+		}
+	}
+
+	for _, membership := range user.Orgs {
+		if membership.Role != models.OrgRoleOwner {
+			continue
+		}
+		owners, err := s.userRepo.CountOrgRole(ctx, membership.OrgID, models.OrgRoleOwner)
+		if err != nil {
+			return err
+		}
+		if owners <= 1 {
+			return errors.New("transfer ownership of your organizations before closing your account")
+		}
+	}
+
+	if err := s.userRepo.Delete(ctx, user.ID.Hex()); err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionUserDelete,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Reason:      "account closed by the user",
+	})
+	s.webhookService.Publish(ctx, models.WebhookEventUserDeleted, user)
+
+	return nil
+}
+
+// verifySelf loads the authenticated user and checks their password
+func (s *UserService) verifySelf(ctx context.Context, password string) (*models.User, error) {
+	if password == "" {
+		return nil, errors.New("password is required")
+	}
+
+	user, err := s.userRepo.FindByID(tenant.AcrossOrgs(ctx), middleware.GetUserID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyPassword(user.Password, password); err != nil {
+		return nil, errors.New("password is incorrect")
+	}
+
+	return user, nil
+}
+
+// RequestEmailChange checks the authenticated user's password and starts a
+// confirmed change to the new email
+func (s *UserService) RequestEmailChange(ctx context.Context, req *models.ChangeEmailRequest) (*models.UserResponse, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if !strings.Contains(email, "@") {
+		return nil, errors.New("email must be a valid email address")
+	}
+
+	user, err := s.verifySelf(ctx, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if email == user.Email {
+		return nil, errors.New("new email must be different from the current email")
+	}
+
+	updated, err := s.emailChangeService.Request(tenant.AcrossOrgs(ctx), user, email)
+	if err != nil {
+		return nil, err
+	}
+	return updated.ToUserResponse(), nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"testing"
+
+	"user-management-system/models"
+)
+
+func TestOwnCredentialsChangeOnlyThroughMe(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin@example.com", "admin", models.OrgRoleOwner)
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+	ctx := env.callerContext(jane)
+	id := jane.ID.Hex()
+
+	for name, test := range map[string]struct {
+		update func() error
+		want   string
+	}{
+		"PUT email": {func() error {
+			_, err := env.userService.UpdateUser(ctx, id, &models.UpdateUserRequest{Email: "thief@example.com"})
+			return err
+		}, "email is changed with POST /api/me/email"},
+		"PUT password": {func() error {
+			_, err := env.userService.UpdateUser(ctx, id, &models.UpdateUserRequest{Password: "stolen token password"})
+			return err
+		}, "password is changed with POST /api/me/password"},
+		"PATCH email": {func() error {
+			_, err := env.userService.PatchUser(ctx, id, map[string]interface{}{"email": "thief@example.com"}, nil)
+			return err
+		}, "email is changed with POST /api/me/email"},
+		"PATCH password": {func() error {
+			_, err := env.userService.PatchUser(ctx, id, map[string]interface{}{"password": "stolen token password"}, nil)
+			return err
+		}, "password is changed with POST /api/me/password"},
+		"PATCH /api/me": {func() error {
+			_, err := env.userService.UpdateProfile(ctx, map[string]interface{}{"password": "stolen token password"}, nil)
+			return err
+		}, "password is changed with POST /api/me/password"},
+	} {
+		if err := test.update(); err == nil || err.Error() != test.want {
+			t.Errorf("%s: err = %v, want %q", name, err, test.want)
+		}
+	}
+	if reloaded := env.reload(jane); reloaded.Email != jane.Email || reloaded.PendingEmail != "" || reloaded.Password != jane.Password {
+		t.Fatalf("jane = %+v, want her credentials unchanged", reloaded)
+	}
+
+	// Sending back the unchanged email with other changes is fine
+	if _, err := env.userService.UpdateUser(ctx, id, &models.UpdateUserRequest{Name: "Jane Roe", Email: "Jane@Example.com"}); err != nil {
+		t.Fatalf("PUT with the unchanged email: %v", err)
+	}
+
+	// Admins still reset other users' passwords
+	if _, err := env.userService.UpdateUser(env.callerContext(admin), id, &models.UpdateUserRequest{Password: "reset by the admin"}); err != nil {
+		t.Fatalf("admin resetting jane's password: %v", err)
+	}
+}
+
+func TestChangeOwnPassword(t *testing.T) {
+	env := newTestEnv(t)
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+	ctx := env.callerContext(jane)
+
+	_, err := env.userService.ChangeOwnPassword(ctx, &models.ChangeOwnPasswordRequest{CurrentPassword: "wrong password", NewPassword: "a brand new password"})
+	if err == nil || err.Error() != "password is incorrect" {
+		t.Fatalf("ChangeOwnPassword with a wrong password: err = %v", err)
+	}
+	if _, err := env.userService.ChangeOwnPassword(ctx, &models.ChangeOwnPasswordRequest{CurrentPassword: testPassword, NewPassword: "a brand new password"}); err != nil {
+		t.Fatalf("ChangeOwnPassword: %v", err)
+	}
+	if _, err := env.userService.Login(systemContext(), &models.LoginRequest{Email: jane.Email, Password: "a brand new password"}); err != nil {
+		t.Fatalf("login with the new password: %v", err)
+	}
+}
+
+func TestRequestEmailChangeNeedsPassword(t *testing.T) {
+	env := newTestEnv(t)
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+	ctx := env.callerContext(jane)
+
+	if _, err := env.userService.RequestEmailChange(ctx, &models.ChangeEmailRequest{Email: "new@example.com", Password: "wrong password"}); err == nil {
+		t.Fatal("RequestEmailChange accepted a wrong password")
+	}
+	user, err := env.userService.RequestEmailChange(ctx, &models.ChangeEmailRequest{Email: "new@example.com", Password: testPassword})
+	if err != nil || user.Email != jane.Email || user.PendingEmail != "new@example.com" {
+		t.Fatalf("RequestEmailChange = %+v, %v", user, err)
+	}
+	env.mailer.token(t, "new@example.com", "http://app/confirm-email")
+}
+
+func TestCloseAccount(t *testing.T) {
+	env := newTestEnv(t)
+	root := env.createUser("root@example.com", "admin", models.OrgRoleOwner)
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	if err := env.userService.CloseAccount(env.callerContext(root), &models.CloseAccountRequest{Password: testPassword}); err == nil || err.Error() != "the last super-admin cannot close their account" {
+		t.Fatalf("closing the last super-admin: err = %v", err)
+	}
+	if err := env.userService.CloseAccount(env.callerContext(jane), &models.CloseAccountRequest{Password: "wrong password"}); err == nil {
+		t.Fatal("CloseAccount accepted a wrong password")
+	}
+	if err := env.userService.CloseAccount(env.callerContext(jane), &models.CloseAccountRequest{Password: testPassword}); err != nil {
+		t.Fatalf("CloseAccount: %v", err)
+	}
+	if _, err := env.userRepo.FindByID(systemContext(), jane.ID.Hex()); err == nil {
+		t.Fatal("the closed account is still there")
+	}
+}
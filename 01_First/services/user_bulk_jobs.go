@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"user-management-system/middleware"
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// deactivateBatchSize is how many users a deactivation job handles between checkpoints
+	deactivateBatchSize = 100
+
+	// deactivateWorkers bounds concurrent updates in a deactivation job
+	deactivateWorkers = 8
+
+	// maxDeactivateErrors caps the per-user error report
+	maxDeactivateErrors = 100
+)
+
+// registerJobs registers the bulk operations that can run as background jobs
+func (s *UserBulkService) registerJobs() {
+	s.jobService.Register(models.JobTypeUserImport, s.runImportJob)
+	s.jobService.Register(models.JobTypeUserExport, s.runExportJob)
+	s.jobService.Register(models.JobTypeUserDeactivate, s.runDeactivateJob)
+}
+
+// QueueImport stores the upload and queues it as a user.import job
+func (s *UserBulkService) QueueImport(ctx context.Context, r io.Reader, opts models.UserImportOptions) (*models.Job, error) {
+	if opts.Format != models.UserFormatCSV && opts.Format != models.UserFormatNDJSON {
+		return nil, errors.New("invalid import file: format must be csv or ndjson")
+	}
+	return s.jobService.Enqueue(ctx, models.JobTypeUserImport, opts, r)
+}
+
+// QueueExport queues a user.export job
+func (s *UserBulkService) QueueExport(ctx context.Context, params models.UserExportJobParams) (*models.Job, error) {
+	if params.Format != models.UserFormatCSV && params.Format != models.UserFormatNDJSON {
+		return nil, errors.New("format must be csv or ndjson")
+	}
+	return s.jobService.Enqueue(ctx, models.JobTypeUserExport, params, nil)
+}
+
+// QueueDeactivation queues a user.deactivate job for the users matching req
+func (s *UserBulkService) QueueDeactivation(ctx context.Context, req *models.DeactivateUsersRequest) (*models.Job, error) {
+	if _, err := deactivateFilter(req); err != nil {
+		return nil, err
+	}
+	return s.jobService.Enqueue(ctx, models.JobTypeUserDeactivate, req, nil)
+}
+
+// runImportJob imports the uploaded file, checkpointing after every batch so
+// a resumed job skips the rows that are already done
+func (s *UserBulkService) runImportJob(ctx context.Context, run *JobRun) (interface{}, error) {
+	var opts models.UserImportOptions
+	if err := run.DecodeParams(&opts); err != nil {
+		return nil, err
+	}
+
+	var resume *importCheckpoint
+	var state importCheckpoint
+	if found, err := run.LoadCheckpoint(&state); err != nil {
+		return nil, err
+	} else if found {
+		resume = &state
+	}
+
+	input, err := run.OpenInput()
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	return s.importUsers(ctx, input, opts, resume, func(checkpoint *importCheckpoint) error {
+		run.SetProgress(int64(checkpoint.Row), 0)
+		return run.SaveCheckpoint(ctx, checkpoint)
+	})
+}
+
+// runExportJob writes the matching users to the job's result file
+func (s *UserBulkService) runExportJob(ctx context.Context, run *JobRun) (interface{}, error) {
+	var params models.UserExportJobParams
+	if err := run.DecodeParams(&params); err != nil {
+		return nil, err
+	}
+
+	filter := models.UserFilter{
+		Search:         params.Search,
+		Role:           params.Role,
+		IsActive:       params.IsActive,
+		IncludeDeleted: params.IncludeDeleted,
+	}
+
+	total, err := s.userRepo.Count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	run.SetProgress(0, total)
+
+	name, contentType := "users.csv", "text/csv; charset=utf-8"
+	if params.Format == models.UserFormatNDJSON {
+		name, contentType = "users.ndjson", "application/x-ndjson"
+	}
+
+	var count int
+	err = run.WriteResultFile(ctx, name, contentType, func(w io.Writer) error {
+		count, err = s.ExportUsers(ctx, w, params.Format, filter, func(written int) {
+			run.SetProgress(int64(written), total)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	run.SetProgress(int64(count), total)
+
+	return &models.UserExportResult{Format: params.Format, Count: count}, nil
+}
+
+// deactivateCheckpoint is the state an interrupted deactivation job resumes from
+type deactivateCheckpoint struct {
+	AfterID string                       `json:"afterId"` // Users up to this ID are done
+	Result  models.DeactivateUsersResult `json:"result"`
+}
+
+// runDeactivateJob deactivates the matching users in ID order. Each batch runs
+// to completion before the job checks for cancellation and checkpoints, so a
+// resumed job continues after the last finished batch.
+func (s *UserBulkService) runDeactivateJob(ctx context.Context, run *JobRun) (interface{}, error) {
+	var req models.DeactivateUsersRequest
+	if err := run.DecodeParams(&req); err != nil {
+		return nil, err
+	}
+
+	filter, err := deactivateFilter(&req)
+	if err != nil {
+		return nil, err
+	}
+
+	state := deactivateCheckpoint{Result: models.DeactivateUsersResult{Errors: []models.DeactivateUserError{}}}
+	if _, err := run.LoadCheckpoint(&state); err != nil {
+		return nil, err
+	}
+	if state.AfterID != "" {
+		if filter.AfterID, err = primitive.ObjectIDFromHex(state.AfterID); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &state.Result
+	done := int64(result.Deactivated + result.Skipped + result.Failed)
+	remaining, err := s.userRepo.Count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	total := done + remaining
+
+	// The admin who started the job is never locked out by it
+	self := middleware.GetUserID(ctx)
+	isActive := false
+	deactivate := &models.UpdateUserRequest{IsActive: &isActive}
+
+	batch := make([]*models.User, 0, deactivateBatchSize)
+	processBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		// Finish the batch even if the job is cancelled meanwhile
+		batchCtx := context.WithoutCancel(ctx)
+		var mu sync.Mutex
+		forEachParallel(batchCtx, len(batch), deactivateWorkers, func(i int) {
+			user := batch[i]
+			var err error
+			if user.ID.Hex() != self {
+				_, err = s.userService.UpdateUser(batchCtx, user.ID.Hex(), deactivate)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case user.ID.Hex() == self:
+				result.Skipped++
+			case err != nil:
+				result.Failed++
+				if len(result.Errors) < maxDeactivateErrors {
+					result.Errors = append(result.Errors, models.DeactivateUserError{UserID: user.ID.Hex(), Email: user.Email, Error: err.Error()})
+				}
+			default:
+				result.Deactivated++
+			}
+		})
+
+		state.AfterID = batch[len(batch)-1].ID.Hex()
+		batch = batch[:0]
+		run.SetProgress(int64(result.Deactivated+result.Skipped+result.Failed), total)
+		if err := run.SaveCheckpoint(ctx, &state); err != nil {
+			return err
+		}
+		return ctx.Err()
+	}
+
+	err = s.userRepo.Stream(ctx, filter, func(user *models.User) error {
+		batch = append(batch, user)
+		if len(batch) < deactivateBatchSize {
+			return nil
+		}
+		return processBatch()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := processBatch(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// deactivateFilter validates a deactivation request and converts it to the
+// filter of active users it applies to. Members whose home is another
+// organization are left alone.
+func deactivateFilter(req *models.DeactivateUsersRequest) (models.UserFilter, error) {
+	isActive := true
+	filter := models.UserFilter{
+		Search:    strings.TrimSpace(req.Search),
+		Role:      req.Role,
+		IsActive:  &isActive,
+		OwnedOnly: true,
+	}
+
+	if len(req.IDs) == 0 && filter.Search == "" && filter.Role == "" {
+		return filter, errors.New("at least one of ids, search or role is required")
+	}
+	if filter.Role != "" && filter.Role != "user" && filter.Role != "admin" {
+		return filter, errors.New("role must be either user or admin")
+	}
+
+	for _, id := range req.IDs {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user ID %q", id)
+		}
+		filter.IDs = append(filter.IDs, objectID)
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"user-management-system/models"
+)
+
+func TestImportJob(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("admin@example.com", "admin", models.OrgRoleOwner))
+
+	file := "name,email,password\nJane Doe,jane@example.com,correct horse battery\nBad,bad,correct horse battery\n"
+	job, err := env.bulkService.QueueImport(ctx, strings.NewReader(file), models.UserImportOptions{Format: models.UserFormatCSV})
+	if err != nil || job.InputFileID == nil {
+		t.Fatalf("QueueImport = %+v, %v", job, err)
+	}
+
+	job = env.runJob(job.ID.Hex())
+	if job.Status != models.JobSucceeded {
+		t.Fatalf("import job = %+v", job)
+	}
+	var report models.UserImportReport
+	if err := json.Unmarshal([]byte(job.Result), &report); err != nil || report.Created != 1 || report.Failed != 1 {
+		t.Fatalf("import report = %+v, %v", report, err)
+	}
+	if _, err := env.userRepo.FindByEmail(ctx, "jane@example.com"); err != nil {
+		t.Fatalf("imported user: %v", err)
+	}
+
+	// The upload is removed once the job is done
+	run := &JobRun{service: env.jobService, job: job}
+	if _, err := run.OpenInput(); err == nil {
+		t.Fatal("the input file of a finished job was kept")
+	}
+
+	if _, err := env.bulkService.QueueImport(ctx, strings.NewReader(file), models.UserImportOptions{Format: "xml"}); err == nil {
+		t.Fatal("QueueImport accepted an unknown format")
+	}
+}
+
+func TestExportJob(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("admin@example.com", "admin", models.OrgRoleOwner))
+	env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	job, err := env.bulkService.QueueExport(ctx, models.UserExportJobParams{Format: models.UserFormatCSV})
+	if err != nil {
+		t.Fatalf("QueueExport: %v", err)
+	}
+	job = env.runJob(job.ID.Hex())
+	if job.Status != models.JobSucceeded || job.Progress.Processed != 2 || job.Progress.Total != 2 || job.ResultFileName != "users.csv" {
+		t.Fatalf("export job = %+v", job)
+	}
+
+	_, file, err := env.jobService.OpenResult(ctx, job.ID.Hex())
+	if err != nil {
+		t.Fatalf("OpenResult: %v", err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil || len(records) != 3 {
+		t.Fatalf("exported file = %v, %v", records, err)
+	}
+}
+
+func TestDeactivateJob(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin@example.com", "admin", models.OrgRoleOwner)
+	ctx := env.callerContext(admin)
+	contractors := []*models.User{
+		env.createUser("a.contractor@example.com", "user", models.OrgRoleMember),
+		env.createUser("b.contractor@example.com", "user", models.OrgRoleMember),
+	}
+	employee := env.createUser("employee@example.com", "user", models.OrgRoleMember)
+
+	if _, err := env.bulkService.QueueDeactivation(ctx, &models.DeactivateUsersRequest{}); err == nil {
+		t.Fatal("QueueDeactivation accepted a request matching everyone")
+	}
+
+	job, err := env.bulkService.QueueDeactivation(ctx, &models.DeactivateUsersRequest{Search: "contractor"})
+	if err != nil {
+		t.Fatalf("QueueDeactivation: %v", err)
+	}
+	job = env.runJob(job.ID.Hex())
+	var result models.DeactivateUsersResult
+	if err := json.Unmarshal([]byte(job.Result), &result); err != nil || result.Deactivated != 2 || result.Failed != 0 {
+		t.Fatalf("deactivation result = %+v, %v (job %+v)", result, err, job)
+	}
+	for _, user := range contractors {
+		if env.reload(user).IsActive {
+			t.Errorf("%s is still active", user.Email)
+		}
+	}
+	if !env.reload(employee).IsActive {
+		t.Fatal("a user who did not match was deactivated")
+	}
+
+	// The admin who starts the job never locks themselves out
+	job, _ = env.bulkService.QueueDeactivation(ctx, &models.DeactivateUsersRequest{IDs: []string{admin.ID.Hex(), employee.ID.Hex()}})
+	job = env.runJob(job.ID.Hex())
+	result = models.DeactivateUsersResult{}
+	json.Unmarshal([]byte(job.Result), &result)
+	if result.Deactivated != 1 || result.Skipped != 1 || !env.reload(admin).IsActive {
+		t.Fatalf("deactivation including the admin = %+v", result)
+	}
+}
+
+func TestDeactivateJobResumesAfterCheckpoint(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin@example.com", "admin", models.OrgRoleOwner)
+	first := env.createUser("first@example.com", "user", models.OrgRoleMember)
+	second := env.createUser("second@example.com", "user", models.OrgRoleMember)
+
+	job, err := env.bulkService.QueueDeactivation(env.callerContext(admin), &models.DeactivateUsersRequest{Role: "user"})
+	if err != nil {
+		t.Fatalf("QueueDeactivation: %v", err)
+	}
+
+	// An earlier attempt finished the batch holding the first user
+	claimed, err := env.jobService.jobRepo.ClaimNext(context.Background(), "dead-replica", env.jobService.lease, env.jobService.maxAttempts)
+	if err != nil || claimed == nil {
+		t.Fatalf("ClaimNext = %v, %v", claimed, err)
+	}
+	run := &JobRun{service: env.jobService, job: claimed}
+	if err := run.SaveCheckpoint(context.Background(), &deactivateCheckpoint{AfterID: first.ID.Hex(), Result: models.DeactivateUsersResult{Deactivated: 1}}); err != nil {
+		t.Fatalf("SaveCheckpoint: %v", err)
+	}
+	env.expireJobLease(claimed)
+
+	job = env.runJob(job.ID.Hex())
+	var result models.DeactivateUsersResult
+	if err := json.Unmarshal([]byte(job.Result), &result); err != nil || result.Deactivated != 2 {
+		t.Fatalf("resumed result = %+v, %v", result, err)
+	}
+	if !env.reload(first).IsActive || env.reload(second).IsActive {
+		t.Fatal("the resumed job did not continue after its checkpoint")
+	}
+}
@@ -0,0 +1,551 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"user-management-system/models"
+	"user-management-system/repositories"
+)
+
+const (
+	// importBatchSize matches the batch size used by scripts/seed_users.go
+	importBatchSize = 500
+
+	// maxImportErrors caps the per-row error report
+	maxImportErrors = 1000
+
+	// importMailWorkers bounds concurrent password-set emails
+	importMailWorkers = 4
+
+	// maxImportLineSize is the longest NDJSON line accepted
+	maxImportLineSize = 64 * 1024
+
+	// exportFlushRows is how often export output is flushed
+	exportFlushRows = 500
+)
+
+// UserBulkService imports, exports and deactivates users in bulk
+type UserBulkService struct {
+	userRepo           *repositories.UserRepository
+	userService        *UserService
+	auditService       *AuditService
+	webhookService     *WebhookService
+	passwordSetService *PasswordSetService
+	jobService         *JobService
+}
+
+// NewUserBulkService creates a new bulk user service and registers its
+// background jobs with jobService
+func NewUserBulkService(userRepo *repositories.UserRepository, userService *UserService, auditService *AuditService, webhookService *WebhookService, passwordSetService *PasswordSetService, jobService *JobService) *UserBulkService {
+	s := &UserBulkService{
+		userRepo:           userRepo,
+		userService:        userService,
+		auditService:       auditService,
+		webhookService:     webhookService,
+		passwordSetService: passwordSetService,
+		jobService:         jobService,
+	}
+	s.registerJobs()
+	return s
+}
+
+// importRow is a validated row waiting to be inserted
+type importRow struct {
+	number int
+	row    models.UserImportRow
+}
+
+// ImportUsers streams rows from r and validates them one by one. Valid rows are
+// inserted in unordered batches, so one bad row never blocks the others. With
+// DryRun nothing is written. With Invite, rows without a password are created
+// with an unusable password and the user is mailed a password-set link.
+func (s *UserBulkService) ImportUsers(ctx context.Context, r io.Reader, opts models.UserImportOptions) (*models.UserImportReport, error) {
+	return s.importUsers(ctx, r, opts, nil, nil)
+}
+
+// importCheckpoint is the state an interrupted import job resumes from
+type importCheckpoint struct {
+	Row    int                     `json:"row"` // Rows up to and including this one are done
+	Report models.UserImportReport `json:"report"`
+}
+
+// importUsers implements ImportUsers. Rows up to resume.Row, when set, were
+// handled by an earlier attempt and are only read to restore the duplicate
+// check. checkpoint, when set, is called after every batch.
+func (s *UserBulkService) importUsers(ctx context.Context, r io.Reader, opts models.UserImportOptions, resume *importCheckpoint, checkpoint func(*importCheckpoint) error) (*models.UserImportReport, error) {
+	next, err := newImportReader(r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.UserImportReport{
+		DryRun: opts.DryRun,
+		Errors: []models.UserImportError{},
+	}
+	skip := 0
+	if resume != nil {
+		*report = resume.Report
+		skip = resume.Row
+	}
+
+	seen := make(map[string]bool)
+	batch := make([]importRow, 0, importBatchSize)
+
+	flush := func(number int) error {
+		if err := s.importBatch(ctx, batch, opts, report); err != nil {
+			return err
+		}
+		batch = batch[:0]
+
+		if checkpoint == nil {
+			return nil
+		}
+		return checkpoint(&importCheckpoint{Row: number, Report: *report})
+	}
+
+	number := 0
+	for {
+		row, rowErr, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		number++
+
+		if number <= skip {
+			if rowErr == nil && normalizeImportRow(row, opts.Invite, s.userService.passwordPolicy) == nil {
+				seen[row.Email] = true
+			}
+			continue
+		}
+		report.Total++
+
+		if rowErr == nil {
+			rowErr = normalizeImportRow(row, opts.Invite, s.userService.passwordPolicy)
+		}
+		if rowErr == nil && seen[row.Email] {
+			rowErr = errors.New("duplicate email in file")
+		}
+		if rowErr != nil {
+			addImportError(report, number, row, rowErr)
+			continue
+		}
+
+		seen[row.Email] = true
+		batch = append(batch, importRow{number: number, row: *row})
+
+		if len(batch) == importBatchSize {
+			if err := flush(number); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(number); err != nil {
+		return nil, err
+	}
+
+	if !opts.DryRun {
+		s.auditService.Record(ctx, &models.AuditEvent{
+			Action:  models.AuditActionUserImport,
+			Success: true,
+			Reason:  fmt.Sprintf("created %d of %d rows, %d failed", report.Created, report.Total, report.Failed),
+		})
+	}
+
+	return report, nil
+}
+
+// importBatch checks a batch against existing users and inserts it
+func (s *UserBulkService) importBatch(ctx context.Context, batch []importRow, opts models.UserImportOptions, report *models.UserImportReport) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	emails := make([]string, len(batch))
+	for i, item := range batch {
+		emails[i] = item.row.Email
+	}
+
+	existing, err := s.userRepo.ExistingEmails(ctx, emails)
+	if err != nil {
+		return err
+	}
+
+	pending := make([]importRow, 0, len(batch))
+	for _, item := range batch {
+		if existing[item.row.Email] {
+			addImportError(report, item.number, &item.row, errors.New("email already registered"))
+			continue
+		}
+		if item.row.Role == "admin" && !canManagePlatformRoles(ctx) {
+			addImportError(report, item.number, &item.row, errors.New("only super-admins can grant the admin role"))
+			continue
+		}
+		pending = append(pending, item)
+	}
+
+	if opts.DryRun {
+		report.Created += len(pending)
+		for _, item := range pending {
+			if item.row.Password == "" {
+				report.Invited++
+			}
+		}
+		return nil
+	}
+
+	users, err := buildImportedUsers(ctx, pending, s.userService.passwordHasher)
+	if err != nil {
+		return err
+	}
+
+	failed, err := s.userRepo.InsertMany(ctx, users)
+	if err != nil {
+		return err
+	}
+
+	var invites []*models.User
+	for i, user := range users {
+		if insertErr, ok := failed[i]; ok {
+			addImportError(report, pending[i].number, &pending[i].row, insertErr)
+			continue
+		}
+
+		report.Created++
+		s.webhookService.Publish(ctx, models.WebhookEventUserCreated, user)
+		if pending[i].row.Password == "" {
+			invites = append(invites, user)
+		}
+	}
+
+	s.sendInvites(ctx, invites, report)
+	return nil
+}
+
+// sendInvites mails password-set links with a bounded number of workers
+func (s *UserBulkService) sendInvites(ctx context.Context, users []*models.User, report *models.UserImportReport) {
+	var mu sync.Mutex
+	forEachParallel(ctx, len(users), importMailWorkers, func(i int) {
+		err := s.passwordSetService.SendLink(ctx, users[i])
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			log.Printf("⚠️  Failed to send password-set link to %s: %v", users[i].Email, err)
+			return
+		}
+		report.Invited++
+	})
+}
+
+// buildImportedUsers hashes passwords in parallel; hashing dominates import time.
+// Invited users get a random password they never see and must set their own.
+func buildImportedUsers(ctx context.Context, rows []importRow, hasher PasswordHasher) ([]*models.User, error) {
+	users := make([]*models.User, len(rows))
+	errs := make([]error, len(rows))
+	now := time.Now()
+
+	err := forEachParallel(ctx, len(rows), runtime.NumCPU(), func(i int) {
+		row := rows[i].row
+
+		password := row.Password
+		if password == "" {
+			generated, err := generateRandomPassword()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			password = generated
+		}
+
+		hashedPassword, err := hasher.Hash(password)
+		if err != nil {
+			errs[i] = errors.New("failed to hash password")
+			return
+		}
+
+		isActive := true
+		if row.IsActive != nil {
+			isActive = *row.IsActive
+		}
+
+		users[i] = &models.User{
+			Name:               row.Name,
+			Email:              row.Email,
+			Password:           hashedPassword,
+			Role:               row.Role,
+			IsActive:           isActive,
+			MustChangePassword: row.Password == "",
+			PasswordChangedAt:  &now,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+// ExportUsers writes users matching the filter to w as CSV or NDJSON and
+// returns how many were written. progress, when set, is called every
+// exportFlushRows users, after buffered output has been written to w.
+func (s *UserBulkService) ExportUsers(ctx context.Context, w io.Writer, format string, filter models.UserFilter, progress func(written int)) (int, error) {
+	var write func(*models.User) error
+	flush := func() error { return nil }
+
+	switch format {
+	case models.UserFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(models.UserExportColumns); err != nil {
+			return 0, err
+		}
+
+		write = func(user *models.User) error {
+			deletedAt := ""
+			if user.DeletedAt != nil {
+				deletedAt = user.DeletedAt.Format(time.RFC3339)
+			}
+			return writer.Write([]string{
+				user.ID.Hex(),
+				user.Name,
+				user.Email,
+				user.Role,
+				strconv.FormatBool(user.IsActive),
+				user.CreatedAt.Format(time.RFC3339),
+				user.UpdatedAt.Format(time.RFC3339),
+				deletedAt,
+			})
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	case models.UserFormatNDJSON:
+		encoder := json.NewEncoder(w)
+		write = func(user *models.User) error {
+			return encoder.Encode(user.ToUserResponse())
+		}
+	default:
+		return 0, errors.New("format must be csv or ndjson")
+	}
+
+	written := 0
+	err := s.userRepo.Stream(ctx, filter, func(user *models.User) error {
+		if err := write(user); err != nil {
+			return err
+		}
+		written++
+
+		if written%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			if progress != nil {
+				progress(written)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return written, err
+	}
+
+	return written, flush()
+}
+
+// normalizeImportRow trims and validates a row in place; passwords must pass policy
+func normalizeImportRow(row *models.UserImportRow, invite bool, policy *PasswordPolicy) error {
+	row.Name = strings.TrimSpace(row.Name)
+	row.Email = strings.ToLower(strings.TrimSpace(row.Email))
+	row.Role = strings.TrimSpace(row.Role)
+
+	if len(row.Name) < 2 || len(row.Name) > 100 {
+		return errors.New("name must be between 2 and 100 characters")
+	}
+
+	if row.Email == "" {
+		return errors.New("email is required")
+	}
+	if addr, err := mail.ParseAddress(row.Email); err != nil || addr.Address != row.Email {
+		return errors.New("email must be a valid email address")
+	}
+
+	if row.Password == "" {
+		if !invite {
+			return errors.New("password is required unless invite=true")
+		}
+	} else if err := policy.Check(row.Password, row.Name, row.Email); err != nil {
+		return err
+	}
+
+	if row.Role == "" {
+		row.Role = "user"
+	}
+	if row.Role != "user" && row.Role != "admin" {
+		return errors.New("role must be either user or admin")
+	}
+
+	return nil
+}
+
+// addImportError records a failed row
+func addImportError(report *models.UserImportReport, number int, row *models.UserImportRow, err error) {
+	report.Failed++
+	if len(report.Errors) >= maxImportErrors {
+		report.ErrorsTruncated = true
+		return
+	}
+
+	var email string
+	if row != nil {
+		email = row.Email
+	}
+	report.Errors = append(report.Errors, models.UserImportError{Row: number, Email: email, Error: err.Error()})
+}
+
+// importReader returns the next row. rowErr reports a bad row that can be
+// skipped; err is io.EOF at the end or a problem with the file as a whole.
+type importReader func() (row *models.UserImportRow, rowErr error, err error)
+
+// newImportReader returns a streaming row reader for the format
+func newImportReader(r io.Reader, format string) (importReader, error) {
+	switch format {
+	case models.UserFormatCSV:
+		return newCSVImportReader(r)
+	case models.UserFormatNDJSON:
+		return newNDJSONImportReader(r), nil
+	default:
+		return nil, errors.New("invalid import file: format must be csv or ndjson")
+	}
+}
+
+// newCSVImportReader reads a CSV file whose header names the columns
+func newCSVImportReader(r io.Reader) (importReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("invalid import file: missing CSV header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid import file: %v", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		known := false
+		for _, column := range models.UserImportColumns {
+			if strings.EqualFold(name, column) {
+				columns[column] = i
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("invalid import file: unknown column %q", name)
+		}
+	}
+	for _, required := range []string{"name", "email"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("invalid import file: missing column %q", required)
+		}
+	}
+
+	field := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	return func() (*models.UserImportRow, error, error) {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil, io.EOF
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, fmt.Errorf("malformed CSV: %v", parseErr.Err), nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		row := &models.UserImportRow{
+			Name:     field(record, "name"),
+			Email:    field(record, "email"),
+			Password: field(record, "password"),
+			Role:     field(record, "role"),
+		}
+
+		if value := strings.TrimSpace(field(record, "isActive")); value != "" {
+			isActive, err := strconv.ParseBool(value)
+			if err != nil {
+				return row, errors.New("isActive must be true or false"), nil
+			}
+			row.IsActive = &isActive
+		}
+
+		return row, nil, nil
+	}, nil
+}
+
+// newNDJSONImportReader reads one JSON object per line; blank lines are skipped
+func newNDJSONImportReader(r io.Reader) importReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxImportLineSize)
+
+	return func() (*models.UserImportRow, error, error) {
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+
+			decoder := json.NewDecoder(bytes.NewReader(line))
+			decoder.DisallowUnknownFields()
+
+			var row models.UserImportRow
+			if err := decoder.Decode(&row); err != nil {
+				return nil, fmt.Errorf("invalid JSON: %v", err), nil
+			}
+			return &row, nil, nil
+		}
+
+		if err := scanner.Err(); err != nil {
+			if errors.Is(err, bufio.ErrTooLong) {
+				return nil, nil, errors.New("invalid import file: line too long")
+			}
+			return nil, nil, err
+		}
+		return nil, nil, io.EOF
+	}
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"user-management-system/models"
+)
+
+func TestImportUsersCSV(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("operator@example.com", "admin", models.OrgRoleOwner))
+	env.createUser("taken@example.com", "user", models.OrgRoleMember)
+
+	file := strings.Join([]string{
+		"name,email,password,role,isActive",
+		"Jane Doe,Jane@Example.com,correct horse battery,,",
+		"Root User,root@example.com,correct horse battery,admin,true",
+		"Off User,off@example.com,correct horse battery,user,false",
+		"Bad Email,not-an-email,correct horse battery,,",
+		"Jane Again,jane@example.com,correct horse battery,,",
+		"Taken,taken@example.com,correct horse battery,,",
+		"No Password,nopass@example.com,,,",
+		"Bad Flag,flag@example.com,correct horse battery,,maybe",
+		"Bad Role,role@example.com,correct horse battery,owner,",
+	}, "\n")
+
+	report, err := env.bulkService.ImportUsers(ctx, strings.NewReader(file), models.UserImportOptions{Format: models.UserFormatCSV})
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+	if report.Total != 9 || report.Created != 3 || report.Failed != 6 || report.DryRun {
+		t.Fatalf("report = %+v", report)
+	}
+
+	want := map[int]string{
+		4: "email must be a valid email address",
+		5: "duplicate email in file",
+		6: "email already registered",
+		7: "password is required unless invite=true",
+		8: "isActive must be true or false",
+		9: "role must be either user or admin",
+	}
+	got := make(map[int]string)
+	for _, rowErr := range report.Errors {
+		got[rowErr.Row] = rowErr.Error
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("row errors = %v, want %v", got, want)
+	}
+	if actions := env.auditActions(); actions[len(actions)-1] != models.AuditActionUserImport {
+		t.Fatalf("audit actions = %v, want the import recorded", actions)
+	}
+
+	if _, err := env.userService.Login(ctx, &models.LoginRequest{Email: "jane@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("imported user cannot log in: %v", err)
+	}
+	root, err := env.userRepo.FindByEmail(ctx, "root@example.com")
+	if err != nil || !root.IsSuperAdmin() {
+		t.Fatalf("imported admin = %+v, %v", root, err)
+	}
+	off, err := env.userRepo.FindByEmail(ctx, "off@example.com")
+	if err != nil || off.IsActive {
+		t.Fatalf("imported inactive user = %+v, %v", off, err)
+	}
+}
+
+func TestImportUsersDryRun(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("operator@example.com", "admin", models.OrgRoleOwner))
+
+	file := "name,email,password\nJane Doe,jane@example.com,correct horse battery\nShort,short@example.com,short\n"
+	report, err := env.bulkService.ImportUsers(ctx, strings.NewReader(file), models.UserImportOptions{Format: models.UserFormatCSV, DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+	if !report.DryRun || report.Total != 2 || report.Created != 1 || report.Failed != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	if _, err := env.userRepo.FindByEmail(ctx, "jane@example.com"); err == nil {
+		t.Fatal("a dry run created a user")
+	}
+	for _, action := range env.auditActions() {
+		if action == models.AuditActionUserImport {
+			t.Fatal("a dry run was audited")
+		}
+	}
+}
+
+func TestImportUsersWithInvites(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.callerContext(env.createUser("operator@example.com", "admin", models.OrgRoleOwner))
+
+	file := `{"name":"Jane Doe","email":"jane@example.com"}
+
+{"name":"John Doe","email":"john@example.com","password":"correct horse battery"}
+{"name":"Extra","email":"extra@example.com","nickname":"x"}
+{"name":"Broken",
+`
+	report, err := env.bulkService.ImportUsers(ctx, strings.NewReader(file), models.UserImportOptions{Format: models.UserFormatNDJSON, Invite: true})
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+	if report.Total != 4 || report.Created != 2 || report.Invited != 1 || report.Failed != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	for _, rowErr := range report.Errors {
+		if !strings.HasPrefix(rowErr.Error, "invalid JSON") {
+			t.Errorf("row %d error = %q, want invalid JSON", rowErr.Row, rowErr.Error)
+		}
+	}
+
+	invited, err := env.userRepo.FindByEmail(ctx, "jane@example.com")
+	if err != nil || !invited.MustChangePassword {
+		t.Fatalf("invited user = %+v, %v; want a password change required", invited, err)
+	}
+	if mails := env.mailer.sent("jane@example.com"); len(mails) != 1 || !strings.Contains(mails[0].body, "http://app/set-password?token=") {
+		t.Fatalf("mails to the invited user = %+v", mails)
+	}
+	if mails := env.mailer.sent("john@example.com"); len(mails) != 0 {
+		t.Fatalf("a user imported with a password was mailed: %+v", mails)
+	}
+}
+
+func TestImportUsersRejectsAdminsForOrgAdmins(t *testing.T) {
+	env := newTestEnv(t)
+	orgAdmin := env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin)
+
+	file := "name,email,password,role\nRoot User,root@example.com,correct horse battery,admin\n"
+	report, err := env.bulkService.ImportUsers(env.callerContext(orgAdmin), strings.NewReader(file), models.UserImportOptions{Format: models.UserFormatCSV})
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+	if report.Created != 0 || len(report.Errors) != 1 || report.Errors[0].Error != "only super-admins can grant the admin role" {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestImportUsersRejectsBadFiles(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+
+	for name, test := range map[string]struct{ format, file, want string }{
+		"unknown format": {"xml", "", "invalid import file: format must be csv or ndjson"},
+		"empty csv":      {models.UserFormatCSV, "", "invalid import file: missing CSV header"},
+		"unknown column": {models.UserFormatCSV, "name,email,age\n", `invalid import file: unknown column "age"`},
+		"missing column": {models.UserFormatCSV, "name,password\n", `invalid import file: missing column "email"`},
+		"long line":      {models.UserFormatNDJSON, strings.Repeat("x", maxImportLineSize+1), "invalid import file: line too long"},
+	} {
+		_, err := env.bulkService.ImportUsers(ctx, strings.NewReader(test.file), models.UserImportOptions{Format: test.format})
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%s: err = %v, want %q", name, err, test.want)
+		}
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	env.createUser("jane@example.com", "user", models.OrgRoleMember)
+	env.createUser("root@example.com", "admin", models.OrgRoleOwner)
+	deleted := env.createUser("gone@example.com", "user", models.OrgRoleMember)
+	if err := env.userService.DeleteUser(ctx, deleted.ID.Hex()); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	var out bytes.Buffer
+	written, err := env.bulkService.ExportUsers(ctx, &out, models.UserFormatCSV, models.UserFilter{}, nil)
+	if err != nil || written != 2 {
+		t.Fatalf("ExportUsers csv = %d, %v", written, err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(records) != 3 || !reflect.DeepEqual(records[0], models.UserExportColumns) {
+		t.Fatalf("csv export = %v, %v", records, err)
+	}
+
+	out.Reset()
+	written, err = env.bulkService.ExportUsers(ctx, &out, models.UserFormatNDJSON, models.UserFilter{Role: "admin"}, nil)
+	if err != nil || written != 1 {
+		t.Fatalf("ExportUsers ndjson = %d, %v", written, err)
+	}
+	var exported models.UserResponse
+	if err := json.Unmarshal(out.Bytes(), &exported); err != nil || exported.Email != "root@example.com" {
+		t.Fatalf("ndjson export = %s, %v", out.Bytes(), err)
+	}
+	if strings.Contains(out.String(), `"password"`) {
+		t.Fatalf("export leaks passwords: %s", out.Bytes())
+	}
+
+	out.Reset()
+	if written, _ := env.bulkService.ExportUsers(ctx, &out, models.UserFormatCSV, models.UserFilter{IncludeDeleted: true}, nil); written != 3 {
+		t.Fatalf("export including deleted users wrote %d users, want 3", written)
+	}
+
+	if _, err := env.bulkService.ExportUsers(context.Background(), &out, "xml", models.UserFilter{}, nil); err == nil {
+		t.Fatal("ExportUsers accepted an unknown format")
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PurgeDeletedUsers permanently removes users soft-deleted longer than retention ago
+func (s *UserService) PurgeDeletedUsers(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.userRepo.PurgeDeleted(ctx, time.Now().Add(-retention))
+}
+
+// StartDeletedUserPurger runs PurgeDeletedUsers every interval until ctx is cancelled.
+// Purging is idempotent, so it is safe to run on every replica.
+func (s *UserService) StartDeletedUserPurger(ctx context.Context, retention, interval time.Duration) {
+	if retention <= 0 || interval <= 0 {
+		log.Println("Deleted user purger disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purgeCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			purged, err := s.PurgeDeletedUsers(purgeCtx, retention)
+			cancel()
+
+			if err != nil {
+				log.Printf("Failed to purge deleted users: %v", err)
+			} else if purged > 0 {
+				log.Printf("🧹 Purged %d deleted users", purged)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
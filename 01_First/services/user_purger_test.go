@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-management-system/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDeleteAndRestoreUser(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	if err := env.userService.DeleteUser(ctx, user.ID.Hex()); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := env.userService.GetUserByID(ctx, user.ID.Hex()); err == nil {
+		t.Fatal("deleted user is still found by ID")
+	}
+	if _, err := env.userService.Login(ctx, &models.LoginRequest{Email: user.Email, Password: testPassword}); err == nil {
+		t.Fatal("deleted user can still log in")
+	}
+
+	// The email stays taken until the user is purged
+	_, err := env.userService.Register(ctx, &models.RegisterRequest{Name: "Other", Email: user.Email, Password: testPassword})
+	if err == nil || err.Error() != "email already registered" {
+		t.Fatalf("Register with a deleted user's email: err = %v", err)
+	}
+
+	users, _, total, err := env.userService.SearchUsers(ctx, models.UserFilter{IncludeDeleted: true}, 1, 10)
+	if err != nil || total != 1 || users[0].ID != user.ID.Hex() {
+		t.Fatalf("SearchUsers including deleted = %v, %d, %v", users, total, err)
+	}
+	if _, _, total, _ := env.userService.SearchUsers(ctx, models.UserFilter{}, 1, 10); total != 0 {
+		t.Fatalf("SearchUsers lists %d deleted users", total)
+	}
+
+	restored, err := env.userService.RestoreUser(ctx, user.ID.Hex())
+	if err != nil || restored.ID != user.ID.Hex() {
+		t.Fatalf("RestoreUser = %v, %v", restored, err)
+	}
+	if _, err := env.userService.Login(ctx, &models.LoginRequest{Email: user.Email, Password: testPassword}); err != nil {
+		t.Fatalf("Login after restore: %v", err)
+	}
+	if _, err := env.userService.RestoreUser(ctx, user.ID.Hex()); err == nil || err.Error() != "deleted user not found" {
+		t.Fatalf("restoring a user who is not deleted: err = %v", err)
+	}
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	old := env.createUser("old@example.com", "user", models.OrgRoleMember)
+	recent := env.createUser("recent@example.com", "user", models.OrgRoleMember)
+	kept := env.createUser("kept@example.com", "user", models.OrgRoleMember)
+
+	for _, user := range []*models.User{old, recent} {
+		if err := env.userService.DeleteUser(ctx, user.ID.Hex()); err != nil {
+			t.Fatalf("DeleteUser: %v", err)
+		}
+	}
+	_, err := env.db.Collection("users").UpdateByID(context.Background(), old.ID, bson.M{"$set": bson.M{"deletedAt": time.Now().Add(-48 * time.Hour)}})
+	if err != nil {
+		t.Fatalf("backdating deletion: %v", err)
+	}
+
+	purged, err := env.userService.PurgeDeletedUsers(ctx, 24*time.Hour)
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeDeletedUsers = %d, %v; want 1", purged, err)
+	}
+
+	if _, err := env.userService.RestoreUser(ctx, old.ID.Hex()); err == nil {
+		t.Fatal("purged user was restored")
+	}
+	if _, err := env.userService.RestoreUser(ctx, recent.ID.Hex()); err != nil {
+		t.Fatalf("user deleted within the retention was purged: %v", err)
+	}
+	env.reload(kept)
+
+	// The purged user's email is free again
+	if _, err := env.userService.Register(ctx, &models.RegisterRequest{Name: "New Old", Email: old.Email, Password: testPassword}); err != nil {
+		t.Fatalf("Register with a purged user's email: %v", err)
+	}
+}
@@ -2,26 +2,49 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
+	"log"
 	"runtime"
 	"strings"
-	"sync"
 	"time"
 
+	"user-management-system/middleware"
 	"user-management-system/models"
 	"user-management-system/repositories"
-
-	"golang.org/x/crypto/bcrypt"
+	"user-management-system/tenant"
 )
 
 // UserService handles business logic for users
 type UserService struct {
-	userRepo *repositories.UserRepository
+	userRepo           *repositories.UserRepository
+	auditService       *AuditService
+	webhookService     *WebhookService
+	emailChangeService *EmailChangeService
+	passwordPolicy     *PasswordPolicy
+	passwordHasher     PasswordHasher
+	defaultOrgID       string
+	authenticators     []Authenticator
 }
 
-// NewUserService creates a new user service
-func NewUserService(userRepo *repositories.UserRepository) *UserService {
-	return &UserService{userRepo: userRepo}
+// NewUserService creates a new user service. Users created outside of any
+// organization, e.g. by self-registration or SCIM, join defaultOrgID. Email
+// changes made by signed-in users are confirmed through emailChangeService;
+// without one, as in the admin CLI, they apply directly. Every password set
+// through the service must pass passwordPolicy and is hashed with
+// passwordHasher. Logins are checked by a chain of authenticators: the local
+// passwords first, then authenticators in order.
+func NewUserService(userRepo *repositories.UserRepository, auditService *AuditService, webhookService *WebhookService, emailChangeService *EmailChangeService, passwordPolicy *PasswordPolicy, passwordHasher PasswordHasher, defaultOrgID string, authenticators ...Authenticator) *UserService {
+	return &UserService{
+		userRepo:           userRepo,
+		auditService:       auditService,
+		webhookService:     webhookService,
+		emailChangeService: emailChangeService,
+		passwordPolicy:     passwordPolicy,
+		passwordHasher:     passwordHasher,
+		defaultOrgID:       defaultOrgID,
+		authenticators:     append([]Authenticator{NewLocalAuthenticator()}, authenticators...),
+	}
 }
 
 // Register creates a new user account
@@ -31,40 +54,284 @@ func (s *UserService) Register(ctx context.Context, req *models.RegisterRequest)
 		return nil, err
 	}
 
+	user, err := s.createUser(ctx, req.Name, req.Email, req.Password, "user", models.OrgRoleMember, true) // Default role
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionUserRegister,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+	})
+	s.webhookService.Publish(ctx, models.WebhookEventUserCreated, user)
+
+	return user.ToUserResponse(), nil
+}
+
+// CreateUser creates a new user account with an explicit role and status (admin tooling)
+func (s *UserService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.UserResponse, error) {
+	// Validate input
+	if err := s.validateRegisterRequest(&models.RegisterRequest{
+		Name:     req.Name,
+		Email:    req.Email,
+		Password: req.Password,
+	}); err != nil {
+		return nil, err
+	}
+
+	role := req.Role
+	if role == "" {
+		role = "user"
+	}
+	if role != "user" && role != "admin" {
+		return nil, errors.New("role must be either user or admin")
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	user, err := s.createUser(ctx, req.Name, req.Email, req.Password, role, models.OrgRoleMember, isActive)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionUserCreate,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"role": {From: nil, To: role}},
+	})
+	s.webhookService.Publish(ctx, models.WebhookEventUserCreated, user)
+
+	return user.ToUserResponse(), nil
+}
+
+// createUser hashes the password and persists a new user with the given
+// platform role, in the organization ctx acts in with the given orgRole
+func (s *UserService) createUser(ctx context.Context, name, email, password, role, orgRole string, isActive bool) (*models.User, error) {
+	return s.insertUser(ctx, &models.User{Name: name, Email: email, Role: role, IsActive: isActive}, password, orgRole)
+}
+
+// insertUser completes user, which has its name, email, role and status set,
+// with the hashed password and a membership of the organization ctx acts in
+// with orgRole, and persists it
+func (s *UserService) insertUser(ctx context.Context, user *models.User, password, orgRole string) (*models.User, error) {
 	// Convert email to lowercase
-	email := strings.ToLower(strings.TrimSpace(req.Email))
+	email := strings.ToLower(strings.TrimSpace(user.Email))
 
-	// Check if user already exists
-	existingUser, _ := s.userRepo.FindByEmail(ctx, email)
+	if user.Role == "admin" && !canManagePlatformRoles(ctx) {
+		return nil, errors.New("only super-admins can grant the admin role")
+	}
+
+	if err := s.passwordPolicy.Check(password, user.Name, email); err != nil {
+		return nil, err
+	}
+
+	orgID, err := s.creationOrg(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if user already exists; emails are unique across organizations
+	existingUser, _ := s.userRepo.FindByEmail(tenant.AcrossOrgs(ctx), email)
 	if existingUser != nil {
 		return nil, errors.New("email already registered")
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.passwordHasher.Hash(password)
 	if err != nil {
 		return nil, errors.New("failed to hash password")
 	}
 
 	// Create user
+	now := time.Now()
+	user.Email = email
+	user.Password = hashedPassword
+	user.OrgID = orgID
+	user.Orgs = []models.OrgMembership{{OrgID: orgID, Role: orgRole}}
+	user.PasswordChangedAt = &now
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		if err.Error() == "email already exists" {
+			return nil, errors.New("email already registered")
+		}
+		return nil, errors.New("failed to create user")
+	}
+
+	return user, nil
+}
+
+// creationOrg returns the organization new users join: the one ctx acts in,
+// or the default organization outside of any
+func (s *UserService) creationOrg(ctx context.Context) (string, error) {
+	if orgID := tenant.OrgID(ctx); orgID != "" {
+		return orgID, nil
+	}
+	if tenant.IsAcrossOrgs(ctx) || s.defaultOrgID == "" {
+		return "", errors.New("organization is required")
+	}
+	return s.defaultOrgID, nil
+}
+
+// canManagePlatformRoles reports whether ctx may grant or revoke the platform
+// admin (super-admin) role: super-admins, trusted provisioners such as SCIM,
+// and system contexts without a user such as the startup bootstrap
+func canManagePlatformRoles(ctx context.Context) bool {
+	return middleware.GetUserID(ctx) == "" || middleware.IsSuperAdmin(ctx) || middleware.IsProvisioner(ctx)
+}
+
+// checkCanModify enforces the role hierarchy when the caller changes another
+// user: only super-admins modify super-admins, and organization admins modify
+// neither owners nor other admins. Super-admins, trusted provisioners and
+// system contexts are not limited.
+func checkCanModify(ctx context.Context, target *models.User) error {
+	callerID := middleware.GetUserID(ctx)
+	if callerID == "" || callerID == target.ID.Hex() || middleware.IsSuperAdmin(ctx) || middleware.IsProvisioner(ctx) {
+		return nil
+	}
+	if target.IsSuperAdmin() {
+		return errors.New("only super-admins can modify super-admins")
+	}
+	if middleware.GetOrgRole(ctx) != models.OrgRoleOwner {
+		switch target.OrgRole(tenant.OrgID(ctx)) {
+		case models.OrgRoleOwner, models.OrgRoleAdmin:
+			return errors.New("only owners can modify owners and admins")
+		}
+	}
+	return nil
+}
+
+// checkSelfUpdate refuses email and password changes to the caller's own
+// account outside /api/me, which asks for the current password first, so a
+// stolen token alone cannot take the account over. Sending the unchanged
+// email is allowed.
+func checkSelfUpdate(ctx context.Context, user *models.User, email string, password bool) error {
+	if user.ID.Hex() != middleware.GetUserID(ctx) {
+		return nil
+	}
+	if email != "" && strings.ToLower(strings.TrimSpace(email)) != user.Email {
+		return errors.New("email is changed with POST /api/me/email")
+	}
+	if password {
+		return errors.New("password is changed with POST /api/me/password")
+	}
+	return nil
+}
+
+// selfScope lets users edit their own account from whichever organization
+// they act in, as writes are otherwise limited to the home organization
+func selfScope(ctx context.Context, id string) context.Context {
+	if id != "" && id == middleware.GetUserID(ctx) {
+		return tenant.AcrossOrgs(ctx)
+	}
+	return ctx
+}
+
+// BootstrapAdmin creates the initial admin account when no admin exists yet.
+// It is safe to call on every start and from several replicas at once: the
+// unique email index lets exactly one insert win. When password is empty a
+// one-time password is generated and returned, and the admin must change it
+// on first login. The returned user is nil when nothing was created. It fails
+// when the email already belongs to a user who is not an admin.
+func (s *UserService) BootstrapAdmin(ctx context.Context, name, email, password string) (*models.User, string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, "", nil
+	}
+
+	// Nothing to do once any admin exists
+	admins, err := s.userRepo.CountByRole(ctx, "admin")
+	if err != nil {
+		return nil, "", err
+	}
+	if admins > 0 {
+		return nil, "", nil
+	}
+
+	// A regular user with the email would leave the system without an admin
+	if err := s.checkBootstrapEmail(ctx, email); err != nil {
+		return nil, "", err
+	}
+
+	generatedPassword := ""
+	if password == "" {
+		generatedPassword, err = generateRandomPassword()
+		if err != nil {
+			return nil, "", errors.New("failed to generate password")
+		}
+		password = generatedPassword
+	}
+
+	if err := s.validateRegisterRequest(&models.RegisterRequest{Name: name, Email: email, Password: password}); err != nil {
+		return nil, "", err
+	}
+	if err := s.passwordPolicy.Check(password, name, email); err != nil {
+		return nil, "", err
+	}
+
+	hashedPassword, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		return nil, "", errors.New("failed to hash password")
+	}
+
+	now := time.Now()
 	user := &models.User{
-		Name:      req.Name,
-		Email:     email,
-		Password:  string(hashedPassword),
-		Role:      "user", // Default role
-		IsActive:  true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		Name:               name,
+		Email:              email,
+		Password:           hashedPassword,
+		Role:               "admin",
+		IsActive:           true,
+		MustChangePassword: generatedPassword != "",
+		PasswordChangedAt:  &now,
+		OrgID:              s.defaultOrgID,
+		Orgs:               []models.OrgMembership{{OrgID: s.defaultOrgID, Role: models.OrgRoleOwner}},
 	}
 
 	if err := s.userRepo.Create(ctx, user); err != nil {
-		return nil, errors.New("failed to create user")
+		if err.Error() == "email already exists" {
+			// Another replica won the race, unless the email was taken meanwhile
+			return nil, "", s.checkBootstrapEmail(ctx, email)
+		}
+		return nil, "", err
 	}
 
-	return user.ToUserResponse(), nil
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionUserCreate,
+		ActorID:     "system:bootstrap",
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"role": {From: nil, To: "admin"}},
+	})
+	s.webhookService.Publish(ctx, models.WebhookEventUserCreated, user)
+
+	return user, generatedPassword, nil
 }
 
-// Login authenticates a user and returns user info
+// checkBootstrapEmail fails when the bootstrap admin email belongs to a user
+// who is not an admin, soft-deleted users included
+func (s *UserService) checkBootstrapEmail(ctx context.Context, email string) error {
+	existing, err := s.userRepo.FindByEmailIncludingDeleted(ctx, email)
+	if err != nil {
+		return nil
+	}
+	if existing.Role != "admin" {
+		return errors.New("ADMIN_EMAIL " + email + " belongs to an existing user who is not an admin; promote them with userctl set-role or choose another email")
+	}
+	return nil
+}
+
+// Login authenticates a user with the first directory of the authenticator
+// chain that accepts their password, starting with the local one, and returns
+// user info
 func (s *UserService) Login(ctx context.Context, req *models.LoginRequest) (*models.User, error) {
 	// Validate input
 	if req.Email == "" || req.Password == "" {
@@ -74,24 +341,196 @@ func (s *UserService) Login(ctx context.Context, req *models.LoginRequest) (*mod
 	// Convert email to lowercase
 	email := strings.ToLower(strings.TrimSpace(req.Email))
 
-	// Find user by email
+	// Find user by email; directory users have no local account before their first login
 	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		user = nil
+	}
+
+	if user != nil {
+		// Service accounts only act through API keys
+		if user.ServiceAccount {
+			s.recordLoginFailure(ctx, email, user, "service account")
+			return nil, errors.New("invalid email or password")
+		}
+
+		// Check if user is active
+		if !user.IsActive {
+			s.recordLoginFailure(ctx, email, user, "account deactivated")
+			return nil, errors.New("account is deactivated")
+		}
+	}
+
+	// Local accounts are never taken over by a directory account with the
+	// same email; a directory only signs in its own shadow users
+	for _, authenticator := range s.authenticators {
+		if authenticator.Name() == LocalDirectory {
+			if user == nil {
+				continue
+			}
+		} else if user != nil && user.Identity(authenticator.Name()) == nil {
+			continue
+		}
+
+		account, err := authenticator.Authenticate(ctx, email, req.Password, user)
+		if err != nil {
+			log.Printf("⚠️  %s authentication of %s failed: %v", authenticator.Name(), email, err)
+			continue
+		}
+		if account == nil {
+			continue
+		}
+		if authenticator.Name() == LocalDirectory {
+			return s.completeLocalLogin(ctx, user, req.Password)
+		}
+
+		shadow, err := s.syncShadowUser(ctx, authenticator.Name(), email, account, user)
+		if err != nil {
+			log.Printf("⚠️  Failed to sync %s user %s: %v", authenticator.Name(), email, err)
+			s.recordLoginFailure(ctx, email, user, "failed to sync "+authenticator.Name()+" user")
+			return nil, errors.New("invalid email or password")
+		}
+
+		s.recordLoginSuccess(ctx, shadow, "login via "+authenticator.Name())
+		return shadow, nil
+	}
+
+	if user == nil {
+		s.recordLoginFailure(ctx, email, nil, "unknown email")
+	} else {
+		s.recordLoginFailure(ctx, email, user, "invalid password")
+	}
+	return nil, errors.New("invalid email or password")
+}
+
+// completeLocalLogin finishes a login whose local password matched
+func (s *UserService) completeLocalLogin(ctx context.Context, user *models.User, password string) (*models.User, error) {
+	s.rehashPassword(ctx, user, password)
+
+	// Force a password change before issuing tokens
+	if user.MustChangePassword {
+		s.recordLoginFailure(ctx, user.Email, user, "password change required")
+		return nil, errors.New("password change required")
+	}
+	if s.passwordPolicy.Expired(user, time.Now()) {
+		s.recordLoginFailure(ctx, user.Email, user, "password expired")
+		return nil, errors.New("password expired")
+	}
+
+	s.recordLoginSuccess(ctx, user, "")
+	return user, nil
+}
+
+// recordLoginSuccess writes a successful login audit event
+func (s *UserService) recordLoginSuccess(ctx context.Context, user *models.User, reason string) {
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionLoginSuccess,
+		ActorID:     user.ID.Hex(),
+		ActorEmail:  user.Email,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Reason:      reason,
+	})
+}
+
+// rehashPassword moves a verified password to the preferred algorithm and
+// parameters when its hash uses others. Failures are logged and the old hash
+// keeps working.
+func (s *UserService) rehashPassword(ctx context.Context, user *models.User, password string) {
+	if s.passwordHasher.Current(user.Password) {
+		return
+	}
+
+	hashedPassword, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		log.Printf("⚠️  Failed to rehash password of user %s: %v", user.ID.Hex(), err)
+		return
+	}
+	if err := s.userRepo.ReplacePasswordHash(ctx, user.ID.Hex(), user.Password, hashedPassword); err != nil {
+		log.Printf("⚠️  Failed to store rehashed password of user %s: %v", user.ID.Hex(), err)
+		return
+	}
+	user.Password = hashedPassword
+}
+
+// recordLoginFailure writes a failed login audit event; user is nil for unknown emails
+func (s *UserService) recordLoginFailure(ctx context.Context, email string, user *models.User, reason string) {
+	event := &models.AuditEvent{
+		Action:      models.AuditActionLoginFailure,
+		TargetEmail: email,
+		Success:     false,
+		Reason:      reason,
+	}
+	if user != nil {
+		event.TargetID = user.ID.Hex()
+	}
+	s.auditService.Record(ctx, event)
+}
+
+// ChangePassword verifies the current password and replaces it with a new one.
+// It also clears a pending forced password change, so it works before login.
+func (s *UserService) ChangePassword(ctx context.Context, req *models.ChangePasswordRequest) (*models.User, error) {
+	if req.Email == "" || req.CurrentPassword == "" {
+		return nil, errors.New("email and current password are required")
+	}
+
+	if err := checkNewPassword(req.CurrentPassword, req.NewPassword); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, errors.New("invalid email or password")
 	}
 
-	// Check if user is active
 	if !user.IsActive {
 		return nil, errors.New("account is deactivated")
 	}
 
-	// Verify password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
-	if err != nil {
+	if err := verifyPassword(user.Password, req.CurrentPassword); err != nil {
 		return nil, errors.New("invalid email or password")
 	}
 
-	return user, nil
+	return s.replacePassword(ctx, user, req.NewPassword)
+}
+
+// checkNewPassword validates a new password against the current one; the
+// password policy is checked once the user is known
+func checkNewPassword(current, next string) error {
+	if next == "" {
+		return errors.New("new password is required")
+	}
+	if next == current {
+		return errors.New("new password must be different from the current password")
+	}
+	return nil
+}
+
+// replacePassword stores a new password for a user whose current password
+// was verified, clears a pending forced change and audits it
+func (s *UserService) replacePassword(ctx context.Context, user *models.User, password string) (*models.User, error) {
+	updateData, err := passwordFields(s.passwordPolicy, s.passwordHasher, user, password)
+	if err != nil {
+		return nil, err
+	}
+
+	updateData["mustChangePassword"] = false
+	if err := s.userRepo.Update(ctx, user.ID.Hex(), updateData); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionPasswordChange,
+		ActorID:     user.ID.Hex(),
+		ActorEmail:  user.Email,
+		TargetID:    user.ID.Hex(),
+		TargetEmail: user.Email,
+		Success:     true,
+		Changes:     map[string]models.AuditChange{"password": {From: "[redacted]", To: "[redacted]"}},
+	})
+
+	return s.userRepo.FindByID(ctx, user.ID.Hex())
 }
 
 // GetUserByID retrieves a user by ID
@@ -104,8 +543,32 @@ func (s *UserService) GetUserByID(ctx context.Context, id string) (*models.UserR
 	return user.ToUserResponse(), nil
 }
 
+// GetUserByEmail retrieves a user by email
+func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.UserResponse, error) {
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.ToUserResponse(), nil
+}
+
 // UpdateUser updates user information
 func (s *UserService) UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.UserResponse, error) {
+	return s.UpdateUserIfMatch(ctx, id, req, nil)
+}
+
+// UpdateUserIfMatch updates user information. When ifMatch is set the update
+// only succeeds if the user is still at that version.
+func (s *UserService) UpdateUserIfMatch(ctx context.Context, id string, req *models.UpdateUserRequest, ifMatch *int64) (*models.UserResponse, error) {
+	ctx = selfScope(ctx, id)
+
+	// Load current state for the audit diff
+	before, err := s.loadForUpdate(ctx, id, ifMatch)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build update data
 	updateData := make(map[string]interface{})
 
@@ -113,25 +576,33 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, req *models.Upd
 		updateData["name"] = req.Name
 	}
 
+	if err := checkSelfUpdate(ctx, before, req.Email, req.Password != ""); err != nil {
+		return nil, err
+	}
+
+	var email string
 	if req.Email != "" {
 		// Convert email to lowercase
-		email := strings.ToLower(strings.TrimSpace(req.Email))
+		email = strings.ToLower(strings.TrimSpace(req.Email))
 
 		// Check if email is already taken by another user
-		existingUser, _ := s.userRepo.FindByEmail(ctx, email)
-		if existingUser != nil && existingUser.ID.Hex() != id {
-			return nil, errors.New("email already in use")
+		if err := checkEmailAvailable(ctx, s.userRepo, id, email); err != nil {
+			return nil, err
+		}
+		if email == before.Email {
+			email = ""
 		}
-		updateData["email"] = email
 	}
 
 	if req.Password != "" {
-		// Hash new password
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		// Check and hash the new password, keeping the history
+		fields, err := passwordFields(s.passwordPolicy, s.passwordHasher, before, req.Password)
 		if err != nil {
-			return nil, errors.New("failed to hash password")
+			return nil, err
+		}
+		for field, value := range fields {
+			updateData[field] = value
 		}
-		updateData["password"] = string(hashedPassword)
 	}
 
 	if req.Role != "" {
@@ -142,27 +613,242 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, req *models.Upd
 		updateData["isActive"] = *req.IsActive
 	}
 
-	// Update user
-	if err := s.userRepo.Update(ctx, id, updateData); err != nil {
+	return s.applyUpdateAndEmail(ctx, before, updateData, email)
+}
+
+// PatchUser applies an RFC 7396 JSON merge patch. Members set to null are
+// removed; role falls back to "user" and the other attributes are required.
+// When ifMatch is set the patch only applies to that version.
+func (s *UserService) PatchUser(ctx context.Context, id string, patch map[string]interface{}, ifMatch *int64) (*models.UserResponse, error) {
+	ctx = selfScope(ctx, id)
+
+	before, err := s.loadForUpdate(ctx, id, ifMatch)
+	if err != nil {
 		return nil, err
 	}
 
-	// Fetch updated user
+	updateData := make(map[string]interface{})
+	var newEmail string
+
+	for field, value := range patch {
+		// null removes a member; only role has a default to fall back to
+		if value == nil {
+			switch field {
+			case "role":
+				updateData["role"] = "user"
+				continue
+			case "name", "email", "password", "isActive":
+				return nil, errors.New(field + " cannot be removed")
+			}
+		}
+
+		switch field {
+		case "name":
+			name, ok := value.(string)
+			if !ok {
+				return nil, errors.New("name must be a string")
+			}
+			name = strings.TrimSpace(name)
+			if len(name) < 2 || len(name) > 100 {
+				return nil, errors.New("name must be between 2 and 100 characters")
+			}
+			updateData["name"] = name
+
+		case "email":
+			email, ok := value.(string)
+			if !ok {
+				return nil, errors.New("email must be a string")
+			}
+			if err := checkSelfUpdate(ctx, before, email, false); err != nil {
+				return nil, err
+			}
+			email = strings.ToLower(strings.TrimSpace(email))
+			if !strings.Contains(email, "@") {
+				return nil, errors.New("email must be a valid email address")
+			}
+			if err := checkEmailAvailable(ctx, s.userRepo, id, email); err != nil {
+				return nil, err
+			}
+			if email != before.Email {
+				newEmail = email
+			}
+
+		case "password":
+			password, ok := value.(string)
+			if !ok {
+				return nil, errors.New("password must be a string")
+			}
+			if err := checkSelfUpdate(ctx, before, "", true); err != nil {
+				return nil, err
+			}
+			fields, err := passwordFields(s.passwordPolicy, s.passwordHasher, before, password)
+			if err != nil {
+				return nil, err
+			}
+			for field, value := range fields {
+				updateData[field] = value
+			}
+
+		case "role":
+			role, _ := value.(string)
+			if role != "user" && role != "admin" {
+				return nil, errors.New("role must be either user or admin")
+			}
+			updateData["role"] = role
+
+		case "isActive":
+			isActive, ok := value.(bool)
+			if !ok {
+				return nil, errors.New("isActive must be a boolean")
+			}
+			updateData["isActive"] = isActive
+
+		case "id", "version", "createdAt", "updatedAt", "deletedAt", "mustChangePassword", "pendingEmail", "serviceAccount":
+			return nil, errors.New(field + " is read-only")
+
+		default:
+			return nil, errors.New("unknown field " + field)
+		}
+	}
+
+	if len(updateData) == 0 && newEmail == "" {
+		return before.ToUserResponse(), nil
+	}
+
+	return s.applyUpdateAndEmail(ctx, before, updateData, newEmail)
+}
+
+// loadForUpdate loads a user the caller may modify and checks the If-Match
+// precondition
+func (s *UserService) loadForUpdate(ctx context.Context, id string, ifMatch *int64) (*models.User, error) {
 	user, err := s.userRepo.FindByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkCanModify(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if ifMatch != nil && *ifMatch != user.Version {
+		return nil, errors.New("version conflict")
+	}
+
+	return user, nil
+}
 
+// applyUpdateAndEmail applies updateData and, when email is set, a new
+// email. Trusted provisioners such as SCIM and userctl, and system contexts,
+// apply email changes directly; for anyone else they only become pending
+// until the new address is confirmed. Users never get here for their own
+// email, which is changed with the current password through /api/me.
+func (s *UserService) applyUpdateAndEmail(ctx context.Context, before *models.User, updateData map[string]interface{}, email string) (*models.UserResponse, error) {
+	if email == "" || s.emailChangeService == nil || middleware.GetUserID(ctx) == "" || middleware.IsProvisioner(ctx) {
+		if email != "" {
+			updateData["email"] = email
+		}
+		return s.applyUpdate(ctx, before, updateData)
+	}
+
+	if len(updateData) > 0 {
+		if _, err := s.applyUpdate(ctx, before, updateData); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := s.emailChangeService.Request(ctx, before, email)
+	if err != nil {
+		return nil, err
+	}
 	return user.ToUserResponse(), nil
 }
 
-// DeleteUser removes a user from the system
+// applyUpdate writes the update conditional on the version that was read, so
+// a concurrent edit in between makes it fail with "version conflict" instead
+// of being silently overwritten. Successful changes are audited and published.
+func (s *UserService) applyUpdate(ctx context.Context, before *models.User, updateData map[string]interface{}) (*models.UserResponse, error) {
+	id := before.ID.Hex()
+
+	if role, ok := updateData["role"]; ok && role != before.Role && !canManagePlatformRoles(ctx) {
+		return nil, errors.New("only super-admins can change the role")
+	}
+
+	user, err := s.userRepo.UpdateIfVersion(ctx, id, before.Version, updateData)
+	if err != nil {
+		return nil, err
+	}
+
+	if changes := userChanges(before, user); len(changes) > 0 {
+		s.auditService.Record(ctx, &models.AuditEvent{
+			Action:      models.AuditActionUserUpdate,
+			TargetID:    id,
+			TargetEmail: user.Email,
+			Success:     true,
+			Changes:     changes,
+		})
+		s.webhookService.Publish(ctx, models.WebhookEventUserUpdated, user)
+		if before.IsActive && !user.IsActive {
+			s.webhookService.Publish(ctx, models.WebhookEventUserDeactivated, user)
+		}
+	}
+
+	return user.ToUserResponse(), nil
+}
+
+// DeleteUser soft-deletes a user; it can be restored until purged
 func (s *UserService) DeleteUser(ctx context.Context, id string) error {
-	return s.userRepo.Delete(ctx, id)
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkCanModify(ctx, user); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionUserDelete,
+		TargetID:    id,
+		TargetEmail: user.Email,
+		Success:     true,
+	})
+	s.webhookService.Publish(ctx, models.WebhookEventUserDeleted, user)
+
+	return nil
+}
+
+// RestoreUser restores a soft-deleted user
+func (s *UserService) RestoreUser(ctx context.Context, id string) (*models.UserResponse, error) {
+	if err := s.userRepo.Restore(ctx, id); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &models.AuditEvent{
+		Action:      models.AuditActionUserRestore,
+		TargetID:    id,
+		TargetEmail: user.Email,
+		Success:     true,
+	})
+	// Restored users are announced as updated with their current state
+	s.webhookService.Publish(ctx, models.WebhookEventUserUpdated, user)
+
+	return user.ToUserResponse(), nil
 }
 
 // GetAllUsers retrieves all users with pagination
 func (s *UserService) GetAllUsers(ctx context.Context, page, limit int) ([]*models.UserResponse, int, int64, error) {
+	return s.SearchUsers(ctx, models.UserFilter{}, page, limit)
+}
+
+// SearchUsers retrieves users matching the filter with pagination
+func (s *UserService) SearchUsers(ctx context.Context, filter models.UserFilter, page, limit int) ([]*models.UserResponse, int, int64, error) {
 	// Validate pagination parameters
 	if page < 1 {
 		page = 1
@@ -174,7 +860,7 @@ func (s *UserService) GetAllUsers(ctx context.Context, page, limit int) ([]*mode
 		limit = 100 // Max limit
 	}
 
-	users, total, err := s.userRepo.FindAll(ctx, page, limit)
+	users, total, err := s.userRepo.FindByFilter(ctx, filter, page, limit)
 	if err != nil {
 		return nil, 0, 0, err
 	}
@@ -213,9 +899,10 @@ func (s *UserService) convertUsersWithWorkerPool(ctx context.Context, users []*m
 		return []*models.UserResponse{}
 	}
 
+	userResponses := make([]*models.UserResponse, len(users))
+
 	// For small datasets, sequential processing is faster due to goroutine overhead
 	if len(users) < 100 {
-		userResponses := make([]*models.UserResponse, len(users))
 		for i, user := range users {
 			userResponses[i] = user.ToUserResponse()
 		}
@@ -224,96 +911,19 @@ func (s *UserService) convertUsersWithWorkerPool(ctx context.Context, users []*m
 
 	// Determine optimal number of workers
 	numWorkers := runtime.NumCPU() * 3 // Use 3x CPU cores for better parallelism
-	if numWorkers > len(users) {
-		numWorkers = len(users)
-	}
 	if numWorkers > 100 {
 		numWorkers = 100 // Cap at 100 workers for very large datasets
 	}
 
-	// Create channels for worker pool pattern
-	userChan := make(chan *userConversionJob, numWorkers*2) // Buffered channel
-	resultChan := make(chan *userConversionResult, len(users))
-
-	// Start worker pool
-	var wg sync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for job := range userChan {
-				// Check context cancellation
-				select {
-				case <-ctx.Done():
-					return
-				default:
-				}
-
-				// Convert user to response
-				response := job.user.ToUserResponse()
-				resultChan <- &userConversionResult{
-					index:    job.index,
-					response: response,
-				}
-			}
-		}(i)
-	}
-
-	// Send all users to worker pool
-	go func() {
-		defer close(userChan)
-		for i, user := range users {
-			select {
-			case <-ctx.Done():
-				return
-			case userChan <- &userConversionJob{
-				index: i,
-				user:  user,
-			}:
-			}
-		}
-	}()
-
-	// Close result channel when all workers are done
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
-
-	// Collect results and maintain order
-	userResponses := make([]*models.UserResponse, len(users))
-	completed := 0
-
-	for result := range resultChan {
-		if result.index >= 0 && result.index < len(userResponses) {
-			userResponses[result.index] = result.response
-			completed++
-		}
-	}
-
-	// Check if context was cancelled
-	select {
-	case <-ctx.Done():
-		// Return partial results if cancelled
-		return userResponses
-	default:
-	}
+	// Results keep their order because each worker writes its own index.
+	// If ctx is cancelled the partial results are returned.
+	forEachParallel(ctx, len(users), numWorkers, func(i int) {
+		userResponses[i] = users[i].ToUserResponse()
+	})
 
 	return userResponses
 }
 
-// userConversionJob represents a job for worker pool
-type userConversionJob struct {
-	index int
-	user  *models.User
-}
-
-// userConversionResult represents the result of user conversion
-type userConversionResult struct {
-	index    int
-	response *models.UserResponse
-}
-
 // validateRegisterRequest validates registration input
 func (s *UserService) validateRegisterRequest(req *models.RegisterRequest) error {
 	if req.Name == "" || len(req.Name) < 2 {
@@ -324,9 +934,34 @@ func (s *UserService) validateRegisterRequest(req *models.RegisterRequest) error
 		return errors.New("email is required")
 	}
 
-	if req.Password == "" || len(req.Password) < 6 {
-		return errors.New("password must be at least 6 characters")
+	if req.Password == "" {
+		return errors.New("password is required")
 	}
 
 	return nil
 }
+
+// generatedPasswordAlphabet has every character class the password policy
+// can require, without look-alike characters. Its 64 characters keep the
+// mapping from random bytes unbiased.
+const generatedPasswordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789-_.!@#$"
+
+// generateRandomPassword returns a random 32-character password that has an
+// uppercase and a lowercase letter, a digit and a symbol
+func generateRandomPassword() (string, error) {
+	for {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for i, b := range buf {
+			buf[i] = generatedPasswordAlphabet[int(b)%len(generatedPasswordAlphabet)]
+		}
+
+		password := string(buf)
+		if strings.ContainsAny(password, "ABCDEFGHJKLMNPQRSTUVWXYZ") && strings.ContainsAny(password, "abcdefghijkmnopqrstuvwxyz") &&
+			strings.ContainsAny(password, "23456789") && strings.ContainsAny(password, "-_.!@#$") {
+			return password, nil
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"user-management-system/models"
+)
+
+func TestBootstrapAdmin(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+
+	admin, generated, err := env.userService.BootstrapAdmin(ctx, "Root", "Root@Example.com", "")
+	if err != nil {
+		t.Fatalf("BootstrapAdmin: %v", err)
+	}
+	if admin == nil || generated == "" {
+		t.Fatalf("BootstrapAdmin = %v, %q; want a new admin with a generated password", admin, generated)
+	}
+	if !admin.IsSuperAdmin() || !admin.MustChangePassword || admin.OrgRole(env.defaultOrg.ID.Hex()) != models.OrgRoleOwner {
+		t.Fatalf("bootstrapped admin = %+v", admin)
+	}
+
+	if _, err := env.userService.Login(ctx, &models.LoginRequest{Email: "root@example.com", Password: generated}); err == nil || err.Error() != "password change required" {
+		t.Fatalf("Login with the one-time password: err = %v, want password change required", err)
+	}
+
+	// Once an admin exists, later starts do nothing
+	again, _, err := env.userService.BootstrapAdmin(ctx, "Root", "other@example.com", testPassword)
+	if err != nil || again != nil {
+		t.Fatalf("second BootstrapAdmin = %v, %v; want nothing created", again, err)
+	}
+}
+
+func TestBootstrapAdminRefusesNonAdminEmail(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	env.createUser("taken@example.com", "user", models.OrgRoleMember)
+
+	admin, _, err := env.userService.BootstrapAdmin(ctx, "Root", "taken@example.com", testPassword)
+	if err == nil || !strings.Contains(err.Error(), "not an admin") {
+		t.Fatalf("BootstrapAdmin = %v, %v; want an error", admin, err)
+	}
+
+	admins, err := env.userRepo.CountByRole(ctx, "admin")
+	if err != nil || admins != 0 {
+		t.Fatalf("admins = %d, %v", admins, err)
+	}
+}
+
+func TestBootstrapAdminRefusesSoftDeletedUserEmail(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	user := env.createUser("deleted@example.com", "user", models.OrgRoleMember)
+	if err := env.userService.DeleteUser(systemContext(), user.ID.Hex()); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	if _, _, err := env.userService.BootstrapAdmin(ctx, "Root", "deleted@example.com", testPassword); err == nil {
+		t.Fatal("BootstrapAdmin succeeded with the email of a soft-deleted user")
+	}
+}
+
+func TestPatchUserMergeSemantics(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	user := env.createUser("jane@example.com", "admin", models.OrgRoleMember)
+	version := user.Version
+
+	patched, err := env.userService.PatchUser(ctx, user.ID.Hex(), map[string]interface{}{"name": "Jane Roe", "role": nil}, nil)
+	if err != nil {
+		t.Fatalf("PatchUser: %v", err)
+	}
+	if patched.Name != "Jane Roe" || patched.Role != "user" || patched.Email != user.Email {
+		t.Fatalf("patched user = %+v; want the name set, the role reset and the rest untouched", patched)
+	}
+	if patched.Version != version+1 {
+		t.Fatalf("version = %d, want %d", patched.Version, version+1)
+	}
+
+	// An empty patch changes nothing and keeps the version
+	unchanged, err := env.userService.PatchUser(ctx, user.ID.Hex(), map[string]interface{}{}, nil)
+	if err != nil || unchanged.Version != patched.Version {
+		t.Fatalf("empty patch = %+v, %v", unchanged, err)
+	}
+
+	for want, patch := range map[string]map[string]interface{}{
+		"name cannot be removed":            {"name": nil},
+		"email cannot be removed":           {"email": nil},
+		"version is read-only":              {"version": 7},
+		"unknown field nickname":            {"nickname": "jr"},
+		"isActive must be a boolean":        {"isActive": "yes"},
+		"role must be either user or admin": {"role": "owner"},
+	} {
+		if _, err := env.userService.PatchUser(ctx, user.ID.Hex(), patch, nil); err == nil || err.Error() != want {
+			t.Errorf("PatchUser(%v): err = %v, want %q", patch, err, want)
+		}
+	}
+	if reloaded := env.reload(user); reloaded.Version != patched.Version {
+		t.Fatalf("rejected patches changed the user: version %d", reloaded.Version)
+	}
+}
+
+func TestUpdateUserIfMatch(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+	stale := user.Version
+
+	updated, err := env.userService.UpdateUserIfMatch(ctx, user.ID.Hex(), &models.UpdateUserRequest{Name: "Jane Roe"}, &stale)
+	if err != nil || updated.Version != stale+1 {
+		t.Fatalf("UpdateUserIfMatch with the current version = %+v, %v", updated, err)
+	}
+
+	if _, err := env.userService.UpdateUserIfMatch(ctx, user.ID.Hex(), &models.UpdateUserRequest{Name: "Jane Poe"}, &stale); err == nil || err.Error() != "version conflict" {
+		t.Fatalf("UpdateUserIfMatch with a stale version: err = %v", err)
+	}
+	if _, err := env.userService.PatchUser(ctx, user.ID.Hex(), map[string]interface{}{"name": "Jane Poe"}, &stale); err == nil || err.Error() != "version conflict" {
+		t.Fatalf("PatchUser with a stale version: err = %v", err)
+	}
+	if reloaded := env.reload(user); reloaded.Name != "Jane Roe" {
+		t.Fatalf("name = %q after rejected updates", reloaded.Name)
+	}
+}
+
+func TestConcurrentUpdatesCannotBothSucceed(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	user := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	// Both writers read the same version before either writes
+	first, second := env.reload(user), env.reload(user)
+	if _, err := env.userService.applyUpdate(ctx, first, map[string]interface{}{"name": "First Writer"}); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	if _, err := env.userService.applyUpdate(ctx, second, map[string]interface{}{"name": "Second Writer"}); err == nil || err.Error() != "version conflict" {
+		t.Fatalf("second update: err = %v, want version conflict", err)
+	}
+	if reloaded := env.reload(user); reloaded.Name != "First Writer" {
+		t.Fatalf("name = %q, want the first write kept", reloaded.Name)
+	}
+}
+
+func TestRoleHierarchyLimitsModifications(t *testing.T) {
+	env := newTestEnv(t)
+	superAdmin := env.createUser("root@example.com", "admin", models.OrgRoleMember)
+	owner := env.createUser("owner@example.com", "user", models.OrgRoleOwner)
+	orgAdmin := env.createUser("orgadmin@example.com", "user", models.OrgRoleAdmin)
+	otherAdmin := env.createUser("otheradmin@example.com", "user", models.OrgRoleAdmin)
+	member := env.createUser("member@example.com", "user", models.OrgRoleMember)
+
+	rename := &models.UpdateUserRequest{Name: "Renamed"}
+	for _, test := range []struct {
+		caller, target *models.User
+		want           string
+	}{
+		{orgAdmin, superAdmin, "only super-admins can modify super-admins"},
+		{owner, superAdmin, "only super-admins can modify super-admins"},
+		{orgAdmin, owner, "only owners can modify owners and admins"},
+		{orgAdmin, otherAdmin, "only owners can modify owners and admins"},
+		{orgAdmin, member, ""},
+		{orgAdmin, orgAdmin, ""},
+		{owner, otherAdmin, ""},
+		{superAdmin, owner, ""},
+	} {
+		ctx := env.callerContext(test.caller)
+		id := test.target.ID.Hex()
+
+		_, updateErr := env.userService.UpdateUser(ctx, id, rename)
+		_, patchErr := env.userService.PatchUser(ctx, id, map[string]interface{}{"isActive": true}, nil)
+		for _, err := range []error{updateErr, patchErr} {
+			if (err == nil) != (test.want == "") || (err != nil && err.Error() != test.want) {
+				t.Errorf("%s modifying %s: err = %v, want %q", test.caller.Email, test.target.Email, err, test.want)
+			}
+		}
+	}
+
+	if err := env.userService.DeleteUser(env.callerContext(orgAdmin), otherAdmin.ID.Hex()); err == nil || err.Error() != "only owners can modify owners and admins" {
+		t.Fatalf("org admin deleting another admin: err = %v", err)
+	}
+	if err := env.userService.DeleteUser(env.callerContext(owner), superAdmin.ID.Hex()); err == nil || err.Error() != "only super-admins can modify super-admins" {
+		t.Fatalf("owner deleting a super-admin: err = %v", err)
+	}
+	if err := env.userService.DeleteUser(env.callerContext(owner), otherAdmin.ID.Hex()); err != nil {
+		t.Fatalf("owner deleting an admin: %v", err)
+	}
+}
+
+func TestAdminEmailChangeIsConfirmed(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin@example.com", "admin", models.OrgRoleOwner)
+	jane := env.createUser("jane@example.com", "user", models.OrgRoleMember)
+
+	updated, err := env.userService.UpdateUser(env.callerContext(admin), jane.ID.Hex(), &models.UpdateUserRequest{Email: "jane.doe@example.com"})
+	if err != nil || updated.Email != jane.Email || updated.PendingEmail != "jane.doe@example.com" {
+		t.Fatalf("admin changing jane's email = %+v, %v; want it pending", updated, err)
+	}
+	env.mailer.token(t, "jane.doe@example.com", "http://app/confirm-email")
+	if mails := env.mailer.sent(jane.Email); len(mails) != 1 {
+		t.Fatalf("mails to the old address = %+v, want a notice", mails)
+	}
+
+	// System contexts without a user, such as the bootstrap, apply it at once
+	updated, err = env.userService.UpdateUser(systemContext(), jane.ID.Hex(), &models.UpdateUserRequest{Email: "jane.roe@example.com"})
+	if err != nil || updated.Email != "jane.roe@example.com" {
+		t.Fatalf("system email change = %+v, %v", updated, err)
+	}
+}
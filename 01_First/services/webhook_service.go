@@ -0,0 +1,374 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"time"
+
+	"user-management-system/models"
+	"user-management-system/repositories"
+	"user-management-system/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// webhookLease is how long a dispatcher owns a claimed delivery
+	webhookLease = 2 * time.Minute
+
+	// Retry backoff grows exponentially from webhookBaseBackoff up to webhookMaxBackoff
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+)
+
+// WebhookService manages webhook subscriptions and delivers queued events
+type WebhookService struct {
+	webhookRepo  *repositories.WebhookRepository
+	deliveryRepo *repositories.WebhookDeliveryRepository
+	client       *http.Client
+	maxAttempts  int
+}
+
+// NewWebhookService creates a new webhook service. Deliveries that fail
+// maxAttempts times are moved to the dead-letter state.
+func NewWebhookService(webhookRepo *repositories.WebhookRepository, deliveryRepo *repositories.WebhookDeliveryRepository, client *http.Client, maxAttempts int) *WebhookService {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &WebhookService{
+		webhookRepo:  webhookRepo,
+		deliveryRepo: deliveryRepo,
+		client:       client,
+		maxAttempts:  maxAttempts,
+	}
+}
+
+// CreateSubscription registers a webhook endpoint and returns it with its signing secret
+func (s *WebhookService) CreateSubscription(ctx context.Context, req *models.CreateWebhookRequest) (*models.WebhookSubscription, string, error) {
+	if err := validateWebhookURL(req.URL); err != nil {
+		return nil, "", err
+	}
+	if err := validateWebhookEvents(req.Events); err != nil {
+		return nil, "", err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", errors.New("failed to generate secret")
+		}
+		secret = "whsec_" + hex.EncodeToString(buf)
+	} else if len(secret) < 16 {
+		return nil, "", errors.New("secret must be at least 16 characters")
+	}
+
+	sub := &models.WebhookSubscription{
+		URL:         req.URL,
+		Secret:      secret,
+		Events:      req.Events,
+		Description: req.Description,
+		IsActive:    true,
+	}
+
+	if err := s.webhookRepo.Create(ctx, sub); err != nil {
+		return nil, "", errors.New("failed to create webhook")
+	}
+
+	return sub, secret, nil
+}
+
+// ListSubscriptions retrieves all webhook subscriptions
+func (s *WebhookService) ListSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	return s.webhookRepo.FindAll(ctx)
+}
+
+// GetSubscription retrieves a webhook subscription by ID
+func (s *WebhookService) GetSubscription(ctx context.Context, id string) (*models.WebhookSubscription, error) {
+	return s.webhookRepo.FindByID(ctx, id)
+}
+
+// UpdateSubscription updates a webhook subscription
+func (s *WebhookService) UpdateSubscription(ctx context.Context, id string, req *models.UpdateWebhookRequest) (*models.WebhookSubscription, error) {
+	updateData := make(map[string]interface{})
+
+	if req.URL != "" {
+		if err := validateWebhookURL(req.URL); err != nil {
+			return nil, err
+		}
+		updateData["url"] = req.URL
+	}
+
+	if req.Events != nil {
+		if err := validateWebhookEvents(req.Events); err != nil {
+			return nil, err
+		}
+		updateData["events"] = req.Events
+	}
+
+	if req.Description != nil {
+		updateData["description"] = *req.Description
+	}
+
+	if req.IsActive != nil {
+		updateData["isActive"] = *req.IsActive
+	}
+
+	if err := s.webhookRepo.Update(ctx, id, updateData); err != nil {
+		return nil, err
+	}
+
+	return s.webhookRepo.FindByID(ctx, id)
+}
+
+// DeleteSubscription removes a webhook subscription; its pending deliveries are dead-lettered on their next attempt
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id string) error {
+	return s.webhookRepo.Delete(ctx, id)
+}
+
+// ListDeliveries retrieves the deliveries of a subscription with pagination
+func (s *WebhookService) ListDeliveries(ctx context.Context, subscriptionID, status string, page, limit int) ([]*models.WebhookDelivery, int, int64, error) {
+	// Validate pagination parameters
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100 // Max limit
+	}
+
+	if _, err := s.webhookRepo.FindByID(ctx, subscriptionID); err != nil {
+		return nil, 0, 0, err
+	}
+
+	deliveries, total, err := s.deliveryRepo.FindBySubscription(ctx, subscriptionID, status, page, limit)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	// Calculate total pages
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+
+	return deliveries, totalPages, total, nil
+}
+
+// GetDelivery retrieves a single delivery with its attempt log
+func (s *WebhookService) GetDelivery(ctx context.Context, subscriptionID, deliveryID string) (*models.WebhookDelivery, error) {
+	return s.deliveryRepo.FindByID(ctx, subscriptionID, deliveryID)
+}
+
+// Redeliver queues a delivery (usually a dead-lettered one) to be sent again immediately
+func (s *WebhookService) Redeliver(ctx context.Context, subscriptionID, deliveryID string) (*models.WebhookDelivery, error) {
+	delivery, err := s.deliveryRepo.FindByID(ctx, subscriptionID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.deliveryRepo.Requeue(ctx, delivery.ID); err != nil {
+		return nil, err
+	}
+
+	return s.deliveryRepo.FindByID(ctx, subscriptionID, deliveryID)
+}
+
+// Publish queues one delivery per active subscription interested in eventType.
+// Failures are logged rather than returned so publishing never breaks the mutation itself.
+//
+// The deliveries are written after the mutation has been saved, not in the same
+// transaction, so an event is lost if the process stops in between or the write
+// fails: events are published at most once. Queued deliveries are then retried
+// until acknowledged, so receivers must deduplicate on the event ID.
+func (s *WebhookService) Publish(ctx context.Context, eventType string, user *models.User) {
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	subs, err := s.webhookRepo.FindActiveForEvent(writeCtx, eventType)
+	if err != nil {
+		log.Printf("Failed to load webhook subscriptions for %s: %v", eventType, err)
+		return
+	}
+	if len(subs) == 0 {
+		return
+	}
+
+	event := models.WebhookEvent{
+		ID:        primitive.NewObjectID().Hex(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      user.ToUserResponse(),
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode webhook event %s: %v", eventType, err)
+		return
+	}
+
+	deliveries := make([]*models.WebhookDelivery, len(subs))
+	for i, sub := range subs {
+		deliveries[i] = &models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      eventType,
+			Payload:        string(payload),
+		}
+	}
+
+	if err := s.deliveryRepo.InsertMany(writeCtx, deliveries); err != nil {
+		log.Printf("Failed to queue webhook event %s: %v", eventType, err)
+	}
+}
+
+// DeliverDue sends every delivery that is currently due and returns how many were attempted
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	attempted := 0
+	for {
+		delivery, err := s.deliveryRepo.ClaimDue(ctx, webhookLease)
+		if err != nil {
+			return attempted, err
+		}
+		if delivery == nil {
+			return attempted, nil
+		}
+
+		s.deliver(ctx, delivery)
+		attempted++
+	}
+}
+
+// StartDispatcher delivers due webhooks with a pool of workers until ctx is cancelled.
+// Claims are leased in MongoDB, so several replicas can dispatch concurrently.
+func (s *WebhookService) StartDispatcher(ctx context.Context, interval time.Duration, workers int) {
+	startPollers(ctx, "Webhook dispatcher", interval, workers, func(ctx context.Context) error {
+		_, err := s.DeliverDue(ctx)
+		return err
+	})
+}
+
+// deliver sends one delivery and records the outcome
+func (s *WebhookService) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	sub, err := s.webhookRepo.FindByID(ctx, delivery.SubscriptionID.Hex())
+	if err != nil || !sub.IsActive {
+		reason := "webhook subscription removed"
+		if err == nil {
+			reason = "webhook subscription disabled"
+		}
+		if err := s.deliveryRepo.MarkDead(ctx, delivery.ID, reason); err != nil {
+			log.Printf("Failed to dead-letter webhook delivery %s: %v", delivery.ID.Hex(), err)
+		}
+		return
+	}
+
+	attempt := s.send(ctx, sub, delivery)
+
+	status := models.WebhookDeliveryDelivered
+	var nextAttemptAt time.Time
+	if attempt.Error != "" {
+		if delivery.Attempts+1 >= s.maxAttempts {
+			status = models.WebhookDeliveryDead
+		} else {
+			status = models.WebhookDeliveryPending
+			nextAttemptAt = time.Now().Add(webhookBackoff(delivery.Attempts + 1))
+		}
+	}
+
+	if err := s.deliveryRepo.RecordAttempt(ctx, delivery.ID, attempt, status, nextAttemptAt); err != nil {
+		log.Printf("Failed to record webhook attempt %s: %v", delivery.ID.Hex(), err)
+	}
+}
+
+// send POSTs the signed payload; any non-2xx response counts as a failure
+func (s *WebhookService) send(ctx context.Context, sub *models.WebhookSubscription, delivery *models.WebhookDelivery) models.WebhookAttempt {
+	start := time.Now()
+	attempt := models.WebhookAttempt{AttemptedAt: start}
+
+	payload := []byte(delivery.Payload)
+	timestamp := start.Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "user-management-webhooks/1.0")
+	req.Header.Set(utils.WebhookIDHeader, delivery.EventID)
+	req.Header.Set(utils.WebhookEventHeader, delivery.EventType)
+	req.Header.Set(utils.WebhookTimestampHeader, fmt.Sprintf("%d", timestamp))
+	req.Header.Set(utils.WebhookSignatureHeader, utils.SignWebhookPayload(sub.Secret, timestamp, payload))
+
+	resp, err := s.client.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+
+	return attempt
+}
+
+// webhookBackoff returns the delay before the next attempt, with up to 20% jitter
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookBaseBackoff
+	for i := 1; i < attempts && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxBackoff {
+		delay = webhookMaxBackoff
+	}
+
+	jitter := time.Duration(mathrand.Int63n(int64(delay) / 5))
+	return delay + jitter
+}
+
+// validateWebhookURL requires an absolute http or https URL
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// validateWebhookEvents requires at least one known event type (or "*")
+func validateWebhookEvents(events []string) error {
+	if len(events) == 0 {
+		return errors.New("at least one event type is required")
+	}
+
+	for _, event := range events {
+		if event == "*" {
+			continue
+		}
+		known := false
+		for _, eventType := range models.WebhookEventTypes {
+			if event == eventType {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown event type: %s", event)
+		}
+	}
+
+	return nil
+}
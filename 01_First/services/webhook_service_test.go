@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"user-management-system/models"
+	"user-management-system/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testWebhookSecret = "whsec_test_secret_value"
+
+// webhookReceiver is an httptest endpoint that answers with the queued status
+// codes (200 once they run out) and keeps every request it receives
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		receiver.mu.Lock()
+		receiver.requests = append(receiver.requests, receivedWebhook{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(receiver.statuses) > 0 {
+			status, receiver.statuses = receiver.statuses[0], receiver.statuses[1:]
+		}
+		receiver.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) received() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.requests...)
+}
+
+// subscribe registers the receiver for the given events
+func (env *testEnv) subscribe(receiver *webhookReceiver, events ...string) *models.WebhookSubscription {
+	env.t.Helper()
+
+	sub, _, err := env.webhookService.CreateSubscription(context.Background(), &models.CreateWebhookRequest{URL: receiver.URL, Events: events, Secret: testWebhookSecret})
+	if err != nil {
+		env.t.Fatalf("CreateSubscription: %v", err)
+	}
+	return sub
+}
+
+// deliveries returns the deliveries of a subscription, newest first
+func (env *testEnv) deliveries(sub *models.WebhookSubscription) []*models.WebhookDelivery {
+	env.t.Helper()
+
+	deliveries, _, _, err := env.webhookService.ListDeliveries(context.Background(), sub.ID.Hex(), "", 1, 100)
+	if err != nil {
+		env.t.Fatalf("ListDeliveries: %v", err)
+	}
+	return deliveries
+}
+
+// makeDeliveriesDue skips the retry backoff of every pending delivery
+func (env *testEnv) makeDeliveriesDue() {
+	env.t.Helper()
+
+	_, err := env.db.Collection("webhook_deliveries").UpdateMany(context.Background(), bson.M{"status": models.WebhookDeliveryPending}, bson.M{"$set": bson.M{"nextAttemptAt": time.Now().Add(-time.Second)}})
+	if err != nil {
+		env.t.Fatalf("making deliveries due: %v", err)
+	}
+}
+
+func TestWebhookDeliveryIsSigned(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	receiver := newWebhookReceiver(t)
+	sub := env.subscribe(receiver, models.WebhookEventUserCreated)
+
+	registered, err := env.userService.Register(ctx, &models.RegisterRequest{Name: "Jane Doe", Email: "jane@example.com", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if attempted, err := env.webhookService.DeliverDue(ctx); err != nil || attempted != 1 {
+		t.Fatalf("DeliverDue = %d, %v; want 1", attempted, err)
+	}
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(requests))
+	}
+	request := requests[0]
+	if !utils.VerifyWebhookSignature(testWebhookSecret, request.header.Get(utils.WebhookTimestampHeader), request.header.Get(utils.WebhookSignatureHeader), request.body, 5*time.Minute) {
+		t.Fatalf("signature %q does not verify", request.header.Get(utils.WebhookSignatureHeader))
+	}
+	if utils.VerifyWebhookSignature("whsec_some_other_secret", request.header.Get(utils.WebhookTimestampHeader), request.header.Get(utils.WebhookSignatureHeader), request.body, 5*time.Minute) {
+		t.Fatal("signature verifies with another secret")
+	}
+
+	var event models.WebhookEvent
+	if err := json.Unmarshal(request.body, &event); err != nil {
+		t.Fatalf("decoding event: %v", err)
+	}
+	if event.Type != models.WebhookEventUserCreated || request.header.Get(utils.WebhookEventHeader) != event.Type || request.header.Get(utils.WebhookIDHeader) != event.ID {
+		t.Fatalf("event %+v sent with headers %v", event, request.header)
+	}
+	data, _ := json.Marshal(event.Data)
+	if !jsonContains(data, "id", registered.ID) {
+		t.Fatalf("event data = %s, want the registered user", data)
+	}
+
+	deliveries := env.deliveries(sub)
+	if len(deliveries) != 1 || deliveries[0].Status != models.WebhookDeliveryDelivered || deliveries[0].Attempts != 1 {
+		t.Fatalf("deliveries = %+v", deliveries)
+	}
+}
+
+func TestWebhookOnlyMatchingSubscriptions(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	created := env.subscribe(newWebhookReceiver(t), models.WebhookEventUserCreated)
+	deleted := env.subscribe(newWebhookReceiver(t), models.WebhookEventUserDeleted)
+	all := env.subscribe(newWebhookReceiver(t), "*")
+
+	if _, err := env.userService.Register(ctx, &models.RegisterRequest{Name: "Jane Doe", Email: "jane@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if got := len(env.deliveries(created)); got != 1 {
+		t.Fatalf("user.created subscription has %d deliveries, want 1", got)
+	}
+	if got := len(env.deliveries(deleted)); got != 0 {
+		t.Fatalf("user.deleted subscription has %d deliveries, want 0", got)
+	}
+	if got := len(env.deliveries(all)); got != 1 {
+		t.Fatalf("wildcard subscription has %d deliveries, want 1", got)
+	}
+}
+
+func TestWebhookRetriesFailedDeliveries(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	sub := env.subscribe(receiver, models.WebhookEventUserCreated)
+
+	if _, err := env.userService.Register(ctx, &models.RegisterRequest{Name: "Jane Doe", Email: "jane@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	env.webhookService.DeliverDue(ctx)
+	delivery := env.deliveries(sub)[0]
+	if delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != 1 || delivery.LastError != "unexpected status 500" {
+		t.Fatalf("after a failed attempt delivery = %+v", delivery)
+	}
+	if !delivery.NextAttemptAt.After(time.Now().Add(20 * time.Second)) {
+		t.Fatalf("next attempt at %v, want it backed off", delivery.NextAttemptAt)
+	}
+
+	// Not due yet: nothing is sent
+	if attempted, _ := env.webhookService.DeliverDue(ctx); attempted != 0 {
+		t.Fatalf("DeliverDue attempted %d deliveries before the backoff elapsed", attempted)
+	}
+
+	env.makeDeliveriesDue()
+	env.webhookService.DeliverDue(ctx)
+	env.makeDeliveriesDue()
+	env.webhookService.DeliverDue(ctx)
+
+	delivery = env.deliveries(sub)[0]
+	if delivery.Status != models.WebhookDeliveryDelivered || delivery.Attempts != 3 || len(delivery.Logs) != 3 || delivery.DeliveredAt == nil {
+		t.Fatalf("after retries delivery = %+v", delivery)
+	}
+	if delivery.Logs[0].StatusCode != 500 || delivery.Logs[1].StatusCode != 503 || delivery.Logs[2].StatusCode != 200 {
+		t.Fatalf("attempt log = %+v", delivery.Logs)
+	}
+
+	// Every attempt carries the same event, so receivers can deduplicate
+	requests := receiver.received()
+	if len(requests) != 3 {
+		t.Fatalf("receiver got %d requests, want 3", len(requests))
+	}
+	for _, request := range requests {
+		if request.header.Get(utils.WebhookIDHeader) != delivery.EventID || string(request.body) != delivery.Payload {
+			t.Fatalf("retry sent a different event: %v %s", request.header, request.body)
+		}
+	}
+}
+
+func TestWebhookDeadLetterAndRedeliver(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	sub := env.subscribe(receiver, models.WebhookEventUserCreated)
+
+	if _, err := env.userService.Register(ctx, &models.RegisterRequest{Name: "Jane Doe", Email: "jane@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// The test environment allows 3 attempts
+	for i := 0; i < 3; i++ {
+		env.makeDeliveriesDue()
+		env.webhookService.DeliverDue(ctx)
+	}
+	delivery := env.deliveries(sub)[0]
+	if delivery.Status != models.WebhookDeliveryDead || delivery.Attempts != 3 {
+		t.Fatalf("after 3 failures delivery = %+v", delivery)
+	}
+	env.makeDeliveriesDue()
+	if attempted, _ := env.webhookService.DeliverDue(ctx); attempted != 0 {
+		t.Fatal("a dead delivery was attempted again")
+	}
+
+	if _, err := env.webhookService.Redeliver(ctx, sub.ID.Hex(), delivery.ID.Hex()); err != nil {
+		t.Fatalf("Redeliver: %v", err)
+	}
+	if attempted, _ := env.webhookService.DeliverDue(ctx); attempted != 1 {
+		t.Fatalf("DeliverDue attempted %d redelivered deliveries, want 1", attempted)
+	}
+	if delivery = env.deliveries(sub)[0]; delivery.Status != models.WebhookDeliveryDelivered {
+		t.Fatalf("after redelivery delivery = %+v", delivery)
+	}
+}
+
+func TestWebhookDisabledSubscriptionIsDeadLettered(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := systemContext()
+	receiver := newWebhookReceiver(t)
+	sub := env.subscribe(receiver, "*")
+
+	if _, err := env.userService.Register(ctx, &models.RegisterRequest{Name: "Jane Doe", Email: "jane@example.com", Password: testPassword}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	inactive := false
+	if _, err := env.webhookService.UpdateSubscription(ctx, sub.ID.Hex(), &models.UpdateWebhookRequest{IsActive: &inactive}); err != nil {
+		t.Fatalf("UpdateSubscription: %v", err)
+	}
+
+	env.webhookService.DeliverDue(ctx)
+	if len(receiver.received()) != 0 {
+		t.Fatal("a disabled subscription received a delivery")
+	}
+	if delivery := env.deliveries(sub)[0]; delivery.Status != models.WebhookDeliveryDead || delivery.LastError != "webhook subscription disabled" {
+		t.Fatalf("delivery = %+v", delivery)
+	}
+}
+
+// jsonContains reports whether a JSON object has the string value at key
+func jsonContains(data []byte, key, value string) bool {
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return false
+	}
+	return object[key] == value
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// forEachParallel calls fn for every index in [0, n) on a pool of at most workers
+// goroutines and waits for them to finish. Indexes are handed out in order;
+// once ctx is cancelled no new calls start and ctx.Err() is returned.
+// fn may write to index i of a pre-sized slice without further locking.
+func forEachParallel(ctx context.Context, n, workers int, fn func(i int)) error {
+	if workers > n {
+		workers = n
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int, workers*2) // Buffered channel
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	// Send all indexes to the worker pool
+	var err error
+send:
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break send
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return err
+}
+
+// startPollers runs poll on a pool of workers goroutines until ctx is cancelled.
+// Each worker calls poll, then waits for the next tick. Work is claimed with
+// leases in MongoDB, so pollers on several replicas can run side by side.
+func startPollers(ctx context.Context, name string, interval time.Duration, workers int, poll func(ctx context.Context) error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				if err := poll(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("%s error: %v", name, err)
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,38 @@
+package tenant
+
+import "context"
+
+// scopeKey is the context key of the organization scope
+type scopeKey struct{}
+
+// scope is the organization a request acts in. Repositories scope their
+// queries to it, so it is kept apart from the HTTP middleware. all marks an
+// explicit cross-organization context, as opposed to one that never had a scope.
+type scope struct {
+	orgID string
+	all   bool
+}
+
+// WithOrg returns a context scoped to the organization
+func WithOrg(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{orgID: orgID})
+}
+
+// AcrossOrgs returns a context that explicitly acts across all organizations,
+// e.g. for a super-admin or for lookups by globally unique email
+func AcrossOrgs(ctx context.Context) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope{all: true})
+}
+
+// OrgID returns the organization ctx is scoped to, or "" when it is not
+// scoped to one
+func OrgID(ctx context.Context) string {
+	s, _ := ctx.Value(scopeKey{}).(scope)
+	return s.orgID
+}
+
+// IsAcrossOrgs reports whether ctx explicitly acts across all organizations
+func IsAcrossOrgs(ctx context.Context) bool {
+	s, _ := ctx.Value(scopeKey{}).(scope)
+	return s.all
+}
@@ -9,26 +9,36 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// JWTClaims represents the JWT token claims
+// JWTClaims represents the JWT token claims. A token acts in one
+// organization; Role is the effective role there.
 type JWTClaims struct {
-	UserID string `json:"userId"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID     string `json:"userId"`
+	Email      string `json:"email"`
+	Role       string `json:"role"`
+	OrgID      string `json:"orgId"`
+	OrgRole    string `json:"orgRole,omitempty"`
+	SuperAdmin bool   `json:"superAdmin,omitempty"`
+
+	// Scope and ClientID are set on access tokens issued to OAuth clients,
+	// which also carry the client as audience. GrantID names the authorization
+	// code the token descends from, if any.
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	GrantID  string `json:"grant_id,omitempty"`
+
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token for a user
-func GenerateToken(userID, email, role string, cfg *config.Config) (string, error) {
-	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(cfg.JWTExpireHours) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+// GenerateToken generates a JWT token carrying the given user claims. The
+// token expires after JWT_EXPIRE_HOURS unless claims set another expiry;
+// other registered claims the caller sets, such as the audience, are kept.
+func GenerateToken(claims JWTClaims, cfg *config.Config) (string, error) {
+	now := time.Now()
+	if claims.ExpiresAt == nil {
+		claims.ExpiresAt = jwt.NewNumericDate(now.Add(time.Duration(cfg.JWTExpireHours) * time.Hour))
 	}
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))